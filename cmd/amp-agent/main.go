@@ -0,0 +1,42 @@
+// Command amp-agent runs amp on a build host for a remote ampd daemon. It
+// connects to the daemon's /api/agents/ws endpoint and runs the tasks
+// dispatched to it, streaming their logs back.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/agent"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/version"
+)
+
+func main() {
+	cfg := agent.Config{Version: version.String()}
+	flag.StringVar(&cfg.URL, "url", os.Getenv("AMPD_AGENT_URL"), "daemon agent endpoint, e.g. wss://ampd.example.com/api/agents/ws (AMPD_AGENT_URL)")
+	flag.StringVar(&cfg.Token, "token", os.Getenv("AGENT_TOKEN"), "agent token configured as agents.token on the daemon (AGENT_TOKEN)")
+	flag.StringVar(&cfg.Name, "name", "", "unique agent name (defaults to the hostname)")
+	flag.IntVar(&cfg.Capacity, "capacity", 1, "tasks run at once")
+	flag.StringVar(&cfg.AmpBinary, "amp-binary", "amp", "amp executable")
+	flag.StringVar(&cfg.WorkDir, "workdir", "", "directory amp runs in (defaults to the current directory)")
+	flag.StringVar(&cfg.LogDir, "log-dir", "", "directory for amp log files while they are streamed")
+	flag.DurationVar(&cfg.ReconnectDelay, "reconnect-delay", 0, "wait before reconnecting (defaults to 5s)")
+	flag.Parse()
+
+	if cfg.URL == "" || cfg.Token == "" {
+		log.Fatal("-url and -token are required")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("amp-agent %s connecting to %s", cfg.Version, cfg.URL)
+	if err := agent.New(cfg).Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,396 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Config configures an agent
+type Config struct {
+	URL            string        // Daemon's agent endpoint, e.g. wss://ampd.example.com/api/agents/ws
+	Token          string        // Shared agent token configured on the daemon
+	Name           string        // Unique name of this agent; defaults to the hostname
+	Capacity       int           // Concurrent runs accepted; defaults to 1
+	AmpBinary      string        // amp executable; defaults to "amp"
+	WorkDir        string        // Directory amp runs in; defaults to the current directory
+	LogDir         string        // Where amp's log files are kept while runs stream them
+	ReconnectDelay time.Duration // Wait before reconnecting after the connection drops
+	Version        string        // Reported to the daemon
+}
+
+// terminateTimeout is how long runs get to exit after SIGTERM when the
+// connection to the daemon drops
+const terminateTimeout = 10 * time.Second
+
+// Agent connects to the daemon and runs the amp invocations it dispatches
+type Agent struct {
+	cfg Config
+
+	ws      *websocket.Conn
+	writeMu sync.Mutex
+
+	mu   sync.Mutex
+	runs map[string]*exec.Cmd // Running processes by run ID
+	wg   sync.WaitGroup       // Runs still streaming output
+}
+
+// New creates an agent, filling in defaults for unset options
+func New(cfg Config) *Agent {
+	if cfg.Name == "" {
+		cfg.Name, _ = os.Hostname()
+	}
+	if cfg.Capacity < 1 {
+		cfg.Capacity = 1
+	}
+	if cfg.AmpBinary == "" {
+		cfg.AmpBinary = "amp"
+	}
+	if cfg.LogDir == "" {
+		cfg.LogDir = filepath.Join(os.TempDir(), "amp-agent")
+	}
+	if cfg.ReconnectDelay <= 0 {
+		cfg.ReconnectDelay = 5 * time.Second
+	}
+
+	return &Agent{
+		cfg:  cfg,
+		runs: make(map[string]*exec.Cmd),
+	}
+}
+
+// Run serves the daemon, reconnecting whenever the connection drops, until ctx
+// is cancelled
+func (a *Agent) Run(ctx context.Context) error {
+	if err := os.MkdirAll(a.cfg.LogDir, 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	for {
+		err := a.Serve(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("Connection to %s lost: %v; reconnecting in %s", a.cfg.URL, err, a.cfg.ReconnectDelay)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(a.cfg.ReconnectDelay):
+		}
+	}
+}
+
+// Serve connects to the daemon and runs its invocations until the connection
+// drops or ctx is cancelled. Runs still in progress are terminated, since
+// their output can no longer be delivered.
+func (a *Agent) Serve(ctx context.Context) error {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+a.cfg.Token)
+	ws, resp, err := websocket.DefaultDialer.DialContext(ctx, a.cfg.URL, header)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("failed to connect: %s", resp.Status)
+		}
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	ws.SetReadLimit(maxMessageSize)
+	a.ws = ws
+
+	defer func() {
+		a.terminateRuns()
+		ws.Close()
+	}()
+
+	// Close the connection to unblock reads when ctx is cancelled
+	stop := context.AfterFunc(ctx, func() { ws.Close() })
+	defer stop()
+
+	if err := a.register(); err != nil {
+		return err
+	}
+	log.Printf("Registered with %s as %s (capacity %d)", a.cfg.URL, a.cfg.Name, a.cfg.Capacity)
+
+	ws.SetReadDeadline(time.Now().Add(pongWait))
+	ws.SetPingHandler(func(data string) error {
+		ws.SetReadDeadline(time.Now().Add(pongWait))
+		a.writeMu.Lock()
+		defer a.writeMu.Unlock()
+		return ws.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(writeWait))
+	})
+
+	for {
+		var msg Message
+		if err := ws.ReadJSON(&msg); err != nil {
+			return err
+		}
+		ws.SetReadDeadline(time.Now().Add(pongWait))
+
+		switch msg.Type {
+		case MessageTypeStart:
+			var start StartMessage
+			if err := json.Unmarshal(msg.Data, &start); err != nil {
+				return fmt.Errorf("invalid start message: %w", err)
+			}
+			a.start(start)
+
+		case MessageTypeSignal:
+			var signal SignalMessage
+			if err := json.Unmarshal(msg.Data, &signal); err != nil {
+				return fmt.Errorf("invalid signal message: %w", err)
+			}
+			a.signal(signal.RunID, signal.Signal)
+
+		default:
+			log.Printf("Ignoring unexpected %q message", msg.Type)
+		}
+	}
+}
+
+// register introduces the agent and waits for the daemon to accept it
+func (a *Agent) register() error {
+	if err := a.send(MessageTypeRegister, RegisterMessage{
+		Name:     a.cfg.Name,
+		Capacity: a.cfg.Capacity,
+		Version:  a.cfg.Version,
+	}); err != nil {
+		return err
+	}
+
+	a.ws.SetReadDeadline(time.Now().Add(writeWait))
+	var msg Message
+	if err := a.ws.ReadJSON(&msg); err != nil {
+		return err
+	}
+	var registered RegisteredMessage
+	if msg.Type != MessageTypeRegistered || json.Unmarshal(msg.Data, &registered) != nil {
+		return errors.New("unexpected response to registration")
+	}
+	if registered.Error != "" {
+		return fmt.Errorf("registration rejected: %s", registered.Error)
+	}
+	return nil
+}
+
+// start runs amp for an invocation, streaming its output to the daemon
+func (a *Agent) start(start StartMessage) {
+	args := start.Args
+	ampLog := ""
+	if start.AmpLog {
+		ampLog = filepath.Join(a.cfg.LogDir, fmt.Sprintf("run-%s-amp.log", start.RunID))
+		args = append([]string{"--log-file", ampLog}, args...)
+	}
+
+	// Run amp directly rather than through a shell, so nothing in the message
+	// or arguments is interpreted on the agent's host
+	cmd := exec.Command(a.cfg.AmpBinary, args...)
+	cmd.Dir = a.cfg.WorkDir
+	if len(start.Env) > 0 {
+		cmd.Env = append(os.Environ(), start.Env...)
+	}
+
+	// Set the process group ID so signals reach amp and any processes it started
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	stdout := &streamWriter{agent: a, runID: start.RunID, stream: StreamStdout}
+	cmd.Stdout = stdout
+	cmd.Stderr = stdout
+
+	log.Printf("Starting run %s for worker %s on thread %s", start.RunID, start.WorkerID, start.ThreadID)
+	stdin, err := cmd.StdinPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
+		fmt.Fprintf(stdout, "[agent] failed to start amp: %v\n", err)
+		a.send(MessageTypeExit, ExitMessage{RunID: start.RunID, ExitCode: -1})
+		return
+	}
+
+	// Write the message in the background since amp may not read it at once
+	go func() {
+		defer stdin.Close()
+		io.WriteString(stdin, start.Message+"\n")
+	}()
+
+	a.mu.Lock()
+	a.runs[start.RunID] = cmd
+	a.mu.Unlock()
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+
+		followed := make(chan struct{})
+		stopFollowing := make(chan struct{})
+		if ampLog != "" {
+			go func() {
+				defer close(followed)
+				followFile(ampLog, &streamWriter{agent: a, runID: start.RunID, stream: StreamAmpLog}, stopFollowing)
+				os.Remove(ampLog)
+			}()
+		} else {
+			close(followed)
+		}
+
+		exitCode := 0
+		if err := cmd.Wait(); err != nil {
+			exitCode = -1
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				exitCode = exitErr.ExitCode()
+			}
+		}
+		close(stopFollowing)
+		<-followed
+
+		a.mu.Lock()
+		delete(a.runs, start.RunID)
+		a.mu.Unlock()
+
+		log.Printf("Run %s exited with code %d", start.RunID, exitCode)
+		a.send(MessageTypeExit, ExitMessage{RunID: start.RunID, ExitCode: exitCode})
+	}()
+}
+
+// signal sends a named signal to a run's process group
+func (a *Agent) signal(runID, name string) {
+	signals := map[string]syscall.Signal{
+		"SIGINT":  syscall.SIGINT,
+		"SIGTERM": syscall.SIGTERM,
+		"SIGKILL": syscall.SIGKILL,
+	}
+	sig, ok := signals[name]
+	if !ok {
+		log.Printf("Ignoring unknown signal %q for run %s", name, runID)
+		return
+	}
+
+	a.mu.Lock()
+	cmd, exists := a.runs[runID]
+	a.mu.Unlock()
+	if !exists || cmd.Process == nil {
+		return
+	}
+
+	log.Printf("Sending %s to run %s", name, runID)
+	if err := syscall.Kill(-cmd.Process.Pid, sig); err != nil {
+		cmd.Process.Signal(sig)
+	}
+}
+
+// terminateRuns sends SIGTERM to every running process, then SIGKILL to
+// those still running after terminateTimeout, and waits for them to exit
+func (a *Agent) terminateRuns() {
+	a.signalAll("SIGTERM")
+
+	exited := make(chan struct{})
+	go func() {
+		a.wg.Wait()
+		close(exited)
+	}()
+
+	select {
+	case <-exited:
+	case <-time.After(terminateTimeout):
+		a.signalAll("SIGKILL")
+		<-exited
+	}
+}
+
+// signalAll sends a named signal to every running process
+func (a *Agent) signalAll(name string) {
+	a.mu.Lock()
+	ids := make([]string, 0, len(a.runs))
+	for id := range a.runs {
+		ids = append(ids, id)
+	}
+	a.mu.Unlock()
+
+	for _, id := range ids {
+		a.signal(id, name)
+	}
+}
+
+// send writes a message to the daemon
+func (a *Agent) send(msgType MessageType, data interface{}) error {
+	raw, err := newMessage(msgType, data)
+	if err != nil {
+		return err
+	}
+
+	a.writeMu.Lock()
+	defer a.writeMu.Unlock()
+	a.ws.SetWriteDeadline(time.Now().Add(writeWait))
+	return a.ws.WriteMessage(websocket.TextMessage, raw)
+}
+
+// streamWriter sends everything written to it as output of a run
+type streamWriter struct {
+	agent  *Agent
+	runID  string
+	stream Stream
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	// Keep chunks within the daemon's message size limit
+	for offset := 0; offset < len(p); offset += maxChunkSize {
+		end := offset + maxChunkSize
+		if end > len(p) {
+			end = len(p)
+		}
+		if err := w.agent.send(MessageTypeOutput, OutputMessage{RunID: w.runID, Stream: w.stream, Data: p[offset:end]}); err != nil {
+			return offset, err
+		}
+	}
+	return len(p), nil
+}
+
+// maxChunkSize is the most output sent in one message. Output is base64
+// encoded, so this keeps messages well within maxMessageSize.
+const maxChunkSize = 256 << 10
+
+// followFile copies what is appended to path into w until stop is closed,
+// then copies whatever remains
+func followFile(path string, w io.Writer, stop <-chan struct{}) {
+	var file *os.File
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		stopping := false
+		select {
+		case <-stop:
+			stopping = true
+		case <-ticker.C:
+		}
+
+		if file == nil {
+			file, _ = os.Open(path)
+		}
+		if file != nil {
+			io.Copy(w, file)
+		}
+		if stopping {
+			return
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+// fakeAmp echoes its arguments and input, writes to its log file, and sleeps
+// when asked to
+const fakeAmp = `#!/bin/bash
+if [ "$1" = "--log-file" ]; then
+	log="$2"
+	shift 2
+fi
+input=$(cat)
+echo "args: $*"
+echo "input: $input"
+if [ -n "$log" ]; then
+	echo '{"level":"info","message":"from agent"}' >> "$log"
+fi
+if [ "$input" = "sleep" ]; then
+	sleep 30
+fi
+exit 3
+`
+
+// startAgent serves a pool and connects an agent to it
+func startAgent(t *testing.T, capacity int) (*Pool, context.CancelFunc) {
+	pool := NewPool("agent-secret")
+	server := httptest.NewServer(http.HandlerFunc(pool.ServeWS))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	ampPath := filepath.Join(dir, "amp")
+	require.NoError(t, os.WriteFile(ampPath, []byte(fakeAmp), 0755))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		New(Config{
+			URL:       "ws" + strings.TrimPrefix(server.URL, "http"),
+			Token:     "agent-secret",
+			Name:      "build-1",
+			Capacity:  capacity,
+			AmpBinary: ampPath,
+			LogDir:    filepath.Join(dir, "logs"),
+			Version:   "test",
+		}).Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+
+	require.Eventually(t, func() bool { return len(pool.Agents()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	return pool, cancel
+}
+
+// waitExit returns the exit code sent on done
+func waitExit(t *testing.T, done <-chan int) int {
+	select {
+	case code := <-done:
+		return code
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not exit")
+		return 0
+	}
+}
+
+func TestPool_RunsInvocationsOnAgent(t *testing.T) {
+	pool, _ := startAgent(t, 1)
+
+	agents := pool.Agents()
+	assert.True(t, pool.Available())
+	assert.Equal(t, "build-1", agents[0].Name)
+	assert.Equal(t, "test", agents[0].Version)
+	assert.Equal(t, 1, agents[0].Capacity)
+
+	var stdout, ampLog bytes.Buffer
+	name, done, err := pool.Start(worker.Invocation{
+		WorkerID: "w1",
+		ThreadID: "T-1",
+		Message:  "hello $(echo injected)",
+		Args:     []string{"--mcp-config=$(echo x)", "--log-level=debug", "threads", "continue", "T-1"},
+		AmpLog:   true,
+	}, &stdout, &ampLog)
+	require.NoError(t, err)
+	assert.Equal(t, "build-1", name)
+
+	assert.Equal(t, 3, waitExit(t, done))
+	assert.Equal(t, "args: --mcp-config=$(echo x) --log-level=debug threads continue T-1\ninput: hello $(echo injected)\n", stdout.String())
+	assert.Equal(t, `{"level":"info","message":"from agent"}`+"\n", ampLog.String())
+	assert.False(t, pool.Running("w1"))
+}
+
+func TestPool_SignalsAndCapacity(t *testing.T) {
+	pool, _ := startAgent(t, 1)
+
+	var stdout bytes.Buffer
+	_, done, err := pool.Start(worker.Invocation{WorkerID: "w1", Message: "sleep"}, &stdout, &bytes.Buffer{})
+	require.NoError(t, err)
+	assert.True(t, pool.Running("w1"))
+	assert.Equal(t, 1, pool.Agents()[0].Running)
+
+	// The only agent is busy
+	assert.False(t, pool.Available())
+	_, _, err = pool.Start(worker.Invocation{WorkerID: "w2", Message: "hello"}, &bytes.Buffer{}, &bytes.Buffer{})
+	assert.ErrorIs(t, err, worker.ErrNoAgentAvailable)
+
+	require.NoError(t, pool.Signal("w1", "SIGTERM"))
+	assert.Equal(t, -1, waitExit(t, done))
+	assert.False(t, pool.Running("w1"))
+}
+
+func TestPool_AgentDisconnectEndsRuns(t *testing.T) {
+	pool, cancel := startAgent(t, 2)
+
+	var stdout bytes.Buffer
+	_, done, err := pool.Start(worker.Invocation{WorkerID: "w1", Message: "sleep"}, &stdout, &bytes.Buffer{})
+	require.NoError(t, err)
+
+	cancel()
+	assert.Equal(t, -1, waitExit(t, done))
+	assert.Contains(t, stdout.String(), "[agent] lost connection to agent build-1\n")
+	assert.Empty(t, pool.Agents())
+
+	_, _, err = pool.Start(worker.Invocation{WorkerID: "w2"}, &bytes.Buffer{}, &bytes.Buffer{})
+	assert.ErrorIs(t, err, worker.ErrNoAgentAvailable)
+}
+
+func TestPool_RejectsInvalidToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(NewPool("agent-secret").ServeWS))
+	defer server.Close()
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer wrong")
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), header)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
@@ -0,0 +1,379 @@
+package agent
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+)
+
+// Info describes a connected agent
+type Info struct {
+	Name        string    `json:"name"`
+	Version     string    `json:"version,omitempty"`
+	Capacity    int       `json:"capacity"`
+	Running     int       `json:"running"`
+	ConnectedAt time.Time `json:"connected_at"`
+	RemoteAddr  string    `json:"remote_addr"`
+}
+
+// Pool tracks the agents connected to the daemon and dispatches amp
+// invocations to them. It implements worker.AgentPool.
+type Pool struct {
+	token    string
+	upgrader websocket.Upgrader
+
+	mu     sync.Mutex
+	agents map[string]*conn // Registered agents by name
+	runs   map[string]*run  // Running invocations by run ID
+}
+
+// conn is a registered agent's connection
+type conn struct {
+	info    Info
+	ws      *websocket.Conn
+	writeMu sync.Mutex
+	runs    map[string]*run
+}
+
+// run is an invocation running on an agent
+type run struct {
+	id       string
+	workerID string
+	agent    *conn
+	stdout   io.Writer
+	ampLog   io.Writer
+	done     chan int
+}
+
+var _ worker.AgentPool = (*Pool)(nil)
+
+// NewPool creates a pool accepting agents that present token
+func NewPool(token string) *Pool {
+	return &Pool{
+		token:  token,
+		agents: make(map[string]*conn),
+		runs:   make(map[string]*run),
+	}
+}
+
+// Agents lists the connected agents by name
+func (p *Pool) Agents() []Info {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	agents := make([]Info, 0, len(p.agents))
+	for _, agent := range p.agents {
+		info := agent.info
+		info.Running = len(agent.runs)
+		agents = append(agents, info)
+	}
+	sort.Slice(agents, func(i, j int) bool { return agents[i].Name < agents[j].Name })
+	return agents
+}
+
+// ServeWS upgrades an agent's connection and serves it until it disconnects
+func (p *Pool) ServeWS(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if p.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(p.token)) != 1 {
+		log.Printf("Agent authentication failed for %s", r.RemoteAddr)
+		response.Error(w, http.StatusUnauthorized, "Invalid agent token")
+		return
+	}
+
+	ws, err := p.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Agent WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer ws.Close()
+	ws.SetReadLimit(maxMessageSize)
+
+	agent, err := p.register(ws, r.RemoteAddr)
+	if err != nil {
+		log.Printf("Agent registration from %s failed: %v", r.RemoteAddr, err)
+		return
+	}
+	log.Printf("Agent %s connected from %s with capacity %d", agent.info.Name, r.RemoteAddr, agent.info.Capacity)
+
+	stop := make(chan struct{})
+	go agent.ping(stop)
+
+	err = p.read(agent)
+	close(stop)
+	p.disconnect(agent)
+	log.Printf("Agent %s disconnected: %v", agent.info.Name, err)
+}
+
+// register reads the agent's register message and adds it to the pool
+func (p *Pool) register(ws *websocket.Conn, remoteAddr string) (*conn, error) {
+	ws.SetReadDeadline(time.Now().Add(writeWait))
+	var msg Message
+	if err := ws.ReadJSON(&msg); err != nil {
+		return nil, err
+	}
+	var register RegisterMessage
+	if msg.Type != MessageTypeRegister || json.Unmarshal(msg.Data, &register) != nil {
+		return nil, errors.New("expected a register message")
+	}
+
+	agent := &conn{
+		info: Info{
+			Name:        register.Name,
+			Version:     register.Version,
+			Capacity:    register.Capacity,
+			ConnectedAt: time.Now(),
+			RemoteAddr:  remoteAddr,
+		},
+		ws:   ws,
+		runs: make(map[string]*run),
+	}
+
+	p.mu.Lock()
+	switch {
+	case register.Name == "":
+		err := errors.New("agent name is required")
+		p.mu.Unlock()
+		agent.send(MessageTypeRegistered, RegisteredMessage{Error: err.Error()})
+		return nil, err
+	case p.agents[register.Name] != nil:
+		err := fmt.Errorf("agent %s is already connected", register.Name)
+		p.mu.Unlock()
+		agent.send(MessageTypeRegistered, RegisteredMessage{Error: err.Error()})
+		return nil, err
+	}
+	if agent.info.Capacity < 1 {
+		agent.info.Capacity = 1
+	}
+	p.agents[register.Name] = agent
+	p.mu.Unlock()
+
+	if err := agent.send(MessageTypeRegistered, RegisteredMessage{}); err != nil {
+		p.disconnect(agent)
+		return nil, err
+	}
+	return agent, nil
+}
+
+// read handles the agent's messages until the connection fails
+func (p *Pool) read(agent *conn) error {
+	agent.ws.SetReadDeadline(time.Now().Add(pongWait))
+	agent.ws.SetPongHandler(func(string) error {
+		agent.ws.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	for {
+		var msg Message
+		if err := agent.ws.ReadJSON(&msg); err != nil {
+			return err
+		}
+		agent.ws.SetReadDeadline(time.Now().Add(pongWait))
+
+		switch msg.Type {
+		case MessageTypeOutput:
+			var output OutputMessage
+			if err := json.Unmarshal(msg.Data, &output); err != nil {
+				return fmt.Errorf("invalid output message: %w", err)
+			}
+			if run := p.run(agent, output.RunID); run != nil {
+				if output.Stream == StreamAmpLog {
+					run.ampLog.Write(output.Data)
+				} else {
+					run.stdout.Write(output.Data)
+				}
+			}
+
+		case MessageTypeExit:
+			var exit ExitMessage
+			if err := json.Unmarshal(msg.Data, &exit); err != nil {
+				return fmt.Errorf("invalid exit message: %w", err)
+			}
+			p.finish(agent, exit.RunID, exit.ExitCode)
+
+		default:
+			log.Printf("Agent %s sent unexpected %q message", agent.info.Name, msg.Type)
+		}
+	}
+}
+
+// run returns one of the agent's runs
+func (p *Pool) run(agent *conn, runID string) *run {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return agent.runs[runID]
+}
+
+// finish removes a run and reports its exit code
+func (p *Pool) finish(agent *conn, runID string, exitCode int) {
+	p.mu.Lock()
+	run, exists := agent.runs[runID]
+	delete(agent.runs, runID)
+	delete(p.runs, runID)
+	p.mu.Unlock()
+
+	if exists {
+		run.done <- exitCode
+	}
+}
+
+// disconnect removes the agent, ending its runs since their output can no
+// longer be received
+func (p *Pool) disconnect(agent *conn) {
+	p.mu.Lock()
+	if p.agents[agent.info.Name] == agent {
+		delete(p.agents, agent.info.Name)
+	}
+	runs := make([]*run, 0, len(agent.runs))
+	for id, run := range agent.runs {
+		runs = append(runs, run)
+		delete(p.runs, id)
+	}
+	agent.runs = make(map[string]*run)
+	p.mu.Unlock()
+
+	for _, run := range runs {
+		fmt.Fprintf(run.stdout, "[agent] lost connection to agent %s\n", agent.info.Name)
+		run.done <- -1
+	}
+}
+
+// Start dispatches inv to the connected agent with the most spare capacity
+func (p *Pool) Start(inv worker.Invocation, stdout, ampLog io.Writer) (string, <-chan int, error) {
+	p.mu.Lock()
+	var agent *conn
+	for _, candidate := range p.agents {
+		spare := candidate.spare()
+		if spare <= 0 {
+			continue
+		}
+		// Ties go to the first agent by name so dispatch is predictable
+		if agent == nil || spare > agent.spare() || (spare == agent.spare() && candidate.info.Name < agent.info.Name) {
+			agent = candidate
+		}
+	}
+	if agent == nil {
+		p.mu.Unlock()
+		return "", nil, worker.ErrNoAgentAvailable
+	}
+
+	run := &run{
+		id:       uuid.New().String(),
+		workerID: inv.WorkerID,
+		agent:    agent,
+		stdout:   stdout,
+		ampLog:   ampLog,
+		done:     make(chan int, 1),
+	}
+	agent.runs[run.id] = run
+	p.runs[run.id] = run
+	p.mu.Unlock()
+
+	if err := agent.send(MessageTypeStart, StartMessage{RunID: run.id, Invocation: inv}); err != nil {
+		p.mu.Lock()
+		delete(agent.runs, run.id)
+		delete(p.runs, run.id)
+		p.mu.Unlock()
+		return "", nil, fmt.Errorf("failed to dispatch to agent %s: %w", agent.info.Name, err)
+	}
+
+	log.Printf("Dispatched run %s of worker %s to agent %s", run.id, inv.WorkerID, agent.info.Name)
+	return agent.info.Name, run.done, nil
+}
+
+// Signal sends signal to the worker's runs
+func (p *Pool) Signal(workerID, signal string) error {
+	p.mu.Lock()
+	var runs []*run
+	for _, run := range p.runs {
+		if run.workerID == workerID {
+			runs = append(runs, run)
+		}
+	}
+	p.mu.Unlock()
+
+	var errs []error
+	for _, run := range runs {
+		if err := run.agent.send(MessageTypeSignal, SignalMessage{RunID: run.id, Signal: signal}); err != nil {
+			errs = append(errs, fmt.Errorf("agent %s: %w", run.agent.info.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Available reports whether any connected agent has spare capacity
+func (p *Pool) Available() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, agent := range p.agents {
+		if agent.spare() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Running reports whether any of the worker's runs are still running
+func (p *Pool) Running(workerID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, run := range p.runs {
+		if run.workerID == workerID {
+			return true
+		}
+	}
+	return false
+}
+
+// spare returns how many more runs the agent accepts. The pool's lock must be held.
+func (c *conn) spare() int {
+	return c.info.Capacity - len(c.runs)
+}
+
+// send writes a message to the agent
+func (c *conn) send(msgType MessageType, data interface{}) error {
+	raw, err := newMessage(msgType, data)
+	if err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.ws.WriteMessage(websocket.TextMessage, raw)
+}
+
+// ping keeps the connection alive until stop is closed
+func (c *conn) ping(stop <-chan struct{}) {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			c.writeMu.Lock()
+			err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait))
+			c.writeMu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
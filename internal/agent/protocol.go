@@ -0,0 +1,102 @@
+// Package agent runs worker amp invocations on remote machines. A Pool in the
+// daemon dispatches invocations to Agents connected to it over WebSocket, and
+// each Agent runs amp locally and streams its output back.
+package agent
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+// MessageType identifies a message exchanged between the daemon and an agent
+type MessageType string
+
+const (
+	// Agent -> daemon
+	MessageTypeRegister MessageType = "register" // First message after connecting
+	MessageTypeOutput   MessageType = "output"
+	MessageTypeExit     MessageType = "exit"
+
+	// Daemon -> agent
+	MessageTypeRegistered MessageType = "registered"
+	MessageTypeStart      MessageType = "start"
+	MessageTypeSignal     MessageType = "signal"
+)
+
+// Stream identifies which of a run's logs output belongs to
+type Stream string
+
+const (
+	StreamStdout Stream = "stdout" // amp's combined stdout and stderr
+	StreamAmpLog Stream = "amp"    // amp's --log-file
+)
+
+const (
+	// Time allowed to write a message to the peer
+	writeWait = 10 * time.Second
+
+	// Time allowed to read the next message or pong from the peer
+	pongWait = 60 * time.Second
+
+	// Send pings to the peer with this period. Must be less than pongWait
+	pingPeriod = (pongWait * 9) / 10
+
+	// Maximum message size allowed from the peer
+	maxMessageSize = 1 << 20
+)
+
+// Message is the envelope of every message on an agent connection
+type Message struct {
+	Type MessageType     `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// RegisterMessage introduces an agent to the daemon
+type RegisterMessage struct {
+	Name     string `json:"name"`
+	Capacity int    `json:"capacity"` // Concurrent runs the agent accepts
+	Version  string `json:"version,omitempty"`
+}
+
+// RegisteredMessage acknowledges a registration. Error is set when the daemon
+// rejected it and is about to close the connection.
+type RegisteredMessage struct {
+	Error string `json:"error,omitempty"`
+}
+
+// StartMessage asks an agent to run amp
+type StartMessage struct {
+	RunID string `json:"run_id"`
+	worker.Invocation
+}
+
+// SignalMessage asks an agent to signal a run's process group
+type SignalMessage struct {
+	RunID  string `json:"run_id"`
+	Signal string `json:"signal"` // e.g. SIGTERM
+}
+
+// OutputMessage carries output written by a run
+type OutputMessage struct {
+	RunID  string `json:"run_id"`
+	Stream Stream `json:"stream"`
+	Data   []byte `json:"data"`
+}
+
+// ExitMessage reports that a run's process exited. It's sent after all of
+// the run's output.
+type ExitMessage struct {
+	RunID    string `json:"run_id"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// newMessage wraps data in a message envelope
+func newMessage(msgType MessageType, data interface{}) ([]byte, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Message{Type: msgType, Data: raw})
+}
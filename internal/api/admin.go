@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+)
+
+// RoleAdmin is the token role allowed to call /api/admin endpoints
+const RoleAdmin = "admin"
+
+// SetAuthenticator restricts /api/admin endpoints to callers presenting an
+// admin token, records the caller as the owner of the tasks they start, and
+// only lets owners and admins stop, abort or delete tasks. Without one, these
+// endpoints are open like the rest of the API.
+func (h *TaskHandler) SetAuthenticator(authenticate hub.Authenticator) {
+	h.authenticate = authenticate
+}
+
+// requireAdmin checks that the caller may use admin endpoints
+func (h *TaskHandler) requireAdmin(r *http.Request) error {
+	if h.authenticate == nil {
+		return nil
+	}
+
+	identity := h.authenticate.Identify(r)
+	if identity == nil {
+		return apierr.New(http.StatusUnauthorized, "Admin token required")
+	}
+	if identity.Role != RoleAdmin {
+		return apierr.New(http.StatusForbidden, "Admin role required")
+	}
+	return nil
+}
+
+// BackfillThreads re-parses worker logs into the thread messages missing from
+// their threads and reports what was added
+func (h *TaskHandler) BackfillThreads(w http.ResponseWriter, r *http.Request) error {
+	if err := h.requireAdmin(r); err != nil {
+		return err
+	}
+
+	// An empty body backfills every task
+	var req BackfillThreadsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		return apierr.BadRequest("Invalid JSON request body")
+	}
+
+	report, err := h.manager.BackfillThreads(req.TaskIDs, req.DryRun, func(done, total int, result worker.BackfillResult) {
+		log.Printf("Thread backfill %d/%d: task %s %s (%d messages)", done, total, result.TaskID, result.Status, result.Added)
+	})
+	if err != nil {
+		return taskError(err, "backfill threads")
+	}
+	return response.OK(w, report)
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+func backfillRequest(router http.Handler, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/api/admin/backfill-threads", strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestBackfillThreads(t *testing.T) {
+	handler, _ := setupHierarchyHandler(t)
+	router := NewRouter(handler, handler.hub)
+
+	w := backfillRequest(router, "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var report worker.BackfillReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, 3, report.Tasks)
+	assert.Len(t, report.Results, 3)
+
+	w = backfillRequest(router, "", `{"task_ids":["child1"],"dry_run":true}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.True(t, report.DryRun)
+	require.Len(t, report.Results, 1)
+	assert.Equal(t, "child1", report.Results[0].TaskID)
+
+	w = backfillRequest(router, "", `{"task_ids":["missing"]}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = backfillRequest(router, "", `{`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestBackfillThreads_RequiresAdmin(t *testing.T) {
+	handler, _ := setupHierarchyHandler(t)
+	handler.SetAuthenticator(hub.TokenAuthenticator(map[string]hub.Identity{
+		"admin-token": {User: "alice", Role: RoleAdmin},
+		"user-token":  {User: "bob"},
+	}))
+	router := NewRouter(handler, handler.hub)
+
+	assert.Equal(t, http.StatusUnauthorized, backfillRequest(router, "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, backfillRequest(router, "wrong", "").Code)
+	assert.Equal(t, http.StatusForbidden, backfillRequest(router, "user-token", "").Code)
+	assert.Equal(t, http.StatusOK, backfillRequest(router, "admin-token", "").Code)
+}
+
+func TestRebuildTaskThread(t *testing.T) {
+	handler, manager := setupHierarchyHandler(t)
+	handler.SetAuthenticator(hub.TokenAuthenticator(map[string]hub.Identity{
+		"admin-token": {User: "alice", Role: RoleAdmin},
+		"user-token":  {User: "bob"},
+	}))
+	router := NewRouter(handler, handler.hub)
+	require.NoError(t, manager.AppendThreadMessage("child1", worker.MessageTypeSystem, "stale", nil))
+
+	rebuild := func(taskID, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/tasks/"+taskID+"/thread/rebuild", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, rebuild("child1", "").Code)
+	assert.Equal(t, http.StatusForbidden, rebuild("child1", "user-token").Code)
+	assert.Equal(t, http.StatusNotFound, rebuild("missing", "admin-token").Code)
+
+	w := rebuild("child1", "admin-token")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var result worker.ThreadRebuild
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, worker.ThreadRebuild{TaskID: "child1", Previous: 1}, result)
+
+	count, err := manager.CountThreadMessages("child1")
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
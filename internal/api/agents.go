@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/agent"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+)
+
+// SetAgentPool accepts remote agents into pool, which runs tasks started with
+// remote execution
+func (h *TaskHandler) SetAgentPool(pool *agent.Pool) {
+	h.agents = pool
+}
+
+// AgentHandler serves the remote agents connected to the daemon
+type AgentHandler struct {
+	pool         *agent.Pool
+	requireAdmin func(r *http.Request) error // Checks the caller may list agents
+}
+
+// NewAgentHandler creates a new agent handler, listing agents only to callers
+// requireAdmin accepts
+func NewAgentHandler(pool *agent.Pool, requireAdmin func(r *http.Request) error) *AgentHandler {
+	return &AgentHandler{pool: pool, requireAdmin: requireAdmin}
+}
+
+// ListAgents returns the connected agents and how busy they are
+func (h *AgentHandler) ListAgents(w http.ResponseWriter, r *http.Request) error {
+	if err := h.requireAdmin(r); err != nil {
+		return err
+	}
+	if h.pool == nil {
+		return apierr.NotFound("Remote agents are not enabled")
+	}
+	return response.OK(w, AgentsResponse{Agents: h.pool.Agents()})
+}
+
+// ServeAgentWS accepts an agent's connection
+func (h *AgentHandler) ServeAgentWS(w http.ResponseWriter, r *http.Request) error {
+	if h.pool == nil {
+		return apierr.NotFound("Remote agents are not enabled")
+	}
+	h.pool.ServeWS(w, r)
+	return nil
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/agent"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+)
+
+func TestListAgents(t *testing.T) {
+	handler, manager := setupHierarchyHandler(t)
+
+	w := httptest.NewRecorder()
+	NewRouter(handler, handler.hub).ServeHTTP(w, httptest.NewRequest("GET", "/api/agents", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	pool := agent.NewPool("agent-secret")
+	handler.SetAgentPool(pool)
+	manager.SetAgentPool(pool)
+	router := NewRouter(handler, handler.hub)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/agents", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp AgentsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Empty(t, resp.Agents)
+
+	// Agents must present the agent token
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/agents/ws", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), `"message":"Invalid agent token"`)
+
+	// Remote tasks fail fast while no agent is connected
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/tasks", strings.NewReader(`{"message":"hi","execution":"remote"}`)))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "no_agent_available")
+}
+
+func TestListAgents_RequiresAdmin(t *testing.T) {
+	handler, _ := setupHierarchyHandler(t)
+	handler.SetAgentPool(agent.NewPool("agent-secret"))
+	handler.SetAuthenticator(hub.TokenAuthenticator(map[string]hub.Identity{
+		"admin-token": {User: "root", Role: RoleAdmin},
+		"user-token":  {User: "alice"},
+	}))
+	router := NewRouter(handler, handler.hub)
+
+	for token, code := range map[string]int{"": http.StatusUnauthorized, "user-token": http.StatusForbidden, "admin-token": http.StatusOK} {
+		req := httptest.NewRequest("GET", "/api/agents", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, code, w.Code, token)
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+)
+
+// GetTaskAmpLogs serves the debug log amp writes for a task, one JSON object
+// per line, for debugging how its thread was parsed. Supports ?tail=n for the
+// last n lines, and a Range header or ?offset=n for raw bytes as on
+// GetTaskLogs.
+func (h *LogHandler) GetTaskAmpLogs(w http.ResponseWriter, r *http.Request) error {
+	taskID := chi.URLParam(r, "id")
+
+	var tailLines int
+	if tailParam := r.URL.Query().Get("tail"); tailParam != "" {
+		var err error
+		tailLines, err = strconv.Atoi(tailParam)
+		if err != nil || tailLines < 0 {
+			return apierr.BadRequest("Invalid tail parameter")
+		}
+	}
+
+	var byteRange *logRange
+	rangeHeader := r.Header.Get("Range")
+	offsetParam := r.URL.Query().Get("offset")
+	if rangeHeader != "" && offsetParam != "" {
+		return apierr.BadRequest("Range and offset cannot be combined")
+	}
+	if rangeHeader != "" {
+		parsed, err := parseLogRange(rangeHeader)
+		if err != nil {
+			return err
+		}
+		byteRange = &parsed
+	}
+	if offsetParam != "" {
+		offset, err := strconv.ParseInt(offsetParam, 10, 64)
+		if err != nil || offset < 0 {
+			return apierr.BadRequest("Invalid offset parameter")
+		}
+		byteRange = &logRange{start: offset, end: -1, offset: true}
+	}
+	if byteRange != nil && tailLines > 0 {
+		return apierr.BadRequest("Byte ranges cannot be combined with tail")
+	}
+
+	file, err := h.manager.OpenAmpLog(taskID)
+	if errors.Is(err, worker.ErrLogNotFound) {
+		return apierr.NotFound("amp log not found")
+	}
+	if err != nil {
+		return taskError(err, "open amp log")
+	}
+	defer file.Close()
+
+	if byteRange != nil {
+		return serveLogRange(w, file, *byteRange)
+	}
+
+	var lines []string
+	if tailLines > 0 {
+		if seeker, ok := file.(io.ReadSeeker); ok {
+			offset, err := tailOffset(seeker, tailLines)
+			if err == nil {
+				_, err = seeker.Seek(offset, io.SeekStart)
+			}
+			if err != nil {
+				return apierr.WrapInternal(err, "Failed to read amp log")
+			}
+		}
+		lines, err = readLastLines(worker.NewLineReader(file, h.manager.LineLimit()), tailLines)
+		if err != nil {
+			return apierr.WrapInternal(err, "Failed to read amp log")
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Accept-Ranges", "bytes")
+
+	if tailLines > 0 {
+		for _, line := range lines {
+			w.Write([]byte(line + "\n"))
+		}
+		return nil
+	}
+	io.Copy(w, file)
+	return nil
+}
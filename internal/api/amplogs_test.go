@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+func TestGetTaskAmpLogs(t *testing.T) {
+	router, manager, _ := setupFakeRouter(t)
+	manager.Add(
+		&worker.Worker{ID: "amp", Status: worker.StatusCompleted},
+		&worker.Worker{ID: "script", Status: worker.StatusCompleted, Backend: "script"},
+	)
+	manager.SetAmpLog("amp", `{"level":"debug","message":"a"}`+"\n"+`{"level":"debug","message":"b"}`+"\n")
+
+	w := serve(router, "GET", "/api/tasks/amp/amp-logs", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	assert.Equal(t, `{"level":"debug","message":"a"}`+"\n"+`{"level":"debug","message":"b"}`+"\n", w.Body.String())
+
+	w = serve(router, "GET", "/api/tasks/amp/amp-logs?tail=1", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"level":"debug","message":"b"}`+"\n", w.Body.String())
+
+	w = serve(router, "GET", "/api/tasks/amp/amp-logs?offset=32", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"level":"debug","message":"b"}`+"\n", w.Body.String())
+
+	w = serve(router, "GET", "/api/tasks/amp/amp-logs?tail=1&offset=0", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Agents other than amp don't write one
+	w = serve(router, "GET", "/api/tasks/script/amp-logs", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serve(router, "GET", "/api/tasks/missing/amp-logs", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
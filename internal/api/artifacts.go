@@ -0,0 +1,41 @@
+package api
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"path"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+)
+
+// ListTaskArtifacts returns the files a task left in its artifacts directory
+func (h *TaskHandler) ListTaskArtifacts(w http.ResponseWriter, r *http.Request) error {
+	artifacts, err := h.manager.ListArtifacts(chi.URLParam(r, "id"))
+	if err != nil {
+		return taskError(err, "list task artifacts")
+	}
+	return response.OK(w, ArtifactsResponse{Artifacts: artifacts})
+}
+
+// DownloadTaskArtifact serves one of a task's artifacts as an attachment,
+// with its detected content type. Range requests are supported.
+func (h *TaskHandler) DownloadTaskArtifact(w http.ResponseWriter, r *http.Request) error {
+	file, artifact, err := h.manager.OpenArtifact(chi.URLParam(r, "id"), chi.URLParam(r, "*"))
+	if errors.Is(err, worker.ErrArtifactNotFound) {
+		return apierr.Wrap(err, http.StatusNotFound, "Artifact not found")
+	}
+	if err != nil {
+		return taskError(err, "download task artifact")
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", artifact.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(artifact.Name)}))
+	http.ServeContent(w, r, artifact.Name, artifact.Modified, file)
+	return nil
+}
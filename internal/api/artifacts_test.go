@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskArtifacts(t *testing.T) {
+	handler, manager := setupHierarchyHandler(t)
+	router := NewRouter(handler, handler.hub)
+
+	get := func(path string, headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// No artifacts until the task produces some
+	w := get("/api/tasks/parent/artifacts")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"artifacts":[]}`, w.Body.String())
+
+	dir, err := manager.ArtifactsDir("parent")
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "reports"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "reports", "coverage.json"), []byte(`{"total": 81.5}`), 0644))
+
+	w = get("/api/tasks/parent/artifacts")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp ArtifactsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Artifacts, 1)
+	assert.Equal(t, "reports/coverage.json", resp.Artifacts[0].Name)
+	assert.Equal(t, "application/json", resp.Artifacts[0].ContentType)
+
+	w = get("/api/tasks/parent/artifacts/reports/coverage.json")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename=coverage.json`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, `{"total": 81.5}`, w.Body.String())
+
+	w = get("/api/tasks/parent/artifacts/reports/coverage.json", "Range", "bytes=0-7")
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, `{"total"`, w.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, get("/api/tasks/parent/artifacts/missing.txt").Code)
+	assert.Equal(t, http.StatusNotFound, get("/api/tasks/parent/artifacts/..%2Fworkers.json").Code)
+	assert.Equal(t, http.StatusNotFound, get("/api/tasks/missing/artifacts").Code)
+	assert.Equal(t, http.StatusNotFound, get("/api/tasks/missing/artifacts/report.txt").Code)
+}
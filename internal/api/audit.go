@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/audit"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+)
+
+// AuditHandler serves the audit log of mutating API calls
+type AuditHandler struct {
+	log *audit.Log
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(log *audit.Log) *AuditHandler {
+	return &AuditHandler{log: log}
+}
+
+// ListAudit returns audited calls, newest first, filtered by ?user=, ?task=,
+// ?method=, ?outcome=, ?since= and ?until=
+func (h *AuditHandler) ListAudit(w http.ResponseWriter, r *http.Request) error {
+	if h.log == nil {
+		return apierr.NotFound("Audit log is not enabled")
+	}
+
+	values := r.URL.Query()
+	filter := audit.Filter{
+		User:   values.Get("user"),
+		TaskID: values.Get("task"),
+		Method: strings.ToUpper(values.Get("method")),
+		Limit:  100,
+	}
+
+	switch outcome := values.Get("outcome"); outcome {
+	case "", audit.OutcomeSuccess, audit.OutcomeFailure:
+		filter.Outcome = outcome
+	default:
+		return apierr.BadRequestf("Invalid outcome filter: %s", outcome)
+	}
+
+	if sinceStr := values.Get("since"); sinceStr != "" {
+		since, err := time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			return apierr.BadRequest("Invalid since format, use RFC3339")
+		}
+		filter.Since = since
+	}
+	if untilStr := values.Get("until"); untilStr != "" {
+		until, err := time.Parse(time.RFC3339, untilStr)
+		if err != nil {
+			return apierr.BadRequest("Invalid until format, use RFC3339")
+		}
+		filter.Until = until
+	}
+
+	if limitStr := values.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			return apierr.BadRequest("Invalid limit parameter")
+		}
+		if limit < 1 {
+			return apierr.BadRequest("Limit must be greater than 0")
+		}
+		if limit > 1000 {
+			return apierr.BadRequest("Limit cannot exceed 1000")
+		}
+		filter.Limit = limit
+	}
+
+	entries, err := h.log.Query(filter)
+	if err != nil {
+		return apierr.WrapInternal(err, "Failed to read audit log")
+	}
+	return response.OK(w, AuditResponse{Entries: entries})
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/audit"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+func setupAuditRouter(t *testing.T) http.Handler {
+	tmpDir := t.TempDir()
+	manager := worker.NewManager(tmpDir)
+	workers := map[string]*worker.Worker{
+		"stopped": {ID: "stopped", ThreadID: "T-stopped", Started: time.Now(), Status: worker.StatusStopped},
+	}
+	require.NoError(t, manager.SaveWorkersForTest(workers, filepath.Join(tmpDir, "workers.json")))
+
+	auditLog, err := audit.Open(filepath.Join(tmpDir, "audit.jsonl"))
+	require.NoError(t, err)
+	t.Cleanup(func() { auditLog.Close() })
+
+	authenticate := hub.TokenAuthenticator(map[string]hub.Identity{"secret": {User: "alice"}})
+	taskHandler := NewTaskHandler(manager, nil)
+	taskHandler.SetAuditLog(auditLog, func(r *http.Request) string {
+		if identity := authenticate.Identify(r); identity != nil {
+			return identity.User
+		}
+		return ""
+	})
+	return NewRouter(taskHandler, hub.NewHub())
+}
+
+func listAudit(t *testing.T, router http.Handler, query string) []audit.Entry {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/audit"+query, nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var result AuditResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	return result.Entries
+}
+
+func TestAudit_RecordsMutations(t *testing.T) {
+	router := setupAuditRouter(t)
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/tasks/stopped", strings.NewReader(`{"title":"Renamed"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Stopping a task that isn't running is rejected, which is recorded as a failure
+	req = httptest.NewRequest(http.MethodPost, "/api/tasks/stopped/stop", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Reads aren't audited
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/tasks", nil))
+
+	entries := listAudit(t, router, "")
+	require.Len(t, entries, 2)
+
+	stop, patch := entries[0], entries[1]
+	assert.Equal(t, "alice", patch.User)
+	assert.Equal(t, http.MethodPatch, patch.Method)
+	assert.Equal(t, "/api/tasks/{id}", patch.Endpoint)
+	assert.Equal(t, "stopped", patch.TaskID)
+	assert.Equal(t, http.StatusOK, patch.Status)
+	assert.Equal(t, audit.OutcomeSuccess, patch.Outcome)
+	assert.NotEmpty(t, patch.RequestID)
+
+	assert.Equal(t, "anonymous", stop.User)
+	assert.Equal(t, "/api/tasks/{id}/stop", stop.Endpoint)
+	assert.Equal(t, audit.OutcomeFailure, stop.Outcome)
+
+	entries = listAudit(t, router, "?user=alice&method=patch")
+	require.Len(t, entries, 1)
+	assert.Equal(t, patch.ID, entries[0].ID)
+
+	assert.Len(t, listAudit(t, router, "?outcome=failure&task=stopped"), 1)
+}
+
+func TestAudit_Errors(t *testing.T) {
+	router := setupAuditRouter(t)
+	for _, query := range []string{"?outcome=maybe", "?since=yesterday", "?limit=0"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/audit"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+
+	// Without an audit log the endpoint doesn't exist
+	router = NewRouter(NewTaskHandler(worker.NewManager(t.TempDir()), nil), hub.NewHub())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/audit", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/ci"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+)
+
+// SetBlockFailingMerges makes merges of tasks whose pull request checks are
+// failing return 409 instead of proceeding
+func (h *TaskHandler) SetBlockFailingMerges(block bool) {
+	h.blockFailingMerges = block
+}
+
+// linkPullRequest records the pull request opened for a task's changes and
+// broadcasts the updated task
+func (h *TaskHandler) linkPullRequest(w http.ResponseWriter, workerID string, req CreatePRRequest) error {
+	if req.Repo == "" || req.Number <= 0 {
+		return apierr.BadRequest("Pull request repo and number are required")
+	}
+
+	link, err := h.manager.LinkPullRequest(workerID, req.Repo, req.Number, req.URL)
+	if err != nil {
+		return taskError(err, "link pull request")
+	}
+
+	if err := response.OK(w, link); err != nil {
+		return err
+	}
+
+	h.broadcastTaskAfterStop(workerID)
+	return nil
+}
+
+// syncCheck records a CI check result on the tasks linked to its pull
+// requests and broadcasts the updated tasks
+func (h *TaskHandler) syncCheck(w http.ResponseWriter, event string, body []byte) error {
+	check, err := ci.ParseGitHub(event, body)
+	if err != nil {
+		return apierr.Wrap(err, http.StatusBadRequest, "Invalid GitHub check payload")
+	}
+
+	updated := []string{}
+	for _, number := range check.PullRequests {
+		ids, err := h.manager.UpdateCheck(check.Repo, number, check.Name, check.Status)
+		if err != nil {
+			return apierr.WrapInternal(err, "Failed to sync check")
+		}
+		updated = append(updated, ids...)
+	}
+
+	if err := response.OK(w, CISyncResponse{Check: check, Updated: updated}); err != nil {
+		return err
+	}
+
+	for _, id := range updated {
+		h.broadcastTaskAfterStop(id)
+	}
+	return nil
+}
+
+// requirePassingChecks refuses to merge a task whose pull request checks are
+// failing, when failing merges are blocked
+func (h *TaskHandler) requirePassingChecks(workerID string) error {
+	if !h.blockFailingMerges {
+		return nil
+	}
+
+	workers, err := h.manager.ListWorkers()
+	if err != nil {
+		return apierr.WrapInternal(err, "Failed to get tasks")
+	}
+	for _, w := range workers {
+		if w.ID == workerID && w.PullRequest != nil && w.PullRequest.CIStatus == worker.CIFailing {
+			return apierr.New(http.StatusConflict, fmt.Sprintf("Cannot merge task %s: CI checks are failing", workerID)).WithCode("ci_failing")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+func TestCreatePRTask_LinksPullRequest(t *testing.T) {
+	handler, manager := setupIssueHandler(t)
+	router := NewRouter(handler, handler.hub)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return w
+	}
+
+	// Without a pull request the endpoint is still a stub
+	assert.Equal(t, http.StatusAccepted, post("/api/tasks/gh/create-pr", "").Code)
+
+	w := post("/api/tasks/gh/create-pr", `{"repo":"acme/api","number":7}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var link worker.PullRequestLink
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+	assert.Equal(t, worker.CIPending, link.CIStatus)
+	assert.Equal(t, 7, findWorker(t, manager, "gh").PullRequest.Number)
+
+	assert.Equal(t, http.StatusBadRequest, post("/api/tasks/gh/create-pr", `{"repo":"acme/api"}`).Code)
+	assert.Equal(t, http.StatusNotFound, post("/api/tasks/missing/create-pr", `{"repo":"acme/api","number":7}`).Code)
+}
+
+func TestReceiveGitHubIssue_SyncsChecks(t *testing.T) {
+	handler, manager := setupIssueHandler(t)
+	_, err := manager.LinkPullRequest("gh", "acme/api", 7, "")
+	require.NoError(t, err)
+
+	body := `{"action":"completed","check_run":{"name":"test","status":"completed","conclusion":"failure","pull_requests":[{"number":7}]},"repository":{"full_name":"acme/api"}}`
+	w := githubIssueRequest(handler, "check_run", body, "gh-secret")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp CISyncResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, []string{"gh"}, resp.Updated)
+	assert.Equal(t, "test", resp.Check.Name)
+	assert.Equal(t, worker.CIFailing, findWorker(t, manager, "gh").PullRequest.CIStatus)
+
+	// Checks on other pull requests don't touch the task
+	body = `{"action":"completed","check_suite":{"status":"completed","conclusion":"success","pull_requests":[{"number":8}],"app":{"name":"CI"}},"repository":{"full_name":"acme/api"}}`
+	w = githubIssueRequest(handler, "check_suite", body, "gh-secret")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Empty(t, resp.Updated)
+
+	assert.Equal(t, http.StatusUnauthorized, githubIssueRequest(handler, "check_run", body, "wrong").Code)
+	assert.Equal(t, http.StatusBadRequest, githubIssueRequest(handler, "check_run", `{}`, "gh-secret").Code)
+}
+
+func TestMergeTask_BlocksFailingChecks(t *testing.T) {
+	handler, manager := setupIssueHandler(t)
+	_, err := manager.LinkPullRequest("gh", "acme/api", 7, "")
+	require.NoError(t, err)
+	_, err = manager.UpdateCheck("acme/api", 7, "test", worker.CIFailing)
+	require.NoError(t, err)
+
+	merge := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/tasks/gh/merge", nil)
+		w := httptest.NewRecorder()
+		NewRouter(handler, handler.hub).ServeHTTP(w, req)
+		return w
+	}
+
+	// Failing checks only block merges when configured to
+	assert.Equal(t, http.StatusAccepted, merge().Code)
+
+	handler.SetBlockFailingMerges(true)
+	w := merge()
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "ci_failing")
+
+	_, err = manager.UpdateCheck("acme/api", 7, "test", worker.CIPassing)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, merge().Code)
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+)
+
+// commandRoute is the REST endpoint a WebSocket command runs as
+type commandRoute struct {
+	path string // Relative to the task's URL when task is set
+	task bool   // The command acts on an existing task
+}
+
+// commandRoutes lists the commands clients may send over the WebSocket
+var commandRoutes = map[string]commandRoute{
+	"start":     {path: "/api/tasks"},
+	"continue":  {path: "/continue", task: true},
+	"interrupt": {path: "/interrupt", task: true},
+	"abort":     {path: "/abort", task: true},
+}
+
+// NewCommandHandler runs the commands clients send over the WebSocket as POST
+// requests to the matching REST endpoints of router, on behalf of the client's
+// identity. Commands are validated, authorized, audited and broadcast exactly
+// like the requests.
+func NewCommandHandler(router http.Handler) hub.CommandHandler {
+	return func(identity *hub.Identity, cmd hub.CommandMessage) hub.CommandResultMessage {
+		route, ok := commandRoutes[cmd.Command]
+		if !ok {
+			return commandError(http.StatusBadRequest, "Unknown command")
+		}
+		path := route.path
+		if route.task {
+			if cmd.TaskID == "" {
+				return commandError(http.StatusBadRequest, "Task ID is required")
+			}
+			path = "/api/tasks/" + url.PathEscape(cmd.TaskID) + route.path
+		}
+
+		ctx := hub.WithIdentity(context.Background(), identity)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, path, bytes.NewReader(cmd.Body))
+		if err != nil {
+			return commandError(http.StatusBadRequest, "Invalid command")
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "websocket"
+
+		w := &commandResponse{header: make(http.Header), status: http.StatusOK}
+		router.ServeHTTP(w, req)
+
+		result := hub.CommandResultMessage{Status: w.status}
+		if body := bytes.TrimSpace(w.body.Bytes()); json.Valid(body) {
+			result.Body = body
+		}
+		return result
+	}
+}
+
+// commandError answers a command that couldn't be run
+func commandError(status int, message string) hub.CommandResultMessage {
+	body, _ := json.Marshal(response.ErrorBody{Code: apierr.DefaultCode(status), Message: message})
+	return hub.CommandResultMessage{Status: status, Body: body}
+}
+
+// commandResponse records the response to a command's request
+type commandResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *commandResponse) Header() http.Header {
+	return w.header
+}
+
+func (w *commandResponse) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *commandResponse) WriteHeader(status int) {
+	w.status = status
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub/hubtest"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker/workertest"
+)
+
+func TestCommands(t *testing.T) {
+	manager := workertest.New()
+	h := hubtest.New(t)
+	handler := NewTaskHandler(manager, h.Hub)
+	handler.SetAuthenticator(hub.TokenAuthenticator(map[string]hub.Identity{"alice-token": {User: "alice"}}))
+	NewRouter(handler, h.Hub)
+	manager.Add(&worker.Worker{ID: "alice1", Status: worker.StatusRunning, Owner: "alice"})
+
+	alice := h.ConnectAs("", &hub.Identity{User: "alice"})
+	bob := h.ConnectAs("", &hub.Identity{User: "bob"})
+	result := func(client *hubtest.Client, id string) hub.CommandResultMessage {
+		msg := client.ExpectEvent(hub.MessageTypeCommandResult, 0)
+		require.Equal(t, id, msg.ID)
+		var result hub.CommandResultMessage
+		require.NoError(t, json.Unmarshal(msg.Data, &result))
+		return result
+	}
+
+	alice.Command("c1", hub.CommandMessage{Command: "continue", TaskID: "alice1", Body: json.RawMessage(`{"message":"and the tests"}`)})
+	res := result(alice, "c1")
+	assert.Equal(t, "continue", res.Command)
+	assert.Equal(t, http.StatusAccepted, res.Status)
+	assert.JSONEq(t, `{"attempt":1}`, string(res.Body))
+
+	// Commands are authorized like the REST endpoints
+	bob.Command("c2", hub.CommandMessage{Command: "abort", TaskID: "alice1"})
+	assert.Equal(t, http.StatusForbidden, result(bob, "c2").Status)
+	assert.Equal(t, worker.StatusRunning, manager.Worker("alice1").Status)
+
+	alice.Command("c3", hub.CommandMessage{Command: "interrupt", TaskID: "alice1"})
+	assert.Equal(t, http.StatusAccepted, result(alice, "c3").Status)
+	assert.Equal(t, worker.StatusInterrupted, manager.Worker("alice1").Status)
+
+	alice.Command("c4", hub.CommandMessage{Command: "start", Body: json.RawMessage(`{"message":"fix the build"}`)})
+	res = result(alice, "c4")
+	require.Equal(t, http.StatusCreated, res.Status)
+	var task TaskDTO
+	require.NoError(t, json.Unmarshal(res.Body, &task))
+	assert.Equal(t, "alice", manager.Worker(task.ID).Owner)
+
+	alice.Command("c5", hub.CommandMessage{Command: "delete", TaskID: "alice1"})
+	assert.Equal(t, http.StatusBadRequest, result(alice, "c5").Status)
+	alice.Command("c6", hub.CommandMessage{Command: "abort"})
+	assert.Equal(t, http.StatusBadRequest, result(alice, "c6").Status)
+	alice.Command("c7", hub.CommandMessage{Command: "abort", TaskID: "missing"})
+	assert.Equal(t, http.StatusNotFound, result(alice, "c7").Status)
+}
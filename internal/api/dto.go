@@ -1,6 +1,18 @@
 package api
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/agent"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/audit"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/ci"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/git"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/issue"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/secrets"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
 
 // TaskDTO represents a task for API responses
 type TaskDTO struct {
@@ -13,11 +25,132 @@ type TaskDTO struct {
 	Description string    `json:"description,omitempty"`
 	Tags        []string  `json:"tags,omitempty"`
 	Priority    string    `json:"priority,omitempty"`
+
+	StatusReason  string                `json:"status_reason,omitempty"`  // Why the task entered its current status
+	FailureReason *worker.FailureReason `json:"failure_reason,omitempty"` // The amp error the task failed with
+	Finished      *time.Time            `json:"finished,omitempty"`       // When the task last stopped running
+	Deleted       *time.Time            `json:"deleted,omitempty"`        // When the task was moved to the trash
+
+	Annotations []worker.Annotation     `json:"annotations,omitempty"`  // Statuses reported by external systems
+	Issue       *worker.IssueLink       `json:"issue,omitempty"`        // Issue the task was created from
+	Execution   string                  `json:"execution,omitempty"`    // Where amp runs: "host", "container" or "remote"
+	Agent       string                  `json:"agent,omitempty"`        // Remote agent running the task
+	Project     string                  `json:"project"`                // Project the task belongs to
+	Owner       string                  `json:"owner,omitempty"`        // User who started the task
+	PullRequest *worker.PullRequestLink `json:"pull_request,omitempty"` // Pull request opened for the task's changes
+	CIStatus    string                  `json:"ci_status,omitempty"`    // "pending", "passing" or "failing" once a pull request is linked
+	AutoCommit  bool                    `json:"auto_commit,omitempty"`  // Changes are committed when the worker's process exits
+	Restart     *worker.RestartPolicy   `json:"restart,omitempty"`      // When amp is restarted after it exits
+	Restarts    int                     `json:"restarts,omitempty"`     // Automatic restarts so far
+	AmpBinary   string                  `json:"amp_binary,omitempty"`   // amp executable used instead of the daemon's
+	AmpArgs     []string                `json:"amp_args,omitempty"`     // Extra flags passed to amp
+	Profile     string                  `json:"profile,omitempty"`      // amp profile the task runs with
+	Pool        string                  `json:"pool,omitempty"`         // Worker pool the task runs in
+	EnvVars     []string                `json:"env_vars,omitempty"`     // Names of the variables set with env; values aren't returned
+	Secrets     map[string]string       `json:"secrets,omitempty"`      // Names of the secrets added to the environment, by variable
+	Backend     string                  `json:"backend"`                // Agent backend running the task
+	Model       string                  `json:"model,omitempty"`        // Model of the backend the task uses; omitted for the backend's default
+
+	// Subtask hierarchy
+	ParentID          string         `json:"parent_id,omitempty"`
+	ChildCount        int            `json:"child_count,omitempty"`
+	ChildStatusCounts map[string]int `json:"child_status_counts,omitempty"`
+	AggregateStatus   string         `json:"aggregate_status,omitempty"` // Rollup status of the task and its subtasks
+
+	fields []string // Fields kept when encoding; nil keeps them all
 }
 
 // StartTaskRequest represents the request body for starting a task
 type StartTaskRequest struct {
-	Message string `json:"message"`
+	Message     string                `json:"message"`
+	Title       string                `json:"title,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Priority    string                `json:"priority,omitempty"` // Takes precedence over the issue's priority
+	ParentID    string                `json:"parent_id,omitempty"`
+	Issue       *IssueRequest         `json:"issue,omitempty"`       // Issue the task is created from
+	Execution   string                `json:"execution,omitempty"`   // "host", "container" or "remote"; defaults to the project's or daemon's mode
+	Project     string                `json:"project,omitempty"`     // Defaults to the parent's project, or "default"
+	AutoCommit  bool                  `json:"auto_commit,omitempty"` // Commit the workspace's changes when the worker's process exits
+	Restart     *worker.RestartPolicy `json:"restart,omitempty"`     // When amp is restarted after it exits; omitted never restarts it
+	AmpBinary   string                `json:"amp_binary,omitempty"`  // amp executable to run; must be in amp_overrides.binaries
+	AmpArgs     []string              `json:"amp_args,omitempty"`    // Extra amp flags; each must be in amp_overrides.flags
+	Profile     string                `json:"profile,omitempty"`     // amp profile from amp_profiles; defaults to the daemon's credentials
+	Env         map[string]string     `json:"env,omitempty"`         // Variables added to amp's environment; some, such as PATH and LD_*, are rejected
+	Secrets     map[string]string     `json:"secrets,omitempty"`     // Names of stored secrets, by the variable their values are added as
+	Backend     string                `json:"backend,omitempty"`     // Agent backend from GET /api/meta/backends; defaults to amp
+	Model       string                `json:"model,omitempty"`       // One of the backend's models; defaults to the backend's own default
+	Pool        string                `json:"pool,omitempty"`        // Worker pool from GET /api/meta/pools; defaults to the "default" pool, if configured
+}
+
+// CreateProjectRequest represents the request body for creating a project
+type CreateProjectRequest struct {
+	Name          string `json:"name"`
+	RepoURL       string `json:"repo_url,omitempty"`
+	DefaultBranch string `json:"default_branch,omitempty"`
+	Execution     string `json:"execution,omitempty"` // Default execution mode of the project's tasks
+	Dir           string `json:"dir,omitempty"`       // Directory amp runs in on the host
+}
+
+// PatchProjectRequest represents the request body for updating a project
+type PatchProjectRequest struct {
+	RepoURL       *string `json:"repo_url,omitempty"`
+	DefaultBranch *string `json:"default_branch,omitempty"`
+	Execution     *string `json:"execution,omitempty"`
+	Dir           *string `json:"dir,omitempty"`
+}
+
+// ProjectsResponse lists the projects
+type ProjectsResponse struct {
+	Projects []*worker.Project `json:"projects"`
+}
+
+// BackfillThreadsRequest selects the tasks whose threads are backfilled
+type BackfillThreadsRequest struct {
+	TaskIDs []string `json:"task_ids,omitempty"` // Empty backfills every task
+	DryRun  bool     `json:"dry_run,omitempty"`  // Report what would be added without writing it
+}
+
+// IssueRequest links a new task to the GitHub or Jira issue it was created
+// from, inheriting its priority and labels. A GitHub issue may be given by
+// key, URL, or number within the configured issues.github_repo.
+type IssueRequest struct {
+	Provider string   `json:"provider,omitempty"` // "github" or "jira"; defaults to github
+	Key      string   `json:"key,omitempty"`      // "owner/repo#number" or a Jira issue key
+	URL      string   `json:"url,omitempty"`
+	Number   int      `json:"number,omitempty"`
+	Priority string   `json:"priority,omitempty"`
+	Labels   []string `json:"labels,omitempty"`
+}
+
+// IssueSyncResponse reports the tasks updated from an issue webhook
+type IssueSyncResponse struct {
+	Issue   *issue.Issue `json:"issue,omitempty"`
+	Updated []string     `json:"updated"`
+}
+
+// ArtifactsResponse lists a task's artifacts
+type ArtifactsResponse struct {
+	Artifacts []worker.Artifact `json:"artifacts"`
+}
+
+// CreatePRRequest represents the request body for linking a pull request to a task
+type CreatePRRequest struct {
+	Repo   string `json:"repo"` // "owner/name"
+	Number int    `json:"number"`
+	URL    string `json:"url,omitempty"`
+}
+
+// CISyncResponse reports the tasks updated from a CI check webhook
+type CISyncResponse struct {
+	Check   *ci.Check `json:"check"`
+	Updated []string  `json:"updated"`
+}
+
+// WindDownTaskRequest represents the request body for winding down a task
+type WindDownTaskRequest struct {
+	Message        string `json:"message,omitempty"`         // Final instruction; defaults to asking for a summary, a WIP commit and a stop
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"` // Before the task is interrupted; defaults to 300
 }
 
 // PatchTaskRequest represents the request body for updating a task
@@ -28,6 +161,28 @@ type PatchTaskRequest struct {
 	Priority    *string  `json:"priority,omitempty"`
 }
 
+// TransitionTaskRequest represents the request body for an atomic status
+// transition combined with metadata changes
+type TransitionTaskRequest struct {
+	Status      string   `json:"status"`
+	Reason      string   `json:"reason,omitempty"`
+	Message     string   `json:"message,omitempty"` // Required when transitioning to running
+	Title       *string  `json:"title,omitempty"`
+	Description *string  `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Priority    *string  `json:"priority,omitempty"`
+}
+
+// AnnotateTaskRequest represents the request body for attaching an
+// annotation to a task
+type AnnotateTaskRequest struct {
+	Key     string                 `json:"key"`
+	Status  string                 `json:"status"` // "pending", "success", "failure" or "neutral"
+	Summary string                 `json:"summary,omitempty"`
+	URL     string                 `json:"url,omitempty"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+}
+
 // WebSocketEvent represents events sent over WebSocket
 type WebSocketEvent struct {
 	Type string      `json:"type"`
@@ -40,6 +195,43 @@ type TaskUpdateEvent struct {
 	Data TaskDTO `json:"data"`
 }
 
+// TaskCreatedEvent announces a newly started task
+type TaskCreatedEvent struct {
+	Type string  `json:"type"` // "task-created"
+	Data TaskDTO `json:"data"`
+}
+
+// TaskDeletedEvent announces that a task was deleted
+type TaskDeletedEvent struct {
+	Type string         `json:"type"` // "task-deleted"
+	Data TaskDeletedDTO `json:"data"`
+}
+
+// TaskDeletedDTO identifies a deleted task and its state when it was deleted
+type TaskDeletedDTO struct {
+	TaskID string  `json:"task_id"`
+	Task   TaskDTO `json:"task"`
+}
+
+// TaskStatsEvent carries a new CPU and memory sample of a running task
+type TaskStatsEvent struct {
+	Type string       `json:"type"` // "task-stats"
+	Data TaskStatsDTO `json:"data"`
+}
+
+// TaskStatsDTO is a task's resource sample
+type TaskStatsDTO struct {
+	TaskID string `json:"task_id"`
+	worker.ResourceSample
+}
+
+// TaskStatsResponse lists a task's recent resource samples, oldest first
+type TaskStatsResponse struct {
+	TaskID          string                  `json:"task_id"`
+	IntervalSeconds float64                 `json:"interval_seconds"`
+	Samples         []worker.ResourceSample `json:"samples"`
+}
+
 // LogEvent represents a log line event
 type LogEvent struct {
 	Type string `json:"type"` // "log"
@@ -53,12 +245,18 @@ type LogData struct {
 	Content   string    `json:"content"`
 }
 
+// TrashResponse lists trashed or restored tasks
+type TrashResponse struct {
+	Tasks []TaskDTO `json:"tasks"`
+}
+
 // PaginatedTasksResponse represents a paginated response for tasks
 type PaginatedTasksResponse struct {
 	Tasks      []TaskDTO `json:"tasks"`
 	NextCursor string    `json:"next_cursor,omitempty"`
 	HasMore    bool      `json:"has_more"`
 	Total      int       `json:"total"`
+	Missing    []string  `json:"missing,omitempty"` // Requested IDs that matched no task
 }
 
 // ThreadMessageDTO represents a thread message for API responses
@@ -72,9 +270,91 @@ type ThreadMessageDTO struct {
 
 // PaginatedThreadResponse represents a paginated response for thread messages
 type PaginatedThreadResponse struct {
-	Messages []ThreadMessageDTO `json:"messages"`
-	HasMore  bool               `json:"has_more"`
-	Total    int                `json:"total"`
+	Messages   []ThreadMessageDTO `json:"messages"`
+	HasMore    bool               `json:"has_more"`
+	Total      int                `json:"total"`
+	NextCursor string             `json:"next_cursor,omitempty"` // Pass as ?cursor= for the next page
+}
+
+// EventHistoryResponse is a page of recorded broadcast events
+type EventHistoryResponse struct {
+	Events    []hub.HistoryEvent `json:"events"`
+	HasMore   bool               `json:"has_more"`
+	NextSince uint64             `json:"next_since"` // Pass as ?since= to fetch the next page
+}
+
+// TaskUpdatesResponse holds the task events a long poll returned, which are
+// empty when the wait expired first
+type TaskUpdatesResponse struct {
+	Events    []json.RawMessage `json:"events"`     // Events as sent over /api/ws, with their "seq"
+	NextSince uint64            `json:"next_since"` // Pass as ?since= to wait for the next events
+}
+
+// EventsDroppedDetails tells a long poll that fell behind where to wait from
+// once it has reloaded tasks
+type EventsDroppedDetails struct {
+	NextSince uint64 `json:"next_since"`
+}
+
+// AuditResponse lists audited API calls, newest first
+type AuditResponse struct {
+	Entries []audit.Entry `json:"entries"`
+}
+
+// AttemptsResponse lists a task's amp invocations, oldest first
+type AttemptsResponse struct {
+	Attempts []worker.Attempt `json:"attempts"`
+}
+
+// TimelineResponse lists a task's status changes, oldest first
+type TimelineResponse struct {
+	Timeline []worker.StatusChange `json:"timeline"`
+}
+
+// ContinueTaskResponse identifies the attempt that will send a message to a
+// task
+type ContinueTaskResponse struct {
+	Attempt int `json:"attempt"`
+}
+
+// ContinueFinishedEvent announces that amp has answered a message sent to a
+// task
+type ContinueFinishedEvent struct {
+	Type string              `json:"type"` // "continue-finished"
+	Data ContinueFinishedDTO `json:"data"`
+}
+
+// ContinueFinishedDTO identifies the attempt that sent a message and how it
+// exited
+type ContinueFinishedDTO struct {
+	TaskID  string         `json:"task_id"`
+	Attempt worker.Attempt `json:"attempt"`
+}
+
+// QueueResponse lists the messages waiting to be sent to a task, oldest first
+type QueueResponse struct {
+	Queue []worker.QueuedMessage `json:"queue"`
+}
+
+// TaskCommitsResponse lists the commits on a task's branch
+type TaskCommitsResponse struct {
+	Base    string       `json:"base"` // Branch the task's branch is compared with
+	Commits []git.Commit `json:"commits"`
+}
+
+// SecretsResponse lists the stored secrets without their values
+type SecretsResponse struct {
+	Secrets []secrets.Secret `json:"secrets"`
+}
+
+// SetSecretRequest represents the request body for storing a secret
+type SetSecretRequest struct {
+	Value string `json:"value"`
+}
+
+// AgentsResponse lists the connected remote agents
+type AgentsResponse struct {
+	Agents []agent.Info `json:"agents"`
 }
 
 // ThreadMessageEvent represents a thread message event over WebSocket
@@ -82,3 +362,71 @@ type ThreadMessageEvent struct {
 	Type string            `json:"type"` // "thread_message"
 	Data ThreadMessageDTO `json:"data"`
 }
+
+// ThreadMessageRemovedEvent announces that a message was removed from a
+// task's thread or redacted
+type ThreadMessageRemovedEvent struct {
+	Type string                  `json:"type"` // "thread_message_removed"
+	Data ThreadMessageRemovedDTO `json:"data"`
+}
+
+// ThreadMessageRemovedDTO identifies a removed or redacted thread message
+type ThreadMessageRemovedDTO struct {
+	TaskID    string            `json:"task_id"`
+	MessageID string            `json:"message_id"`
+	Redacted  bool              `json:"redacted"`          // The record was kept with its content blanked
+	Message   *ThreadMessageDTO `json:"message,omitempty"` // The redacted message
+}
+
+// TaskStalledEvent reports that a task stopped producing output
+type TaskStalledEvent struct {
+	Type string       `json:"type"` // "task-stalled"
+	Data TaskStallDTO `json:"data"`
+}
+
+// SystemEvent reports a daemon-wide condition not tied to a single task
+type SystemEvent struct {
+	Type string         `json:"type"` // "system"
+	Data SystemEventDTO `json:"data"`
+}
+
+// SystemEventDTO describes a system event
+type SystemEventDTO struct {
+	Event   string      `json:"event"` // e.g. "rate-limit-saturated"
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// TaskStallDTO describes a stall and the action taken by the stall monitor
+type TaskStallDTO struct {
+	TaskID      string `json:"task_id"`
+	Action      string `json:"action"` // "detected", "nudged" or "escalated"
+	Nudges      int    `json:"nudges"`
+	IdleSeconds int    `json:"idle_seconds"`
+}
+
+// LogMatchDTO is a log line matching a search, with the lines around it
+type LogMatchDTO struct {
+	Line    int      `json:"line"` // 1-based line number
+	Content string   `json:"content"`
+	Before  []string `json:"before,omitempty"` // Lines immediately before, oldest first
+	After   []string `json:"after,omitempty"`  // Lines immediately after
+}
+
+// LogSearchResponse holds the lines of a task's log matching a search
+type LogSearchResponse struct {
+	Matches      []LogMatchDTO `json:"matches"`
+	HasMore      bool          `json:"has_more"`      // More lines matched than the limit
+	LinesScanned int           `json:"lines_scanned"` // Lines read before the search stopped
+}
+
+// TaskExportDTO bundles a task with its full log and thread, so it can be
+// shared as an example or attached to a bug report
+type TaskExportDTO struct {
+	Task       TaskDTO            `json:"task"`
+	Logs       []string           `json:"logs"`
+	Thread     []ThreadMessageDTO `json:"thread"`
+	Anonymized bool               `json:"anonymized"`
+	Pseudonyms map[string]int     `json:"pseudonyms,omitempty"` // Distinct values given pseudonyms, by kind
+	ExportedAt time.Time          `json:"exported_at"`
+}
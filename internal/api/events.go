@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+)
+
+// EventHandler serves the history of broadcast events and long polls for new
+// ones
+type EventHandler struct {
+	hub *hub.Hub
+}
+
+// NewEventHandler creates a new event history handler
+func NewEventHandler(h *hub.Hub) *EventHandler {
+	return &EventHandler{hub: h}
+}
+
+// ListEvents returns recorded events after ?since=<id>, optionally filtered by
+// ?type= (comma-separated) and ?task=, so clients can backfill missed events
+func (h *EventHandler) ListEvents(w http.ResponseWriter, r *http.Request) error {
+	var history *hub.History
+	if h.hub != nil {
+		history = h.hub.History()
+	}
+	if history == nil {
+		return apierr.NotFound("Event history is not enabled")
+	}
+
+	values := r.URL.Query()
+	q := hub.HistoryQuery{Limit: 100}
+
+	if sinceStr := values.Get("since"); sinceStr != "" {
+		since, err := strconv.ParseUint(sinceStr, 10, 64)
+		if err != nil {
+			return apierr.BadRequest("Invalid since parameter")
+		}
+		q.Since = since
+	}
+
+	if limitStr := values.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			return apierr.BadRequest("Invalid limit parameter")
+		}
+		if limit < 1 {
+			return apierr.BadRequest("Limit must be greater than 0")
+		}
+		if limit > 1000 {
+			return apierr.BadRequest("Limit cannot exceed 1000")
+		}
+		q.Limit = limit
+	}
+
+	if typeStr := values.Get("type"); typeStr != "" {
+		for _, msgType := range strings.Split(typeStr, ",") {
+			if msgType = strings.TrimSpace(msgType); msgType != "" {
+				q.Types = append(q.Types, hub.MessageType(msgType))
+			}
+		}
+	}
+
+	if taskID := values.Get("task"); taskID != "" {
+		q.Room = TaskRoom(taskID)
+	}
+
+	events, hasMore, err := history.Query(q)
+	if err != nil {
+		return apierr.WrapInternal(err, "Failed to read event history")
+	}
+
+	result := EventHistoryResponse{
+		Events:    events,
+		HasMore:   hasMore,
+		NextSince: q.Since,
+	}
+	if len(events) > 0 {
+		result.NextSince = events[len(events)-1].ID
+	}
+	return response.OK(w, result)
+}
+
+const (
+	// defaultUpdatesWait is how long GET /api/tasks/updates waits for an
+	// event when the request doesn't say
+	defaultUpdatesWait = 30 * time.Second
+
+	// maxUpdatesWait caps the wait requested with ?wait=
+	maxUpdatesWait = 2 * time.Minute
+)
+
+// taskUpdateTypes are the events GET /api/tasks/updates waits for by default
+var taskUpdateTypes = []hub.MessageType{hub.MessageTypeTaskCreated, hub.MessageTypeTaskUpdate, hub.MessageTypeTaskDeleted}
+
+// WaitForTaskUpdates long-polls for task events after ?since=<seq>, waiting up
+// to ?wait= for one when there are none yet, for clients that can't keep a
+// WebSocket open. ?type= (comma-separated) replaces the default task events.
+func (h *EventHandler) WaitForTaskUpdates(w http.ResponseWriter, r *http.Request) error {
+	if h.hub == nil {
+		return apierr.NotFound("Event replay is not enabled")
+	}
+
+	values := r.URL.Query()
+	since := h.hub.LastSeq()
+	if sinceStr := values.Get("since"); sinceStr != "" {
+		var err error
+		since, err = strconv.ParseUint(sinceStr, 10, 64)
+		if err != nil {
+			return apierr.BadRequest("Invalid since parameter")
+		}
+	}
+
+	wait := defaultUpdatesWait
+	if waitStr := values.Get("wait"); waitStr != "" {
+		var err error
+		if wait, err = parseWait(waitStr); err != nil {
+			return err
+		}
+	}
+
+	types := taskUpdateTypes
+	if typeStr := values.Get("type"); typeStr != "" {
+		types = nil
+		for _, msgType := range strings.Split(typeStr, ",") {
+			if msgType = strings.TrimSpace(msgType); msgType != "" {
+				types = append(types, hub.MessageType(msgType))
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), wait)
+	defer cancel()
+	events, next, err := h.hub.WaitForEvents(ctx, since, types)
+	switch {
+	case errors.Is(err, hub.ErrReplayDisabled):
+		return apierr.NotFound("Event replay is not enabled")
+	case errors.Is(err, hub.ErrEventsDropped):
+		return apierr.New(http.StatusGone, "Events since the given sequence number are no longer available; reload tasks and wait from next_since").
+			WithDetails(EventsDroppedDetails{NextSince: next})
+	case err != nil:
+		return apierr.WrapInternal(err, "Failed to wait for task updates")
+	}
+
+	return response.OK(w, TaskUpdatesResponse{Events: events, NextSince: next})
+}
+
+// parseWait parses a long poll's wait, given as a duration such as "30s" or as
+// whole seconds
+func parseWait(value string) (time.Duration, error) {
+	wait, err := time.ParseDuration(value)
+	if seconds, convErr := strconv.Atoi(value); convErr == nil {
+		wait, err = time.Duration(seconds)*time.Second, nil
+	}
+	if err != nil || wait < 0 {
+		return 0, apierr.BadRequest("Invalid wait parameter")
+	}
+	if wait > maxUpdatesWait {
+		return 0, apierr.BadRequestf("Wait cannot exceed %s", maxUpdatesWait)
+	}
+	return wait, nil
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub/hubtest"
+	errormw "github.com/brettsmith212/amp-orchestrator-2/internal/middleware"
+)
+
+func listEvents(t *testing.T, handler *EventHandler, query string) (*httptest.ResponseRecorder, EventHistoryResponse) {
+	req := httptest.NewRequest(http.MethodGet, "/api/events"+query, nil)
+	w := httptest.NewRecorder()
+	errormw.Error(handler.ListEvents).ServeHTTP(w, req)
+
+	var result EventHistoryResponse
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	}
+	return w, result
+}
+
+func TestListEvents(t *testing.T) {
+	h := hub.NewHub()
+	require.NoError(t, h.EnableReplay(hub.ReplayConfig{Size: 10}))
+	require.NoError(t, h.EnableHistory(hub.HistoryConfig{Path: filepath.Join(t.TempDir(), "history.jsonl")}))
+	hubtest.Run(t, h)
+
+	h.BroadcastToRoom(TaskRoom("a"), []byte(`{"type":"task-update","data":{"id":"a"}}`))
+	h.BroadcastToRoom(TaskRoom("a"), []byte(`{"type":"thread_message","data":{"content":"hi"}}`))
+	h.BroadcastToRoom(TaskRoom("b"), []byte(`{"type":"task-update","data":{"id":"b"}}`))
+
+	handler := NewEventHandler(h)
+	require.Eventually(t, func() bool {
+		_, result := listEvents(t, handler, "")
+		return len(result.Events) == 3
+	}, time.Second, 10*time.Millisecond)
+
+	w, result := listEvents(t, handler, "?since=1&limit=1")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, result.Events, 1)
+	assert.Equal(t, uint64(2), result.Events[0].ID)
+	assert.True(t, result.HasMore)
+	assert.Equal(t, uint64(2), result.NextSince)
+
+	_, result = listEvents(t, handler, "?type=task-update")
+	require.Len(t, result.Events, 2)
+	assert.Equal(t, uint64(3), result.Events[1].ID)
+
+	_, result = listEvents(t, handler, "?type=task-update&task=a")
+	require.Len(t, result.Events, 1)
+	assert.JSONEq(t, `{"id":"a"}`, string(result.Events[0].Data))
+
+	// Caught up clients keep their position
+	_, result = listEvents(t, handler, "?since=3")
+	assert.Empty(t, result.Events)
+	assert.False(t, result.HasMore)
+	assert.Equal(t, uint64(3), result.NextSince)
+}
+
+func TestListEvents_Errors(t *testing.T) {
+	w, _ := listEvents(t, NewEventHandler(hub.NewHub()), "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	h := hub.NewHub()
+	require.NoError(t, h.EnableReplay(hub.ReplayConfig{Size: 10}))
+	require.NoError(t, h.EnableHistory(hub.HistoryConfig{Path: filepath.Join(t.TempDir(), "history.jsonl")}))
+	handler := NewEventHandler(h)
+
+	for _, query := range []string{"?since=-1", "?since=x", "?limit=0", "?limit=1001"} {
+		w, _ := listEvents(t, handler, query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func waitForTaskUpdates(t *testing.T, handler *EventHandler, query string) (*httptest.ResponseRecorder, TaskUpdatesResponse) {
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/updates"+query, nil)
+	w := httptest.NewRecorder()
+	errormw.Error(handler.WaitForTaskUpdates).ServeHTTP(w, req)
+
+	var result TaskUpdatesResponse
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	}
+	return w, result
+}
+
+func TestWaitForTaskUpdates(t *testing.T) {
+	h := hub.NewHub()
+	require.NoError(t, h.EnableReplay(hub.ReplayConfig{Size: 10}))
+	hubtest.Run(t, h)
+	handler := NewEventHandler(h)
+
+	// Without since, only events broadcast after the request are returned
+	h.BroadcastToRoom(TaskRoom("a"), []byte(`{"type":"task-update","data":{"id":"old"}}`))
+	require.Eventually(t, func() bool { return h.LastSeq() == 1 }, time.Second, 10*time.Millisecond)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		h.BroadcastToRoom(TaskRoom("a"), []byte(`{"type":"log","data":{"content":"hi"}}`))
+		h.BroadcastToRoom(TaskRoom("a"), []byte(`{"type":"task-update","data":{"id":"a"}}`))
+	}()
+	w, result := waitForTaskUpdates(t, handler, "?wait=5s")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, result.Events, 1)
+	assert.Contains(t, string(result.Events[0]), `"id":"a"`)
+	assert.Equal(t, uint64(3), result.NextSince)
+
+	// Earlier events are returned at once
+	_, result = waitForTaskUpdates(t, handler, "?since=0&type=log")
+	require.Len(t, result.Events, 1)
+	assert.Contains(t, string(result.Events[0]), `"content":"hi"`)
+
+	// Expired waits return no events
+	w, result = waitForTaskUpdates(t, handler, "?since=3&wait=0")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, result.Events)
+	assert.Equal(t, uint64(3), result.NextSince)
+
+	// Positions the buffer can't resume from must reload
+	w, _ = waitForTaskUpdates(t, handler, "?since=7&wait=0")
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Contains(t, w.Body.String(), `"next_since":3`)
+}
+
+func TestWaitForTaskUpdates_Errors(t *testing.T) {
+	w, _ := waitForTaskUpdates(t, NewEventHandler(hub.NewHub()), "?wait=0")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	h := hub.NewHub()
+	require.NoError(t, h.EnableReplay(hub.ReplayConfig{Size: 10}))
+	handler := NewEventHandler(h)
+
+	for _, query := range []string{"?since=x", "?wait=soon", "?wait=-1s", "?wait=10m"} {
+		w, _ := waitForTaskUpdates(t, handler, query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
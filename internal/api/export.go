@@ -0,0 +1,152 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/anonymize"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+)
+
+// ExportTask returns a task with its full log and thread. With ?anonymize=true,
+// hostnames, usernames, file paths and emails are replaced with pseudonyms that
+// stay consistent across the whole export. ?ansi=strip removes escape sequences
+// and control characters from the log.
+func (h *LogHandler) ExportTask(w http.ResponseWriter, r *http.Request) error {
+	taskID := chi.URLParam(r, "id")
+
+	clean, err := parseANSI(r)
+	if err != nil {
+		return err
+	}
+
+	anonymized := false
+	if param := r.URL.Query().Get("anonymize"); param != "" {
+		var err error
+		anonymized, err = strconv.ParseBool(param)
+		if err != nil {
+			return apierr.BadRequest("Invalid anonymize parameter")
+		}
+	}
+
+	workers, err := h.manager.ListWorkers()
+	if err != nil {
+		return apierr.WrapInternal(err, "Failed to list workers")
+	}
+
+	var task *worker.Worker
+	for _, w := range workers {
+		if w.ID == taskID {
+			task = w
+			break
+		}
+	}
+	if task == nil {
+		return apierr.NotFound("Task not found")
+	}
+
+	logs, err := h.readLog(taskID, clean)
+	if err != nil {
+		return apierr.WrapInternal(err, "Failed to read log file")
+	}
+
+	messages, err := h.manager.GetThreadMessages(taskID, 0, 0)
+	if err != nil {
+		return apierr.WrapInternal(err, "Failed to retrieve thread messages")
+	}
+
+	export := TaskExportDTO{
+		Task:       newTaskDTO(task, worker.NewHierarchyFromList(workers)),
+		Logs:       logs,
+		Thread:     make([]ThreadMessageDTO, len(messages)),
+		Anonymized: anonymized,
+		ExportedAt: time.Now(),
+	}
+	for i, msg := range messages {
+		export.Thread[i] = ThreadMessageDTO{
+			ID:        msg.ID,
+			Type:      string(msg.Type),
+			Content:   msg.Content,
+			Timestamp: msg.Timestamp,
+			Metadata:  msg.Metadata,
+		}
+	}
+
+	if anonymized {
+		anonymizeExport(anonymize.ForLocalMachine(), &export)
+	}
+
+	return response.OK(w, export)
+}
+
+// readLog reads every line of a task's log, passed through clean, returning
+// none if it doesn't exist yet
+func (h *LogHandler) readLog(taskID string, clean func(string) string) ([]string, error) {
+	file, err := h.manager.OpenLog(taskID)
+	if errors.Is(err, worker.ErrLogNotFound) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	lines := []string{}
+	scanner := worker.NewLineReader(file, h.manager.LineLimit())
+	for scanner.Scan() {
+		lines = append(lines, clean(scanner.Text()))
+	}
+	return lines, scanner.Err()
+}
+
+// anonymizeExport replaces identifying details in the free-text parts of an
+// export. IDs, statuses and timestamps are kept so the export stays coherent.
+func anonymizeExport(a *anonymize.Anonymizer, export *TaskExportDTO) {
+	a.Learn(export.Logs...)
+	for _, msg := range export.Thread {
+		a.Learn(msg.Content)
+	}
+
+	task := &export.Task
+	task.LogFile = a.String(task.LogFile)
+	task.Title = a.String(task.Title)
+	task.Description = a.String(task.Description)
+	task.StatusReason = a.String(task.StatusReason)
+	if task.Tags != nil {
+		tags := make([]string, len(task.Tags))
+		for i, tag := range task.Tags {
+			tags[i] = a.String(tag)
+		}
+		task.Tags = tags
+	}
+	if task.Annotations != nil {
+		annotations := make([]worker.Annotation, len(task.Annotations))
+		for i, annotation := range task.Annotations {
+			annotation.Summary = a.String(annotation.Summary)
+			annotation.URL = a.String(annotation.URL)
+			if annotation.Data != nil {
+				annotation.Data = a.Value(annotation.Data).(map[string]interface{})
+			}
+			annotations[i] = annotation
+		}
+		task.Annotations = annotations
+	}
+
+	for i, line := range export.Logs {
+		export.Logs[i] = a.String(line)
+	}
+	for i, msg := range export.Thread {
+		export.Thread[i].Content = a.String(msg.Content)
+		if msg.Metadata != nil {
+			export.Thread[i].Metadata = a.Value(msg.Metadata).(map[string]interface{})
+		}
+	}
+
+	export.Pseudonyms = a.Pseudonyms()
+}
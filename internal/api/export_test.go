@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	errormw "github.com/brettsmith212/amp-orchestrator-2/internal/middleware"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+func setupExportHandler(t *testing.T) *LogHandler {
+	tmpDir := t.TempDir()
+	manager := worker.NewManager(tmpDir)
+
+	logFile := filepath.Join(tmpDir, "worker-export.log")
+	logContent := "cloning git@github.acme.com:acme/api.git\nopen /home/alice/src/api/main.go: permission denied\n"
+	require.NoError(t, os.WriteFile(logFile, []byte(logContent), 0644))
+
+	workers := map[string]*worker.Worker{
+		"export": {
+			ID:          "export",
+			ThreadID:    "T-export",
+			LogFile:     logFile,
+			Started:     time.Now(),
+			Status:      worker.StatusFailed,
+			Title:       "Fix build on db01.acme.internal",
+			Description: "Reported by alice@acme.com",
+		},
+	}
+	manager.SaveWorkersForTest(workers, filepath.Join(tmpDir, "workers.json"))
+	require.NoError(t, manager.AppendThreadMessage("export", worker.MessageTypeUser, "alice says the build fails", nil))
+	require.NoError(t, manager.AppendThreadMessage("export", worker.MessageTypeAssistant, "Checked db01.acme.internal",
+		map[string]interface{}{"host": "db01.acme.internal"}))
+
+	return NewLogHandler(manager)
+}
+
+func exportTask(t *testing.T, handler *LogHandler, query string) (*httptest.ResponseRecorder, TaskExportDTO) {
+	req := withTaskID(httptest.NewRequest(http.MethodGet, "/api/tasks/export/export"+query, nil), "export")
+	w := httptest.NewRecorder()
+	errormw.Error(handler.ExportTask).ServeHTTP(w, req)
+
+	var export TaskExportDTO
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &export))
+	}
+	return w, export
+}
+
+func TestExportTask(t *testing.T) {
+	handler := setupExportHandler(t)
+
+	w, export := exportTask(t, handler, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, export.Anonymized)
+	assert.Equal(t, "export", export.Task.ID)
+	assert.Len(t, export.Logs, 2)
+	require.Len(t, export.Thread, 2)
+	assert.Equal(t, "alice says the build fails", export.Thread[0].Content)
+	assert.Nil(t, export.Pseudonyms)
+}
+
+func TestExportTask_Anonymized(t *testing.T) {
+	handler := setupExportHandler(t)
+
+	w, export := exportTask(t, handler, "?anonymize=true")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, export.Anonymized)
+
+	body := w.Body.String()
+	for _, secret := range []string{"alice", "acme", "db01"} {
+		assert.NotContains(t, body, secret)
+	}
+
+	// The same host gets the same pseudonym in the task, thread and metadata
+	host, ok := export.Thread[1].Metadata["host"].(string)
+	require.True(t, ok)
+	assert.Regexp(t, `^host\d+\.example$`, host)
+	assert.Equal(t, "Fix build on "+host, export.Task.Title)
+	assert.Equal(t, "Checked "+host, export.Thread[1].Content)
+
+	// The username is learned from the log before the thread mentions it
+	assert.Regexp(t, `^open /path\d+/main.go: permission denied$`, export.Logs[1])
+	assert.Regexp(t, `^user\d+ says the build fails$`, export.Thread[0].Content)
+	assert.Equal(t, "export", export.Task.ID)
+}
+
+func TestExportTask_Errors(t *testing.T) {
+	handler := setupExportHandler(t)
+
+	w, _ := exportTask(t, handler, "?anonymize=maybe")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req := withTaskID(httptest.NewRequest(http.MethodGet, "/api/tasks/missing/export", nil), "missing")
+	w = httptest.NewRecorder()
+	errormw.Error(handler.ExportTask).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+)
+
+// taskFields are the JSON names of the fields a task can be trimmed to
+var taskFields = jsonFields(reflect.TypeOf(TaskDTO{}))
+
+// jsonFields returns the JSON names of a struct's encoded fields
+func jsonFields(t reflect.Type) map[string]bool {
+	fields := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = true
+	}
+	return fields
+}
+
+// parseFields returns the task fields named by the request's fields
+// parameter, or nil when every field was asked for
+func parseFields(r *http.Request) ([]string, error) {
+	param := r.URL.Query().Get("fields")
+	if param == "" {
+		return nil, nil
+	}
+
+	var fields []string
+	for _, field := range strings.Split(param, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !taskFields[field] {
+			return nil, apierr.BadRequestf("Invalid field: %s", field)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// withFields returns the task trimmed to the given fields when it is encoded.
+// A nil list keeps every field.
+func (t TaskDTO) withFields(fields []string) TaskDTO {
+	t.fields = fields
+	return t
+}
+
+// MarshalJSON encodes the task, keeping only its selected fields when it was
+// trimmed. Fields left empty are omitted as usual, even when selected.
+func (t TaskDTO) MarshalJSON() ([]byte, error) {
+	type plain TaskDTO
+	data, err := json.Marshal(plain(t))
+	if err != nil || t.fields == nil {
+		return data, err
+	}
+
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	selected := make(map[string]json.RawMessage, len(t.fields))
+	for _, field := range t.fields {
+		if value, ok := all[field]; ok {
+			selected[field] = value
+		}
+	}
+	return json.Marshal(selected)
+}
+
+// GetTask returns a task, trimmed to the fields parameter when it is set
+func (h *TaskHandler) GetTask(w http.ResponseWriter, r *http.Request) error {
+	fields, err := parseFields(r)
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := h.manager.Snapshot()
+	if err != nil {
+		return apierr.WrapInternal(err, "Failed to get task")
+	}
+	task := snapshot.Worker(chi.URLParam(r, "id"))
+	if task == nil {
+		return apierr.NotFound("Task not found")
+	}
+
+	tree := worker.NewHierarchyFromList(snapshot.Workers())
+	return response.OKWithETag(w, r, newTaskDTO(task, tree).withFields(fields))
+}
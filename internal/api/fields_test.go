@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+func TestListTasks_Fields(t *testing.T) {
+	router, manager, _ := setupFakeRouter(t)
+	manager.Add(&worker.Worker{ID: "a", Status: worker.StatusRunning, Title: "Fix build", Started: time.Now()})
+
+	w := serve(router, "GET", "/api/tasks?fields=id,status,title", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Tasks []map[string]interface{} `json:"tasks"`
+		Total int                      `json:"total"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 1, resp.Total)
+	require.Len(t, resp.Tasks, 1)
+	assert.Equal(t, map[string]interface{}{"id": "a", "status": "running", "title": "Fix build"}, resp.Tasks[0])
+
+	// Without fields every field is returned
+	w = serve(router, "GET", "/api/tasks", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Contains(t, resp.Tasks[0], "log_file")
+	assert.Contains(t, resp.Tasks[0], "backend")
+
+	w = serve(router, "GET", "/api/tasks?fields=id,secret_sauce", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "secret_sauce")
+}
+
+func TestGetTask(t *testing.T) {
+	router, manager, _ := setupFakeRouter(t)
+	manager.Add(
+		&worker.Worker{ID: "parent", Status: worker.StatusRunning, Title: "Parent", Started: time.Now()},
+		&worker.Worker{ID: "child", Status: worker.StatusFailed, ParentID: "parent", Started: time.Now()},
+	)
+
+	w := serve(router, "GET", "/api/tasks/parent", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Header().Get("ETag"))
+	var task TaskDTO
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &task))
+	assert.Equal(t, "Parent", task.Title)
+	assert.Equal(t, 1, task.ChildCount)
+
+	w = serve(router, "GET", "/api/tasks/parent?fields=id,child_count", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"id":"parent","child_count":1}`, w.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, serve(router, "GET", "/api/tasks/missing", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(router, "GET", "/api/tasks/parent?fields=nope", "").Code)
+}
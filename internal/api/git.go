@@ -0,0 +1,77 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/git"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+)
+
+// GetTaskDiff returns the changes in a task's workspace since it branched
+// from the base branch, limited to the files named by ?file=
+func (h *TaskHandler) GetTaskDiff(w http.ResponseWriter, r *http.Request) error {
+	workspace, err := h.manager.Workspace(chi.URLParam(r, "id"))
+	if err != nil {
+		return taskError(err, "get task diff")
+	}
+
+	diff, err := git.Repo{Dir: workspace.Dir}.Diff(r.Context(), workspace.BaseBranch, r.URL.Query()["file"]...)
+	if err != nil {
+		return gitError(err, "get task diff")
+	}
+	return response.OK(w, diff)
+}
+
+// GetTaskCommits returns the commits made on a task's branch since it
+// branched from the base branch
+func (h *TaskHandler) GetTaskCommits(w http.ResponseWriter, r *http.Request) error {
+	workspace, err := h.manager.Workspace(chi.URLParam(r, "id"))
+	if err != nil {
+		return taskError(err, "get task commits")
+	}
+
+	commits, err := git.Repo{Dir: workspace.Dir}.Commits(r.Context(), workspace.BaseBranch)
+	if err != nil {
+		return gitError(err, "get task commits")
+	}
+	return response.OK(w, TaskCommitsResponse{Base: workspace.BaseBranch, Commits: commits})
+}
+
+// CheckTaskMerge reports whether merging a task's branch into the base
+// branch, or the branch given as ?target=, would conflict
+func (h *TaskHandler) CheckTaskMerge(w http.ResponseWriter, r *http.Request) error {
+	workspace, err := h.manager.Workspace(chi.URLParam(r, "id"))
+	if err != nil {
+		return taskError(err, "check task merge")
+	}
+
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		target = workspace.BaseBranch
+	}
+
+	check, err := git.Repo{Dir: workspace.Dir}.MergeCheck(r.Context(), target)
+	if errors.Is(err, git.ErrUnknownRevision) && r.URL.Query().Get("target") != "" {
+		return apierr.Wrap(err, http.StatusConflict, "Target branch not found in task workspace")
+	}
+	if err != nil {
+		return gitError(err, "check task merge")
+	}
+	return response.OK(w, check)
+}
+
+// gitError maps a git error to an API error
+func gitError(err error, action string) error {
+	switch {
+	case errors.Is(err, git.ErrNotRepository):
+		return apierr.Wrap(err, http.StatusConflict, "Task workspace is not a git repository")
+	case errors.Is(err, git.ErrUnknownRevision):
+		return apierr.Wrap(err, http.StatusConflict, "Base branch not found in task workspace")
+	default:
+		return apierr.WrapInternalf(err, "Failed to %s", action)
+	}
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/git"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+// setupGitRepo creates a repository with a commit on main and two changed files
+func setupGitRepo(t *testing.T) string {
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"commit", "-q", "--allow-empty", "-m", "initial"},
+	} {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("todo\n"), 0644))
+	return dir
+}
+
+func TestGetTaskDiff(t *testing.T) {
+	handler, manager := setupHierarchyHandler(t)
+	router := NewRouter(handler, handler.hub)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	// The workspace isn't a repository until one is configured
+	manager.SetWorkspace(worker.Workspace{Dir: t.TempDir(), BaseBranch: "main"})
+	assert.Equal(t, http.StatusConflict, get("/api/tasks/parent/diff").Code)
+
+	manager.SetWorkspace(worker.Workspace{Dir: setupGitRepo(t), BaseBranch: "main"})
+	w := get("/api/tasks/parent/diff")
+	require.Equal(t, http.StatusOK, w.Code)
+	var diff git.Diff
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &diff))
+	assert.Equal(t, "main", diff.Base)
+	assert.Equal(t, []string{"main.go", "notes.txt"}, diff.Files)
+
+	w = get("/api/tasks/parent/diff?file=notes.txt")
+	require.Equal(t, http.StatusOK, w.Code)
+	var filtered git.Diff
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &filtered))
+	assert.Equal(t, []string{"notes.txt"}, filtered.Files)
+	assert.Contains(t, filtered.Patch, "+todo")
+
+	// Projects compare against their own default branch
+	release := "release"
+	_, err := manager.UpdateProject(worker.DefaultProject, worker.ProjectUpdate{DefaultBranch: &release})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, get("/api/tasks/parent/diff").Code)
+
+	assert.Equal(t, http.StatusNotFound, get("/api/tasks/missing/diff").Code)
+}
+
+func TestGetTaskCommits(t *testing.T) {
+	handler, manager := setupHierarchyHandler(t)
+	router := NewRouter(handler, handler.hub)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	dir := setupGitRepo(t)
+	for _, args := range [][]string{
+		{"checkout", "-q", "-b", "task"},
+		{"add", "main.go"},
+		{"commit", "-q", "-m", "add main\n\nStarts the program."},
+	} {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	manager.SetWorkspace(worker.Workspace{Dir: dir, BaseBranch: "main"})
+
+	w := get("/api/tasks/parent/commits")
+	require.Equal(t, http.StatusOK, w.Code)
+	var response TaskCommitsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "main", response.Base)
+	require.Len(t, response.Commits, 1)
+	assert.Equal(t, "add main", response.Commits[0].Subject)
+	assert.Equal(t, "add main\n\nStarts the program.", response.Commits[0].Message)
+	assert.Equal(t, 1, response.Commits[0].Files)
+	assert.Equal(t, 1, response.Commits[0].Insertions)
+
+	manager.SetWorkspace(worker.Workspace{Dir: t.TempDir(), BaseBranch: "main"})
+	assert.Equal(t, http.StatusConflict, get("/api/tasks/parent/commits").Code)
+	assert.Equal(t, http.StatusNotFound, get("/api/tasks/missing/commits").Code)
+}
+
+func TestCheckTaskMerge(t *testing.T) {
+	handler, manager := setupHierarchyHandler(t)
+	router := NewRouter(handler, handler.hub)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	dir := setupGitRepo(t)
+	for _, args := range [][]string{
+		{"add", "."},
+		{"commit", "-q", "-m", "add files"},
+		{"checkout", "-q", "-b", "task"},
+	} {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	manager.SetWorkspace(worker.Workspace{Dir: dir, BaseBranch: "main"})
+
+	w := get("/api/tasks/parent/merge-check")
+	require.Equal(t, http.StatusOK, w.Code)
+	var check git.MergeCheck
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &check))
+	assert.Equal(t, "main", check.Target)
+	assert.True(t, check.Mergeable)
+
+	assert.Equal(t, http.StatusConflict, get("/api/tasks/parent/merge-check?target=release").Code)
+	assert.Equal(t, http.StatusNotFound, get("/api/tasks/missing/merge-check").Code)
+}
@@ -2,9 +2,61 @@ package api
 
 import (
 	"net/http"
+
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
 )
 
+// HealthHandler reports that the process is alive. It is served at /livez
+// and, for existing clients, /healthz.
 func HealthHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("ok"))
 }
+
+// ReadinessHandler reports whether the daemon is able to accept work
+type ReadinessHandler struct {
+	manager HealthManager
+}
+
+// NewReadinessHandler creates a new readiness handler
+func NewReadinessHandler(manager HealthManager) *ReadinessHandler {
+	return &ReadinessHandler{
+		manager: manager,
+	}
+}
+
+// ComponentStatusDTO is the result of one readiness check
+type ComponentStatusDTO struct {
+	Status string `json:"status"` // "ok" or "fail"
+	Error  string `json:"error,omitempty"`
+}
+
+// ReadinessDTO is the response body of the readiness endpoint
+type ReadinessDTO struct {
+	Status     string                        `json:"status"` // "ready" or "not_ready"
+	Components map[string]ComponentStatusDTO `json:"components"`
+}
+
+// GetReadiness runs every readiness check, responding 200 when all pass and
+// 503 otherwise
+func (h *ReadinessHandler) GetReadiness(w http.ResponseWriter, r *http.Request) error {
+	readiness := ReadinessDTO{
+		Status:     "ready",
+		Components: make(map[string]ComponentStatusDTO),
+	}
+
+	for _, check := range h.manager.ReadinessChecks() {
+		if err := check.Check(); err != nil {
+			readiness.Status = "not_ready"
+			readiness.Components[check.Name] = ComponentStatusDTO{Status: "fail", Error: err.Error()}
+			continue
+		}
+		readiness.Components[check.Name] = ComponentStatusDTO{Status: "ok"}
+	}
+
+	status := http.StatusOK
+	if readiness.Status != "ready" {
+		status = http.StatusServiceUnavailable
+	}
+	return response.JSON(w, status, readiness)
+}
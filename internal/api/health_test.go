@@ -1,11 +1,18 @@
 package api
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	errormw "github.com/brettsmith212/amp-orchestrator-2/internal/middleware"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
 )
 
 func TestHealthHandler(t *testing.T) {
@@ -17,3 +24,57 @@ func TestHealthHandler(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, "ok", w.Body.String())
 }
+
+func TestReadinessHandler(t *testing.T) {
+	tmpDir := t.TempDir()
+	amp := filepath.Join(tmpDir, "amp")
+	require.NoError(t, os.WriteFile(amp, []byte("#!/bin/sh\n"), 0755))
+
+	manager := worker.NewManager(tmpDir)
+	manager.SetAmpBinary(amp)
+	handler := NewReadinessHandler(manager)
+
+	getReadiness := func() (int, ReadinessDTO) {
+		w := httptest.NewRecorder()
+		errormw.Error(handler.GetReadiness)(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		var readiness ReadinessDTO
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &readiness))
+		return w.Code, readiness
+	}
+
+	t.Run("ready", func(t *testing.T) {
+		code, readiness := getReadiness()
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "ready", readiness.Status)
+		assert.Equal(t, map[string]ComponentStatusDTO{
+			"amp_binary": {Status: "ok"},
+			"log_dir":    {Status: "ok"},
+			"state":      {Status: "ok"},
+		}, readiness.Components)
+	})
+
+	t.Run("amp binary not executable", func(t *testing.T) {
+		require.NoError(t, os.Chmod(amp, 0644))
+		defer os.Chmod(amp, 0755)
+
+		code, readiness := getReadiness()
+
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "not_ready", readiness.Status)
+		assert.Equal(t, "fail", readiness.Components["amp_binary"].Status)
+		assert.Contains(t, readiness.Components["amp_binary"].Error, "not executable")
+		assert.Equal(t, "ok", readiness.Components["state"].Status)
+	})
+
+	t.Run("corrupt state", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "workers.json"), []byte("{not json"), 0644))
+
+		code, readiness := getReadiness()
+
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "fail", readiness.Components["state"].Status)
+		assert.Equal(t, "ok", readiness.Components["amp_binary"].Status)
+	})
+}
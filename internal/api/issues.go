@@ -0,0 +1,242 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/ci"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/issue"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+)
+
+// maxIssuePayloadSize bounds issue webhook bodies; GitHub caps payloads at 25MB
+// but issue events are far smaller
+const maxIssuePayloadSize = 5 << 20
+
+// IssueCommentAnnotation is the annotation key recording the outcome of
+// commenting on a finished task's GitHub issue
+const IssueCommentAnnotation = "issue-comment"
+
+const (
+	// issueCommentTimeout bounds posting a comment on an issue
+	issueCommentTimeout = time.Minute
+
+	// maxIssueSummary is the longest summary, in characters, posted on an issue
+	maxIssueSummary = 4000
+)
+
+// IssueCommenter posts comments on GitHub issues, returning the comment's URL
+type IssueCommenter interface {
+	Comment(ctx context.Context, key, body string) (string, error)
+}
+
+// IssueSync configures how tasks inherit priority and tags from the issues
+// they were created from, and how finished tasks report back to them
+type IssueSync struct {
+	Mapping      issue.Mapping
+	GitHubSecret string         // Verifies X-Hub-Signature-256; empty accepts unsigned payloads
+	JiraSecret   string         // Required as ?secret=; empty accepts any request
+	GitHubRepo   string         // "owner/repo" of issues referenced by number alone
+	Commenter    IssueCommenter // Comments on a task's GitHub issue when it finishes; nil disables comments
+}
+
+// SetIssueSync sets the mapping applied to tasks linked to issues and the
+// secrets that authenticate issue webhooks
+func (h *TaskHandler) SetIssueSync(sync IssueSync) {
+	h.issues = sync
+}
+
+// ReceiveGitHubIssue syncs tasks linked to the issue in a GitHub "issues"
+// webhook, or to the pull requests in a "check_run" or "check_suite" webhook
+func (h *TaskHandler) ReceiveGitHubIssue(w http.ResponseWriter, r *http.Request) error {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxIssuePayloadSize))
+	if err != nil {
+		return apierr.BadRequest("Failed to read request body")
+	}
+
+	if h.issues.GitHubSecret != "" && !issue.VerifyGitHubSignature(h.issues.GitHubSecret, body, r.Header.Get("X-Hub-Signature-256")) {
+		return apierr.New(http.StatusUnauthorized, "Invalid webhook signature")
+	}
+
+	switch event := r.Header.Get("X-GitHub-Event"); event {
+	case "ping":
+		return response.OK(w, IssueSyncResponse{Updated: []string{}})
+	case "issues":
+	case ci.EventCheckRun, ci.EventCheckSuite:
+		return h.syncCheck(w, event, body)
+	default:
+		return apierr.BadRequestf("Unsupported GitHub event: %s", event)
+	}
+
+	source, err := issue.ParseGitHub(body)
+	if err != nil {
+		return apierr.Wrap(err, http.StatusBadRequest, "Invalid GitHub issue payload")
+	}
+	return h.syncIssue(w, source)
+}
+
+// ReceiveJiraIssue syncs tasks linked to the issue in a Jira issue webhook
+func (h *TaskHandler) ReceiveJiraIssue(w http.ResponseWriter, r *http.Request) error {
+	secret := r.URL.Query().Get("secret")
+	if h.issues.JiraSecret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(h.issues.JiraSecret)) != 1 {
+		return apierr.New(http.StatusUnauthorized, "Invalid webhook secret")
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxIssuePayloadSize))
+	if err != nil {
+		return apierr.BadRequest("Failed to read request body")
+	}
+
+	source, err := issue.ParseJira(body)
+	if err != nil {
+		return apierr.Wrap(err, http.StatusBadRequest, "Invalid Jira issue payload")
+	}
+	return h.syncIssue(w, source)
+}
+
+// syncIssue applies an issue's priority and labels to its linked tasks and
+// broadcasts the updated tasks
+func (h *TaskHandler) syncIssue(w http.ResponseWriter, source *issue.Issue) error {
+	updated, err := h.manager.SyncIssue(source.Provider, source.Key, h.issues.Mapping.Fields(*source))
+	if err != nil {
+		return apierr.WrapInternal(err, "Failed to sync issue")
+	}
+
+	if err := response.OK(w, IssueSyncResponse{Issue: source, Updated: updated}); err != nil {
+		return err
+	}
+
+	for _, id := range updated {
+		h.broadcastTaskAfterStop(id)
+	}
+	return nil
+}
+
+// issueFromRequest validates the issue a task is being created from, deriving
+// a GitHub issue's key from its URL or from its number in defaultRepo
+func issueFromRequest(req *IssueRequest, defaultRepo string) (*issue.Issue, error) {
+	provider := strings.ToLower(req.Provider)
+	if provider == "" {
+		provider = issue.ProviderGitHub
+	}
+	if provider != issue.ProviderGitHub && provider != issue.ProviderJira {
+		return nil, apierr.BadRequestf("Invalid issue provider: %s", req.Provider)
+	}
+
+	key, url := req.Key, req.URL
+	if provider == issue.ProviderGitHub {
+		switch {
+		case key != "":
+			if _, _, err := issue.SplitGitHubKey(key); err != nil {
+				return nil, apierr.BadRequestf("Invalid GitHub issue key: %s", key)
+			}
+		case url != "":
+			parsed, err := issue.ParseGitHubURL(url)
+			if err != nil {
+				return nil, apierr.BadRequestf("Invalid GitHub issue URL: %s", url)
+			}
+			key = parsed
+		case req.Number > 0:
+			if defaultRepo == "" {
+				return nil, apierr.BadRequest("Issue numbers require issues.github_repo to be configured")
+			}
+			key = fmt.Sprintf("%s#%d", defaultRepo, req.Number)
+		}
+		if key != "" && url == "" {
+			repo, number, _ := issue.SplitGitHubKey(key)
+			url = fmt.Sprintf("https://github.com/%s/issues/%d", repo, number)
+		}
+	}
+	if key == "" {
+		return nil, apierr.BadRequest("Issue key is required")
+	}
+
+	labels := req.Labels
+	if labels == nil {
+		labels = []string{}
+	}
+	return &issue.Issue{
+		Provider: provider,
+		Key:      key,
+		URL:      url,
+		Priority: req.Priority,
+		Labels:   labels,
+	}, nil
+}
+
+// commentOnIssue posts a finished task's status, pull request and summary on
+// its GitHub issue, recording the outcome as an "issue-comment" annotation
+func (h *TaskHandler) commentOnIssue(task TaskDTO) {
+	if h.issues.Commenter == nil || task.Issue == nil || task.Issue.Provider != issue.ProviderGitHub {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), issueCommentTimeout)
+	defer cancel()
+
+	annotation := worker.Annotation{Key: IssueCommentAnnotation, Status: worker.AnnotationFailure}
+	url, err := h.issues.Commenter.Comment(ctx, task.Issue.Key, h.issueComment(task))
+	if err != nil {
+		annotation.Summary = err.Error()
+	} else {
+		annotation.Status = worker.AnnotationSuccess
+		annotation.Summary = "Commented on " + task.Issue.Key
+		annotation.URL = url
+	}
+
+	if _, err := h.manager.Annotate(task.ID, annotation); err != nil {
+		log.Printf("Failed to record issue comment of task %s: %v", task.ID, err)
+		return
+	}
+	h.broadcastTaskAfterStop(task.ID)
+}
+
+// issueComment renders the comment posted on a finished task's issue
+func (h *TaskHandler) issueComment(task TaskDTO) string {
+	title := task.Title
+	if title == "" {
+		title = "Task " + task.ID
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "**%s** finished with status `%s`.\n", title, task.Status)
+	if pr := task.PullRequest; pr != nil {
+		if pr.URL != "" {
+			fmt.Fprintf(&b, "\nPull request: %s\n", pr.URL)
+		} else {
+			fmt.Fprintf(&b, "\nPull request: %s#%d\n", pr.Repo, pr.Number)
+		}
+	}
+	if summary := h.taskSummary(task.ID); summary != "" {
+		fmt.Fprintf(&b, "\n### Summary\n\n%s\n", summary)
+	}
+	return b.String()
+}
+
+// taskSummary returns amp's last response in a task's thread, shortened to
+// fit comfortably in an issue comment
+func (h *TaskHandler) taskSummary(taskID string) string {
+	messages, err := h.manager.GetThreadMessages(taskID, 0, 0)
+	if err != nil {
+		return ""
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Type != worker.MessageTypeAssistant {
+			continue
+		}
+		summary := strings.TrimSpace(messages[i].Content)
+		if runes := []rune(summary); len(runes) > maxIssueSummary {
+			summary = string(runes[:maxIssueSummary]) + "…"
+		}
+		return summary
+	}
+	return ""
+}
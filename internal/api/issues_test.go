@@ -0,0 +1,208 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub/hubtest"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/issue"
+	errormw "github.com/brettsmith212/amp-orchestrator-2/internal/middleware"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+// setupIssueHandler saves one task linked to a GitHub issue and one linked to a Jira issue
+func setupIssueHandler(t *testing.T) (*TaskHandler, *worker.Manager) {
+	tempDir := t.TempDir()
+	manager := worker.NewManager(tempDir)
+	h := hub.NewHub()
+	hubtest.Run(t, h)
+	handler := NewTaskHandler(manager, h)
+	handler.SetIssueSync(IssueSync{
+		Mapping: issue.Mapping{
+			Priorities: map[string]string{"Highest": "high", "P1": "high"},
+			Tags:       map[string]string{"bug": "type:bug"},
+		},
+		GitHubSecret: "gh-secret",
+		JiraSecret:   "jira-secret",
+	})
+
+	mockWorkers := map[string]*worker.Worker{
+		"gh": {
+			ID: "gh", ThreadID: "T-gh", Started: time.Now(), Status: worker.StatusRunning,
+			Priority: "low", Tags: []string{"manual"},
+			Issue: &worker.IssueLink{Provider: issue.ProviderGitHub, Key: "acme/api#42"},
+		},
+		"jira": {
+			ID: "jira", ThreadID: "T-jira", Started: time.Now(), Status: worker.StatusRunning,
+			Issue: &worker.IssueLink{Provider: issue.ProviderJira, Key: "OPS-17"},
+		},
+	}
+	require.NoError(t, manager.SaveWorkersForTest(mockWorkers, filepath.Join(tempDir, "workers.json")))
+	return handler, manager
+}
+
+func githubIssueRequest(handler *TaskHandler, event, body, secret string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/integrations/github", strings.NewReader(body))
+	req.Header.Set("X-GitHub-Event", event)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	w := httptest.NewRecorder()
+	errormw.Error(handler.ReceiveGitHubIssue)(w, req)
+	return w
+}
+
+func TestReceiveGitHubIssue_SyncsLinkedTasks(t *testing.T) {
+	handler, manager := setupIssueHandler(t)
+
+	body := `{"action":"labeled","issue":{"number":42,"labels":[{"name":"bug"},{"name":"P1"}]},"repository":{"full_name":"acme/api"}}`
+	w := githubIssueRequest(handler, "issues", body, "gh-secret")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp IssueSyncResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, []string{"gh"}, resp.Updated)
+	assert.Equal(t, "acme/api#42", resp.Issue.Key)
+
+	saved := findWorker(t, manager, "gh")
+	assert.Equal(t, "high", saved.Priority)
+	assert.Equal(t, []string{"manual", "type:bug"}, saved.Tags)
+
+	// Removing the label removes the tag it added
+	body = `{"action":"unlabeled","issue":{"number":42,"labels":[]},"repository":{"full_name":"acme/api"}}`
+	w = githubIssueRequest(handler, "issues", body, "gh-secret")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	saved = findWorker(t, manager, "gh")
+	assert.Equal(t, "high", saved.Priority)
+	assert.Equal(t, []string{"manual"}, saved.Tags)
+}
+
+func TestReceiveGitHubIssue_Rejections(t *testing.T) {
+	handler, _ := setupIssueHandler(t)
+
+	w := githubIssueRequest(handler, "issues", `{}`, "wrong-secret")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = githubIssueRequest(handler, "ping", `{"zen":"Design for failure."}`, "gh-secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = githubIssueRequest(handler, "push", `{}`, "gh-secret")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = githubIssueRequest(handler, "issues", `{"repository":{"full_name":"acme/api"}}`, "gh-secret")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestReceiveJiraIssue(t *testing.T) {
+	handler, manager := setupIssueHandler(t)
+	body := `{"webhookEvent":"jira:issue_updated","issue":{"key":"OPS-17","fields":{"priority":{"name":"Highest"},"labels":["bug"]}}}`
+
+	req := httptest.NewRequest(http.MethodPost, "/api/integrations/jira?secret=nope", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	errormw.Error(handler.ReceiveJiraIssue)(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/integrations/jira?secret=jira-secret", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	errormw.Error(handler.ReceiveJiraIssue)(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	saved := findWorker(t, manager, "jira")
+	assert.Equal(t, "high", saved.Priority)
+	assert.Equal(t, []string{"type:bug"}, saved.Tags)
+	assert.Empty(t, findWorker(t, manager, "gh").Issue.Tags)
+}
+
+func TestIssueFromRequest_Validation(t *testing.T) {
+	_, err := issueFromRequest(&IssueRequest{Provider: "gitlab", Key: "acme/api#1"}, "")
+	assert.Error(t, err)
+	_, err = issueFromRequest(&IssueRequest{Provider: "jira"}, "")
+	assert.Error(t, err)
+	_, err = issueFromRequest(&IssueRequest{Key: "acme#1"}, "")
+	assert.Error(t, err)
+	_, err = issueFromRequest(&IssueRequest{URL: "https://gitlab.com/acme/api/issues/1"}, "")
+	assert.Error(t, err)
+	_, err = issueFromRequest(&IssueRequest{Number: 7}, "")
+	assert.Error(t, err, "numbers need a default repository")
+
+	source, err := issueFromRequest(&IssueRequest{Provider: "GitHub", Key: "acme/api#1", Labels: nil}, "")
+	require.NoError(t, err)
+	assert.Equal(t, issue.ProviderGitHub, source.Provider)
+	assert.Equal(t, "https://github.com/acme/api/issues/1", source.URL)
+	assert.Equal(t, []string{}, source.Labels)
+}
+
+func TestIssueFromRequest_GitHubReferences(t *testing.T) {
+	source, err := issueFromRequest(&IssueRequest{URL: "https://github.com/acme/api/issues/42"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, issue.ProviderGitHub, source.Provider)
+	assert.Equal(t, "acme/api#42", source.Key)
+	assert.Equal(t, "https://github.com/acme/api/issues/42", source.URL)
+
+	source, err = issueFromRequest(&IssueRequest{Number: 7}, "acme/web")
+	require.NoError(t, err)
+	assert.Equal(t, "acme/web#7", source.Key)
+	assert.Equal(t, "https://github.com/acme/web/issues/7", source.URL)
+}
+
+// fakeCommenter records the comments posted on issues
+type fakeCommenter struct {
+	key  string
+	body string
+	err  error
+}
+
+func (c *fakeCommenter) Comment(ctx context.Context, key, body string) (string, error) {
+	c.key, c.body = key, body
+	if c.err != nil {
+		return "", c.err
+	}
+	return "https://github.com/acme/api/issues/42#issuecomment-1", nil
+}
+
+func TestCommentOnIssue(t *testing.T) {
+	handler, manager := setupIssueHandler(t)
+	commenter := &fakeCommenter{}
+	handler.issues.Commenter = commenter
+
+	_, err := manager.LinkPullRequest("gh", "acme/api", 43, "https://github.com/acme/api/pull/43")
+	require.NoError(t, err)
+	require.NoError(t, manager.AppendThreadMessage("gh", worker.MessageTypeAssistant, "Fixed the login redirect.", nil))
+
+	task := newTaskDTO(findWorker(t, manager, "gh"), nil)
+	handler.commentOnIssue(task)
+
+	assert.Equal(t, "acme/api#42", commenter.key)
+	assert.Contains(t, commenter.body, "**Task gh** finished with status `stopped`.")
+	assert.Contains(t, commenter.body, "Pull request: https://github.com/acme/api/pull/43")
+	assert.Contains(t, commenter.body, "### Summary\n\nFixed the login redirect.")
+
+	annotations := findWorker(t, manager, "gh").Annotations
+	require.Len(t, annotations, 1)
+	assert.Equal(t, IssueCommentAnnotation, annotations[0].Key)
+	assert.Equal(t, worker.AnnotationSuccess, annotations[0].Status)
+	assert.Equal(t, "https://github.com/acme/api/issues/42#issuecomment-1", annotations[0].URL)
+
+	// Failures are recorded, and Jira issues aren't commented on
+	commenter.err = errors.New("403 Forbidden")
+	handler.commentOnIssue(task)
+	assert.Equal(t, worker.AnnotationFailure, findWorker(t, manager, "gh").Annotations[0].Status)
+
+	commenter.key = ""
+	handler.commentOnIssue(newTaskDTO(findWorker(t, manager, "jira"), nil))
+	assert.Empty(t, commenter.key)
+}
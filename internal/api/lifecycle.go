@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+)
+
+// GetTaskAttempts lists the amp invocations of a task, with what started
+// each one and how it exited
+func (h *TaskHandler) GetTaskAttempts(w http.ResponseWriter, r *http.Request) error {
+	attempts, err := h.manager.Attempts(chi.URLParam(r, "id"))
+	if err != nil {
+		return taskError(err, "get task attempts")
+	}
+	return response.OK(w, AttemptsResponse{Attempts: attempts})
+}
+
+// GetTaskTimeline lists the status changes of a task with what caused each
+// one
+func (h *TaskHandler) GetTaskTimeline(w http.ResponseWriter, r *http.Request) error {
+	timeline, err := h.manager.Timeline(chi.URLParam(r, "id"))
+	if err != nil {
+		return taskError(err, "get task timeline")
+	}
+	return response.OK(w, TimelineResponse{Timeline: timeline})
+}
+
+// GetTaskQueue lists the messages waiting for amp to answer the ones sent to
+// a task before them
+func (h *TaskHandler) GetTaskQueue(w http.ResponseWriter, r *http.Request) error {
+	queue, err := h.manager.QueuedMessages(chi.URLParam(r, "id"))
+	if err != nil {
+		return taskError(err, "get task queue")
+	}
+	return response.OK(w, QueueResponse{Queue: queue})
+}
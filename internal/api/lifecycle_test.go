@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub/hubtest"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker/workertest"
+)
+
+func TestGetTaskAttempts(t *testing.T) {
+	router, _, _ := setupFakeRouter(t)
+
+	require.Equal(t, http.StatusCreated, serve(router, "POST", "/api/tasks", `{"message":"fix the build"}`).Code)
+	require.Equal(t, http.StatusAccepted, serve(router, "POST", "/api/tasks/task-1/stop", "").Code)
+	require.Equal(t, http.StatusAccepted, serve(router, "POST", "/api/tasks/task-1/retry", `{"message":"try again"}`).Code)
+
+	w := serve(router, "GET", "/api/tasks/task-1/attempts", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp AttemptsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Attempts, 2)
+	assert.Equal(t, worker.AttemptStart, resp.Attempts[0].Action)
+	assert.NotNil(t, resp.Attempts[0].Finished)
+	assert.Equal(t, worker.AttemptRetry, resp.Attempts[1].Action)
+	assert.Equal(t, 2, resp.Attempts[1].Number)
+	assert.Nil(t, resp.Attempts[1].Finished)
+
+	w = serve(router, "GET", "/api/tasks/missing/attempts", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetTaskTimeline(t *testing.T) {
+	router, _, _ := setupFakeRouter(t)
+
+	require.Equal(t, http.StatusCreated, serve(router, "POST", "/api/tasks", `{"message":"fix the build"}`).Code)
+	require.Equal(t, http.StatusAccepted, serve(router, "POST", "/api/tasks/task-1/stop", "").Code)
+
+	w := serve(router, "GET", "/api/tasks/task-1/timeline", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp TimelineResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Timeline, 2)
+	assert.Empty(t, resp.Timeline[0].From)
+	assert.Equal(t, worker.StatusRunning, resp.Timeline[0].To)
+	assert.Equal(t, worker.StatusRunning, resp.Timeline[1].From)
+	assert.Equal(t, worker.StatusStopped, resp.Timeline[1].To)
+	assert.Equal(t, worker.ActorAPI, resp.Timeline[1].Actor)
+
+	w = serve(router, "GET", "/api/tasks/missing/timeline", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetTaskQueue(t *testing.T) {
+	router, manager, _ := setupFakeRouter(t)
+
+	require.Equal(t, http.StatusCreated, serve(router, "POST", "/api/tasks", `{"message":"fix the build"}`).Code)
+	queued := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	manager.SetQueue("task-1", worker.QueuedMessage{Attempt: 2, Message: "and the tests", Queued: queued})
+
+	w := serve(router, "GET", "/api/tasks/task-1/queue", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"queue":[{"attempt":2,"message":"and the tests","queued":"2024-05-01T12:00:00Z"}]}`, w.Body.String())
+
+	w = serve(router, "GET", "/api/tasks/missing/queue", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestContinueTask_ReturnsAttempt(t *testing.T) {
+	router, _, _ := setupFakeRouter(t)
+
+	require.Equal(t, http.StatusCreated, serve(router, "POST", "/api/tasks", `{"message":"fix the build"}`).Code)
+	w := serve(router, "POST", "/api/tasks/task-1/continue", `{"message":"and the tests"}`)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	assert.JSONEq(t, `{"attempt":2}`, w.Body.String())
+}
+
+func TestBroadcastContinueFinished(t *testing.T) {
+	manager := workertest.New()
+	h := hubtest.New(t)
+	client := h.Connect()
+	handler := NewTaskHandler(manager, h.Hub)
+	manager.Add(&worker.Worker{ID: "w", Status: worker.StatusRunning})
+
+	code := 0
+	handler.BroadcastContinueFinished("w", worker.Attempt{Number: 2, Action: worker.AttemptContinue, ExitCode: &code})
+	client.ExpectEvent(hub.MessageTypeTaskUpdate, 0)
+	msg := client.ExpectEvent("continue-finished", 0)
+	assert.Contains(t, string(msg.Data), `"task_id":"w","attempt":{"number":2,"action":"continue"`)
+}
@@ -1,60 +1,49 @@
 package api
 
 import (
-	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/ansi"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
 )
 
 // LogHandler handles log-related API requests
 type LogHandler struct {
-	manager *worker.Manager
+	manager LogManager
+
+	// How often followed logs are checked for new lines, and how long they
+	// may stay quiet before a heartbeat is sent
+	followInterval    time.Duration
+	heartbeatInterval time.Duration
 }
 
 // NewLogHandler creates a new log handler
-func NewLogHandler(manager *worker.Manager) *LogHandler {
+func NewLogHandler(manager LogManager) *LogHandler {
 	return &LogHandler{
-		manager: manager,
+		manager:           manager,
+		followInterval:    250 * time.Millisecond,
+		heartbeatInterval: 15 * time.Second,
 	}
 }
 
 // GetTaskLogs serves the log file for a specific task
-// Supports optional ?tail=n query parameter to limit number of lines
-func (h *LogHandler) GetTaskLogs(w http.ResponseWriter, r *http.Request) {
+// Supports optional ?tail=n query parameter to limit number of lines, and
+// ?follow=true to keep streaming lines as they are written. A Range header or
+// ?offset=n returns the log's raw bytes from that position instead, and
+// ?ansi=strip removes escape sequences and control characters from lines.
+func (h *LogHandler) GetTaskLogs(w http.ResponseWriter, r *http.Request) error {
 	taskID := chi.URLParam(r, "id")
 	if taskID == "" {
-		http.Error(w, "Task ID is required", http.StatusBadRequest)
-		return
-	}
-
-	// Find the worker to get log file path
-	workers, err := h.manager.ListWorkers()
-	if err != nil {
-		http.Error(w, "Failed to list workers", http.StatusInternalServerError)
-		return
-	}
-
-	var logFile string
-	for _, worker := range workers {
-		if worker.ID == taskID {
-			logFile = worker.LogFile
-			break
-		}
-	}
-
-	if logFile == "" {
-		http.Error(w, "Task not found", http.StatusNotFound)
-		return
-	}
-
-	// Check if log file exists
-	if _, err := os.Stat(logFile); os.IsNotExist(err) {
-		http.Error(w, "Log file not found", http.StatusNotFound)
-		return
+		return apierr.BadRequest("Task ID is required")
 	}
 
 	// Parse tail parameter
@@ -64,76 +53,406 @@ func (h *LogHandler) GetTaskLogs(w http.ResponseWriter, r *http.Request) {
 		var err error
 		tailLines, err = strconv.Atoi(tailParam)
 		if err != nil || tailLines < 0 {
-			http.Error(w, "Invalid tail parameter", http.StatusBadRequest)
-			return
+			return apierr.BadRequest("Invalid tail parameter")
 		}
 	}
 
-	// Set response headers
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.Header().Set("Cache-Control", "no-cache")
+	var follow bool
+	if followParam := r.URL.Query().Get("follow"); followParam != "" {
+		var err error
+		follow, err = strconv.ParseBool(followParam)
+		if err != nil {
+			return apierr.BadRequest("Invalid follow parameter")
+		}
+	}
+
+	// Parse the byte range, given as a Range header or an offset
+	var byteRange *logRange
+	rangeHeader := r.Header.Get("Range")
+	offsetParam := r.URL.Query().Get("offset")
+	if rangeHeader != "" && offsetParam != "" {
+		return apierr.BadRequest("Range and offset cannot be combined")
+	}
+	if rangeHeader != "" {
+		parsed, err := parseLogRange(rangeHeader)
+		if err != nil {
+			return err
+		}
+		byteRange = &parsed
+	}
+	if offsetParam != "" {
+		offset, err := strconv.ParseInt(offsetParam, 10, 64)
+		if err != nil || offset < 0 {
+			return apierr.BadRequest("Invalid offset parameter")
+		}
+		byteRange = &logRange{start: offset, end: -1, offset: true}
+	}
+	if byteRange != nil && (tailLines > 0 || follow) {
+		return apierr.BadRequest("Byte ranges cannot be combined with tail or follow")
+	}
+
+	clean, err := parseANSI(r)
+	if err != nil {
+		return err
+	}
+	if byteRange != nil && r.URL.Query().Get("ansi") == "strip" {
+		return apierr.BadRequest("Byte ranges cannot be combined with ansi=strip")
+	}
 
-	// Open log file
-	file, err := os.Open(logFile)
+	// Open log file, which may have been offloaded to the object store
+	file, err := h.manager.OpenLog(taskID)
+	if errors.Is(err, worker.ErrLogNotFound) {
+		return apierr.NotFound("Log file not found")
+	}
 	if err != nil {
-		http.Error(w, "Failed to open log file", http.StatusInternalServerError)
-		return
+		return taskError(err, "open log file")
 	}
 	defer file.Close()
 
+	if byteRange != nil {
+		return serveLogRange(w, file, *byteRange)
+	}
+
+	// Start reading near the end of the log when only its last lines are
+	// wanted. One extra line is included in case the last is held back below.
+	if seeker, ok := file.(io.ReadSeeker); ok && tailLines > 0 {
+		offset, err := tailOffset(seeker, tailLines+1)
+		if err == nil {
+			_, err = seeker.Seek(offset, io.SeekStart)
+		}
+		if err != nil {
+			return apierr.WrapInternal(err, "Failed to read log file")
+		}
+	}
+
+	// A followed log's last line may still be being written, so hold it back
+	// until it's complete
+	var log io.Reader = file
+	var held *heldLineReader
+	if follow {
+		held = &heldLineReader{r: file, max: h.manager.LineLimit().MaxSize}
+		log = held
+	}
+
+	var lines []string
 	if tailLines > 0 {
-		// Read last N lines
-		lines, err := readLastLines(file, tailLines)
+		// Read last N lines before writing so failures still get an error response
+		lines, err = readLastLines(worker.NewLineReader(log, h.manager.LineLimit()), tailLines)
 		if err != nil {
-			http.Error(w, "Failed to read log file", http.StatusInternalServerError)
-			return
+			return apierr.WrapInternal(err, "Failed to read log file")
 		}
+	}
+
+	// Set response headers
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Accept-Ranges", "bytes")
 
+	if tailLines > 0 {
 		for _, line := range lines {
-			w.Write([]byte(line + "\n"))
+			w.Write([]byte(clean(line) + "\n"))
 		}
 	} else {
 		// Stream entire file
-		scanner := bufio.NewScanner(file)
+		scanner := worker.NewLineReader(log, h.manager.LineLimit())
 		for scanner.Scan() {
-			w.Write([]byte(scanner.Text() + "\n"))
+			w.Write([]byte(clean(scanner.Text()) + "\n"))
 		}
+	}
+
+	if follow {
+		h.followLog(w, r, taskID, held, clean)
+	}
+
+	// A scan error can't be reported once data has been sent
+	return nil
+}
+
+// parseANSI reads the ?ansi= parameter, returning how each log line is
+// written: as amp wrote it with "keep", the default, or as plain text with
+// "strip"
+func parseANSI(r *http.Request) (func(string) string, error) {
+	switch r.URL.Query().Get("ansi") {
+	case "", "keep":
+		return func(line string) string { return line }, nil
+	case "strip":
+		return ansi.Strip, nil
+	}
+	return nil, apierr.BadRequest("Invalid ansi parameter: must be strip or keep")
+}
 
-		if err := scanner.Err(); err != nil {
-			// Log error but don't fail the response since we may have already sent data
+// logRange is a range of a log's bytes. end is inclusive, or -1 for the end
+// of the log, and a negative start counts back from the end.
+type logRange struct {
+	start, end int64
+	offset     bool // Given as ?offset, so the end of the log isn't an error
+}
+
+// parseLogRange parses a Range header holding a single byte range
+func parseLogRange(header string) (logRange, error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return logRange{}, apierr.BadRequest("Invalid Range header: only a single byte range is supported")
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return logRange{}, apierr.BadRequest("Invalid Range header")
+	}
+
+	// A suffix range, bytes=-n, asks for the last n bytes
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return logRange{}, apierr.BadRequest("Invalid Range header")
+		}
+		return logRange{start: -n, end: -1}, nil
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return logRange{}, apierr.BadRequest("Invalid Range header")
+	}
+	end := int64(-1)
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return logRange{}, apierr.BadRequest("Invalid Range header")
+		}
+	}
+	return logRange{start: start, end: end}, nil
+}
+
+// serveLogRange writes the raw bytes of a log in byteRange, with the log's
+// size in X-Log-Size so the next request can start where this one ended.
+// Range requests are answered with 206 Partial Content, offsets with 200 OK.
+func serveLogRange(w http.ResponseWriter, file io.Reader, byteRange logRange) error {
+	// Logs offloaded to the object store can't seek, so read them whole
+	seeker, ok := file.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return apierr.WrapInternal(err, "Failed to read log file")
+		}
+		seeker = bytes.NewReader(data)
+	}
+
+	// The size is fixed now so the bytes sent match it while the log grows
+	size, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return apierr.WrapInternal(err, "Failed to read log file")
+	}
+	w.Header().Set("X-Log-Size", strconv.FormatInt(size, 10))
+	w.Header().Set("Accept-Ranges", "bytes")
+
+	start, end := byteRange.start, byteRange.end
+	if start < 0 {
+		start = max(size+start, 0)
+	}
+	if end < 0 || end >= size {
+		end = size - 1
+	}
+	if start >= size && !(byteRange.offset && start == size) {
+		if !byteRange.offset {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		}
+		return apierr.New(http.StatusRequestedRangeNotSatisfiable, "Range is beyond the end of the log")
+	}
+
+	if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+		return apierr.WrapInternal(err, "Failed to read log file")
+	}
+	length := end - start + 1
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+	if byteRange.offset {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+		w.WriteHeader(http.StatusPartialContent)
+	}
+
+	// A copy error can't be reported once data has been sent
+	io.CopyN(w, seeker, length)
+	return nil
+}
+
+// followLog streams lines as they are appended to a task's log until the
+// client disconnects or the task stops running. A ": heartbeat" line is sent
+// whenever the log has been quiet for the heartbeat interval so proxies and
+// clients don't time the connection out.
+func (h *LogHandler) followLog(w http.ResponseWriter, r *http.Request, taskID string, log *heldLineReader, clean func(string) string) {
+	rc := http.NewResponseController(w)
+	rc.Flush()
+
+	poll := time.NewTicker(h.followInterval)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(h.heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
 			return
+
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": heartbeat\n"); err != nil {
+				return
+			}
+			rc.Flush()
+
+		case <-poll.C:
+			// Check before reading so everything written before the task
+			// stopped is sent
+			running, err := h.manager.IsRunning(taskID)
+			if err != nil {
+				running = false
+			}
+
+			wrote := false
+			scanner := worker.NewLineReader(log, h.manager.LineLimit())
+			for scanner.Scan() {
+				if _, err := w.Write([]byte(clean(scanner.Text()) + "\n")); err != nil {
+					return
+				}
+				wrote = true
+			}
+			if scanner.Err() != nil {
+				return
+			}
+
+			if !running {
+				if line := log.rest(); line != "" {
+					w.Write([]byte(clean(line) + "\n"))
+				}
+				rc.Flush()
+				return
+			}
+			if wrote {
+				rc.Flush()
+				heartbeat.Reset(h.heartbeatInterval)
+			}
+		}
+	}
+}
+
+// heldLineReader reads a log that is still being written, returning only
+// complete lines and holding back a trailing partial line until the rest of
+// it arrives. It can be read again after io.EOF once the log has grown.
+type heldLineReader struct {
+	r   io.Reader
+	max int // Longest line kept; the rest of a longer partial line is dropped
+
+	buf     []byte
+	ready   []byte // Complete lines not yet returned
+	pending []byte // Start of the line being written
+}
+
+func (h *heldLineReader) Read(p []byte) (int, error) {
+	for len(h.ready) == 0 {
+		if h.buf == nil {
+			h.buf = make([]byte, 32*1024)
+		}
+		n, err := h.r.Read(h.buf)
+		h.pending = append(h.pending, h.buf[:n]...)
+		if i := bytes.LastIndexByte(h.pending, '\n'); i >= 0 {
+			h.ready = h.pending[:i+1]
+			h.pending = append([]byte(nil), h.pending[i+1:]...)
+		}
+
+		// Keep one byte more than a line may hold so the line reader still
+		// marks the line as truncated once it's complete
+		max := h.max
+		if max <= 0 {
+			max = worker.DefaultMaxLineSize
+		}
+		if len(h.pending) > max+1 {
+			h.pending = h.pending[:max+1]
+		}
+
+		if len(h.ready) == 0 && (err != nil || n == 0) {
+			if err == nil {
+				err = io.EOF
+			}
+			return 0, err
 		}
 	}
+
+	n := copy(p, h.ready)
+	h.ready = h.ready[n:]
+	return n, nil
 }
 
-// readLastLines reads the last n lines from a file
-func readLastLines(file *os.File, n int) ([]string, error) {
+// rest returns the held back partial line, for when the log won't grow again
+func (h *heldLineReader) rest() string {
+	line := string(h.pending)
+	h.pending = nil
+	return line
+}
+
+// readLastLines reads the last n lines from a line reader, keeping only n
+// lines in memory however long the input is
+func readLastLines(scanner *worker.LineReader, n int) ([]string, error) {
 	if n <= 0 {
 		return []string{}, nil
 	}
 
-	// Simple approach: read entire file and get last n lines
-	// For very large files, this could be optimized, but it's sufficient for log files
-	scanner := bufio.NewScanner(file)
-	var allLines []string
-	
+	// Keep the last n lines in a ring, oldest at next once it's full
+	ring := make([]string, 0, n)
+	next := 0
 	for scanner.Scan() {
-		allLines = append(allLines, scanner.Text())
+		if len(ring) < n {
+			ring = append(ring, scanner.Text())
+			continue
+		}
+		ring[next] = scanner.Text()
+		next = (next + 1) % n
 	}
-	
+
 	if err := scanner.Err(); err != nil {
 		return nil, err
 	}
-	
-	// Return empty slice for empty file
-	if len(allLines) == 0 {
-		return []string{}, nil
+
+	return append(ring[next:], ring[:next]...), nil
+}
+
+// tailOffset returns the offset at which the last n lines of r start, reading
+// backwards from the end in blocks so the time taken depends on the length of
+// those lines rather than the size of the log. A final line without a newline
+// counts as a line.
+func tailOffset(r io.ReadSeeker, n int) (int64, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
 	}
-	
-	// Return last n lines
-	if len(allLines) <= n {
-		return allLines, nil
+
+	buf := make([]byte, 64*1024)
+	found := 0
+	for end := size; end > 0; {
+		start := end - int64(len(buf))
+		if start < 0 {
+			start = 0
+		}
+		block := buf[:end-start]
+		if _, err := r.Seek(start, io.SeekStart); err != nil {
+			return 0, err
+		}
+		if _, err := io.ReadFull(r, block); err != nil {
+			return 0, err
+		}
+
+		for i := len(block) - 1; i >= 0; i-- {
+			// Every newline but the log's last starts another line
+			offset := start + int64(i)
+			if block[i] != '\n' || offset == size-1 {
+				continue
+			}
+			if found++; found == n {
+				return offset + 1, nil
+			}
+		}
+		end = start
 	}
-	
-	return allLines[len(allLines)-n:], nil
+	return 0, nil
 }
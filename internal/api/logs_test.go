@@ -1,18 +1,21 @@
 package api
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	errormw "github.com/brettsmith212/amp-orchestrator-2/internal/middleware"
 	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
 )
 
@@ -56,7 +59,7 @@ func TestLogHandler_GetTaskLogs(t *testing.T) {
 		}))
 		
 		w := httptest.NewRecorder()
-		handler.GetTaskLogs(w, req)
+		errormw.Error(handler.GetTaskLogs)(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
@@ -74,7 +77,7 @@ func TestLogHandler_GetTaskLogs(t *testing.T) {
 		}))
 		
 		w := httptest.NewRecorder()
-		handler.GetTaskLogs(w, req)
+		errormw.Error(handler.GetTaskLogs)(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		expected := "Line 4\nLine 5\n"
@@ -92,7 +95,7 @@ func TestLogHandler_GetTaskLogs(t *testing.T) {
 		}))
 		
 		w := httptest.NewRecorder()
-		handler.GetTaskLogs(w, req)
+		errormw.Error(handler.GetTaskLogs)(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Equal(t, logContent, w.Body.String())
@@ -109,7 +112,7 @@ func TestLogHandler_GetTaskLogs(t *testing.T) {
 		}))
 		
 		w := httptest.NewRecorder()
-		handler.GetTaskLogs(w, req)
+		errormw.Error(handler.GetTaskLogs)(w, req)
 
 		assert.Equal(t, http.StatusNotFound, w.Code)
 		assert.Contains(t, w.Body.String(), "Task not found")
@@ -126,7 +129,7 @@ func TestLogHandler_GetTaskLogs(t *testing.T) {
 		}))
 		
 		w := httptest.NewRecorder()
-		handler.GetTaskLogs(w, req)
+		errormw.Error(handler.GetTaskLogs)(w, req)
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
 		assert.Contains(t, w.Body.String(), "Invalid tail parameter")
@@ -143,7 +146,7 @@ func TestLogHandler_GetTaskLogs(t *testing.T) {
 		}))
 		
 		w := httptest.NewRecorder()
-		handler.GetTaskLogs(w, req)
+		errormw.Error(handler.GetTaskLogs)(w, req)
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
 		assert.Contains(t, w.Body.String(), "Invalid tail parameter")
@@ -186,12 +189,300 @@ func TestLogHandler_EmptyLogFile(t *testing.T) {
 	}))
 	
 	w := httptest.NewRecorder()
-	handler.GetTaskLogs(w, req)
+	errormw.Error(handler.GetTaskLogs)(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, "", w.Body.String())
 }
 
+func TestLogHandler_GetTaskLogs_LongLine(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := worker.NewManager(tmpDir)
+	manager.SetMaxLineSize(1024)
+	handler := NewLogHandler(manager)
+
+	workerID := "test-worker-long"
+	logFile := filepath.Join(tmpDir, fmt.Sprintf("worker-%s.log", workerID))
+
+	// Longer than bufio.Scanner's 64KB default token size
+	longLine := strings.Repeat("x", 100*1024)
+	require.NoError(t, os.WriteFile(logFile, []byte("before\n"+longLine+"\nafter\n"), 0644))
+
+	workers := map[string]*worker.Worker{workerID: {
+		ID:      workerID,
+		LogFile: logFile,
+		Started: time.Now(),
+		Status:  "stopped",
+	}}
+	require.NoError(t, manager.SaveWorkersForTest(workers, filepath.Join(tmpDir, "workers.json")))
+
+	req := httptest.NewRequest("GET", "/api/tasks/"+workerID+"/logs", nil)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, &chi.Context{
+		URLParams: chi.RouteParams{
+			Keys:   []string{"id"},
+			Values: []string{workerID},
+		},
+	}))
+
+	w := httptest.NewRecorder()
+	errormw.Error(handler.GetTaskLogs)(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	expected := "before\n" + longLine[:1024] + worker.TruncationMarker + "\nafter\n"
+	assert.Equal(t, expected, w.Body.String())
+	assert.Equal(t, uint64(1), manager.LogStats().TruncatedLines)
+}
+
+func TestLogHandler_GetTaskLogs_Follow(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := worker.NewManager(tmpDir)
+	handler := NewLogHandler(manager)
+	handler.followInterval = 10 * time.Millisecond
+	handler.heartbeatInterval = 100 * time.Millisecond
+
+	workerID := "test-worker-follow"
+	logFile := filepath.Join(tmpDir, fmt.Sprintf("worker-%s.log", workerID))
+	require.NoError(t, os.WriteFile(logFile, []byte("Line 1\nLine 2\npart"), 0644))
+
+	require.NoError(t, manager.SaveWorkersForTest(map[string]*worker.Worker{workerID: {
+		ID:      workerID,
+		PID:     os.Getpid(),
+		LogFile: logFile,
+		Started: time.Now(),
+		Status:  worker.StatusRunning,
+	}}, filepath.Join(tmpDir, "workers.json")))
+
+	router := chi.NewRouter()
+	router.Get("/api/tasks/{id}/logs", errormw.Error(handler.GetTaskLogs))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/tasks/" + workerID + "/logs?follow=true&tail=1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body := bufio.NewReader(resp.Body)
+	readLine := func() string {
+		line, err := body.ReadString('\n')
+		require.NoError(t, err)
+		return strings.TrimSuffix(line, "\n")
+	}
+
+	// The partial line is held back until it's complete
+	assert.Equal(t, "Line 2", readLine())
+
+	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString("ial\nLine 4\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	assert.Equal(t, "partial", readLine())
+	assert.Equal(t, "Line 4", readLine())
+	assert.Equal(t, ": heartbeat", readLine())
+}
+
+func TestLogHandler_GetTaskLogs_FollowStopped(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := worker.NewManager(tmpDir)
+	handler := NewLogHandler(manager)
+	handler.followInterval = 10 * time.Millisecond
+
+	workerID := "test-worker-follow"
+	logFile := filepath.Join(tmpDir, fmt.Sprintf("worker-%s.log", workerID))
+	require.NoError(t, os.WriteFile(logFile, []byte("Line 1\nLine 2"), 0644))
+	require.NoError(t, manager.SaveWorkersForTest(map[string]*worker.Worker{workerID: {
+		ID:      workerID,
+		LogFile: logFile,
+		Started: time.Now(),
+		Status:  worker.StatusStopped,
+	}}, filepath.Join(tmpDir, "workers.json")))
+
+	req := httptest.NewRequest("GET", "/api/tasks/"+workerID+"/logs?follow=true", nil)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, &chi.Context{
+		URLParams: chi.RouteParams{
+			Keys:   []string{"id"},
+			Values: []string{workerID},
+		},
+	}))
+
+	// Following a task that has stopped ends once its whole log is sent
+	w := httptest.NewRecorder()
+	errormw.Error(handler.GetTaskLogs)(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Line 1\nLine 2\n", w.Body.String())
+}
+
+func TestLogHandler_GetTaskLogs_FollowDisconnect(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := worker.NewManager(tmpDir)
+	handler := NewLogHandler(manager)
+	handler.followInterval = 10 * time.Millisecond
+
+	workerID := "test-worker-follow"
+	logFile := filepath.Join(tmpDir, fmt.Sprintf("worker-%s.log", workerID))
+	require.NoError(t, os.WriteFile(logFile, []byte("Line 1\n"), 0644))
+	require.NoError(t, manager.SaveWorkersForTest(map[string]*worker.Worker{workerID: {
+		ID:      workerID,
+		PID:     os.Getpid(),
+		LogFile: logFile,
+		Started: time.Now(),
+		Status:  worker.StatusRunning,
+	}}, filepath.Join(tmpDir, "workers.json")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/api/tasks/"+workerID+"/logs?follow=true", nil)
+	req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, &chi.Context{
+		URLParams: chi.RouteParams{
+			Keys:   []string{"id"},
+			Values: []string{workerID},
+		},
+	}))
+
+	done := make(chan struct{})
+	w := httptest.NewRecorder()
+	go func() {
+		errormw.Error(handler.GetTaskLogs)(w, req)
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("following the log did not stop when the client disconnected")
+	}
+	assert.Equal(t, "Line 1\n", w.Body.String())
+
+	req = httptest.NewRequest("GET", "/api/tasks/"+workerID+"/logs?follow=maybe", nil)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, &chi.Context{
+		URLParams: chi.RouteParams{
+			Keys:   []string{"id"},
+			Values: []string{workerID},
+		},
+	}))
+	w = httptest.NewRecorder()
+	errormw.Error(handler.GetTaskLogs)(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestLogHandler_GetTaskLogs_ByteRange(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := worker.NewManager(tmpDir)
+	handler := NewLogHandler(manager)
+
+	workerID := "test-worker-range"
+	logFile := filepath.Join(tmpDir, fmt.Sprintf("worker-%s.log", workerID))
+	require.NoError(t, os.WriteFile(logFile, []byte("Line 1\nLine 2\npart"), 0644))
+	require.NoError(t, manager.SaveWorkersForTest(map[string]*worker.Worker{workerID: {
+		ID:      workerID,
+		LogFile: logFile,
+		Started: time.Now(),
+		Status:  worker.StatusStopped,
+	}}, filepath.Join(tmpDir, "workers.json")))
+
+	get := func(query, rangeHeader string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/tasks/"+workerID+"/logs"+query, nil)
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, &chi.Context{
+			URLParams: chi.RouteParams{
+				Keys:   []string{"id"},
+				Values: []string{workerID},
+			},
+		}))
+		w := httptest.NewRecorder()
+		errormw.Error(handler.GetTaskLogs)(w, req)
+		return w
+	}
+
+	t.Run("offset", func(t *testing.T) {
+		// Raw bytes are returned, including a partial last line
+		w := get("?offset=7", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Line 2\npart", w.Body.String())
+		assert.Equal(t, "18", w.Header().Get("X-Log-Size"))
+
+		// Polling at the end of the log returns nothing new
+		w = get("?offset=18", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Body.String())
+
+		assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, get("?offset=19", "").Code)
+		assert.Equal(t, http.StatusBadRequest, get("?offset=-1", "").Code)
+	})
+
+	t.Run("range header", func(t *testing.T) {
+		w := get("", "bytes=0-5")
+		assert.Equal(t, http.StatusPartialContent, w.Code)
+		assert.Equal(t, "Line 1", w.Body.String())
+		assert.Equal(t, "bytes 0-5/18", w.Header().Get("Content-Range"))
+		assert.Equal(t, "18", w.Header().Get("X-Log-Size"))
+
+		w = get("", "bytes=14-")
+		assert.Equal(t, http.StatusPartialContent, w.Code)
+		assert.Equal(t, "part", w.Body.String())
+
+		w = get("", "bytes=-4")
+		assert.Equal(t, "part", w.Body.String())
+		assert.Equal(t, "bytes 14-17/18", w.Header().Get("Content-Range"))
+
+		w = get("", "bytes=18-")
+		assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+		assert.Equal(t, "bytes */18", w.Header().Get("Content-Range"))
+	})
+
+	t.Run("invalid combinations", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, get("", "bytes=0-1,4-5").Code)
+		assert.Equal(t, http.StatusBadRequest, get("", "bytes=5-1").Code)
+		assert.Equal(t, http.StatusBadRequest, get("", "lines=1-2").Code)
+		assert.Equal(t, http.StatusBadRequest, get("?offset=0", "bytes=0-").Code)
+		assert.Equal(t, http.StatusBadRequest, get("?offset=0&tail=1", "").Code)
+		assert.Equal(t, http.StatusBadRequest, get("?offset=0&follow=true", "").Code)
+	})
+}
+
+func TestLogHandler_GetTaskLogs_ANSI(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := worker.NewManager(tmpDir)
+	handler := NewLogHandler(manager)
+
+	workerID := "test-worker-ansi"
+	logFile := filepath.Join(tmpDir, fmt.Sprintf("worker-%s.log", workerID))
+	logContent := "\x1b[1;32mdone\x1b[0m\nstep 1\rstep 2\n"
+	require.NoError(t, os.WriteFile(logFile, []byte(logContent), 0644))
+	require.NoError(t, manager.SaveWorkersForTest(map[string]*worker.Worker{workerID: {
+		ID:      workerID,
+		LogFile: logFile,
+		Started: time.Now(),
+		Status:  worker.StatusStopped,
+	}}, filepath.Join(tmpDir, "workers.json")))
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/tasks/"+workerID+"/logs"+query, nil)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, &chi.Context{
+			URLParams: chi.RouteParams{
+				Keys:   []string{"id"},
+				Values: []string{workerID},
+			},
+		}))
+		w := httptest.NewRecorder()
+		errormw.Error(handler.GetTaskLogs)(w, req)
+		return w
+	}
+
+	assert.Equal(t, "done\nstep 1step 2\n", get("?ansi=strip").Body.String())
+	assert.Equal(t, "step 1step 2\n", get("?ansi=strip&tail=1").Body.String())
+	assert.Equal(t, logContent, get("?ansi=keep").Body.String())
+	assert.Equal(t, logContent, get("").Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, get("?ansi=html").Code)
+	assert.Equal(t, http.StatusBadRequest, get("?ansi=strip&offset=0").Code)
+}
+
 func TestReadLastLines(t *testing.T) {
 	tmpDir := t.TempDir()
 	testFile := filepath.Join(tmpDir, "test.log")
@@ -249,10 +540,47 @@ func TestReadLastLines(t *testing.T) {
 			require.NoError(t, err)
 			defer file.Close()
 
-			lines, err := readLastLines(file, tt.n)
+			lines, err := readLastLines(worker.NewLineReader(file, nil), tt.n)
 			require.NoError(t, err)
 
 			assert.Equal(t, tt.expected, lines)
 		})
 	}
 }
+
+func TestTailOffset(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		n        int
+		expected string // The content from the returned offset
+	}{
+		{"last lines", "line1\nline2\nline3\n", 2, "line2\nline3\n"},
+		{"no final newline", "line1\nline2\nline3", 2, "line2\nline3"},
+		{"more lines than available", "line1\nline2\n", 5, "line1\nline2\n"},
+		{"empty lines", "line1\n\n\n", 2, "\n\n"},
+		{"empty file", "", 3, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offset, err := tailOffset(strings.NewReader(tt.content), tt.n)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, tt.content[offset:])
+		})
+	}
+
+	t.Run("lines spanning blocks", func(t *testing.T) {
+		var b strings.Builder
+		for i := 0; i < 5000; i++ {
+			fmt.Fprintf(&b, "line %d %s\n", i, strings.Repeat("x", i%100))
+		}
+		content := b.String()
+
+		offset, err := tailOffset(strings.NewReader(content), 3000)
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSuffix(content[offset:], "\n"), "\n")
+		require.Len(t, lines, 3000)
+		assert.True(t, strings.HasPrefix(lines[0], "line 2000 "))
+	})
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+)
+
+// Limits on log searches
+const (
+	defaultLogMatches = 100
+	maxLogMatches     = 1000
+	maxLogContext     = 10
+)
+
+// SearchTaskLogs returns the lines of a task's log containing ?q=, or matching
+// it as a regular expression with ?regex=true, each with ?context= lines
+// around it. The log is scanned on the server so clients don't have to
+// download all of it to find a few lines.
+func (h *LogHandler) SearchTaskLogs(w http.ResponseWriter, r *http.Request) error {
+	taskID := chi.URLParam(r, "id")
+	values := r.URL.Query()
+
+	q := values.Get("q")
+	if q == "" {
+		return apierr.BadRequest("Search query (q) is required")
+	}
+
+	match := func(line string) bool { return strings.Contains(line, q) }
+	if regexParam := values.Get("regex"); regexParam != "" {
+		useRegex, err := strconv.ParseBool(regexParam)
+		if err != nil {
+			return apierr.BadRequest("Invalid regex parameter")
+		}
+		if useRegex {
+			re, err := regexp.Compile(q)
+			if err != nil {
+				return apierr.BadRequestf("Invalid regular expression: %v", err)
+			}
+			match = re.MatchString
+		}
+	}
+
+	contextLines := 0
+	if contextParam := values.Get("context"); contextParam != "" {
+		var err error
+		contextLines, err = strconv.Atoi(contextParam)
+		if err != nil || contextLines < 0 || contextLines > maxLogContext {
+			return apierr.BadRequestf("Context must be between 0 and %d", maxLogContext)
+		}
+	}
+
+	limit := defaultLogMatches
+	if limitParam := values.Get("limit"); limitParam != "" {
+		var err error
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > maxLogMatches {
+			return apierr.BadRequestf("Limit must be between 1 and %d", maxLogMatches)
+		}
+	}
+
+	clean, err := parseANSI(r)
+	if err != nil {
+		return err
+	}
+
+	file, err := h.manager.OpenLog(taskID)
+	if errors.Is(err, worker.ErrLogNotFound) {
+		return apierr.NotFound("Log file not found")
+	}
+	if err != nil {
+		return taskError(err, "open log file")
+	}
+	defer file.Close()
+
+	result := LogSearchResponse{Matches: []LogMatchDTO{}}
+	var recent []string // The last lines read, for the next match's Before
+	var open []int      // Matches still collecting their After lines
+	scanner := worker.NewLineReader(file, h.manager.LineLimit())
+	for scanner.Scan() {
+		result.LinesScanned++
+		line := clean(scanner.Text())
+
+		still := open[:0]
+		for _, i := range open {
+			m := &result.Matches[i]
+			m.After = append(m.After, line)
+			if len(m.After) < contextLines {
+				still = append(still, i)
+			}
+		}
+		open = still
+
+		if match(line) {
+			if len(result.Matches) == limit {
+				result.HasMore = true
+			} else {
+				result.Matches = append(result.Matches, LogMatchDTO{
+					Line:    result.LinesScanned,
+					Content: line,
+					Before:  append([]string(nil), recent...),
+				})
+				if contextLines > 0 {
+					open = append(open, len(result.Matches)-1)
+				}
+			}
+		}
+
+		// Once over the limit, read only as far as the last matches' context
+		if result.HasMore && len(open) == 0 {
+			break
+		}
+
+		if contextLines > 0 {
+			if len(recent) == contextLines {
+				recent = recent[1:]
+			}
+			recent = append(recent, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return apierr.WrapInternal(err, "Failed to read log file")
+	}
+
+	return response.OK(w, result)
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	errormw "github.com/brettsmith212/amp-orchestrator-2/internal/middleware"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+func TestSearchTaskLogs(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := worker.NewManager(tmpDir)
+	handler := NewLogHandler(manager)
+
+	logFile := filepath.Join(tmpDir, "worker-search.log")
+	lines := []string{"start", "build ok", "Error: missing file", "retrying", "error: timeout 30s", "done"}
+	require.NoError(t, os.WriteFile(logFile, []byte(strings.Join(lines, "\n")+"\n"), 0644))
+	require.NoError(t, manager.SaveWorkersForTest(map[string]*worker.Worker{"search": {
+		ID:      "search",
+		LogFile: logFile,
+		Started: time.Now(),
+		Status:  worker.StatusStopped,
+	}}, filepath.Join(tmpDir, "workers.json")))
+
+	r := chi.NewRouter()
+	r.Get("/api/tasks/{id}/logs/search", errormw.Error(handler.SearchTaskLogs))
+	search := func(query string) (int, LogSearchResponse) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/api/tasks/search/logs/search?"+query, nil))
+		var resp LogSearchResponse
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		}
+		return w.Code, resp
+	}
+
+	t.Run("substring", func(t *testing.T) {
+		code, resp := search("q=error")
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, resp.Matches, 1)
+		assert.Equal(t, 5, resp.Matches[0].Line)
+		assert.Equal(t, "error: timeout 30s", resp.Matches[0].Content)
+		assert.Nil(t, resp.Matches[0].Before)
+		assert.False(t, resp.HasMore)
+		assert.Equal(t, 6, resp.LinesScanned)
+	})
+
+	t.Run("regex with context", func(t *testing.T) {
+		code, resp := search("q=(?i)^error&regex=true&context=1")
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, resp.Matches, 2)
+		assert.Equal(t, LogMatchDTO{Line: 3, Content: "Error: missing file", Before: []string{"build ok"}, After: []string{"retrying"}}, resp.Matches[0])
+		assert.Equal(t, LogMatchDTO{Line: 5, Content: "error: timeout 30s", Before: []string{"retrying"}, After: []string{"done"}}, resp.Matches[1])
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		code, resp := search("q=(?i)error&regex=true&limit=1&context=1")
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, resp.Matches, 1)
+		assert.Equal(t, 3, resp.Matches[0].Line)
+		assert.Equal(t, []string{"retrying"}, resp.Matches[0].After)
+		assert.True(t, resp.HasMore)
+		assert.Equal(t, 5, resp.LinesScanned)
+	})
+
+	t.Run("no matches", func(t *testing.T) {
+		code, resp := search("q=panic")
+		require.Equal(t, http.StatusOK, code)
+		assert.Empty(t, resp.Matches)
+		assert.NotNil(t, resp.Matches)
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		for _, query := range []string{"", "q=(&regex=true", "q=x&regex=maybe", "q=x&context=11", "q=x&limit=0", "q=x&ansi=html"} {
+			code, _ := search(query)
+			assert.Equal(t, http.StatusBadRequest, code, query)
+		}
+	})
+
+	t.Run("unknown task", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/api/tasks/missing/logs/search?q=x", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+// Each handler depends on the small part of worker.Manager it uses rather than
+// on *worker.Manager, so its tests can run against workertest.Manager, which
+// keeps workers in memory and never starts amp.
+
+// WorkerLister lists the daemon's workers
+type WorkerLister interface {
+	ListWorkers() ([]*worker.Worker, error)
+}
+
+// TaskManager is the part of worker.Manager TaskHandler uses
+type TaskManager interface {
+	WorkerLister
+
+	// Task lifecycle
+	StartWorkerWithOptions(ctx context.Context, message string, opts worker.StartOptions) (*worker.Worker, error)
+	QueueContinue(workerID, message string) (int, error)
+	RetryWorker(ctx context.Context, workerID, message string) error
+	StopWorker(workerID string) error
+	InterruptWorker(workerID string) error
+	AbortWorker(workerID string) error
+	DeleteWorker(workerID string, force bool) error
+	StopWorkerTree(workerID string) ([]string, error)
+	AbortWorkerTree(workerID string) ([]string, error)
+	DeleteWorkerTree(workerID string, force bool) ([]string, error)
+	ListTrash() ([]*worker.Worker, error)
+	RestoreWorker(workerID string) ([]*worker.Worker, error)
+	TransitionWorker(ctx context.Context, workerID string, t worker.Transition) (*worker.Worker, error)
+	WindDownWorker(workerID string, opts worker.WindDownOptions) (<-chan worker.WindDownResult, error)
+
+	// Task state and metadata
+	Snapshot() (*worker.Snapshot, error)
+	UpdateWorkerMetadata(workerID string, title, description, priority *string, tags []string) error
+	Annotate(workerID string, annotation worker.Annotation) (*worker.Annotation, error)
+	Attempts(workerID string) ([]worker.Attempt, error)
+	Timeline(workerID string) ([]worker.StatusChange, error)
+	QueuedMessages(workerID string) ([]worker.QueuedMessage, error)
+
+	// Threads
+	GetThreadMessages(workerID string, limit, offset int) ([]worker.ThreadMessage, error)
+	GetThreadPage(workerID string, cursor *worker.ThreadCursor, limit int, desc bool) ([]worker.ThreadMessage, bool, error)
+	CountThreadMessages(workerID string) (int, error)
+	BackfillThreads(taskIDs []string, dryRun bool, progress func(done, total int, result worker.BackfillResult)) (*worker.BackfillReport, error)
+	RebuildThread(workerID string) (*worker.ThreadRebuild, error)
+	RemoveThreadMessage(workerID, messageID string) error
+	RedactThreadMessage(workerID, messageID string) (*worker.ThreadMessage, error)
+
+	// Artifacts, workspaces and integrations
+	ListArtifacts(workerID string) ([]worker.Artifact, error)
+	OpenArtifact(workerID, name string) (*os.File, *worker.Artifact, error)
+	Workspace(workerID string) (worker.Workspace, error)
+	LinkPullRequest(workerID, repo string, number int, url string) (*worker.PullRequestLink, error)
+	UpdateCheck(repo string, number int, check string, status worker.CIStatus) ([]string, error)
+	SyncIssue(provider, key string, fields worker.IssueFields) ([]string, error)
+
+	// Amp and execution backends
+	AmpVersion() (worker.AmpVersion, bool)
+	Backends() []worker.BackendInfo
+	Pools() ([]worker.PoolInfo, error)
+}
+
+// LogManager is the part of worker.Manager LogHandler uses
+type LogManager interface {
+	WorkerLister
+	IsRunning(workerID string) (bool, error)
+	OpenLog(workerID string) (io.ReadCloser, error)
+	OpenAmpLog(workerID string) (io.ReadCloser, error)
+	GetThreadMessages(workerID string, limit, offset int) ([]worker.ThreadMessage, error)
+	LineLimit() *worker.LineLimit
+}
+
+// ProjectManager is the part of worker.Manager ProjectHandler uses
+type ProjectManager interface {
+	ListProjects() ([]*worker.Project, error)
+	GetProject(name string) (*worker.Project, error)
+	CreateProject(project worker.Project) (*worker.Project, error)
+	UpdateProject(name string, update worker.ProjectUpdate) (*worker.Project, error)
+	DeleteProject(name string) error
+}
+
+// HealthManager is the part of worker.Manager the readiness, metrics and
+// system handlers use
+type HealthManager interface {
+	ReadinessChecks() []worker.ReadinessCheck
+	RateLimitStats() []worker.RateLimitStats
+	LogStats() worker.LogStats
+	StorageUsage() (worker.StorageUsage, error)
+	AmpStatus() worker.AmpStatus
+	CountWorkers() (worker.WorkerCounts, error)
+}
+
+// WorkerManager is everything the router hands out to its handlers
+type WorkerManager interface {
+	TaskManager
+	LogManager
+	ProjectManager
+	HealthManager
+}
+
+var _ WorkerManager = (*worker.Manager)(nil)
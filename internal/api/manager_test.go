@@ -0,0 +1,180 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub/hubtest"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker/workertest"
+)
+
+var _ WorkerManager = (*workertest.Manager)(nil)
+
+// setupFakeRouter serves the API over a fake manager and a running hub
+func setupFakeRouter(t *testing.T) (http.Handler, *workertest.Manager, *hubtest.Client) {
+	manager := workertest.New()
+	h := hubtest.New(t)
+	client := h.Connect()
+	return NewRouter(NewTaskHandler(manager, h.Hub), h.Hub), manager, client
+}
+
+func serve(router http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+func TestFakeManager_TaskLifecycle(t *testing.T) {
+	router, manager, client := setupFakeRouter(t)
+
+	w := serve(router, "POST", "/api/tasks", `{"message":"fix the build"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var task TaskDTO
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &task))
+	assert.Equal(t, "task-1", task.ID)
+	assert.Equal(t, "running", task.Status)
+	client.ExpectEvent(hub.MessageTypeTaskCreated, 0)
+
+	w = serve(router, "POST", "/api/tasks/task-1/stop", "")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, worker.StatusStopped, manager.Worker("task-1").Status)
+
+	// Only running tasks can be stopped or continued
+	w = serve(router, "POST", "/api/tasks/task-1/stop", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = serve(router, "POST", "/api/tasks/task-1/continue", `{"message":"again"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = serve(router, "POST", "/api/tasks/task-1/retry", `{"message":"try again"}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, worker.StatusRunning, manager.Worker("task-1").Status)
+
+	w = serve(router, "GET", "/api/tasks/task-1/thread", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "fix the build")
+	assert.Contains(t, w.Body.String(), "try again")
+
+	// Running tasks are only deleted with force
+	w = serve(router, "DELETE", "/api/tasks/task-1", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"task_running"`)
+	w = serve(router, "DELETE", "/api/tasks/task-1?force=true", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Nil(t, manager.Worker("task-1"))
+
+	w = serve(router, "POST", "/api/tasks/task-1/stop", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestFakeManager_StartWithMetadata(t *testing.T) {
+	router, manager, client := setupFakeRouter(t)
+
+	w := serve(router, "POST", "/api/tasks", `{"message":"fix the build","title":"Fix build","description":"CI is red","tags":["ci"],"priority":"high"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var task TaskDTO
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &task))
+	assert.Equal(t, "Fix build", task.Title)
+	assert.Equal(t, "CI is red", task.Description)
+	assert.Equal(t, []string{"ci"}, task.Tags)
+	assert.Equal(t, "high", task.Priority)
+	assert.Equal(t, "Fix build", manager.Worker(task.ID).Title)
+
+	// The creation event already carries the metadata
+	msg := client.ExpectEvent(hub.MessageTypeTaskCreated, 0)
+	assert.Contains(t, string(msg.Data), `"title":"Fix build"`)
+}
+
+func TestFakeManager_ListTasks(t *testing.T) {
+	router, manager, _ := setupFakeRouter(t)
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	manager.Add(
+		&worker.Worker{ID: "a", Status: worker.StatusRunning, Started: base},
+		&worker.Worker{ID: "b", Status: worker.StatusCompleted, Started: base.Add(time.Minute), ParentID: "a"},
+		&worker.Worker{ID: "c", Status: worker.StatusFailed, Started: base.Add(2 * time.Minute)},
+	)
+
+	w := serve(router, "GET", "/api/tasks?status=running,failed", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp PaginatedTasksResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Tasks, 2)
+	assert.Equal(t, "c", resp.Tasks[0].ID)
+	assert.Equal(t, "a", resp.Tasks[1].ID)
+}
+
+func TestFakeManager_Failures(t *testing.T) {
+	router, manager, _ := setupFakeRouter(t)
+
+	manager.Fail("StartWorkerWithOptions", worker.ErrRateLimited)
+	w := serve(router, "POST", "/api/tasks", `{"message":"hi"}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"rate_limited"`)
+
+	// Errors are matched by kind, not by their wording
+	manager.Fail("StartWorkerWithOptions", errors.New(`failed to create thread: exec: "amp": executable file not found in $PATH`))
+	w = serve(router, "POST", "/api/tasks", `{"message":"hi"}`)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	manager.Fail("StartWorkerWithOptions", nil)
+	w = serve(router, "POST", "/api/tasks", `{"message":"hi"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = serve(router, "POST", "/api/tasks", `{"message":"hi","parent_id":"missing"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Parent task not found")
+
+	w = serve(router, "POST", "/api/tasks/missing/continue", `{"message":"hi"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "Task not found")
+}
+
+func TestFakeManager_Backends(t *testing.T) {
+	router, _, _ := setupFakeRouter(t)
+
+	w := serve(router, "GET", "/api/meta/backends", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"backends":[{"name":"amp","models":[]}]}`, w.Body.String())
+
+	w = serve(router, "POST", "/api/tasks", `{"message":"hi","backend":"aider"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serve(router, "POST", "/api/tasks", `{"message":"hi","model":"gpt-4o"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(router, "POST", "/api/tasks", `{"message":"hi","backend":"amp"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var task TaskDTO
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &task))
+	assert.Equal(t, "amp", task.Backend)
+	assert.Empty(t, task.Model)
+}
+
+func TestFakeManager_Pools(t *testing.T) {
+	router, manager, _ := setupFakeRouter(t)
+
+	w := serve(router, "GET", "/api/meta/pools", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"pools":[]}`, w.Body.String())
+
+	w = serve(router, "POST", "/api/tasks", `{"message":"hi","pool":"gpu"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	manager.Fail("StartWorkerWithOptions", worker.ErrPoolFull)
+	w = serve(router, "POST", "/api/tasks", `{"message":"hi"}`)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"pool_full"`)
+
+	manager.Fail("StartWorkerWithOptions", worker.ErrTooManyWorkers)
+	w = serve(router, "POST", "/api/tasks", `{"message":"hi"}`)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"max_workers_reached"`)
+}
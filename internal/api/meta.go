@@ -0,0 +1,359 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/version"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/schema"
+)
+
+// EventDirection says which side of the WebSocket sends an event
+type EventDirection string
+
+const (
+	EventFromServer EventDirection = "server"
+	EventFromClient EventDirection = "client"
+)
+
+// eventDefinition describes one WebSocket event type. Envelope is the Go type
+// the event is encoded as; Data, when set, is the type carried in the
+// envelope's "data" field if the envelope doesn't declare it.
+type eventDefinition struct {
+	Type        string
+	Version     int
+	Direction   EventDirection
+	Description string
+	Sequenced   bool // Carries a "seq" field and can be replayed on reconnect
+	Envelope    interface{}
+	Data        interface{}
+}
+
+// eventCatalog lists every event exchanged over the WebSocket. Bump an event's
+// version whenever its payload changes incompatibly.
+var eventCatalog = []eventDefinition{
+	{
+		Type:        "task-update",
+		Version:     1,
+		Direction:   EventFromServer,
+		Description: "A task was created or its status or metadata changed",
+		Sequenced:   true,
+		Envelope:    TaskUpdateEvent{},
+	},
+	{
+		Type:        "task-created",
+		Version:     1,
+		Direction:   EventFromServer,
+		Description: "A task was started; followed by a task-update with the same state",
+		Sequenced:   true,
+		Envelope:    TaskCreatedEvent{},
+	},
+	{
+		Type:        "task-deleted",
+		Version:     1,
+		Direction:   EventFromServer,
+		Description: "A task was deleted; carries its state at deletion. Cascading deletes send one event per task",
+		Sequenced:   true,
+		Envelope:    TaskDeletedEvent{},
+	},
+	{
+		Type:        "log",
+		Version:     1,
+		Direction:   EventFromServer,
+		Description: "A line was written to a task's log",
+		Sequenced:   true,
+		Envelope:    LogEvent{},
+	},
+	{
+		Type:        "thread_message",
+		Version:     1,
+		Direction:   EventFromServer,
+		Description: "A message was added to a task's amp thread",
+		Sequenced:   true,
+		Envelope:    ThreadMessageEvent{},
+	},
+	{
+		Type:        "thread_message_removed",
+		Version:     1,
+		Direction:   EventFromServer,
+		Description: "A message was removed from a task's thread, or redacted to a blank record",
+		Sequenced:   true,
+		Envelope:    ThreadMessageRemovedEvent{},
+	},
+	{
+		Type:        "continue-finished",
+		Version:     1,
+		Direction:   EventFromServer,
+		Description: "amp answered a message sent to a task",
+		Sequenced:   true,
+		Envelope:    ContinueFinishedEvent{},
+	},
+	{
+		Type:        "task-stalled",
+		Version:     1,
+		Direction:   EventFromServer,
+		Description: "A running task stopped producing output",
+		Sequenced:   true,
+		Envelope:    TaskStalledEvent{},
+	},
+	{
+		Type:        "task-stats",
+		Version:     1,
+		Direction:   EventFromServer,
+		Description: "A new CPU and memory sample of a running task; not replayed or recorded in history",
+		Envelope:    TaskStatsEvent{},
+	},
+	{
+		Type:        "system",
+		Version:     1,
+		Direction:   EventFromServer,
+		Description: "A daemon-wide condition not tied to a single task",
+		Sequenced:   true,
+		Envelope:    SystemEvent{},
+	},
+	{
+		Type:        string(hub.MessageTypeHeartbeat),
+		Version:     1,
+		Direction:   EventFromServer,
+		Description: "Periodic keepalive sent to every client",
+		Envelope:    hub.WebSocketMessage{},
+		Data:        hub.HeartbeatMessage{},
+	},
+	{
+		Type:        string(hub.MessageTypePong),
+		Version:     1,
+		Direction:   EventFromServer,
+		Description: "Reply to a client ping",
+		Envelope:    hub.WebSocketMessage{},
+		Data:        hub.PongMessage{},
+	},
+	{
+		Type:        string(hub.MessageTypeResyncRequired),
+		Version:     1,
+		Direction:   EventFromServer,
+		Description: "Events after the requested sequence number are no longer available; reload state over the REST API",
+		Envelope:    hub.WebSocketMessage{},
+		Data:        hub.ResyncMessage{},
+	},
+	{
+		Type:        string(hub.MessageTypeAuthOK),
+		Version:     1,
+		Direction:   EventFromServer,
+		Description: "Confirms a successful auth handshake and reports the authenticated user",
+		Envelope:    hub.WebSocketMessage{},
+		Data:        hub.Identity{},
+	},
+	{
+		Type:        string(hub.MessageTypeCommandResult),
+		Version:     1,
+		Direction:   EventFromServer,
+		Description: "Result of a command, carrying the command's ID and the status and body its REST endpoint responded with",
+		Envelope:    hub.WebSocketMessage{},
+		Data:        hub.CommandResultMessage{},
+	},
+	{
+		Type:        string(hub.MessageTypeAuth),
+		Version:     1,
+		Direction:   EventFromClient,
+		Description: "Authenticates the connection; must be the first message when auth is enabled and no token was sent with the upgrade request",
+		Envelope:    hub.WebSocketMessage{},
+		Data:        hub.AuthMessage{},
+	},
+	{
+		Type:        string(hub.MessageTypePing),
+		Version:     1,
+		Direction:   EventFromClient,
+		Description: "Application-level ping; the server replies with pong",
+		Envelope:    hub.WebSocketMessage{},
+		Data:        hub.PingMessage{},
+	},
+	{
+		Type:        string(hub.MessageTypeSubscribe),
+		Version:     1,
+		Direction:   EventFromClient,
+		Description: "Subscribe to event types, optionally limited to specific tasks",
+		Envelope:    hub.WebSocketMessage{},
+		Data:        hub.SubscribeMessage{},
+	},
+	{
+		Type:        string(hub.MessageTypeUnsubscribe),
+		Version:     1,
+		Direction:   EventFromClient,
+		Description: "Unsubscribe from event types",
+		Envelope:    hub.WebSocketMessage{},
+		Data:        hub.SubscribeMessage{},
+	},
+	{
+		Type:        string(hub.MessageTypeCommand),
+		Version:     1,
+		Direction:   EventFromClient,
+		Description: "Starts, continues, interrupts or aborts a task as the REST endpoint of the same name would; answered with command-result",
+		Envelope:    hub.WebSocketMessage{},
+		Data:        hub.CommandMessage{},
+	},
+}
+
+// EventSchemaDTO describes one event type and the JSON Schema of its payload
+type EventSchemaDTO struct {
+	Type        string         `json:"type"`
+	Version     int            `json:"version"`
+	Direction   EventDirection `json:"direction"`
+	Description string         `json:"description"`
+	Schema      *schema.Schema `json:"schema"`
+}
+
+// EventSchemasResponse is the response body of the event schema endpoint
+type EventSchemasResponse struct {
+	Events []EventSchemaDTO `json:"events"`
+}
+
+// eventSchema generates the JSON Schema for an event definition
+func eventSchema(def eventDefinition) *schema.Schema {
+	s := schema.For(def.Envelope)
+	s.Schema = schema.Draft
+	s.Title = def.Type
+	s.Description = def.Description
+	s.Properties["type"] = &schema.Schema{Type: "string", Const: def.Type}
+
+	if def.Data != nil {
+		s.Properties["data"] = schema.For(def.Data)
+	}
+	if def.Sequenced {
+		s.Properties["seq"] = &schema.Schema{
+			Type:        "integer",
+			Description: "Position in the event stream; present when event replay is enabled",
+		}
+	}
+
+	return s
+}
+
+// GetEventSchemas returns JSON Schemas for every WebSocket event type
+func GetEventSchemas(w http.ResponseWriter, r *http.Request) error {
+	resp := EventSchemasResponse{
+		Events: make([]EventSchemaDTO, 0, len(eventCatalog)),
+	}
+	for _, def := range eventCatalog {
+		resp.Events = append(resp.Events, EventSchemaDTO{
+			Type:        def.Type,
+			Version:     def.Version,
+			Direction:   def.Direction,
+			Description: def.Description,
+			Schema:      eventSchema(def),
+		})
+	}
+
+	return response.OK(w, resp)
+}
+
+// Authentication modes reported in FeaturesResponse
+const (
+	AuthModeNone  = "none"
+	AuthModeToken = "token"
+)
+
+// Features says which optional subsystems the daemon has enabled, so clients
+// only offer what the connected daemon supports. Git operations and the
+// scheduler aren't available in this version and are always false.
+type Features struct {
+	Git            bool `json:"git"`       // Merge, branch deletion and pull requests
+	Scheduler      bool `json:"scheduler"` // Scheduled and recurring tasks
+	Agents         bool `json:"agents"`    // Backends other than amp are configured
+	TLS            bool `json:"tls"`
+	Replay         bool `json:"replay"`  // WebSocket clients can resume with ?since= and GET /api/tasks/updates works
+	History        bool `json:"history"` // GET /api/events
+	Audit          bool `json:"audit"`   // GET /api/audit
+	Webhooks       bool `json:"webhooks"`
+	RateLimit      bool `json:"rate_limit"`
+	StallDetection bool `json:"stall_detection"`
+	Cleanup        bool `json:"cleanup"`
+	Hooks          bool `json:"hooks"`         // Lifecycle hook commands are configured
+	Plugins        bool `json:"plugins"`       // Plugin programs receive events
+	Pools          bool `json:"pools"`         // Tasks can select a worker pool
+	Containers     bool `json:"containers"`    // Tasks can run with "execution": "container"
+	RemoteAgents   bool `json:"remote_agents"` // Tasks can run with "execution": "remote"
+	IssueSync      bool `json:"issue_sync"`    // Issue priorities or labels are mapped onto linked tasks
+	Secrets        bool `json:"secrets"`       // Tasks can reference stored secrets
+	Sampling       bool `json:"sampling"`      // GET /api/tasks/{id}/stats and task-stats events
+}
+
+// FeaturesResponse is the response for GET /api/meta/features
+type FeaturesResponse struct {
+	Version  string   `json:"version"`
+	Auth     string   `json:"auth"` // AuthModeNone or AuthModeToken
+	Features Features `json:"features"`
+}
+
+// SetFeatures records the optional subsystems enabled on this daemon and how
+// clients authenticate
+func (h *TaskHandler) SetFeatures(authMode string, features Features) {
+	h.authMode = authMode
+	h.features = features
+}
+
+// GetFeatures returns the daemon's version and enabled optional subsystems
+func (h *TaskHandler) GetFeatures(w http.ResponseWriter, r *http.Request) error {
+	authMode := h.authMode
+	if authMode == "" {
+		authMode = AuthModeNone
+	}
+
+	return response.OK(w, FeaturesResponse{
+		Version:  version.String(),
+		Auth:     authMode,
+		Features: h.features,
+	})
+}
+
+// BackendsResponse is the response for GET /api/meta/backends
+type BackendsResponse struct {
+	Backends []worker.BackendInfo `json:"backends"`
+}
+
+// GetBackends lists the agent backends and models tasks may select
+func (h *TaskHandler) GetBackends(w http.ResponseWriter, r *http.Request) error {
+	return response.OK(w, BackendsResponse{Backends: h.manager.Backends()})
+}
+
+// PoolsResponse is the response for GET /api/meta/pools
+type PoolsResponse struct {
+	Pools []worker.PoolInfo `json:"pools"`
+}
+
+// GetPools lists the worker pools tasks may select and how many of each
+// pool's tasks are running
+func (h *TaskHandler) GetPools(w http.ResponseWriter, r *http.Request) error {
+	pools, err := h.manager.Pools()
+	if err != nil {
+		return apierr.Wrap(err, http.StatusInternalServerError, "Failed to list worker pools")
+	}
+	return response.OK(w, PoolsResponse{Pools: pools})
+}
+
+// AmpVersionDTO describes the amp executable the daemon runs
+type AmpVersionDTO struct {
+	Version   string `json:"version"`    // As reported by amp --version
+	LogFormat string `json:"log_format"` // Format the daemon parses amp's logs in
+}
+
+// VersionResponse is the response for GET /api/version
+type VersionResponse struct {
+	Version string         `json:"version"`
+	Amp     *AmpVersionDTO `json:"amp"` // nil when amp's version couldn't be detected
+}
+
+// GetVersion returns the daemon's version and the version of amp detected at
+// startup
+func (h *TaskHandler) GetVersion(w http.ResponseWriter, r *http.Request) error {
+	resp := VersionResponse{Version: version.String()}
+	if ampVersion, ok := h.manager.AmpVersion(); ok {
+		resp.Amp = &AmpVersionDTO{
+			Version:   ampVersion.String(),
+			LogFormat: worker.LogFormatFor(ampVersion).Name,
+		}
+	}
+	return response.OK(w, resp)
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	errormw "github.com/brettsmith212/amp-orchestrator-2/internal/middleware"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+func TestGetEventSchemas(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/meta/events", nil)
+	w := httptest.NewRecorder()
+
+	errormw.Error(GetEventSchemas)(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Events []struct {
+			Type      string                 `json:"type"`
+			Version   int                    `json:"version"`
+			Direction string                 `json:"direction"`
+			Schema    map[string]interface{} `json:"schema"`
+		} `json:"events"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	schemas := make(map[string]map[string]interface{})
+	for _, event := range resp.Events {
+		assert.Equal(t, 1, event.Version, event.Type)
+		schemas[event.Type] = event.Schema
+	}
+
+	// Every hub message type must be documented
+	for _, msgType := range []hub.MessageType{
+		hub.MessageTypeTaskUpdate,
+		hub.MessageTypeLog,
+		hub.MessageTypeThreadMessage,
+		hub.MessageTypePong,
+		hub.MessageTypeHeartbeat,
+		hub.MessageTypeTaskStalled,
+		hub.MessageTypeResyncRequired,
+		hub.MessageTypeSystem,
+		hub.MessageTypePing,
+		hub.MessageTypeSubscribe,
+		hub.MessageTypeUnsubscribe,
+		hub.MessageTypeAuth,
+		hub.MessageTypeAuthOK,
+	} {
+		assert.Contains(t, schemas, string(msgType))
+	}
+
+	taskUpdate := schemas["task-update"]
+	properties := taskUpdate["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "string", "const": "task-update"}, properties["type"])
+	assert.Contains(t, properties, "seq")
+
+	data := properties["data"].(map[string]interface{})
+	assert.Contains(t, data["properties"], "status")
+	assert.Contains(t, data["required"], "id")
+
+	// Hub envelopes describe the payload carried in data
+	heartbeat := schemas["heartbeat"]["properties"].(map[string]interface{})
+	assert.Contains(t, heartbeat["data"].(map[string]interface{})["properties"], "timestamp")
+	assert.NotContains(t, heartbeat, "seq")
+}
+
+func TestGetFeatures(t *testing.T) {
+	handler, _ := setupHierarchyHandler(t)
+	router := NewRouter(handler, handler.hub)
+
+	req := httptest.NewRequest("GET", "/api/meta/features", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "dev", w.Header().Get(errormw.VersionHeader))
+
+	var resp FeaturesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "dev", resp.Version)
+	assert.Equal(t, AuthModeNone, resp.Auth)
+	assert.Equal(t, Features{}, resp.Features)
+
+	handler.SetFeatures(AuthModeToken, Features{Replay: true, Audit: true})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, AuthModeToken, resp.Auth)
+	assert.True(t, resp.Features.Replay)
+	assert.True(t, resp.Features.Audit)
+	assert.False(t, resp.Features.Git)
+
+	// Every response carries the version, including probes and errors
+	for _, path := range []string{"/livez", "/api/tasks/missing/logs"} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, "dev", w.Header().Get(errormw.VersionHeader), path)
+	}
+}
+
+func TestGetVersion(t *testing.T) {
+	handler, manager := setupHierarchyHandler(t)
+	router := NewRouter(handler, handler.hub)
+
+	req := httptest.NewRequest("GET", "/api/version", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp VersionResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "dev", resp.Version)
+	assert.Nil(t, resp.Amp)
+
+	scriptPath := filepath.Join(t.TempDir(), "amp")
+	require.NoError(t, os.WriteFile(scriptPath, []byte("#!/bin/bash\necho \"0.0.1752595486-g6e2c95\"\n"), 0755))
+	manager.SetAmpBinary(scriptPath)
+	_, err := manager.DetectAmpVersion()
+	require.NoError(t, err)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.NotNil(t, resp.Amp)
+	assert.Equal(t, "0.0.1752595486-g6e2c95", resp.Amp.Version)
+	assert.Equal(t, worker.DefaultLogFormat().Name, resp.Amp.LogFormat)
+}
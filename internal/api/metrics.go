@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+)
+
+// MetricsHandler reports operational metrics for the daemon
+type MetricsHandler struct {
+	manager HealthManager
+	hub     *hub.Hub
+}
+
+// NewMetricsHandler creates a new metrics handler; h may be nil when the
+// daemon serves no WebSocket clients
+func NewMetricsHandler(manager HealthManager, h *hub.Hub) *MetricsHandler {
+	return &MetricsHandler{
+		manager: manager,
+		hub:     h,
+	}
+}
+
+// MetricsDTO is the response body of the metrics endpoint
+type MetricsDTO struct {
+	RateLimits []worker.RateLimitStats `json:"rate_limits"`
+	Logs       worker.LogStats         `json:"logs"`
+	WebSocket  *hub.Totals             `json:"websocket,omitempty"`
+}
+
+// GetMetrics returns the current metrics
+func (h *MetricsHandler) GetMetrics(w http.ResponseWriter, r *http.Request) error {
+	metrics := MetricsDTO{
+		RateLimits: h.manager.RateLimitStats(),
+		Logs:       h.manager.LogStats(),
+	}
+	if h.hub != nil {
+		totals := h.hub.Totals()
+		metrics.WebSocket = &totals
+	}
+	if metrics.RateLimits == nil {
+		metrics.RateLimits = []worker.RateLimitStats{}
+	}
+
+	return response.OK(w, metrics)
+}
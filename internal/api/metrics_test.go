@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+func TestGetMetrics_RateLimits(t *testing.T) {
+	manager := worker.NewManager(t.TempDir())
+	handler := NewMetricsHandler(manager, nil)
+
+	// Without a limiter the list is empty rather than null
+	req := httptest.NewRequest(http.MethodGet, "/api/metrics", nil)
+	w := httptest.NewRecorder()
+	require.NoError(t, handler.GetMetrics(w, req))
+	assert.JSONEq(t, `{"rate_limits": [], "logs": {"max_line_size": 1048576, "truncated_lines": 0}}`, w.Body.String())
+
+	limiter := worker.NewRateLimiter(worker.RateLimitConfig{ContinuesPerMinute: 5})
+	require.NoError(t, limiter.Wait(context.Background(), worker.InvocationContinue))
+	manager.SetRateLimiter(limiter)
+
+	w = httptest.NewRecorder()
+	require.NoError(t, handler.GetMetrics(w, req))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var metrics MetricsDTO
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &metrics))
+	require.Len(t, metrics.RateLimits, 1)
+	assert.Equal(t, worker.InvocationContinue, metrics.RateLimits[0].Kind)
+	assert.Equal(t, 5, metrics.RateLimits[0].Limit)
+	assert.Equal(t, 1, metrics.RateLimits[0].InWindow)
+}
+
+func TestGetMetrics_WebSocket(t *testing.T) {
+	h := hub.NewHub()
+	h.SetSlowClientPolicy(hub.SlowClientDropOldest)
+	handler := NewMetricsHandler(worker.NewManager(t.TempDir()), h)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/metrics", nil)
+	w := httptest.NewRecorder()
+	require.NoError(t, handler.GetMetrics(w, req))
+
+	var metrics MetricsDTO
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &metrics))
+	require.NotNil(t, metrics.WebSocket)
+	assert.Equal(t, hub.SlowClientDropOldest, metrics.WebSocket.SlowClientPolicy)
+	assert.Zero(t, metrics.WebSocket.DroppedMessages)
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+)
+
+// OwnerMe filters tasks by the caller's own identity in ?owner=
+const OwnerMe = "me"
+
+// resolveOwner returns the user named by an owner filter, resolving "me" to
+// the caller
+func (h *TaskHandler) resolveOwner(r *http.Request, owner string) (string, error) {
+	if owner != OwnerMe {
+		return owner, nil
+	}
+	identity := h.authenticate.Identify(r)
+	if identity == nil {
+		return "", apierr.New(http.StatusUnauthorized, "Token required for owner=me")
+	}
+	return identity.User, nil
+}
+
+// filterOwner returns the workers started by owner
+func filterOwner(workers []*worker.Worker, owner string) []*worker.Worker {
+	filtered := make([]*worker.Worker, 0, len(workers))
+	for _, w := range workers {
+		if w.Owner == owner {
+			filtered = append(filtered, w)
+		}
+	}
+	return filtered
+}
+
+// requireTaskControl checks that the caller owns the task, and with cascade
+// all of its subtasks, or is an admin. Tasks without an owner can only be
+// controlled by admins. Unknown tasks pass so the caller reports them as not
+// found.
+func (h *TaskHandler) requireTaskControl(r *http.Request, workerID string, cascade bool, action string) error {
+	return h.requireControl(r, workerID, cascade, action, func() ([]*worker.Worker, error) {
+		snapshot, err := h.manager.Snapshot()
+		if err != nil {
+			return nil, err
+		}
+		return snapshot.Workers(), nil
+	})
+}
+
+// requireTrashControl checks that the caller owns the trashed task and its
+// trashed subtasks, or is an admin
+func (h *TaskHandler) requireTrashControl(r *http.Request, workerID string, action string) error {
+	return h.requireControl(r, workerID, true, action, h.manager.ListTrash)
+}
+
+// requireControl checks the caller's ownership of a task among those listed
+func (h *TaskHandler) requireControl(r *http.Request, workerID string, cascade bool, action string, list func() ([]*worker.Worker, error)) error {
+	if h.authenticate == nil {
+		return nil
+	}
+
+	identity := h.authenticate.Identify(r)
+	if identity == nil {
+		return apierr.New(http.StatusUnauthorized, "Token required")
+	}
+	if identity.Role == RoleAdmin {
+		return nil
+	}
+
+	workers, err := list()
+	if err != nil {
+		return apierr.WrapInternal(err, "Failed to get tasks")
+	}
+	tree := worker.NewHierarchyFromList(workers)
+
+	tasks := tree.Subtree(workerID)
+	if !cascade && len(tasks) > 0 {
+		tasks = tasks[len(tasks)-1:] // The task itself comes after its descendants
+	}
+	for _, task := range tasks {
+		if task.Owner != identity.User {
+			return apierr.New(http.StatusForbidden, fmt.Sprintf("Only the owner of task %s or an admin may %s it", task.ID, action))
+		}
+	}
+	return nil
+}
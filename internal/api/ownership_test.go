@@ -0,0 +1,139 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub/hubtest"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+// setupOwnedTasks serves tasks owned by alice and bob, with token auth
+func setupOwnedTasks(t *testing.T) (*chi.Mux, *worker.Manager) {
+	tempDir := t.TempDir()
+	amp := filepath.Join(tempDir, "amp")
+	require.NoError(t, os.WriteFile(amp, []byte("#!/bin/sh\necho T-owned\n"), 0755))
+
+	manager := worker.NewManager(tempDir)
+	manager.SetAmpBinary(amp)
+	h := hub.NewHub()
+	hubtest.Run(t, h)
+	handler := NewTaskHandler(manager, h)
+	handler.SetAuthenticator(hub.TokenAuthenticator(map[string]hub.Identity{
+		"alice-token": {User: "alice"},
+		"bob-token":   {User: "bob"},
+		"admin-token": {User: "root", Role: RoleAdmin},
+	}))
+
+	base := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, manager.SaveWorkersForTest(map[string]*worker.Worker{
+		"alice1": {ID: "alice1", Started: base, Status: worker.StatusStopped, Owner: "alice"},
+		"bob1":   {ID: "bob1", Started: base.Add(time.Minute), Status: worker.StatusStopped, Owner: "bob", ParentID: "alice1"},
+		"legacy": {ID: "legacy", Started: base.Add(2 * time.Minute), Status: worker.StatusStopped},
+	}, filepath.Join(tempDir, "workers.json")))
+
+	return NewRouter(handler, h), manager
+}
+
+// ownedRequest serves a request presenting token
+func ownedRequest(router http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestListTasks_OwnerFilter(t *testing.T) {
+	router, _ := setupOwnedTasks(t)
+
+	list := func(query, token string) []string {
+		w := ownedRequest(router, "GET", "/api/tasks"+query, token, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp PaginatedTasksResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		var ids []string
+		for _, task := range resp.Tasks {
+			ids = append(ids, task.ID)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{"alice1"}, list("?owner=me", "alice-token"))
+	assert.Equal(t, []string{"bob1"}, list("?owner=bob", "alice-token"))
+	assert.Len(t, list("", ""), 3)
+
+	assert.Equal(t, http.StatusUnauthorized, ownedRequest(router, "GET", "/api/tasks?owner=me", "", "").Code)
+}
+
+func TestStartTask_RecordsOwner(t *testing.T) {
+	router, _ := setupOwnedTasks(t)
+
+	w := ownedRequest(router, "POST", "/api/tasks", "bob-token", `{"message":"hi"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var task TaskDTO
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &task))
+	assert.Equal(t, "bob", task.Owner)
+
+	w = ownedRequest(router, "POST", "/api/tasks", "", `{"message":"hi"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var anonymous TaskDTO
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &anonymous))
+	assert.Empty(t, anonymous.Owner)
+}
+
+func TestTaskControl_RequiresOwnerOrAdmin(t *testing.T) {
+	router, manager := setupOwnedTasks(t)
+
+	assert.Equal(t, http.StatusUnauthorized, ownedRequest(router, "DELETE", "/api/tasks/bob1", "", "").Code)
+	assert.Equal(t, http.StatusForbidden, ownedRequest(router, "DELETE", "/api/tasks/bob1", "alice-token", "").Code)
+	assert.Equal(t, http.StatusForbidden, ownedRequest(router, "POST", "/api/tasks/bob1/abort", "alice-token", "").Code)
+	assert.Equal(t, http.StatusForbidden, ownedRequest(router, "POST", "/api/tasks/bob1/stop", "alice-token", "").Code)
+
+	// Only admins control tasks without an owner
+	assert.Equal(t, http.StatusForbidden, ownedRequest(router, "DELETE", "/api/tasks/legacy", "alice-token", "").Code)
+	assert.Equal(t, http.StatusNoContent, ownedRequest(router, "DELETE", "/api/tasks/legacy", "admin-token", "").Code)
+
+	// Cascading needs every subtask to be the caller's too
+	w := ownedRequest(router, "DELETE", "/api/tasks/alice1?cascade=true", "alice-token", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "bob1")
+
+	assert.Equal(t, http.StatusNoContent, ownedRequest(router, "DELETE", "/api/tasks/bob1", "bob-token", "").Code)
+	assert.Equal(t, http.StatusNoContent, ownedRequest(router, "DELETE", "/api/tasks/alice1?cascade=true", "alice-token", "").Code)
+
+	workers, err := manager.ListWorkers()
+	require.NoError(t, err)
+	assert.Empty(t, workers)
+
+	assert.Equal(t, http.StatusNotFound, ownedRequest(router, "DELETE", "/api/tasks/missing", "alice-token", "").Code)
+}
+
+func TestTaskControl_TransitionInterruptRetry(t *testing.T) {
+	router, manager := setupOwnedTasks(t)
+
+	assert.Equal(t, http.StatusForbidden, ownedRequest(router, "POST", "/api/tasks/bob1/transition", "alice-token", `{"status":"aborted"}`).Code)
+	assert.Equal(t, http.StatusForbidden, ownedRequest(router, "POST", "/api/tasks/bob1/interrupt", "alice-token", "").Code)
+	assert.Equal(t, http.StatusForbidden, ownedRequest(router, "POST", "/api/tasks/bob1/retry", "alice-token", `{"message":"again"}`).Code)
+	assert.Equal(t, http.StatusUnauthorized, ownedRequest(router, "POST", "/api/tasks/bob1/transition", "", `{"status":"aborted"}`).Code)
+
+	workers, err := manager.ListWorkers()
+	require.NoError(t, err)
+	for _, task := range workers {
+		assert.Equal(t, worker.StatusStopped, task.Status, task.ID)
+	}
+
+}
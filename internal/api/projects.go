@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+)
+
+// ProjectHandler serves the projects tasks are grouped into
+type ProjectHandler struct {
+	manager ProjectManager
+}
+
+// NewProjectHandler creates a new project handler
+func NewProjectHandler(manager ProjectManager) *ProjectHandler {
+	return &ProjectHandler{manager: manager}
+}
+
+// ListProjects returns every project
+func (h *ProjectHandler) ListProjects(w http.ResponseWriter, r *http.Request) error {
+	projects, err := h.manager.ListProjects()
+	if err != nil {
+		return apierr.WrapInternal(err, "Failed to list projects")
+	}
+	return response.OK(w, ProjectsResponse{Projects: projects})
+}
+
+// CreateProject adds a project
+func (h *ProjectHandler) CreateProject(w http.ResponseWriter, r *http.Request) error {
+	var req CreateProjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return apierr.BadRequest("Invalid JSON request body")
+	}
+	if req.Name == "" {
+		return apierr.BadRequest("Name is required")
+	}
+	execution, err := projectExecution(req.Execution)
+	if err != nil {
+		return err
+	}
+
+	project, err := h.manager.CreateProject(worker.Project{
+		Name:          req.Name,
+		RepoURL:       req.RepoURL,
+		DefaultBranch: req.DefaultBranch,
+		Amp:           worker.AmpSettings{Execution: execution, Dir: req.Dir},
+	})
+	if err != nil {
+		return projectError(err, "create project")
+	}
+	return response.Created(w, project)
+}
+
+// GetProject returns a project
+func (h *ProjectHandler) GetProject(w http.ResponseWriter, r *http.Request) error {
+	project, err := h.manager.GetProject(chi.URLParam(r, "name"))
+	if err != nil {
+		return projectError(err, "get project")
+	}
+	return response.OK(w, project)
+}
+
+// PatchProject changes a project's settings
+func (h *ProjectHandler) PatchProject(w http.ResponseWriter, r *http.Request) error {
+	var req PatchProjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return apierr.BadRequest("Invalid JSON request body")
+	}
+
+	update := worker.ProjectUpdate{
+		RepoURL:       req.RepoURL,
+		DefaultBranch: req.DefaultBranch,
+		Dir:           req.Dir,
+	}
+	if req.Execution != nil {
+		execution, err := projectExecution(*req.Execution)
+		if err != nil {
+			return err
+		}
+		update.Execution = &execution
+	}
+
+	project, err := h.manager.UpdateProject(chi.URLParam(r, "name"), update)
+	if err != nil {
+		return projectError(err, "update project")
+	}
+	return response.OK(w, project)
+}
+
+// DeleteProject removes a project that has no tasks
+func (h *ProjectHandler) DeleteProject(w http.ResponseWriter, r *http.Request) error {
+	if err := h.manager.DeleteProject(chi.URLParam(r, "name")); err != nil {
+		return projectError(err, "delete project")
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// projectExecution validates a project's default execution mode, which may be
+// empty to use the daemon's
+func projectExecution(value string) (worker.ExecutionMode, error) {
+	execution := worker.ExecutionMode(value)
+	switch execution {
+	case "", worker.ExecutionHost, worker.ExecutionContainer, worker.ExecutionRemote:
+		return execution, nil
+	}
+	return "", apierr.BadRequestf("Invalid execution mode: %s", value)
+}
+
+// projectError maps a project error to an API error
+func projectError(err error, action string) error {
+	switch {
+	case errors.Is(err, worker.ErrProjectNotFound):
+		return apierr.Wrap(err, http.StatusNotFound, "Project not found")
+	case errors.Is(err, worker.ErrInvalidProjectName):
+		return apierr.Wrap(err, http.StatusBadRequest, err.Error())
+	case errors.Is(err, worker.ErrContainerNotConfigured):
+		return apierr.Wrap(err, http.StatusBadRequest, "Container execution is not configured")
+	case errors.Is(err, worker.ErrRemoteNotConfigured):
+		return apierr.Wrap(err, http.StatusBadRequest, "Remote execution is not configured")
+	case strings.Contains(err.Error(), "cannot "):
+		return apierr.Wrap(err, http.StatusConflict, err.Error())
+	default:
+		return apierr.WrapInternalf(err, "Failed to %s", action)
+	}
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub/hubtest"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+func TestProjects(t *testing.T) {
+	handler, _ := setupHierarchyHandler(t)
+	router := NewRouter(handler, handler.hub)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	w := serve("POST", "/api/projects", `{"name":"infra","repo_url":"git@example.com:infra.git","default_branch":"main"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var project worker.Project
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &project))
+	assert.Equal(t, "infra", project.Name)
+	assert.Equal(t, "main", project.DefaultBranch)
+
+	assert.Equal(t, http.StatusConflict, serve("POST", "/api/projects", `{"name":"infra"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve("POST", "/api/projects", `{"name":"Not Valid"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve("POST", "/api/projects", `{"name":"x","execution":"moon"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve("POST", "/api/projects", `{}`).Code)
+
+	w = serve("PATCH", "/api/projects/infra", `{"default_branch":"develop"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &project))
+	assert.Equal(t, "develop", project.DefaultBranch)
+	assert.Equal(t, "git@example.com:infra.git", project.RepoURL)
+	assert.Equal(t, http.StatusNotFound, serve("PATCH", "/api/projects/missing", `{}`).Code)
+
+	w = serve("GET", "/api/projects", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp ProjectsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Projects, 2)
+	assert.Equal(t, "default", resp.Projects[0].Name)
+	assert.Equal(t, "infra", resp.Projects[1].Name)
+
+	assert.Equal(t, http.StatusOK, serve("GET", "/api/projects/infra", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve("GET", "/api/projects/missing", "").Code)
+
+	// Tasks can't be started in a project that doesn't exist
+	w = serve("POST", "/api/tasks", `{"message":"hi","project":"missing"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	assert.Equal(t, http.StatusConflict, serve("DELETE", "/api/projects/default", "").Code)
+	assert.Equal(t, http.StatusNoContent, serve("DELETE", "/api/projects/infra", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve("DELETE", "/api/projects/infra", "").Code)
+}
+
+func TestListTasks_ProjectFilter(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := worker.NewManager(tempDir)
+	h := hub.NewHub()
+	hubtest.Run(t, h)
+	handler := NewTaskHandler(manager, h)
+
+	// Tasks saved before projects existed belong to the default project
+	base := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, manager.SaveWorkersForTest(map[string]*worker.Worker{
+		"legacy": {ID: "legacy", Started: base, Status: worker.StatusStopped},
+		"plain":  {ID: "plain", Started: base.Add(time.Minute), Status: worker.StatusStopped, Project: "default"},
+		"infra":  {ID: "infra", Started: base.Add(2 * time.Minute), Status: worker.StatusStopped, Project: "infra"},
+	}, filepath.Join(tempDir, "workers.json")))
+
+	router := NewRouter(handler, handler.hub)
+	list := func(query string) PaginatedTasksResponse {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/tasks"+query, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp PaginatedTasksResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	resp := list("?project=infra")
+	require.Len(t, resp.Tasks, 1)
+	assert.Equal(t, "infra", resp.Tasks[0].ID)
+	assert.Equal(t, "infra", resp.Tasks[0].Project)
+
+	resp = list("?project=default")
+	assert.Equal(t, 2, resp.Total)
+	assert.Equal(t, "default", resp.Tasks[0].Project)
+
+	assert.Equal(t, 3, list("").Total)
+}
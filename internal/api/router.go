@@ -2,45 +2,128 @@ package api
 
 import (
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
 
 	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
 	errormw "github.com/brettsmith212/amp-orchestrator-2/internal/middleware"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/version"
 )
 
 func NewRouter(taskHandler *TaskHandler, h *hub.Hub) *chi.Mux {
 	r := chi.NewRouter()
 	
 	// Add basic middleware
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
+	r.Use(errormw.RequestID)
+	r.Use(errormw.Version(version.String()))
+	r.Use(errormw.RequestLogger)
+	r.Use(errormw.Recovery)
 	
-	// Health check endpoint
+	// Liveness and readiness probes
+	readinessHandler := NewReadinessHandler(taskHandler.manager)
+	r.Get("/livez", HealthHandler)
 	r.Get("/healthz", HealthHandler)
+	r.Get("/readyz", errormw.Error(readinessHandler.GetReadiness))
 	
 	// Create log handler using the same manager from task handler
 	logHandler := NewLogHandler(taskHandler.manager)
 	
 	// WebSocket handler
-	wsHandler := NewWSHandler(h)
+	wsHandler := NewWSHandler(h, taskHandler.manager)
+
+	// Webhook handler using the task handler's dispatcher
+	webhookHandler := NewWebhookHandler(taskHandler.webhooks)
+
+	// Event handler reading the hub's history journal and replay buffer
+	eventHandler := NewEventHandler(h)
+
+	// Audit handler reading the task handler's audit log
+	auditHandler := NewAuditHandler(taskHandler.audit)
+
+	// Agent handler using the task handler's agent pool
+	agentHandler := NewAgentHandler(taskHandler.agents, taskHandler.requireAdmin)
+
+	// Project handler using the same manager
+	projectHandler := NewProjectHandler(taskHandler.manager)
+
+	// Metrics handler using the same manager
+	metricsHandler := NewMetricsHandler(taskHandler.manager, h)
+
+	// System handler reporting on the daemon and its log directory
+	systemHandler := NewSystemHandler(taskHandler.manager)
 	
 	r.Route("/api", func(r chi.Router) {
+		if taskHandler.audit != nil {
+			r.Use(errormw.Audit(taskHandler.audit, taskHandler.identifyCaller))
+		}
+
 		r.Get("/tasks", errormw.Error(taskHandler.ListTasks))
-		r.Post("/tasks", taskHandler.StartTask)
-		r.Patch("/tasks/{id}", taskHandler.PatchTask)
-		r.Delete("/tasks/{id}", taskHandler.DeleteTask)
-		r.Post("/tasks/{id}/stop", taskHandler.StopTask)
-		r.Post("/tasks/{id}/continue", taskHandler.ContinueTask)
-		r.Post("/tasks/{id}/interrupt", taskHandler.InterruptTask)
-		r.Post("/tasks/{id}/abort", taskHandler.AbortTask)
-		r.Post("/tasks/{id}/retry", taskHandler.RetryTask)
-		r.Post("/tasks/{id}/merge", taskHandler.MergeTask)
-		r.Post("/tasks/{id}/delete-branch", taskHandler.DeleteBranchTask)
-		r.Post("/tasks/{id}/create-pr", taskHandler.CreatePRTask)
-		r.Get("/tasks/{id}/logs", logHandler.GetTaskLogs)
-		r.Get("/tasks/{id}/thread", GetTaskThread(taskHandler.manager))
+		r.Get("/tasks/updates", errormw.Error(eventHandler.WaitForTaskUpdates))
+		r.Post("/tasks", errormw.Error(taskHandler.StartTask))
+		r.Get("/tasks/{id}", errormw.Error(taskHandler.GetTask))
+		r.Patch("/tasks/{id}", errormw.Error(taskHandler.PatchTask))
+		r.Delete("/tasks/{id}", errormw.Error(taskHandler.DeleteTask))
+		r.Post("/tasks/{id}/stop", errormw.Error(taskHandler.StopTask))
+		r.Post("/tasks/{id}/continue", errormw.Error(taskHandler.ContinueTask))
+		r.Post("/tasks/{id}/interrupt", errormw.Error(taskHandler.InterruptTask))
+		r.Post("/tasks/{id}/abort", errormw.Error(taskHandler.AbortTask))
+		r.Post("/tasks/{id}/wind-down", errormw.Error(taskHandler.WindDownTask))
+		r.Post("/tasks/{id}/retry", errormw.Error(taskHandler.RetryTask))
+		r.Post("/tasks/{id}/restore", errormw.Error(taskHandler.RestoreTask))
+		r.Post("/tasks/{id}/transition", errormw.Error(taskHandler.TransitionTask))
+		r.Post("/tasks/{id}/annotations", errormw.Error(taskHandler.AnnotateTask))
+		r.Post("/tasks/{id}/merge", errormw.Error(taskHandler.MergeTask))
+		r.Post("/tasks/{id}/delete-branch", errormw.Error(taskHandler.DeleteBranchTask))
+		r.Post("/tasks/{id}/create-pr", errormw.Error(taskHandler.CreatePRTask))
+		r.Get("/tasks/{id}/diff", errormw.Error(taskHandler.GetTaskDiff))
+		r.Get("/tasks/{id}/commits", errormw.Error(taskHandler.GetTaskCommits))
+		r.Get("/tasks/{id}/merge-check", errormw.Error(taskHandler.CheckTaskMerge))
+		r.Get("/tasks/{id}/stats", errormw.Error(taskHandler.GetTaskStats))
+		r.Get("/tasks/{id}/attempts", errormw.Error(taskHandler.GetTaskAttempts))
+		r.Get("/tasks/{id}/timeline", errormw.Error(taskHandler.GetTaskTimeline))
+		r.Get("/tasks/{id}/queue", errormw.Error(taskHandler.GetTaskQueue))
+		r.Get("/tasks/{id}/artifacts", errormw.Error(taskHandler.ListTaskArtifacts))
+		r.Get("/tasks/{id}/artifacts/*", errormw.Error(taskHandler.DownloadTaskArtifact))
+		r.Get("/tasks/{id}/logs", errormw.Error(logHandler.GetTaskLogs))
+		r.Get("/tasks/{id}/logs/search", errormw.Error(logHandler.SearchTaskLogs))
+		r.Get("/tasks/{id}/amp-logs", errormw.Error(logHandler.GetTaskAmpLogs))
+		r.Get("/tasks/{id}/export", errormw.Error(logHandler.ExportTask))
+		r.Get("/tasks/{id}/thread", errormw.Error(taskHandler.GetTaskThread))
+		r.Post("/tasks/{id}/thread/rebuild", errormw.Error(taskHandler.RebuildTaskThread))
+		r.Delete("/tasks/{id}/thread/{messageID}", errormw.Error(taskHandler.RemoveThreadMessage))
+		r.Post("/tasks/{id}/thread/{messageID}/redact", errormw.Error(taskHandler.RedactThreadMessage))
+		r.Get("/tasks/{id}/ws", errormw.Error(wsHandler.ServeTaskWS))
+		r.Get("/trash", errormw.Error(taskHandler.ListTrash))
+		r.Get("/projects", errormw.Error(projectHandler.ListProjects))
+		r.Post("/projects", errormw.Error(projectHandler.CreateProject))
+		r.Get("/projects/{name}", errormw.Error(projectHandler.GetProject))
+		r.Patch("/projects/{name}", errormw.Error(projectHandler.PatchProject))
+		r.Delete("/projects/{name}", errormw.Error(projectHandler.DeleteProject))
+		r.Post("/integrations/github", errormw.Error(taskHandler.ReceiveGitHubIssue))
+		r.Post("/integrations/jira", errormw.Error(taskHandler.ReceiveJiraIssue))
+		r.Post("/webhooks/evaluate", errormw.Error(webhookHandler.EvaluateRoutes))
+		r.Post("/webhooks/{name}/test", errormw.Error(webhookHandler.TestWebhook))
+		r.Get("/metrics", errormw.Error(metricsHandler.GetMetrics))
+		r.Get("/system", errormw.Error(systemHandler.GetSystem))
+		r.Get("/events", errormw.Error(eventHandler.ListEvents))
+		r.Get("/audit", errormw.Error(auditHandler.ListAudit))
+		r.Get("/agents", errormw.Error(agentHandler.ListAgents))
+		r.Get("/secrets", errormw.Error(taskHandler.ListSecrets))
+		r.Put("/secrets/{name}", errormw.Error(taskHandler.SetSecret))
+		r.Delete("/secrets/{name}", errormw.Error(taskHandler.DeleteSecret))
+		r.Get("/agents/ws", errormw.Error(agentHandler.ServeAgentWS))
+		r.Get("/meta/events", errormw.Error(GetEventSchemas))
+		r.Get("/meta/features", errormw.Error(taskHandler.GetFeatures))
+		r.Get("/meta/backends", errormw.Error(taskHandler.GetBackends))
+		r.Get("/meta/pools", errormw.Error(taskHandler.GetPools))
+		r.Get("/version", errormw.Error(taskHandler.GetVersion))
 		r.Get("/ws", wsHandler.ServeWS)
+		r.Get("/ws/stats", errormw.Error(wsHandler.GetStats))
+		r.Post("/admin/backfill-threads", errormw.Error(taskHandler.BackfillThreads))
 	})
 	
+	// Commands sent over the WebSocket run through the same endpoints
+	if h != nil {
+		h.SetCommandHandler(NewCommandHandler(r))
+	}
+	
 	return r
 }
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/secrets"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+)
+
+// SetSecretStore manages the secrets tasks reference through store. The
+// secrets endpoints are restricted like /api/admin endpoints.
+func (h *TaskHandler) SetSecretStore(store *secrets.Store) {
+	h.secrets = store
+}
+
+// ListSecrets returns the stored secrets without their values
+func (h *TaskHandler) ListSecrets(w http.ResponseWriter, r *http.Request) error {
+	if err := h.requireAdmin(r); err != nil {
+		return err
+	}
+	if h.secrets == nil {
+		return apierr.NotFound("Secrets are not enabled")
+	}
+
+	list, err := h.secrets.List()
+	if err != nil {
+		return apierr.WrapInternal(err, "Failed to list secrets")
+	}
+	return response.OK(w, SecretsResponse{Secrets: list})
+}
+
+// SetSecret creates or replaces a secret
+func (h *TaskHandler) SetSecret(w http.ResponseWriter, r *http.Request) error {
+	if err := h.requireAdmin(r); err != nil {
+		return err
+	}
+	if h.secrets == nil {
+		return apierr.NotFound("Secrets are not enabled")
+	}
+
+	var req SetSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return apierr.BadRequest("Invalid JSON request body")
+	}
+	if req.Value == "" {
+		return apierr.BadRequest("Value is required")
+	}
+
+	secret, created, err := h.secrets.Set(chi.URLParam(r, "name"), req.Value)
+	if err != nil {
+		if errors.Is(err, secrets.ErrInvalidName) {
+			return apierr.BadRequest("Invalid secret name: use letters, digits, '.', '_' and '-'")
+		}
+		return apierr.WrapInternal(err, "Failed to store secret")
+	}
+	if created {
+		return response.Created(w, secret)
+	}
+	return response.OK(w, secret)
+}
+
+// DeleteSecret removes a secret. Tasks referencing it can no longer be
+// continued or retried.
+func (h *TaskHandler) DeleteSecret(w http.ResponseWriter, r *http.Request) error {
+	if err := h.requireAdmin(r); err != nil {
+		return err
+	}
+	if h.secrets == nil {
+		return apierr.NotFound("Secrets are not enabled")
+	}
+
+	if err := h.secrets.Delete(chi.URLParam(r, "name")); err != nil {
+		if errors.Is(err, secrets.ErrNotFound) {
+			return apierr.Wrap(err, http.StatusNotFound, "Secret not found")
+		}
+		return apierr.WrapInternal(err, "Failed to delete secret")
+	}
+	response.NoContent(w)
+	return nil
+}
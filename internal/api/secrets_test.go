@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub/hubtest"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/secrets"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+func TestSecrets(t *testing.T) {
+	tempDir := t.TempDir()
+	amp := filepath.Join(tempDir, "amp")
+	require.NoError(t, os.WriteFile(amp, []byte("#!/bin/sh\necho T-secret\n"), 0755))
+
+	store, err := secrets.Open(filepath.Join(tempDir, "secrets.json"), "0123456789abcdef")
+	require.NoError(t, err)
+	manager := worker.NewManager(tempDir)
+	manager.SetAmpBinary(amp)
+	manager.SetSecrets(store)
+	h := hub.NewHub()
+	hubtest.Run(t, h)
+	handler := NewTaskHandler(manager, h)
+	handler.SetSecretStore(store)
+	handler.SetAuthenticator(hub.TokenAuthenticator(map[string]hub.Identity{
+		"alice-token": {User: "alice"},
+		"admin-token": {User: "root", Role: RoleAdmin},
+	}))
+	router := NewRouter(handler, h)
+
+	assert.Equal(t, http.StatusForbidden, ownedRequest(router, "GET", "/api/secrets", "alice-token", "").Code)
+	assert.Equal(t, http.StatusForbidden, ownedRequest(router, "PUT", "/api/secrets/github-token", "alice-token", `{"value":"ghp_123"}`).Code)
+
+	assert.Equal(t, http.StatusCreated, ownedRequest(router, "PUT", "/api/secrets/github-token", "admin-token", `{"value":"ghp_123"}`).Code)
+	assert.Equal(t, http.StatusOK, ownedRequest(router, "PUT", "/api/secrets/github-token", "admin-token", `{"value":"ghp_456"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, ownedRequest(router, "PUT", "/api/secrets/github-token", "admin-token", `{}`).Code)
+
+	w := ownedRequest(router, "GET", "/api/secrets", "admin-token", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "ghp_456")
+	var list SecretsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Secrets, 1)
+	assert.Equal(t, "github-token", list.Secrets[0].Name)
+
+	// Tasks reference secrets by name; values stay out of the task
+	w = ownedRequest(router, "POST", "/api/tasks", "alice-token", `{"message":"hi","secrets":{"GITHUB_TOKEN":"npm-token"}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "secret not found: npm-token")
+
+	w = ownedRequest(router, "POST", "/api/tasks", "alice-token", `{"message":"hi","secrets":{"GITHUB_TOKEN":"github-token"}}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.NotContains(t, w.Body.String(), "ghp_456")
+	var task TaskDTO
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &task))
+	assert.Equal(t, map[string]string{"GITHUB_TOKEN": "github-token"}, task.Secrets)
+	state, err := os.ReadFile(filepath.Join(tempDir, "workers.json"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(state), "ghp_456")
+
+	assert.Equal(t, http.StatusNoContent, ownedRequest(router, "DELETE", "/api/secrets/github-token", "admin-token", "").Code)
+	assert.Equal(t, http.StatusNotFound, ownedRequest(router, "DELETE", "/api/secrets/github-token", "admin-token", "").Code)
+}
+
+func TestSecrets_NotEnabled(t *testing.T) {
+	router, _ := setupOwnedTasks(t)
+
+	w := ownedRequest(router, "GET", "/api/secrets", "admin-token", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "Secrets are not enabled")
+
+	w = ownedRequest(router, "POST", "/api/tasks", "alice-token", `{"message":"hi","secrets":{"GITHUB_TOKEN":"github-token"}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Secrets are not enabled")
+}
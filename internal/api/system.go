@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/version"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+)
+
+// SystemHandler reports the state of the daemon and its host for ops dashboards
+type SystemHandler struct {
+	manager HealthManager
+	started time.Time
+}
+
+// NewSystemHandler creates a new system handler; uptime is counted from now
+func NewSystemHandler(manager HealthManager) *SystemHandler {
+	return &SystemHandler{
+		manager: manager,
+		started: time.Now(),
+	}
+}
+
+// SystemDTO is the response body of the system endpoint
+type SystemDTO struct {
+	Version       string              `json:"version"`
+	StartedAt     time.Time           `json:"started_at"`
+	UptimeSeconds int64               `json:"uptime_seconds"`
+	Goroutines    int                 `json:"goroutines"`
+	Workers       worker.WorkerCounts `json:"workers"`
+	Storage       worker.StorageUsage `json:"storage"`
+	Amp           worker.AmpStatus    `json:"amp"`
+}
+
+// GetSystem returns the daemon's uptime, tracked workers, disk usage and amp health
+func (h *SystemHandler) GetSystem(w http.ResponseWriter, r *http.Request) error {
+	workers, err := h.manager.CountWorkers()
+	if err != nil {
+		return apierr.WrapInternal(err, "Failed to count tasks")
+	}
+	storage, err := h.manager.StorageUsage()
+	if err != nil {
+		return apierr.WrapInternal(err, "Failed to measure the log directory")
+	}
+
+	return response.OK(w, SystemDTO{
+		Version:       version.String(),
+		StartedAt:     h.started,
+		UptimeSeconds: int64(time.Since(h.started).Seconds()),
+		Goroutines:    runtime.NumGoroutine(),
+		Workers:       workers,
+		Storage:       storage,
+		Amp:           h.manager.AmpStatus(),
+	})
+}
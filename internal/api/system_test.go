@@ -0,0 +1,48 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+func TestGetSystem(t *testing.T) {
+	logDir := t.TempDir()
+	manager := worker.NewManager(logDir)
+	manager.SetAmpBinary(filepath.Join(logDir, "missing-amp"))
+	require.NoError(t, manager.SaveWorkersForTest(map[string]*worker.Worker{
+		"done":   {ID: "done", PID: 999998, Started: time.Now(), Status: worker.StatusCompleted},
+		"failed": {ID: "failed", PID: 999999, Started: time.Now(), Status: worker.StatusFailed},
+	}, filepath.Join(logDir, "workers.json")))
+	require.NoError(t, os.WriteFile(filepath.Join(logDir, "worker-done.log"), []byte("0123456789"), 0644))
+	state, err := os.Stat(filepath.Join(logDir, "workers.json"))
+	require.NoError(t, err)
+
+	handler := NewSystemHandler(manager)
+	req := httptest.NewRequest(http.MethodGet, "/api/system", nil)
+	w := httptest.NewRecorder()
+	require.NoError(t, handler.GetSystem(w, req))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var system SystemDTO
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &system))
+	assert.Equal(t, 2, system.Workers.Total)
+	assert.Equal(t, map[string]int{"completed": 1, "failed": 1}, system.Workers.ByStatus)
+	assert.Equal(t, state.Size(), system.Storage.StateFileBytes)
+	assert.Equal(t, state.Size()+10, system.Storage.LogDirBytes)
+	assert.Equal(t, 2, system.Storage.LogDirFiles)
+	assert.Positive(t, system.Goroutines)
+	assert.False(t, system.StartedAt.IsZero())
+
+	assert.False(t, system.Amp.Healthy)
+	assert.Contains(t, system.Amp.Error, "missing-amp")
+}
@@ -2,12 +2,24 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/agent"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/audit"
 	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/secrets"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/webhook"
 	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/ansi"
 	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
 	"github.com/brettsmith212/amp-orchestrator-2/pkg/query"
 	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
@@ -15,20 +27,68 @@ import (
 
 // TaskHandler handles task-related API requests
 type TaskHandler struct {
-	manager *worker.Manager
-	hub     *hub.Hub
+	// Used as a TaskManager; the router hands the rest to the other handlers
+	manager  WorkerManager
+	hub      *hub.Hub
+	webhooks *webhook.Dispatcher
+
+	// Records mutating calls; nil disables auditing
+	audit          *audit.Log
+	identifyCaller func(r *http.Request) string
+
+	// Priority and tag inheritance from linked issues
+	issues IssueSync
+
+	// Refuse to merge tasks whose pull request checks are failing
+	blockFailingMerges bool
+
+	// Reported by GET /api/meta/features
+	authMode string
+	features Features
+
+	// Identifies task owners and admins; nil leaves admin endpoints and
+	// task control open
+	authenticate hub.Authenticator
+
+	// Remote agents; nil disables remote execution
+	agents *agent.Pool
+
+	// Secrets tasks can reference; nil disables the secrets endpoints
+	secrets *secrets.Store
+
+	// CPU and memory samples of running tasks; nil disables the stats endpoint
+	sampler *worker.Sampler
 }
 
 // NewTaskHandler creates a new task handler
-func NewTaskHandler(manager *worker.Manager, h *hub.Hub) *TaskHandler {
+func NewTaskHandler(manager WorkerManager, h *hub.Hub) *TaskHandler {
 	return &TaskHandler{
 		manager: manager,
 		hub:     h,
 	}
 }
 
-// broadcastTaskUpdate sends a task-update event over WebSocket
+// SetWebhookDispatcher sets the dispatcher that delivers task events to webhooks
+func (h *TaskHandler) SetWebhookDispatcher(d *webhook.Dispatcher) {
+	h.webhooks = d
+}
+
+// SetAuditLog records mutating API calls in the audit log, naming callers
+// with identify
+func (h *TaskHandler) SetAuditLog(log *audit.Log, identify func(r *http.Request) string) {
+	h.audit = log
+	h.identifyCaller = identify
+}
+
+// broadcastTaskUpdate sends a task-update event over WebSocket and to webhooks
 func (h *TaskHandler) broadcastTaskUpdate(task TaskDTO) {
+	h.webhooks.Dispatch(webhook.Event{
+		Type:       "task-update",
+		TaskID:     task.ID,
+		Task:       task,
+		Attributes: taskAttributes(task),
+	})
+
 	if h.hub == nil {
 		return
 	}
@@ -44,37 +104,186 @@ func (h *TaskHandler) broadcastTaskUpdate(task TaskDTO) {
 		return
 	}
 
-	h.hub.Broadcast(eventJSON)
+	h.hub.SetTaskTags(task.ID, task.Tags)
+	h.hub.BroadcastTaskEvent(task.ID, TaskRoom(task.ID), eventJSON)
+}
+
+// broadcastTaskCreated sends a task-created event over WebSocket and to webhooks
+func (h *TaskHandler) broadcastTaskCreated(task TaskDTO) {
+	h.webhooks.Dispatch(webhook.Event{
+		Type:       "task-created",
+		TaskID:     task.ID,
+		Task:       task,
+		Attributes: taskAttributes(task),
+	})
+
+	if h.hub == nil {
+		return
+	}
+
+	eventJSON, err := json.Marshal(TaskCreatedEvent{Type: "task-created", Data: task})
+	if err != nil {
+		return
+	}
+
+	h.hub.SetTaskTags(task.ID, task.Tags)
+	h.hub.BroadcastTaskEvent(task.ID, TaskRoom(task.ID), eventJSON)
+}
+
+// broadcastTaskDeleted sends a task-deleted event carrying the task's final
+// state over WebSocket and to webhooks
+func (h *TaskHandler) broadcastTaskDeleted(task TaskDTO) {
+	h.webhooks.Dispatch(webhook.Event{
+		Type:       "task-deleted",
+		TaskID:     task.ID,
+		Task:       task,
+		Attributes: taskAttributes(task),
+	})
+
+	if h.hub == nil {
+		return
+	}
+
+	event := TaskDeletedEvent{
+		Type: "task-deleted",
+		Data: TaskDeletedDTO{TaskID: task.ID, Task: task},
+	}
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	// Tag subscribers still match the event; the tags are forgotten after it
+	h.hub.BroadcastTaskEvent(task.ID, TaskRoom(task.ID), eventJSON)
+	h.hub.SetTaskTags(task.ID, nil)
+}
+
+// newTaskDTO converts a worker to its API representation, filling in
+// subtask rollup fields from the hierarchy when the task has children
+func newTaskDTO(w *worker.Worker, tree *worker.Hierarchy) TaskDTO {
+	task := TaskDTO{
+		ID:          w.ID,
+		ThreadID:    w.ThreadID,
+		Status:      string(w.Status),
+		Started:     w.Started,
+		LogFile:     w.LogFile,
+		Title:       w.Title,
+		Description: w.Description,
+		Tags:        w.Tags,
+		Priority:    w.Priority,
+		ParentID:    w.ParentID,
+
+		StatusReason:  w.StatusReason,
+		FailureReason: w.FailureReason,
+		Finished:      w.Finished,
+		Deleted:       w.Deleted,
+		Annotations:   w.Annotations,
+		Issue:         w.Issue,
+		Execution:     string(w.Execution),
+		Agent:         w.Agent,
+		Project:       w.ProjectName(),
+		Owner:         w.Owner,
+		PullRequest:   w.PullRequest,
+		AutoCommit:    w.AutoCommit,
+		Restart:       w.Restart,
+		Restarts:      w.Restarts,
+		AmpBinary:     w.AmpBinary,
+		AmpArgs:       w.AmpArgs,
+		Profile:       w.Profile,
+		Pool:          w.Pool,
+		Secrets:       w.Secrets,
+		Backend:       w.Backend,
+		Model:         w.Model,
+	}
+	if task.Backend == "" {
+		task.Backend = worker.DefaultBackend
+	}
+	for name := range w.Env {
+		task.EnvVars = append(task.EnvVars, name)
+	}
+	sort.Strings(task.EnvVars)
+	if w.PullRequest != nil {
+		task.CIStatus = string(w.PullRequest.CIStatus)
+	}
+
+	if tree == nil {
+		return task
+	}
+
+	if children := tree.Children(w.ID); len(children) > 0 {
+		task.ChildCount = len(children)
+		task.ChildStatusCounts = make(map[string]int)
+		for status, count := range tree.ChildStatusCounts(w.ID) {
+			task.ChildStatusCounts[string(status)] = count
+		}
+		task.AggregateStatus = string(tree.AggregateStatus(w.ID))
+	}
+
+	return task
+}
+
+// currentTask returns the task's current state, with its subtask rollup
+func (h *TaskHandler) currentTask(taskID string) (TaskDTO, bool) {
+	workers, err := h.manager.ListWorkers()
+	if err != nil {
+		return TaskDTO{}, false
+	}
+
+	tree := worker.NewHierarchyFromList(workers)
+	for _, worker := range workers {
+		if worker.ID == taskID {
+			return newTaskDTO(worker, tree), true
+		}
+	}
+	return TaskDTO{}, false
 }
 
 // broadcastTaskAfterStop gets the task and broadcasts its updated status
 func (h *TaskHandler) broadcastTaskAfterStop(taskID string) {
-	// Get the updated worker status
+	if task, ok := h.currentTask(taskID); ok {
+		h.broadcastTaskUpdate(task)
+	}
+}
+
+// BroadcastTaskUpdate broadcasts the current state of a task, e.g. after its worker exits
+func (h *TaskHandler) BroadcastTaskUpdate(taskID string) {
+	h.broadcastTaskAfterStop(taskID)
+}
+
+// DispatchTaskFinished sends a task-finished event for a task whose worker
+// exited to webhooks and email recipients, and comments on its GitHub issue
+// when configured. Stopping a task or transitioning
+// it to completed or failed ends its process, so every finish passes through
+// here exactly once.
+func (h *TaskHandler) DispatchTaskFinished(taskID string) {
 	workers, err := h.manager.ListWorkers()
 	if err != nil {
 		return
 	}
 
-	// Find the worker and broadcast its updated status
-	for _, worker := range workers {
-		if worker.ID == taskID {
-			task := TaskDTO{
-				ID:          worker.ID,
-				ThreadID:    worker.ThreadID,
-				Status:      string(worker.Status),
-				Started:     worker.Started,
-				LogFile:     worker.LogFile,
-				Title:       worker.Title,
-				Description: worker.Description,
-				Tags:        worker.Tags,
-				Priority:    worker.Priority,
-			}
-			h.broadcastTaskUpdate(task)
-			break
+	tree := worker.NewHierarchyFromList(workers)
+	for _, w := range workers {
+		if w.ID == taskID {
+			task := newTaskDTO(w, tree)
+			h.webhooks.Dispatch(webhook.Event{
+				Type:       "task-finished",
+				TaskID:     task.ID,
+				Task:       task,
+				Attributes: taskAttributes(task),
+			})
+			go h.commentOnIssue(task)
+			return
 		}
 	}
 }
 
+// cascadeRequested reports whether the request asked for an operation to
+// apply to the task's whole subtree
+func cascadeRequested(r *http.Request) bool {
+	cascade, _ := strconv.ParseBool(r.URL.Query().Get("cascade"))
+	return cascade
+}
+
 // BroadcastLogEvent sends a log event over WebSocket
 func (h *TaskHandler) BroadcastLogEvent(logLine worker.LogLine) {
 	if h.hub == nil {
@@ -86,7 +295,7 @@ func (h *TaskHandler) BroadcastLogEvent(logLine worker.LogLine) {
 		Data: LogData{
 			WorkerID:  logLine.WorkerID,
 			Timestamp: logLine.Timestamp,
-			Content:   logLine.Content,
+			Content:   ansi.Sanitize(logLine.Content),
 		},
 	}
 
@@ -96,6 +305,124 @@ func (h *TaskHandler) BroadcastLogEvent(logLine worker.LogLine) {
 		return
 	}
 
+	h.hub.BroadcastTaskEvent(logLine.WorkerID, TaskRoom(logLine.WorkerID), eventJSON)
+}
+
+// BroadcastContinueFinished notifies WebSocket clients that amp has answered
+// a message sent to a task, along with the task's current state
+func (h *TaskHandler) BroadcastContinueFinished(taskID string, attempt worker.Attempt) {
+	h.broadcastTaskAfterStop(taskID)
+	if h.hub == nil {
+		return
+	}
+
+	eventJSON, err := json.Marshal(ContinueFinishedEvent{
+		Type: "continue-finished",
+		Data: ContinueFinishedDTO{TaskID: taskID, Attempt: attempt},
+	})
+	if err != nil {
+		return
+	}
+	h.hub.BroadcastTaskEvent(taskID, TaskRoom(taskID), eventJSON)
+}
+
+// BroadcastStallEvent notifies WebSocket clients and webhooks that a task stalled
+func (h *TaskHandler) BroadcastStallEvent(stall worker.StallEvent) {
+	data := TaskStallDTO{
+		TaskID:      stall.WorkerID,
+		Action:      string(stall.Action),
+		Nudges:      stall.Nudges,
+		IdleSeconds: int(stall.IdleFor.Seconds()),
+	}
+
+	h.webhooks.Dispatch(webhook.Event{
+		Type:   "task-stalled",
+		TaskID: stall.WorkerID,
+		Data:   data,
+	})
+
+	// Escalation interrupts the worker, so clients also need its new status
+	if stall.Action == worker.StallEscalated {
+		h.broadcastTaskAfterStop(stall.WorkerID)
+	}
+
+	if h.hub == nil {
+		return
+	}
+
+	eventJSON, err := json.Marshal(TaskStalledEvent{
+		Type: "task-stalled",
+		Data: data,
+	})
+	if err != nil {
+		return
+	}
+
+	h.hub.BroadcastTaskEvent(stall.WorkerID, TaskRoom(stall.WorkerID), eventJSON)
+}
+
+// BroadcastQuotaEvent warns clients and webhooks that the log directory is
+// over its disk quota and new tasks are refused
+func (h *TaskHandler) BroadcastQuotaEvent(event worker.QuotaEvent) {
+	data := SystemEventDTO{
+		Event:   "disk-quota-exceeded",
+		Message: fmt.Sprintf("The log directory uses %d bytes, over its quota of %d; new tasks are refused", event.UsedBytes, event.LimitBytes),
+		Details: map[string]interface{}{
+			"used_bytes":  event.UsedBytes,
+			"limit_bytes": event.LimitBytes,
+		},
+	}
+
+	h.webhooks.Dispatch(webhook.Event{
+		Type: "disk-quota-exceeded",
+		Data: data,
+	})
+
+	if h.hub == nil {
+		return
+	}
+
+	eventJSON, err := json.Marshal(SystemEvent{
+		Type: "system",
+		Data: data,
+	})
+	if err != nil {
+		return
+	}
+
+	h.hub.Broadcast(eventJSON)
+}
+
+// BroadcastRateLimitEvent notifies clients and webhooks that amp invocations
+// are being delayed by the rate limiter
+func (h *TaskHandler) BroadcastRateLimitEvent(event worker.RateLimitEvent) {
+	data := SystemEventDTO{
+		Event:   "rate-limit-saturated",
+		Message: fmt.Sprintf("%s invocations exceed %d per minute and are being delayed", event.Kind, event.Limit),
+		Details: map[string]interface{}{
+			"kind":    event.Kind,
+			"limit":   event.Limit,
+			"waiting": event.Waiting,
+		},
+	}
+
+	h.webhooks.Dispatch(webhook.Event{
+		Type: "rate-limit-saturated",
+		Data: data,
+	})
+
+	if h.hub == nil {
+		return
+	}
+
+	eventJSON, err := json.Marshal(SystemEvent{
+		Type: "system",
+		Data: data,
+	})
+	if err != nil {
+		return
+	}
+
 	h.hub.Broadcast(eventJSON)
 }
 
@@ -106,40 +433,59 @@ func (h *TaskHandler) ListTasks(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return err
 	}
+	fields, err := parseFields(r)
+	if err != nil {
+		return err
+	}
+
+	// Answer the whole request from one snapshot so the page, total and
+	// hierarchy rollups all reflect the same state
+	snapshot, err := h.manager.Snapshot()
+	if err != nil {
+		return apierr.WrapInternal(err, "Failed to list tasks")
+	}
 
 	// Get filtered and sorted workers
-	workers, err := h.manager.ListWorkersWithFilter(
+	workers := snapshot.Filter(
 		taskQuery.Status,
 		taskQuery.StartedBefore,
 		taskQuery.StartedAfter,
+		worker.MetadataFilter{
+			Tags:       taskQuery.Tags,
+			Priorities: taskQuery.Priorities,
+			Text:       taskQuery.Q,
+		},
 		taskQuery.SortBy,
 		taskQuery.SortOrder,
 	)
-	if err != nil {
-		return apierr.WrapInternal(err, "Failed to list tasks")
+	var missing []string
+	if len(taskQuery.IDs) > 0 {
+		workers = filterIDs(workers, taskQuery.IDs)
+		missing = missingIDs(snapshot, taskQuery.IDs)
+	}
+	if taskQuery.Project != "" {
+		workers = filterProject(workers, taskQuery.Project)
+	}
+	if taskQuery.Owner != "" {
+		owner, err := h.resolveOwner(r, taskQuery.Owner)
+		if err != nil {
+			return err
+		}
+		workers = filterOwner(workers, owner)
 	}
 
 	// Apply cursor-based pagination
-	var paginatedWorkers []*worker.Worker
 	var startIndex int
-
 	if taskQuery.Cursor != "" {
 		cursorTime, cursorID, err := query.ParseCursor(taskQuery.Cursor)
 		if err != nil {
 			return err
 		}
-
-		// Find the starting position based on cursor
-		for i, w := range workers {
-			if w.Started.Equal(cursorTime) && w.ID == cursorID {
-				startIndex = i + 1
-				break
-			} else if (taskQuery.SortOrder == "desc" && w.Started.Before(cursorTime)) ||
-				(taskQuery.SortOrder == "asc" && w.Started.After(cursorTime)) {
-				startIndex = i
-				break
-			}
+		anchor := snapshot.Worker(cursorID)
+		if anchor == nil {
+			anchor = &worker.Worker{ID: cursorID, Started: cursorTime}
 		}
+		startIndex = cursorStart(workers, anchor, taskQuery.SortBy, taskQuery.SortOrder)
 	}
 
 	// Get the page of workers
@@ -147,22 +493,15 @@ func (h *TaskHandler) ListTasks(w http.ResponseWriter, r *http.Request) error {
 	if endIndex > len(workers) {
 		endIndex = len(workers)
 	}
-	paginatedWorkers = workers[startIndex:endIndex]
+	paginatedWorkers := workers[startIndex:endIndex]
+
+	// Build the hierarchy from all tasks so rollups include filtered-out children
+	tree := worker.NewHierarchyFromList(snapshot.Workers())
 
 	// Convert workers to DTOs
 	tasks := make([]TaskDTO, len(paginatedWorkers))
 	for i, worker := range paginatedWorkers {
-		tasks[i] = TaskDTO{
-			ID:          worker.ID,
-			ThreadID:    worker.ThreadID,
-			Status:      string(worker.Status),
-			Started:     worker.Started,
-			LogFile:     worker.LogFile,
-			Title:       worker.Title,
-			Description: worker.Description,
-			Tags:        worker.Tags,
-			Priority:    worker.Priority,
-		}
+		tasks[i] = newTaskDTO(worker, tree).withFields(fields)
 	}
 
 	// Prepare response
@@ -170,6 +509,7 @@ func (h *TaskHandler) ListTasks(w http.ResponseWriter, r *http.Request) error {
 		Tasks:   tasks,
 		HasMore: endIndex < len(workers),
 		Total:   len(workers),
+		Missing: missing,
 	}
 
 	// Generate next cursor if there are more results
@@ -178,353 +518,670 @@ func (h *TaskHandler) ListTasks(w http.ResponseWriter, r *http.Request) error {
 		resp.NextCursor = query.GenerateCursor(lastTask.ID, lastTask.Started)
 	}
 
-	return response.OK(w, resp)
+	// Pollers presenting the ETag of an unchanged page get a 304
+	return response.OKWithETag(w, r, resp)
+}
+
+// filterIDs returns the workers with one of the given IDs
+func filterIDs(workers []*worker.Worker, ids []string) []*worker.Worker {
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	filtered := make([]*worker.Worker, 0, len(ids))
+	for _, w := range workers {
+		if wanted[w.ID] {
+			filtered = append(filtered, w)
+		}
+	}
+	return filtered
+}
+
+// missingIDs returns the IDs that match no task in the snapshot
+func missingIDs(snapshot *worker.Snapshot, ids []string) []string {
+	var missing []string
+	for _, id := range ids {
+		if snapshot.Worker(id) == nil {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+// filterProject returns the workers belonging to project
+func filterProject(workers []*worker.Worker, project string) []*worker.Worker {
+	filtered := make([]*worker.Worker, 0, len(workers))
+	for _, w := range workers {
+		if w.ProjectName() == project {
+			filtered = append(filtered, w)
+		}
+	}
+	return filtered
+}
+
+// cursorStart returns the index of the first worker that sorts after the
+// cursor's task. The comparison is made on the task's sort key and ID rather
+// than its position, so pages stay stable when tasks are added or removed
+// between them. When the cursor's task was deleted, only its start time and
+// ID are known, which place it exactly when sorting by start time or ID.
+func cursorStart(workers []*worker.Worker, anchor *worker.Worker, sortBy, sortOrder string) int {
+	return sort.Search(len(workers), func(i int) bool {
+		return worker.Precedes(anchor, workers[i], sortBy, sortOrder)
+	})
+}
+
+// taskError maps a manager error to an API error, using action to describe
+// unexpected failures (e.g. "stop task")
+func taskError(err error, action string) error {
+	switch {
+	case errors.Is(err, worker.ErrRateLimited):
+		return apierr.Wrap(err, http.StatusTooManyRequests, "Rate limit exceeded, try again later").WithCode("rate_limited")
+	case errors.Is(err, worker.ErrDiskQuotaExceeded):
+		return apierr.Wrap(err, http.StatusInsufficientStorage, "The log directory is over its disk quota; new tasks can't be started until space is freed").WithCode("disk_quota_exceeded")
+	case errors.Is(err, worker.ErrContainerNotConfigured):
+		return apierr.Wrap(err, http.StatusBadRequest, "Container execution is not configured")
+	case errors.Is(err, worker.ErrRemoteNotConfigured):
+		return apierr.Wrap(err, http.StatusBadRequest, "Remote execution is not configured")
+	case errors.Is(err, worker.ErrSecretsNotConfigured):
+		return apierr.Wrap(err, http.StatusBadRequest, "Secrets are not enabled")
+	case errors.Is(err, secrets.ErrNotFound):
+		return apierr.Wrap(err, http.StatusBadRequest, err.Error())
+	case errors.Is(err, worker.ErrWorkerRunning):
+		return apierr.Wrap(err, http.StatusConflict, "Task is running; delete it with ?force=true").WithCode("task_running")
+	case errors.Is(err, worker.ErrUnknownProfile):
+		return apierr.Wrap(err, http.StatusBadRequest, "Unknown amp profile")
+	case errors.Is(err, worker.ErrUnknownBackend), errors.Is(err, worker.ErrUnknownModel), errors.Is(err, worker.ErrBackendNotSupported):
+		return apierr.Wrap(err, http.StatusBadRequest, err.Error())
+	case errors.Is(err, worker.ErrPreStartHookFailed):
+		reason := strings.TrimPrefix(err.Error(), worker.ErrPreStartHookFailed.Error()+": ")
+		return apierr.Wrap(err, http.StatusUnprocessableEntity, "Task refused by a pre-start hook: "+reason).WithCode("pre_start_hook_failed")
+	case errors.Is(err, worker.ErrUnknownPool):
+		return apierr.Wrap(err, http.StatusBadRequest, "Unknown worker pool")
+	case errors.Is(err, worker.ErrPoolFull):
+		return apierr.Wrap(err, http.StatusServiceUnavailable, "Worker pool is full, try again later").WithCode("pool_full")
+	case errors.Is(err, worker.ErrTooManyWorkers):
+		return apierr.Wrap(err, http.StatusServiceUnavailable, "Too many tasks running, try again later").WithCode("max_workers_reached")
+	case errors.Is(err, worker.ErrNoAgentAvailable):
+		return apierr.Wrap(err, http.StatusServiceUnavailable, "No remote agent available, try again later").WithCode("no_agent_available")
+	case errors.Is(err, worker.ErrMessageNotFound):
+		return apierr.Wrap(err, http.StatusNotFound, "Thread message not found")
+	case errors.Is(err, worker.ErrWorkerNotFound):
+		return apierr.Wrap(err, http.StatusNotFound, "Task not found")
+	case errors.Is(err, worker.ErrNotRunning):
+		return apierr.Wrap(err, http.StatusConflict, "Task is not running")
+	case errors.Is(err, worker.ErrInvalidTransition):
+		return apierr.Wrap(err, http.StatusConflict, strings.TrimPrefix(err.Error(), worker.ErrInvalidTransition.Error()+": "))
+	default:
+		return apierr.WrapInternalf(err, "Failed to %s", action)
+	}
 }
 
 // StartTask creates and starts a new task
-func (h *TaskHandler) StartTask(w http.ResponseWriter, r *http.Request) {
+func (h *TaskHandler) StartTask(w http.ResponseWriter, r *http.Request) error {
 	var req StartTaskRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON request body", http.StatusBadRequest)
-		return
+		return apierr.BadRequest("Invalid JSON request body")
 	}
 
 	if req.Message == "" {
-		http.Error(w, "Message is required", http.StatusBadRequest)
-		return
+		return apierr.BadRequest("Message is required")
 	}
-
-	// Start the worker
-	if err := h.manager.StartWorker(req.Message); err != nil {
-		http.Error(w, "Failed to start task", http.StatusInternalServerError)
-		return
+	priority, err := validateMetadata(&req.Title, &req.Priority, req.Tags)
+	if err != nil {
+		return err
 	}
+	req.Priority = *priority
 
-	// Get the latest workers to find the one we just created
-	workers, err := h.manager.ListWorkers()
-	if err != nil {
-		http.Error(w, "Failed to retrieve created task", http.StatusInternalServerError)
-		return
+	execution := worker.ExecutionMode(req.Execution)
+	switch execution {
+	case "", worker.ExecutionHost, worker.ExecutionContainer, worker.ExecutionRemote:
+	default:
+		return apierr.BadRequestf("Invalid execution mode: %s", req.Execution)
 	}
 
-	// Find the most recently started worker (the one we just created)
-	var latestWorker *worker.Worker
-	for _, w := range workers {
-		if latestWorker == nil || w.Started.After(latestWorker.Started) {
-			latestWorker = w
+	opts := worker.StartOptions{
+		Title:       req.Title,
+		Description: req.Description,
+		Tags:        req.Tags,
+		Priority:    req.Priority,
+		ParentID:    req.ParentID,
+		Execution:   execution,
+		Project:     req.Project,
+		AutoCommit:  req.AutoCommit,
+		Restart:     req.Restart,
+		AmpBinary:   req.AmpBinary,
+		AmpArgs:     req.AmpArgs,
+		Profile:     req.Profile,
+		Env:         req.Env,
+		Secrets:     req.Secrets,
+		Backend:     req.Backend,
+		Model:       req.Model,
+		Pool:        req.Pool,
+	}
+	if identity := h.authenticate.Identify(r); identity != nil {
+		opts.Owner = identity.User
+	}
+	if req.Issue != nil {
+		source, err := issueFromRequest(req.Issue, h.issues.GitHubRepo)
+		if err != nil {
+			return err
 		}
+		opts.Issue = source.Link()
+		opts.IssueFields = h.issues.Mapping.Fields(*source)
 	}
 
-	if latestWorker == nil {
-		http.Error(w, "Failed to find created task", http.StatusInternalServerError)
-		return
+	// Start the worker
+	latestWorker, err := h.manager.StartWorkerWithOptions(r.Context(), req.Message, opts)
+	if err != nil {
+		if errors.Is(err, worker.ErrParentNotFound) {
+			return apierr.Wrap(err, http.StatusBadRequest, "Parent task not found")
+		}
+		if errors.Is(err, worker.ErrProjectNotFound) {
+			return apierr.Wrap(err, http.StatusBadRequest, "Project not found")
+		}
+		if errors.Is(err, worker.ErrAmpOverrideNotAllowed) || errors.Is(err, worker.ErrEnvNotAllowed) || errors.Is(err, worker.ErrInvalidRestartPolicy) {
+			return apierr.Wrap(err, http.StatusBadRequest, err.Error())
+		}
+		return taskError(err, "start task")
 	}
 
 	// Convert to DTO and return
-	task := TaskDTO{
-		ID:          latestWorker.ID,
-		ThreadID:    latestWorker.ThreadID,
-		Status:      string(latestWorker.Status),
-		Started:     latestWorker.Started,
-		LogFile:     latestWorker.LogFile,
-		Title:       latestWorker.Title,
-		Description: latestWorker.Description,
-		Tags:        latestWorker.Tags,
-		Priority:    latestWorker.Priority,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(task); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-		return
+	task := newTaskDTO(latestWorker, nil)
+
+	if err := response.Created(w, task); err != nil {
+		return err
 	}
 
-	// Broadcast task update event
+	// Announce the new task; task-update is kept for clients that only follow updates
+	h.broadcastTaskCreated(task)
 	h.broadcastTaskUpdate(task)
+	return nil
 }
 
 // StopTask stops a running task
-func (h *TaskHandler) StopTask(w http.ResponseWriter, r *http.Request) {
+func (h *TaskHandler) StopTask(w http.ResponseWriter, r *http.Request) error {
 	taskID := chi.URLParam(r, "id")
 	if taskID == "" {
-		http.Error(w, "Task ID is required", http.StatusBadRequest)
-		return
+		return apierr.BadRequest("Task ID is required")
 	}
 
-	err := h.manager.StopWorker(taskID)
-	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			http.Error(w, "Task not found", http.StatusNotFound)
-			return
+	if err := h.requireTaskControl(r, taskID, cascadeRequested(r), "stop"); err != nil {
+		return err
+	}
+
+	if cascadeRequested(r) {
+		// Members stopped before one failed are stopped all the same
+		stopped, err := h.manager.StopWorkerTree(taskID)
+		for _, id := range stopped {
+			h.broadcastTaskAfterStop(id)
 		}
-		if strings.Contains(err.Error(), "not running") {
-			http.Error(w, "Task is not running", http.StatusConflict)
-			return
+		if err != nil {
+			return taskError(err, "stop task")
 		}
-		http.Error(w, "Failed to stop task", http.StatusInternalServerError)
-		return
+
+		w.WriteHeader(http.StatusAccepted)
+		return nil
+	}
+
+	if err := h.manager.StopWorker(taskID); err != nil {
+		return taskError(err, "stop task")
 	}
 
 	w.WriteHeader(http.StatusAccepted)
 
 	// Broadcast task update after stopping
 	h.broadcastTaskAfterStop(taskID)
+	return nil
 }
 
 // ContinueTask sends a message to a running task
-func (h *TaskHandler) ContinueTask(w http.ResponseWriter, r *http.Request) {
+func (h *TaskHandler) ContinueTask(w http.ResponseWriter, r *http.Request) error {
 	taskID := chi.URLParam(r, "id")
 	if taskID == "" {
-		http.Error(w, "Task ID is required", http.StatusBadRequest)
-		return
+		return apierr.BadRequest("Task ID is required")
 	}
 
 	var req StartTaskRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON request body", http.StatusBadRequest)
-		return
+		return apierr.BadRequest("Invalid JSON request body")
 	}
 
 	if req.Message == "" {
-		http.Error(w, "Message is required", http.StatusBadRequest)
-		return
+		return apierr.BadRequest("Message is required")
 	}
 
-	err := h.manager.ContinueWorker(taskID, req.Message)
+	attempt, err := h.manager.QueueContinue(taskID, req.Message)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			http.Error(w, "Task not found", http.StatusNotFound)
-			return
-		}
-		if strings.Contains(err.Error(), "not running") {
-			http.Error(w, "Task is not running", http.StatusConflict)
-			return
-		}
-		http.Error(w, "Failed to continue task", http.StatusInternalServerError)
-		return
+		return taskError(err, "continue task")
 	}
 
-	w.WriteHeader(http.StatusAccepted)
+	// amp answers in the background; continue-finished reports when it's done
+	return response.JSON(w, http.StatusAccepted, ContinueTaskResponse{Attempt: attempt})
 }
 
 // InterruptTask interrupts a running task with SIGINT
-func (h *TaskHandler) InterruptTask(w http.ResponseWriter, r *http.Request) {
+func (h *TaskHandler) InterruptTask(w http.ResponseWriter, r *http.Request) error {
 	workerID := chi.URLParam(r, "id")
-	
+
+	if err := h.requireTaskControl(r, workerID, false, "interrupt"); err != nil {
+		return err
+	}
+
 	if err := h.manager.InterruptWorker(workerID); err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			http.Error(w, "Task not found", http.StatusNotFound)
-			return
-		}
-		if strings.Contains(err.Error(), "cannot interrupt") {
-			http.Error(w, err.Error(), http.StatusConflict)
-			return
-		}
-		http.Error(w, "Failed to interrupt task", http.StatusInternalServerError)
-		return
+		return taskError(err, "interrupt task")
 	}
 
 	// Broadcast the task update after interrupting
 	h.broadcastTaskAfterStop(workerID)
 
 	w.WriteHeader(http.StatusAccepted)
+	return nil
+}
+
+// maxWindDownTimeout bounds how long a winding-down task may keep running
+const maxWindDownTimeout = time.Hour
+
+// WindDownTask sends a running task a final instruction to summarize its
+// state, commit its work and stop. The task is interrupted if it's still
+// running at the deadline, and amp's response is recorded as its checkpoint
+// annotation.
+func (h *TaskHandler) WindDownTask(w http.ResponseWriter, r *http.Request) error {
+	workerID := chi.URLParam(r, "id")
+
+	if err := h.requireTaskControl(r, workerID, false, "wind down"); err != nil {
+		return err
+	}
+
+	// An empty body uses the default instruction and deadline
+	var req WindDownTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		return apierr.BadRequest("Invalid JSON request body")
+	}
+	timeout := time.Duration(req.TimeoutSeconds) * time.Second
+	if timeout < 0 || timeout > maxWindDownTimeout {
+		return apierr.BadRequestf("timeout_seconds must be between 0 and %d", int(maxWindDownTimeout.Seconds()))
+	}
+
+	done, err := h.manager.WindDownWorker(workerID, worker.WindDownOptions{
+		Message: req.Message,
+		Timeout: timeout,
+	})
+	if err != nil {
+		return taskError(err, "wind down task")
+	}
+
+	// Broadcast the pending checkpoint now and the outcome once it's recorded
+	h.broadcastTaskAfterStop(workerID)
+	go func() {
+		<-done
+		h.broadcastTaskAfterStop(workerID)
+	}()
+
+	w.WriteHeader(http.StatusAccepted)
+	return nil
 }
 
 // AbortTask forcefully terminates a task with SIGKILL
-func (h *TaskHandler) AbortTask(w http.ResponseWriter, r *http.Request) {
+func (h *TaskHandler) AbortTask(w http.ResponseWriter, r *http.Request) error {
 	workerID := chi.URLParam(r, "id")
-	
-	if err := h.manager.AbortWorker(workerID); err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			http.Error(w, "Task not found", http.StatusNotFound)
-			return
+
+	if err := h.requireTaskControl(r, workerID, cascadeRequested(r), "abort"); err != nil {
+		return err
+	}
+
+	if cascadeRequested(r) {
+		aborted, err := h.manager.AbortWorkerTree(workerID)
+		if err != nil {
+			return taskError(err, "abort task")
 		}
-		if strings.Contains(err.Error(), "cannot abort") {
-			http.Error(w, err.Error(), http.StatusConflict)
-			return
+
+		for _, id := range aborted {
+			h.broadcastTaskAfterStop(id)
 		}
-		http.Error(w, "Failed to abort task", http.StatusInternalServerError)
-		return
+
+		w.WriteHeader(http.StatusAccepted)
+		return nil
+	}
+
+	if err := h.manager.AbortWorker(workerID); err != nil {
+		return taskError(err, "abort task")
 	}
 
 	// Broadcast the task update after aborting
 	h.broadcastTaskAfterStop(workerID)
 
 	w.WriteHeader(http.StatusAccepted)
+	return nil
 }
 
 // RetryTask restarts a task with a new message
-func (h *TaskHandler) RetryTask(w http.ResponseWriter, r *http.Request) {
+func (h *TaskHandler) RetryTask(w http.ResponseWriter, r *http.Request) error {
 	workerID := chi.URLParam(r, "id")
-	
+
+	if err := h.requireTaskControl(r, workerID, false, "retry"); err != nil {
+		return err
+	}
+
 	var req struct {
 		Message string `json:"message"`
 	}
-	
+
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
-		return
+		return apierr.BadRequest("Invalid JSON body")
 	}
-	
+
 	if req.Message == "" {
-		http.Error(w, "Message is required", http.StatusBadRequest)
-		return
+		return apierr.BadRequest("Message is required")
 	}
-	
-	if err := h.manager.RetryWorker(workerID, req.Message); err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			http.Error(w, "Task not found", http.StatusNotFound)
-			return
-		}
-		if strings.Contains(err.Error(), "cannot retry") {
-			http.Error(w, err.Error(), http.StatusConflict)
-			return
-		}
-		http.Error(w, "Failed to retry task", http.StatusInternalServerError)
-		return
+
+	if err := h.manager.RetryWorker(r.Context(), workerID, req.Message); err != nil {
+		return taskError(err, "retry task")
 	}
 
 	// Broadcast the task update after retrying
 	h.broadcastTaskAfterStop(workerID)
 
 	w.WriteHeader(http.StatusAccepted)
+	return nil
 }
 
 // PatchTask updates task metadata
-func (h *TaskHandler) PatchTask(w http.ResponseWriter, r *http.Request) {
+func (h *TaskHandler) PatchTask(w http.ResponseWriter, r *http.Request) error {
 	workerID := chi.URLParam(r, "id")
-	
+
 	var req PatchTaskRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
-		return
+		return apierr.BadRequest("Invalid JSON body")
 	}
-	
-	if err := h.manager.UpdateWorkerMetadata(workerID, req.Title, req.Description, req.Priority, req.Tags); err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			http.Error(w, "Task not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Failed to update task", http.StatusInternalServerError)
-		return
+	priority, err := validateMetadata(req.Title, req.Priority, req.Tags)
+	if err != nil {
+		return err
+	}
+
+	if err := h.manager.UpdateWorkerMetadata(workerID, req.Title, req.Description, priority, req.Tags); err != nil {
+		return taskError(err, "update task")
 	}
 
 	// Broadcast the task update after patching
+	task, ok := h.currentTask(workerID)
+	if !ok {
+		return apierr.NotFound("Task not found")
+	}
+	h.broadcastTaskUpdate(task)
+
+	return response.OK(w, task)
+}
+
+// Limits on task metadata, so titles and tags stay displayable
+const (
+	maxTitleLength = 200
+	maxTags        = 20
+	maxTagLength   = 50
+)
+
+// validPriorities are the task priorities, from lowest to highest
+var validPriorities = []string{"low", "medium", "high"}
+
+// validateMetadata checks the metadata set on a task, returning the priority
+// lower-cased. Nil fields aren't being set, and an empty priority clears it.
+func validateMetadata(title, priority *string, tags []string) (*string, error) {
+	if title != nil && utf8.RuneCountInString(*title) > maxTitleLength {
+		return nil, apierr.BadRequestf("Title must be at most %d characters", maxTitleLength)
+	}
+	if len(tags) > maxTags {
+		return nil, apierr.BadRequestf("At most %d tags are allowed", maxTags)
+	}
+	for _, tag := range tags {
+		if strings.TrimSpace(tag) == "" {
+			return nil, apierr.BadRequest("Tags must not be empty")
+		}
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, apierr.BadRequestf("Tags must be at most %d characters: %s", maxTagLength, tag)
+		}
+	}
+	if priority == nil || *priority == "" {
+		return priority, nil
+	}
+	normalized := strings.ToLower(*priority)
+	for _, valid := range validPriorities {
+		if normalized == valid {
+			return &normalized, nil
+		}
+	}
+	return nil, apierr.BadRequestf("Invalid priority: %s (must be one of %s)", *priority, strings.Join(validPriorities, ", "))
+}
+
+// AnnotateTask attaches a status annotation from an external system, such as
+// a CI run or deployment, replacing any earlier annotation with the same key
+func (h *TaskHandler) AnnotateTask(w http.ResponseWriter, r *http.Request) error {
+	workerID := chi.URLParam(r, "id")
+
+	var req AnnotateTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return apierr.BadRequest("Invalid JSON request body")
+	}
+	if req.Key == "" {
+		return apierr.BadRequest("Key is required")
+	}
+	status := worker.AnnotationStatus(req.Status)
+	if !status.Valid() {
+		return apierr.BadRequestf("Invalid status: %s", req.Status)
+	}
+
+	annotation, err := h.manager.Annotate(workerID, worker.Annotation{
+		Key:     req.Key,
+		Status:  status,
+		Summary: req.Summary,
+		URL:     req.URL,
+		Data:    req.Data,
+	})
+	if err != nil {
+		return taskError(err, "annotate task")
+	}
+
 	h.broadcastTaskAfterStop(workerID)
 
-	w.WriteHeader(http.StatusOK)
+	return response.Created(w, annotation)
+}
+
+// TransitionTask moves a task to a new status and applies metadata changes in
+// one step, so clients never observe a partially applied update
+func (h *TaskHandler) TransitionTask(w http.ResponseWriter, r *http.Request) error {
+	workerID := chi.URLParam(r, "id")
+
+	if err := h.requireTaskControl(r, workerID, false, "transition"); err != nil {
+		return err
+	}
+
+	var req TransitionTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return apierr.BadRequest("Invalid JSON request body")
+	}
+
+	status := worker.WorkerStatus(req.Status)
+	if _, known := worker.AllowedTransitions[status]; !known {
+		return apierr.BadRequestf("Invalid status: %s", req.Status)
+	}
+	if status == worker.StatusRunning && req.Message == "" {
+		return apierr.BadRequest("Message is required to transition to running")
+	}
+	priority, err := validateMetadata(req.Title, req.Priority, req.Tags)
+	if err != nil {
+		return err
+	}
+
+	updated, err := h.manager.TransitionWorker(r.Context(), workerID, worker.Transition{
+		Status:  status,
+		Reason:  req.Reason,
+		Message: req.Message,
+		Metadata: worker.MetadataUpdate{
+			Title:       req.Title,
+			Description: req.Description,
+			Priority:    priority,
+			Tags:        req.Tags,
+		},
+	})
+	if err != nil {
+		return taskError(err, "transition task")
+	}
+
+	task := newTaskDTO(updated, nil)
+	h.broadcastTaskUpdate(task)
+
+	return response.OK(w, task)
 }
 
 // DeleteTask removes a task completely
-func (h *TaskHandler) DeleteTask(w http.ResponseWriter, r *http.Request) {
+func (h *TaskHandler) DeleteTask(w http.ResponseWriter, r *http.Request) error {
 	workerID := chi.URLParam(r, "id")
-	
-	if err := h.manager.DeleteWorker(workerID); err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			http.Error(w, "Task not found", http.StatusNotFound)
-			return
+
+	if err := h.requireTaskControl(r, workerID, cascadeRequested(r), "delete"); err != nil {
+		return err
+	}
+
+	// Capture the tasks' final state for the task-deleted events
+	final := make(map[string]TaskDTO)
+	if workers, err := h.manager.ListWorkers(); err == nil {
+		tree := worker.NewHierarchyFromList(workers)
+		for _, worker := range workers {
+			final[worker.ID] = newTaskDTO(worker, tree)
 		}
-		http.Error(w, "Failed to delete task", http.StatusInternalServerError)
-		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+	deleteWorker := func(id string, force bool) ([]string, error) {
+		if err := h.manager.DeleteWorker(id, force); err != nil {
+			return nil, err
+		}
+		return []string{id}, nil
+	}
+	if cascadeRequested(r) {
+		deleteWorker = h.manager.DeleteWorkerTree
+	}
+
+	// Members deleted before one failed are deleted all the same
+	deleted, err := deleteWorker(workerID, force)
+	for _, id := range deleted {
+		task, ok := final[id]
+		if !ok {
+			task = TaskDTO{ID: id}
+		}
+		h.broadcastTaskDeleted(task)
+	}
+	if err != nil {
+		return taskError(err, "delete task")
+	}
+
+	response.NoContent(w)
+	return nil
 }
 
-// Git operation stub endpoints - these return 202 + TODO for now
+// ListTrash returns the deleted tasks that can still be restored, most
+// recently deleted first
+func (h *TaskHandler) ListTrash(w http.ResponseWriter, r *http.Request) error {
+	workers, err := h.manager.ListTrash()
+	if err != nil {
+		return apierr.WrapInternal(err, "Failed to get trash")
+	}
 
-// MergeTask creates a merge request/PR for the task's changes
-func (h *TaskHandler) MergeTask(w http.ResponseWriter, r *http.Request) {
+	resp := TrashResponse{Tasks: make([]TaskDTO, 0, len(workers))}
+	for _, w := range workers {
+		resp.Tasks = append(resp.Tasks, newTaskDTO(w, nil))
+	}
+	return response.OK(w, resp)
+}
+
+// RestoreTask moves a task and its subtasks deleted along with it out of the
+// trash
+func (h *TaskHandler) RestoreTask(w http.ResponseWriter, r *http.Request) error {
 	workerID := chi.URLParam(r, "id")
-	
-	// Verify task exists
+
+	if err := h.requireTrashControl(r, workerID, "restore"); err != nil {
+		return err
+	}
+
+	restored, err := h.manager.RestoreWorker(workerID)
+	if err != nil {
+		return taskError(err, "restore task")
+	}
+
+	resp := TrashResponse{Tasks: make([]TaskDTO, 0, len(restored))}
+	for _, w := range restored {
+		task, ok := h.currentTask(w.ID)
+		if !ok {
+			task = newTaskDTO(w, nil)
+		}
+		h.broadcastTaskUpdate(task)
+		resp.Tasks = append(resp.Tasks, task)
+	}
+	return response.OK(w, resp)
+}
+
+// Git operation stub endpoints - these return 202 + TODO for now
+
+// requireTask returns a not found error unless the task exists
+func (h *TaskHandler) requireTask(workerID string) error {
 	workers, err := h.manager.ListWorkers()
 	if err != nil {
-		http.Error(w, "Failed to get tasks", http.StatusInternalServerError)
-		return
+		return apierr.WrapInternal(err, "Failed to get tasks")
 	}
-	
-	found := false
+
 	for _, worker := range workers {
 		if worker.ID == workerID {
-			found = true
-			break
+			return nil
 		}
 	}
-	
-	if !found {
-		http.Error(w, "Task not found", http.StatusNotFound)
-		return
+
+	return apierr.NotFound("Task not found")
+}
+
+// MergeTask creates a merge request/PR for the task's changes
+func (h *TaskHandler) MergeTask(w http.ResponseWriter, r *http.Request) error {
+	workerID := chi.URLParam(r, "id")
+	if err := h.requireTask(workerID); err != nil {
+		return err
+	}
+	if err := h.requirePassingChecks(workerID); err != nil {
+		return err
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusAccepted)
-	json.NewEncoder(w).Encode(map[string]string{
+	return response.Accepted(w, map[string]string{
 		"message": "TODO: Git merge operation not yet implemented",
 		"status":  "accepted",
 	})
 }
 
 // DeleteBranchTask deletes the git branch associated with the task
-func (h *TaskHandler) DeleteBranchTask(w http.ResponseWriter, r *http.Request) {
-	workerID := chi.URLParam(r, "id")
-	
-	// Verify task exists
-	workers, err := h.manager.ListWorkers()
-	if err != nil {
-		http.Error(w, "Failed to get tasks", http.StatusInternalServerError)
-		return
-	}
-	
-	found := false
-	for _, worker := range workers {
-		if worker.ID == workerID {
-			found = true
-			break
-		}
-	}
-	
-	if !found {
-		http.Error(w, "Task not found", http.StatusNotFound)
-		return
+func (h *TaskHandler) DeleteBranchTask(w http.ResponseWriter, r *http.Request) error {
+	if err := h.requireTask(chi.URLParam(r, "id")); err != nil {
+		return err
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusAccepted)
-	json.NewEncoder(w).Encode(map[string]string{
+	return response.Accepted(w, map[string]string{
 		"message": "TODO: Git branch deletion not yet implemented",
 		"status":  "accepted",
 	})
 }
 
-// CreatePRTask creates a pull request for the task's changes
-func (h *TaskHandler) CreatePRTask(w http.ResponseWriter, r *http.Request) {
+// CreatePRTask creates a pull request for the task's changes. Until that is
+// implemented, a pull request opened by other means can be linked to track
+// its CI checks.
+func (h *TaskHandler) CreatePRTask(w http.ResponseWriter, r *http.Request) error {
 	workerID := chi.URLParam(r, "id")
-	
-	// Verify task exists
-	workers, err := h.manager.ListWorkers()
-	if err != nil {
-		http.Error(w, "Failed to get tasks", http.StatusInternalServerError)
-		return
+	if err := h.requireTask(workerID); err != nil {
+		return err
 	}
-	
-	found := false
-	for _, worker := range workers {
-		if worker.ID == workerID {
-			found = true
-			break
-		}
+
+	var req CreatePRRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		return apierr.BadRequest("Invalid JSON in request body")
 	}
-	
-	if !found {
-		http.Error(w, "Task not found", http.StatusNotFound)
-		return
+	if req.Number != 0 || req.Repo != "" {
+		return h.linkPullRequest(w, workerID, req)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusAccepted)
-	json.NewEncoder(w).Encode(map[string]string{
+	return response.Accepted(w, map[string]string{
 		"message": "TODO: Create pull request operation not yet implemented",
 		"status":  "accepted",
 	})
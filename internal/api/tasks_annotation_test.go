@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	errormw "github.com/brettsmith212/amp-orchestrator-2/internal/middleware"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+func annotateRequest(handler *TaskHandler, id, body string) *httptest.ResponseRecorder {
+	req := withTaskID(httptest.NewRequest(http.MethodPost, "/api/tasks/"+id+"/annotations", strings.NewReader(body)), id)
+	w := httptest.NewRecorder()
+	errormw.Error(handler.AnnotateTask)(w, req)
+	return w
+}
+
+func TestAnnotateTask_ReplacesAnnotationWithSameKey(t *testing.T) {
+	handler, manager := setupHierarchyHandler(t)
+
+	w := annotateRequest(handler, "parent", `{"key":"ci","status":"pending","url":"https://ci.example.com/runs/1"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var annotation worker.Annotation
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &annotation))
+	assert.Equal(t, worker.AnnotationPending, annotation.Status)
+	assert.False(t, annotation.UpdatedAt.IsZero())
+
+	w = annotateRequest(handler, "parent", `{"key":"ci","status":"failure","summary":"3 tests failed","data":{"failed":3}}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = annotateRequest(handler, "parent", `{"key":"security-scan","status":"success"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	saved := findWorker(t, manager, "parent")
+	require.Len(t, saved.Annotations, 2)
+	assert.Equal(t, "ci", saved.Annotations[0].Key)
+	assert.Equal(t, worker.AnnotationFailure, saved.Annotations[0].Status)
+	assert.Equal(t, "3 tests failed", saved.Annotations[0].Summary)
+	assert.Equal(t, float64(3), saved.Annotations[0].Data["failed"])
+	assert.Equal(t, "security-scan", saved.Annotations[1].Key)
+
+	// Every annotation is recorded in the task's thread
+	messages, err := manager.GetThreadMessages("parent", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, messages, 3)
+	assert.Equal(t, worker.MessageTypeAnnotation, messages[1].Type)
+	assert.Equal(t, "ci: failure - 3 tests failed", messages[1].Content)
+}
+
+func TestAnnotateTask_Validation(t *testing.T) {
+	handler, _ := setupHierarchyHandler(t)
+
+	tests := []struct {
+		name     string
+		id       string
+		body     string
+		expected int
+	}{
+		{"invalid json", "parent", `{`, http.StatusBadRequest},
+		{"missing key", "parent", `{"status":"success"}`, http.StatusBadRequest},
+		{"unknown status", "parent", `{"key":"ci","status":"passed"}`, http.StatusBadRequest},
+		{"missing task", "nonexistent", `{"key":"ci","status":"success"}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := annotateRequest(handler, tt.id, tt.body)
+			assert.Equal(t, tt.expected, w.Code, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub/hubtest"
+	errormw "github.com/brettsmith212/amp-orchestrator-2/internal/middleware"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+func setupHierarchyHandler(t *testing.T) (*TaskHandler, *worker.Manager) {
+	tempDir := t.TempDir()
+	manager := worker.NewManager(tempDir)
+	h := hub.NewHub()
+	hubtest.Run(t, h)
+	handler := NewTaskHandler(manager, h)
+
+	base := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	mockWorkers := map[string]*worker.Worker{
+		"parent": {
+			ID:       "parent",
+			ThreadID: "T-parent",
+			PID:      999997,
+			Started:  base,
+			Status:   worker.StatusStopped,
+		},
+		"child1": {
+			ID:       "child1",
+			ThreadID: "T-child1",
+			PID:      999998,
+			Started:  base.Add(time.Minute),
+			Status:   worker.StatusFailed,
+			ParentID: "parent",
+		},
+		"child2": {
+			ID:       "child2",
+			ThreadID: "T-child2",
+			PID:      999999,
+			Started:  base.Add(2 * time.Minute),
+			Status:   worker.StatusCompleted,
+			ParentID: "parent",
+		},
+	}
+
+	err := manager.SaveWorkersForTest(mockWorkers, filepath.Join(tempDir, "workers.json"))
+	require.NoError(t, err)
+
+	return handler, manager
+}
+
+func withTaskID(req *http.Request, id string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, &chi.Context{
+		URLParams: chi.RouteParams{
+			Keys:   []string{"id"},
+			Values: []string{id},
+		},
+	}))
+}
+
+func TestListTasks_HierarchyRollup(t *testing.T) {
+	handler, _ := setupHierarchyHandler(t)
+
+	req := httptest.NewRequest("GET", "/api/tasks", nil)
+	w := httptest.NewRecorder()
+
+	err := handler.ListTasks(w, req)
+	require.NoError(t, err)
+
+	var response PaginatedTasksResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	tasks := make(map[string]TaskDTO)
+	for _, task := range response.Tasks {
+		tasks[task.ID] = task
+	}
+
+	parent := tasks["parent"]
+	assert.Equal(t, 2, parent.ChildCount)
+	assert.Equal(t, map[string]int{"failed": 1, "completed": 1}, parent.ChildStatusCounts)
+	assert.Equal(t, "failed", parent.AggregateStatus)
+
+	child := tasks["child1"]
+	assert.Equal(t, "parent", child.ParentID)
+	assert.Zero(t, child.ChildCount)
+	assert.Empty(t, child.AggregateStatus)
+}
+
+func TestListTasks_HierarchyRollupIgnoresFilter(t *testing.T) {
+	handler, _ := setupHierarchyHandler(t)
+
+	req := httptest.NewRequest("GET", "/api/tasks?status=stopped", nil)
+	w := httptest.NewRecorder()
+
+	err := handler.ListTasks(w, req)
+	require.NoError(t, err)
+
+	var response PaginatedTasksResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Tasks, 1)
+	assert.Equal(t, 2, response.Tasks[0].ChildCount)
+}
+
+func TestAbortTask_Cascade(t *testing.T) {
+	handler, manager := setupHierarchyHandler(t)
+
+	req := withTaskID(httptest.NewRequest("POST", "/api/tasks/parent/abort?cascade=true", nil), "parent")
+	w := httptest.NewRecorder()
+
+	errormw.Error(handler.AbortTask)(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	workers, err := manager.ListWorkers()
+	require.NoError(t, err)
+	statuses := make(map[string]worker.WorkerStatus)
+	for _, w := range workers {
+		statuses[w.ID] = w.Status
+	}
+	assert.Equal(t, worker.StatusAborted, statuses["parent"])
+	// Failed and completed tasks cannot be aborted and are left alone
+	assert.Equal(t, worker.StatusFailed, statuses["child1"])
+	assert.Equal(t, worker.StatusCompleted, statuses["child2"])
+}
+
+func TestStopTask_CascadeNotRunning(t *testing.T) {
+	handler, _ := setupHierarchyHandler(t)
+
+	req := withTaskID(httptest.NewRequest("POST", "/api/tasks/parent/stop?cascade=true", nil), "parent")
+	w := httptest.NewRecorder()
+
+	errormw.Error(handler.StopTask)(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestDeleteTask_Cascade(t *testing.T) {
+	handler, manager := setupHierarchyHandler(t)
+
+	req := withTaskID(httptest.NewRequest("DELETE", "/api/tasks/parent?cascade=true", nil), "parent")
+	w := httptest.NewRecorder()
+
+	errormw.Error(handler.DeleteTask)(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	workers, err := manager.ListWorkers()
+	require.NoError(t, err)
+	assert.Empty(t, workers)
+}
+
+func TestDeleteTask_TrashAndRestore(t *testing.T) {
+	handler, manager := setupHierarchyHandler(t)
+	manager.SetTrashRetention(time.Hour)
+	router := NewRouter(handler, handler.hub)
+
+	w := serve(router, "DELETE", "/api/tasks/parent?cascade=true", "")
+	require.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, "GET", "/api/tasks/parent/logs", "").Code)
+
+	w = serve(router, "GET", "/api/trash", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var trash TrashResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &trash))
+	require.Len(t, trash.Tasks, 3)
+	assert.NotNil(t, trash.Tasks[0].Deleted)
+
+	w = serve(router, "POST", "/api/tasks/parent/restore", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var restored TrashResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &restored))
+	assert.Len(t, restored.Tasks, 3)
+
+	workers, err := manager.ListWorkers()
+	require.NoError(t, err)
+	assert.Len(t, workers, 3)
+
+	assert.Equal(t, http.StatusNotFound, serve(router, "POST", "/api/tasks/parent/restore", "").Code)
+}
+
+func TestDeleteTask_BroadcastsTaskDeleted(t *testing.T) {
+	handler, _ := setupHierarchyHandler(t)
+	client := handler.hub.NewLocalClient("", nil)
+	handler.hub.Register(client)
+
+	req := withTaskID(httptest.NewRequest("DELETE", "/api/tasks/parent?cascade=true", nil), "parent")
+	w := httptest.NewRecorder()
+	errormw.Error(handler.DeleteTask)(w, req)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	deleted := make(map[string]string)
+	timeout := time.After(time.Second)
+	for len(deleted) < 3 {
+		select {
+		case message := <-client.Messages():
+			var event TaskDeletedEvent
+			require.NoError(t, json.Unmarshal(message, &event))
+			if event.Type == "task-deleted" {
+				assert.Equal(t, event.Data.TaskID, event.Data.Task.ID)
+				deleted[event.Data.TaskID] = event.Data.Task.Status
+			}
+		case <-timeout:
+			t.Fatalf("received task-deleted events for %v only", deleted)
+		}
+	}
+	assert.Equal(t, map[string]string{"parent": "stopped", "child1": "failed", "child2": "completed"}, deleted)
+}
@@ -135,6 +135,35 @@ func TestListTasks_Pagination(t *testing.T) {
 		assert.Equal(t, "worker2", response2.Tasks[0].ID)
 		assert.NotEqual(t, response1.Tasks[0].ID, response2.Tasks[0].ID)
 	})
+
+	t.Run("cursor anchor deleted between pages", func(t *testing.T) {
+		req1 := httptest.NewRequest("GET", "/api/tasks?limit=2", nil)
+		w1 := httptest.NewRecorder()
+		require.NoError(t, handler.ListTasks(w1, req1))
+
+		var response1 PaginatedTasksResponse
+		require.NoError(t, json.Unmarshal(w1.Body.Bytes(), &response1))
+		require.Equal(t, "worker2", response1.Tasks[1].ID)
+
+		// The task the cursor points at is deleted before the next page is fetched
+		remaining := map[string]*worker.Worker{
+			"worker1": mockWorkers["worker1"],
+			"worker3": mockWorkers["worker3"],
+		}
+		require.NoError(t, manager.SaveWorkersForTest(remaining, stateFile))
+
+		req2 := httptest.NewRequest("GET", "/api/tasks?limit=2&cursor="+response1.NextCursor, nil)
+		w2 := httptest.NewRecorder()
+		require.NoError(t, handler.ListTasks(w2, req2))
+
+		var response2 PaginatedTasksResponse
+		require.NoError(t, json.Unmarshal(w2.Body.Bytes(), &response2))
+
+		// Resumes after where the deleted task was rather than starting over
+		require.Len(t, response2.Tasks, 1)
+		assert.Equal(t, "worker1", response2.Tasks[0].ID)
+		assert.False(t, response2.HasMore)
+	})
 }
 
 func TestListTasks_Filtering(t *testing.T) {
@@ -154,6 +183,9 @@ func TestListTasks_Filtering(t *testing.T) {
 			LogFile:  filepath.Join(tempDir, "worker-running1.log"),
 			Started:  now.Add(-1 * time.Hour),
 			Status:   "running",
+			Title:    "Fix login bug",
+			Tags:     []string{"bug", "auth"},
+			Priority: "high",
 		},
 		"stopped1": {
 			ID:       "stopped1",
@@ -162,6 +194,9 @@ func TestListTasks_Filtering(t *testing.T) {
 			LogFile:  filepath.Join(tempDir, "worker-stopped1.log"),
 			Started:  now.Add(-2 * time.Hour),
 			Status:   "stopped",
+			Title:    "Update docs",
+			Tags:     []string{"bug"},
+			Priority: "low",
 		},
 		"running2": {
 			ID:       "running2",
@@ -226,6 +261,40 @@ func TestListTasks_Filtering(t *testing.T) {
 		assert.Len(t, response.Tasks, 3)
 		assert.Equal(t, 3, response.Total)
 	})
+
+	t.Run("filter by other statuses", func(t *testing.T) {
+		for _, status := range []string{"interrupted", "aborted", "failed", "completed"} {
+			req := httptest.NewRequest("GET", "/api/tasks?status="+status, nil)
+			w := httptest.NewRecorder()
+
+			require.NoError(t, handler.ListTasks(w, req), status)
+
+			var response PaginatedTasksResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Empty(t, response.Tasks, status)
+		}
+	})
+
+	t.Run("filter by tag, priority and text", func(t *testing.T) {
+		list := func(query string) []string {
+			w := httptest.NewRecorder()
+			require.NoError(t, handler.ListTasks(w, httptest.NewRequest("GET", "/api/tasks?"+query, nil)))
+
+			var response PaginatedTasksResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			ids := make([]string, len(response.Tasks))
+			for i, task := range response.Tasks {
+				ids[i] = task.ID
+			}
+			return ids
+		}
+
+		assert.Equal(t, []string{"running1", "stopped1"}, list("tag=bug"))
+		assert.Equal(t, []string{"running1"}, list("tag=bug&tag=auth"))
+		assert.Equal(t, []string{"stopped1"}, list("priority=low,medium"))
+		assert.Equal(t, []string{"running1"}, list("q=LOGIN"))
+		assert.Equal(t, []string{"stopped1"}, list("tag=bug&status=stopped"))
+	})
 }
 
 func TestListTasks_Sorting(t *testing.T) {
@@ -338,3 +407,101 @@ func TestListTasks_ErrorHandling(t *testing.T) {
 		assert.Contains(t, w.Body.String(), "Invalid cursor")
 	})
 }
+
+func TestListTasks_KeysetCursor(t *testing.T) {
+	router, manager, _ := setupFakeRouter(t)
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	manager.Add(
+		&worker.Worker{ID: "b", Status: worker.StatusStopped, Started: base.Add(700 * time.Millisecond), Title: "Beta"},
+		&worker.Worker{ID: "a", Status: worker.StatusStopped, Started: base.Add(300 * time.Millisecond), Title: "Alpha"},
+		&worker.Worker{ID: "c", Status: worker.StatusStopped, Started: base.Add(-time.Hour), Title: "Gamma"},
+	)
+
+	page := func(query string) PaginatedTasksResponse {
+		w := serve(router, "GET", "/api/tasks?limit=1"+query, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp PaginatedTasksResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.Len(t, resp.Tasks, 1)
+		return resp
+	}
+
+	first := page("")
+	assert.Equal(t, "b", first.Tasks[0].ID)
+
+	// The cursor's task is deleted and a newer task is added between pages;
+	// the next page resumes after the deleted task, within the same second
+	require.Equal(t, http.StatusNoContent, serve(router, "DELETE", "/api/tasks/b", "").Code)
+	manager.Add(&worker.Worker{ID: "d", Status: worker.StatusStopped, Started: base.Add(time.Hour), Title: "Delta"})
+	second := page("&cursor=" + first.NextCursor)
+	assert.Equal(t, "a", second.Tasks[0].ID)
+	third := page("&cursor=" + second.NextCursor)
+	assert.Equal(t, "c", third.Tasks[0].ID)
+	assert.False(t, third.HasMore)
+
+	// Other sort keys are compared on the cursor's task when it still exists,
+	// even if it no longer matches the filter
+	first = page("&sort_by=title&sort_order=asc&status=stopped")
+	assert.Equal(t, "a", first.Tasks[0].ID)
+	manager.Add(&worker.Worker{ID: "a", Status: worker.StatusRunning, Started: base.Add(300 * time.Millisecond), Title: "Alpha"})
+	second = page("&sort_by=title&sort_order=asc&status=stopped&cursor=" + first.NextCursor)
+	assert.Equal(t, "d", second.Tasks[0].ID)
+}
+
+func TestListTasks_ETag(t *testing.T) {
+	router, manager, _ := setupFakeRouter(t)
+	manager.Add(&worker.Worker{ID: "a", Status: worker.StatusRunning, Started: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)})
+
+	list := func(etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/tasks?status=running", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := list("")
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	w = list(etag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	// Changing a task in the filtered set changes the ETag
+	require.Equal(t, http.StatusOK, serve(router, "PATCH", "/api/tasks/a", `{"title":"Fix build"}`).Code)
+	w = list(etag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+}
+
+func TestListTasks_IDs(t *testing.T) {
+	router, manager, _ := setupFakeRouter(t)
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, id := range []string{"a", "b", "c", "d"} {
+		manager.Add(&worker.Worker{ID: id, Status: worker.StatusRunning, Started: base.Add(time.Duration(i) * time.Minute)})
+	}
+
+	w := serve(router, "GET", "/api/tasks?ids=c,a,gone,a", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp PaginatedTasksResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Tasks, 2)
+	assert.Equal(t, "c", resp.Tasks[0].ID)
+	assert.Equal(t, "a", resp.Tasks[1].ID)
+	assert.Equal(t, 2, resp.Total)
+	assert.Equal(t, []string{"gone"}, resp.Missing)
+
+	// Other filters still apply
+	require.Equal(t, http.StatusAccepted, serve(router, "POST", "/api/tasks/c/stop", "").Code)
+	w = serve(router, "GET", "/api/tasks?ids=a,c&status=running", "")
+	resp = PaginatedTasksResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Tasks, 1)
+	assert.Equal(t, "a", resp.Tasks[0].ID)
+	assert.Empty(t, resp.Missing)
+}
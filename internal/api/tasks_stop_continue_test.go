@@ -16,6 +16,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	errormw "github.com/brettsmith212/amp-orchestrator-2/internal/middleware"
 	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
 )
 
@@ -51,7 +52,7 @@ func TestStopTask_Success(t *testing.T) {
 	}))
 	w := httptest.NewRecorder()
 
-	handler.StopTask(w, req)
+	errormw.Error(handler.StopTask)(w, req)
 
 	// Since the fake PID won't exist, the manager returns an error, which maps to 500
 	// This tests the error handling path - in a real scenario the PID would exist
@@ -73,7 +74,7 @@ func TestStopTask_NotFound(t *testing.T) {
 	}))
 	w := httptest.NewRecorder()
 
-	handler.StopTask(w, req)
+	errormw.Error(handler.StopTask)(w, req)
 
 	assert.Equal(t, http.StatusNotFound, w.Code)
 	assert.Contains(t, w.Body.String(), "Task not found")
@@ -111,7 +112,7 @@ func TestStopTask_NotRunning(t *testing.T) {
 	}))
 	w := httptest.NewRecorder()
 
-	handler.StopTask(w, req)
+	errormw.Error(handler.StopTask)(w, req)
 
 	assert.Equal(t, http.StatusConflict, w.Code)
 	assert.Contains(t, w.Body.String(), "not running")
@@ -134,7 +135,7 @@ func TestContinueTask_NotFound(t *testing.T) {
 	}))
 	w := httptest.NewRecorder()
 
-	handler.ContinueTask(w, req)
+	errormw.Error(handler.ContinueTask)(w, req)
 
 	assert.Equal(t, http.StatusNotFound, w.Code)
 	assert.Contains(t, w.Body.String(), "Task not found")
@@ -156,7 +157,7 @@ func TestContinueTask_InvalidJSON(t *testing.T) {
 	}))
 	w := httptest.NewRecorder()
 
-	handler.ContinueTask(w, req)
+	errormw.Error(handler.ContinueTask)(w, req)
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 	assert.Contains(t, w.Body.String(), "Invalid JSON request body")
@@ -179,7 +180,7 @@ func TestContinueTask_EmptyMessage(t *testing.T) {
 	}))
 	w := httptest.NewRecorder()
 
-	handler.ContinueTask(w, req)
+	errormw.Error(handler.ContinueTask)(w, req)
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 	assert.Contains(t, w.Body.String(), "Message is required")
@@ -16,6 +16,8 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub/hubtest"
+	errormw "github.com/brettsmith212/amp-orchestrator-2/internal/middleware"
 	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
 )
 
@@ -122,12 +124,28 @@ func TestStartTask_InvalidJSON(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	
-	handler.StartTask(w, req)
+	errormw.Error(handler.StartTask)(w, req)
 	
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 	assert.Contains(t, w.Body.String(), "Invalid JSON request body")
 }
 
+func TestStartTask_DiskQuotaExceeded(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := worker.NewManager(tempDir)
+	manager.SetLogDirQuota(10, nil)
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "worker-old.log"), []byte("more than ten bytes"), 0644))
+	handler := NewTaskHandler(manager, nil)
+
+	req := httptest.NewRequest("POST", "/api/tasks", strings.NewReader(`{"message":"hello"}`))
+	w := httptest.NewRecorder()
+
+	errormw.Error(handler.StartTask)(w, req)
+
+	assert.Equal(t, http.StatusInsufficientStorage, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"disk_quota_exceeded"`)
+}
+
 func TestStartTask_EmptyMessage(t *testing.T) {
 	tempDir := t.TempDir()
 	manager := worker.NewManager(tempDir)
@@ -139,7 +157,7 @@ func TestStartTask_EmptyMessage(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	
-	handler.StartTask(w, req)
+	errormw.Error(handler.StartTask)(w, req)
 	
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 	assert.Contains(t, w.Body.String(), "Message is required")
@@ -156,17 +174,48 @@ func TestStartTask_MissingMessage(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	
-	handler.StartTask(w, req)
+	errormw.Error(handler.StartTask)(w, req)
 	
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 	assert.Contains(t, w.Body.String(), "Message is required")
 }
 
+func TestStartTask_Execution(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := worker.NewManager(tempDir)
+	h := hub.NewHub()
+	handler := NewTaskHandler(manager, h)
+
+	tests := []struct {
+		body    string
+		message string
+	}{
+		{`{"message":"hi","execution":"vm"}`, "Invalid execution mode: vm"},
+		{`{"message":"hi","execution":"container"}`, "Container execution is not configured"},
+		{`{"message":"hi","execution":"remote"}`, "Remote execution is not configured"},
+		{`{"message":"hi","amp_binary":"/tmp/amp"}`, `amp binary \"/tmp/amp\" is not allowed`},
+		{`{"message":"hi","amp_args":["--dangerously-allow-all"]}`, "is not allowed"},
+		{`{"message":"hi","profile":"staging"}`, "Unknown amp profile"},
+		{`{"message":"hi","env":{"LD_PRELOAD":"/tmp/x.so"}}`, "environment variable not allowed: LD_PRELOAD"},
+		{`{"message":"hi","restart":{"mode":"sometimes"}}`, `invalid restart policy: unknown mode \"sometimes\"`},
+		{`{"message":"hi","restart":{"mode":"always","max_restarts":-1}}`, "max_restarts must not be negative"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/api/tasks", strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+
+		errormw.Error(handler.StartTask)(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, tt.body)
+		assert.Contains(t, w.Body.String(), tt.message)
+	}
+}
+
 func TestInterruptTask(t *testing.T) {
 tempDir := t.TempDir()
 manager := worker.NewManager(tempDir)
 h := hub.NewHub()
-go h.Run() // Start the hub in a goroutine
+hubtest.Run(t, h)
 	handler := NewTaskHandler(manager, h)
 
 // Create a test worker
@@ -193,7 +242,7 @@ req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, &chi.Con
 	}))
 	w := httptest.NewRecorder()
 
-	handler.InterruptTask(w, req)
+	errormw.Error(handler.InterruptTask)(w, req)
 
 	assert.Equal(t, http.StatusAccepted, w.Code)
 }
@@ -202,7 +251,7 @@ func TestInterruptTask_NotFound(t *testing.T) {
 tempDir := t.TempDir()
 manager := worker.NewManager(tempDir)
 h := hub.NewHub()
-go h.Run() // Start the hub in a goroutine
+hubtest.Run(t, h)
 	handler := NewTaskHandler(manager, h)
 
 req := httptest.NewRequest("POST", "/api/tasks/nonexistent/interrupt", nil)
@@ -214,7 +263,7 @@ req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, &chi.Con
 }))
 	w := httptest.NewRecorder()
 
-	handler.InterruptTask(w, req)
+	errormw.Error(handler.InterruptTask)(w, req)
 
 	assert.Equal(t, http.StatusNotFound, w.Code)
 	assert.Contains(t, w.Body.String(), "Task not found")
@@ -224,7 +273,7 @@ func TestAbortTask(t *testing.T) {
 tempDir := t.TempDir()
 manager := worker.NewManager(tempDir)
 h := hub.NewHub()
-go h.Run() // Start the hub in a goroutine
+hubtest.Run(t, h)
 	handler := NewTaskHandler(manager, h)
 
 testWorkers := map[string]*worker.Worker{
@@ -250,7 +299,7 @@ req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, &chi.Con
 	}))
 	w := httptest.NewRecorder()
 
-	handler.AbortTask(w, req)
+	errormw.Error(handler.AbortTask)(w, req)
 
 	assert.Equal(t, http.StatusAccepted, w.Code)
 }
@@ -259,7 +308,7 @@ func TestPatchTask(t *testing.T) {
 tempDir := t.TempDir()
 manager := worker.NewManager(tempDir)
 h := hub.NewHub()
-go h.Run() // Start the hub in a goroutine
+hubtest.Run(t, h)
 handler := NewTaskHandler(manager, h)
 
 // Create a test worker
@@ -288,16 +337,48 @@ Values: []string{"test-worker"},
 req.Header.Set("Content-Type", "application/json")
 w := httptest.NewRecorder()
 
-handler.PatchTask(w, req)
+errormw.Error(handler.PatchTask)(w, req)
 
 assert.Equal(t, http.StatusOK, w.Code)
 }
 
+func TestPatchTask_ValidatesAndBroadcasts(t *testing.T) {
+	router, manager, client := setupFakeRouter(t)
+	manager.Add(&worker.Worker{ID: "a", Status: worker.StatusRunning, Priority: "low"})
+
+	w := serve(router, "PATCH", "/api/tasks/a", `{"title":"Fix login","priority":"High","tags":["auth"]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var task TaskDTO
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &task))
+	assert.Equal(t, "Fix login", task.Title)
+	assert.Equal(t, "high", task.Priority)
+
+	msg := client.ExpectEvent(hub.MessageTypeTaskUpdate, 0)
+	assert.Contains(t, string(msg.Data), `"priority":"high"`)
+
+	for name, body := range map[string]string{
+		"unknown priority": `{"priority":"urgent"}`,
+		"long title":       `{"title":"` + strings.Repeat("x", maxTitleLength+1) + `"}`,
+		"long tag":         `{"tags":["` + strings.Repeat("x", maxTagLength+1) + `"]}`,
+		"empty tag":        `{"tags":[" "]}`,
+		"too many tags":    `{"tags":[` + strings.Repeat(`"t",`, maxTags) + `"t"]}`,
+	} {
+		w = serve(router, "PATCH", "/api/tasks/a", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, name)
+	}
+	assert.Equal(t, "high", manager.Worker("a").Priority, "rejected updates change nothing")
+
+	// An empty priority clears it
+	w = serve(router, "PATCH", "/api/tasks/a", `{"priority":""}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, manager.Worker("a").Priority)
+}
+
 func TestPatchTask_NotFound(t *testing.T) {
 tempDir := t.TempDir()
 manager := worker.NewManager(tempDir)
 h := hub.NewHub()
-go h.Run()
+hubtest.Run(t, h)
 handler := NewTaskHandler(manager, h)
 
 reqBody := `{"title": "Updated Task"}`
@@ -311,7 +392,7 @@ Values: []string{"nonexistent"},
 req.Header.Set("Content-Type", "application/json")
 w := httptest.NewRecorder()
 
-handler.PatchTask(w, req)
+errormw.Error(handler.PatchTask)(w, req)
 
 assert.Equal(t, http.StatusNotFound, w.Code)
 assert.Contains(t, w.Body.String(), "Task not found")
@@ -321,7 +402,7 @@ func TestDeleteTask(t *testing.T) {
 tempDir := t.TempDir()
 manager := worker.NewManager(tempDir)
 h := hub.NewHub()
-go h.Run()
+hubtest.Run(t, h)
 handler := NewTaskHandler(manager, h)
 
 // Create a test worker
@@ -348,7 +429,7 @@ Values: []string{"test-worker"},
 }))
 w := httptest.NewRecorder()
 
-handler.DeleteTask(w, req)
+errormw.Error(handler.DeleteTask)(w, req)
 
 assert.Equal(t, http.StatusNoContent, w.Code)
 }
@@ -357,7 +438,7 @@ func TestDeleteTask_NotFound(t *testing.T) {
 tempDir := t.TempDir()
 manager := worker.NewManager(tempDir)
 h := hub.NewHub()
-go h.Run()
+hubtest.Run(t, h)
 handler := NewTaskHandler(manager, h)
 
 req := httptest.NewRequest("DELETE", "/api/tasks/nonexistent", nil)
@@ -369,7 +450,7 @@ Values: []string{"nonexistent"},
 }))
 w := httptest.NewRecorder()
 
-handler.DeleteTask(w, req)
+errormw.Error(handler.DeleteTask)(w, req)
 
 assert.Equal(t, http.StatusNotFound, w.Code)
 assert.Contains(t, w.Body.String(), "Task not found")
@@ -379,7 +460,7 @@ func TestGitStubEndpoints(t *testing.T) {
 tempDir := t.TempDir()
 manager := worker.NewManager(tempDir)
 h := hub.NewHub()
-go h.Run()
+hubtest.Run(t, h)
 handler := NewTaskHandler(manager, h)
 
 // Create a test worker
@@ -407,7 +488,7 @@ Values: []string{"test-worker"},
 }))
 w := httptest.NewRecorder()
 
-handler.MergeTask(w, req)
+errormw.Error(handler.MergeTask)(w, req)
 
 assert.Equal(t, http.StatusAccepted, w.Code)
 assert.Contains(t, w.Body.String(), "TODO: Git merge operation not yet implemented")
@@ -422,7 +503,7 @@ Values: []string{"test-worker"},
 }))
 w = httptest.NewRecorder()
 
-handler.DeleteBranchTask(w, req)
+errormw.Error(handler.DeleteBranchTask)(w, req)
 
 assert.Equal(t, http.StatusAccepted, w.Code)
 assert.Contains(t, w.Body.String(), "TODO: Git branch deletion not yet implemented")
@@ -437,8 +518,34 @@ Values: []string{"test-worker"},
 }))
 w = httptest.NewRecorder()
 
-handler.CreatePRTask(w, req)
+errormw.Error(handler.CreatePRTask)(w, req)
 
 assert.Equal(t, http.StatusAccepted, w.Code)
 assert.Contains(t, w.Body.String(), "TODO: Create pull request operation not yet implemented")
 }
+
+func TestWindDownTask(t *testing.T) {
+	handler, _ := setupHierarchyHandler(t)
+	router := NewRouter(handler, handler.hub)
+
+	tests := []struct {
+		name   string
+		id     string
+		body   string
+		status int
+	}{
+		{name: "unknown task", id: "missing", status: http.StatusNotFound},
+		{name: "task not running", id: "parent", body: `{"message":"wrap up"}`, status: http.StatusConflict},
+		{name: "negative timeout", id: "parent", body: `{"timeout_seconds":-1}`, status: http.StatusBadRequest},
+		{name: "timeout too long", id: "parent", body: `{"timeout_seconds":7200}`, status: http.StatusBadRequest},
+		{name: "invalid JSON", id: "parent", body: `{`, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("POST", "/api/tasks/"+tt.id+"/wind-down", strings.NewReader(tt.body)))
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+	errormw "github.com/brettsmith212/amp-orchestrator-2/internal/middleware"
+)
+
+func transitionRequest(handler *TaskHandler, id, body string) *httptest.ResponseRecorder {
+	req := withTaskID(httptest.NewRequest(http.MethodPost, "/api/tasks/"+id+"/transition", strings.NewReader(body)), id)
+	w := httptest.NewRecorder()
+	errormw.Error(handler.TransitionTask)(w, req)
+	return w
+}
+
+func findWorker(t *testing.T, manager *worker.Manager, id string) *worker.Worker {
+	workers, err := manager.ListWorkers()
+	require.NoError(t, err)
+	for _, w := range workers {
+		if w.ID == id {
+			return w
+		}
+	}
+	t.Fatalf("worker %s not found", id)
+	return nil
+}
+
+func TestTransitionTask_AppliesStatusAndMetadata(t *testing.T) {
+	handler, manager := setupHierarchyHandler(t)
+
+	w := transitionRequest(handler, "parent", `{"status":"aborted","reason":"superseded","title":"Old attempt","tags":["archived"]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var task TaskDTO
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &task))
+	assert.Equal(t, "aborted", task.Status)
+	assert.Equal(t, "superseded", task.StatusReason)
+	assert.Equal(t, "Old attempt", task.Title)
+
+	saved := findWorker(t, manager, "parent")
+	assert.Equal(t, worker.StatusAborted, saved.Status)
+	assert.Equal(t, "superseded", saved.StatusReason)
+	assert.Equal(t, []string{"archived"}, saved.Tags)
+}
+
+func TestTransitionTask_InvalidTransitionChangesNothing(t *testing.T) {
+	handler, manager := setupHierarchyHandler(t)
+
+	// Failed tasks can only be retried
+	w := transitionRequest(handler, "child1", `{"status":"interrupted","title":"Should not apply"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	saved := findWorker(t, manager, "child1")
+	assert.Equal(t, worker.StatusFailed, saved.Status)
+	assert.Empty(t, saved.Title)
+}
+
+func TestTransitionTask_Validation(t *testing.T) {
+	handler, _ := setupHierarchyHandler(t)
+
+	tests := []struct {
+		name     string
+		id       string
+		body     string
+		expected int
+	}{
+		{"invalid json", "parent", `{`, http.StatusBadRequest},
+		{"unknown status", "parent", `{"status":"paused"}`, http.StatusBadRequest},
+		{"running without message", "parent", `{"status":"running"}`, http.StatusBadRequest},
+		{"missing task", "nonexistent", `{"status":"aborted"}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := transitionRequest(handler, tt.id, tt.body)
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+}
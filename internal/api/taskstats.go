@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+)
+
+// SetSampler serves the CPU and memory samples the sampler records
+func (h *TaskHandler) SetSampler(sampler *worker.Sampler) {
+	h.sampler = sampler
+}
+
+// GetTaskStats returns the recent CPU and memory samples of a task, oldest first
+func (h *TaskHandler) GetTaskStats(w http.ResponseWriter, r *http.Request) error {
+	if h.sampler == nil {
+		return apierr.NotFound("Resource sampling is not enabled")
+	}
+
+	workerID := chi.URLParam(r, "id")
+	if err := h.requireTask(workerID); err != nil {
+		return err
+	}
+
+	samples := h.sampler.Samples(workerID)
+	if samples == nil {
+		samples = []worker.ResourceSample{}
+	}
+	return response.OK(w, TaskStatsResponse{
+		TaskID:          workerID,
+		IntervalSeconds: h.sampler.Interval().Seconds(),
+		Samples:         samples,
+	})
+}
+
+// BroadcastTaskStats sends a task-stats event with a new sample over
+// WebSocket. Samples are transient: they're neither replayed nor kept in history.
+func (h *TaskHandler) BroadcastTaskStats(workerID string, sample worker.ResourceSample) {
+	if h.hub == nil {
+		return
+	}
+
+	event := TaskStatsEvent{
+		Type: "task-stats",
+		Data: TaskStatsDTO{TaskID: workerID, ResourceSample: sample},
+	}
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	h.hub.BroadcastTaskEvent(workerID, TaskRoom(workerID), eventJSON)
+}
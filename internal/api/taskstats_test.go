@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+func TestGetTaskStats(t *testing.T) {
+	handler, manager := setupHierarchyHandler(t)
+	router := NewRouter(handler, handler.hub)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	// Sampling is disabled until a sampler is set
+	assert.Equal(t, http.StatusNotFound, get("/api/tasks/parent/stats").Code)
+
+	handler.SetSampler(worker.NewSampler(manager, worker.SamplerPolicy{Interval: 2 * time.Second}, nil))
+	w := get("/api/tasks/parent/stats")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"task_id":"parent","interval_seconds":2,"samples":[]}`, w.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, get("/api/tasks/missing/stats").Code)
+}
+
+func TestBroadcastTaskStats(t *testing.T) {
+	handler, _ := setupHierarchyHandler(t)
+	client := handler.hub.NewLocalClient("", nil)
+	handler.hub.Register(client)
+
+	now := time.Date(2025, 6, 4, 16, 18, 0, 0, time.UTC)
+	handler.BroadcastTaskStats("child1", worker.ResourceSample{Time: now, CPUPercent: 12.5, RSSBytes: 4096, Processes: 3})
+
+	select {
+	case message := <-client.Messages():
+		var event TaskStatsEvent
+		require.NoError(t, json.Unmarshal(message, &event))
+		assert.Equal(t, "task-stats", event.Type)
+		assert.Equal(t, "child1", event.Data.TaskID)
+		assert.Equal(t, 12.5, event.Data.CPUPercent)
+		assert.Equal(t, uint64(4096), event.Data.RSSBytes)
+		assert.True(t, now.Equal(event.Data.Time))
+	case <-time.After(time.Second):
+		t.Fatal("no task-stats event received")
+	}
+}
@@ -1,79 +1,194 @@
 package api
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
 	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
 )
 
-// GetTaskThread returns the thread messages for a specific task
-func GetTaskThread(wm *worker.Manager) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		taskID := chi.URLParam(r, "id")
-		if taskID == "" {
-			response.Error(w, http.StatusBadRequest, "task ID is required")
-			return
-		}
+// formatThreadCursor returns the cursor of the page ending with message. Unlike
+// task cursors it keeps nanoseconds, since messages arrive in bursts.
+func formatThreadCursor(message worker.ThreadMessage) string {
+	return fmt.Sprintf("%d_%s", message.Timestamp.UnixNano(), message.ID)
+}
 
-		// Parse pagination parameters
-		limitStr := r.URL.Query().Get("limit")
-		offsetStr := r.URL.Query().Get("offset")
-
-		limit := 50 // Default limit
-		if limitStr != "" {
-			if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
-				limit = parsedLimit
-				if limit > 100 {
-					limit = 100 // Cap at 100
-				}
-			}
-		}
+// parseThreadCursor parses a cursor returned by formatThreadCursor
+func parseThreadCursor(cursor string) (*worker.ThreadCursor, error) {
+	nanos, id, ok := strings.Cut(cursor, "_")
+	timestamp, err := strconv.ParseInt(nanos, 10, 64)
+	if !ok || err != nil || id == "" {
+		return nil, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return &worker.ThreadCursor{Timestamp: time.Unix(0, timestamp), ID: id}, nil
+}
 
-		offset := 0 // Default offset
-		if offsetStr != "" {
-			if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
-				offset = parsedOffset
+// GetTaskThread returns the thread messages for a specific task, a page at a
+// time using ?cursor= (or the older ?offset=), oldest first unless ?order=desc
+func (h *TaskHandler) GetTaskThread(w http.ResponseWriter, r *http.Request) error {
+	taskID := chi.URLParam(r, "id")
+	if taskID == "" {
+		return apierr.BadRequest("Task ID is required")
+	}
+
+	// Parse pagination parameters
+	limitStr := r.URL.Query().Get("limit")
+	offsetStr := r.URL.Query().Get("offset")
+
+	limit := 50 // Default limit
+	if limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+			if limit > 100 {
+				limit = 100 // Cap at 100
 			}
 		}
+	}
 
-		// Get total count first
-		total, err := wm.CountThreadMessages(taskID)
-		if err != nil {
-			response.Error(w, http.StatusInternalServerError, "failed to count thread messages")
-			return
+	offset := 0 // Default offset
+	if offsetStr != "" {
+		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
+			offset = parsedOffset
 		}
+	}
 
-		// Get messages
-		messages, err := wm.GetThreadMessages(taskID, limit, offset)
+	order := r.URL.Query().Get("order")
+	if order != "" && order != "asc" && order != "desc" {
+		return apierr.BadRequest("order must be asc or desc")
+	}
+	var cursor *worker.ThreadCursor
+	if cursorStr := r.URL.Query().Get("cursor"); cursorStr != "" {
+		parsed, err := parseThreadCursor(cursorStr)
 		if err != nil {
-			response.Error(w, http.StatusInternalServerError, "failed to retrieve thread messages")
-			return
+			return apierr.Wrap(err, http.StatusBadRequest, err.Error())
 		}
+		cursor = parsed
+	}
+	if offset > 0 && (cursor != nil || order == "desc") {
+		return apierr.BadRequest("offset cannot be combined with cursor or order=desc")
+	}
 
-		// Convert to DTOs
-		messageDTOs := make([]ThreadMessageDTO, len(messages))
-		for i, msg := range messages {
-			messageDTOs[i] = ThreadMessageDTO{
-				ID:        msg.ID,
-				Type:      string(msg.Type),
-				Content:   msg.Content,
-				Timestamp: msg.Timestamp,
-				Metadata:  msg.Metadata,
-			}
-		}
+	// Get total count first
+	total, err := h.manager.CountThreadMessages(taskID)
+	if err != nil {
+		return taskError(err, "count thread messages")
+	}
 
-		// Calculate has_more
-		hasMore := offset+len(messages) < total
+	// Get messages
+	var messages []worker.ThreadMessage
+	var hasMore bool
+	if offset > 0 {
+		messages, err = h.manager.GetThreadMessages(taskID, limit, offset)
+		hasMore = offset+len(messages) < total
+	} else {
+		messages, hasMore, err = h.manager.GetThreadPage(taskID, cursor, limit, order == "desc")
+	}
+	if err != nil {
+		return taskError(err, "retrieve thread messages")
+	}
 
-		responseData := PaginatedThreadResponse{
-			Messages: messageDTOs,
-			HasMore:  hasMore,
-			Total:    total,
+	// Convert to DTOs
+	messageDTOs := make([]ThreadMessageDTO, len(messages))
+	for i, msg := range messages {
+		messageDTOs[i] = ThreadMessageDTO{
+			ID:        msg.ID,
+			Type:      string(msg.Type),
+			Content:   msg.Content,
+			Timestamp: msg.Timestamp,
+			Metadata:  msg.Metadata,
 		}
+	}
+
+	responseData := PaginatedThreadResponse{
+		Messages: messageDTOs,
+		HasMore:  hasMore,
+		Total:    total,
+	}
+	if hasMore && len(messages) > 0 {
+		responseData.NextCursor = formatThreadCursor(messages[len(messages)-1])
+	}
 
-		response.JSON(w, http.StatusOK, responseData)
+	return response.OK(w, responseData)
+}
+
+// RebuildTaskThread replaces a stopped task's thread with the conversation
+// re-parsed from its amp log
+func (h *TaskHandler) RebuildTaskThread(w http.ResponseWriter, r *http.Request) error {
+	taskID := chi.URLParam(r, "id")
+	if taskID == "" {
+		return apierr.BadRequest("Task ID is required")
+	}
+
+	if err := h.requireTaskControl(r, taskID, false, "rebuild"); err != nil {
+		return err
+	}
+
+	rebuild, err := h.manager.RebuildThread(taskID)
+	if err != nil {
+		return taskError(err, "rebuild thread")
+	}
+	return response.OK(w, rebuild)
+}
+
+// RemoveThreadMessage deletes a message from a task's thread, e.g. one where
+// the agent echoed a secret
+func (h *TaskHandler) RemoveThreadMessage(w http.ResponseWriter, r *http.Request) error {
+	taskID := chi.URLParam(r, "id")
+	messageID := chi.URLParam(r, "messageID")
+
+	if err := h.requireTaskControl(r, taskID, false, "edit the thread of"); err != nil {
+		return err
+	}
+
+	if err := h.manager.RemoveThreadMessage(taskID, messageID); err != nil {
+		return taskError(err, "remove thread message")
+	}
+	h.broadcastThreadMessageRemoved(ThreadMessageRemovedDTO{TaskID: taskID, MessageID: messageID})
+	response.NoContent(w)
+	return nil
+}
+
+// RedactThreadMessage blanks the content of a message in a task's thread,
+// keeping its record, and returns the redacted message
+func (h *TaskHandler) RedactThreadMessage(w http.ResponseWriter, r *http.Request) error {
+	taskID := chi.URLParam(r, "id")
+	messageID := chi.URLParam(r, "messageID")
+
+	if err := h.requireTaskControl(r, taskID, false, "edit the thread of"); err != nil {
+		return err
+	}
+
+	message, err := h.manager.RedactThreadMessage(taskID, messageID)
+	if err != nil {
+		return taskError(err, "redact thread message")
+	}
+	dto := ThreadMessageDTO{
+		ID:        message.ID,
+		Type:      string(message.Type),
+		Content:   message.Content,
+		Timestamp: message.Timestamp,
+		Metadata:  message.Metadata,
+	}
+	h.broadcastThreadMessageRemoved(ThreadMessageRemovedDTO{TaskID: taskID, MessageID: messageID, Redacted: true, Message: &dto})
+	return response.OK(w, dto)
+}
+
+// broadcastThreadMessageRemoved tells the task's subscribers to drop or
+// replace a thread message they may have shown
+func (h *TaskHandler) broadcastThreadMessageRemoved(removed ThreadMessageRemovedDTO) {
+	if h.hub == nil {
+		return
+	}
+	eventJSON, err := json.Marshal(ThreadMessageRemovedEvent{Type: "thread_message_removed", Data: removed})
+	if err != nil {
+		return
 	}
+	h.hub.BroadcastTaskEvent(removed.TaskID, TaskRoom(removed.TaskID), eventJSON)
 }
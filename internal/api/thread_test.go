@@ -12,6 +12,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	errormw "github.com/brettsmith212/amp-orchestrator-2/internal/middleware"
 	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
 )
 
@@ -22,7 +23,7 @@ func TestGetTaskThread(t *testing.T) {
 	defer os.RemoveAll(tempDir)
 
 	manager := worker.NewManager(tempDir)
-	handler := GetTaskThread(manager)
+	handler := errormw.Error(NewTaskHandler(manager, nil).GetTaskThread)
 
 	// Add some test messages
 	taskID := "test-task-123"
@@ -157,6 +158,50 @@ func TestGetTaskThread(t *testing.T) {
 		assert.Equal(t, http.StatusOK, w.Code) // Should use default offset
 	})
 
+	t.Run("CursorPagination", func(t *testing.T) {
+		get := func(query string) PaginatedThreadResponse {
+			req := httptest.NewRequest("GET", "/api/tasks/test-task-123/thread?"+query, nil)
+			req = setURLParam(req, "id", taskID)
+			w := httptest.NewRecorder()
+			handler(w, req)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			var response PaginatedThreadResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			return response
+		}
+
+		first := get("limit=2")
+		require.Len(t, first.Messages, 2)
+		require.NotEmpty(t, first.NextCursor)
+		next := get("limit=2&cursor=" + first.NextCursor)
+		require.Len(t, next.Messages, 1)
+		assert.Equal(t, "System message", next.Messages[0].Content)
+		assert.False(t, next.HasMore)
+		assert.Empty(t, next.NextCursor)
+
+		newest := get("limit=2&order=desc")
+		require.Len(t, newest.Messages, 2)
+		assert.Equal(t, "System message", newest.Messages[0].Content)
+		assert.Equal(t, "Hello back!", newest.Messages[1].Content)
+		assert.True(t, newest.HasMore)
+		older := get("limit=2&order=desc&cursor=" + newest.NextCursor)
+		require.Len(t, older.Messages, 1)
+		assert.Equal(t, "Hello", older.Messages[0].Content)
+		assert.False(t, older.HasMore)
+		assert.Equal(t, 3, older.Total)
+	})
+
+	t.Run("InvalidCursorParameters", func(t *testing.T) {
+		for _, query := range []string{"cursor=abc", "cursor=123_", "order=newest", "offset=1&order=desc"} {
+			req := httptest.NewRequest("GET", "/api/tasks/test-task-123/thread?"+query, nil)
+			req = setURLParam(req, "id", taskID)
+			w := httptest.NewRecorder()
+			handler(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+	})
+
 	t.Run("NonExistentTask", func(t *testing.T) {
 		req := httptest.NewRequest("GET", "/api/tasks/nonexistent/thread", nil)
 		req = setURLParam(req, "id", "nonexistent")
@@ -215,3 +260,41 @@ func TestGetTaskThread(t *testing.T) {
 		}
 	})
 }
+
+func TestRemoveThreadMessage(t *testing.T) {
+	router, manager, client := setupFakeRouter(t)
+	manager.Add(&worker.Worker{ID: "w", Status: worker.StatusCompleted})
+	manager.AddThreadMessages("w",
+		worker.ThreadMessage{ID: "m1", Type: worker.MessageTypeUser, Content: "deploy it"},
+		worker.ThreadMessage{ID: "m2", Type: worker.MessageTypeTool, Content: "TOKEN=hunter2", Metadata: map[string]interface{}{"tool": "bash"}},
+		worker.ThreadMessage{ID: "m3", Type: worker.MessageTypeAssistant, Content: "AWS_SECRET=abc"},
+	)
+
+	w := serve(router, "POST", "/api/tasks/w/thread/m2/redact", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var redacted ThreadMessageDTO
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &redacted))
+	assert.Equal(t, "m2", redacted.ID)
+	assert.Equal(t, "tool", redacted.Type)
+	assert.Empty(t, redacted.Content)
+	assert.Equal(t, map[string]interface{}{"redacted": true}, redacted.Metadata)
+	msg := client.ExpectEvent("thread_message_removed", 0)
+	assert.Contains(t, string(msg.Data), `"message_id":"m2","redacted":true`)
+
+	w = serve(router, "DELETE", "/api/tasks/w/thread/m3", "")
+	require.Equal(t, http.StatusNoContent, w.Code)
+	msg = client.ExpectEvent("thread_message_removed", 0)
+	assert.Contains(t, string(msg.Data), `"message_id":"m3","redacted":false`)
+
+	w = serve(router, "GET", "/api/tasks/w/thread", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "hunter2")
+	assert.NotContains(t, w.Body.String(), "AWS_SECRET")
+	assert.Contains(t, w.Body.String(), `"id":"m2"`)
+
+	w = serve(router, "DELETE", "/api/tasks/w/thread/m3", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "Thread message not found")
+	w = serve(router, "POST", "/api/tasks/missing/thread/m1/redact", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/webhook"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+)
+
+// WebhookHandler handles webhook-related API requests
+type WebhookHandler struct {
+	dispatcher *webhook.Dispatcher
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(dispatcher *webhook.Dispatcher) *WebhookHandler {
+	return &WebhookHandler{
+		dispatcher: dispatcher,
+	}
+}
+
+// TestWebhookRequest represents the optional request body for test-firing a
+// webhook or evaluating routing rules
+type TestWebhookRequest struct {
+	Type string   `json:"type,omitempty"` // Event type, defaults to task-update
+	Task *TaskDTO `json:"task,omitempty"` // Task to render, defaults to a sample task
+}
+
+// sampleTask returns a representative task used to render test payloads
+func sampleTask() TaskDTO {
+	return TaskDTO{
+		ID:          "sample01",
+		ThreadID:    "T-00000000-0000-0000-0000-000000000000",
+		Status:      "completed",
+		Started:     time.Now().Add(-5 * time.Minute),
+		LogFile:     "logs/worker-sample01.log",
+		Title:       "Sample task",
+		Description: "Sample task used to test webhook payloads",
+		Tags:        []string{"sample"},
+		Priority:    "medium",
+	}
+}
+
+// eventFromRequest builds the event described by a test or evaluation request
+// body, defaulting to a task-update for a sample task
+func eventFromRequest(r *http.Request) (webhook.Event, error) {
+	var req TestWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		return webhook.Event{}, apierr.BadRequest("Invalid JSON request body")
+	}
+
+	if req.Type == "" {
+		req.Type = "task-update"
+	}
+	task := sampleTask()
+	if req.Task != nil {
+		task = *req.Task
+	}
+
+	return webhook.Event{
+		Type:       req.Type,
+		TaskID:     task.ID,
+		Task:       task,
+		Timestamp:  time.Now(),
+		Attributes: taskAttributes(task),
+	}, nil
+}
+
+// taskAttributes returns the task fields webhook routing rules match against
+func taskAttributes(task TaskDTO) webhook.Attributes {
+	return webhook.Attributes{
+		Tags:     task.Tags,
+		Priority: task.Priority,
+		Status:   task.Status,
+		Project:  task.Project,
+	}
+}
+
+// TestWebhook renders a sample event with the webhook's payload template and
+// delivers it, or only renders it when ?dry_run=true
+func (h *WebhookHandler) TestWebhook(w http.ResponseWriter, r *http.Request) error {
+	name := chi.URLParam(r, "name")
+
+	event, err := eventFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	if dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run")); dryRun {
+		payload, err := h.dispatcher.Render(name, event)
+		if err != nil {
+			return webhookError(err, name)
+		}
+		return response.OK(w, webhook.DeliveryResult{Webhook: name, Payload: payload})
+	}
+
+	result, err := h.dispatcher.Deliver(r.Context(), name, event)
+	if err != nil {
+		return webhookError(err, name)
+	}
+
+	return response.OK(w, result)
+}
+
+// EvaluateRoutes reports which routing rules match an event and which webhooks
+// it would be delivered to, without delivering it
+func (h *WebhookHandler) EvaluateRoutes(w http.ResponseWriter, r *http.Request) error {
+	event, err := eventFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	return response.OK(w, h.dispatcher.Evaluate(event))
+}
+
+// webhookError maps dispatcher errors to API errors
+func webhookError(err error, name string) error {
+	if errors.Is(err, webhook.ErrNotFound) {
+		return apierr.NotFoundf("Webhook %s not found", name)
+	}
+	return apierr.Wrap(err, http.StatusUnprocessableEntity, err.Error())
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/middleware"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/webhook"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/config"
+)
+
+func withWebhookName(req *http.Request, name string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, &chi.Context{
+		URLParams: chi.RouteParams{
+			Keys:   []string{"name"},
+			Values: []string{name},
+		},
+	}))
+}
+
+func TestTestWebhook_DryRun(t *testing.T) {
+	dispatcher, err := webhook.NewDispatcher([]config.WebhookConfig{{
+		Name:     "pager",
+		URL:      "http://127.0.0.1:1", // never contacted in dry-run mode
+		Template: `{"summary": {{json .Task.Title}}, "status": {{json .Task.Status}}}`,
+	}})
+	require.NoError(t, err)
+	handler := NewWebhookHandler(dispatcher)
+
+	body := `{"task": {"id": "abc", "title": "Deploy docs", "status": "failed"}}`
+	req := withWebhookName(httptest.NewRequest("POST", "/api/webhooks/pager/test?dry_run=true", strings.NewReader(body)), "pager")
+	w := httptest.NewRecorder()
+
+	middleware.Error(handler.TestWebhook)(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var result webhook.DeliveryResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.JSONEq(t, `{"summary": "Deploy docs", "status": "failed"}`, result.Payload)
+	assert.Zero(t, result.StatusCode)
+}
+
+func TestTestWebhook_Deliver(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		received = payload["title"]
+	}))
+	defer server.Close()
+
+	dispatcher, err := webhook.NewDispatcher([]config.WebhookConfig{{
+		Name:     "custom",
+		URL:      server.URL,
+		Template: `{"title": {{json .Task.Title}}}`,
+	}})
+	require.NoError(t, err)
+	handler := NewWebhookHandler(dispatcher)
+
+	req := withWebhookName(httptest.NewRequest("POST", "/api/webhooks/custom/test", nil), "custom")
+	w := httptest.NewRecorder()
+
+	middleware.Error(handler.TestWebhook)(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var result webhook.DeliveryResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "Sample task", received)
+}
+
+func TestTestWebhook_NotFound(t *testing.T) {
+	handler := NewWebhookHandler(nil)
+
+	req := withWebhookName(httptest.NewRequest("POST", "/api/webhooks/missing/test", nil), "missing")
+	w := httptest.NewRecorder()
+
+	middleware.Error(handler.TestWebhook)(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestTestWebhook_TemplateError(t *testing.T) {
+	dispatcher, err := webhook.NewDispatcher([]config.WebhookConfig{{
+		Name:     "broken",
+		URL:      "http://127.0.0.1:1",
+		Template: `{{.Task.DoesNotExist}}`,
+	}})
+	require.NoError(t, err)
+	handler := NewWebhookHandler(dispatcher)
+
+	req := withWebhookName(httptest.NewRequest("POST", "/api/webhooks/broken/test?dry_run=true", nil), "broken")
+	w := httptest.NewRecorder()
+
+	middleware.Error(handler.TestWebhook)(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestEvaluateRoutes(t *testing.T) {
+	dispatcher, err := webhook.NewDispatcher([]config.WebhookConfig{
+		{Name: "oncall", URL: "http://127.0.0.1:1"}, // never contacted when evaluating
+		{Name: "quiet", URL: "http://127.0.0.1:1"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, dispatcher.SetRoutes([]config.RouteConfig{
+		{Name: "infra-failures", Projects: []string{"infra"}, Statuses: []string{"failed"}, Webhooks: []string{"oncall"}},
+		{Name: "docs", Tags: []string{"docs"}, Webhooks: []string{"quiet"}},
+	}))
+	handler := NewWebhookHandler(dispatcher)
+
+	body := `{"task": {"id": "abc", "status": "failed", "tags": ["project:infra"]}}`
+	req := httptest.NewRequest("POST", "/api/webhooks/evaluate", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	middleware.Error(handler.EvaluateRoutes)(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var evaluation webhook.Evaluation
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &evaluation))
+	assert.Equal(t, []string{"infra-failures"}, evaluation.Routes)
+	assert.Equal(t, []string{"oncall"}, evaluation.Webhooks)
+}
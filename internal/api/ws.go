@@ -3,18 +3,23 @@ package api
 import (
 	"net/http"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
 )
 
 // WSHandler handles WebSocket connections
 type WSHandler struct {
-	hub *hub.Hub
+	hub     *hub.Hub
+	manager WorkerLister
 }
 
 // NewWSHandler creates a new WebSocket handler
-func NewWSHandler(h *hub.Hub) *WSHandler {
+func NewWSHandler(h *hub.Hub, manager WorkerLister) *WSHandler {
 	return &WSHandler{
-		hub: h,
+		hub:     h,
+		manager: manager,
 	}
 }
 
@@ -22,3 +27,33 @@ func NewWSHandler(h *hub.Hub) *WSHandler {
 func (h *WSHandler) ServeWS(w http.ResponseWriter, r *http.Request) {
 	h.hub.ServeWS(w, r)
 }
+
+// ServeTaskWS handles WebSocket upgrade requests from clients that only want
+// the events of a single task
+func (h *WSHandler) ServeTaskWS(w http.ResponseWriter, r *http.Request) error {
+	taskID := chi.URLParam(r, "id")
+
+	workers, err := h.manager.ListWorkers()
+	if err != nil {
+		return apierr.WrapInternal(err, "Failed to get tasks")
+	}
+	for _, worker := range workers {
+		if worker.ID == taskID {
+			h.hub.ServeRoom(w, r, TaskRoom(taskID))
+			return nil
+		}
+	}
+
+	return apierr.NotFound("Task not found")
+}
+
+// GetStats returns the connected WebSocket clients with their subscriptions,
+// send queue depths and dropped message counts
+func (h *WSHandler) GetStats(w http.ResponseWriter, r *http.Request) error {
+	return response.OK(w, h.hub.Stats())
+}
+
+// TaskRoom returns the hub room that receives a task's events
+func TaskRoom(taskID string) string {
+	return "task:" + taskID
+}
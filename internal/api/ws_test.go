@@ -0,0 +1,142 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	errormw "github.com/brettsmith212/amp-orchestrator-2/internal/middleware"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+// readEvent reads the next event from a WebSocket connection, skipping the
+// session message every client is sent first. Queued events may be batched
+// into one frame, separated by newlines.
+func readEvent(t *testing.T, conn *websocket.Conn) []byte {
+	t.Helper()
+	for {
+		_, frame, err := conn.ReadMessage()
+		require.NoError(t, err)
+		for _, message := range bytes.Split(frame, []byte("\n")) {
+			if msg, err := hub.ParseMessage(message); err == nil && msg.Type == hub.MessageTypeSession {
+				continue
+			}
+			return message
+		}
+	}
+}
+
+func TestServeTaskWS(t *testing.T) {
+	handler, manager := setupHierarchyHandler(t)
+	wsHandler := NewWSHandler(handler.hub, manager)
+
+	r := chi.NewRouter()
+	r.Get("/api/tasks/{id}/ws", errormw.Error(wsHandler.ServeTaskWS))
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	t.Run("unknown task", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/api/tasks/missing/ws")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("only delivers the task's events", func(t *testing.T) {
+		wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/tasks/child1/ws"
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		// Let the hub register the client before broadcasting
+		time.Sleep(20 * time.Millisecond)
+		handler.BroadcastLogEvent(worker.LogLine{WorkerID: "parent", Content: "other task"})
+		handler.BroadcastLogEvent(worker.LogLine{WorkerID: "child1", Content: "\x1b]0;amp\x07\x1b[32mthis task\r"})
+
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		message := readEvent(t, conn)
+
+		var event LogEvent
+		require.NoError(t, json.Unmarshal(message, &event))
+		assert.Equal(t, "child1", event.Data.WorkerID)
+		// Control sequences a browser can't render are removed, colors kept
+		assert.Equal(t, "\x1b[32mthis task", event.Data.Content)
+	})
+}
+
+func TestGetWSStats(t *testing.T) {
+	handler, manager := setupHierarchyHandler(t)
+	wsHandler := NewWSHandler(handler.hub, manager)
+
+	r := chi.NewRouter()
+	r.Get("/api/ws", wsHandler.ServeWS)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/ws", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	time.Sleep(20 * time.Millisecond)
+
+	req := httptest.NewRequest("GET", "/api/ws/stats", nil)
+	w := httptest.NewRecorder()
+	errormw.Error(wsHandler.GetStats)(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var stats hub.Stats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, 1, stats.Clients)
+	require.Len(t, stats.ClientStats, 1)
+	assert.Equal(t, 256, stats.ClientStats[0].QueueCapacity)
+	assert.Zero(t, stats.DroppedMessages)
+}
+
+func TestBroadcast_TagSubscription(t *testing.T) {
+	handler, _ := setupHierarchyHandler(t)
+
+	client := handler.hub.NewLocalClient("", nil)
+	handler.hub.Register(client)
+	subscribe, err := hub.CreateMessage(hub.MessageTypeSubscribe, hub.SubscribeMessage{Tags: []string{"urgent"}})
+	require.NoError(t, err)
+	raw, err := hub.MarshalMessage(subscribe)
+	require.NoError(t, err)
+	client.Receive(raw)
+
+	// Task updates tell the hub which tasks carry the tag
+	handler.broadcastTaskUpdate(TaskDTO{ID: "child1", Tags: []string{"urgent"}})
+	handler.BroadcastLogEvent(worker.LogLine{WorkerID: "child2", Content: "untagged"})
+	handler.BroadcastLogEvent(worker.LogLine{WorkerID: "child1", Content: "tagged"})
+
+	var types []string
+	var logs []string
+	timeout := time.After(time.Second)
+	for len(logs) == 0 {
+		select {
+		case message := <-client.Messages():
+			var event struct {
+				Type string  `json:"type"`
+				Data LogData `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(message, &event))
+			types = append(types, event.Type)
+			if event.Type == "log" {
+				logs = append(logs, event.Data.Content)
+			}
+		case <-timeout:
+			t.Fatal("no log event received")
+		}
+	}
+	assert.Equal(t, []string{"task-update", "log"}, types)
+	assert.Equal(t, []string{"tagged"}, logs)
+}
@@ -0,0 +1,156 @@
+// Package audit records mutating API calls so teams can trace who changed a task
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcomes of an audited call
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Entry is one audited API call
+type Entry struct {
+	ID         string    `json:"id"`
+	Time       time.Time `json:"time"`
+	User       string    `json:"user"` // "anonymous" when the caller presented no valid token
+	RemoteAddr string    `json:"remote_addr"`
+	RequestID  string    `json:"request_id,omitempty"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Endpoint   string    `json:"endpoint,omitempty"` // Route pattern, e.g. /api/tasks/{id}/abort
+	TaskID     string    `json:"task_id,omitempty"`
+	Status     int       `json:"status"`
+	Outcome    string    `json:"outcome"`
+}
+
+// Filter selects entries from the audit log. Zero fields match any entry.
+type Filter struct {
+	User    string
+	TaskID  string
+	Method  string
+	Outcome string
+	Since   time.Time // Entries at or after this time
+	Until   time.Time // Entries before this time
+	Limit   int       // Maximum entries returned
+}
+
+// matches reports whether an entry passes the filter
+func (f Filter) matches(e Entry) bool {
+	if f.User != "" && e.User != f.User {
+		return false
+	}
+	if f.TaskID != "" && e.TaskID != f.TaskID {
+		return false
+	}
+	if f.Method != "" && !strings.EqualFold(e.Method, f.Method) {
+		return false
+	}
+	if f.Outcome != "" && e.Outcome != f.Outcome {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Log is an append-only JSON lines file of audit entries
+type Log struct {
+	path string
+	mu   sync.Mutex
+	file *os.File
+}
+
+// Open opens the audit log at path, creating it if needed
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &Log{path: path, file: file}, nil
+}
+
+// Record appends an entry to the log
+func (l *Log) Record(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return errors.New("audit log is closed")
+	}
+	_, err = l.file.Write(append(line, '\n'))
+	return err
+}
+
+// Query returns the entries matching the filter, newest first
+func (l *Log) Query(filter Filter) ([]Entry, error) {
+	file, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Entry{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	var matched []Entry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A partial line from a crash mid-write; skip it
+			continue
+		}
+		if filter.matches(entry) {
+			matched = append(matched, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(matched))
+	for i := len(matched) - 1; i >= 0; i-- {
+		if filter.Limit > 0 && len(entries) == filter.Limit {
+			break
+		}
+		entries = append(entries, matched[i])
+	}
+	return entries, nil
+}
+
+// Close closes the log
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
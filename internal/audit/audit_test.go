@@ -0,0 +1,70 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog_RecordAndQuery(t *testing.T) {
+	log, err := Open(filepath.Join(t.TempDir(), "audit", "audit.jsonl"))
+	require.NoError(t, err)
+	defer log.Close()
+
+	start := time.Date(2025, 6, 4, 12, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{ID: "1", Time: start, User: "alice", Method: "POST", TaskID: "a", Status: 200, Outcome: OutcomeSuccess},
+		{ID: "2", Time: start.Add(time.Minute), User: "bob", Method: "DELETE", TaskID: "a", Status: 409, Outcome: OutcomeFailure},
+		{ID: "3", Time: start.Add(2 * time.Minute), User: "alice", Method: "POST", TaskID: "b", Status: 200, Outcome: OutcomeSuccess},
+	}
+	for _, entry := range entries {
+		require.NoError(t, log.Record(entry))
+	}
+
+	ids := func(filter Filter) []string {
+		found, err := log.Query(filter)
+		require.NoError(t, err)
+		result := []string{}
+		for _, entry := range found {
+			result = append(result, entry.ID)
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"3", "2", "1"}, ids(Filter{}))
+	assert.Equal(t, []string{"3", "1"}, ids(Filter{User: "alice"}))
+	assert.Equal(t, []string{"2", "1"}, ids(Filter{TaskID: "a"}))
+	assert.Equal(t, []string{"2"}, ids(Filter{Method: "delete"}))
+	assert.Equal(t, []string{"2"}, ids(Filter{Outcome: OutcomeFailure}))
+	assert.Equal(t, []string{"2"}, ids(Filter{Since: start.Add(time.Minute), Until: start.Add(2 * time.Minute)}))
+	assert.Equal(t, []string{"3"}, ids(Filter{Limit: 1}))
+}
+
+func TestLog_SurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	log, err := Open(path)
+	require.NoError(t, err)
+	require.NoError(t, log.Record(Entry{ID: "1", Time: time.Now()}))
+	require.NoError(t, log.Close())
+	assert.Error(t, log.Record(Entry{ID: "2"}))
+
+	// A torn final line is skipped
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	file.WriteString(`{"id":"trunc`)
+	file.Close()
+
+	log, err = Open(path)
+	require.NoError(t, err)
+	defer log.Close()
+
+	entries, err := log.Query(Filter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "1", entries[0].ID)
+}
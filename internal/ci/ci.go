@@ -0,0 +1,99 @@
+// Package ci reads the results of CI checks on pull requests from GitHub
+// webhooks, so tasks whose pull requests are checked reflect the outcome
+package ci
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+// GitHub events carrying check results
+const (
+	EventCheckRun   = "check_run"
+	EventCheckSuite = "check_suite"
+)
+
+// Check is the result of one CI check on the pull requests of a commit
+type Check struct {
+	Repo         string          `json:"repo"` // "owner/name"
+	PullRequests []int           `json:"pull_requests"`
+	Name         string          `json:"name"` // The check run's name, or the app that ran the check suite
+	Status       worker.CIStatus `json:"status"`
+}
+
+// checkPayload is the part of a check run or check suite that Check is read from
+type checkPayload struct {
+	Name         string `json:"name"`
+	Status       string `json:"status"`
+	Conclusion   string `json:"conclusion"`
+	PullRequests []struct {
+		Number int `json:"number"`
+	} `json:"pull_requests"`
+	App struct {
+		Name string `json:"name"`
+	} `json:"app"`
+}
+
+// ParseGitHub reads the check from a GitHub "check_run" or "check_suite"
+// webhook payload
+func ParseGitHub(event string, body []byte) (*Check, error) {
+	var payload struct {
+		CheckRun   *checkPayload `json:"check_run"`
+		CheckSuite *checkPayload `json:"check_suite"`
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid GitHub payload: %w", err)
+	}
+
+	var source *checkPayload
+	var name string
+	switch event {
+	case EventCheckRun:
+		source = payload.CheckRun
+		if source != nil {
+			name = source.Name
+		}
+	case EventCheckSuite:
+		source = payload.CheckSuite
+		if source != nil {
+			name = source.App.Name
+		}
+	default:
+		return nil, fmt.Errorf("unsupported GitHub event %q", event)
+	}
+	if source == nil || name == "" || payload.Repository.FullName == "" {
+		return nil, errors.New("GitHub payload has no check")
+	}
+
+	check := &Check{
+		Repo:         payload.Repository.FullName,
+		PullRequests: []int{},
+		Name:         name,
+		Status:       status(source.Status, source.Conclusion),
+	}
+	for _, pr := range source.PullRequests {
+		check.PullRequests = append(check.PullRequests, pr.Number)
+	}
+	return check, nil
+}
+
+// status maps a GitHub check status and conclusion to a CI status. Checks
+// that completed without succeeding, including cancelled ones, fail.
+func status(status, conclusion string) worker.CIStatus {
+	if status != "completed" {
+		return worker.CIPending
+	}
+	switch conclusion {
+	case "success", "neutral", "skipped":
+		return worker.CIPassing
+	case "stale":
+		return worker.CIPending
+	}
+	return worker.CIFailing
+}
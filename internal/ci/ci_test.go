@@ -0,0 +1,53 @@
+package ci
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+func TestParseGitHub_CheckRun(t *testing.T) {
+	check, err := ParseGitHub(EventCheckRun, []byte(`{
+		"action": "completed",
+		"check_run": {"name": "test", "status": "completed", "conclusion": "failure",
+			"pull_requests": [{"number": 7}], "app": {"name": "GitHub Actions"}},
+		"repository": {"full_name": "acme/api"}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, &Check{
+		Repo:         "acme/api",
+		PullRequests: []int{7},
+		Name:         "test",
+		Status:       worker.CIFailing,
+	}, check)
+}
+
+func TestParseGitHub_CheckSuite(t *testing.T) {
+	check, err := ParseGitHub(EventCheckSuite, []byte(`{
+		"action": "requested",
+		"check_suite": {"status": "queued", "conclusion": null, "pull_requests": [], "app": {"name": "CircleCI"}},
+		"repository": {"full_name": "acme/api"}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, "CircleCI", check.Name)
+	assert.Equal(t, worker.CIPending, check.Status)
+	assert.Equal(t, []int{}, check.PullRequests)
+
+	_, err = ParseGitHub(EventCheckSuite, []byte(`{"check_run": {"name": "test"}, "repository": {"full_name": "acme/api"}}`))
+	assert.Error(t, err)
+	_, err = ParseGitHub("push", []byte(`{}`))
+	assert.Error(t, err)
+	_, err = ParseGitHub(EventCheckRun, []byte(`not json`))
+	assert.Error(t, err)
+}
+
+func TestStatus(t *testing.T) {
+	assert.Equal(t, worker.CIPending, status("in_progress", ""))
+	assert.Equal(t, worker.CIPassing, status("completed", "success"))
+	assert.Equal(t, worker.CIPassing, status("completed", "skipped"))
+	assert.Equal(t, worker.CIFailing, status("completed", "timed_out"))
+	assert.Equal(t, worker.CIFailing, status("completed", "cancelled"))
+}
@@ -0,0 +1,241 @@
+// Package git inspects the git checkouts tasks work in
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotRepository is returned when a checkout isn't a git repository
+	ErrNotRepository = errors.New("not a git repository")
+
+	// ErrUnknownRevision is returned when a branch or commit doesn't exist
+	ErrUnknownRevision = errors.New("unknown revision")
+
+	// ErrNothingToCommit is returned when committing a working tree without changes
+	ErrNothingToCommit = errors.New("nothing to commit")
+)
+
+// Repo runs git in a repository checkout
+type Repo struct {
+	Dir string
+}
+
+// Diff is the change made in a working tree since it branched from a base
+type Diff struct {
+	Base      string   `json:"base"`       // Branch the changes are compared with
+	MergeBase string   `json:"merge_base"` // Commit the working tree branched from
+	Files     []string `json:"files"`      // Changed files, including untracked ones
+	Patch     string   `json:"patch"`      // Unified diff
+}
+
+// Diff returns the changes in the working tree, committed or not, since it
+// branched from base. Untracked files are included as new files. paths limit
+// the diff to the given files or directories.
+func (r Repo) Diff(ctx context.Context, base string, paths ...string) (*Diff, error) {
+	mergeBase, err := r.run(ctx, "merge-base", base, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	mergeBase = strings.TrimSpace(mergeBase)
+
+	diff := &Diff{Base: base, MergeBase: mergeBase}
+
+	names, err := r.run(ctx, append([]string{"diff", "--name-only", mergeBase, "--"}, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := r.run(ctx, append([]string{"diff", mergeBase, "--"}, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	diff.Files = lines(names)
+	diff.Patch = patch
+
+	untracked, err := r.run(ctx, append([]string{"ls-files", "--others", "--exclude-standard", "--"}, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range lines(untracked) {
+		// --no-index exits with 1 when the files differ, which they always do
+		patch, err := r.run(ctx, "diff", "--no-index", "--", "/dev/null", file)
+		var exitErr *exec.ExitError
+		if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+			return nil, err
+		}
+		diff.Files = append(diff.Files, file)
+		diff.Patch += patch
+	}
+	sort.Strings(diff.Files)
+
+	return diff, nil
+}
+
+// Commit is a commit made on a branch
+type Commit struct {
+	Hash       string    `json:"hash"`
+	Author     string    `json:"author"`
+	AuthoredAt time.Time `json:"authored_at"`
+	Subject    string    `json:"subject"`
+	Message    string    `json:"message"` // Full message, including the subject
+	Files      int       `json:"files"`   // Files changed
+	Insertions int       `json:"insertions"`
+	Deletions  int       `json:"deletions"`
+}
+
+// Field and record separators in the log format read by Commits
+const (
+	fieldSeparator  = "\x1f"
+	recordSeparator = "\x1e"
+)
+
+// Commits returns the commits on HEAD since it branched from base, newest
+// first, with their line counts. Binary files count as changed files only.
+func (r Repo) Commits(ctx context.Context, base string) ([]Commit, error) {
+	mergeBase, err := r.run(ctx, "merge-base", base, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	format := "--format=" + recordSeparator + strings.Join([]string{"%H", "%an", "%aI", "%B"}, fieldSeparator) + fieldSeparator
+	output, err := r.run(ctx, "log", "--numstat", format, strings.TrimSpace(mergeBase)+"..HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	commits := []Commit{}
+	for _, record := range strings.Split(output, recordSeparator) {
+		fields := strings.Split(record, fieldSeparator)
+		if len(fields) != 5 {
+			continue
+		}
+
+		commit := Commit{
+			Hash:    fields[0],
+			Author:  fields[1],
+			Message: strings.TrimSpace(fields[3]),
+		}
+		commit.AuthoredAt, _ = time.Parse(time.RFC3339, fields[2])
+		commit.Subject, _, _ = strings.Cut(commit.Message, "\n")
+
+		// Each numstat line is "<insertions>\t<deletions>\t<path>", with "-"
+		// counts for binary files
+		for _, line := range lines(fields[4]) {
+			counts := strings.SplitN(line, "\t", 3)
+			if len(counts) != 3 {
+				continue
+			}
+			commit.Files++
+			insertions, _ := strconv.Atoi(counts[0])
+			deletions, _ := strconv.Atoi(counts[1])
+			commit.Insertions += insertions
+			commit.Deletions += deletions
+		}
+		commits = append(commits, commit)
+	}
+	return commits, nil
+}
+
+// MergeCheck is the outcome of merging HEAD into a target branch
+type MergeCheck struct {
+	Target    string   `json:"target"`    // Branch HEAD would be merged into
+	Mergeable bool     `json:"mergeable"` // Whether the merge is free of conflicts
+	Conflicts []string `json:"conflicts"` // Files with conflicts, sorted
+}
+
+// MergeCheck merges HEAD into target in memory and reports the files that
+// would conflict. Only committed changes take part; neither the checkout nor
+// any branch is changed.
+func (r Repo) MergeCheck(ctx context.Context, target string) (*MergeCheck, error) {
+	output, err := r.run(ctx, "merge-tree", "--write-tree", "--name-only", "--no-messages", target, "HEAD")
+
+	// merge-tree exits with 1 when the merge has conflicts
+	var exitErr *exec.ExitError
+	conflicted := errors.As(err, &exitErr) && exitErr.ExitCode() == 1
+	if err != nil && !conflicted {
+		return nil, err
+	}
+
+	check := &MergeCheck{Target: target, Mergeable: !conflicted, Conflicts: []string{}}
+	if !conflicted {
+		return check, nil
+	}
+
+	// The first line is the merged tree, followed by each conflicted file
+	seen := make(map[string]bool)
+	for i, file := range lines(output) {
+		if i > 0 && !seen[file] {
+			check.Conflicts = append(check.Conflicts, file)
+			seen[file] = true
+		}
+	}
+	sort.Strings(check.Conflicts)
+	return check, nil
+}
+
+// CommitAll stages every change in the working tree, including untracked
+// files, commits it with message and returns the new commit's hash
+func (r Repo) CommitAll(ctx context.Context, message string) (string, error) {
+	if _, err := r.run(ctx, "add", "-A"); err != nil {
+		return "", err
+	}
+
+	status, err := r.run(ctx, "status", "--porcelain")
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(status) == "" {
+		return "", ErrNothingToCommit
+	}
+
+	if _, err := r.run(ctx, "commit", "-q", "-m", message); err != nil {
+		return "", err
+	}
+	hash, err := r.run(ctx, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(hash), nil
+}
+
+// run runs git with args in the checkout and returns its output
+func (r Repo) run(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", r.Dir}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		switch {
+		case strings.Contains(message, "not a git repository"):
+			return "", fmt.Errorf("%w: %s", ErrNotRepository, r.Dir)
+		case strings.Contains(message, "Not a valid object name"),
+			strings.Contains(message, "unknown revision"),
+			strings.Contains(message, "bad revision"),
+			strings.Contains(message, "not something we can merge"):
+			return "", fmt.Errorf("%w: %s", ErrUnknownRevision, message)
+		}
+		return stdout.String(), fmt.Errorf("git %s: %w: %s", args[0], err, message)
+	}
+	return stdout.String(), nil
+}
+
+// lines splits output into its non-empty lines
+func lines(output string) []string {
+	var result []string
+	for _, line := range strings.Split(output, "\n") {
+		if line != "" {
+			result = append(result, line)
+		}
+	}
+	return result
+}
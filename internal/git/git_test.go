@@ -0,0 +1,171 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupRepo creates a repository whose task branch has a committed change,
+// an uncommitted change and an untracked file, while main moved on
+func setupRepo(t *testing.T) string {
+	dir := t.TempDir()
+	gitCmd := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	write := func(name, content string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	gitCmd("init", "-q", "-b", "main")
+	write("README.md", "hello\n")
+	write("src/app.go", "package app\n")
+	gitCmd("add", ".")
+	gitCmd("commit", "-q", "-m", "initial")
+
+	gitCmd("checkout", "-q", "-b", "task")
+	write("src/app.go", "package app\n\nfunc Run() {}\n")
+	gitCmd("commit", "-q", "-am", "add Run")
+
+	gitCmd("checkout", "-q", "main")
+	write("CHANGELOG.md", "v1\n")
+	gitCmd("add", ".")
+	gitCmd("commit", "-q", "-m", "changelog")
+	gitCmd("checkout", "-q", "task")
+
+	write("README.md", "hello world\n")
+	write("src/notes.txt", "todo\n")
+	return dir
+}
+
+func TestDiff(t *testing.T) {
+	dir := setupRepo(t)
+
+	diff, err := Repo{Dir: dir}.Diff(context.Background(), "main")
+	require.NoError(t, err)
+	assert.Equal(t, "main", diff.Base)
+	assert.Len(t, diff.MergeBase, 40)
+
+	// Changes on main since the task branched aren't included
+	assert.Equal(t, []string{"README.md", "src/app.go", "src/notes.txt"}, diff.Files)
+	assert.Contains(t, diff.Patch, "+func Run() {}")
+	assert.Contains(t, diff.Patch, "+hello world")
+	assert.Contains(t, diff.Patch, "+++ b/src/notes.txt")
+	assert.NotContains(t, diff.Patch, "CHANGELOG")
+}
+
+func TestDiff_Paths(t *testing.T) {
+	dir := setupRepo(t)
+
+	diff, err := Repo{Dir: dir}.Diff(context.Background(), "main", "src")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"src/app.go", "src/notes.txt"}, diff.Files)
+	assert.NotContains(t, diff.Patch, "README")
+
+	diff, err = Repo{Dir: dir}.Diff(context.Background(), "main", "README.md")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"README.md"}, diff.Files)
+}
+
+func TestDiff_Errors(t *testing.T) {
+	_, err := Repo{Dir: t.TempDir()}.Diff(context.Background(), "main")
+	assert.ErrorIs(t, err, ErrNotRepository)
+
+	_, err = Repo{Dir: setupRepo(t)}.Diff(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrUnknownRevision)
+}
+
+func TestCommits(t *testing.T) {
+	dir := setupRepo(t)
+
+	commits, err := Repo{Dir: dir}.Commits(context.Background(), "main")
+	require.NoError(t, err)
+
+	// Only the task branch's commit; main's changelog commit isn't included
+	require.Len(t, commits, 1)
+	commit := commits[0]
+	assert.Len(t, commit.Hash, 40)
+	assert.Equal(t, "test", commit.Author)
+	assert.False(t, commit.AuthoredAt.IsZero())
+	assert.Equal(t, "add Run", commit.Subject)
+	assert.Equal(t, "add Run", commit.Message)
+	assert.Equal(t, 1, commit.Files)
+	assert.Equal(t, 2, commit.Insertions)
+	assert.Equal(t, 0, commit.Deletions)
+
+	commits, err = Repo{Dir: dir}.Commits(context.Background(), "task")
+	require.NoError(t, err)
+	assert.Empty(t, commits)
+
+	_, err = Repo{Dir: dir}.Commits(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrUnknownRevision)
+}
+
+func TestMergeCheck(t *testing.T) {
+	dir := setupRepo(t)
+
+	// The task branch and main changed different files
+	check, err := Repo{Dir: dir}.MergeCheck(context.Background(), "main")
+	require.NoError(t, err)
+	assert.True(t, check.Mergeable)
+	assert.Equal(t, []string{}, check.Conflicts)
+
+	gitCmd := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	gitCmd("stash", "-q", "-u")
+	gitCmd("checkout", "-q", "main")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "src/app.go"), []byte("package app\n\nfunc Stop() {}\n"), 0644))
+	gitCmd("commit", "-q", "-am", "add Stop")
+	gitCmd("checkout", "-q", "task")
+
+	check, err = Repo{Dir: dir}.MergeCheck(context.Background(), "main")
+	require.NoError(t, err)
+	assert.Equal(t, "main", check.Target)
+	assert.False(t, check.Mergeable)
+	assert.Equal(t, []string{"src/app.go"}, check.Conflicts)
+
+	// The checkout is left alone
+	out, err := exec.Command("git", "-C", dir, "status", "--porcelain").Output()
+	require.NoError(t, err)
+	assert.Empty(t, string(out))
+
+	_, err = Repo{Dir: dir}.MergeCheck(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrUnknownRevision)
+}
+
+func TestCommitAll(t *testing.T) {
+	dir := setupRepo(t)
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	repo := Repo{Dir: dir}
+
+	hash, err := repo.CommitAll(context.Background(), "update readme\n\nAnd notes.")
+	require.NoError(t, err)
+	assert.Len(t, hash, 40)
+
+	// The modified and untracked files are both committed
+	commits, err := repo.Commits(context.Background(), "main")
+	require.NoError(t, err)
+	require.Len(t, commits, 2)
+	assert.Equal(t, hash, commits[0].Hash)
+	assert.Equal(t, "update readme", commits[0].Subject)
+	assert.Equal(t, 2, commits[0].Files)
+
+	_, err = repo.CommitAll(context.Background(), "again")
+	assert.ErrorIs(t, err, ErrNothingToCommit)
+}
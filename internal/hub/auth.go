@@ -0,0 +1,127 @@
+package hub
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// authTimeout is how long a connection may take to send its auth message
+const authTimeout = 10 * time.Second
+
+// Identity is the authenticated user behind a WebSocket connection
+type Identity struct {
+	User string `json:"user"`
+	Role string `json:"role,omitempty"`
+}
+
+// Authenticator resolves a token to the identity it belongs to, reporting
+// false for unknown tokens
+type Authenticator func(token string) (*Identity, bool)
+
+// TokenAuthenticator authenticates against a fixed set of tokens
+func TokenAuthenticator(tokens map[string]Identity) Authenticator {
+	return func(token string) (*Identity, bool) {
+		for known, identity := range tokens {
+			if subtle.ConstantTimeCompare([]byte(known), []byte(token)) == 1 {
+				identity := identity
+				return &identity, true
+			}
+		}
+		return nil, false
+	}
+}
+
+// identityKey is the context key of an identity that was authenticated
+// before a request was made
+type identityKey struct{}
+
+// WithIdentity returns a context whose requests are made on behalf of an
+// identity that was already authenticated, such as a WebSocket client's
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// Identify returns the identity behind the token presented with an HTTP
+// request, or nil when there is no token or it isn't valid. Requests made
+// with a context from WithIdentity carry their identity instead.
+func (a Authenticator) Identify(r *http.Request) *Identity {
+	if a == nil {
+		return nil
+	}
+	if identity, ok := r.Context().Value(identityKey{}).(*Identity); ok && identity != nil {
+		return identity
+	}
+	token := requestToken(r)
+	if token == "" {
+		return nil
+	}
+	identity, _ := a(token)
+	return identity
+}
+
+// AuthMessage is the first message sent by a client authenticating over the
+// WebSocket rather than with the upgrade request
+type AuthMessage struct {
+	Token string `json:"token"`
+}
+
+// SetAuthenticator requires every WebSocket client to authenticate, either
+// with a token on the upgrade request or with an auth message sent first
+func (h *Hub) SetAuthenticator(authenticate Authenticator) {
+	h.authenticate = authenticate
+}
+
+// requestToken returns the token presented with the upgrade request, from the
+// token query parameter or a bearer Authorization header
+func requestToken(r *http.Request) string {
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return ""
+}
+
+// awaitHandshake reads the connection's first message, which must be an auth
+// message carrying a valid token
+func (h *Hub) awaitHandshake(conn *websocket.Conn) (*Identity, error) {
+	conn.SetReadLimit(h.limits.MaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(authTimeout))
+
+	_, raw, err := conn.ReadMessage()
+	if err != nil {
+		return nil, fmt.Errorf("no auth message received: %w", err)
+	}
+
+	msg, err := ParseMessage(raw)
+	if err != nil || msg.Type != MessageTypeAuth {
+		return nil, errors.New("first message must be an auth message")
+	}
+
+	var auth AuthMessage
+	if err := json.Unmarshal(msg.Data, &auth); err != nil || auth.Token == "" {
+		return nil, errors.New("auth message has no token")
+	}
+
+	identity, ok := h.authenticate(auth.Token)
+	if !ok {
+		return nil, errors.New("invalid token")
+	}
+	return identity, nil
+}
+
+// rejectConnection closes a connection that failed to authenticate
+func (h *Hub) rejectConnection(conn *websocket.Conn) {
+	closeMessage := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "authentication required")
+	conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(h.limits.WriteWait))
+	conn.Close()
+}
@@ -0,0 +1,147 @@
+package hub
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startAuthHub starts a hub that accepts the token "secret" for alice
+func startAuthHub(t *testing.T) (*Hub, string) {
+	t.Helper()
+
+	hub := NewHub()
+	hub.SetAuthenticator(TokenAuthenticator(map[string]Identity{
+		"secret": {User: "alice", Role: "admin"},
+	}))
+	runHub(t, hub)
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	t.Cleanup(server.Close)
+
+	return hub, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// registeredIdentity waits for a single registered client and returns its identity
+func registeredIdentity(t *testing.T, hub *Hub) *Identity {
+	t.Helper()
+
+	var client *Client
+	require.Eventually(t, func() bool {
+		hub.mu.RLock()
+		defer hub.mu.RUnlock()
+		for c := range hub.clients {
+			client = c
+		}
+		return client != nil
+	}, time.Second, 10*time.Millisecond)
+	return client.Identity()
+}
+
+func TestServeWS_QueryTokenAuth(t *testing.T) {
+	hub, wsURL := startAuthHub(t)
+
+	conn, _, err := dialTest(wsURL+"?token=secret", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assert.Equal(t, &Identity{User: "alice", Role: "admin"}, registeredIdentity(t, hub))
+
+	hub.Broadcast([]byte(`{"type":"log"}`))
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	message, err := conn.next()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"log"}`, string(message))
+}
+
+func TestServeWS_BearerTokenAuth(t *testing.T) {
+	hub, wsURL := startAuthHub(t)
+
+	header := http.Header{"Authorization": []string{"Bearer secret"}}
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, header)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assert.Equal(t, "alice", registeredIdentity(t, hub).User)
+}
+
+func TestServeWS_InvalidQueryTokenRejected(t *testing.T) {
+	_, wsURL := startAuthHub(t)
+
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL+"?token=wrong", nil)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestServeWS_HandshakeAuth(t *testing.T) {
+	hub, wsURL := startAuthHub(t)
+
+	conn, _, err := dialTest(wsURL, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"auth","data":{"token":"secret"}}`)))
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	raw, err := conn.next()
+	require.NoError(t, err)
+
+	msg, err := ParseMessage(raw)
+	require.NoError(t, err)
+	assert.Equal(t, MessageTypeAuthOK, msg.Type)
+	assert.JSONEq(t, `{"user":"alice","role":"admin"}`, string(msg.Data))
+
+	assert.Equal(t, "alice", registeredIdentity(t, hub).User)
+}
+
+func TestServeWS_HandshakeRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+	}{
+		{"invalid token", `{"type":"auth","data":{"token":"wrong"}}`},
+		{"missing token", `{"type":"auth","data":{}}`},
+		{"not an auth message", `{"type":"subscribe","data":{"types":["log"]}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub, wsURL := startAuthHub(t)
+
+			conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+			require.NoError(t, err)
+			defer conn.Close()
+
+			require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(tt.message)))
+
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			_, _, err = conn.ReadMessage()
+			assert.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation), "unexpected error: %v", err)
+
+			hub.mu.RLock()
+			assert.Empty(t, hub.clients)
+			hub.mu.RUnlock()
+		})
+	}
+}
+
+func TestServeWS_NoAuthenticatorAcceptsAnyone(t *testing.T) {
+	hub := NewHub()
+	runHub(t, hub)
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assert.Nil(t, registeredIdentity(t, hub))
+}
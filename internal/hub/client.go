@@ -2,27 +2,16 @@ package hub
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-const (
-	// Time allowed to write a message to the peer
-	writeWait = 10 * time.Second
-
-	// Time allowed to read the next pong message from the peer
-	pongWait = 60 * time.Second
-
-	// Send pings to peer with this period. Must be less than pongWait
-	pingPeriod = (pongWait * 9) / 10
-
-	// Maximum message size allowed from peer
-	maxMessageSize = 512
-)
-
 var (
 	newline = []byte{'\n'}
 	space   = []byte{' '}
@@ -47,13 +36,56 @@ type Client struct {
 	
 	// Subscription preferences
 	subscribedTypes map[MessageType]bool
-	subscribedTasks map[string]bool
+	subscribedTasks map[string]bool // Task IDs and patterns
+	subscribedTags  map[string]bool // Lowercased task tags
 	
 	// Mutex for thread-safe access to subscription state
 	mu sync.RWMutex
 	
 	// Connection state
 	connected bool
+	
+	// Sequence number the client is resuming from, if any
+	resumeFrom *uint64
+	
+	// Session token the client reconnected with, and the session it holds;
+	// local clients have no session
+	sessionToken string
+	session      atomic.Pointer[session]
+	
+	// Authenticated user; nil when authentication is disabled
+	identity *Identity
+	
+	// Room the client receives events for; empty receives every event
+	room string
+	
+	// When the connection was established
+	connectedAt time.Time
+	
+	// Messages dropped because the send queue was full
+	dropped atomic.Uint64
+	
+	// Whether the hub removed the client and closed its send queue; guarded
+	// by the hub's mutex
+	removed bool
+}
+
+// Identity returns the authenticated user behind the connection, or nil when
+// authentication is disabled
+func (c *Client) Identity() *Identity {
+	return c.identity
+}
+
+// String identifies the client in logs, including its user when authenticated
+func (c *Client) String() string {
+	name := c.id
+	if c.room != "" {
+		name = fmt.Sprintf("%s [room %s]", name, c.room)
+	}
+	if c.identity != nil {
+		return fmt.Sprintf("%s (user %s)", name, c.identity.User)
+	}
+	return name
 }
 
 // readPump pumps messages from the websocket connection to the hub
@@ -67,10 +99,11 @@ func (c *Client) readPump() {
 		c.conn.Close()
 	}()
 
-	c.conn.SetReadLimit(maxMessageSize)
-	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	limits := c.hub.limits
+	c.conn.SetReadLimit(limits.MaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(limits.PongWait))
 	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		c.conn.SetReadDeadline(time.Now().Add(limits.PongWait))
 		c.UpdateLastPong()
 		return nil
 	})
@@ -95,7 +128,8 @@ func (c *Client) readPump() {
 // application ensures that there is at most one writer to a connection by
 // executing all writes from this goroutine.
 func (c *Client) writePump() {
-	ticker := time.NewTicker(pingPeriod)
+	limits := c.hub.limits
+	ticker := time.NewTicker(limits.PingPeriod)
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
@@ -104,7 +138,7 @@ func (c *Client) writePump() {
 	for {
 		select {
 		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.conn.SetWriteDeadline(time.Now().Add(limits.WriteWait))
 			if !ok {
 				// The hub closed the channel
 				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
@@ -116,20 +150,24 @@ func (c *Client) writePump() {
 				return
 			}
 			w.Write(message)
+			written := [][]byte{message}
 
 			// Add queued messages to the current websocket message
 			n := len(c.send)
 			for i := 0; i < n; i++ {
+				queued := <-c.send
 				w.Write(newline)
-				w.Write(<-c.send)
+				w.Write(queued)
+				written = append(written, queued)
 			}
 
 			if err := w.Close(); err != nil {
 				return
 			}
+			c.recordDelivery(written)
 
 		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.conn.SetWriteDeadline(time.Now().Add(limits.WriteWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
@@ -156,6 +194,14 @@ func (c *Client) handleMessage(rawMessage []byte) {
 		c.handleSubscribe(msg)
 	case MessageTypeUnsubscribe:
 		c.handleUnsubscribe(msg)
+	case MessageTypeUnsubscribeAll:
+		c.handleUnsubscribeAll()
+	case MessageTypeGetSubscriptions:
+		c.handleGetSubscriptions(msg)
+	case MessageTypeCommand:
+		c.handleCommand(msg)
+	case MessageTypeAuth:
+		// Already authenticated when the connection was established
 	default:
 		log.Printf("Unknown message type from client %s: %s", c.id, msg.Type)
 	}
@@ -194,6 +240,7 @@ func (c *Client) handlePing(msg *WebSocketMessage) {
 	select {
 	case c.send <- pongBytes:
 	default:
+		c.hub.recordDrop(c)
 		log.Printf("Failed to send pong to client %s: send channel full", c.id)
 	}
 }
@@ -208,18 +255,9 @@ func (c *Client) handleSubscribe(msg *WebSocketMessage) {
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.subscribe(subData)
 
-	// Subscribe to message types
-	for _, msgType := range subData.Types {
-		c.subscribedTypes[msgType] = true
-	}
-
-	// Subscribe to specific task IDs
-	for _, taskID := range subData.TaskIDs {
-		c.subscribedTasks[taskID] = true
-	}
-
-	log.Printf("Client %s subscribed to types: %v, tasks: %v", c.id, subData.Types, subData.TaskIDs)
+	log.Printf("Client %s subscribed to types: %v, tasks: %v, tags: %v", c, subData.Types, subData.TaskIDs, subData.Tags)
 }
 
 // handleUnsubscribe processes unsubscription requests
@@ -232,27 +270,61 @@ func (c *Client) handleUnsubscribe(msg *WebSocketMessage) {
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.unsubscribe(subData)
 
-	// Unsubscribe from message types
-	for _, msgType := range subData.Types {
-		delete(c.subscribedTypes, msgType)
+	log.Printf("Client %s unsubscribed from types: %v, tasks: %v, tags: %v", c, subData.Types, subData.TaskIDs, subData.Tags)
+}
+
+// handleUnsubscribeAll clears every subscription, so the client receives all
+// events again
+func (c *Client) handleUnsubscribeAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscribedTypes = make(map[MessageType]bool)
+	c.subscribedTasks = make(map[string]bool)
+	c.subscribedTags = make(map[string]bool)
+
+	log.Printf("Client %s unsubscribed from everything", c)
+}
+
+// handleGetSubscriptions replies with the client's active subscriptions,
+// echoing the request's ID
+func (c *Client) handleGetSubscriptions(msg *WebSocketMessage) {
+	sub := c.subscriptions()
+	reply, err := CreateMessage(MessageTypeSubscriptions, SubscriptionsMessage{
+		Types:   append([]MessageType{}, sub.Types...),
+		TaskIDs: append([]string{}, sub.TaskIDs...),
+		Tags:    append([]string{}, sub.Tags...),
+	})
+	if err != nil {
+		log.Printf("Failed to create subscriptions message for client %s: %v", c.id, err)
+		return
 	}
+	reply.ID = msg.ID
 
-	// Unsubscribe from specific task IDs
-	for _, taskID := range subData.TaskIDs {
-		delete(c.subscribedTasks, taskID)
+	replyBytes, err := MarshalMessage(reply)
+	if err != nil {
+		log.Printf("Failed to marshal subscriptions message for client %s: %v", c.id, err)
+		return
 	}
 
-	log.Printf("Client %s unsubscribed from types: %v, tasks: %v", c.id, subData.Types, subData.TaskIDs)
+	select {
+	case c.send <- replyBytes:
+	default:
+		c.hub.recordDrop(c)
+		log.Printf("Failed to send subscriptions to client %s: send channel full", c.id)
+	}
 }
 
-// ShouldReceiveMessage checks if client should receive a message based on subscriptions
-func (c *Client) ShouldReceiveMessage(msgType MessageType, taskID string) bool {
+// ShouldReceiveMessage checks if client should receive a message about the
+// task with the given ID and tags based on subscriptions. Subscribed task IDs
+// may be patterns such as "proj-*"; tags are compared case-insensitively.
+func (c *Client) ShouldReceiveMessage(msgType MessageType, taskID string, tags ...string) bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	// If no subscriptions are set, receive all messages (default behavior)
-	if len(c.subscribedTypes) == 0 && len(c.subscribedTasks) == 0 {
+	if len(c.subscribedTypes) == 0 && len(c.subscribedTasks) == 0 && len(c.subscribedTags) == 0 {
 		return true
 	}
 
@@ -261,9 +333,20 @@ func (c *Client) ShouldReceiveMessage(msgType MessageType, taskID string) bool {
 		return true
 	}
 
-	// Check task ID subscription (if taskID is provided)
-	if taskID != "" && c.subscribedTasks[taskID] {
-		return true
+	// Check task ID and pattern subscriptions (if taskID is provided)
+	if taskID != "" {
+		for subscribed := range c.subscribedTasks {
+			if matchTask(subscribed, taskID) {
+				return true
+			}
+		}
+	}
+
+	// Check tag subscriptions
+	for _, tag := range tags {
+		if c.subscribedTags[strings.ToLower(tag)] {
+			return true
+		}
 	}
 
 	return false
@@ -0,0 +1,96 @@
+package hub
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+)
+
+// CommandMessage asks the server to act on a task as the REST endpoint of the
+// same name would. The message's ID is echoed on the result, so clients can
+// match results to the commands they sent.
+type CommandMessage struct {
+	Command string          `json:"command"`           // "start", "continue", "interrupt" or "abort"
+	TaskID  string          `json:"task_id,omitempty"` // Task acted on; not used by start
+	Body    json.RawMessage `json:"body,omitempty"`    // Request body of the REST endpoint
+}
+
+// CommandResultMessage answers a command with the status code and body the
+// REST endpoint responded with
+type CommandResultMessage struct {
+	Command string          `json:"command"`
+	Status  int             `json:"status"`
+	Body    json.RawMessage `json:"body,omitempty"`
+}
+
+// CommandHandler runs a command on behalf of a client's identity, which is nil
+// when authentication is disabled
+type CommandHandler func(identity *Identity, cmd CommandMessage) CommandResultMessage
+
+// SetCommandHandler lets clients send commands over the WebSocket. Without a
+// handler every command is refused.
+func (h *Hub) SetCommandHandler(handle CommandHandler) {
+	h.handleCommand = handle
+}
+
+// handleCommand runs a command and replies with its result. Commands run in
+// their own goroutine, so a slow one doesn't hold up the client's pings or
+// later commands; results may arrive out of order.
+func (c *Client) handleCommand(msg *WebSocketMessage) {
+	var cmd CommandMessage
+	if err := json.Unmarshal(msg.Data, &cmd); err != nil {
+		log.Printf("Failed to parse command from client %s: %v", c.id, err)
+		c.sendCommandResult(msg.ID, CommandResultMessage{Status: http.StatusBadRequest, Body: errorBody(http.StatusBadRequest, "Invalid command")})
+		return
+	}
+
+	handle := c.hub.handleCommand
+	if handle == nil {
+		c.sendCommandResult(msg.ID, CommandResultMessage{Command: cmd.Command, Status: http.StatusNotImplemented, Body: errorBody(http.StatusNotImplemented, "Commands are not supported")})
+		return
+	}
+	go func() {
+		result := handle(c.identity, cmd)
+		result.Command = cmd.Command
+		c.sendCommandResult(msg.ID, result)
+	}()
+}
+
+// sendCommandResult queues the result of the command with the given ID, unless
+// the client disconnected while the command ran
+func (c *Client) sendCommandResult(id string, result CommandResultMessage) {
+	reply, err := CreateMessage(MessageTypeCommandResult, result)
+	if err != nil {
+		log.Printf("Failed to create command result for client %s: %v", c.id, err)
+		return
+	}
+	reply.ID = id
+
+	replyBytes, err := MarshalMessage(reply)
+	if err != nil {
+		log.Printf("Failed to marshal command result for client %s: %v", c.id, err)
+		return
+	}
+
+	// The hub closes the send queue of clients it removes while holding its lock
+	c.hub.mu.RLock()
+	defer c.hub.mu.RUnlock()
+	if c.removed {
+		return
+	}
+	select {
+	case c.send <- replyBytes:
+	default:
+		c.hub.recordDrop(c)
+		log.Printf("Failed to send command result to client %s: send channel full", c.id)
+	}
+}
+
+// errorBody encodes an error as the REST API does
+func errorBody(status int, message string) json.RawMessage {
+	body, _ := json.Marshal(response.ErrorBody{Code: apierr.DefaultCode(status), Message: message})
+	return body
+}
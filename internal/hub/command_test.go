@@ -0,0 +1,59 @@
+package hub
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Command(t *testing.T) {
+	hub := NewHub()
+	runHub(t, hub)
+	identity := &Identity{User: "alice"}
+	client := hub.NewLocalClient("", identity)
+	hub.Register(client)
+	t.Cleanup(func() { hub.Unregister(client) })
+
+	result := func() (string, CommandResultMessage) {
+		select {
+		case raw := <-client.Messages():
+			msg, err := ParseMessage(raw)
+			require.NoError(t, err)
+			require.Equal(t, MessageTypeCommandResult, msg.Type)
+			var result CommandResultMessage
+			require.NoError(t, json.Unmarshal(msg.Data, &result))
+			return msg.ID, result
+		case <-time.After(time.Second):
+			t.Fatal("no command result")
+			return "", CommandResultMessage{}
+		}
+	}
+
+	// Without a handler commands are refused
+	client.Receive([]byte(`{"type":"command","id":"c1","data":{"command":"abort","task_id":"a1"}}`))
+	id, res := result()
+	assert.Equal(t, "c1", id)
+	assert.Equal(t, "abort", res.Command)
+	assert.Equal(t, http.StatusNotImplemented, res.Status)
+	assert.JSONEq(t, `{"code":"not_implemented","message":"Commands are not supported"}`, string(res.Body))
+
+	var received CommandMessage
+	var receivedAs *Identity
+	hub.SetCommandHandler(func(identity *Identity, cmd CommandMessage) CommandResultMessage {
+		received, receivedAs = cmd, identity
+		return CommandResultMessage{Status: http.StatusAccepted, Body: json.RawMessage(`{"attempt":2}`)}
+	})
+	client.Receive([]byte(`{"type":"command","id":"c2","data":{"command":"continue","task_id":"a1","body":{"message":"hi"}}}`))
+	id, res = result()
+	assert.Equal(t, "c2", id)
+	assert.Equal(t, "continue", res.Command)
+	assert.Equal(t, http.StatusAccepted, res.Status)
+	assert.JSONEq(t, `{"attempt":2}`, string(res.Body))
+	assert.Equal(t, identity, receivedAs)
+	assert.Equal(t, "a1", received.TaskID)
+	assert.JSONEq(t, `{"message":"hi"}`, string(received.Body))
+}
@@ -0,0 +1,216 @@
+package hub
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// HistoryConfig configures the journal of broadcast events
+type HistoryConfig struct {
+	Path      string        // Append-only journal file
+	Retention time.Duration // Events older than this are pruned on startup; 0 keeps everything
+}
+
+// HistoryEvent is a broadcast event as recorded in the history journal. The ID
+// is the event's replay sequence number, so it matches the "seq" clients see.
+type HistoryEvent struct {
+	ID   uint64          `json:"id"`
+	Time time.Time       `json:"time"`
+	Type MessageType     `json:"type"`
+	Room string          `json:"room,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// HistoryQuery selects events from the history
+type HistoryQuery struct {
+	Since uint64        // Only events with a greater ID
+	Types []MessageType // Only events of these types; empty matches any
+	Room  string        // Only events broadcast to this room; empty matches any
+	Limit int           // Maximum events returned
+}
+
+// History is an append-only journal of every broadcast event, so clients and
+// integrations can backfill events they missed long after the replay buffer
+// has dropped them
+type History struct {
+	config HistoryConfig
+	mu     sync.Mutex
+	file   *os.File
+}
+
+// OpenHistory opens the history journal, pruning events older than the
+// retention period
+func OpenHistory(config HistoryConfig) (*History, error) {
+	if config.Path == "" {
+		return nil, errors.New("history path is required")
+	}
+	if err := os.MkdirAll(filepath.Dir(config.Path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create event history directory: %w", err)
+	}
+
+	h := &History{config: config}
+	if config.Retention > 0 {
+		if err := h.prune(time.Now().Add(-config.Retention)); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := os.OpenFile(config.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event history: %w", err)
+	}
+	h.file = file
+	return h, nil
+}
+
+// prune rewrites the journal without events from before the cutoff
+func (h *History) prune(cutoff time.Time) error {
+	var kept [][]byte
+	err := h.scan(func(event HistoryEvent, line []byte) bool {
+		if !event.Time.Before(cutoff) {
+			kept = append(kept, append([]byte(nil), line...))
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	tmpPath := h.config.Path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to prune event history: %w", err)
+	}
+	writer := bufio.NewWriter(tmp)
+	for _, line := range kept {
+		writer.Write(line)
+		writer.WriteByte('\n')
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to prune event history: %w", err)
+	}
+	tmp.Close()
+
+	if err := os.Rename(tmpPath, h.config.Path); err != nil {
+		return fmt.Errorf("failed to prune event history: %w", err)
+	}
+	return nil
+}
+
+// Record appends an event to the journal
+func (h *History) Record(event HistoryEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.file == nil {
+		return errors.New("event history is closed")
+	}
+	_, err = h.file.Write(append(line, '\n'))
+	return err
+}
+
+// Query returns the events matching q in ID order. hasMore reports whether
+// further matching events were cut off by the limit.
+func (h *History) Query(q HistoryQuery) (events []HistoryEvent, hasMore bool, err error) {
+	types := make(map[MessageType]bool, len(q.Types))
+	for _, msgType := range q.Types {
+		types[msgType] = true
+	}
+
+	events = []HistoryEvent{}
+	err = h.scan(func(event HistoryEvent, _ []byte) bool {
+		if event.ID <= q.Since {
+			return true
+		}
+		if len(types) > 0 && !types[event.Type] {
+			return true
+		}
+		if q.Room != "" && event.Room != q.Room {
+			return true
+		}
+		if q.Limit > 0 && len(events) == q.Limit {
+			hasMore = true
+			return false
+		}
+		events = append(events, event)
+		return true
+	})
+	return events, hasMore, err
+}
+
+// scan calls fn for each event in the journal until it returns false
+func (h *History) scan(fn func(event HistoryEvent, line []byte) bool) error {
+	file, err := os.Open(h.config.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open event history: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var event HistoryEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			// A partial line from a crash mid-write; skip it
+			continue
+		}
+		if !fn(event, scanner.Bytes()) {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read event history: %w", err)
+	}
+	return nil
+}
+
+// Close closes the journal
+func (h *History) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.file == nil {
+		return nil
+	}
+	err := h.file.Close()
+	h.file = nil
+	return err
+}
+
+// historyData returns the data recorded for an event. Log lines are already
+// in the task's log file, so only their metadata is recorded.
+func historyData(msgType MessageType, data json.RawMessage) json.RawMessage {
+	if msgType != MessageTypeLog {
+		return data
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+		return data
+	}
+	if content, ok := fields["content"].(string); ok {
+		fields["content_length"] = len(content)
+	}
+	delete(fields, "content")
+
+	trimmed, err := json.Marshal(fields)
+	if err != nil {
+		return data
+	}
+	return trimmed
+}
@@ -0,0 +1,108 @@
+package hub
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory_Query(t *testing.T) {
+	history, err := OpenHistory(HistoryConfig{Path: filepath.Join(t.TempDir(), "history.jsonl")})
+	require.NoError(t, err)
+	defer history.Close()
+
+	now := time.Now()
+	types := []MessageType{MessageTypeTaskUpdate, MessageTypeLog, MessageTypeThreadMessage, MessageTypeTaskUpdate}
+	for i, msgType := range types {
+		room := "task:a"
+		if i == 3 {
+			room = "task:b"
+		}
+		require.NoError(t, history.Record(HistoryEvent{ID: uint64(i + 1), Time: now, Type: msgType, Room: room}))
+	}
+
+	events, hasMore, err := history.Query(HistoryQuery{})
+	require.NoError(t, err)
+	assert.Len(t, events, 4)
+	assert.False(t, hasMore)
+
+	events, _, err = history.Query(HistoryQuery{Since: 2})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, uint64(3), events[0].ID)
+
+	events, _, err = history.Query(HistoryQuery{Types: []MessageType{MessageTypeTaskUpdate}})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, uint64(4), events[1].ID)
+
+	events, _, err = history.Query(HistoryQuery{Room: "task:b"})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, uint64(4), events[0].ID)
+
+	events, hasMore, err = history.Query(HistoryQuery{Limit: 3})
+	require.NoError(t, err)
+	assert.Len(t, events, 3)
+	assert.True(t, hasMore)
+}
+
+func TestHistory_PrunesOnOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+
+	history, err := OpenHistory(HistoryConfig{Path: path})
+	require.NoError(t, err)
+	require.NoError(t, history.Record(HistoryEvent{ID: 1, Time: time.Now().Add(-2 * time.Hour), Type: MessageTypeLog}))
+	require.NoError(t, history.Record(HistoryEvent{ID: 2, Time: time.Now(), Type: MessageTypeLog}))
+	require.NoError(t, history.Close())
+
+	history, err = OpenHistory(HistoryConfig{Path: path, Retention: time.Hour})
+	require.NoError(t, err)
+	defer history.Close()
+
+	events, _, err := history.Query(HistoryQuery{})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, uint64(2), events[0].ID)
+
+	// New events are appended after the pruned ones
+	require.NoError(t, history.Record(HistoryEvent{ID: 3, Time: time.Now(), Type: MessageTypeLog}))
+	events, _, err = history.Query(HistoryQuery{})
+	require.NoError(t, err)
+	assert.Len(t, events, 2)
+}
+
+func TestHubRecordsHistory(t *testing.T) {
+	hub := NewHub()
+	require.Error(t, hub.EnableHistory(HistoryConfig{Path: filepath.Join(t.TempDir(), "history.jsonl")}))
+
+	require.NoError(t, hub.EnableReplay(ReplayConfig{Size: 1}))
+	require.NoError(t, hub.EnableHistory(HistoryConfig{Path: filepath.Join(t.TempDir(), "history.jsonl")}))
+	runHub(t, hub)
+
+	hub.BroadcastToRoom("task:a", []byte(`{"type":"task-update","data":{"id":"a"}}`))
+	hub.BroadcastToRoom("task:a", []byte(`{"type":"log","data":{"worker_id":"a","content":"secret output"}}`))
+	hub.Broadcast([]byte(`{"type":"heartbeat","data":{}}`))
+
+	var events []HistoryEvent
+	require.Eventually(t, func() bool {
+		var err error
+		events, _, err = hub.History().Query(HistoryQuery{})
+		return err == nil && len(events) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	// Events outlive the replay buffer and keep their sequence numbers
+	assert.Equal(t, uint64(1), events[0].ID)
+	assert.Equal(t, MessageTypeTaskUpdate, events[0].Type)
+	assert.Equal(t, "task:a", events[0].Room)
+	assert.JSONEq(t, `{"id":"a"}`, string(events[0].Data))
+
+	// Only log metadata is recorded
+	var logData map[string]interface{}
+	require.NoError(t, json.Unmarshal(events[1].Data, &logData))
+	assert.Equal(t, map[string]interface{}{"worker_id": "a", "content_length": float64(13)}, logData)
+}
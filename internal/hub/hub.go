@@ -1,13 +1,21 @@
 package hub
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
 )
 
 const (
@@ -26,8 +34,11 @@ type Hub struct {
 	// Registered clients
 	clients map[*Client]bool
 
-	// Inbound messages from clients
-	broadcast chan []byte
+	// Registered clients by room; clients outside any room receive every event
+	rooms map[string]map[*Client]bool
+
+	// Messages queued for broadcast
+	broadcast chan broadcastMessage
 
 	// Register requests from clients
 	register chan *Client
@@ -38,6 +49,47 @@ type Hub struct {
 	// WebSocket upgrader
 	upgrader websocket.Upgrader
 	
+	// Reject browser connections from non-HTTPS origins
+	secureOrigins bool
+	
+	// Cross-origin allow-list; nil allows same-origin connections only
+	allowOrigin func(origin string) bool
+	
+	// Recent events for clients resuming after a reconnect; nil disables replay
+	replay *replayBuffer
+	
+	// Journal of every sequenced event; nil disables history
+	history *History
+	
+	// Resolves client tokens to identities; nil accepts unauthenticated clients
+	authenticate Authenticator
+	
+	// Runs the commands clients send; nil refuses them
+	handleCommand CommandHandler
+	
+	// Receive every broadcast event, e.g. to pass it on to plugins
+	listeners []EventListener
+	
+	// What to do when a client's send queue is full
+	slowClientPolicy SlowClientPolicy
+	
+	// Timeouts and inbound message size of client connections
+	limits ConnectionLimits
+	
+	// Sessions of socket clients by token, kept for a while after they
+	// disconnect so a reconnect can resume them
+	sessions  map[string]*session
+	sessionMu sync.Mutex
+	
+	// Tags of tasks, matched against clients' tag subscriptions
+	taskTags map[string][]string
+	tagsMu   sync.RWMutex
+	
+	// Messages dropped because a client's send queue was full, and the
+	// clients disconnected for it
+	dropped         atomic.Uint64
+	slowDisconnects atomic.Uint64
+	
 	// Mutex for thread-safe access to clients
 	mu sync.RWMutex
 	
@@ -46,66 +98,176 @@ type Hub struct {
 	
 	// Ticker for server heartbeat messages
 	serverHeartbeatTicker *time.Ticker
+
+	// Closed when Run returns
+	done chan struct{}
+}
+
+// broadcastMessage is a message queued for delivery to clients
+type broadcastMessage struct {
+	data     []byte
+	room     string // Also deliver to clients in this room
+	everyone bool   // Deliver to every client, whatever its room and subscriptions
+	taskID   string // Task the message is about, for subscription matching
 }
 
 // NewHub creates a new WebSocket hub
 func NewHub() *Hub {
 	hub := &Hub{
 		clients:    make(map[*Client]bool),
-		broadcast:  make(chan []byte),
+		rooms:      make(map[string]map[*Client]bool),
+		broadcast:  make(chan broadcastMessage),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
-		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				// Allow connections from any origin for now
-				return true
-			},
-		},
+		slowClientPolicy:      SlowClientDisconnect,
+		limits:                DefaultConnectionLimits(),
+		sessions:              make(map[string]*session),
+		taskTags:              make(map[string][]string),
 		heartbeatTicker:       time.NewTicker(heartbeatInterval),
 		serverHeartbeatTicker: time.NewTicker(serverHeartbeatInterval),
+		done:                  make(chan struct{}),
 	}
+	hub.upgrader.CheckOrigin = hub.checkOrigin
 	return hub
 }
 
-// Run starts the hub and handles client registration, unregistration, and broadcasting
-func (h *Hub) Run() {
+// SetSecureOrigins requires browser clients to connect from HTTPS pages, so
+// credentials sent over a TLS-protected socket can't originate from plain HTTP
+func (h *Hub) SetSecureOrigins(secure bool) {
+	h.secureOrigins = secure
+}
+
+// SetAllowedOrigins sets the cross-origin allow-list, normally the same one
+// used for CORS, so browser dashboards on other origins can connect
+func (h *Hub) SetAllowedOrigins(allow func(origin string) bool) {
+	h.allowOrigin = allow
+}
+
+// EnableReplay sequences broadcast events and keeps recent ones so clients
+// can resume from a sequence number, restoring any journaled events
+func (h *Hub) EnableReplay(config ReplayConfig) error {
+	replay, err := newReplayBuffer(config)
+	if err != nil {
+		return err
+	}
+	h.replay = replay
+	return nil
+}
+
+// EnableHistory records every sequenced event in an append-only journal.
+// Replay must be enabled first, since events are identified by their
+// sequence numbers.
+func (h *Hub) EnableHistory(config HistoryConfig) error {
+	if h.replay == nil {
+		return errors.New("event history requires replay to be enabled")
+	}
+	history, err := OpenHistory(config)
+	if err != nil {
+		return err
+	}
+	h.history = history
+	return nil
+}
+
+// History returns the event history, or nil when it isn't enabled
+func (h *Hub) History() *History {
+	return h.history
+}
+
+// checkOrigin decides whether a WebSocket upgrade request is allowed
+func (h *Hub) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// Non-browser clients don't send an Origin header
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if h.secureOrigins && u.Scheme != "https" {
+		return false
+	}
+
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return h.allowOrigin != nil && h.allowOrigin(origin)
+}
+
+// Run handles client registration, unregistration and broadcasting until ctx
+// is done, then disconnects the remaining clients. Later broadcasts are
+// dropped and later clients are disconnected as they register.
+func (h *Hub) Run(ctx context.Context) {
+	defer close(h.done)
 	defer h.heartbeatTicker.Stop()
 	defer h.serverHeartbeatTicker.Stop()
 	
 	for {
 		select {
+		case <-ctx.Done():
+			h.mu.Lock()
+			for client := range h.clients {
+				h.remove(client)
+			}
+			h.mu.Unlock()
+			return
+
+
 		case client := <-h.register:
+			// Replay before registering so no broadcast is missed or duplicated
+			if client.conn != nil {
+				h.attachSession(client)
+			}
+			if client.resumeFrom != nil {
+				h.replayTo(client, *client.resumeFrom)
+			}
 			h.mu.Lock()
 			h.clients[client] = true
+			if client.room != "" {
+				if h.rooms[client.room] == nil {
+					h.rooms[client.room] = make(map[*Client]bool)
+				}
+				h.rooms[client.room][client] = true
+			}
 			h.mu.Unlock()
 			client.SetConnected(true)
-			log.Printf("Client registered: %s", client.id)
+			log.Printf("Client registered: %s", client)
 
 		case client := <-h.unregister:
 			h.mu.Lock()
 			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-				client.SetConnected(false)
-				log.Printf("Client unregistered: %s", client.id)
+				h.remove(client)
+				log.Printf("Client unregistered: %s", client)
 			}
 			h.mu.Unlock()
 
-		case message := <-h.broadcast:
+		case msg := <-h.broadcast:
+			message := h.sequence(msg)
+			h.notifyListeners(msg, message)
+			var slow []*Client
 			h.mu.RLock()
-			for client := range h.clients {
-				if client.IsConnected() {
-					select {
-					case client.send <- message:
-					default:
-						close(client.send)
-						delete(h.clients, client)
-						client.SetConnected(false)
-					}
+			for _, client := range h.recipients(msg) {
+				if !h.enqueue(client, message) {
+					slow = append(slow, client)
 				}
 			}
 			h.mu.RUnlock()
 			
+			// Disconnect slow clients once delivery to everyone else is done
+			if len(slow) > 0 {
+				h.mu.Lock()
+				for _, client := range slow {
+					if _, ok := h.clients[client]; ok {
+						h.slowDisconnects.Add(1)
+						log.Printf("Client %s disconnected: send queue full", client)
+						h.remove(client)
+					}
+				}
+				h.mu.Unlock()
+			}
+			
 		case <-h.heartbeatTicker.C:
 			h.checkHeartbeats()
 			
@@ -115,19 +277,167 @@ func (h *Hub) Run() {
 	}
 }
 
-// Broadcast sends a message to all connected clients
+// Broadcast sends a message to all connected clients outside a room
 func (h *Hub) Broadcast(message []byte) {
-	h.broadcast <- message
+	h.send(broadcastMessage{data: message})
+}
+
+// BroadcastToRoom sends a message to the clients in a room as well as to
+// every client outside a room
+func (h *Hub) BroadcastToRoom(room string, message []byte) {
+	h.send(broadcastMessage{data: message, room: room})
+}
+
+// send queues a message for delivery, dropping it once Run has returned
+func (h *Hub) send(msg broadcastMessage) {
+	select {
+	case h.broadcast <- msg:
+	case <-h.done:
+	}
+}
+
+// recipients returns the connected clients a message is delivered to, leaving
+// out clients whose subscriptions don't match it. Callers must hold the lock.
+func (h *Hub) recipients(msg broadcastMessage) []*Client {
+	if msg.everyone {
+		var clients []*Client
+		for client := range h.clients {
+			if client.IsConnected() {
+				clients = append(clients, client)
+			}
+		}
+		return clients
+	}
+
+	msgType := messageType(msg.data)
+	tags := h.tags(msg.taskID)
+	wants := func(client *Client) bool {
+		return client.IsConnected() && client.ShouldReceiveMessage(msgType, msg.taskID, tags...)
+	}
+
+	var clients []*Client
+	for client := range h.clients {
+		if client.room == "" && wants(client) {
+			clients = append(clients, client)
+		}
+	}
+	if msg.room != "" {
+		for client := range h.rooms[msg.room] {
+			if wants(client) {
+				clients = append(clients, client)
+			}
+		}
+	}
+	return clients
+}
+
+// remove drops a client and closes its send channel. Callers must hold the lock.
+func (h *Hub) remove(client *Client) {
+	delete(h.clients, client)
+	if members, ok := h.rooms[client.room]; ok {
+		delete(members, client)
+		if len(members) == 0 {
+			delete(h.rooms, client.room)
+		}
+	}
+	h.detachSession(client)
+	close(client.send)
+	client.removed = true
+	client.SetConnected(false)
+}
+
+// sequence records a broadcast event for replay, stamping it with its sequence
+// number. Heartbeats and resource samples are transient and aren't recorded.
+func (h *Hub) sequence(msg broadcastMessage) []byte {
+	if h.replay == nil || msg.everyone {
+		return msg.data
+	}
+
+	parsed, err := ParseMessage(msg.data)
+	if err == nil && (parsed.Type == MessageTypeHeartbeat || parsed.Type == MessageTypeTaskStats) {
+		return msg.data
+	}
+
+	now := time.Now()
+	sequenced, ok := h.replay.Append(msg.data, msg.room, now)
+	if ok && err == nil && h.history != nil {
+		// Only the Run loop sequences events, so LastSeq is this event's
+		event := HistoryEvent{
+			ID:   h.replay.LastSeq(),
+			Time: now,
+			Type: parsed.Type,
+			Room: msg.room,
+			Data: historyData(parsed.Type, parsed.Data),
+		}
+		if err := h.history.Record(event); err != nil {
+			log.Printf("Failed to record event %d in history: %v", event.ID, err)
+		}
+	}
+	return sequenced
+}
+
+// replayTo sends a resuming client the events it missed, or tells it to
+// resync when they are no longer available
+func (h *Hub) replayTo(client *Client, since uint64) {
+	if h.replay != nil {
+		events, oldest, ok := h.replay.Since(since, client.room, time.Now())
+		if ok && len(events) <= cap(client.send)-len(client.send) {
+			for _, event := range events {
+				client.send <- event
+			}
+			log.Printf("Replayed %d events to client %s from seq %d", len(events), client.id, since)
+			return
+		}
+
+		h.sendResync(client, ResyncMessage{Since: since, OldestSeq: oldest, LastSeq: h.replay.LastSeq()})
+		return
+	}
+
+	h.sendResync(client, ResyncMessage{Since: since})
+}
+
+// sendResync tells a client it must reload state instead of resuming
+func (h *Hub) sendResync(client *Client, data ResyncMessage) {
+	msg, err := CreateMessage(MessageTypeResyncRequired, data)
+	if err != nil {
+		log.Printf("Failed to create resync message: %v", err)
+		return
+	}
+	msgBytes, err := MarshalMessage(msg)
+	if err != nil {
+		log.Printf("Failed to marshal resync message: %v", err)
+		return
+	}
+
+	select {
+	case client.send <- msgBytes:
+	default:
+		h.recordDrop(client)
+		log.Printf("Failed to send resync to client %s: send channel full", client.id)
+	}
 }
 
-// Register adds a client to the hub
+// Register adds a client to the hub. Once Run has returned the client's send
+// queue is closed instead, disconnecting it.
 func (h *Hub) Register(client *Client) {
-	h.register <- client
+	select {
+	case h.register <- client:
+	case <-h.done:
+		h.mu.Lock()
+		if !client.removed {
+			close(client.send)
+			client.removed = true
+		}
+		h.mu.Unlock()
+	}
 }
 
 // Unregister removes a client from the hub
 func (h *Hub) Unregister(client *Client) {
-	h.unregister <- client
+	select {
+	case h.unregister <- client:
+	case <-h.done:
+	}
 }
 
 // checkHeartbeats disconnects clients that have timed out
@@ -145,12 +455,15 @@ func (h *Hub) checkHeartbeats() {
 		}
 	}
 	h.mu.RUnlock()
+	h.pruneSessions(now)
 
 	// Disconnect timed out clients
 	for _, client := range timeoutClients {
 		log.Printf("Client %s timed out, disconnecting", client.id)
 		h.Unregister(client)
-		client.conn.Close()
+		if client.conn != nil {
+			client.conn.Close()
+		}
 	}
 }
 
@@ -173,11 +486,37 @@ func (h *Hub) sendServerHeartbeat() {
 		return
 	}
 
-	h.Broadcast(heartbeatBytes)
+	h.broadcast <- broadcastMessage{data: heartbeatBytes, everyone: true}
 }
 
-// ServeWS handles websocket requests from clients
+// ServeWS handles websocket requests from clients receiving every event
 func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, "")
+}
+
+// ServeRoom handles websocket requests from clients that only receive the
+// events broadcast to a room
+func (h *Hub) ServeRoom(w http.ResponseWriter, r *http.Request, room string) {
+	h.serve(w, r, room)
+}
+
+// serve upgrades a websocket request and starts the client, placing it in
+// room when one is given
+func (h *Hub) serve(w http.ResponseWriter, r *http.Request, room string) {
+	// A token on the upgrade request is checked before upgrading, so bad
+	// credentials get a plain HTTP 401
+	var identity *Identity
+	if h.authenticate != nil {
+		if token := requestToken(r); token != "" {
+			var ok bool
+			if identity, ok = h.authenticate(token); !ok {
+				log.Printf("WebSocket authentication failed for %s: invalid token", r.RemoteAddr)
+				response.Error(w, http.StatusUnauthorized, "Invalid token")
+				return
+			}
+		}
+	}
+
 	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade failed: %v", err)
@@ -191,11 +530,50 @@ func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request) {
 		id:              uuid.New().String()[:8], // Short client ID
 		lastHeartbeat:   time.Now(),
 		lastPong:        time.Now(),
+		connectedAt:     time.Now(),
 		subscribedTypes: make(map[MessageType]bool),
 		subscribedTasks: make(map[string]bool),
+		subscribedTags:  make(map[string]bool),
 		connected:       false,
+		identity:        identity,
+		room:            room,
+	}
+
+	// Clients reconnecting with ?session=<token> get their subscriptions back
+	// and, like those reconnecting with ?since=<seq>, the events they missed
+	client.sessionToken = r.URL.Query().Get("session")
+	if since := r.URL.Query().Get("since"); since != "" {
+		if seq, err := strconv.ParseUint(since, 10, 64); err == nil {
+			client.resumeFrom = &seq
+		}
+	}
+
+	if h.authenticate != nil && identity == nil {
+		// Without a token on the request, the first message must authenticate
+		go func() {
+			identity, err := h.awaitHandshake(conn)
+			if err != nil {
+				log.Printf("WebSocket authentication failed for %s: %v", r.RemoteAddr, err)
+				h.rejectConnection(conn)
+				return
+			}
+			client.identity = identity
+
+			if ack, err := CreateMessage(MessageTypeAuthOK, identity); err == nil {
+				if ackBytes, err := MarshalMessage(ack); err == nil {
+					client.send <- ackBytes
+				}
+			}
+			h.start(client)
+		}()
+		return
 	}
 
+	h.start(client)
+}
+
+// start registers a client and begins pumping messages to and from it
+func (h *Hub) start(client *Client) {
 	client.hub.Register(client)
 
 	// Allow collection of memory referenced by the caller by doing all work in
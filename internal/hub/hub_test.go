@@ -1,6 +1,7 @@
 package hub
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -14,9 +15,16 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// runHub runs hub until the test ends
+func runHub(t testing.TB, hub *Hub) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go hub.Run(ctx)
+}
+
 func TestHub_Broadcast(t *testing.T) {
 	hub := NewHub()
-	go hub.Run()
+	runHub(t, hub)
 
 	// Create mock clients with just the send channel (no WebSocket connection)
 	client1 := &Client{
@@ -75,7 +83,7 @@ func TestHub_Broadcast(t *testing.T) {
 
 func TestHub_RegisterUnregister(t *testing.T) {
 	hub := NewHub()
-	go hub.Run()
+	runHub(t, hub)
 
 	client := &Client{
 		hub:             hub,
@@ -117,16 +125,50 @@ func TestHub_RegisterUnregister(t *testing.T) {
 	}
 }
 
+func TestHub_RunStopsWhenContextDone(t *testing.T) {
+	hub := NewHub()
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		hub.Run(ctx)
+		close(stopped)
+	}()
+
+	client := hub.NewLocalClient("", nil)
+	hub.Register(client)
+	require.Eventually(t, client.IsConnected, time.Second, 5*time.Millisecond)
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Run didn't return after its context was done")
+	}
+
+	// Remaining clients are disconnected
+	_, open := <-client.send
+	assert.False(t, open)
+	assert.False(t, client.IsConnected())
+
+	// Later calls return instead of blocking on the stopped hub
+	hub.Broadcast([]byte("dropped"))
+	late := hub.NewLocalClient("", nil)
+	hub.Register(late)
+	_, open = <-late.send
+	assert.False(t, open)
+	hub.Unregister(late)
+}
+
 func TestHubBasicBroadcast(t *testing.T) {
 	hub := NewHub()
-	go hub.Run()
+	runHub(t, hub)
 
 	// Connect a client
 	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
 	defer server.Close()
 
 	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
-	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	conn, _, err := dialTest(wsURL, nil)
 	require.NoError(t, err)
 	defer conn.Close()
 
@@ -139,14 +181,14 @@ func TestHubBasicBroadcast(t *testing.T) {
 
 	// Read the message from the client
 	conn.SetReadDeadline(time.Now().Add(time.Second))
-	_, message, err := conn.ReadMessage()
+	message, err := conn.next()
 	require.NoError(t, err)
 	assert.Equal(t, testMessage, message)
 }
 
 func TestHubMultipleClients(t *testing.T) {
 	hub := NewHub()
-	go hub.Run()
+	runHub(t, hub)
 
 	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
 	defer server.Close()
@@ -154,9 +196,9 @@ func TestHubMultipleClients(t *testing.T) {
 	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
 
 	// Connect multiple clients
-	var clients []*websocket.Conn
+	var clients []*testConn
 	for i := 0; i < 3; i++ {
-		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		conn, _, err := dialTest(wsURL, nil)
 		require.NoError(t, err)
 		clients = append(clients, conn)
 	}
@@ -177,10 +219,10 @@ func TestHubMultipleClients(t *testing.T) {
 	var wg sync.WaitGroup
 	for i, client := range clients {
 		wg.Add(1)
-		go func(clientIndex int, c *websocket.Conn) {
+		go func(clientIndex int, c *testConn) {
 			defer wg.Done()
 			c.SetReadDeadline(time.Now().Add(2 * time.Second))
-			_, message, err := c.ReadMessage()
+			message, err := c.next()
 			assert.NoError(t, err, "Client %d should receive message", clientIndex)
 			assert.Equal(t, testMessage, message, "Client %d should receive correct message", clientIndex)
 		}(i, client)
@@ -191,13 +233,13 @@ func TestHubMultipleClients(t *testing.T) {
 
 func TestHubPingPongHandling(t *testing.T) {
 	hub := NewHub()
-	go hub.Run()
+	runHub(t, hub)
 
 	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
 	defer server.Close()
 
 	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
-	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	conn, _, err := dialTest(wsURL, nil)
 	require.NoError(t, err)
 	defer conn.Close()
 
@@ -221,7 +263,7 @@ func TestHubPingPongHandling(t *testing.T) {
 
 	// Read the pong response
 	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
-	_, responseBytes, err := conn.ReadMessage()
+	responseBytes, err := conn.next()
 	require.NoError(t, err)
 
 	// Parse the response
@@ -239,7 +281,7 @@ func TestHubPingPongHandling(t *testing.T) {
 
 func TestHubSubscriptionHandling(t *testing.T) {
 	hub := NewHub()
-	go hub.Run()
+	runHub(t, hub)
 
 	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
 	defer server.Close()
@@ -276,13 +318,13 @@ func TestHubSubscriptionHandling(t *testing.T) {
 
 func TestHubInvalidMessage(t *testing.T) {
 	hub := NewHub()
-	go hub.Run()
+	runHub(t, hub)
 
 	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
 	defer server.Close()
 
 	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
-	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	conn, _, err := dialTest(wsURL, nil)
 	require.NoError(t, err)
 	defer conn.Close()
 
@@ -314,7 +356,7 @@ func TestHubInvalidMessage(t *testing.T) {
 
 	// Should still receive pong response
 	conn.SetReadDeadline(time.Now().Add(time.Second))
-	_, responseBytes, err := conn.ReadMessage()
+	responseBytes, err := conn.next()
 	require.NoError(t, err)
 
 	response, err := ParseMessage(responseBytes)
@@ -408,3 +450,39 @@ func TestClientHeartbeatTracking(t *testing.T) {
 	client.UpdateLastPong()
 	assert.False(t, client.lastPong.IsZero())
 }
+
+func TestHubCheckOrigin(t *testing.T) {
+	hub := NewHub()
+
+	hub.SetAllowedOrigins(func(origin string) bool {
+		return origin == "https://app.example.com" || origin == "http://localhost:3000"
+	})
+
+	tests := []struct {
+		name     string
+		secure   bool
+		origin   string
+		expected bool
+	}{
+		{"no origin", false, "", true},
+		{"same origin", false, "http://ampd.local:8080", true},
+		{"allowed origin", false, "http://localhost:3000", true},
+		{"unlisted origin", false, "http://other.example.com", false},
+		{"no origin with secure origins", true, "", true},
+		{"https origin with secure origins", true, "https://app.example.com", true},
+		{"http origin with secure origins", true, "http://localhost:3000", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub.SetSecureOrigins(tt.secure)
+
+			req := httptest.NewRequest(http.MethodGet, "http://ampd.local:8080/api/ws", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+
+			assert.Equal(t, tt.expected, hub.checkOrigin(req))
+		})
+	}
+}
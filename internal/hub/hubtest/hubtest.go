@@ -0,0 +1,239 @@
+// Package hubtest runs an in-process WebSocket hub with fake clients, so
+// features that broadcast events can be tested without real sockets or sleeps.
+//
+// Clients are registered through the hub's event loop, so events broadcast
+// after Connect returns are always delivered to them.
+package hubtest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+)
+
+// DefaultTimeout is how long ExpectEvent waits when no duration is given
+const DefaultTimeout = time.Second
+
+// Hub is a running hub that fake clients connect to
+type Hub struct {
+	*hub.Hub
+	t testing.TB
+}
+
+// New starts a hub with the default configuration
+func New(t testing.TB) *Hub {
+	return Run(t, hub.NewHub())
+}
+
+// Run starts a hub that was configured by the caller, e.g. with replay
+// enabled. The hub stops when the test ends.
+func Run(t testing.TB, h *hub.Hub) *Hub {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go h.Run(ctx)
+	return &Hub{Hub: h, t: t}
+}
+
+// Connect registers a fake client receiving every event
+func (h *Hub) Connect() *Client {
+	return h.ConnectAs("", nil)
+}
+
+// ConnectRoom registers a fake client receiving the events broadcast to a room,
+// e.g. api.TaskRoom(id)
+func (h *Hub) ConnectRoom(room string) *Client {
+	return h.ConnectAs(room, nil)
+}
+
+// ConnectAs registers a fake client in a room (empty for every event) with an
+// authenticated identity (nil when unauthenticated)
+func (h *Hub) ConnectAs(room string, identity *hub.Identity) *Client {
+	client := &Client{t: h.t, hub: h.Hub, client: h.NewLocalClient(room, identity)}
+	h.Register(client.client)
+	h.t.Cleanup(client.Disconnect)
+	return client
+}
+
+// Client is a fake WebSocket client whose inbound messages are scripted by the
+// test and whose outbound events are checked with the Expect helpers
+type Client struct {
+	t      testing.TB
+	hub    *hub.Hub
+	client *hub.Client
+	closed bool
+}
+
+// Hub returns the client as the hub sees it
+func (c *Client) Hub() *hub.Client {
+	return c.client
+}
+
+// Subscribe sends a subscribe message for event types and task IDs
+func (c *Client) Subscribe(types []hub.MessageType, taskIDs ...string) {
+	c.t.Helper()
+	c.Send(hub.MessageTypeSubscribe, hub.SubscribeMessage{Types: types, TaskIDs: taskIDs})
+}
+
+// Unsubscribe sends an unsubscribe message for event types and task IDs
+func (c *Client) Unsubscribe(types []hub.MessageType, taskIDs ...string) {
+	c.t.Helper()
+	c.Send(hub.MessageTypeUnsubscribe, hub.SubscribeMessage{Types: types, TaskIDs: taskIDs})
+}
+
+// UnsubscribeAll clears every subscription of the client
+func (c *Client) UnsubscribeAll() {
+	c.t.Helper()
+	c.Send(hub.MessageTypeUnsubscribeAll, nil)
+}
+
+// Ping sends a ping; the pong is expected with ExpectEvent(hub.MessageTypePong, d)
+func (c *Client) Ping(id string) {
+	c.t.Helper()
+	c.Send(hub.MessageTypePing, hub.PingMessage{ID: id, Timestamp: time.Now()})
+}
+
+// Command sends a command with a correlation ID; its result is expected with
+// ExpectEvent(hub.MessageTypeCommandResult, d)
+func (c *Client) Command(id string, cmd hub.CommandMessage) {
+	c.t.Helper()
+	c.send(id, hub.MessageTypeCommand, cmd)
+}
+
+// Send sends a message to the hub as if the client had written it to its socket
+func (c *Client) Send(msgType hub.MessageType, data interface{}) {
+	c.t.Helper()
+	c.send("", msgType, data)
+}
+
+// send sends a message with an ID (none when empty) to the hub
+func (c *Client) send(id string, msgType hub.MessageType, data interface{}) {
+	c.t.Helper()
+	if c.closed {
+		c.t.Fatalf("hubtest: client %s sent a %s message after disconnecting", c.client.ID(), msgType)
+	}
+	msg, err := hub.CreateMessage(msgType, data)
+	if err != nil {
+		c.t.Fatalf("hubtest: failed to create %s message: %v", msgType, err)
+	}
+	msg.ID = id
+	raw, err := hub.MarshalMessage(msg)
+	if err != nil {
+		c.t.Fatalf("hubtest: failed to marshal %s message: %v", msgType, err)
+	}
+	c.client.Receive(raw)
+}
+
+// Disconnect unregisters the client. It is safe to call more than once and is
+// called automatically when the test ends.
+func (c *Client) Disconnect() {
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.hub.Unregister(c.client)
+}
+
+// ExpectEvent waits up to within (DefaultTimeout when 0) for an event of the
+// given type, skipping events of other types, and fails the test if none arrives
+func (c *Client) ExpectEvent(msgType hub.MessageType, within time.Duration) *hub.WebSocketMessage {
+	c.t.Helper()
+	if within <= 0 {
+		within = DefaultTimeout
+	}
+
+	timeout := time.NewTimer(within)
+	defer timeout.Stop()
+	var skipped []hub.MessageType
+	for {
+		select {
+		case raw, ok := <-c.client.Messages():
+			if !ok {
+				c.t.Fatalf("hubtest: client %s was disconnected while waiting for a %s event", c.client.ID(), msgType)
+				return nil
+			}
+			msg := c.parse(raw)
+			if msg.Type == msgType {
+				return msg
+			}
+			skipped = append(skipped, msg.Type)
+		case <-timeout.C:
+			c.t.Fatalf("hubtest: no %s event within %s (received %v)", msgType, within, skipped)
+			return nil
+		}
+	}
+}
+
+// ExpectNoEvent fails the test if an event of any of the given types (any
+// event when none are given) arrives within d
+func (c *Client) ExpectNoEvent(d time.Duration, types ...hub.MessageType) {
+	c.t.Helper()
+
+	timeout := time.NewTimer(d)
+	defer timeout.Stop()
+	for {
+		select {
+		case raw, ok := <-c.client.Messages():
+			if !ok {
+				return
+			}
+			msg := c.parse(raw)
+			if len(types) == 0 || contains(types, msg.Type) {
+				c.t.Fatalf("hubtest: unexpected %s event: %s", msg.Type, raw)
+				return
+			}
+		case <-timeout.C:
+			return
+		}
+	}
+}
+
+// ExpectDisconnected fails the test unless the hub drops the client within d,
+// e.g. because its send queue filled up. Queued events are discarded.
+func (c *Client) ExpectDisconnected(d time.Duration) {
+	c.t.Helper()
+
+	timeout := time.NewTimer(d)
+	defer timeout.Stop()
+	for {
+		select {
+		case _, ok := <-c.client.Messages():
+			if !ok {
+				c.closed = true
+				return
+			}
+		case <-timeout.C:
+			c.t.Fatalf("hubtest: client %s still connected after %s", c.client.ID(), d)
+			return
+		}
+	}
+}
+
+// parse decodes an outbound event, failing the test if it isn't a message
+func (c *Client) parse(raw []byte) *hub.WebSocketMessage {
+	c.t.Helper()
+	msg, err := hub.ParseMessage(raw)
+	if err != nil {
+		c.t.Fatalf("hubtest: invalid event %q: %v", raw, err)
+	}
+	return msg
+}
+
+// Decode unmarshals an event's data into v, failing the test on error
+func Decode(t testing.TB, msg *hub.WebSocketMessage, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(msg.Data, v); err != nil {
+		t.Fatalf("hubtest: failed to decode %s event data: %v", msg.Type, err)
+	}
+}
+
+func contains(types []hub.MessageType, msgType hub.MessageType) bool {
+	for _, t := range types {
+		if t == msgType {
+			return true
+		}
+	}
+	return false
+}
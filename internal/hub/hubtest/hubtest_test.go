@@ -0,0 +1,99 @@
+package hubtest_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub/hubtest"
+)
+
+func event(t *testing.T, msgType hub.MessageType, data interface{}) []byte {
+	msg, err := hub.CreateMessage(msgType, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := hub.MarshalMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestExpectEvent_DeliversBroadcasts(t *testing.T) {
+	h := hubtest.New(t)
+	global := h.Connect()
+	room := h.ConnectRoom("task:a")
+
+	h.BroadcastToRoom("task:b", event(t, hub.MessageTypeLog, map[string]string{"task": "b"}))
+	h.BroadcastToRoom("task:a", event(t, hub.MessageTypeTaskUpdate, map[string]string{"task": "a"}))
+
+	// Events of other types are skipped
+	msg := global.ExpectEvent(hub.MessageTypeTaskUpdate, 0)
+	var data map[string]string
+	hubtest.Decode(t, msg, &data)
+	assert.Equal(t, "a", data["task"])
+
+	room.ExpectEvent(hub.MessageTypeTaskUpdate, 0)
+	room.ExpectNoEvent(20 * time.Millisecond)
+}
+
+func TestPing(t *testing.T) {
+	client := hubtest.New(t).Connect()
+
+	client.Ping("p1")
+
+	var pong hub.PongMessage
+	hubtest.Decode(t, client.ExpectEvent(hub.MessageTypePong, 0), &pong)
+	assert.Equal(t, "p1", pong.PingID)
+}
+
+func TestSubscribe(t *testing.T) {
+	client := hubtest.New(t).Connect()
+
+	client.Subscribe([]hub.MessageType{hub.MessageTypeLog}, "task1")
+	assert.True(t, client.Hub().ShouldReceiveMessage(hub.MessageTypeTaskUpdate, "task1"))
+	assert.False(t, client.Hub().ShouldReceiveMessage(hub.MessageTypeTaskUpdate, "task2"))
+
+	client.Unsubscribe(nil, "task1")
+	assert.False(t, client.Hub().ShouldReceiveMessage(hub.MessageTypeTaskUpdate, "task1"))
+	assert.True(t, client.Hub().ShouldReceiveMessage(hub.MessageTypeLog, "task1"))
+
+	client.UnsubscribeAll()
+	assert.True(t, client.Hub().ShouldReceiveMessage(hub.MessageTypeTaskUpdate, "task2"))
+}
+
+func TestExpectDisconnected_SlowClient(t *testing.T) {
+	h := hubtest.New(t)
+	slow := h.Connect()
+
+	// The hub handles broadcasts in order, so once the one after the overflow
+	// is accepted the client has been dropped
+	for i := 0; i < cap(slow.Hub().Messages())+2; i++ {
+		h.Broadcast(event(t, hub.MessageTypeLog, map[string]int{"n": i}))
+	}
+
+	slow.ExpectDisconnected(time.Second)
+	assert.Equal(t, uint64(1), h.Stats().SlowDisconnects)
+}
+
+func TestReplayedEventsReachResumingClients(t *testing.T) {
+	h := hub.NewHub()
+	if err := h.EnableReplay(hub.ReplayConfig{Size: 10}); err != nil {
+		t.Fatal(err)
+	}
+	th := hubtest.Run(t, h)
+	client := th.Connect()
+
+	for i := 0; i < 3; i++ {
+		th.Broadcast(event(t, hub.MessageTypeLog, map[string]string{"line": fmt.Sprint(i)}))
+	}
+	for i := 0; i < 3; i++ {
+		var data map[string]string
+		hubtest.Decode(t, client.ExpectEvent(hub.MessageTypeLog, 0), &data)
+		assert.Equal(t, fmt.Sprint(i), data["line"])
+	}
+}
@@ -0,0 +1,61 @@
+package hub
+
+import (
+	"errors"
+	"time"
+)
+
+// ConnectionLimits controls the timeouts and inbound message size of client
+// connections
+type ConnectionLimits struct {
+	WriteWait      time.Duration // Time allowed to write a message to the peer
+	PongWait       time.Duration // Time allowed to read the next pong message from the peer
+	PingPeriod     time.Duration // How often the peer is pinged; must be less than PongWait
+	MaxMessageSize int64         // Largest message accepted from the peer, in bytes
+}
+
+// DefaultConnectionLimits returns the limits used unless configured otherwise.
+// Messages may be up to 64 KiB, enough to subscribe to thousands of tasks.
+func DefaultConnectionLimits() ConnectionLimits {
+	return ConnectionLimits{
+		WriteWait:      10 * time.Second,
+		PongWait:       60 * time.Second,
+		PingPeriod:     54 * time.Second,
+		MaxMessageSize: 64 << 10,
+	}
+}
+
+// Validate checks that the limits are positive and that pings are sent
+// before the pong wait runs out
+func (l ConnectionLimits) Validate() error {
+	if l.WriteWait <= 0 || l.PongWait <= 0 || l.PingPeriod <= 0 || l.MaxMessageSize <= 0 {
+		return errors.New("connection timeouts and the maximum message size must be positive")
+	}
+	if l.PingPeriod >= l.PongWait {
+		return errors.New("the ping period must be less than the pong wait")
+	}
+	return nil
+}
+
+// SetConnectionLimits sets the timeouts and inbound message size of client
+// connections opened afterwards. Zero fields keep their defaults.
+func (h *Hub) SetConnectionLimits(limits ConnectionLimits) error {
+	defaults := DefaultConnectionLimits()
+	if limits.WriteWait == 0 {
+		limits.WriteWait = defaults.WriteWait
+	}
+	if limits.PongWait == 0 {
+		limits.PongWait = defaults.PongWait
+	}
+	if limits.PingPeriod == 0 {
+		limits.PingPeriod = limits.PongWait * 9 / 10
+	}
+	if limits.MaxMessageSize == 0 {
+		limits.MaxMessageSize = defaults.MaxMessageSize
+	}
+	if err := limits.Validate(); err != nil {
+		return err
+	}
+	h.limits = limits
+	return nil
+}
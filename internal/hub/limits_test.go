@@ -0,0 +1,79 @@
+package hub
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetConnectionLimits(t *testing.T) {
+	hub := NewHub()
+	assert.Equal(t, DefaultConnectionLimits(), hub.limits)
+
+	require.NoError(t, hub.SetConnectionLimits(ConnectionLimits{PongWait: 2 * time.Minute}))
+	assert.Equal(t, ConnectionLimits{
+		WriteWait:      10 * time.Second,
+		PongWait:       2 * time.Minute,
+		PingPeriod:     108 * time.Second,
+		MaxMessageSize: 64 << 10,
+	}, hub.limits)
+
+	assert.Error(t, hub.SetConnectionLimits(ConnectionLimits{PongWait: time.Second, PingPeriod: time.Minute}))
+	assert.Error(t, hub.SetConnectionLimits(ConnectionLimits{MaxMessageSize: -1}))
+	assert.Equal(t, 2*time.Minute, hub.limits.PongWait, "invalid limits are not applied")
+}
+
+// subscribeAndPing subscribes to many tasks and reports whether the
+// connection still answers a ping afterwards
+func subscribeAndPing(t *testing.T, hub *Hub) bool {
+	runHub(t, hub)
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+
+	conn, _, err := dialTest("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	taskIDs := make([]string, 500)
+	for i := range taskIDs {
+		taskIDs[i] = fmt.Sprintf("task-%08d-0000-0000-0000-000000000000", i)
+	}
+	for _, message := range []*WebSocketMessage{
+		mustCreateMessage(t, MessageTypeSubscribe, SubscribeMessage{Types: []MessageType{MessageTypeLog}, TaskIDs: taskIDs}),
+		mustCreateMessage(t, MessageTypePing, PingMessage{ID: "after-subscribe", Timestamp: time.Now()}),
+	} {
+		raw, err := MarshalMessage(message)
+		require.NoError(t, err)
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, raw))
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	raw, err := conn.next()
+	if err != nil {
+		return false
+	}
+	response, err := ParseMessage(raw)
+	require.NoError(t, err)
+	return response.Type == MessageTypePong
+}
+
+func mustCreateMessage(t *testing.T, msgType MessageType, data interface{}) *WebSocketMessage {
+	message, err := CreateMessage(msgType, data)
+	require.NoError(t, err)
+	return message
+}
+
+func TestConnectionLimits_LargeSubscription(t *testing.T) {
+	assert.True(t, subscribeAndPing(t, NewHub()), "the default limit accepts large subscriptions")
+
+	hub := NewHub()
+	require.NoError(t, hub.SetConnectionLimits(ConnectionLimits{MaxMessageSize: 1024}))
+	assert.False(t, subscribeAndPing(t, hub), "oversized messages close the connection")
+}
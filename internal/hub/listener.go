@@ -0,0 +1,22 @@
+package hub
+
+// EventListener receives each event broadcast to clients, after it has been
+// sequenced, along with the ID of the task it is about when there is one.
+// Listeners run on the hub's broadcast loop, so they must return quickly.
+type EventListener func(taskID string, message []byte)
+
+// AddListener registers a listener for broadcast events. Heartbeats aren't
+// passed to listeners. Add listeners before calling Run.
+func (h *Hub) AddListener(listener EventListener) {
+	h.listeners = append(h.listeners, listener)
+}
+
+// notifyListeners passes a broadcast event to the listeners
+func (h *Hub) notifyListeners(msg broadcastMessage, message []byte) {
+	if msg.everyone {
+		return
+	}
+	for _, listener := range h.listeners {
+		listener(msg.taskID, message)
+	}
+}
@@ -0,0 +1,49 @@
+package hub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHubListeners(t *testing.T) {
+	hub := NewHub()
+	require.NoError(t, hub.EnableReplay(ReplayConfig{Size: 10}))
+
+	type event struct {
+		taskID  string
+		message []byte
+	}
+	received := make(chan event, 10)
+	hub.AddListener(func(taskID string, message []byte) {
+		received <- event{taskID, message}
+	})
+	runHub(t, hub)
+
+	hub.BroadcastTaskEvent("a", "task:a", []byte(`{"type":"log"}`))
+	hub.sendServerHeartbeat()
+	hub.Broadcast([]byte(`{"type":"system"}`))
+
+	next := func() event {
+		select {
+		case e := <-received:
+			return e
+		case <-time.After(time.Second):
+			t.Fatal("listener wasn't called")
+			return event{}
+		}
+	}
+
+	// Listeners see events as clients do, sequenced
+	first := next()
+	assert.Equal(t, "a", first.taskID)
+	assert.Equal(t, uint64(1), eventSeq(t, first.message))
+
+	// Heartbeats are skipped
+	second := next()
+	assert.Equal(t, "", second.taskID)
+	assert.Equal(t, MessageTypeSystem, messageType(second.message))
+	assert.Equal(t, uint64(2), eventSeq(t, second.message))
+}
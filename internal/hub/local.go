@@ -0,0 +1,44 @@
+package hub
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NewLocalClient creates a client with no WebSocket connection, for in-process
+// consumers such as tests. Its outbound messages are read from Messages and
+// its inbound messages are passed to Receive. It is placed in room when one is
+// given and joins the hub once registered.
+func (h *Hub) NewLocalClient(room string, identity *Identity) *Client {
+	now := time.Now()
+	return &Client{
+		hub:             h,
+		send:            make(chan []byte, 256),
+		id:              uuid.New().String()[:8],
+		lastHeartbeat:   now,
+		lastPong:        now,
+		connectedAt:     now,
+		subscribedTypes: make(map[MessageType]bool),
+		subscribedTasks: make(map[string]bool),
+		subscribedTags:  make(map[string]bool),
+		identity:        identity,
+		room:            room,
+	}
+}
+
+// ID returns the client's short identifier
+func (c *Client) ID() string {
+	return c.id
+}
+
+// Messages returns the client's queue of outbound messages, which the hub
+// closes when it drops the client
+func (c *Client) Messages() <-chan []byte {
+	return c.send
+}
+
+// Receive processes a message as if it had been read from the client's connection
+func (c *Client) Receive(raw []byte) {
+	c.handleMessage(raw)
+}
@@ -10,16 +10,30 @@ type MessageType string
 
 const (
 	// Outbound message types (server -> client)
-	MessageTypeTaskUpdate     MessageType = "task-update"
-	MessageTypeLog            MessageType = "log"
-	MessageTypeThreadMessage  MessageType = "thread_message"
-	MessageTypePong           MessageType = "pong"
-	MessageTypeHeartbeat      MessageType = "heartbeat"
+	MessageTypeTaskUpdate       MessageType = "task-update"
+	MessageTypeTaskCreated      MessageType = "task-created"
+	MessageTypeTaskDeleted      MessageType = "task-deleted"
+	MessageTypeLog              MessageType = "log"
+	MessageTypeThreadMessage    MessageType = "thread_message"
+	MessageTypePong             MessageType = "pong"
+	MessageTypeHeartbeat        MessageType = "heartbeat"
+	MessageTypeTaskStalled      MessageType = "task-stalled"
+	MessageTypeTaskStats        MessageType = "task-stats"
+	MessageTypeResyncRequired   MessageType = "resync-required"
+	MessageTypeSystem           MessageType = "system"
+	MessageTypeAuthOK           MessageType = "auth-ok"
+	MessageTypeSession          MessageType = "session"
+	MessageTypeSubscriptions    MessageType = "subscriptions"
+	MessageTypeCommandResult    MessageType = "command-result"
 	
 	// Inbound message types (client -> server)
-	MessageTypePing           MessageType = "ping"
-	MessageTypeSubscribe      MessageType = "subscribe"
-	MessageTypeUnsubscribe    MessageType = "unsubscribe"
+	MessageTypePing             MessageType = "ping"
+	MessageTypeSubscribe        MessageType = "subscribe"
+	MessageTypeUnsubscribe      MessageType = "unsubscribe"
+	MessageTypeUnsubscribeAll   MessageType = "unsubscribe-all"
+	MessageTypeGetSubscriptions MessageType = "get-subscriptions"
+	MessageTypeAuth             MessageType = "auth"
+	MessageTypeCommand          MessageType = "command"
 )
 
 // WebSocketMessage represents a structured WebSocket message
@@ -46,7 +60,16 @@ type PongMessage struct {
 // SubscribeMessage represents a subscription request
 type SubscribeMessage struct {
 	Types   []MessageType `json:"types"`
-	TaskIDs []string      `json:"task_ids,omitempty"`
+	TaskIDs []string      `json:"task_ids,omitempty"` // IDs or patterns such as "proj-*"
+	Tags    []string      `json:"tags,omitempty"`
+}
+
+// SubscriptionsMessage lists a client's active subscriptions in reply to a
+// get-subscriptions request. Empty lists mean the client receives every event.
+type SubscriptionsMessage struct {
+	Types   []MessageType `json:"types"`
+	TaskIDs []string      `json:"task_ids"`
+	Tags    []string      `json:"tags"`
 }
 
 // HeartbeatMessage represents server heartbeat
@@ -55,6 +78,14 @@ type HeartbeatMessage struct {
 	ServerID  string    `json:"server_id,omitempty"`
 }
 
+// ResyncMessage tells a resuming client that the events it missed are no
+// longer available and it must reload state over the REST API
+type ResyncMessage struct {
+	Since     uint64 `json:"since"`
+	OldestSeq uint64 `json:"oldest_seq,omitempty"`
+	LastSeq   uint64 `json:"last_seq,omitempty"`
+}
+
 // CreateMessage creates a WebSocket message with the given type and data
 func CreateMessage(msgType MessageType, data interface{}) (*WebSocketMessage, error) {
 	var rawData json.RawMessage
@@ -115,6 +115,9 @@ func TestMessageTypes(t *testing.T) {
 		assert.Equal(t, MessageType("ping"), MessageTypePing)
 		assert.Equal(t, MessageType("subscribe"), MessageTypeSubscribe)
 		assert.Equal(t, MessageType("unsubscribe"), MessageTypeUnsubscribe)
+		assert.Equal(t, MessageType("unsubscribe-all"), MessageTypeUnsubscribeAll)
+		assert.Equal(t, MessageType("get-subscriptions"), MessageTypeGetSubscriptions)
+		assert.Equal(t, MessageType("subscriptions"), MessageTypeSubscriptions)
 	})
 }
 
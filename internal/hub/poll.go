@@ -0,0 +1,72 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrReplayDisabled is returned when waiting for events on a hub that
+	// doesn't keep them
+	ErrReplayDisabled = errors.New("event replay is not enabled")
+
+	// ErrEventsDropped is returned when the events after a sequence number
+	// are no longer available, so the caller must reload state and wait from
+	// the latest sequence number instead
+	ErrEventsDropped = errors.New("events are no longer available")
+)
+
+// LastSeq returns the sequence number of the most recent event, or 0 when
+// replay is disabled
+func (h *Hub) LastSeq() uint64 {
+	if h.replay == nil {
+		return 0
+	}
+	return h.replay.LastSeq()
+}
+
+// WaitForEvents returns the events after since whose type is one of types, or
+// of any type when types is empty. When there are none yet it waits for one
+// to be broadcast until ctx is done, then returns no events. It also returns
+// the sequence number to wait from next, which is the latest one when the
+// events were dropped.
+func (h *Hub) WaitForEvents(ctx context.Context, since uint64, types []MessageType) ([]json.RawMessage, uint64, error) {
+	if h.replay == nil {
+		return nil, 0, ErrReplayDisabled
+	}
+
+	wanted := make(map[MessageType]bool, len(types))
+	for _, msgType := range types {
+		wanted[msgType] = true
+	}
+
+	for {
+		events, last, changed, ok := h.replay.After(since, time.Now())
+		if !ok {
+			return nil, last, ErrEventsDropped
+		}
+
+		matched := []json.RawMessage{}
+		for _, event := range events {
+			since = event.Seq
+			if len(wanted) > 0 {
+				parsed, err := ParseMessage(event.Data)
+				if err != nil || !wanted[parsed.Type] {
+					continue
+				}
+			}
+			matched = append(matched, event.Data)
+		}
+		if len(matched) > 0 {
+			return matched, since, nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return matched, since, nil
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package hub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHubWaitForEvents(t *testing.T) {
+	hub := NewHub()
+	require.NoError(t, hub.EnableReplay(ReplayConfig{Size: 3}))
+
+	hub.sequence(broadcastMessage{data: []byte(`{"type":"log"}`), room: "task:a"})
+	hub.sequence(broadcastMessage{data: []byte(`{"type":"task-update"}`), room: "task:a"})
+
+	events, next, err := hub.WaitForEvents(context.Background(), 0, []MessageType{MessageTypeTaskUpdate})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, uint64(2), eventSeq(t, events[0]))
+	assert.Equal(t, uint64(2), next)
+
+	// Waits for the next matching event, skipping others
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		hub.sequence(broadcastMessage{data: []byte(`{"type":"log"}`), room: "task:a"})
+		hub.sequence(broadcastMessage{data: []byte(`{"type":"task-update"}`), room: "task:a"})
+	}()
+	events, next, err = hub.WaitForEvents(context.Background(), next, []MessageType{MessageTypeTaskUpdate})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, uint64(4), eventSeq(t, events[0]))
+	assert.Equal(t, uint64(4), next)
+
+	// Expired waits return no events and keep the position
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	events, next, err = hub.WaitForEvents(ctx, next, nil)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+	assert.Equal(t, uint64(4), next)
+
+	// Event 1 was evicted, and seq 9 was never issued
+	for _, since := range []uint64{0, 9} {
+		_, next, err = hub.WaitForEvents(context.Background(), since, nil)
+		assert.ErrorIs(t, err, ErrEventsDropped)
+		assert.Equal(t, uint64(4), next)
+	}
+
+	_, _, err = NewHub().WaitForEvents(context.Background(), 0, nil)
+	assert.ErrorIs(t, err, ErrReplayDisabled)
+}
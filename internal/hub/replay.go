@@ -0,0 +1,270 @@
+package hub
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ReplayConfig configures the buffer of recent events that reconnecting
+// clients can resume from
+type ReplayConfig struct {
+	Size      int           // Events kept for replay
+	Retention time.Duration // Maximum age of replayed events; 0 keeps events until evicted by size
+	Path      string        // Journal file persisting events across restarts; empty keeps them in memory only
+}
+
+// replayEvent is a sequenced event as stored in the buffer and journal
+type replayEvent struct {
+	Seq  uint64          `json:"seq"`
+	Time time.Time       `json:"time"`
+	Room string          `json:"room,omitempty"`
+	Data json.RawMessage `json:"data"`
+}
+
+// replayBuffer holds the most recent broadcast events, optionally journaled to disk
+type replayBuffer struct {
+	config  ReplayConfig
+	mu      sync.Mutex
+	events  []replayEvent
+	nextSeq uint64
+	changed chan struct{} // Closed and replaced when an event is appended
+
+	journal        *os.File
+	journalEntries int // Entries written since the journal was last compacted
+}
+
+// newReplayBuffer creates a replay buffer, restoring events from the journal
+// when one is configured
+func newReplayBuffer(config ReplayConfig) (*replayBuffer, error) {
+	if config.Size <= 0 {
+		return nil, errors.New("replay size must be positive")
+	}
+
+	b := &replayBuffer{config: config, nextSeq: 1, changed: make(chan struct{})}
+	if config.Path == "" {
+		return b, nil
+	}
+
+	if err := b.load(time.Now()); err != nil {
+		return nil, err
+	}
+	if err := b.compact(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// load reads retained events from the journal
+func (b *replayBuffer) load(now time.Time) error {
+	file, err := os.Open(b.config.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open event journal: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var event replayEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			// A partial line from a crash mid-write; skip it
+			continue
+		}
+		if event.Seq >= b.nextSeq {
+			b.nextSeq = event.Seq + 1
+		}
+		b.events = append(b.events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read event journal: %w", err)
+	}
+
+	b.trim(now)
+	return nil
+}
+
+// compact rewrites the journal with only the buffered events and reopens it for appending
+func (b *replayBuffer) compact() error {
+	if b.journal != nil {
+		b.journal.Close()
+		b.journal = nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(b.config.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create event journal directory: %w", err)
+	}
+
+	tmpPath := b.config.Path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to compact event journal: %w", err)
+	}
+	writer := bufio.NewWriter(tmp)
+	for _, event := range b.events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to compact event journal: %w", err)
+		}
+		writer.Write(line)
+		writer.WriteByte('\n')
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to compact event journal: %w", err)
+	}
+	tmp.Close()
+
+	if err := os.Rename(tmpPath, b.config.Path); err != nil {
+		return fmt.Errorf("failed to compact event journal: %w", err)
+	}
+
+	journal, err := os.OpenFile(b.config.Path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open event journal: %w", err)
+	}
+	b.journal = journal
+	b.journalEntries = len(b.events)
+	return nil
+}
+
+// Append stamps a JSON object message with the next sequence number as its
+// "seq" field and records it along with the room it was broadcast to.
+// Messages that aren't JSON objects are returned unchanged and not recorded.
+func (b *replayBuffer) Append(message []byte, room string, now time.Time) ([]byte, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(message, &fields); err != nil || fields == nil {
+		return message, false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	fields["seq"], _ = json.Marshal(b.nextSeq)
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return message, false
+	}
+
+	event := replayEvent{Seq: b.nextSeq, Time: now, Room: room, Data: json.RawMessage(data)}
+	b.nextSeq++
+	b.events = append(b.events, event)
+	b.trim(now)
+	close(b.changed)
+	b.changed = make(chan struct{})
+
+	if b.journal != nil {
+		if line, err := json.Marshal(event); err == nil {
+			b.journal.Write(append(line, '\n'))
+			b.journalEntries++
+		}
+
+		// Keep the journal from growing without bound
+		if b.journalEntries >= 2*b.config.Size {
+			if err := b.compact(); err != nil {
+				log.Printf("Failed to compact event journal: %v", err)
+			}
+		}
+	}
+
+	return data, true
+}
+
+// Since returns the events after seq, limited to those broadcast to room when
+// one is given. ok is false when events after seq have already been dropped,
+// in which case the client must resync.
+func (b *replayBuffer) Since(seq uint64, room string, now time.Time) (events [][]byte, oldest uint64, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trim(now)
+
+	oldest = b.nextSeq
+	if len(b.events) > 0 {
+		oldest = b.events[0].Seq
+	}
+	if seq+1 < oldest {
+		return nil, oldest, false
+	}
+
+	for _, event := range b.events {
+		if event.Seq > seq && (room == "" || event.Room == room) {
+			events = append(events, event.Data)
+		}
+	}
+	return events, oldest, true
+}
+
+// After returns the events after seq, the sequence number of the most recent
+// event, and a channel closed when the next event is appended. ok is false
+// when events after seq have already been dropped, or when seq is ahead of
+// the buffer because it was issued before a restart that lost its events.
+func (b *replayBuffer) After(seq uint64, now time.Time) (events []replayEvent, last uint64, changed <-chan struct{}, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trim(now)
+
+	last = b.nextSeq - 1
+	oldest := b.nextSeq
+	if len(b.events) > 0 {
+		oldest = b.events[0].Seq
+	}
+	if seq+1 < oldest || seq > last {
+		return nil, last, b.changed, false
+	}
+	for _, event := range b.events {
+		if event.Seq > seq {
+			events = append(events, event)
+		}
+	}
+	return events, last, b.changed, true
+}
+
+// LastSeq returns the sequence number of the most recent event
+func (b *replayBuffer) LastSeq() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.nextSeq - 1
+}
+
+// Close closes the journal
+func (b *replayBuffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.journal == nil {
+		return nil
+	}
+	err := b.journal.Close()
+	b.journal = nil
+	return err
+}
+
+// trim drops events beyond the size limit or older than the retention window.
+// Callers must hold the lock.
+func (b *replayBuffer) trim(now time.Time) {
+	drop := 0
+	if len(b.events) > b.config.Size {
+		drop = len(b.events) - b.config.Size
+	}
+	if b.config.Retention > 0 {
+		cutoff := now.Add(-b.config.Retention)
+		for drop < len(b.events) && b.events[drop].Time.Before(cutoff) {
+			drop++
+		}
+	}
+	if drop > 0 {
+		b.events = append([]replayEvent(nil), b.events[drop:]...)
+	}
+}
@@ -0,0 +1,184 @@
+package hub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func eventSeq(t *testing.T, data []byte) uint64 {
+	var msg struct {
+		Seq uint64 `json:"seq"`
+	}
+	require.NoError(t, json.Unmarshal(data, &msg))
+	return msg.Seq
+}
+
+func TestReplayBuffer_AppendAndSince(t *testing.T) {
+	buffer, err := newReplayBuffer(ReplayConfig{Size: 3})
+	require.NoError(t, err)
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		data, ok := buffer.Append([]byte(fmt.Sprintf(`{"type":"log","data":{"n":%d}}`, i)), "", now)
+		require.True(t, ok)
+		assert.Equal(t, uint64(i+1), eventSeq(t, data))
+	}
+	assert.Equal(t, uint64(5), buffer.LastSeq())
+
+	// Events 3-5 are retained
+	events, oldest, ok := buffer.Since(3, "", now)
+	require.True(t, ok)
+	assert.Equal(t, uint64(3), oldest)
+	require.Len(t, events, 2)
+	assert.Equal(t, uint64(4), eventSeq(t, events[0]))
+
+	events, _, ok = buffer.Since(2, "", now)
+	require.True(t, ok)
+	assert.Len(t, events, 3)
+
+	// Event 2 was evicted, so a client that last saw seq 1 must resync
+	_, _, ok = buffer.Since(1, "", now)
+	assert.False(t, ok)
+
+	// Up to date clients get nothing
+	events, _, ok = buffer.Since(5, "", now)
+	assert.True(t, ok)
+	assert.Empty(t, events)
+}
+
+func TestReplayBuffer_NonObjectMessages(t *testing.T) {
+	buffer, err := newReplayBuffer(ReplayConfig{Size: 3})
+	require.NoError(t, err)
+
+	data, ok := buffer.Append([]byte("plain text"), "", time.Now())
+	assert.False(t, ok)
+	assert.Equal(t, []byte("plain text"), data)
+	assert.Equal(t, uint64(0), buffer.LastSeq())
+}
+
+func TestReplayBuffer_Retention(t *testing.T) {
+	buffer, err := newReplayBuffer(ReplayConfig{Size: 10, Retention: time.Minute})
+	require.NoError(t, err)
+
+	start := time.Now()
+	buffer.Append([]byte(`{"type":"log"}`), "", start)
+	buffer.Append([]byte(`{"type":"log"}`), "", start.Add(2*time.Minute))
+
+	_, oldest, ok := buffer.Since(0, "", start.Add(2*time.Minute))
+	assert.False(t, ok)
+	assert.Equal(t, uint64(2), oldest)
+}
+
+func TestReplayBuffer_PersistsAcrossRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	buffer, err := newReplayBuffer(ReplayConfig{Size: 10, Path: path})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		buffer.Append([]byte(`{"type":"task-update"}`), "", time.Now())
+	}
+	require.NoError(t, buffer.Close())
+
+	restored, err := newReplayBuffer(ReplayConfig{Size: 10, Path: path})
+	require.NoError(t, err)
+	defer restored.Close()
+
+	assert.Equal(t, uint64(3), restored.LastSeq())
+	events, _, ok := restored.Since(1, "", time.Now())
+	require.True(t, ok)
+	assert.Len(t, events, 2)
+
+	// Sequence numbers continue where the previous run stopped
+	data, _ := restored.Append([]byte(`{"type":"task-update"}`), "", time.Now())
+	assert.Equal(t, uint64(4), eventSeq(t, data))
+}
+
+func TestReplayBuffer_CompactsJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	buffer, err := newReplayBuffer(ReplayConfig{Size: 2, Path: path})
+	require.NoError(t, err)
+	defer buffer.Close()
+
+	for i := 0; i < 10; i++ {
+		buffer.Append([]byte(`{"type":"log"}`), "", time.Now())
+	}
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := bytes.Count(content, []byte("\n"))
+	assert.LessOrEqual(t, lines, 4)
+}
+
+func TestHubResumeFromSequence(t *testing.T) {
+	hub := NewHub()
+	require.NoError(t, hub.EnableReplay(ReplayConfig{Size: 10}))
+	runHub(t, hub)
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+
+	for i := 0; i < 3; i++ {
+		hub.Broadcast([]byte(fmt.Sprintf(`{"type":"log","data":{"n":%d}}`, i)))
+	}
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, _, err := dialTest(wsURL+"?since=1", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var seqs []uint64
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for len(seqs) < 2 {
+		message, err := conn.next()
+		require.NoError(t, err)
+		seqs = append(seqs, eventSeq(t, message))
+	}
+	assert.Equal(t, []uint64{2, 3}, seqs)
+}
+
+func TestHubResumeRequiresResync(t *testing.T) {
+	hub := NewHub()
+	runHub(t, hub)
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+
+	// Without replay, resuming always requires a resync
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, _, err := dialTest(wsURL+"?since=5", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	message, err := conn.next()
+	require.NoError(t, err)
+
+	msg, err := ParseMessage(message)
+	require.NoError(t, err)
+	assert.Equal(t, MessageTypeResyncRequired, msg.Type)
+
+	var resync ResyncMessage
+	require.NoError(t, json.Unmarshal(msg.Data, &resync))
+	assert.Equal(t, uint64(5), resync.Since)
+}
+
+func TestHubSequence_SkipsTransientEvents(t *testing.T) {
+	hub := NewHub()
+	require.NoError(t, hub.EnableReplay(ReplayConfig{Size: 10}))
+
+	stats := []byte(`{"type":"task-stats","data":{"task_id":"a"}}`)
+	assert.Equal(t, stats, hub.sequence(broadcastMessage{data: stats, room: "task:a"}))
+	assert.Equal(t, uint64(1), eventSeq(t, hub.sequence(broadcastMessage{data: []byte(`{"type":"log"}`), room: "task:a"})))
+}
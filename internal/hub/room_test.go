@@ -0,0 +1,94 @@
+package hub
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRoomClient(hub *Hub, id, room string) *Client {
+	return &Client{
+		hub:             hub,
+		send:            make(chan []byte, 256),
+		id:              id,
+		subscribedTypes: make(map[MessageType]bool),
+		subscribedTasks: make(map[string]bool),
+		subscribedTags:  make(map[string]bool),
+		room:            room,
+	}
+}
+
+// received drains the messages queued for a client
+func received(client *Client) []string {
+	var messages []string
+	for {
+		select {
+		case msg := <-client.send:
+			messages = append(messages, string(msg))
+		case <-time.After(50 * time.Millisecond):
+			return messages
+		}
+	}
+}
+
+func TestHub_BroadcastToRoom(t *testing.T) {
+	hub := NewHub()
+	runHub(t, hub)
+
+	global := newRoomClient(hub, "global", "")
+	roomA := newRoomClient(hub, "room-a", "task:a")
+	roomB := newRoomClient(hub, "room-b", "task:b")
+	hub.Register(global)
+	hub.Register(roomA)
+	hub.Register(roomB)
+
+	hub.BroadcastToRoom("task:a", []byte("a"))
+	hub.BroadcastToRoom("task:b", []byte("b"))
+	hub.Broadcast([]byte("system"))
+	hub.broadcast <- broadcastMessage{data: []byte("heartbeat"), everyone: true}
+
+	assert.Equal(t, []string{"a", "b", "system", "heartbeat"}, received(global))
+	assert.Equal(t, []string{"a", "heartbeat"}, received(roomA))
+	assert.Equal(t, []string{"b", "heartbeat"}, received(roomB))
+
+	// Leaving a room stops delivery without affecting other members
+	hub.Unregister(roomA)
+	hub.BroadcastToRoom("task:a", []byte("a2"))
+	assert.Equal(t, []string{"a2"}, received(global))
+	assert.Empty(t, received(roomB))
+}
+
+func TestHub_RoomResumeOnlyReplaysRoomEvents(t *testing.T) {
+	hub := NewHub()
+	require.NoError(t, hub.EnableReplay(ReplayConfig{Size: 10}))
+	runHub(t, hub)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.ServeRoom(w, r, "task:a")
+	}))
+	defer server.Close()
+
+	for i, room := range []string{"task:a", "task:b", "", "task:a"} {
+		hub.BroadcastToRoom(room, []byte(fmt.Sprintf(`{"type":"log","data":{"n":%d}}`, i)))
+	}
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, _, err := dialTest(wsURL+"?since=0", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var seqs []uint64
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for len(seqs) < 2 {
+		message, err := conn.next()
+		require.NoError(t, err)
+		seqs = append(seqs, eventSeq(t, message))
+	}
+	assert.Equal(t, []uint64{1, 4}, seqs)
+}
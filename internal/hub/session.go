@@ -0,0 +1,173 @@
+package hub
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// sessionRetention is how long a disconnected client's session can be resumed
+const sessionRetention = 10 * time.Minute
+
+// SessionMessage gives a client the token that resumes its session after a
+// reconnect, and says whether an earlier session was resumed
+type SessionMessage struct {
+	Token   string `json:"token"`
+	Resumed bool   `json:"resumed"`
+	LastSeq uint64 `json:"last_seq,omitempty"` // Last event delivered before the reconnect; later ones are replayed
+}
+
+// session is the state a client gets back when it reconnects with its token:
+// its subscriptions and the last event it was sent
+type session struct {
+	token string
+	user  string // Authenticated user the session belongs to; empty when unauthenticated
+	room  string
+
+	mu       sync.Mutex
+	owner    *Client // Connected client using the session; nil once it disconnects
+	lastSeq  uint64
+	subs     SubscribeMessage
+	detached time.Time
+}
+
+// delivered records that the session's client was sent the event with seq.
+// Events written by a client that lost the session are ignored.
+func (s *session) delivered(client *Client, seq uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.owner == client && seq > s.lastSeq {
+		s.lastSeq = seq
+	}
+}
+
+// sessionUser returns the user a client's sessions belong to
+func sessionUser(client *Client) string {
+	if client.identity == nil {
+		return ""
+	}
+	return client.identity.User
+}
+
+// attachSession gives a socket client a session, resuming the one named by
+// its token when it exists and belongs to the same user and room. A resumed
+// session restores the client's subscriptions and, unless the client asked
+// for a sequence number itself, replays the events sent after its last one.
+// A client still holding the session, e.g. a connection that hasn't timed out
+// yet, is disconnected. Only the Run goroutine may call it.
+func (h *Hub) attachSession(client *Client) {
+	h.sessionMu.Lock()
+	s, resumed := h.sessions[client.sessionToken]
+	if resumed && (s.user != sessionUser(client) || s.room != client.room) {
+		resumed = false
+	}
+	if !resumed {
+		s = &session{token: uuid.New().String(), user: sessionUser(client), room: client.room}
+		if h.replay != nil {
+			s.lastSeq = h.replay.LastSeq()
+		}
+		h.sessions[s.token] = s
+	}
+	h.sessionMu.Unlock()
+
+	s.mu.Lock()
+	previous := s.owner
+	s.owner = client
+	lastSeq := s.lastSeq
+	subs := s.subs
+	s.mu.Unlock()
+	client.session.Store(s)
+
+	if previous != nil {
+		h.mu.Lock()
+		if _, ok := h.clients[previous]; ok {
+			log.Printf("Client %s disconnected: session resumed by %s", previous, client)
+			h.remove(previous)
+		}
+		h.mu.Unlock()
+		if previous.conn != nil {
+			previous.conn.Close()
+		}
+	}
+
+	message := SessionMessage{Token: s.token}
+	if resumed {
+		message.Resumed = true
+		message.LastSeq = lastSeq
+		client.restoreSubscriptions(subs)
+		if client.resumeFrom == nil {
+			client.resumeFrom = &lastSeq
+		}
+		log.Printf("Client %s resumed its session from seq %d", client, lastSeq)
+	}
+
+	msg, err := CreateMessage(MessageTypeSession, message)
+	if err != nil {
+		log.Printf("Failed to create session message: %v", err)
+		return
+	}
+	msgBytes, err := MarshalMessage(msg)
+	if err != nil {
+		log.Printf("Failed to marshal session message: %v", err)
+		return
+	}
+	client.send <- msgBytes
+}
+
+// detachSession keeps a disconnecting client's subscriptions in its session
+// so a reconnect can restore them. Callers must hold the lock.
+func (h *Hub) detachSession(client *Client) {
+	s := client.session.Load()
+	if s == nil {
+		return
+	}
+	subs := client.subscriptions()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.owner != client {
+		return
+	}
+	s.owner = nil
+	s.subs = subs
+	s.detached = time.Now()
+}
+
+// pruneSessions forgets sessions whose client disconnected longer than
+// sessionRetention ago
+func (h *Hub) pruneSessions(now time.Time) {
+	h.sessionMu.Lock()
+	defer h.sessionMu.Unlock()
+	for token, s := range h.sessions {
+		s.mu.Lock()
+		expired := s.owner == nil && now.Sub(s.detached) > sessionRetention
+		s.mu.Unlock()
+		if expired {
+			delete(h.sessions, token)
+		}
+	}
+}
+
+// recordDelivery records the sequence numbers of events written to the
+// client's connection in its session
+func (c *Client) recordDelivery(messages [][]byte) {
+	s := c.session.Load()
+	if s == nil {
+		return
+	}
+	var last uint64
+	for _, message := range messages {
+		var event struct {
+			Seq uint64 `json:"seq"`
+		}
+		if json.Unmarshal(message, &event) == nil && event.Seq > last {
+			last = event.Seq
+		}
+	}
+	if last > 0 {
+		s.delivered(c, last)
+	}
+}
@@ -0,0 +1,242 @@
+package hub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testConn reads the messages a test connection receives one at a time. The
+// hub may batch queued messages into one frame separated by newlines, and
+// first sends every socket client its session, which next sets aside.
+type testConn struct {
+	*websocket.Conn
+	pending [][]byte
+	session *SessionMessage
+}
+
+// dialTest connects to a test server like websocket.DefaultDialer.Dial
+func dialTest(url string, header http.Header) (*testConn, *http.Response, error) {
+	conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		return nil, resp, err
+	}
+	return &testConn{Conn: conn}, resp, nil
+}
+
+// next returns the next message other than the session message
+func (c *testConn) next() ([]byte, error) {
+	for {
+		for len(c.pending) > 0 {
+			message := c.pending[0]
+			c.pending = c.pending[1:]
+			if !c.keepSession(message) {
+				return message, nil
+			}
+		}
+		if err := c.read(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// read queues the messages of the next frame
+func (c *testConn) read() error {
+	_, frame, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	c.pending = append(c.pending, bytes.Split(frame, newline)...)
+	return nil
+}
+
+// keepSession records message and reports true if it's the session message
+func (c *testConn) keepSession(message []byte) bool {
+	msg, err := ParseMessage(message)
+	if err != nil || msg.Type != MessageTypeSession {
+		return false
+	}
+	var session SessionMessage
+	if json.Unmarshal(msg.Data, &session) != nil {
+		return false
+	}
+	c.session = &session
+	return true
+}
+
+// awaitSession waits for the session message, leaving other messages queued
+func (c *testConn) awaitSession(t *testing.T) SessionMessage {
+	t.Helper()
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		for i, message := range c.pending {
+			if c.keepSession(message) {
+				c.pending = append(c.pending[:i], c.pending[i+1:]...)
+				return *c.session
+			}
+		}
+		require.NoError(t, c.read())
+	}
+}
+
+// waitForClients waits until n clients are registered
+func waitForClients(t *testing.T, hub *Hub, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		hub.mu.RLock()
+		defer hub.mu.RUnlock()
+		return len(hub.clients) == n
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestSession_ResumeRestoresSubscriptionsAndMissedEvents(t *testing.T) {
+	hub := NewHub()
+	require.NoError(t, hub.EnableReplay(ReplayConfig{Size: 10}))
+	runHub(t, hub)
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	conn, _, err := dialTest(wsURL, nil)
+	require.NoError(t, err)
+	session := conn.awaitSession(t)
+	assert.NotEmpty(t, session.Token)
+	assert.False(t, session.Resumed)
+
+	subscribe, err := CreateMessage(MessageTypeSubscribe, SubscribeMessage{Types: []MessageType{MessageTypeLog}, TaskIDs: []string{"task1"}})
+	require.NoError(t, err)
+	raw, err := MarshalMessage(subscribe)
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, raw))
+	require.Eventually(t, func() bool {
+		hub.mu.RLock()
+		defer hub.mu.RUnlock()
+		for client := range hub.clients {
+			return len(client.subscriptions().TaskIDs) == 1
+		}
+		return false
+	}, time.Second, 5*time.Millisecond)
+
+	// The first event is delivered, the others are broadcast while the
+	// client is away
+	hub.Broadcast([]byte(`{"type":"log","data":{"n":1}}`))
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	message, err := conn.next()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), eventSeq(t, message))
+
+	conn.Close()
+	waitForClients(t, hub, 0)
+	for i := 2; i <= 3; i++ {
+		hub.Broadcast([]byte(fmt.Sprintf(`{"type":"log","data":{"n":%d}}`, i)))
+	}
+
+	resumed, _, err := dialTest(wsURL+"?session="+session.Token, nil)
+	require.NoError(t, err)
+	defer resumed.Close()
+	assert.Equal(t, SessionMessage{Token: session.Token, Resumed: true, LastSeq: 1}, resumed.awaitSession(t))
+
+	var seqs []uint64
+	resumed.SetReadDeadline(time.Now().Add(time.Second))
+	for len(seqs) < 2 {
+		message, err := resumed.next()
+		require.NoError(t, err)
+		seqs = append(seqs, eventSeq(t, message))
+	}
+	assert.Equal(t, []uint64{2, 3}, seqs)
+
+	waitForClients(t, hub, 1)
+	hub.mu.RLock()
+	for client := range hub.clients {
+		assert.Equal(t, SubscribeMessage{Types: []MessageType{MessageTypeLog}, TaskIDs: []string{"task1"}}, client.subscriptions())
+	}
+	hub.mu.RUnlock()
+}
+
+func TestSession_UnknownTokenStartsNewSession(t *testing.T) {
+	hub := NewHub()
+	runHub(t, hub)
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+
+	conn, _, err := dialTest("ws"+strings.TrimPrefix(server.URL, "http")+"?session=unknown", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	session := conn.awaitSession(t)
+	assert.NotEqual(t, "unknown", session.Token)
+	assert.False(t, session.Resumed)
+}
+
+func TestSession_ResumeTakesOverLiveConnection(t *testing.T) {
+	hub := NewHub()
+	runHub(t, hub)
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	stale, _, err := dialTest(wsURL, nil)
+	require.NoError(t, err)
+	defer stale.Close()
+	session := stale.awaitSession(t)
+
+	conn, _, err := dialTest(wsURL+"?session="+session.Token, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.True(t, conn.awaitSession(t).Resumed)
+
+	// The connection that held the session is dropped
+	stale.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = stale.next()
+	assert.Error(t, err)
+	waitForClients(t, hub, 1)
+}
+
+func TestSession_BelongsToItsUser(t *testing.T) {
+	hub := NewHub()
+	hub.SetAuthenticator(TokenAuthenticator(map[string]Identity{
+		"alice-token": {User: "alice"},
+		"bob-token":   {User: "bob"},
+	}))
+	runHub(t, hub)
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	alice, _, err := dialTest(wsURL+"?token=alice-token", nil)
+	require.NoError(t, err)
+	session := alice.awaitSession(t)
+	alice.Close()
+	waitForClients(t, hub, 0)
+
+	bob, _, err := dialTest(wsURL+"?token=bob-token&session="+session.Token, nil)
+	require.NoError(t, err)
+	defer bob.Close()
+	assert.False(t, bob.awaitSession(t).Resumed)
+}
+
+func TestPruneSessions(t *testing.T) {
+	hub := NewHub()
+	now := time.Now()
+	hub.sessions["old"] = &session{token: "old", detached: now.Add(-sessionRetention - time.Second)}
+	hub.sessions["recent"] = &session{token: "recent", detached: now.Add(-time.Minute)}
+	hub.sessions["connected"] = &session{token: "connected", owner: &Client{}}
+
+	hub.pruneSessions(now)
+	assert.NotContains(t, hub.sessions, "old")
+	assert.Contains(t, hub.sessions, "recent")
+	assert.Contains(t, hub.sessions, "connected")
+}
@@ -0,0 +1,64 @@
+package hub
+
+import "fmt"
+
+// SlowClientPolicy decides what happens to a message for a client whose send
+// queue is full
+type SlowClientPolicy string
+
+const (
+	// SlowClientDisconnect disconnects the client so it can reconnect and resync
+	SlowClientDisconnect SlowClientPolicy = "disconnect"
+
+	// SlowClientDropMessage keeps the client and drops the new message
+	SlowClientDropMessage SlowClientPolicy = "drop-message"
+
+	// SlowClientDropOldest keeps the client and drops its oldest queued message
+	// to make room for the new one
+	SlowClientDropOldest SlowClientPolicy = "drop-oldest"
+)
+
+// ParseSlowClientPolicy parses a policy name, defaulting to disconnect when empty
+func ParseSlowClientPolicy(name string) (SlowClientPolicy, error) {
+	switch policy := SlowClientPolicy(name); policy {
+	case "":
+		return SlowClientDisconnect, nil
+	case SlowClientDisconnect, SlowClientDropMessage, SlowClientDropOldest:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown slow client policy %q", name)
+}
+
+// SetSlowClientPolicy sets how clients that can't keep up with broadcasts are handled
+func (h *Hub) SetSlowClientPolicy(policy SlowClientPolicy) {
+	h.slowClientPolicy = policy
+}
+
+// enqueue queues a message for a client, applying the slow client policy when
+// its queue is full. It reports false when the client must be disconnected.
+// Only the Run goroutine may call it, so the queue can't be closed underneath it.
+func (h *Hub) enqueue(client *Client, message []byte) bool {
+	select {
+	case client.send <- message:
+		return true
+	default:
+	}
+
+	h.recordDrop(client)
+	switch h.slowClientPolicy {
+	case SlowClientDropMessage:
+		return true
+	case SlowClientDropOldest:
+		select {
+		case <-client.send:
+		default:
+		}
+		select {
+		case client.send <- message:
+		default:
+			// A pong queued by the client's reader took the freed slot
+		}
+		return true
+	}
+	return false
+}
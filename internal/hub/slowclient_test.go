@@ -0,0 +1,88 @@
+package hub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHub_SlowClientPolicy(t *testing.T) {
+	tests := []struct {
+		policy      SlowClientPolicy
+		queued      []string
+		connected   bool
+		disconnects uint64
+	}{
+		{SlowClientDisconnect, []string{"1", "2"}, false, 1},
+		{SlowClientDropMessage, []string{"1", "2"}, true, 0},
+		{SlowClientDropOldest, []string{"2", "3"}, true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			hub := NewHub()
+			hub.SetSlowClientPolicy(tt.policy)
+			runHub(t, hub)
+
+			slow := newRoomClient(hub, "slow", "")
+			slow.send = make(chan []byte, 2)
+			fast := newRoomClient(hub, "fast", "")
+			hub.Register(slow)
+			hub.Register(fast)
+
+			for _, msg := range []string{"1", "2", "3"} {
+				hub.Broadcast([]byte(msg))
+			}
+			time.Sleep(20 * time.Millisecond)
+
+			// Other clients are unaffected by the slow one
+			assert.Equal(t, []string{"1", "2", "3"}, received(fast))
+
+			var queued []string
+			for msg := range drain(slow.send) {
+				queued = append(queued, msg)
+			}
+			assert.Equal(t, tt.queued, queued)
+			assert.Equal(t, tt.connected, slow.IsConnected())
+
+			totals := hub.Totals()
+			assert.Equal(t, tt.policy, totals.SlowClientPolicy)
+			assert.Equal(t, uint64(1), totals.DroppedMessages)
+			assert.Equal(t, tt.disconnects, totals.SlowDisconnects)
+		})
+	}
+}
+
+// drain yields the messages queued on a channel without blocking, whether or
+// not the hub has closed it
+func drain(send chan []byte) chan string {
+	out := make(chan string, cap(send))
+	for {
+		select {
+		case msg, ok := <-send:
+			if !ok {
+				close(out)
+				return out
+			}
+			out <- string(msg)
+		default:
+			close(out)
+			return out
+		}
+	}
+}
+
+func TestParseSlowClientPolicy(t *testing.T) {
+	policy, err := ParseSlowClientPolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, SlowClientDisconnect, policy)
+
+	policy, err = ParseSlowClientPolicy("drop-oldest")
+	require.NoError(t, err)
+	assert.Equal(t, SlowClientDropOldest, policy)
+
+	_, err = ParseSlowClientPolicy("block")
+	assert.Error(t, err)
+}
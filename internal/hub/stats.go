@@ -0,0 +1,118 @@
+package hub
+
+import (
+	"sort"
+	"time"
+)
+
+// Totals summarises the hub's clients and dropped messages
+type Totals struct {
+	Clients          int              `json:"clients"`
+	SlowClientPolicy SlowClientPolicy `json:"slow_client_policy"`
+	DroppedMessages  uint64           `json:"dropped_messages"` // Messages dropped since startup, including for disconnected clients
+	SlowDisconnects  uint64           `json:"slow_disconnects"` // Clients disconnected because their send queue was full
+}
+
+// Stats describes the hub's connected clients, for diagnosing slow consumers
+type Stats struct {
+	Totals
+	ClientStats []ClientStats `json:"client_stats"`
+}
+
+// ClientStats describes one connected client
+type ClientStats struct {
+	ID              string        `json:"id"`
+	User            string        `json:"user,omitempty"`
+	Room            string        `json:"room,omitempty"`
+	ConnectedAt     time.Time     `json:"connected_at"`
+	LastHeartbeat   time.Time     `json:"last_heartbeat"`
+	SubscribedTypes []MessageType `json:"subscribed_types"`
+	SubscribedTasks []string      `json:"subscribed_tasks"` // IDs and patterns
+	SubscribedTags  []string      `json:"subscribed_tags"`
+	QueueDepth      int           `json:"queue_depth"`    // Messages waiting to be written to the client
+	QueueCapacity   int           `json:"queue_capacity"` // The client is disconnected when its queue is full
+	DroppedMessages uint64        `json:"dropped_messages"`
+}
+
+// Totals returns the hub's client count and dropped message counters
+func (h *Hub) Totals() Totals {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.totals()
+}
+
+// totals builds the hub totals. Callers must hold the lock.
+func (h *Hub) totals() Totals {
+	return Totals{
+		Clients:          len(h.clients),
+		SlowClientPolicy: h.slowClientPolicy,
+		DroppedMessages:  h.dropped.Load(),
+		SlowDisconnects:  h.slowDisconnects.Load(),
+	}
+}
+
+// Stats returns a snapshot of the connected clients, ordered by connection time
+func (h *Hub) Stats() Stats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	stats := Stats{
+		Totals:      h.totals(),
+		ClientStats: make([]ClientStats, 0, len(h.clients)),
+	}
+	for client := range h.clients {
+		stats.ClientStats = append(stats.ClientStats, client.stats())
+	}
+
+	sort.Slice(stats.ClientStats, func(i, j int) bool {
+		a, b := stats.ClientStats[i], stats.ClientStats[j]
+		if !a.ConnectedAt.Equal(b.ConnectedAt) {
+			return a.ConnectedAt.Before(b.ConnectedAt)
+		}
+		return a.ID < b.ID
+	})
+	return stats
+}
+
+// stats describes the client
+func (c *Client) stats() ClientStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	stats := ClientStats{
+		ID:              c.id,
+		Room:            c.room,
+		ConnectedAt:     c.connectedAt,
+		LastHeartbeat:   c.lastHeartbeat,
+		SubscribedTypes: make([]MessageType, 0, len(c.subscribedTypes)),
+		SubscribedTasks: make([]string, 0, len(c.subscribedTasks)),
+		SubscribedTags:  make([]string, 0, len(c.subscribedTags)),
+		QueueDepth:      len(c.send),
+		QueueCapacity:   cap(c.send),
+		DroppedMessages: c.dropped.Load(),
+	}
+	if c.identity != nil {
+		stats.User = c.identity.User
+	}
+	for msgType := range c.subscribedTypes {
+		stats.SubscribedTypes = append(stats.SubscribedTypes, msgType)
+	}
+	for taskID := range c.subscribedTasks {
+		stats.SubscribedTasks = append(stats.SubscribedTasks, taskID)
+	}
+	for tag := range c.subscribedTags {
+		stats.SubscribedTags = append(stats.SubscribedTags, tag)
+	}
+	sort.Slice(stats.SubscribedTypes, func(i, j int) bool { return stats.SubscribedTypes[i] < stats.SubscribedTypes[j] })
+	sort.Strings(stats.SubscribedTasks)
+	sort.Strings(stats.SubscribedTags)
+	return stats
+}
+
+// recordDrop counts a message that couldn't be queued for a client
+func (h *Hub) recordDrop(client *Client) {
+	client.dropped.Add(1)
+	if h != nil {
+		h.dropped.Add(1)
+	}
+}
@@ -0,0 +1,50 @@
+package hub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHub_Stats(t *testing.T) {
+	hub := NewHub()
+	runHub(t, hub)
+
+	fast := newRoomClient(hub, "fast", "")
+	fast.connectedAt = time.Now()
+	fast.subscribedTypes[MessageTypeLog] = true
+	fast.subscribedTasks["task1"] = true
+	fast.identity = &Identity{User: "alice"}
+
+	slow := newRoomClient(hub, "slow", "task:a")
+	slow.connectedAt = fast.connectedAt.Add(time.Second)
+	slow.send = make(chan []byte, 1)
+
+	hub.Register(fast)
+	hub.Register(slow)
+	hub.BroadcastToRoom("task:a", []byte(`{"type":"log"}`))
+	time.Sleep(20 * time.Millisecond)
+
+	stats := hub.Stats()
+	require.Equal(t, 2, stats.Clients)
+	assert.Equal(t, "fast", stats.ClientStats[0].ID)
+	assert.Equal(t, "alice", stats.ClientStats[0].User)
+	assert.Equal(t, []MessageType{MessageTypeLog}, stats.ClientStats[0].SubscribedTypes)
+	assert.Equal(t, []string{"task1"}, stats.ClientStats[0].SubscribedTasks)
+	assert.Equal(t, 1, stats.ClientStats[0].QueueDepth)
+	assert.Equal(t, 256, stats.ClientStats[0].QueueCapacity)
+	assert.Equal(t, "task:a", stats.ClientStats[1].Room)
+	assert.Equal(t, 1, stats.ClientStats[1].QueueDepth)
+
+	// The slow client's full queue drops the next message and disconnects it
+	hub.BroadcastToRoom("task:a", []byte("second"))
+	time.Sleep(20 * time.Millisecond)
+
+	stats = hub.Stats()
+	assert.Equal(t, 1, stats.Clients)
+	assert.Equal(t, uint64(1), stats.DroppedMessages)
+	assert.Equal(t, uint64(1), stats.SlowDisconnects)
+	assert.Equal(t, uint64(1), slow.dropped.Load())
+}
@@ -0,0 +1,127 @@
+package hub
+
+import (
+	"encoding/json"
+	"path"
+	"sort"
+	"strings"
+)
+
+// isTaskPattern reports whether a subscribed task ID is a pattern, e.g.
+// "proj-*", rather than a literal ID
+func isTaskPattern(taskID string) bool {
+	return strings.ContainsAny(taskID, `*?[\`)
+}
+
+// matchTask reports whether taskID is the subscribed ID or matches it as a
+// pattern. Patterns use path.Match syntax.
+func matchTask(subscribed, taskID string) bool {
+	if subscribed == taskID {
+		return true
+	}
+	if !isTaskPattern(subscribed) {
+		return false
+	}
+	matched, err := path.Match(subscribed, taskID)
+	return err == nil && matched
+}
+
+// SetTaskTags records a task's tags, so clients subscribed to one of them
+// receive the events broadcast about the task
+func (h *Hub) SetTaskTags(taskID string, tags []string) {
+	h.tagsMu.Lock()
+	defer h.tagsMu.Unlock()
+	if len(tags) == 0 {
+		delete(h.taskTags, taskID)
+		return
+	}
+	h.taskTags[taskID] = append([]string(nil), tags...)
+}
+
+// tags returns the recorded tags of a task
+func (h *Hub) tags(taskID string) []string {
+	h.tagsMu.RLock()
+	defer h.tagsMu.RUnlock()
+	return h.taskTags[taskID]
+}
+
+// BroadcastTaskEvent sends an event about a task to the clients in room and
+// to every client outside a room. Clients with subscriptions only receive it
+// when they're subscribed to its type, the task, a pattern matching the
+// task's ID or one of its tags.
+func (h *Hub) BroadcastTaskEvent(taskID, room string, message []byte) {
+	h.send(broadcastMessage{data: message, room: room, taskID: taskID})
+}
+
+// messageType returns the type of a broadcast message, or "" when it isn't
+// a JSON message
+func messageType(data []byte) MessageType {
+	var msg struct {
+		Type MessageType `json:"type"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return ""
+	}
+	return msg.Type
+}
+
+// subscribe adds event types, task IDs or patterns and tags to the client's
+// subscriptions. Callers must hold the client's lock.
+func (c *Client) subscribe(sub SubscribeMessage) {
+	for _, msgType := range sub.Types {
+		c.subscribedTypes[msgType] = true
+	}
+	for _, taskID := range sub.TaskIDs {
+		if isTaskPattern(taskID) {
+			if _, err := path.Match(taskID, ""); err != nil {
+				continue
+			}
+		}
+		c.subscribedTasks[taskID] = true
+	}
+	for _, tag := range sub.Tags {
+		c.subscribedTags[strings.ToLower(tag)] = true
+	}
+}
+
+// unsubscribe removes event types, task IDs or patterns and tags from the
+// client's subscriptions. Callers must hold the client's lock.
+func (c *Client) unsubscribe(sub SubscribeMessage) {
+	for _, msgType := range sub.Types {
+		delete(c.subscribedTypes, msgType)
+	}
+	for _, taskID := range sub.TaskIDs {
+		delete(c.subscribedTasks, taskID)
+	}
+	for _, tag := range sub.Tags {
+		delete(c.subscribedTags, strings.ToLower(tag))
+	}
+}
+
+// subscriptions returns the client's subscriptions, sorted
+func (c *Client) subscriptions() SubscribeMessage {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var sub SubscribeMessage
+	for msgType := range c.subscribedTypes {
+		sub.Types = append(sub.Types, msgType)
+	}
+	for taskID := range c.subscribedTasks {
+		sub.TaskIDs = append(sub.TaskIDs, taskID)
+	}
+	for tag := range c.subscribedTags {
+		sub.Tags = append(sub.Tags, tag)
+	}
+	sort.Slice(sub.Types, func(i, j int) bool { return sub.Types[i] < sub.Types[j] })
+	sort.Strings(sub.TaskIDs)
+	sort.Strings(sub.Tags)
+	return sub
+}
+
+// restoreSubscriptions subscribes the client to the given subscriptions
+func (c *Client) restoreSubscriptions(sub SubscribeMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscribe(sub)
+}
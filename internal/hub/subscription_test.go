@@ -0,0 +1,104 @@
+package hub
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchTask(t *testing.T) {
+	tests := []struct {
+		subscribed string
+		taskID     string
+		want       bool
+	}{
+		{"proj-1", "proj-1", true},
+		{"proj-1", "proj-12", false},
+		{"proj-*", "proj-12", true},
+		{"proj-*", "other-1", false},
+		{"task-?", "task-a", true},
+		{"task-[ab]", "task-c", false},
+		{"task-[", "task-[", true},
+		{"task-[", "task-a", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, matchTask(tt.subscribed, tt.taskID), "%s matching %s", tt.subscribed, tt.taskID)
+	}
+}
+
+func TestShouldReceiveMessage_PatternsAndTags(t *testing.T) {
+	client := newRoomClient(nil, "c", "")
+	client.subscribe(SubscribeMessage{TaskIDs: []string{"proj-*", "bad-["}, Tags: []string{"Urgent"}})
+
+	assert.Equal(t, SubscribeMessage{TaskIDs: []string{"proj-*"}, Tags: []string{"urgent"}}, client.subscriptions(), "invalid patterns are ignored")
+	assert.True(t, client.ShouldReceiveMessage(MessageTypeLog, "proj-7"))
+	assert.False(t, client.ShouldReceiveMessage(MessageTypeLog, "other-7"))
+	assert.True(t, client.ShouldReceiveMessage(MessageTypeLog, "other-7", "backend", "URGENT"))
+	assert.False(t, client.ShouldReceiveMessage(MessageTypeLog, "other-7", "backend"))
+	assert.False(t, client.ShouldReceiveMessage(MessageTypeSystem, ""))
+
+	client.unsubscribe(SubscribeMessage{TaskIDs: []string{"proj-*"}, Tags: []string{"URGENT"}})
+	assert.Equal(t, SubscribeMessage{}, client.subscriptions())
+	assert.True(t, client.ShouldReceiveMessage(MessageTypeLog, "other-7"), "no subscriptions receive everything")
+}
+
+func TestBroadcastTaskEvent_FollowsSubscriptions(t *testing.T) {
+	hub := NewHub()
+	runHub(t, hub)
+
+	everything := newRoomClient(hub, "everything", "")
+	byPattern := newRoomClient(hub, "pattern", "")
+	byPattern.subscribe(SubscribeMessage{TaskIDs: []string{"proj-*"}})
+	byTag := newRoomClient(hub, "tag", "")
+	byTag.subscribe(SubscribeMessage{Tags: []string{"urgent"}})
+	byType := newRoomClient(hub, "type", "")
+	byType.subscribe(SubscribeMessage{Types: []MessageType{MessageTypeTaskUpdate}})
+	for _, client := range []*Client{everything, byPattern, byTag, byType} {
+		hub.Register(client)
+	}
+
+	hub.SetTaskTags("other-1", []string{"Urgent"})
+	hub.BroadcastTaskEvent("proj-1", "task:proj-1", []byte(`{"type":"log","n":1}`))
+	hub.BroadcastTaskEvent("other-1", "task:other-1", []byte(`{"type":"log","n":2}`))
+	hub.BroadcastTaskEvent("other-2", "task:other-2", []byte(`{"type":"task-update","n":3}`))
+	hub.SetTaskTags("other-1", nil)
+	hub.BroadcastTaskEvent("other-1", "task:other-1", []byte(`{"type":"log","n":4}`))
+	time.Sleep(20 * time.Millisecond)
+
+	assert.Equal(t, []string{`{"type":"log","n":1}`, `{"type":"log","n":2}`, `{"type":"task-update","n":3}`, `{"type":"log","n":4}`}, received(everything))
+	assert.Equal(t, []string{`{"type":"log","n":1}`}, received(byPattern))
+	assert.Equal(t, []string{`{"type":"log","n":2}`}, received(byTag))
+	assert.Equal(t, []string{`{"type":"task-update","n":3}`}, received(byType))
+}
+
+func TestClient_GetSubscriptionsAndUnsubscribeAll(t *testing.T) {
+	hub := NewHub()
+	client := hub.NewLocalClient("", nil)
+
+	query := func() (string, SubscriptionsMessage) {
+		client.Receive([]byte(`{"type":"get-subscriptions","id":"q1"}`))
+		msg, err := ParseMessage(<-client.Messages())
+		require.NoError(t, err)
+		require.Equal(t, MessageTypeSubscriptions, msg.Type)
+		var subs SubscriptionsMessage
+		require.NoError(t, json.Unmarshal(msg.Data, &subs))
+		return msg.ID, subs
+	}
+
+	client.Receive([]byte(`{"type":"subscribe","data":{"types":["log"],"task_ids":["proj-*","a1"],"tags":["urgent"]}}`))
+	id, subs := query()
+	assert.Equal(t, "q1", id)
+	assert.Equal(t, SubscriptionsMessage{
+		Types:   []MessageType{MessageTypeLog},
+		TaskIDs: []string{"a1", "proj-*"},
+		Tags:    []string{"urgent"},
+	}, subs)
+
+	client.Receive([]byte(`{"type":"unsubscribe-all"}`))
+	_, subs = query()
+	assert.Equal(t, SubscriptionsMessage{Types: []MessageType{}, TaskIDs: []string{}, Tags: []string{}}, subs)
+	assert.True(t, client.ShouldReceiveMessage(MessageTypeTaskUpdate, "b2"))
+}
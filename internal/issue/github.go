@@ -0,0 +1,113 @@
+package issue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultGitHubAPI is the GitHub REST API comments are posted to
+const DefaultGitHubAPI = "https://api.github.com"
+
+// githubTimeout bounds a single request to the GitHub API
+const githubTimeout = 30 * time.Second
+
+// ParseGitHubURL returns the key ("owner/repo#number") of a GitHub issue or
+// pull request URL such as https://github.com/acme/api/issues/42
+func ParseGitHubURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host != "github.com" {
+		return "", fmt.Errorf("not a GitHub issue URL: %s", rawURL)
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) != 4 || (parts[2] != "issues" && parts[2] != "pull") {
+		return "", fmt.Errorf("not a GitHub issue URL: %s", rawURL)
+	}
+	number, err := strconv.Atoi(parts[3])
+	if err != nil || number <= 0 {
+		return "", fmt.Errorf("not a GitHub issue URL: %s", rawURL)
+	}
+	return fmt.Sprintf("%s/%s#%d", parts[0], parts[1], number), nil
+}
+
+// SplitGitHubKey splits an "owner/repo#number" key into its repository and number
+func SplitGitHubKey(key string) (string, int, error) {
+	repo, rawNumber, ok := strings.Cut(key, "#")
+	owner, name, hasName := strings.Cut(repo, "/")
+	if !ok || !hasName || owner == "" || name == "" || strings.Contains(name, "/") {
+		return "", 0, fmt.Errorf("invalid GitHub issue key %q: use owner/repo#number", key)
+	}
+	number, err := strconv.Atoi(rawNumber)
+	if err != nil || number <= 0 {
+		return "", 0, fmt.Errorf("invalid GitHub issue key %q: use owner/repo#number", key)
+	}
+	return repo, number, nil
+}
+
+// GitHubClient posts comments on GitHub issues and pull requests
+type GitHubClient struct {
+	Token   string // Needs permission to write issues
+	BaseURL string // Defaults to DefaultGitHubAPI; set for GitHub Enterprise
+	client  *http.Client
+}
+
+// NewGitHubClient creates a client authenticating with token
+func NewGitHubClient(token, baseURL string) *GitHubClient {
+	if baseURL == "" {
+		baseURL = DefaultGitHubAPI
+	}
+	return &GitHubClient{
+		Token:   token,
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: githubTimeout},
+	}
+}
+
+// Comment posts body as a comment on the issue with the given key, returning
+// the comment's URL
+func (c *GitHubClient) Comment(ctx context.Context, key, body string) (string, error) {
+	repo, number, err := SplitGitHubKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(map[string]string{"body": body})
+	if err != nil {
+		return "", err
+	}
+	endpoint := fmt.Sprintf("%s/repos/%s/issues/%d/comments", c.BaseURL, repo, number)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to comment on %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("failed to comment on %s: %s: %s", key, resp.Status, bytes.TrimSpace(message))
+	}
+
+	var comment struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&comment); err != nil {
+		return "", fmt.Errorf("failed to read comment on %s: %w", key, err)
+	}
+	return comment.HTMLURL, nil
+}
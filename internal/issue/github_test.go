@@ -0,0 +1,80 @@
+package issue
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGitHubURL(t *testing.T) {
+	key, err := ParseGitHubURL("https://github.com/acme/api/issues/42")
+	require.NoError(t, err)
+	assert.Equal(t, "acme/api#42", key)
+
+	key, err = ParseGitHubURL("https://github.com/acme/api/pull/7/")
+	require.NoError(t, err)
+	assert.Equal(t, "acme/api#7", key)
+
+	for _, invalid := range []string{
+		"https://gitlab.com/acme/api/issues/42",
+		"https://github.com/acme/api",
+		"https://github.com/acme/api/issues/new",
+		"https://github.com/acme/api/issues/42/comments",
+	} {
+		_, err := ParseGitHubURL(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestSplitGitHubKey(t *testing.T) {
+	repo, number, err := SplitGitHubKey("acme/api#42")
+	require.NoError(t, err)
+	assert.Equal(t, "acme/api", repo)
+	assert.Equal(t, 42, number)
+
+	for _, invalid := range []string{"acme/api", "acme#1", "acme/api#x", "acme/api/x#1", "/api#1"} {
+		_, _, err := SplitGitHubKey(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestGitHubClient_Comment(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/repos/acme/api/issues/42/comments", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "Done", body["body"])
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"html_url": "https://github.com/acme/api/issues/42#issuecomment-1"}`))
+	}))
+	defer server.Close()
+
+	url, err := NewGitHubClient("secret", server.URL+"/").Comment(context.Background(), "acme/api#42", "Done")
+	require.NoError(t, err)
+	assert.Equal(t, "https://github.com/acme/api/issues/42#issuecomment-1", url)
+}
+
+func TestGitHubClient_CommentError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message": "Resource not accessible by integration"}`))
+	}))
+	defer server.Close()
+
+	client := NewGitHubClient("secret", server.URL)
+	_, err := client.Comment(context.Background(), "acme/api#42", "Done")
+	assert.ErrorContains(t, err, "403 Forbidden")
+	assert.ErrorContains(t, err, "Resource not accessible")
+
+	_, err = client.Comment(context.Background(), "OPS-17", "Done")
+	assert.ErrorContains(t, err, "invalid GitHub issue key")
+}
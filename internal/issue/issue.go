@@ -0,0 +1,169 @@
+// Package issue turns GitHub and Jira issue webhooks into task priorities and
+// tags, so tasks created from issues stay in sync with them
+package issue
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+// Providers of issue webhooks
+const (
+	ProviderGitHub = "github"
+	ProviderJira   = "jira"
+)
+
+// Issue is the part of a GitHub or Jira issue that tasks inherit
+type Issue struct {
+	Provider string   `json:"provider"`
+	Key      string   `json:"key"` // "owner/repo#number" on GitHub, the issue key on Jira
+	URL      string   `json:"url,omitempty"`
+	Priority string   `json:"priority,omitempty"` // Jira's priority field; GitHub has none
+	Labels   []string `json:"labels,omitempty"`
+}
+
+// Link returns the link recorded on tasks created from the issue
+func (i Issue) Link() *worker.IssueLink {
+	return &worker.IssueLink{Provider: i.Provider, Key: i.Key, URL: i.URL}
+}
+
+// Mapping derives a task's priority and tags from an issue
+type Mapping struct {
+	// Priorities maps an issue priority or label to a task priority. The
+	// issue's priority is tried first, then its labels in order.
+	Priorities map[string]string
+	// Tags maps issue labels to task tags
+	Tags map[string]string
+	// CopyLabels adds labels missing from Tags as tags unchanged
+	CopyLabels bool
+}
+
+// Fields returns the task fields for an issue. Keys are matched case-insensitively.
+func (m Mapping) Fields(issue Issue) worker.IssueFields {
+	fields := worker.IssueFields{Tags: []string{}}
+
+	candidates := append([]string{issue.Priority}, issue.Labels...)
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		if priority, ok := lookup(m.Priorities, candidate); ok {
+			fields.Priority = priority
+			break
+		}
+	}
+
+	for _, label := range issue.Labels {
+		if tag, ok := lookup(m.Tags, label); ok {
+			fields.Tags = append(fields.Tags, tag)
+		} else if m.CopyLabels {
+			fields.Tags = append(fields.Tags, label)
+		}
+	}
+	return fields
+}
+
+// lookup finds a key in a map ignoring case
+func lookup(values map[string]string, key string) (string, bool) {
+	if value, ok := values[key]; ok {
+		return value, true
+	}
+	for k, value := range values {
+		if strings.EqualFold(k, key) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// ParseGitHub reads the issue from a GitHub "issues" webhook payload
+func ParseGitHub(body []byte) (*Issue, error) {
+	var payload struct {
+		Issue *struct {
+			Number  int    `json:"number"`
+			HTMLURL string `json:"html_url"`
+			Labels  []struct {
+				Name string `json:"name"`
+			} `json:"labels"`
+		} `json:"issue"`
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid GitHub payload: %w", err)
+	}
+	if payload.Issue == nil || payload.Issue.Number == 0 || payload.Repository.FullName == "" {
+		return nil, errors.New("GitHub payload has no issue")
+	}
+
+	issue := &Issue{
+		Provider: ProviderGitHub,
+		Key:      fmt.Sprintf("%s#%d", payload.Repository.FullName, payload.Issue.Number),
+		URL:      payload.Issue.HTMLURL,
+		Labels:   []string{},
+	}
+	for _, label := range payload.Issue.Labels {
+		issue.Labels = append(issue.Labels, label.Name)
+	}
+	return issue, nil
+}
+
+// ParseJira reads the issue from a Jira issue webhook payload
+func ParseJira(body []byte) (*Issue, error) {
+	var payload struct {
+		Issue *struct {
+			Key    string `json:"key"`
+			Self   string `json:"self"`
+			Fields struct {
+				Priority *struct {
+					Name string `json:"name"`
+				} `json:"priority"`
+				Labels []string `json:"labels"`
+			} `json:"fields"`
+		} `json:"issue"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid Jira payload: %w", err)
+	}
+	if payload.Issue == nil || payload.Issue.Key == "" {
+		return nil, errors.New("Jira payload has no issue")
+	}
+
+	issue := &Issue{
+		Provider: ProviderJira,
+		Key:      payload.Issue.Key,
+		URL:      payload.Issue.Self,
+		Labels:   payload.Issue.Fields.Labels,
+	}
+	if issue.Labels == nil {
+		issue.Labels = []string{}
+	}
+	if payload.Issue.Fields.Priority != nil {
+		issue.Priority = payload.Issue.Fields.Priority.Name
+	}
+	return issue, nil
+}
+
+// VerifyGitHubSignature checks a payload against GitHub's X-Hub-Signature-256 header
+func VerifyGitHubSignature(secret string, body []byte, signature string) bool {
+	digest, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	expected, err := hex.DecodeString(digest)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
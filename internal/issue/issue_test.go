@@ -0,0 +1,89 @@
+package issue
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapping_Fields(t *testing.T) {
+	mapping := Mapping{
+		Priorities: map[string]string{"Highest": "high", "priority: low": "low"},
+		Tags:       map[string]string{"bug": "type:bug"},
+	}
+
+	fields := mapping.Fields(Issue{Priority: "highest", Labels: []string{"Bug", "docs"}})
+	assert.Equal(t, "high", fields.Priority)
+	assert.Equal(t, []string{"type:bug"}, fields.Tags)
+
+	// Labels are tried when the priority isn't mapped
+	fields = mapping.Fields(Issue{Priority: "Medium", Labels: []string{"docs", "priority: low"}})
+	assert.Equal(t, "low", fields.Priority)
+	assert.Equal(t, []string{}, fields.Tags)
+
+	mapping.CopyLabels = true
+	fields = mapping.Fields(Issue{Labels: []string{"bug", "docs"}})
+	assert.Empty(t, fields.Priority)
+	assert.Equal(t, []string{"type:bug", "docs"}, fields.Tags)
+}
+
+func TestParseGitHub(t *testing.T) {
+	issue, err := ParseGitHub([]byte(`{
+		"action": "labeled",
+		"issue": {"number": 42, "html_url": "https://github.com/acme/api/issues/42", "labels": [{"name": "bug"}, {"name": "P1"}]},
+		"repository": {"full_name": "acme/api"}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, &Issue{
+		Provider: ProviderGitHub,
+		Key:      "acme/api#42",
+		URL:      "https://github.com/acme/api/issues/42",
+		Labels:   []string{"bug", "P1"},
+	}, issue)
+
+	_, err = ParseGitHub([]byte(`{"zen": "Keep it logically awesome."}`))
+	assert.Error(t, err)
+	_, err = ParseGitHub([]byte(`not json`))
+	assert.Error(t, err)
+}
+
+func TestParseJira(t *testing.T) {
+	issue, err := ParseJira([]byte(`{
+		"webhookEvent": "jira:issue_updated",
+		"issue": {"key": "OPS-17", "self": "https://acme.atlassian.net/rest/api/2/issue/10017",
+			"fields": {"priority": {"name": "Highest"}, "labels": ["incident"]}}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, &Issue{
+		Provider: ProviderJira,
+		Key:      "OPS-17",
+		URL:      "https://acme.atlassian.net/rest/api/2/issue/10017",
+		Priority: "Highest",
+		Labels:   []string{"incident"},
+	}, issue)
+
+	issue, err = ParseJira([]byte(`{"issue": {"key": "OPS-18", "fields": {}}}`))
+	require.NoError(t, err)
+	assert.Empty(t, issue.Priority)
+	assert.Equal(t, []string{}, issue.Labels)
+
+	_, err = ParseJira([]byte(`{"webhookEvent": "project_created"}`))
+	assert.Error(t, err)
+}
+
+func TestVerifyGitHubSignature(t *testing.T) {
+	body := []byte(`{"issue":{}}`)
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	assert.True(t, VerifyGitHubSignature("s3cret", body, signature))
+	assert.False(t, VerifyGitHubSignature("other", body, signature))
+	assert.False(t, VerifyGitHubSignature("s3cret", []byte(`{}`), signature))
+	assert.False(t, VerifyGitHubSignature("s3cret", body, "sha1=abc"))
+	assert.False(t, VerifyGitHubSignature("s3cret", body, "sha256=zz"))
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimw "github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/audit"
+)
+
+// Audit records every mutating request (POST, PUT, PATCH, DELETE) in the audit
+// log with its caller, endpoint, task ID and outcome. identify names the
+// caller; requests it can't attribute are recorded as "anonymous". Requests
+// are never failed because of the audit log.
+func Audit(log *audit.Log, identify func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !mutating(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ww := chimw.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			entry := audit.Entry{
+				ID:         uuid.New().String(),
+				Time:       time.Now(),
+				User:       "anonymous",
+				RemoteAddr: r.RemoteAddr,
+				RequestID:  GetRequestID(r.Context()),
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				Status:     status,
+				Outcome:    audit.OutcomeSuccess,
+			}
+			if identify != nil {
+				if user := identify(r); user != "" {
+					entry.User = user
+				}
+			}
+			// Routing has filled in the route context by the time the handler returns
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				entry.Endpoint = rctx.RoutePattern()
+				entry.TaskID = rctx.URLParam("id")
+			}
+			if status >= http.StatusBadRequest {
+				entry.Outcome = audit.OutcomeFailure
+			}
+
+			if err := log.Record(entry); err != nil {
+				Logger(r.Context()).Error("failed to record audit entry", "error", err)
+			}
+		})
+	}
+}
+
+// mutating reports whether a request method changes state
+func mutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/config"
+)
+
+func corsHandler(cfg config.CORSConfig) http.Handler {
+	return CORS(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func TestCORS_AllowedOrigin(t *testing.T) {
+	handler := corsHandler(config.CORSConfig{
+		AllowedOrigins:   []string{"http://localhost:3000"},
+		AllowCredentials: true,
+	})
+
+	req := httptest.NewRequest("GET", "/api/tasks", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "http://localhost:3000", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "Origin", w.Header().Get("Vary"))
+	assert.Equal(t, "X-Ampd-Version, X-Request-ID, X-Log-Size, Content-Range, ETag", w.Header().Get("Access-Control-Expose-Headers"))
+}
+
+func TestCORS_DisallowedOrigin(t *testing.T) {
+	handler := corsHandler(config.CORSConfig{AllowedOrigins: []string{"http://localhost:3000"}})
+
+	req := httptest.NewRequest("GET", "/api/tasks", nil)
+	req.Header.Set("Origin", "http://evil.example.com")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORS_Wildcard(t *testing.T) {
+	handler := corsHandler(config.CORSConfig{AllowedOrigins: []string{"*"}})
+
+	req := httptest.NewRequest("GET", "/api/tasks", nil)
+	req.Header.Set("Origin", "https://dash.example.com")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	handler := corsHandler(config.CORSConfig{
+		AllowedOrigins: []string{"http://localhost:3000"},
+		AllowedMethods: []string{"GET", "PATCH"},
+		AllowedHeaders: []string{"Content-Type"},
+		MaxAge:         600,
+	})
+
+	req := httptest.NewRequest("OPTIONS", "/api/tasks/abc", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	req.Header.Set("Access-Control-Request-Method", "PATCH")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "GET, PATCH", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Content-Type", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+
+	// Preflight from an unknown origin is rejected
+	req.Header.Set("Origin", "http://evil.example.com")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -1,7 +1,7 @@
 package middleware
 
 import (
-	"log"
+	"errors"
 	"net/http"
 
 	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
@@ -20,16 +20,29 @@ func Error(handler ErrorHandler) http.HandlerFunc {
 		}
 
 		// Log the error for debugging
-		log.Printf("API Error: %v", err)
+		Logger(r.Context()).Error("API error", "error", err)
 
-		// Check if it's an APIError
-		if apiErr, ok := err.(*apierr.APIError); ok {
-			response.Error(w, apiErr.StatusCode, apiErr.Message)
-			return
+		body := response.ErrorBody{
+			Code:      apierr.DefaultCode(http.StatusInternalServerError),
+			Message:   "Internal server error",
+			RequestID: GetRequestID(r.Context()),
+		}
+		statusCode := http.StatusInternalServerError
+
+		// APIErrors carry their own status, code and message; anything else
+		// is reported as a generic 500 so internals aren't leaked
+		var apiErr *apierr.APIError
+		if errors.As(err, &apiErr) {
+			statusCode = apiErr.StatusCode
+			body.Code = apiErr.Code
+			if body.Code == "" {
+				body.Code = apierr.DefaultCode(statusCode)
+			}
+			body.Message = apiErr.Message
+			body.Details = apiErr.Details
 		}
 
-		// Generic error - return 500
-		response.Error(w, http.StatusInternalServerError, "Internal server error")
+		response.ErrorJSON(w, statusCode, body)
 	}
 }
 
@@ -38,8 +51,12 @@ func Recovery(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				log.Printf("Panic recovered: %v", err)
-				response.Error(w, http.StatusInternalServerError, "Internal server error")
+				Logger(r.Context()).Error("Panic recovered", "panic", err)
+				response.ErrorJSON(w, http.StatusInternalServerError, response.ErrorBody{
+					Code:      apierr.DefaultCode(http.StatusInternalServerError),
+					Message:   "Internal server error",
+					RequestID: GetRequestID(r.Context()),
+				})
 			}
 		}()
 		next.ServeHTTP(w, r)
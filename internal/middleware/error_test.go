@@ -37,8 +37,30 @@ func TestError_APIError(t *testing.T) {
 	handler(w, req)
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
-	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
-	assert.Equal(t, "invalid input", w.Body.String())
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"code":"bad_request","message":"invalid input"}`, w.Body.String())
+}
+
+func TestError_CodeDetailsAndRequestID(t *testing.T) {
+	handler := RequestID(Error(func(w http.ResponseWriter, r *http.Request) error {
+		return apierr.Conflict("task is busy").
+			WithCode("task_busy").
+			WithDetails(map[string]string{"status": "running"})
+	}))
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set(RequestIDHeader, "req-123")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.JSONEq(t, `{
+		"code": "task_busy",
+		"message": "task is busy",
+		"details": {"status": "running"},
+		"request_id": "req-123"
+	}`, w.Body.String())
 }
 
 func TestError_GenericError(t *testing.T) {
@@ -52,8 +74,8 @@ func TestError_GenericError(t *testing.T) {
 	handler(w, req)
 
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
-	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
-	assert.Equal(t, "Internal server error", w.Body.String())
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"code":"internal_server_error","message":"Internal server error"}`, w.Body.String())
 }
 
 func TestError_WrappedAPIError(t *testing.T) {
@@ -68,7 +90,7 @@ func TestError_WrappedAPIError(t *testing.T) {
 	handler(w, req)
 
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
-	assert.Equal(t, "failed to save data", w.Body.String())
+	assert.JSONEq(t, `{"code":"internal_server_error","message":"failed to save data"}`, w.Body.String())
 }
 
 func TestRecovery_NoPanic(t *testing.T) {
@@ -97,6 +119,6 @@ func TestRecovery_WithPanic(t *testing.T) {
 	handler.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
-	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
-	assert.Equal(t, "Internal server error", w.Body.String())
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"code":"internal_server_error","message":"Internal server error"}`, w.Body.String())
 }
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+)
+
+// captureLogs routes the default slog logger to a JSON buffer for the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+func TestRequestID_Generated(t *testing.T) {
+	var seen string
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = GetRequestID(r.Context())
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/tasks", nil))
+
+	assert.NotEmpty(t, seen)
+	assert.Equal(t, seen, w.Header().Get(RequestIDHeader))
+}
+
+func TestRequestID_Propagated(t *testing.T) {
+	var seen string
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = GetRequestID(r.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/api/tasks", nil)
+	req.Header.Set(RequestIDHeader, "client-abc-123")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, "client-abc-123", seen)
+	assert.Equal(t, "client-abc-123", w.Header().Get(RequestIDHeader))
+}
+
+func TestRequestID_RejectsInvalid(t *testing.T) {
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, id := range []string{"has space", strings.Repeat("a", 200), "bad\x01id"} {
+		req := httptest.NewRequest("GET", "/api/tasks", nil)
+		req.Header.Set(RequestIDHeader, id)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		assert.NotEqual(t, id, w.Header().Get(RequestIDHeader))
+		assert.NotEmpty(t, w.Header().Get(RequestIDHeader))
+	}
+}
+
+func TestRequestLogger(t *testing.T) {
+	logs := captureLogs(t)
+
+	handler := RequestID(RequestLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	})))
+
+	req := httptest.NewRequest("POST", "/api/tasks", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &entry))
+	assert.Equal(t, "request", entry["msg"])
+	assert.Equal(t, "POST", entry["method"])
+	assert.Equal(t, "/api/tasks", entry["path"])
+	assert.Equal(t, float64(http.StatusCreated), entry["status"])
+	assert.Equal(t, float64(7), entry["bytes"])
+	assert.Equal(t, "req-1", entry["request_id"])
+	assert.Contains(t, entry, "latency")
+}
+
+func TestError_LogsRequestID(t *testing.T) {
+	logs := captureLogs(t)
+
+	handler := RequestID(Error(func(w http.ResponseWriter, r *http.Request) error {
+		return apierr.NotFound("Task not found")
+	}))
+
+	req := httptest.NewRequest("GET", "/api/tasks/missing", nil)
+	req.Header.Set(RequestIDHeader, "req-2")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "req-2", w.Header().Get(RequestIDHeader))
+	assert.Contains(t, logs.String(), `"request_id":"req-2"`)
+}
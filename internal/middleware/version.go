@@ -0,0 +1,17 @@
+package middleware
+
+import "net/http"
+
+// VersionHeader is the response header carrying the daemon's version
+const VersionHeader = "X-Ampd-Version"
+
+// Version adds the daemon's version to every response, so clients can tell
+// which daemon they are talking to
+func Version(version string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(VersionHeader, version)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
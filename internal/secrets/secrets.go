@@ -0,0 +1,218 @@
+// Package secrets stores named values, such as API tokens, that tasks can
+// reference without the values appearing in the tasks themselves
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned for a secret that isn't in the store
+var ErrNotFound = errors.New("secret not found")
+
+// ErrInvalidName is returned for a secret name that can't be stored
+var ErrInvalidName = errors.New("invalid secret name")
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,127}$`)
+
+// Secret describes a stored secret; its value is only returned by Get
+type Secret struct {
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// entry is a secret as saved to the store's file
+type entry struct {
+	Secret
+	Value []byte `json:"value"` // Nonce followed by the sealed value
+}
+
+// Store keeps secrets in a file, each value encrypted with AES-GCM under a key
+// derived from the master key
+type Store struct {
+	path string
+	aead cipher.AEAD
+	mu   sync.Mutex
+}
+
+// Open opens the store saved at path, creating it on the first Set. It fails
+// if existing secrets can't be decrypted with masterKey.
+func Open(path, masterKey string) (*Store, error) {
+	if masterKey == "" {
+		return nil, errors.New("secrets master key must not be empty")
+	}
+	key := sha256.Sum256([]byte(masterKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{path: path, aead: aead}
+	entries, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if _, err := s.open(e); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// List returns the stored secrets sorted by name
+func (s *Store) List() ([]Secret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Secret, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, e.Secret)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// Get returns the value of the named secret
+func (s *Store) Get(name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	e, ok := entries[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return s.open(e)
+}
+
+// Set stores value under name, reporting whether the secret is new
+func (s *Store) Set(name, value string) (Secret, bool, error) {
+	if !namePattern.MatchString(name) {
+		return Secret{}, false, fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.load()
+	if err != nil {
+		return Secret{}, false, err
+	}
+
+	now := time.Now().UTC()
+	e, exists := entries[name]
+	if !exists {
+		e.Secret = Secret{Name: name, Created: now}
+	}
+	e.Updated = now
+
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return Secret{}, false, err
+	}
+	// Sealing with the name keeps values from being swapped between secrets
+	e.Value = s.aead.Seal(nonce, nonce, []byte(value), []byte(name))
+	entries[name] = e
+
+	if err := s.save(entries); err != nil {
+		return Secret{}, false, err
+	}
+	return e.Secret, !exists, nil
+}
+
+// Delete removes the named secret
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := entries[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	delete(entries, name)
+	return s.save(entries)
+}
+
+// open decrypts a saved secret's value
+func (s *Store) open(e entry) (string, error) {
+	size := s.aead.NonceSize()
+	if len(e.Value) < size {
+		return "", fmt.Errorf("secret %s is corrupt", e.Name)
+	}
+	value, err := s.aead.Open(nil, e.Value[:size], e.Value[size:], []byte(e.Name))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret %s: wrong master key or corrupt store", e.Name)
+	}
+	return string(value), nil
+}
+
+// load reads the saved secrets by name
+func (s *Store) load() (map[string]entry, error) {
+	entries := make(map[string]entry)
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return entries, nil
+		}
+		return nil, err
+	}
+	if len(data) == 0 {
+		return entries, nil
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to read secrets: %w", err)
+	}
+	return entries, nil
+}
+
+// save replaces the store's file, readable only by the daemon's user
+func (s *Store) save(entries map[string]entry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".secrets-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
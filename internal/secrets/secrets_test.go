@@ -0,0 +1,73 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	store, err := Open(path, "master")
+	require.NoError(t, err)
+
+	secret, created, err := store.Set("github-token", "ghp_123")
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "github-token", secret.Name)
+
+	_, created, err = store.Set("github-token", "ghp_456")
+	require.NoError(t, err)
+	assert.False(t, created)
+	_, _, err = store.Set("npm", "npm_789")
+	require.NoError(t, err)
+
+	value, err := store.Get("github-token")
+	require.NoError(t, err)
+	assert.Equal(t, "ghp_456", value)
+
+	list, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "github-token", list[0].Name)
+	assert.Equal(t, "npm", list[1].Name)
+
+	// Values are encrypted at rest and the file is private
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "ghp_456")
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	require.NoError(t, store.Delete("npm"))
+	_, err = store.Get("npm")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.Delete("npm"), ErrNotFound)
+
+	_, _, err = store.Set("../etc", "x")
+	assert.ErrorIs(t, err, ErrInvalidName)
+}
+
+func TestOpen_WrongMasterKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	store, err := Open(path, "master")
+	require.NoError(t, err)
+	_, _, err = store.Set("github-token", "ghp_123")
+	require.NoError(t, err)
+
+	_, err = Open(path, "other")
+	assert.ErrorContains(t, err, "wrong master key")
+
+	reopened, err := Open(path, "master")
+	require.NoError(t, err)
+	value, err := reopened.Get("github-token")
+	require.NoError(t, err)
+	assert.Equal(t, "ghp_123", value)
+
+	_, err = Open(path, "")
+	assert.Error(t, err)
+}
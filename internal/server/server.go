@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"path/filepath"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/config"
+)
+
+// Max age advertised in the Strict-Transport-Security header (one year)
+const hstsMaxAge = 31536000
+
+// Server serves the API over HTTP, or over HTTPS with an optional plain HTTP
+// listener that redirects to it
+type Server struct {
+	cfg      *config.Config
+	main     *http.Server
+	redirect *http.Server
+}
+
+// New creates a server for the handler according to the configuration
+func New(cfg *config.Config, handler http.Handler) *Server {
+	s := &Server{cfg: cfg}
+
+	if !cfg.TLS.Enabled() {
+		s.main = &http.Server{Addr: ":" + cfg.Port, Handler: handler}
+		return s
+	}
+
+	s.main = &http.Server{
+		Addr:    ":" + cfg.Port,
+		Handler: SecureHeaders(handler),
+		TLSConfig: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		},
+	}
+
+	var redirect http.Handler = RedirectHandler(cfg.Port)
+	if cfg.TLS.UseAutocert() {
+		cacheDir := cfg.TLS.Autocert.CacheDir
+		if cacheDir == "" {
+			cacheDir = filepath.Join(cfg.LogDir, "autocert")
+		}
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.TLS.Autocert.Domains...),
+			Cache:      autocert.DirCache(cacheDir),
+			Email:      cfg.TLS.Autocert.Email,
+		}
+		s.main.TLSConfig = manager.TLSConfig()
+		s.main.TLSConfig.MinVersion = tls.VersionTLS12
+
+		// Answer ACME HTTP-01 challenges on the redirect listener
+		redirect = manager.HTTPHandler(redirect)
+	}
+
+	if cfg.TLS.RedirectPort != "" {
+		s.redirect = &http.Server{Addr: ":" + cfg.TLS.RedirectPort, Handler: redirect}
+	}
+
+	return s
+}
+
+// ListenAndServe starts the listeners and blocks until the main one stops
+func (s *Server) ListenAndServe() error {
+	if !s.cfg.TLS.Enabled() {
+		log.Printf("Starting ampd server on %s", s.main.Addr)
+		return s.main.ListenAndServe()
+	}
+
+	if s.redirect != nil {
+		go func() {
+			log.Printf("Redirecting HTTP on %s to HTTPS", s.redirect.Addr)
+			if err := s.redirect.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("HTTP redirect server failed: %v", err)
+			}
+		}()
+	}
+
+	log.Printf("Starting ampd server with TLS on %s", s.main.Addr)
+	// Certificates come from TLSConfig when using autocert
+	return s.main.ListenAndServeTLS(s.cfg.TLS.CertFile, s.cfg.TLS.KeyFile)
+}
+
+// Shutdown gracefully stops all listeners
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.redirect != nil {
+		if err := s.redirect.Shutdown(ctx); err != nil {
+			return fmt.Errorf("failed to shut down redirect server: %w", err)
+		}
+	}
+	return s.main.Shutdown(ctx)
+}
+
+// RedirectHandler permanently redirects plain HTTP requests to the same URL
+// over HTTPS on the given port
+func RedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
+
+// SecureHeaders tells browsers to only contact the server over HTTPS
+func SecureHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", fmt.Sprintf("max-age=%d", hstsMaxAge))
+		next.ServeHTTP(w, r)
+	})
+}
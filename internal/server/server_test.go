@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/config"
+)
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		httpsPort string
+		host      string
+		target    string
+		expected  string
+	}{
+		{"default port", "443", "ampd.example.com", "/api/tasks?status=running", "https://ampd.example.com/api/tasks?status=running"},
+		{"strips http port", "443", "ampd.example.com:80", "/healthz", "https://ampd.example.com/healthz"},
+		{"custom port", "8443", "localhost:8080", "/api/ws", "https://localhost:8443/api/ws"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Host = tt.host
+			w := httptest.NewRecorder()
+
+			RedirectHandler(tt.httpsPort).ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+			assert.Equal(t, tt.expected, w.Header().Get("Location"))
+		})
+	}
+}
+
+func TestSecureHeaders(t *testing.T) {
+	handler := SecureHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Strict-Transport-Security"), "max-age=")
+}
+
+func TestNew(t *testing.T) {
+	plain := New(&config.Config{Port: "8080"}, http.NotFoundHandler())
+	assert.Nil(t, plain.main.TLSConfig)
+	assert.Nil(t, plain.redirect)
+
+	cfg := &config.Config{
+		Port:   "443",
+		LogDir: t.TempDir(),
+		TLS: config.TLSConfig{
+			Autocert:     config.AutocertConfig{Domains: []string{"ampd.example.com"}},
+			RedirectPort: "80",
+		},
+	}
+	secure := New(cfg, http.NotFoundHandler())
+	assert.NotNil(t, secure.main.TLSConfig.GetCertificate)
+	assert.Equal(t, ":80", secure.redirect.Addr)
+}
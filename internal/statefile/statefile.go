@@ -0,0 +1,125 @@
+// Package statefile versions the JSON files the daemon keeps its state in, so
+// files written by older releases are upgraded when they're read and files
+// written by newer releases are refused instead of silently losing fields.
+//
+// A versioned file is an envelope holding the format version and the state:
+//
+//	{"version": 1, "data": {...}}
+//
+// Files written before versioning hold the state alone and are version 0.
+package statefile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Migration upgrades a state file's data by one version
+type Migration func(data json.RawMessage) (json.RawMessage, error)
+
+// Unchanged is the migration to a version that only changed the envelope,
+// such as the first
+func Unchanged(data json.RawMessage) (json.RawMessage, error) {
+	return data, nil
+}
+
+// Format describes the versions of one kind of state file
+type Format struct {
+	Name       string      // Names the file in errors, e.g. "workers"
+	Migrations []Migration // Migrations[i] upgrades version i to i+1
+}
+
+// Version returns the version files are written with
+func (f Format) Version() int {
+	return len(f.Migrations)
+}
+
+// envelope is a versioned state file
+type envelope struct {
+	Version int             `json:"version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Decode reads a state file's contents into v, upgrading them from the version
+// they were written with. It returns that version, which is older than
+// Version when the file should be rewritten.
+func (f Format) Decode(contents []byte, v interface{}) (int, error) {
+	version, data, err := f.unwrap(contents)
+	if err != nil {
+		return 0, err
+	}
+	if version > f.Version() {
+		return version, fmt.Errorf("%s state is version %d, newer than the supported version %d; upgrade the daemon", f.Name, version, f.Version())
+	}
+
+	for i := version; i < f.Version(); i++ {
+		if data, err = f.Migrations[i](data); err != nil {
+			return version, fmt.Errorf("failed to upgrade %s state from version %d: %w", f.Name, i, err)
+		}
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return version, err
+	}
+	return version, nil
+}
+
+// unwrap returns the version and data of a state file. Unversioned files are
+// objects or arrays without a numeric "version", so are told apart from
+// envelopes by its type.
+func (f Format) unwrap(contents []byte) (int, json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(contents, &fields); err != nil {
+		// Not an object, so an unversioned file such as an array
+		return 0, contents, nil
+	}
+
+	raw, ok := fields["version"]
+	var version int
+	if !ok || json.Unmarshal(raw, &version) != nil {
+		return 0, contents, nil
+	}
+	if version < 1 {
+		return 0, nil, fmt.Errorf("%s state has invalid version %d", f.Name, version)
+	}
+	data := fields["data"]
+	if data == nil {
+		data = json.RawMessage("null")
+	}
+	return version, data, nil
+}
+
+// Encode returns v as a state file of the current version
+func (f Format) Encode(v interface{}) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "  ", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "{\n  \"version\": %d,\n  \"data\": ", f.Version())
+	b.Write(data)
+	b.WriteString("\n}\n")
+	return b.Bytes(), nil
+}
+
+// Backup copies the file at path, written with an older version, to
+// <path>.v<version>.bak before it's upgraded, so the upgrade can be undone by
+// restoring it and running the previous release. An existing backup is kept.
+func Backup(path string, version int) error {
+	backup := fmt.Sprintf("%s.v%d.bak", path, version)
+	if _, err := os.Stat(backup); err == nil {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(backup, contents, info.Mode().Perm())
+}
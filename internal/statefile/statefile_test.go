@@ -0,0 +1,98 @@
+package statefile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type item struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// testFormat renames "title" to "name" in version 2
+var testFormat = Format{
+	Name: "items",
+	Migrations: []Migration{
+		Unchanged,
+		func(data json.RawMessage) (json.RawMessage, error) {
+			var items map[string]map[string]interface{}
+			if err := json.Unmarshal(data, &items); err != nil {
+				return nil, err
+			}
+			for _, it := range items {
+				it["name"] = it["title"]
+				delete(it, "title")
+			}
+			return json.Marshal(items)
+		},
+	},
+}
+
+func TestFormat_EncodeDecode(t *testing.T) {
+	items := map[string]item{"a": {Name: "first", Count: 1}}
+	contents, err := testFormat.Encode(items)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(contents), "{\n  \"version\": 2,\n"))
+
+	var decoded map[string]item
+	version, err := testFormat.Decode(contents, &decoded)
+	require.NoError(t, err)
+	assert.Equal(t, 2, version)
+	assert.Equal(t, items, decoded)
+}
+
+func TestFormat_DecodeUpgrades(t *testing.T) {
+	expected := map[string]item{"version": {Name: "first", Count: 1}}
+
+	// Unversioned files hold the state alone, even with a "version" key
+	var decoded map[string]item
+	version, err := testFormat.Decode([]byte(`{"version": {"title": "first", "count": 1}}`), &decoded)
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+	assert.Equal(t, expected, decoded)
+
+	decoded = nil
+	version, err = testFormat.Decode([]byte(`{"version": 1, "data": {"version": {"title": "first", "count": 1}}}`), &decoded)
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+	assert.Equal(t, expected, decoded)
+}
+
+func TestFormat_DecodeErrors(t *testing.T) {
+	var decoded map[string]item
+	_, err := testFormat.Decode([]byte(`{"version": 3, "data": {}}`), &decoded)
+	assert.ErrorContains(t, err, "newer than the supported version 2")
+
+	_, err = testFormat.Decode([]byte(`{"version": 0, "data": {}}`), &decoded)
+	assert.ErrorContains(t, err, "invalid version")
+
+	_, err = testFormat.Decode([]byte(`{"a": 1}`), &decoded)
+	assert.ErrorContains(t, err, "failed to upgrade items state from version 1")
+
+	_, err = testFormat.Decode([]byte(`{not json`), &decoded)
+	assert.Error(t, err)
+}
+
+func TestBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "items.json")
+	require.NoError(t, os.WriteFile(path, []byte("old"), 0644))
+
+	require.NoError(t, Backup(path, 0))
+	backup, err := os.ReadFile(path + ".v0.bak")
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(backup))
+
+	// A second upgrade from the same version keeps the first backup
+	require.NoError(t, os.WriteFile(path, []byte("newer"), 0644))
+	require.NoError(t, Backup(path, 0))
+	backup, err = os.ReadFile(path + ".v0.bak")
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(backup))
+}
@@ -0,0 +1,263 @@
+// Package storage keeps files in an S3-compatible object store: Amazon S3,
+// Google Cloud Storage through its XML API with HMAC keys, or any other
+// service accepting AWS Signature Version 4
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Backends selecting the default endpoint and region
+const (
+	BackendS3  = "s3"
+	BackendGCS = "gcs"
+)
+
+// ErrNotFound is returned when an object doesn't exist
+var ErrNotFound = errors.New("object not found")
+
+// requestTimeout bounds a single request to the store
+const requestTimeout = 5 * time.Minute
+
+// Config describes the bucket objects are kept in
+type Config struct {
+	Backend         string // "s3" or "gcs"
+	Bucket          string
+	Prefix          string // Prepended to every key, e.g. "ampd/"
+	Region          string // Defaults to us-east-1 on S3 and "auto" on GCS
+	Endpoint        string // Defaults to the backend's public endpoint
+	AccessKeyID     string // An HMAC key's access ID on GCS
+	SecretAccessKey string
+}
+
+// Store reads and writes objects with path-style requests signed with AWS
+// Signature Version 4
+type Store struct {
+	config Config
+	client *http.Client
+	now    func() time.Time
+}
+
+// New creates a store for the configured bucket
+func New(config Config) (*Store, error) {
+	switch config.Backend {
+	case BackendS3:
+		if config.Region == "" {
+			config.Region = "us-east-1"
+		}
+		if config.Endpoint == "" {
+			config.Endpoint = "https://s3." + config.Region + ".amazonaws.com"
+		}
+	case BackendGCS:
+		if config.Region == "" {
+			config.Region = "auto"
+		}
+		if config.Endpoint == "" {
+			config.Endpoint = "https://storage.googleapis.com"
+		}
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", config.Backend)
+	}
+	if config.Bucket == "" {
+		return nil, errors.New("storage bucket is required")
+	}
+	if config.AccessKeyID == "" || config.SecretAccessKey == "" {
+		return nil, errors.New("storage credentials are required")
+	}
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+
+	return &Store{
+		config: config,
+		client: &http.Client{Timeout: requestTimeout},
+		now:    time.Now,
+	}, nil
+}
+
+// Put uploads body as the object key, replacing any existing object
+func (s *Store) Put(ctx context.Context, key string, body io.ReadSeeker) error {
+	hash := sha256.New()
+	size, err := io.Copy(hash, body)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read %s: %w", key, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), io.NopCloser(body))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+
+	resp, err := s.do(req, hex.EncodeToString(hash.Sum(nil)))
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Get downloads the object key. The caller must close the returned reader.
+func (s *Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	return resp.Body, nil
+}
+
+// Delete removes the object key. Deleting a missing object succeeds.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req, emptyPayloadHash)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// objectURL returns the path-style URL of an object
+func (s *Store) objectURL(key string) string {
+	return s.config.Endpoint + escapePath(s.config.Bucket+"/"+s.config.Prefix+key)
+}
+
+// do signs and sends a request, turning error statuses into errors
+func (s *Store) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	sign(req, payloadHash, s.config, s.now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return nil, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(message))
+}
+
+// emptyPayloadHash is the SHA-256 of an empty body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// sign adds AWS Signature Version 4 headers to req, signing every header it
+// already has along with the host
+func sign(req *http.Request, payloadHash string, config Config, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escapePath(strings.TrimPrefix(req.URL.Path, "/")),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + config.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+config.SecretAccessKey), date)
+	for _, part := range []string{config.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s,SignedHeaders=%s,Signature=%s",
+		config.AccessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+// canonicalQuery returns the query sorted and encoded for signing
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, escape(name)+"="+escape(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// escapePath encodes each segment of a slash-separated path for signing,
+// with a leading slash
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = escape(segment)
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// escape percent-encodes everything but unreserved characters, as SigV4 requires
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
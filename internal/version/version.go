@@ -0,0 +1,20 @@
+// Package version reports the daemon's version
+package version
+
+import "runtime/debug"
+
+// version is set at build time with
+// -ldflags "-X github.com/brettsmith212/amp-orchestrator-2/internal/version.version=v1.2.3"
+var version string
+
+// String returns the version set at build time, falling back to the module
+// version recorded by `go install` and then to "dev"
+func String() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
@@ -0,0 +1,144 @@
+package webhook
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/config"
+)
+
+// Default email templates, used when the configuration doesn't set them
+const (
+	DefaultEmailSubject = "[ampd] {{with .Task}}{{if .Title}}{{.Title}}{{else}}Task {{.ID}}{{end}}{{end}}: {{.Attributes.Status}}"
+	DefaultEmailBody    = "Task {{.TaskID}} finished with status {{.Attributes.Status}}{{with .Attributes.Project}} in project {{.}}{{end}}.\n\n{{json .Task}}\n"
+)
+
+// sendMailFunc sends a message over SMTP; smtp.SendMail in production
+type sendMailFunc func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error
+
+// emailer delivers task events to email recipients
+type emailer struct {
+	config   config.EmailConfig
+	subject  *template.Template
+	body     *template.Template
+	sendMail sendMailFunc
+}
+
+// SetEmail sends events of the configured types to email recipients, parsing
+// the subject and body templates up front so mistakes are reported at startup.
+// A configuration without a host disables email.
+func (d *Dispatcher) SetEmail(cfg config.EmailConfig) error {
+	if !cfg.Enabled() {
+		d.email = nil
+		return nil
+	}
+
+	if cfg.Subject == "" {
+		cfg.Subject = DefaultEmailSubject
+	}
+	if cfg.Body == "" {
+		cfg.Body = DefaultEmailBody
+	}
+	if len(cfg.Events) == 0 {
+		cfg.Events = []string{"task-finished"}
+	}
+
+	subject, err := template.New("email_subject").Funcs(templateFuncs).Option("missingkey=error").Parse(cfg.Subject)
+	if err != nil {
+		return fmt.Errorf("invalid email subject template: %w", err)
+	}
+	body, err := template.New("email_body").Funcs(templateFuncs).Option("missingkey=error").Parse(cfg.Body)
+	if err != nil {
+		return fmt.Errorf("invalid email body template: %w", err)
+	}
+
+	d.email = &emailer{config: cfg, subject: subject, body: body, sendMail: smtp.SendMail}
+	return nil
+}
+
+// dispatch sends an event asynchronously to its recipients, if email is
+// configured and subscribed to the event's type
+func (e *emailer) dispatch(event Event) {
+	if e == nil || !matchesAny(e.config.Events, event.Type) {
+		return
+	}
+	to := e.recipients(event.Attributes.Project)
+	if len(to) == 0 {
+		return
+	}
+
+	go func() {
+		if err := e.send(event, to); err != nil {
+			log.Printf("Email delivery failed: %v", err)
+		}
+	}()
+}
+
+// recipients returns everyone notified of a project's events, without duplicates
+func (e *emailer) recipients(project string) []string {
+	seen := make(map[string]bool)
+	var to []string
+	for _, address := range append(append([]string{}, e.config.To...), e.config.Projects[project]...) {
+		key := strings.ToLower(address)
+		if !seen[key] {
+			seen[key] = true
+			to = append(to, address)
+		}
+	}
+	return to
+}
+
+// send renders an event as an email and sends it to the recipients
+func (e *emailer) send(event Event, to []string) error {
+	msg, err := e.message(event, to)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if e.config.Username != "" {
+		auth = smtp.PlainAuth("", e.config.Username, e.config.Password, e.config.Host)
+	}
+	addr := net.JoinHostPort(e.config.Host, strconv.Itoa(e.config.Port))
+	if err := e.sendMail(addr, auth, e.config.From, to, msg); err != nil {
+		return fmt.Errorf("failed to send email to %s: %w", strings.Join(to, ", "), err)
+	}
+	return nil
+}
+
+// message renders the subject and body templates into a plain text email
+func (e *emailer) message(event Event, to []string) ([]byte, error) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	var subject, body bytes.Buffer
+	if err := e.subject.Execute(&subject, event); err != nil {
+		return nil, fmt.Errorf("failed to render email subject: %w", err)
+	}
+	if err := e.body.Execute(&body, event); err != nil {
+		return nil, fmt.Errorf("failed to render email body: %w", err)
+	}
+
+	// Newlines in the subject would start new headers
+	subjectLine := strings.Join(strings.Fields(subject.String()), " ")
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subjectLine))
+	fmt.Fprintf(&msg, "Date: %s\r\n", event.Timestamp.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(body.String(), "\r\n", "\n"), "\n", "\r\n"))
+	return msg.Bytes(), nil
+}
@@ -0,0 +1,130 @@
+package webhook
+
+import (
+	"errors"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/config"
+)
+
+// sentMail records a message passed to sendMail
+type sentMail struct {
+	addr string
+	auth smtp.Auth
+	from string
+	to   []string
+	msg  string
+}
+
+// newTestEmailer returns a dispatcher sending email through a recorder
+func newTestEmailer(t *testing.T, cfg config.EmailConfig) (*Dispatcher, chan sentMail) {
+	d, err := NewDispatcher(nil)
+	require.NoError(t, err)
+	require.NoError(t, d.SetEmail(cfg))
+
+	sent := make(chan sentMail, 10)
+	d.email.sendMail = func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+		sent <- sentMail{addr: addr, auth: auth, from: from, to: to, msg: string(msg)}
+		return nil
+	}
+	return d, sent
+}
+
+func finishedEvent(project string) Event {
+	return Event{
+		Type:       "task-finished",
+		TaskID:     "abc",
+		Task:       testTask{ID: "abc", Title: "Fix login", Status: "failed"},
+		Timestamp:  time.Date(2025, 6, 4, 16, 20, 0, 0, time.UTC),
+		Attributes: Attributes{Status: "failed", Project: project},
+	}
+}
+
+func TestEmail_SendsToProjectRecipients(t *testing.T) {
+	d, sent := newTestEmailer(t, config.EmailConfig{
+		Host:     "smtp.example.com",
+		Port:     587,
+		Username: "ampd",
+		Password: "secret",
+		From:     "ampd@example.com",
+		To:       []string{"team@example.com"},
+		Projects: map[string][]string{"infra": {"oncall@example.com", "Team@example.com"}},
+	})
+
+	d.Dispatch(finishedEvent("infra"))
+
+	select {
+	case mail := <-sent:
+		assert.Equal(t, "smtp.example.com:587", mail.addr)
+		assert.NotNil(t, mail.auth)
+		assert.Equal(t, "ampd@example.com", mail.from)
+		assert.Equal(t, []string{"team@example.com", "oncall@example.com"}, mail.to)
+		assert.Contains(t, mail.msg, "Subject: [ampd] Fix login: failed\r\n")
+		assert.Contains(t, mail.msg, "To: team@example.com, oncall@example.com\r\n")
+		assert.Contains(t, mail.msg, "\r\n\r\nTask abc finished with status failed in project infra.\r\n")
+	case <-time.After(time.Second):
+		t.Fatal("email was not sent")
+	}
+}
+
+func TestEmail_SkipsOtherEventsAndProjects(t *testing.T) {
+	d, sent := newTestEmailer(t, config.EmailConfig{
+		Host:     "smtp.example.com",
+		Port:     25,
+		From:     "ampd@example.com",
+		Projects: map[string][]string{"infra": {"oncall@example.com"}},
+	})
+
+	update := finishedEvent("infra")
+	update.Type = "task-update"
+	d.Dispatch(update)
+	d.Dispatch(finishedEvent("web"))
+
+	select {
+	case mail := <-sent:
+		t.Fatalf("unexpected email to %v", mail.to)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestEmail_MessageTemplates(t *testing.T) {
+	d, err := NewDispatcher(nil)
+	require.NoError(t, err)
+	require.NoError(t, d.SetEmail(config.EmailConfig{
+		Host:    "smtp.example.com",
+		Port:    25,
+		From:    "ampd@example.com",
+		To:      []string{"team@example.com"},
+		Subject: "{{.Task.Title}}\nBcc: attacker@example.com",
+		Body:    "Status: {{upper .Task.Status}}\n",
+	}))
+
+	msg, err := d.email.message(finishedEvent(""), []string{"team@example.com"})
+	require.NoError(t, err)
+	assert.Contains(t, string(msg), "Subject: Fix login Bcc: attacker@example.com\r\n")
+	assert.True(t, strings.HasSuffix(string(msg), "\r\n\r\nStatus: FAILED\r\n"))
+
+	assert.Error(t, d.SetEmail(config.EmailConfig{Host: "smtp.example.com", Body: "{{.Task"}))
+}
+
+func TestEmail_SendError(t *testing.T) {
+	d, _ := newTestEmailer(t, config.EmailConfig{
+		Host: "smtp.example.com",
+		Port: 25,
+		From: "ampd@example.com",
+		To:   []string{"team@example.com"},
+	})
+	assert.Equal(t, []string{"team@example.com"}, d.email.recipients("web"))
+
+	d.email.sendMail = func(string, smtp.Auth, string, []string, []byte) error {
+		return errors.New("connection refused")
+	}
+	err := d.email.send(finishedEvent(""), []string{"team@example.com"})
+	assert.ErrorContains(t, err, "failed to send email to team@example.com: connection refused")
+}
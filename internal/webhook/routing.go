@@ -0,0 +1,150 @@
+package webhook
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/config"
+)
+
+// Tag prefixes identifying a task's project and owner for routing
+const (
+	projectTagPrefix = "project:"
+	ownerTagPrefix   = "owner:"
+)
+
+// Attributes describe the task an event is about, for matching routing rules
+type Attributes struct {
+	Tags     []string
+	Priority string
+	Status   string
+	Project  string
+}
+
+// Evaluation describes where an event would be delivered
+type Evaluation struct {
+	Routes   []string `json:"routes"`   // Routes that matched, in evaluation order
+	Webhooks []string `json:"webhooks"` // Webhooks the event would be delivered to
+}
+
+// SetRoutes sets the routing rules, which are evaluated in order for every
+// event. Webhooks named by a route only receive events a route sends them.
+func (d *Dispatcher) SetRoutes(routes []config.RouteConfig) error {
+	for _, h := range d.hooks {
+		h.routed = false
+	}
+
+	for _, route := range routes {
+		for _, name := range route.Webhooks {
+			h, ok := d.byName[name]
+			if !ok {
+				return fmt.Errorf("route %s: %w: %s", route.Name, ErrNotFound, name)
+			}
+			h.routed = true
+		}
+	}
+
+	d.routes = routes
+	return nil
+}
+
+// Evaluate reports which routes match an event and which webhooks it would be
+// delivered to, without delivering it
+func (d *Dispatcher) Evaluate(event Event) Evaluation {
+	evaluation := Evaluation{Routes: []string{}, Webhooks: []string{}}
+	if d == nil {
+		return evaluation
+	}
+
+	routes, hooks := d.targets(event)
+	evaluation.Routes = append(evaluation.Routes, routes...)
+	for _, h := range hooks {
+		evaluation.Webhooks = append(evaluation.Webhooks, h.config.Name)
+	}
+	return evaluation
+}
+
+// targets returns the names of the routes matching an event and the webhooks
+// it should be delivered to
+func (d *Dispatcher) targets(event Event) ([]string, []*hook) {
+	var matched []string
+	selected := make(map[string]bool)
+	for _, route := range d.routes {
+		if !routeMatches(route, event) {
+			continue
+		}
+		matched = append(matched, route.Name)
+		for _, name := range route.Webhooks {
+			selected[name] = true
+		}
+		if route.Final {
+			break
+		}
+	}
+
+	var hooks []*hook
+	for _, h := range d.hooks {
+		if !h.subscribed(event.Type) {
+			continue
+		}
+		if h.routed && !selected[h.config.Name] {
+			continue
+		}
+		hooks = append(hooks, h)
+	}
+	return matched, hooks
+}
+
+// routeMatches reports whether an event satisfies every criterion of a route
+func routeMatches(route config.RouteConfig, event Event) bool {
+	attrs := event.Attributes
+	return matchesAny(route.Events, event.Type) &&
+		matchesAny(route.Priorities, attrs.Priority) &&
+		matchesAny(route.Statuses, attrs.Status) &&
+		hasAnyTag(route.Tags, "", attrs.Tags) &&
+		matchesProject(route.Projects, attrs) &&
+		hasAnyTag(route.Owners, ownerTagPrefix, attrs.Tags)
+}
+
+// matchesAny reports whether value is one of values, treating no values as a wildcard
+func matchesAny(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesProject reports whether the task belongs to any of projects, either
+// as its project or through a project:<name> tag, treating no projects as a
+// wildcard
+func matchesProject(projects []string, attrs Attributes) bool {
+	if len(projects) == 0 {
+		return true
+	}
+	if attrs.Project != "" && matchesAny(projects, attrs.Project) {
+		return true
+	}
+	return hasAnyTag(projects, projectTagPrefix, attrs.Tags)
+}
+
+// hasAnyTag reports whether tags contain any of values with the given prefix,
+// treating no values as a wildcard
+func hasAnyTag(values []string, prefix string, tags []string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, prefix) {
+			continue
+		}
+		if matchesAny(values, strings.TrimPrefix(tag, prefix)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/config"
+)
+
+func newRoutedDispatcher(t *testing.T, routes []config.RouteConfig) *Dispatcher {
+	d, err := NewDispatcher([]config.WebhookConfig{
+		{Name: "oncall", URL: "http://example.com"},
+		{Name: "quiet", URL: "http://example.com"},
+		{Name: "audit", URL: "http://example.com", Events: []string{"task-update"}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, d.SetRoutes(routes))
+	return d
+}
+
+func TestDispatcher_Evaluate(t *testing.T) {
+	routes := []config.RouteConfig{
+		{Name: "infra-failures", Projects: []string{"infra"}, Statuses: []string{"failed"}, Webhooks: []string{"oncall"}, Final: true},
+		{Name: "urgent", Priorities: []string{"high"}, Webhooks: []string{"oncall"}},
+		{Name: "docs", Tags: []string{"docs"}, Owners: []string{"alice"}, Webhooks: []string{"quiet"}},
+		{Name: "catch-all", Webhooks: []string{"quiet"}},
+	}
+	d := newRoutedDispatcher(t, routes)
+
+	tests := []struct {
+		name     string
+		event    Event
+		routes   []string
+		webhooks []string
+	}{
+		{
+			name:     "final route stops evaluation",
+			event:    Event{Type: "task-update", Attributes: Attributes{Tags: []string{"project:infra"}, Priority: "high", Status: "failed"}},
+			routes:   []string{"infra-failures"},
+			webhooks: []string{"oncall", "audit"},
+		},
+		{
+			name:     "task project matches projects",
+			event:    Event{Type: "task-update", Attributes: Attributes{Project: "infra", Status: "failed"}},
+			routes:   []string{"infra-failures"},
+			webhooks: []string{"oncall", "audit"},
+		},
+		{
+			name:     "criteria must all match",
+			event:    Event{Type: "task-update", Attributes: Attributes{Tags: []string{"docs", "owner:bob"}}},
+			routes:   []string{"catch-all"},
+			webhooks: []string{"quiet", "audit"},
+		},
+		{
+			name:     "several routes match",
+			event:    Event{Type: "task-update", Attributes: Attributes{Tags: []string{"docs", "owner:alice"}, Priority: "HIGH"}},
+			routes:   []string{"urgent", "docs", "catch-all"},
+			webhooks: []string{"oncall", "quiet", "audit"},
+		},
+		{
+			name:     "event subscriptions still apply",
+			event:    Event{Type: "task-stalled"},
+			routes:   []string{"catch-all"},
+			webhooks: []string{"quiet"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluation := d.Evaluate(tt.event)
+			assert.Equal(t, tt.routes, evaluation.Routes)
+			assert.Equal(t, tt.webhooks, evaluation.Webhooks)
+		})
+	}
+}
+
+func TestDispatcher_EvaluateWithoutRoutes(t *testing.T) {
+	d := newRoutedDispatcher(t, nil)
+
+	evaluation := d.Evaluate(Event{Type: "task-update"})
+	assert.Empty(t, evaluation.Routes)
+	assert.Equal(t, []string{"oncall", "quiet", "audit"}, evaluation.Webhooks)
+}
+
+func TestDispatcher_SetRoutesUnknownWebhook(t *testing.T) {
+	d, err := NewDispatcher(nil)
+	require.NoError(t, err)
+
+	err = d.SetRoutes([]config.RouteConfig{{Name: "r", Webhooks: []string{"missing"}}})
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
@@ -0,0 +1,241 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/config"
+)
+
+const (
+	// Time allowed for a single webhook delivery
+	deliveryTimeout = 10 * time.Second
+
+	// Header carrying the HMAC-SHA256 signature of the payload
+	signatureHeader = "X-Ampd-Signature"
+)
+
+// ErrNotFound is returned when no webhook is configured with the requested name
+var ErrNotFound = errors.New("webhook not found")
+
+// Event is a task event delivered to webhooks. Templates can reference its
+// fields, e.g. {{.Type}} or {{.Task.Title}}.
+type Event struct {
+	Type      string      `json:"type"`
+	TaskID    string      `json:"task_id,omitempty"`
+	Task      interface{} `json:"task,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+
+	Attributes Attributes `json:"-"` // Matched against routing rules
+}
+
+// DeliveryResult describes the outcome of delivering an event to a webhook
+type DeliveryResult struct {
+	Webhook    string `json:"webhook"`
+	Payload    string `json:"payload"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// hook is a configured webhook with its parsed payload template
+type hook struct {
+	config   config.WebhookConfig
+	template *template.Template
+	routed   bool // Only receives events a route sends it
+}
+
+// Dispatcher delivers task events to the configured webhooks
+type Dispatcher struct {
+	hooks  []*hook
+	byName map[string]*hook
+	routes []config.RouteConfig
+	client *http.Client
+	email  *emailer // Nil when email is disabled
+}
+
+// templateFuncs are available to payload templates
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// NewDispatcher creates a dispatcher for the given webhooks, parsing each
+// payload template up front so mistakes are reported at startup
+func NewDispatcher(webhooks []config.WebhookConfig) (*Dispatcher, error) {
+	d := &Dispatcher{
+		byName: make(map[string]*hook),
+		client: &http.Client{Timeout: deliveryTimeout},
+	}
+
+	for _, cfg := range webhooks {
+		h := &hook{config: cfg}
+		if cfg.Template != "" {
+			tmpl, err := template.New(cfg.Name).Funcs(templateFuncs).Option("missingkey=error").Parse(cfg.Template)
+			if err != nil {
+				return nil, fmt.Errorf("invalid template for webhook %s: %w", cfg.Name, err)
+			}
+			h.template = tmpl
+		}
+		d.hooks = append(d.hooks, h)
+		d.byName[cfg.Name] = h
+	}
+
+	return d, nil
+}
+
+// Dispatch delivers an event asynchronously to every webhook subscribed to its
+// type that the routing rules select, and to the event's email recipients
+func (d *Dispatcher) Dispatch(event Event) {
+	if d == nil {
+		return
+	}
+
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	_, hooks := d.targets(event)
+	for _, h := range hooks {
+		go func(h *hook) {
+			result := d.deliver(context.Background(), h, event)
+			if result.Error != "" {
+				log.Printf("Webhook %s delivery failed: %s", h.config.Name, result.Error)
+			}
+		}(h)
+	}
+	d.email.dispatch(event)
+}
+
+// Render returns the payload the named webhook would receive for an event
+func (d *Dispatcher) Render(name string, event Event) (string, error) {
+	h, ok := d.lookup(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+
+	payload, err := h.render(event)
+	if err != nil {
+		return "", err
+	}
+	return string(payload), nil
+}
+
+// Deliver synchronously sends an event to the named webhook, regardless of
+// its event subscriptions
+func (d *Dispatcher) Deliver(ctx context.Context, name string, event Event) (*DeliveryResult, error) {
+	h, ok := d.lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	return d.deliver(ctx, h, event), nil
+}
+
+// lookup finds a webhook by name
+func (d *Dispatcher) lookup(name string) (*hook, bool) {
+	if d == nil {
+		return nil, false
+	}
+	h, ok := d.byName[name]
+	return h, ok
+}
+
+// deliver renders the payload and posts it to the webhook URL
+func (d *Dispatcher) deliver(ctx context.Context, h *hook, event Event) *DeliveryResult {
+	result := &DeliveryResult{Webhook: h.config.Name}
+
+	payload, err := h.render(event)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Payload = string(payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.config.URL, bytes.NewReader(payload))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	contentType := h.config.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	req.Header.Set("Content-Type", contentType)
+	for key, value := range h.config.Headers {
+		req.Header.Set(key, value)
+	}
+	if h.config.Secret != "" {
+		req.Header.Set(signatureHeader, Sign(h.config.Secret, payload))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 300 {
+		result.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+
+	return result
+}
+
+// Sign returns the hex-encoded HMAC-SHA256 signature of a payload
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// subscribed reports whether the hook wants events of the given type
+func (h *hook) subscribed(eventType string) bool {
+	if len(h.config.Events) == 0 {
+		return true
+	}
+	for _, t := range h.config.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// render builds the payload for an event, using the hook's template when set
+// and the JSON-encoded event otherwise
+func (h *hook) render(event Event) ([]byte, error) {
+	if h.template == nil {
+		return json.Marshal(event)
+	}
+
+	var buf bytes.Buffer
+	if err := h.template.Execute(&buf, event); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
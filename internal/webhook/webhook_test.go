@@ -0,0 +1,167 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/config"
+)
+
+type testTask struct {
+	ID     string
+	Title  string
+	Status string
+}
+
+func TestDispatcher_RenderTemplate(t *testing.T) {
+	d, err := NewDispatcher([]config.WebhookConfig{{
+		Name:     "pager",
+		URL:      "http://example.com",
+		Template: `{"summary": {{json .Task.Title}}, "state": "{{upper .Task.Status}}", "event": "{{.Type}}"}`,
+	}})
+	require.NoError(t, err)
+
+	payload, err := d.Render("pager", Event{
+		Type: "task-update",
+		Task: testTask{ID: "abc", Title: `Fix "quoted" bug`, Status: "failed"},
+	})
+	require.NoError(t, err)
+
+	var decoded map[string]string
+	require.NoError(t, json.Unmarshal([]byte(payload), &decoded))
+	assert.Equal(t, `Fix "quoted" bug`, decoded["summary"])
+	assert.Equal(t, "FAILED", decoded["state"])
+	assert.Equal(t, "task-update", decoded["event"])
+}
+
+func TestDispatcher_RenderDefaultJSON(t *testing.T) {
+	d, err := NewDispatcher([]config.WebhookConfig{{Name: "plain", URL: "http://example.com"}})
+	require.NoError(t, err)
+
+	payload, err := d.Render("plain", Event{Type: "task-update", TaskID: "abc"})
+	require.NoError(t, err)
+
+	var decoded Event
+	require.NoError(t, json.Unmarshal([]byte(payload), &decoded))
+	assert.Equal(t, "task-update", decoded.Type)
+	assert.Equal(t, "abc", decoded.TaskID)
+}
+
+func TestDispatcher_RenderErrors(t *testing.T) {
+	d, err := NewDispatcher([]config.WebhookConfig{{
+		Name:     "bad",
+		URL:      "http://example.com",
+		Template: `{{.Task.Missing}}`,
+	}})
+	require.NoError(t, err)
+
+	_, err = d.Render("bad", Event{Task: testTask{}})
+	assert.Error(t, err)
+
+	_, err = d.Render("unknown", Event{})
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestNewDispatcher_InvalidTemplate(t *testing.T) {
+	_, err := NewDispatcher([]config.WebhookConfig{{
+		Name:     "broken",
+		URL:      "http://example.com",
+		Template: `{{.Type`,
+	}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "broken")
+}
+
+func TestDispatcher_Deliver(t *testing.T) {
+	var received []byte
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		headers = r.Header
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	d, err := NewDispatcher([]config.WebhookConfig{{
+		Name:        "custom",
+		URL:         server.URL,
+		Secret:      "s3cret",
+		Template:    `text={{.Task.Title}}`,
+		ContentType: "text/plain",
+		Headers:     map[string]string{"X-Custom": "yes"},
+	}})
+	require.NoError(t, err)
+
+	result, err := d.Deliver(context.Background(), "custom", Event{Type: "task-update", Task: testTask{Title: "hello"}})
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusAccepted, result.StatusCode)
+	assert.Empty(t, result.Error)
+	assert.Equal(t, "text=hello", string(received))
+	assert.Equal(t, "text/plain", headers.Get("Content-Type"))
+	assert.Equal(t, "yes", headers.Get("X-Custom"))
+	assert.Equal(t, Sign("s3cret", received), headers.Get(signatureHeader))
+}
+
+func TestDispatcher_DeliverErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	d, err := NewDispatcher([]config.WebhookConfig{{Name: "failing", URL: server.URL}})
+	require.NoError(t, err)
+
+	result, err := d.Deliver(context.Background(), "failing", Event{Type: "task-update"})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, result.StatusCode)
+	assert.Contains(t, result.Error, "500")
+}
+
+func TestDispatcher_DispatchFiltersEvents(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls[r.URL.Path]++
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	d, err := NewDispatcher([]config.WebhookConfig{
+		{Name: "all", URL: server.URL + "/all"},
+		{Name: "updates", URL: server.URL + "/updates", Events: []string{"task-update"}},
+		{Name: "other", URL: server.URL + "/other", Events: []string{"task-deleted"}},
+	})
+	require.NoError(t, err)
+
+	d.Dispatch(Event{Type: "task-update"})
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls["/all"] == 1 && calls["/updates"] == 1
+	}, time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Zero(t, calls["/other"])
+}
+
+func TestDispatcher_NilSafe(t *testing.T) {
+	var d *Dispatcher
+	d.Dispatch(Event{Type: "task-update"})
+
+	_, err := d.Deliver(context.Background(), "any", Event{})
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
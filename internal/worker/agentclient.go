@@ -0,0 +1,241 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// DefaultBackend is the agent backend of workers that don't choose one
+const DefaultBackend = "amp"
+
+// ErrUnknownBackend is returned when a worker uses an agent backend that isn't
+// configured
+var ErrUnknownBackend = errors.New("unknown agent backend")
+
+// ErrUnknownModel is returned when a worker asks for a model its agent backend
+// doesn't offer
+var ErrUnknownModel = errors.New("unknown model")
+
+// ErrBackendNotSupported is returned when a worker asks its agent backend for
+// something only amp can do, such as remote execution
+var ErrBackendNotSupported = errors.New("not supported by the agent backend")
+
+// AgentClient builds the command lines that drive a coding agent. Each
+// invocation runs in the worker's execution environment and reads the user's
+// message on stdin. amp is the built-in backend; other agents, such as aider or
+// a custom script, are orchestrated by implementing AgentClient and
+// registering it with SetAgentClients.
+type AgentClient interface {
+	// Binary returns the agent's executable
+	Binary() string
+	// NewThreadArgs returns the arguments of the invocation that creates a
+	// thread and prints its ID on stdout. Agents without threads return nil,
+	// and the daemon makes up an ID for each worker.
+	NewThreadArgs() []string
+	// ContinueArgs returns the arguments of the invocation that sends the
+	// message to a thread. logFile, when not empty, is where the agent writes
+	// the log its thread messages are parsed from.
+	ContinueArgs(threadID, logFile string) []string
+	// ModelArgs returns the arguments that select a model, passed before
+	// every invocation's own, and false when the agent doesn't offer it
+	ModelArgs(model string) ([]string, bool)
+	// ModelNames returns the names of the models the agent offers, sorted
+	ModelNames() []string
+}
+
+// AmpClient runs amp
+type AmpClient struct {
+	Path   string              // amp executable
+	Models map[string][]string // amp flags selecting each model, e.g. {"rush": ["--mode=rush"]}
+}
+
+// Binary returns the amp executable
+func (c AmpClient) Binary() string {
+	return c.Path
+}
+
+// NewThreadArgs runs "amp threads new"
+func (c AmpClient) NewThreadArgs() []string {
+	return []string{"threads", "new"}
+}
+
+// ContinueArgs runs "amp threads continue", logging at debug level to logFile
+func (c AmpClient) ContinueArgs(threadID, logFile string) []string {
+	if logFile == "" {
+		return []string{"threads", "continue", threadID}
+	}
+	return []string{"--log-file", logFile, "--log-level=debug", "threads", "continue", threadID}
+}
+
+// ModelArgs returns the flags configured for model
+func (c AmpClient) ModelArgs(model string) ([]string, bool) {
+	args, ok := c.Models[model]
+	return args, ok
+}
+
+// Models returns the names of the configured models
+func (c AmpClient) ModelNames() []string {
+	return sortedKeys(c.Models)
+}
+
+// CommandClient runs an agent through a configured command line. Its output
+// is kept in the worker's log, but isn't parsed into thread messages.
+type CommandClient struct {
+	Path      string              // Executable
+	NewThread []string            // Arguments that print a new thread's ID; empty for agents without threads
+	Continue  []string            // Arguments that send stdin to a thread; "{thread}" is replaced by its ID
+	Models    map[string][]string // Arguments selecting each model
+}
+
+// Binary returns the configured executable
+func (c CommandClient) Binary() string {
+	return c.Path
+}
+
+// NewThreadArgs returns the configured thread creation arguments
+func (c CommandClient) NewThreadArgs() []string {
+	return c.NewThread
+}
+
+// ContinueArgs returns the configured arguments with the thread ID filled in.
+// The agent's log isn't parsed, so logFile is ignored.
+func (c CommandClient) ContinueArgs(threadID, logFile string) []string {
+	args := make([]string, len(c.Continue))
+	for i, arg := range c.Continue {
+		args[i] = strings.ReplaceAll(arg, "{thread}", threadID)
+	}
+	return args
+}
+
+// ModelArgs returns the arguments configured for model
+func (c CommandClient) ModelArgs(model string) ([]string, bool) {
+	args, ok := c.Models[model]
+	return args, ok
+}
+
+// Models returns the names of the configured models
+func (c CommandClient) ModelNames() []string {
+	return sortedKeys(c.Models)
+}
+
+// sortedKeys returns the keys of models, sorted
+func sortedKeys(models map[string][]string) []string {
+	names := make([]string, 0, len(models))
+	for name := range models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BackendInfo describes an agent backend workers may select
+type BackendInfo struct {
+	Name   string   `json:"name"`
+	Models []string `json:"models"` // Models workers of the backend may select; empty when there's no choice
+}
+
+// SetAmpModels sets the models amp workers may select, with the amp flags
+// that select each one
+func (m *Manager) SetAmpModels(models map[string][]string) {
+	m.ampModels = models
+}
+
+// SetAgentClients sets the agent backends workers may select by name, in
+// addition to amp
+func (m *Manager) SetAgentClients(clients map[string]AgentClient) {
+	m.agentClients = clients
+}
+
+// Backends returns the agent backends workers may select, sorted by name
+func (m *Manager) Backends() []BackendInfo {
+	backends := []BackendInfo{{Name: DefaultBackend, Models: sortedKeys(m.ampModels)}}
+	for name, client := range m.agentClients {
+		if name != DefaultBackend {
+			backends = append(backends, BackendInfo{Name: name, Models: client.ModelNames()})
+		}
+	}
+	sort.Slice(backends, func(i, j int) bool { return backends[i].Name < backends[j].Name })
+	return backends
+}
+
+// isAmp reports whether backend names amp
+func isAmp(backend string) bool {
+	return backend == "" || backend == DefaultBackend
+}
+
+// agentClient returns the client of the named backend. amp runs the
+// worker's amp binary.
+func (m *Manager) agentClient(backend string, worker *Worker) (AgentClient, error) {
+	if isAmp(backend) {
+		return AmpClient{Path: m.ampBinary(worker), Models: m.ampModels}, nil
+	}
+	client, ok := m.agentClients[backend]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownBackend, backend)
+	}
+	return client, nil
+}
+
+// validateBackend checks that a new worker's agent backend exists and can
+// honour the rest of its options
+func (m *Manager) validateBackend(opts StartOptions, execution ExecutionMode) error {
+	client, err := m.agentClient(opts.Backend, &Worker{})
+	if err != nil {
+		return err
+	}
+	if _, err := modelArgs(client, opts.Model); err != nil {
+		return err
+	}
+	if isAmp(opts.Backend) {
+		return nil
+	}
+	if execution == ExecutionRemote {
+		return fmt.Errorf("%w: backend %s can't run on remote agents", ErrBackendNotSupported, opts.Backend)
+	}
+	if opts.AmpBinary != "" || len(opts.AmpArgs) > 0 {
+		return fmt.Errorf("%w: amp overrides only apply to the amp backend", ErrAmpOverrideNotAllowed)
+	}
+	return nil
+}
+
+// modelArgs returns the arguments that select model with client; the empty
+// model is the agent's default
+func modelArgs(client AgentClient, model string) ([]string, error) {
+	if model == "" {
+		return nil, nil
+	}
+	args, ok := client.ModelArgs(model)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownModel, model)
+	}
+	return args, nil
+}
+
+// invocationArgs returns the arguments of one of a worker's invocations: its
+// model's, then its own amp flags, then args. Models are looked up each time,
+// so removing one from the configuration fails the worker's next invocation.
+func (m *Manager) invocationArgs(worker *Worker, args []string) ([]string, error) {
+	client, err := m.agentClient(worker.Backend, worker)
+	if err != nil {
+		return nil, err
+	}
+	selected, err := modelArgs(client, worker.Model)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]string{}, selected...), ampArgs(worker, args)...), nil
+}
+
+// continueCommand builds the command that sends a message to the worker's
+// thread with its agent backend, writing the agent's log to logFile when set.
+// Start it with startAmp.
+func (m *Manager) continueCommand(worker *Worker, logFile string) (*exec.Cmd, error) {
+	client, err := m.agentClient(worker.Backend, worker)
+	if err != nil {
+		return nil, err
+	}
+	return m.ampCommand(worker, client.ContinueArgs(worker.ThreadID, logFile)...)
+}
@@ -0,0 +1,120 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingAgent appends its arguments and stdin to calls.log in dir
+const recordingAgent = `#!/bin/sh
+echo "$@" >> %[1]s/calls.log
+cat >> %[1]s/calls.log
+`
+
+func TestAmpClient_Args(t *testing.T) {
+	client := AmpClient{Path: "/usr/bin/amp"}
+	assert.Equal(t, "/usr/bin/amp", client.Binary())
+	assert.Equal(t, []string{"threads", "new"}, client.NewThreadArgs())
+	assert.Equal(t, []string{"threads", "continue", "T-1"}, client.ContinueArgs("T-1", ""))
+	assert.Equal(t, []string{"--log-file", "/logs/a.log", "--log-level=debug", "threads", "continue", "T-1"}, client.ContinueArgs("T-1", "/logs/a.log"))
+}
+
+func TestManager_CommandBackend(t *testing.T) {
+	tmpDir := t.TempDir()
+	script := filepath.Join(tmpDir, "agent")
+	require.NoError(t, os.WriteFile(script, []byte(strings.ReplaceAll(recordingAgent, "%[1]s", tmpDir)), 0755))
+
+	manager := NewManager(tmpDir)
+	manager.SetAmpBinary(filepath.Join(tmpDir, "missing-amp"))
+	manager.SetAgentClients(map[string]AgentClient{
+		"script": CommandClient{Path: script, Continue: []string{"--session", "{thread}"}},
+	})
+	assert.Equal(t, []BackendInfo{{Name: "amp", Models: []string{}}, {Name: "script", Models: []string{}}}, manager.Backends())
+
+	worker, err := manager.StartWorkerWithOptions(context.Background(), "fix the tests", StartOptions{Backend: "script"})
+	require.NoError(t, err)
+	assert.Equal(t, "script", worker.Backend)
+	assert.NotEmpty(t, worker.ThreadID, "agents without threads get a made-up ID")
+	assert.Empty(t, worker.AmpLogFile, "only amp's log is parsed")
+
+	require.Eventually(t, func() bool {
+		calls, _ := os.ReadFile(filepath.Join(tmpDir, "calls.log"))
+		return string(calls) == "--session "+worker.ThreadID+"\nfix the tests\n"
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		running, err := manager.IsRunning(worker.ID)
+		return err == nil && !running
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Retries go through the same backend
+	require.NoError(t, manager.RetryWorker(context.Background(), worker.ID, "try again"))
+	require.Eventually(t, func() bool {
+		calls, _ := os.ReadFile(filepath.Join(tmpDir, "calls.log"))
+		return strings.HasSuffix(string(calls), "--session "+worker.ThreadID+"\ntry again\n")
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestManager_BackendValidation(t *testing.T) {
+	manager := NewManager(t.TempDir())
+	manager.SetAgentClients(map[string]AgentClient{"script": CommandClient{Path: "/bin/true"}})
+
+	_, err := manager.StartWorkerWithOptions(context.Background(), "hi", StartOptions{Backend: "aider"})
+	assert.ErrorIs(t, err, ErrUnknownBackend)
+
+	_, err = manager.StartWorkerWithOptions(context.Background(), "hi", StartOptions{Backend: "script", Model: "gpt-4o"})
+	assert.ErrorIs(t, err, ErrUnknownModel)
+
+	_, err = manager.StartWorkerWithOptions(context.Background(), "hi", StartOptions{Backend: "script", AmpArgs: []string{"--mcp-config=x"}})
+	assert.ErrorIs(t, err, ErrAmpOverrideNotAllowed)
+
+	manager.SetAgentPool(newFakePool())
+	_, err = manager.StartWorkerWithOptions(context.Background(), "hi", StartOptions{Backend: "script", Execution: ExecutionRemote})
+	assert.ErrorIs(t, err, ErrBackendNotSupported)
+}
+
+func TestManager_Models(t *testing.T) {
+	tmpDir := t.TempDir()
+	script := filepath.Join(tmpDir, "agent")
+	require.NoError(t, os.WriteFile(script, []byte(strings.ReplaceAll(recordingAgent, "%[1]s", tmpDir)), 0755))
+
+	manager := NewManager(tmpDir)
+	manager.SetAmpModels(map[string][]string{"rush": {"--mode=rush"}})
+	manager.SetAgentClients(map[string]AgentClient{
+		"script": CommandClient{
+			Path:     script,
+			Continue: []string{"--session", "{thread}"},
+			Models:   map[string][]string{"fast": {"--model", "small"}, "slow": {"--model", "large"}},
+		},
+	})
+	assert.Equal(t, []BackendInfo{
+		{Name: "amp", Models: []string{"rush"}},
+		{Name: "script", Models: []string{"fast", "slow"}},
+	}, manager.Backends())
+
+	worker, err := manager.StartWorkerWithOptions(context.Background(), "fix the tests", StartOptions{Backend: "script", Model: "slow"})
+	require.NoError(t, err)
+	assert.Equal(t, "slow", worker.Model)
+
+	require.Eventually(t, func() bool {
+		calls, _ := os.ReadFile(filepath.Join(tmpDir, "calls.log"))
+		return string(calls) == "--model large --session "+worker.ThreadID+"\nfix the tests\n"
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		running, err := manager.IsRunning(worker.ID)
+		return err == nil && !running
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Retries keep the model
+	require.NoError(t, manager.RetryWorker(context.Background(), worker.ID, "try again"))
+	require.Eventually(t, func() bool {
+		calls, _ := os.ReadFile(filepath.Join(tmpDir, "calls.log"))
+		return strings.HasSuffix(string(calls), "--model large --session "+worker.ThreadID+"\ntry again\n")
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -65,6 +65,7 @@ type MessageState struct {
 type AmpLogParser struct {
 	workerID        string
 	onMessage       func(ThreadMessage)
+	format          LogFormat
 	latestThread    *Thread
 	lastThreadUpdate time.Time
 	conversationProcessed bool
@@ -75,9 +76,15 @@ func NewAmpLogParser(workerID string, onMessage func(ThreadMessage)) *AmpLogPars
 	return &AmpLogParser{
 		workerID:  workerID,
 		onMessage: onMessage,
+		format:    DefaultLogFormat(),
 	}
 }
 
+// SetLogFormat sets the format of the log being parsed
+func (p *AmpLogParser) SetLogFormat(format LogFormat) {
+	p.format = format
+}
+
 // ParseLine processes a single line from amp's JSON log file
 func (p *AmpLogParser) ParseLine(line string) {
 	line = strings.TrimSpace(line)
@@ -91,8 +98,8 @@ func (p *AmpLogParser) ParseLine(line string) {
 		return
 	}
 	
-	// Only process thread-state events which contain the conversation
-	if logEntry.Event != nil && logEntry.Event.Type == "thread-state" && logEntry.Event.Thread != nil {
+	// Only process the events which contain the whole conversation
+	if logEntry.Event != nil && logEntry.Event.Type == p.format.ThreadEvent && logEntry.Event.Thread != nil {
 		p.updateThreadState(logEntry.Event.Thread, logEntry.Timestamp)
 	}
 }
@@ -277,6 +284,11 @@ func NewLogTailerWithParser(logFile, workerID string, onLogLine func(LogLine), o
 	}
 }
 
+// SetLogFormat sets the format of the amp log being parsed
+func (lt *LogTailerWithParser) SetLogFormat(format LogFormat) {
+	lt.parser.SetLogFormat(format)
+}
+
 // ProcessFinalConversation exposes the parser's ProcessFinalConversation method
 func (lt *LogTailerWithParser) ProcessFinalConversation() {
 	if lt.parser != nil {
@@ -0,0 +1,67 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrAmpOverrideNotAllowed is returned when a task asks for an amp binary or
+// flag that isn't allow-listed
+var ErrAmpOverrideNotAllowed = errors.New("amp override not allowed")
+
+// AmpOverrides lists the amp binaries and flags individual tasks may use
+// instead of, or in addition to, the daemon's
+type AmpOverrides struct {
+	Binaries []string // Paths of amp executables tasks may choose
+	Flags    []string // Flags tasks may add, e.g. "--mcp-config"
+}
+
+// SetAmpOverrides sets the amp binaries and flags tasks may ask for
+func (m *Manager) SetAmpOverrides(overrides AmpOverrides) {
+	m.ampOverrides = overrides
+}
+
+// validate checks a task's amp binary and arguments against the allow-lists.
+// Arguments must be allowed flags, with any value attached as --flag=value.
+func (o AmpOverrides) validate(binary string, args []string, execution ExecutionMode) error {
+	if binary != "" {
+		if execution != ExecutionHost {
+			return fmt.Errorf("%w: amp binaries can only be chosen for host execution", ErrAmpOverrideNotAllowed)
+		}
+		if !contains(o.Binaries, binary) {
+			return fmt.Errorf("%w: amp binary %q is not allowed", ErrAmpOverrideNotAllowed, binary)
+		}
+	}
+
+	for _, arg := range args {
+		flag, _, _ := strings.Cut(arg, "=")
+		if !strings.HasPrefix(flag, "-") || !contains(o.Flags, flag) {
+			return fmt.Errorf("%w: amp argument %q is not allowed", ErrAmpOverrideNotAllowed, arg)
+		}
+	}
+	return nil
+}
+
+// ampBinary returns the amp executable a worker runs on the host
+func (m *Manager) ampBinary(worker *Worker) string {
+	if worker.AmpBinary != "" {
+		return worker.AmpBinary
+	}
+	return m.ampBinaryPath
+}
+
+// ampArgs returns a worker's own amp arguments followed by args
+func ampArgs(worker *Worker, args []string) []string {
+	return append(append([]string{}, worker.AmpArgs...), args...)
+}
+
+// contains reports whether values includes value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ampVersionTimeout bounds how long amp --version may take
+const ampVersionTimeout = 10 * time.Second
+
+// AmpVersion is the version of an amp executable, as reported by amp --version
+type AmpVersion struct {
+	Raw   string // First line of amp --version's output
+	Parts []int  // Numeric components, e.g. [0 0 1752595486]; empty when unrecognized
+}
+
+// String returns the version as amp reported it
+func (v AmpVersion) String() string {
+	return v.Raw
+}
+
+// Compare returns -1, 0 or 1 as v is older than, the same as or newer than
+// other, comparing numeric components and treating missing ones as zero
+func (v AmpVersion) Compare(other AmpVersion) int {
+	for i := 0; i < len(v.Parts) || i < len(other.Parts); i++ {
+		var a, b int
+		if i < len(v.Parts) {
+			a = v.Parts[i]
+		}
+		if i < len(other.Parts) {
+			b = other.Parts[i]
+		}
+		if a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// ampVersionPattern matches the first dotted version number in amp --version's
+// output, e.g. 0.0.1752595486 in "0.0.1752595486-g6e2c95 (released ...)"
+var ampVersionPattern = regexp.MustCompile(`v?(\d+(?:\.\d+)*)`)
+
+// ParseAmpVersion parses the output of amp --version. Output without a version
+// number is kept as Raw with no Parts, so it's still reported.
+func ParseAmpVersion(output string) AmpVersion {
+	line, _, _ := strings.Cut(strings.TrimSpace(output), "\n")
+	version := AmpVersion{Raw: strings.TrimSpace(line)}
+
+	match := ampVersionPattern.FindStringSubmatch(version.Raw)
+	if match == nil {
+		return version
+	}
+	for _, part := range strings.Split(match[1], ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return AmpVersion{Raw: version.Raw}
+		}
+		version.Parts = append(version.Parts, n)
+	}
+	return version
+}
+
+// LogFormat describes how a range of amp releases write their JSON log, so the
+// parser doesn't assume one output format
+type LogFormat struct {
+	Name        string
+	MinVersion  AmpVersion // Oldest release writing this format; zero matches any
+	ThreadEvent string     // Type of the events carrying the whole thread
+}
+
+// logFormats lists the known log formats, newest first
+var logFormats = []LogFormat{
+	{Name: "thread-state", ThreadEvent: "thread-state"},
+}
+
+// DefaultLogFormat is the newest known log format, used when amp's version is
+// unknown
+func DefaultLogFormat() LogFormat {
+	return logFormats[0]
+}
+
+// LogFormatFor returns the log format written by the given amp version.
+// Versions that couldn't be parsed get the newest format.
+func LogFormatFor(version AmpVersion) LogFormat {
+	if len(version.Parts) == 0 {
+		return DefaultLogFormat()
+	}
+	for _, format := range logFormats {
+		if version.Compare(format.MinVersion) >= 0 {
+			return format
+		}
+	}
+	return logFormats[len(logFormats)-1]
+}
+
+// DetectAmpVersion runs the amp executable with --version, records its version
+// and returns it. Workers started afterwards have their logs parsed in the
+// format that version writes.
+func (m *Manager) DetectAmpVersion() (AmpVersion, error) {
+	version, err := m.detectAmpVersion(m.ampBinaryPath)
+	if err != nil {
+		return AmpVersion{}, err
+	}
+	if len(version.Parts) == 0 {
+		log.Printf("Unrecognized amp version %q; assuming the %s log format", version.Raw, DefaultLogFormat().Name)
+	}
+	return version, nil
+}
+
+// AmpVersion returns the version of the amp executable, and false when it
+// hasn't been detected
+func (m *Manager) AmpVersion() (AmpVersion, bool) {
+	value, ok := m.ampVersions.Load(m.ampBinaryPath)
+	if !ok {
+		return AmpVersion{}, false
+	}
+	return value.(AmpVersion), true
+}
+
+// detectAmpVersion runs binary with --version and caches its version
+func (m *Manager) detectAmpVersion(binary string) (AmpVersion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ampVersionTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, binary, "--version").Output()
+	if err != nil {
+		return AmpVersion{}, fmt.Errorf("failed to run %s --version: %w", binary, err)
+	}
+	version := ParseAmpVersion(string(output))
+	m.ampVersions.Store(binary, version)
+	return version, nil
+}
+
+// logFormat returns the log format a worker's amp writes. Workers choosing
+// their own amp binary have its version detected the first time; others use
+// the daemon's amp, or the newest format when its version is unknown.
+func (m *Manager) logFormat(worker *Worker) LogFormat {
+	binary := m.ampBinary(worker)
+	if value, ok := m.ampVersions.Load(binary); ok {
+		return LogFormatFor(value.(AmpVersion))
+	}
+	if binary != m.ampBinaryPath {
+		if version, err := m.detectAmpVersion(binary); err == nil {
+			return LogFormatFor(version)
+		}
+	}
+	return DefaultLogFormat()
+}
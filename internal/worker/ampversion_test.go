@@ -0,0 +1,103 @@
+package worker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAmpVersion(t *testing.T) {
+	tests := []struct {
+		output string
+		raw    string
+		parts  []int
+	}{
+		{"0.0.1752595486-g6e2c95\n", "0.0.1752595486-g6e2c95", []int{0, 0, 1752595486}},
+		{"amp v1.2.3 (released 2025-07-15)\nextra\n", "amp v1.2.3 (released 2025-07-15)", []int{1, 2, 3}},
+		{"development build", "development build", nil},
+		{"", "", nil},
+	}
+
+	for _, tt := range tests {
+		version := ParseAmpVersion(tt.output)
+		assert.Equal(t, tt.raw, version.Raw, tt.output)
+		assert.Equal(t, tt.parts, version.Parts, tt.output)
+	}
+}
+
+func TestAmpVersionCompare(t *testing.T) {
+	v := func(parts ...int) AmpVersion { return AmpVersion{Parts: parts} }
+
+	assert.Equal(t, 0, v(1, 2).Compare(v(1, 2, 0)))
+	assert.Equal(t, -1, v(1, 2).Compare(v(1, 10)))
+	assert.Equal(t, 1, v(2).Compare(v(1, 99)))
+	assert.Equal(t, 1, v(0, 0, 1).Compare(AmpVersion{}))
+}
+
+func TestLogFormatFor(t *testing.T) {
+	defer func(formats []LogFormat) { logFormats = formats }(logFormats)
+	logFormats = []LogFormat{
+		{Name: "new", MinVersion: AmpVersion{Parts: []int{2}}, ThreadEvent: "thread"},
+		{Name: "old", ThreadEvent: "thread-state"},
+	}
+
+	assert.Equal(t, "new", LogFormatFor(ParseAmpVersion("2.0.1")).Name)
+	assert.Equal(t, "old", LogFormatFor(ParseAmpVersion("1.9.9")).Name)
+	assert.Equal(t, "new", LogFormatFor(ParseAmpVersion("unknown")).Name)
+}
+
+func TestManager_DetectAmpVersion(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := NewManager(tmpDir)
+
+	_, ok := manager.AmpVersion()
+	assert.False(t, ok)
+
+	manager.SetAmpBinary(filepath.Join(tmpDir, "missing-amp"))
+	_, err := manager.DetectAmpVersion()
+	assert.Error(t, err)
+	assert.Equal(t, DefaultLogFormat(), manager.logFormat(&Worker{}))
+
+	scriptPath := filepath.Join(tmpDir, "amp")
+	require.NoError(t, os.WriteFile(scriptPath, []byte("#!/bin/bash\necho \"0.0.1752595486-g6e2c95\"\n"), 0755))
+	manager.SetAmpBinary(scriptPath)
+
+	version, err := manager.DetectAmpVersion()
+	require.NoError(t, err)
+	assert.Equal(t, "0.0.1752595486-g6e2c95", version.String())
+
+	detected, ok := manager.AmpVersion()
+	assert.True(t, ok)
+	assert.Equal(t, version, detected)
+
+	// A worker's own amp binary is detected when its format is first needed
+	otherPath := filepath.Join(tmpDir, "other-amp")
+	require.NoError(t, os.WriteFile(otherPath, []byte("#!/bin/bash\necho \"1.0.0\"\n"), 0755))
+	assert.Equal(t, DefaultLogFormat(), manager.logFormat(&Worker{AmpBinary: otherPath}))
+	_, ok = manager.ampVersions.Load(otherPath)
+	assert.True(t, ok)
+}
+
+func TestAmpLogParser_LogFormat(t *testing.T) {
+	line := `{"level":"info","message":"state","timestamp":"2025-07-15T12:00:00Z","event":{"type":"thread","thread":{"id":"T-1","messages":[{"role":"assistant","content":[{"type":"text","text":"Done"}]}]}}}`
+
+	var messages []ThreadMessage
+	parser := NewAmpLogParser("w1", func(message ThreadMessage) {
+		messages = append(messages, message)
+	})
+	parser.ParseLine(line)
+	parser.ProcessFinalConversation()
+	assert.Empty(t, messages)
+
+	parser = NewAmpLogParser("w1", func(message ThreadMessage) {
+		messages = append(messages, message)
+	})
+	parser.SetLogFormat(LogFormat{Name: "test", ThreadEvent: "thread"})
+	parser.ParseLine(line)
+	parser.ProcessFinalConversation()
+	require.Len(t, messages, 1)
+	assert.Equal(t, "Done", messages[0].Content)
+}
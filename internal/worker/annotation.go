@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"fmt"
+	"time"
+)
+
+// MessageTypeAnnotation marks thread messages recording an annotation, so
+// external results appear in the task's timeline
+const MessageTypeAnnotation MessageType = "annotation"
+
+// AnnotationStatus is the outcome an external system reports for a task
+type AnnotationStatus string
+
+const (
+	AnnotationPending AnnotationStatus = "pending"
+	AnnotationSuccess AnnotationStatus = "success"
+	AnnotationFailure AnnotationStatus = "failure"
+	AnnotationNeutral AnnotationStatus = "neutral"
+)
+
+// Valid reports whether the status is one of the known annotation statuses
+func (s AnnotationStatus) Valid() bool {
+	switch s {
+	case AnnotationPending, AnnotationSuccess, AnnotationFailure, AnnotationNeutral:
+		return true
+	}
+	return false
+}
+
+// Annotation is a structured status attached to a task by an external system,
+// e.g. a CI run, deployment or security scan. A task keeps one annotation per
+// key; annotating again with the same key replaces it.
+type Annotation struct {
+	Key       string                 `json:"key"` // e.g. "ci", "deploy/staging"
+	Status    AnnotationStatus       `json:"status"`
+	Summary   string                 `json:"summary,omitempty"`
+	URL       string                 `json:"url,omitempty"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+// Annotate attaches an annotation to a worker, replacing any with the same
+// key, and records it in the worker's thread
+func (m *Manager) Annotate(workerID string, annotation Annotation) (*Annotation, error) {
+	if annotation.Key == "" {
+		return nil, fmt.Errorf("cannot annotate worker %s: key is required", workerID)
+	}
+	if !annotation.Status.Valid() {
+		return nil, fmt.Errorf("cannot annotate worker %s: invalid status %q", workerID, annotation.Status)
+	}
+
+	annotation.UpdatedAt = time.Now()
+	_, err := m.updateWorker(workerID, func(worker *Worker) error {
+		for i, existing := range worker.Annotations {
+			if existing.Key == annotation.Key {
+				worker.Annotations[i] = annotation
+				return nil
+			}
+		}
+		worker.Annotations = append(worker.Annotations, annotation)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	content := fmt.Sprintf("%s: %s", annotation.Key, annotation.Status)
+	if annotation.Summary != "" {
+		content += " - " + annotation.Summary
+	}
+	metadata := map[string]interface{}{
+		"key":    annotation.Key,
+		"status": string(annotation.Status),
+	}
+	if annotation.URL != "" {
+		metadata["url"] = annotation.URL
+	}
+	if err := m.AppendThreadMessage(workerID, MessageTypeAnnotation, content, metadata); err != nil {
+		return nil, err
+	}
+
+	return &annotation, nil
+}
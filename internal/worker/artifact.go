@@ -0,0 +1,178 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ArtifactsEnv is the environment variable giving amp and cleanup commands
+// the directory to place a task's artifacts in
+const ArtifactsEnv = "AMP_ARTIFACTS_DIR"
+
+// ErrArtifactNotFound is returned when a task has no artifact with the requested name
+var ErrArtifactNotFound = errors.New("artifact not found")
+
+// Artifact is an output file a task left in its artifacts directory, e.g. a
+// built binary or a test report
+type Artifact struct {
+	Name        string    `json:"name"` // Slash-separated path within the artifacts directory
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	Modified    time.Time `json:"modified"`
+}
+
+// artifactsDir returns the absolute directory holding a worker's artifacts
+func (m *Manager) artifactsDir(worker *Worker) string {
+	dir := filepath.Join(m.projectDir(worker.Project), "artifacts", worker.ID)
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return dir
+}
+
+// ArtifactsDir returns the directory a worker's artifacts are placed in. It
+// may not exist until the worker's amp or cleanup commands run.
+func (m *Manager) ArtifactsDir(workerID string) (string, error) {
+	worker, err := m.findWorker(workerID)
+	if err != nil {
+		return "", err
+	}
+	return m.artifactsDir(worker), nil
+}
+
+// artifactsEnv creates a worker's artifacts directory and returns the
+// variable naming it, or nothing if it can't be created
+func (m *Manager) artifactsEnv(worker *Worker) []string {
+	dir := m.artifactsDir(worker)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil
+	}
+	return []string{ArtifactsEnv + "=" + dir}
+}
+
+// ListArtifacts returns the artifacts of a worker sorted by name
+func (m *Manager) ListArtifacts(workerID string) ([]Artifact, error) {
+	worker, err := m.findWorker(workerID)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := m.artifactsDir(worker)
+	artifacts := []Artifact{}
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		// Symlinks could point outside the directory, so only regular files count
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		artifacts = append(artifacts, Artifact{
+			Name:        filepath.ToSlash(name),
+			Size:        info.Size(),
+			ContentType: detectContentType(path),
+			Modified:    info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list artifacts: %w", err)
+	}
+
+	sort.Slice(artifacts, func(i, j int) bool { return artifacts[i].Name < artifacts[j].Name })
+	return artifacts, nil
+}
+
+// OpenArtifact opens one of a worker's artifacts by its slash-separated name.
+// The caller must close the file.
+func (m *Manager) OpenArtifact(workerID, name string) (*os.File, *Artifact, error) {
+	worker, err := m.findWorker(workerID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	local := filepath.FromSlash(name)
+	if !filepath.IsLocal(local) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrArtifactNotFound, name)
+	}
+	dir := m.artifactsDir(worker)
+	path := filepath.Join(dir, local)
+
+	// Refuse symlinks anywhere in the path, which could lead outside the directory
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, nil, fmt.Errorf("%w: %s", ErrArtifactNotFound, name)
+	}
+	resolvedDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrArtifactNotFound, name)
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err != nil || resolved != filepath.Join(resolvedDir, local) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrArtifactNotFound, name)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return file, &Artifact{
+		Name:        filepath.ToSlash(local),
+		Size:        info.Size(),
+		ContentType: detectContentType(path),
+		Modified:    info.ModTime(),
+	}, nil
+}
+
+// detectContentType guesses a file's content type from its extension, then
+// from its first bytes
+func detectContentType(path string) string {
+	if contentType := mime.TypeByExtension(filepath.Ext(path)); contentType != "" {
+		return contentType
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "application/octet-stream"
+	}
+	return http.DetectContentType(head[:n])
+}
+
+// findWorker returns a saved worker by ID
+func (m *Manager) findWorker(workerID string) (*Worker, error) {
+	workers, err := m.loadWorkers()
+	if err != nil {
+		return nil, err
+	}
+	worker, exists := workers[workerID]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrWorkerNotFound, workerID)
+	}
+	return worker, nil
+}
@@ -0,0 +1,83 @@
+package worker
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupArtifactWorker saves a worker whose artifacts directory holds a
+// report, a binary in a subdirectory and a symlink
+func setupArtifactWorker(t *testing.T) (*Manager, string) {
+	tmpDir := t.TempDir()
+	manager := NewManager(tmpDir)
+	workers := map[string]*Worker{"done": {ID: "done", Started: time.Now(), Status: StatusStopped}}
+	require.NoError(t, manager.SaveWorkersForTest(workers, filepath.Join(tmpDir, "workers.json")))
+
+	dir, err := manager.ArtifactsDir("done")
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "bin"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "report.html"), []byte("<html></html>"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bin", "app"), []byte("\x7fELF\x02\x01\x01\x00"), 0755))
+	require.NoError(t, os.Symlink("/etc/passwd", filepath.Join(dir, "passwd")))
+	require.NoError(t, os.Symlink("/etc", filepath.Join(dir, "etc")))
+	return manager, dir
+}
+
+func TestListArtifacts(t *testing.T) {
+	manager, _ := setupArtifactWorker(t)
+
+	artifacts, err := manager.ListArtifacts("done")
+	require.NoError(t, err)
+	require.Len(t, artifacts, 2)
+	assert.Equal(t, "bin/app", artifacts[0].Name)
+	assert.Equal(t, "application/octet-stream", artifacts[0].ContentType)
+	assert.Equal(t, int64(8), artifacts[0].Size)
+	assert.Equal(t, "report.html", artifacts[1].Name)
+	assert.Equal(t, "text/html; charset=utf-8", artifacts[1].ContentType)
+
+	_, err = manager.ListArtifacts("missing")
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestListArtifacts_NoDirectory(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := NewManager(tmpDir)
+	workers := map[string]*Worker{"new": {ID: "new", Started: time.Now(), Status: StatusRunning}}
+	require.NoError(t, manager.SaveWorkersForTest(workers, filepath.Join(tmpDir, "workers.json")))
+
+	artifacts, err := manager.ListArtifacts("new")
+	require.NoError(t, err)
+	assert.Empty(t, artifacts)
+}
+
+func TestOpenArtifact(t *testing.T) {
+	manager, _ := setupArtifactWorker(t)
+
+	file, artifact, err := manager.OpenArtifact("done", "report.html")
+	require.NoError(t, err)
+	content, err := io.ReadAll(file)
+	file.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "<html></html>", string(content))
+	assert.Equal(t, "report.html", artifact.Name)
+
+	// Paths escaping the directory, symlinks and directories aren't served
+	for _, name := range []string{"../workers.json", "/etc/passwd", "passwd", "etc/passwd", "bin", "missing.txt"} {
+		_, _, err := manager.OpenArtifact("done", name)
+		assert.ErrorIs(t, err, ErrArtifactNotFound, name)
+	}
+}
+
+func TestDeleteWorker_RemovesArtifacts(t *testing.T) {
+	manager, dir := setupArtifactWorker(t)
+
+	require.NoError(t, manager.DeleteWorker("done", false))
+	_, err := os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+}
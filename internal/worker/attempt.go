@@ -0,0 +1,94 @@
+package worker
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// AttemptAction is what started one of a worker's amp invocations
+type AttemptAction string
+
+const (
+	AttemptStart      AttemptAction = "start"      // The worker was created
+	AttemptContinue   AttemptAction = "continue"   // A message was sent to the running worker
+	AttemptRetry      AttemptAction = "retry"      // The finished worker was retried
+	AttemptTransition AttemptAction = "transition" // The worker was transitioned back to running
+	AttemptRestart    AttemptAction = "restart"    // The restart policy ran amp again after it exited
+)
+
+// Attempt is one amp invocation of a worker
+type Attempt struct {
+	Number   int           `json:"number"` // Counting from 1
+	Action   AttemptAction `json:"action"`
+	Started  time.Time     `json:"started"`
+	Finished *time.Time    `json:"finished,omitempty"`  // nil while amp runs
+	ExitCode *int          `json:"exit_code,omitempty"` // amp's exit code; nil while it runs or when its exit wasn't seen, e.g. while the daemon was down
+}
+
+// beginAttempt records that an invocation started by action begins now and
+// returns its number
+func (w *Worker) beginAttempt(action AttemptAction) int {
+	number := len(w.Attempts) + 1
+	w.Attempts = append(w.Attempts, Attempt{Number: number, Action: action, Started: time.Now()})
+	return number
+}
+
+// restartAttempt records that a queued invocation begins now
+func (w *Worker) restartAttempt(number int) {
+	for i := range w.Attempts {
+		if w.Attempts[i].Number == number {
+			w.Attempts[i].Started = time.Now()
+		}
+	}
+}
+
+// endAttempt records that an invocation exited with code, or with an unknown
+// code when code is nil. number 0 ends the latest invocation of the worker's
+// process, skipping the continues that run beside it. It returns the ended
+// attempt, or nil when none was running.
+func (w *Worker) endAttempt(number int, code *int) *Attempt {
+	for i := len(w.Attempts) - 1; i >= 0; i-- {
+		attempt := &w.Attempts[i]
+		if attempt.Finished != nil || number != 0 && attempt.Number != number || number == 0 && attempt.Action == AttemptContinue {
+			continue
+		}
+		now := time.Now()
+		attempt.Finished = &now
+		attempt.ExitCode = code
+		return attempt
+	}
+	return nil
+}
+
+// endContinueAttempt records the exit of a continue invocation and runs the
+// continue callback. The worker is reloaded, since its process may have
+// changed its state meanwhile.
+func (m *Manager) endContinueAttempt(workerID string, number, code int) {
+	var attempt *Attempt
+	_, err := m.updateWorker(workerID, func(worker *Worker) error {
+		attempt = worker.endAttempt(number, &code)
+		return nil
+	})
+	if errors.Is(err, ErrWorkerNotFound) {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to record attempt of %s: %v", workerID, err)
+	}
+	if attempt != nil && m.onContinue != nil {
+		m.onContinue(workerID, *attempt)
+	}
+}
+
+// Attempts returns the amp invocations of a worker, oldest first
+func (m *Manager) Attempts(workerID string) ([]Attempt, error) {
+	worker, err := m.findWorker(workerID)
+	if err != nil {
+		return nil, err
+	}
+	if worker.Attempts == nil {
+		return []Attempt{}, nil
+	}
+	return worker.Attempts, nil
+}
@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorker_EndAttempt(t *testing.T) {
+	worker := &Worker{}
+	worker.beginAttempt(AttemptStart)
+	worker.beginAttempt(AttemptContinue)
+
+	// The process's exit skips the continue running beside it
+	code := 2
+	worker.endAttempt(0, &code)
+	require.NotNil(t, worker.Attempts[0].Finished)
+	assert.Equal(t, 2, *worker.Attempts[0].ExitCode)
+	assert.Nil(t, worker.Attempts[1].Finished)
+
+	worker.endAttempt(2, nil)
+	require.NotNil(t, worker.Attempts[1].Finished)
+	assert.Nil(t, worker.Attempts[1].ExitCode)
+}
+
+func TestManager_Attempts(t *testing.T) {
+	manager, _ := setupRestartManager(t, "3")
+
+	worker, err := manager.StartWorkerWithOptions(context.Background(), "fix the build", StartOptions{})
+	require.NoError(t, err)
+	assert.ErrorContains(t, manager.ContinueWorker(context.Background(), worker.ID, "and the tests"), "exit status 3")
+	waitStopped(t, manager, worker.ID)
+
+	require.NoError(t, manager.RetryWorker(context.Background(), worker.ID, "try again"))
+	waitStopped(t, manager, worker.ID)
+
+	attempts, err := manager.Attempts(worker.ID)
+	require.NoError(t, err)
+	require.Len(t, attempts, 3)
+	for i, action := range []AttemptAction{AttemptStart, AttemptContinue, AttemptRetry} {
+		assert.Equal(t, i+1, attempts[i].Number)
+		assert.Equal(t, action, attempts[i].Action)
+		require.NotNil(t, attempts[i].Finished)
+		require.NotNil(t, attempts[i].ExitCode)
+		assert.Equal(t, 3, *attempts[i].ExitCode)
+	}
+
+	_, err = manager.Attempts("missing")
+	assert.ErrorContains(t, err, "not found")
+}
+
+// waitStopped waits for a worker's process to exit
+func waitStopped(t *testing.T, manager *Manager, workerID string) {
+	require.Eventually(t, func() bool {
+		return findTestWorker(t, manager, workerID).Status != StatusRunning
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,136 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/git"
+)
+
+// DefaultCommitMessage is the auto-commit message template used when none is configured
+const DefaultCommitMessage = "{{if .Title}}{{.Title}}{{else}}amp task {{.ID}}{{end}}\n\nAmp-Thread: {{.ThreadID}}"
+
+// AutoCommitAnnotation is the annotation key recording the outcome of
+// committing a worker's changes
+const AutoCommitAnnotation = "auto-commit"
+
+// autoCommitTimeout bounds committing a worker's changes
+const autoCommitTimeout = time.Minute
+
+// CommitMessageData is what commit message templates are executed with
+type CommitMessageData struct {
+	ID          string
+	ThreadID    string
+	Title       string
+	Description string
+	Project     string
+}
+
+// SetCommitMessage sets the template of the messages auto-commits are made
+// with. An empty template uses DefaultCommitMessage.
+func (m *Manager) SetCommitMessage(text string) error {
+	if text == "" {
+		text = DefaultCommitMessage
+	}
+	tmpl, err := template.New("commit_message").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return fmt.Errorf("invalid commit message template: %w", err)
+	}
+	m.commitMessage = tmpl
+	return nil
+}
+
+// runAutoCommit commits everything in the workspace of a worker started with
+// auto-commit, appending the outcome to its log and recording it as an
+// "auto-commit" annotation
+func (m *Manager) runAutoCommit(workerID string) {
+	workers, err := m.loadWorkers()
+	if err != nil {
+		log.Printf("Failed to load workers for auto-commit: %v", err)
+		return
+	}
+	worker, exists := workers[workerID]
+	if !exists || !worker.AutoCommit {
+		return
+	}
+
+	annotation := m.autoCommit(worker)
+	if logFile, err := os.OpenFile(worker.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err == nil {
+		fmt.Fprintf(logFile, "[auto-commit] %s\n", annotation.Summary)
+		logFile.Close()
+	}
+	if _, err := m.Annotate(workerID, annotation); err != nil {
+		log.Printf("Failed to record auto-commit of worker %s: %v", workerID, err)
+	}
+}
+
+// autoCommit commits a worker's changes and returns the annotation recording the outcome
+func (m *Manager) autoCommit(worker *Worker) Annotation {
+	annotation := Annotation{Key: AutoCommitAnnotation, Status: AnnotationFailure}
+
+	// Remote workers change the agent's checkout, which the daemon can't reach
+	if worker.Execution == ExecutionRemote {
+		annotation.Status = AnnotationNeutral
+		annotation.Summary = "Not committed: remote tasks are committed on their agent"
+		return annotation
+	}
+
+	message, err := m.renderCommitMessage(worker)
+	if err != nil {
+		annotation.Summary = err.Error()
+		return annotation
+	}
+
+	workspace, err := m.Workspace(worker.ID)
+	if err != nil {
+		annotation.Summary = err.Error()
+		return annotation
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), autoCommitTimeout)
+	defer cancel()
+
+	hash, err := git.Repo{Dir: workspace.Dir}.CommitAll(ctx, message)
+	switch {
+	case errors.Is(err, git.ErrNothingToCommit):
+		annotation.Status = AnnotationNeutral
+		annotation.Summary = "No changes to commit"
+	case err != nil:
+		annotation.Summary = fmt.Sprintf("Failed to commit changes: %v", err)
+	default:
+		annotation.Status = AnnotationSuccess
+		annotation.Summary = fmt.Sprintf("Committed %s", hash)
+		annotation.Data = map[string]interface{}{"commit": hash}
+	}
+	return annotation
+}
+
+// renderCommitMessage executes the commit message template for a worker
+func (m *Manager) renderCommitMessage(worker *Worker) (string, error) {
+	tmpl := m.commitMessage
+	if tmpl == nil {
+		tmpl = template.Must(template.New("commit_message").Parse(DefaultCommitMessage))
+	}
+
+	var message strings.Builder
+	err := tmpl.Execute(&message, CommitMessageData{
+		ID:          worker.ID,
+		ThreadID:    worker.ThreadID,
+		Title:       worker.Title,
+		Description: worker.Description,
+		Project:     worker.ProjectName(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render commit message: %w", err)
+	}
+	if strings.TrimSpace(message.String()) == "" {
+		return "", errors.New("commit message template rendered an empty message")
+	}
+	return message.String(), nil
+}
@@ -0,0 +1,93 @@
+package worker
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupAutoCommitWorker saves a stopped worker started with auto-commit whose
+// workspace is a repository with an uncommitted file
+func setupAutoCommitWorker(t *testing.T) (*Manager, string) {
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	repo := t.TempDir()
+	for _, args := range [][]string{{"init", "-q", "-b", "main"}, {"commit", "-q", "--allow-empty", "-m", "initial"}} {
+		out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "main.go"), []byte("package main\n"), 0644))
+
+	tmpDir := t.TempDir()
+	manager := NewManager(tmpDir)
+	manager.SetWorkspace(Workspace{Dir: repo, BaseBranch: "main"})
+	workers := map[string]*Worker{
+		"done": {
+			ID: "done", ThreadID: "T-1", Title: "Add main", Started: time.Now(), Status: StatusStopped,
+			LogFile: filepath.Join(tmpDir, "done.log"), AutoCommit: true,
+		},
+	}
+	require.NoError(t, manager.SaveWorkersForTest(workers, filepath.Join(tmpDir, "workers.json")))
+	return manager, repo
+}
+
+func TestRunAutoCommit_CommitsWorkspace(t *testing.T) {
+	manager, repo := setupAutoCommitWorker(t)
+	require.NoError(t, manager.SetCommitMessage("{{.Title}} ({{.ThreadID}})"))
+
+	manager.runAutoCommit("done")
+
+	out, err := exec.Command("git", "-C", repo, "log", "-1", "--format=%s").Output()
+	require.NoError(t, err)
+	assert.Equal(t, "Add main (T-1)\n", string(out))
+
+	annotation := findTestWorker(t, manager, "done").Annotations[0]
+	assert.Equal(t, AutoCommitAnnotation, annotation.Key)
+	assert.Equal(t, AnnotationSuccess, annotation.Status)
+	assert.Len(t, annotation.Data["commit"], 40)
+
+	content, err := os.ReadFile(findTestWorker(t, manager, "done").LogFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "[auto-commit] Committed ")
+
+	// Nothing left to commit the second time
+	manager.runAutoCommit("done")
+	annotation = findTestWorker(t, manager, "done").Annotations[0]
+	assert.Equal(t, AnnotationNeutral, annotation.Status)
+}
+
+func TestRunAutoCommit_DefaultMessage(t *testing.T) {
+	manager, repo := setupAutoCommitWorker(t)
+
+	manager.runAutoCommit("done")
+
+	out, err := exec.Command("git", "-C", repo, "log", "-1", "--format=%B").Output()
+	require.NoError(t, err)
+	assert.Equal(t, "Add main\n\nAmp-Thread: T-1\n\n", string(out))
+}
+
+func TestRunAutoCommit_OnlyWhenRequested(t *testing.T) {
+	manager, repo := setupAutoCommitWorker(t)
+	worker := findTestWorker(t, manager, "done")
+	worker.AutoCommit = false
+	require.NoError(t, manager.saveWorker(worker))
+
+	manager.runAutoCommit("done")
+
+	out, err := exec.Command("git", "-C", repo, "status", "--porcelain").Output()
+	require.NoError(t, err)
+	assert.Equal(t, "?? main.go\n", string(out))
+	assert.Empty(t, findTestWorker(t, manager, "done").Annotations)
+}
+
+func TestSetCommitMessage_InvalidTemplate(t *testing.T) {
+	assert.Error(t, NewManager(t.TempDir()).SetCommitMessage("{{.Title"))
+}
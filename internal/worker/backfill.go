@@ -0,0 +1,274 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// Outcomes of backfilling one worker's thread
+const (
+	BackfillAdded          = "backfilled"      // Messages missing from the thread were added
+	BackfillUpToDate       = "up-to-date"      // The thread already had every message
+	BackfillNoConversation = "no-conversation" // The logs contain no amp conversation
+	BackfillRunning        = "running"         // Skipped; the running worker's tailer owns its thread
+	BackfillOffloaded      = "offloaded"       // Skipped; the worker's logs were moved to the object store
+	BackfillFailed         = "failed"
+)
+
+// BackfillResult is the outcome of backfilling one worker's thread
+type BackfillResult struct {
+	TaskID string `json:"task_id"`
+	Status string `json:"status"`
+	Added  int    `json:"added"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BackfillReport summarizes a thread backfill
+type BackfillReport struct {
+	DryRun     bool             `json:"dry_run"`
+	Tasks      int              `json:"tasks"`
+	Backfilled int              `json:"backfilled"`
+	UpToDate   int              `json:"up_to_date"`
+	Skipped    int              `json:"skipped"` // Running workers and logs without a conversation
+	Failed     int              `json:"failed"`
+	Added      int              `json:"added"` // Messages added across all threads
+	Results    []BackfillResult `json:"results"`
+}
+
+// BackfillThreads re-parses the logs of the given workers (every worker when
+// taskIDs is empty) and adds the conversation messages missing from their
+// threads, e.g. for workers created before thread storage existed or whose
+// parsing failed. Messages already stored are recognized by type, content and
+// time, so running it again adds nothing. With dryRun nothing is written.
+// progress, when set, is called after each worker.
+func (m *Manager) BackfillThreads(taskIDs []string, dryRun bool, progress func(done, total int, result BackfillResult)) (*BackfillReport, error) {
+	workers, err := m.loadWorkers()
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []*Worker
+	if len(taskIDs) == 0 {
+		for _, worker := range workers {
+			targets = append(targets, worker)
+		}
+		sortWorkers(targets, "started", "asc")
+	} else {
+		for _, id := range taskIDs {
+			worker, exists := workers[id]
+			if !exists {
+				return nil, fmt.Errorf("%w: %s", ErrWorkerNotFound, id)
+			}
+			targets = append(targets, worker)
+		}
+	}
+
+	report := &BackfillReport{DryRun: dryRun, Tasks: len(targets), Results: []BackfillResult{}}
+	for i, worker := range targets {
+		result := BackfillResult{TaskID: worker.ID}
+		if worker.Status == StatusRunning {
+			result.Status = BackfillRunning
+		} else if worker.Offloaded != nil {
+			result.Status = BackfillOffloaded
+		} else if added, err := m.backfillThread(worker, dryRun); err != nil {
+			result.Status = BackfillFailed
+			result.Error = err.Error()
+		} else {
+			result.Status = BackfillUpToDate
+			if added < 0 {
+				result.Status = BackfillNoConversation
+			} else if added > 0 {
+				result.Status = BackfillAdded
+				result.Added = added
+			}
+		}
+
+		switch result.Status {
+		case BackfillAdded:
+			report.Backfilled++
+		case BackfillUpToDate:
+			report.UpToDate++
+		case BackfillFailed:
+			report.Failed++
+		default:
+			report.Skipped++
+		}
+		report.Added += result.Added
+		report.Results = append(report.Results, result)
+
+		if progress != nil {
+			progress(i+1, len(targets), result)
+		}
+	}
+	return report, nil
+}
+
+// backfillThread adds the conversation parsed from a worker's logs to its
+// thread, skipping messages already stored. It returns the number of messages added, or -1 when the logs contain no conversation.
+func (m *Manager) backfillThread(worker *Worker, dryRun bool) (int, error) {
+	parsed, err := m.parseLogs(worker)
+	if err != nil {
+		return 0, err
+	}
+	if len(parsed) == 0 {
+		return -1, nil
+	}
+
+	threads := m.threads(worker.Project)
+	existing, err := threads.ReadMessages(worker.ID, 0, 0)
+	if err != nil {
+		return 0, err
+	}
+	stored := make(map[string]bool, len(existing))
+	for _, message := range existing {
+		stored[messageKey(message)] = true
+	}
+
+	added := 0
+	for _, message := range parsed {
+		key := messageKey(message)
+		if stored[key] {
+			continue
+		}
+		stored[key] = true
+		added++
+		if dryRun {
+			continue
+		}
+
+		if err := threads.AppendMessage(worker.ID, message); err != nil {
+			return added - 1, err
+		}
+		if m.onThreadMsg != nil {
+			m.onThreadMsg(worker.ID, message)
+		}
+	}
+
+	if added > 0 && !dryRun {
+		log.Printf("Backfilled %d thread messages for worker %s", added, worker.ID)
+	}
+	return added, nil
+}
+
+// ThreadRebuild is the outcome of rebuilding a worker's thread
+type ThreadRebuild struct {
+	TaskID   string `json:"task_id"`
+	Previous int    `json:"previous"` // Messages in the thread before it was rebuilt
+	Messages int    `json:"messages"` // Messages in the rebuilt thread
+}
+
+// RebuildThread replaces a worker's thread with the conversation parsed from
+// its logs, e.g. after the parser improved or the stored thread was
+// corrupted. Duplicate messages are dropped, and messages that were already
+// stored keep their IDs so clients' cursors stay valid. Offloaded files are
+// restored first. Running workers are refused, since their tailer owns the
+// thread.
+func (m *Manager) RebuildThread(workerID string) (*ThreadRebuild, error) {
+	workers, err := m.loadWorkers()
+	if err != nil {
+		return nil, err
+	}
+	worker, exists := workers[workerID]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrWorkerNotFound, workerID)
+	}
+	if worker.Status == StatusRunning {
+		return nil, fmt.Errorf("%w: cannot rebuild the thread of running worker %s", ErrInvalidTransition, workerID)
+	}
+	if err := m.restore(worker); err != nil {
+		return nil, err
+	}
+
+	parsed, err := m.parseLogs(worker)
+	if err != nil {
+		return nil, err
+	}
+
+	threads := m.threads(worker.Project)
+	existing, err := threads.ReadMessages(worker.ID, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]string, len(existing))
+	for _, message := range existing {
+		if _, seen := ids[messageKey(message)]; !seen {
+			ids[messageKey(message)] = message.ID
+		}
+	}
+
+	seen := make(map[string]bool, len(parsed))
+	messages := make([]ThreadMessage, 0, len(parsed))
+	for _, message := range parsed {
+		key := messageKey(message)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if id, ok := ids[key]; ok {
+			message.ID = id
+		}
+		messages = append(messages, message)
+	}
+
+	if err := threads.ReplaceMessages(worker.ID, messages); err != nil {
+		return nil, err
+	}
+	log.Printf("Rebuilt thread of worker %s: %d messages, %d before", worker.ID, len(messages), len(existing))
+	return &ThreadRebuild{TaskID: worker.ID, Previous: len(existing), Messages: len(messages)}, nil
+}
+
+// parseLogs returns the conversation in a worker's logs. The amp log is
+// parsed first, and the stdout log only when the amp log holds no
+// conversation.
+func (m *Manager) parseLogs(worker *Worker) ([]ThreadMessage, error) {
+	for _, path := range []string{worker.AmpLogFile, worker.LogFile} {
+		if path == "" {
+			continue
+		}
+		messages, err := m.parseConversation(worker.ID, path, m.logFormat(worker))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(messages) > 0 {
+			return messages, nil
+		}
+	}
+	return nil, nil
+}
+
+// parseConversation returns the final conversation in a log file written in
+// the given format
+func (m *Manager) parseConversation(workerID, path string, format LogFormat) ([]ThreadMessage, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var messages []ThreadMessage
+	parser := NewAmpLogParser(workerID, func(message ThreadMessage) {
+		messages = append(messages, message)
+	})
+	parser.SetLogFormat(format)
+
+	scanner := NewLineReader(file, &m.lineLimit)
+	for scanner.Scan() {
+		parser.ParseLine(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	parser.ProcessFinalConversation()
+	return messages, nil
+}
+
+// messageKey identifies a parsed message independently of its random ID
+func messageKey(message ThreadMessage) string {
+	return fmt.Sprintf("%s\x00%d\x00%s", message.Type, message.Timestamp.Truncate(time.Millisecond).UnixNano(), message.Content)
+}
@@ -0,0 +1,153 @@
+package worker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const backfillAmpLog = `{"level":"info","message":"starting","timestamp":"2024-01-01T10:00:00Z"}
+{"level":"debug","message":"state","timestamp":"2024-01-01T10:00:01Z","event":{"type":"thread-state","thread":{"id":"T-1","title":"Hello","messages":[{"role":"user","content":[{"type":"text","text":"say hi"}]}]}}}
+{"level":"debug","message":"state","timestamp":"2024-01-01T10:00:05Z","event":{"type":"thread-state","thread":{"id":"T-1","title":"Hello","messages":[{"role":"user","content":[{"type":"text","text":"say hi"}],"meta":{"sentAt":1704103201000}},{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"Bash","input":{"cmd":"echo hi"}},{"type":"text","text":"hi"}]}]}}}
+`
+
+// setupBackfillManager saves a stopped worker with an amp log, one whose only
+// conversation is in its stdout log, one without logs and a running worker
+func setupBackfillManager(t *testing.T) *Manager {
+	tmpDir := t.TempDir()
+	manager := NewManager(tmpDir)
+
+	ampLog := filepath.Join(tmpDir, "worker-old-amp.log")
+	require.NoError(t, os.WriteFile(ampLog, []byte(backfillAmpLog), 0644))
+	stdoutLog := filepath.Join(tmpDir, "worker-stdout.log")
+	require.NoError(t, os.WriteFile(stdoutLog, []byte(backfillAmpLog), 0644))
+
+	base := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	workers := map[string]*Worker{
+		"old":     {ID: "old", ThreadID: "T-1", AmpLogFile: ampLog, LogFile: filepath.Join(tmpDir, "missing.log"), Started: base, Status: StatusCompleted},
+		"stdout":  {ID: "stdout", ThreadID: "T-2", LogFile: stdoutLog, Started: base.Add(time.Minute), Status: StatusStopped},
+		"empty":   {ID: "empty", ThreadID: "T-3", AmpLogFile: filepath.Join(tmpDir, "none.log"), Started: base.Add(2 * time.Minute), Status: StatusFailed},
+		"running": {ID: "running", ThreadID: "T-4", AmpLogFile: ampLog, Started: base.Add(3 * time.Minute), Status: StatusRunning},
+	}
+	require.NoError(t, manager.SaveWorkersForTest(workers, filepath.Join(tmpDir, "workers.json")))
+	return manager
+}
+
+func TestBackfillThreads(t *testing.T) {
+	manager := setupBackfillManager(t)
+
+	var progress []int
+	report, err := manager.BackfillThreads(nil, false, func(done, total int, result BackfillResult) {
+		assert.Equal(t, 4, total)
+		progress = append(progress, done)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3, 4}, progress)
+
+	assert.Equal(t, 4, report.Tasks)
+	assert.Equal(t, 2, report.Backfilled)
+	assert.Equal(t, 2, report.Skipped)
+	assert.Equal(t, 8, report.Added)
+	assert.Equal(t, []BackfillResult{
+		{TaskID: "old", Status: BackfillAdded, Added: 4},
+		{TaskID: "stdout", Status: BackfillAdded, Added: 4},
+		{TaskID: "empty", Status: BackfillNoConversation},
+		{TaskID: "running", Status: BackfillRunning},
+	}, report.Results)
+
+	messages, err := manager.threadStorage.ReadMessages("old", 0, 0)
+	require.NoError(t, err)
+	require.Len(t, messages, 4)
+	assert.Equal(t, "Thread: Hello", messages[0].Content)
+	assert.Equal(t, MessageTypeUser, messages[1].Type)
+	assert.Equal(t, "Running command: echo hi", messages[2].Content)
+	assert.Equal(t, "hi", messages[3].Content)
+
+	// Running it again adds nothing
+	report, err = manager.BackfillThreads([]string{"old", "stdout"}, false, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.UpToDate)
+	assert.Equal(t, 0, report.Added)
+	count, err := manager.CountThreadMessages("old")
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+}
+
+func TestBackfillThreads_PartialThreadAndDryRun(t *testing.T) {
+	manager := setupBackfillManager(t)
+
+	// A thread that only got its first message, plus one from another source
+	_, err := manager.BackfillThreads([]string{"old"}, false, nil)
+	require.NoError(t, err)
+	messages, err := manager.threadStorage.ReadMessages("old", 0, 0)
+	require.NoError(t, err)
+	threadFile := manager.threadStorage.getThreadFilePath("old")
+	require.NoError(t, os.Remove(threadFile))
+	require.NoError(t, manager.threadStorage.AppendMessage("old", messages[0]))
+	require.NoError(t, manager.AppendThreadMessage("old", MessageTypeSystem, "ci: success", nil))
+
+	report, err := manager.BackfillThreads([]string{"old"}, true, nil)
+	require.NoError(t, err)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 3, report.Added)
+	count, err := manager.CountThreadMessages("old")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	report, err = manager.BackfillThreads([]string{"old"}, false, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Added)
+	count, err = manager.CountThreadMessages("old")
+	require.NoError(t, err)
+	assert.Equal(t, 5, count)
+
+	_, err = manager.BackfillThreads([]string{"missing"}, false, nil)
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestRebuildThread(t *testing.T) {
+	manager := setupBackfillManager(t)
+
+	// A corrupted thread holding a duplicate, a stale message and junk
+	_, err := manager.BackfillThreads([]string{"old"}, false, nil)
+	require.NoError(t, err)
+	messages, err := manager.threadStorage.ReadMessages("old", 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, manager.threadStorage.AppendMessage("old", messages[1]))
+	require.NoError(t, manager.AppendThreadMessage("old", MessageTypeSystem, "stale", nil))
+	file, err := os.OpenFile(manager.threadStorage.getThreadFilePath("old"), os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = file.WriteString("{not json\n")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	rebuild, err := manager.RebuildThread("old")
+	require.NoError(t, err)
+	assert.Equal(t, &ThreadRebuild{TaskID: "old", Previous: 6, Messages: 4}, rebuild)
+
+	rebuilt, err := manager.threadStorage.ReadMessages("old", 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, messages, rebuilt, "messages keep their IDs")
+	count, err := manager.CountThreadMessages("old")
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	// Threads that were never stored are created from the stdout log
+	rebuild, err = manager.RebuildThread("stdout")
+	require.NoError(t, err)
+	assert.Equal(t, &ThreadRebuild{TaskID: "stdout", Messages: 4}, rebuild)
+
+	// Logs without a conversation leave an empty thread
+	rebuild, err = manager.RebuildThread("empty")
+	require.NoError(t, err)
+	assert.Equal(t, 0, rebuild.Messages)
+
+	_, err = manager.RebuildThread("running")
+	assert.ErrorContains(t, err, "cannot rebuild")
+	_, err = manager.RebuildThread("missing")
+	assert.ErrorContains(t, err, "not found")
+}
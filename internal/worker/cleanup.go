@@ -0,0 +1,137 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultCleanupTimeout bounds each cleanup command when no timeout is configured
+const DefaultCleanupTimeout = 2 * time.Minute
+
+// CleanupConfig lists commands run after a worker's process exits, e.g. to
+// stop services it started or remove temporary credentials
+type CleanupConfig struct {
+	Commands []string      // Run in order with bash -c
+	Timeout  time.Duration // Per command; 0 uses DefaultCleanupTimeout
+	Dir      string        // Working directory; empty uses the daemon's
+}
+
+// CleanupResult is the outcome of one cleanup command
+type CleanupResult struct {
+	Command  string `json:"command"`
+	ExitCode int    `json:"exit_code"`
+	TimedOut bool   `json:"timed_out,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// SetCleanup sets the commands run after each worker's process exits
+func (m *Manager) SetCleanup(config CleanupConfig) {
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultCleanupTimeout
+	}
+	m.cleanup = config
+}
+
+// runCleanup runs the cleanup commands for a worker, appending their output to
+// its log and recording the outcome as a "cleanup" annotation
+func (m *Manager) runCleanup(workerID string) {
+	if len(m.cleanup.Commands) == 0 {
+		return
+	}
+
+	workers, err := m.loadWorkers()
+	if err != nil {
+		log.Printf("Failed to load workers for cleanup: %v", err)
+		return
+	}
+	worker, exists := workers[workerID]
+	if !exists {
+		return
+	}
+
+	logFile, err := os.OpenFile(worker.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("Failed to open log file for cleanup of worker %s: %v", workerID, err)
+		return
+	}
+	defer logFile.Close()
+
+	env := append(os.Environ(),
+		"AMP_TASK_ID="+worker.ID,
+		"AMP_THREAD_ID="+worker.ThreadID,
+		"AMP_TASK_STATUS="+string(worker.Status),
+	)
+	env = append(env, m.artifactsEnv(worker)...)
+
+	var results []CleanupResult
+	var failed []string
+	for _, command := range m.cleanup.Commands {
+		fmt.Fprintf(logFile, "[cleanup] $ %s\n", command)
+		result := m.runCleanupCommand(command, env, logFile)
+		if result.Error != "" {
+			fmt.Fprintf(logFile, "[cleanup] %s\n", result.Error)
+			failed = append(failed, command)
+		}
+		results = append(results, result)
+	}
+
+	annotation := Annotation{
+		Key:     "cleanup",
+		Status:  AnnotationSuccess,
+		Summary: fmt.Sprintf("%d cleanup commands succeeded", len(results)),
+		Data:    map[string]interface{}{"results": results},
+	}
+	if len(failed) > 0 {
+		annotation.Status = AnnotationFailure
+		annotation.Summary = fmt.Sprintf("%d of %d cleanup commands failed: %s", len(failed), len(results), strings.Join(failed, "; "))
+	}
+	if _, err := m.Annotate(workerID, annotation); err != nil {
+		log.Printf("Failed to record cleanup of worker %s: %v", workerID, err)
+	}
+}
+
+// runCleanupCommand runs one command with the cleanup timeout
+func (m *Manager) runCleanupCommand(command string, env []string, output *os.File) CleanupResult {
+	result := CleanupResult{Command: command}
+	var err error
+	result.ExitCode, result.TimedOut, err = runShellCommand(command, env, m.cleanup.Dir, m.cleanup.Timeout, output)
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// runShellCommand runs command with bash -c in dir, killing its whole process
+// group if it overruns timeout
+func runShellCommand(command string, env []string, dir string, timeout time.Duration, output io.Writer) (exitCode int, timedOut bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "bash", "-c", command)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return exitCode, true, fmt.Errorf("timed out after %s", timeout)
+	}
+	return exitCode, false, err
+}
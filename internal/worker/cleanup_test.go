@@ -0,0 +1,94 @@
+package worker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupCleanupWorker saves a stopped worker with a log file
+func setupCleanupWorker(t *testing.T) (*Manager, string) {
+	tmpDir := t.TempDir()
+	manager := NewManager(tmpDir)
+
+	logFile := filepath.Join(tmpDir, "worker-done.log")
+	require.NoError(t, os.WriteFile(logFile, []byte("done\n"), 0644))
+
+	workers := map[string]*Worker{
+		"done": {ID: "done", ThreadID: "T-done", LogFile: logFile, Started: time.Now(), Status: StatusStopped},
+	}
+	require.NoError(t, manager.SaveWorkersForTest(workers, filepath.Join(tmpDir, "workers.json")))
+	return manager, logFile
+}
+
+func TestRunCleanup_Success(t *testing.T) {
+	manager, logFile := setupCleanupWorker(t)
+	dir := t.TempDir()
+	manager.SetCleanup(CleanupConfig{
+		Commands: []string{"echo cleaning $AMP_TASK_ID $AMP_TASK_STATUS", "pwd"},
+		Dir:      dir,
+	})
+
+	manager.runCleanup("done")
+
+	content, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "[cleanup] $ echo cleaning $AMP_TASK_ID $AMP_TASK_STATUS\ncleaning done stopped\n")
+	assert.Contains(t, string(content), dir+"\n")
+
+	worker := findTestWorker(t, manager, "done")
+	require.Len(t, worker.Annotations, 1)
+	assert.Equal(t, "cleanup", worker.Annotations[0].Key)
+	assert.Equal(t, AnnotationSuccess, worker.Annotations[0].Status)
+}
+
+func TestRunCleanup_FailuresFlagged(t *testing.T) {
+	manager, logFile := setupCleanupWorker(t)
+	manager.SetCleanup(CleanupConfig{
+		Commands: []string{"exit 3", "sleep 10", "echo still runs"},
+		Timeout:  200 * time.Millisecond,
+	})
+
+	start := time.Now()
+	manager.runCleanup("done")
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	content, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "[cleanup] exit status 3\n")
+	assert.Contains(t, string(content), "[cleanup] timed out after 200ms\n")
+	assert.Contains(t, string(content), "still runs\n")
+
+	annotation := findTestWorker(t, manager, "done").Annotations[0]
+	assert.Equal(t, AnnotationFailure, annotation.Status)
+	assert.Equal(t, "2 of 3 cleanup commands failed: exit 3; sleep 10", annotation.Summary)
+
+	results := annotation.Data["results"].([]interface{})
+	require.Len(t, results, 3)
+	assert.Equal(t, float64(3), results[0].(map[string]interface{})["exit_code"])
+	assert.Equal(t, true, results[1].(map[string]interface{})["timed_out"])
+}
+
+func TestRunCleanup_NoCommands(t *testing.T) {
+	manager, logFile := setupCleanupWorker(t)
+
+	manager.runCleanup("done")
+
+	content, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.Equal(t, "done\n", string(content))
+	assert.Empty(t, findTestWorker(t, manager, "done").Annotations)
+}
+
+// findTestWorker returns a saved worker by ID
+func findTestWorker(t *testing.T, manager *Manager, id string) *Worker {
+	workers, err := manager.loadWorkers()
+	require.NoError(t, err)
+	worker, ok := workers[id]
+	require.True(t, ok)
+	return worker
+}
@@ -0,0 +1,121 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrSecretsNotConfigured is returned when a task references secrets but the
+// daemon has no secret store
+var ErrSecretsNotConfigured = errors.New("secrets are not configured")
+
+// SecretSource looks up the values of the secrets tasks reference by name
+type SecretSource interface {
+	Get(name string) (string, error)
+}
+
+// SetSecrets sets where the secrets referenced by tasks are read from
+func (m *Manager) SetSecrets(source SecretSource) {
+	m.secrets = source
+}
+
+// ErrEnvNotAllowed is returned when a task sets an environment variable that
+// could change how amp is run or whose account it runs under
+var ErrEnvNotAllowed = errors.New("environment variable not allowed")
+
+// deniedEnv lists variables tasks may not set
+var deniedEnv = []string{
+	"PATH", "HOME", "SHELL", "USER", "IFS", "ENV", "BASH_ENV", "SHELLOPTS", "BASHOPTS", "PS4", "PROMPT_COMMAND",
+	"AMP_API_KEY", "AMP_URL", ArtifactsEnv,
+}
+
+// deniedEnvPrefixes lists prefixes of variables tasks may not set, such as the
+// dynamic loader's and Node's, whose NODE_OPTIONS can load code into amp
+var deniedEnvPrefixes = []string{"LD_", "DYLD_", "BASH_FUNC_", "NODE_"}
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateEnv checks the names of the variables a task sets
+func validateEnv(env map[string]string) error {
+	for name := range env {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("%w: invalid name %q", ErrEnvNotAllowed, name)
+		}
+		upper := strings.ToUpper(name)
+		if contains(deniedEnv, upper) {
+			return fmt.Errorf("%w: %s", ErrEnvNotAllowed, name)
+		}
+		for _, prefix := range deniedEnvPrefixes {
+			if strings.HasPrefix(upper, prefix) {
+				return fmt.Errorf("%w: %s", ErrEnvNotAllowed, name)
+			}
+		}
+	}
+	return nil
+}
+
+// secretEnv reads the secrets referenced by a task, returning them as
+// sorted NAME=value pairs
+func (m *Manager) secretEnv(secrets map[string]string) ([]string, error) {
+	if len(secrets) == 0 {
+		return nil, nil
+	}
+	if m.secrets == nil {
+		return nil, ErrSecretsNotConfigured
+	}
+
+	env := make([]string, 0, len(secrets))
+	for name, secret := range secrets {
+		value, err := m.secrets.Get(secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret for %s: %w", name, err)
+		}
+		env = append(env, name+"="+value)
+	}
+	sort.Strings(env)
+	return env, nil
+}
+
+// workerEnv returns the variables added to the daemon's environment for a
+// worker's amp invocations as NAME=value pairs: the task's own, then its
+// pool's, then its secrets, then its profile's
+func (m *Manager) workerEnv(worker *Worker) ([]string, error) {
+	var env []string
+	for name, value := range worker.Env {
+		env = append(env, name+"="+value)
+	}
+	sort.Strings(env)
+	env = append(env, m.poolEnv(worker)...)
+
+	secretEnv, err := m.secretEnv(worker.Secrets)
+	if err != nil {
+		return nil, err
+	}
+	profileEnv, err := m.profileEnv(worker.Profile)
+	if err != nil {
+		return nil, err
+	}
+	return append(append(env, secretEnv...), profileEnv...), nil
+}
+
+// workerEnvVars returns the names of the variables in workerEnv
+func (m *Manager) workerEnvVars(worker *Worker) []string {
+	names := make([]string, 0, len(worker.Env))
+	for name := range worker.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, pair := range m.poolEnv(worker) {
+		names = append(names, strings.SplitN(pair, "=", 2)[0])
+	}
+
+	secretNames := make([]string, 0, len(worker.Secrets))
+	for name := range worker.Secrets {
+		secretNames = append(secretNames, name)
+	}
+	sort.Strings(secretNames)
+	return append(append(names, secretNames...), m.profileVars(worker.Profile)...)
+}
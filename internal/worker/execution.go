@@ -0,0 +1,207 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ExecutionMode selects where a worker's amp process runs
+type ExecutionMode string
+
+const (
+	ExecutionHost      ExecutionMode = "host"      // Directly on the daemon's host
+	ExecutionContainer ExecutionMode = "container" // Inside a container started for each amp invocation
+)
+
+// ErrContainerNotConfigured is returned when a worker asks for container
+// execution but no container image is configured
+var ErrContainerNotConfigured = errors.New("container execution is not configured")
+
+// Container labels identifying the worker and thread an amp container belongs to
+const (
+	containerTaskLabel   = "ampd.task"
+	containerThreadLabel = "ampd.thread"
+)
+
+// ContainerConfig describes the container amp runs in when a worker uses
+// container execution. The log directory is always mounted at the same path
+// so amp's logs reach the tailer as they do on the host.
+type ContainerConfig struct {
+	Runtime   string   // Container CLI; defaults to "docker"
+	Image     string   // Image with amp installed
+	AmpBinary string   // amp inside the image; defaults to "amp"
+	Mounts    []string // Extra bind mounts as "host:container[:ro]"
+	Network   string   // Passed as --network, e.g. "none"; empty uses the runtime's default
+	Env       []string // Variables passed through from the daemon's environment, e.g. AMP_API_KEY
+	Workdir   string   // Working directory inside the container
+}
+
+// SetExecution sets where workers run by default and the container used for
+// container execution
+func (m *Manager) SetExecution(mode ExecutionMode, container ContainerConfig) error {
+	if container.Runtime == "" {
+		container.Runtime = "docker"
+	}
+	if container.AmpBinary == "" {
+		container.AmpBinary = "amp"
+	}
+	m.container = container
+
+	resolved, err := m.executionMode(mode)
+	if err != nil {
+		return err
+	}
+	m.defaultExecution = resolved
+	return nil
+}
+
+// executionMode resolves a requested mode, falling back to the default
+func (m *Manager) executionMode(requested ExecutionMode) (ExecutionMode, error) {
+	switch requested {
+	case "":
+		if m.defaultExecution == "" {
+			return ExecutionHost, nil
+		}
+		return m.defaultExecution, nil
+	case ExecutionHost:
+		return ExecutionHost, nil
+	case ExecutionContainer:
+		if m.container.Image == "" {
+			return "", ErrContainerNotConfigured
+		}
+		return ExecutionContainer, nil
+	case ExecutionRemote:
+		if m.agents == nil {
+			return "", ErrRemoteNotConfigured
+		}
+		return ExecutionRemote, nil
+	}
+	return "", fmt.Errorf("unknown execution mode %q", requested)
+}
+
+// onHost reports whether a worker's agent runs directly on the daemon's host.
+// Workers saved before execution modes existed have none and ran there.
+func onHost(worker *Worker) bool {
+	return worker.Execution == ExecutionHost || worker.Execution == ""
+}
+
+// ampCommand builds the command that runs the worker's agent (amp unless it
+// chose another backend) with args, on the host or in a container labelled
+// with the worker and thread. Start it with startAmp to send the message.
+func (m *Manager) ampCommand(worker *Worker, args ...string) (*exec.Cmd, error) {
+	client, err := m.agentClient(worker.Backend, worker)
+	if err != nil {
+		return nil, err
+	}
+	env, err := m.workerEnv(worker)
+	if err != nil {
+		return nil, err
+	}
+	args, err = m.invocationArgs(worker, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if onHost(worker) {
+		cmd := exec.Command(client.Binary(), args...)
+		// Run in the pool's directory, or the project's checkout when it has one
+		if dir := m.poolDir(worker); dir != "" {
+			cmd.Dir = dir
+		} else if project, err := m.GetProject(worker.ProjectName()); err == nil {
+			cmd.Dir = project.Amp.Dir
+		}
+		cmd.Env = append(append(os.Environ(), env...), m.artifactsEnv(worker)...)
+		return cmd, nil
+	}
+
+	// Other agents are expected at the same path inside the image
+	binary := m.container.AmpBinary
+	if !isAmp(worker.Backend) {
+		binary = client.Binary()
+	}
+	command := append([]string{binary}, args...)
+	cmd := exec.Command(m.container.Runtime, m.containerArgs(worker, command)...)
+	// The container takes the worker's values from the runtime's environment,
+	// keeping them out of its command line
+	cmd.Env = append(os.Environ(), env...)
+	return cmd, nil
+}
+
+// containerArgs returns the arguments that run command in a new container for
+// worker, with the runtime's stdin attached to it
+func (m *Manager) containerArgs(worker *Worker, command []string) []string {
+	logDir, err := filepath.Abs(m.logDir)
+	if err != nil {
+		logDir = m.logDir
+	}
+
+	// --init forwards the signals proxied by the CLI to amp and reaps its children
+	args := []string{
+		"run", "--rm", "-i", "--init",
+		"--label", containerTaskLabel + "=" + worker.ID,
+		"--label", containerThreadLabel + "=" + worker.ThreadID,
+		"-v", logDir + ":" + logDir,
+	}
+	for _, mount := range m.container.Mounts {
+		args = append(args, "-v", mount)
+	}
+	if m.container.Network != "" {
+		args = append(args, "--network", m.container.Network)
+	}
+	for _, name := range m.container.Env {
+		args = append(args, "-e", name)
+	}
+	for _, name := range m.workerEnvVars(worker) {
+		args = append(args, "-e", name)
+	}
+	// The artifacts directory is under the mounted log directory
+	for _, variable := range m.artifactsEnv(worker) {
+		args = append(args, "-e", variable)
+	}
+	if m.container.Workdir != "" {
+		args = append(args, "-w", m.container.Workdir)
+	}
+	return append(append(args, m.container.Image), command...)
+}
+
+// startAmp starts an amp command built by ampCommand and writes message to its
+// stdin, closing it afterwards so amp reads the whole message. The message is
+// written in the background since amp may not read it before it's started.
+func startAmp(cmd *exec.Cmd, message string) error {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	go func() {
+		defer stdin.Close()
+		io.WriteString(stdin, message+"\n")
+	}()
+	return nil
+}
+
+// signalContainers sends signal to the running containers whose label has
+// value. Failures are ignored since the containers may already have exited.
+func (m *Manager) signalContainers(label, value, signal string) {
+	if m.container.Image == "" {
+		return
+	}
+
+	output, err := exec.Command(m.container.Runtime, "ps", "-q", "--filter", "label="+label+"="+value).Output()
+	if err != nil {
+		return
+	}
+	ids := strings.Fields(string(output))
+	if len(ids) == 0 {
+		return
+	}
+	exec.Command(m.container.Runtime, append([]string{"kill", "--signal", signal}, ids...)...).Run()
+}
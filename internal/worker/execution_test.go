@@ -0,0 +1,228 @@
+package worker
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutionMode(t *testing.T) {
+	manager := NewManager(t.TempDir())
+
+	mode, err := manager.executionMode("")
+	require.NoError(t, err)
+	assert.Equal(t, ExecutionHost, mode)
+
+	_, err = manager.executionMode(ExecutionContainer)
+	assert.ErrorIs(t, err, ErrContainerNotConfigured)
+	assert.ErrorIs(t, manager.SetExecution(ExecutionContainer, ContainerConfig{}), ErrContainerNotConfigured)
+
+	require.NoError(t, manager.SetExecution(ExecutionContainer, ContainerConfig{Image: "amp-sandbox"}))
+	mode, err = manager.executionMode("")
+	require.NoError(t, err)
+	assert.Equal(t, ExecutionContainer, mode)
+
+	// Tasks may still ask for the host
+	mode, err = manager.executionMode(ExecutionHost)
+	require.NoError(t, err)
+	assert.Equal(t, ExecutionHost, mode)
+
+	_, err = manager.executionMode("vm")
+	assert.Error(t, err)
+}
+
+func TestAmpCommand(t *testing.T) {
+	logDir := t.TempDir()
+	manager := NewManager(logDir)
+	manager.SetAmpBinary("/usr/local/bin/amp")
+	worker := &Worker{ID: "abc123", ThreadID: "T-1"}
+
+	cmd, err := manager.ampCommand(worker, "threads", "continue", "T-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/usr/local/bin/amp", "threads", "continue", "T-1"}, cmd.Args)
+	assert.Contains(t, cmd.Env, ArtifactsEnv+"="+filepath.Join(logDir, "artifacts", "abc123"))
+
+	require.NoError(t, manager.SetExecution(ExecutionHost, ContainerConfig{
+		Runtime: "podman",
+		Image:   "amp-sandbox:1",
+		Mounts:  []string{"/srv/repo:/workspace:ro"},
+		Network: "none",
+		Env:     []string{"AMP_API_KEY"},
+		Workdir: "/workspace",
+	}))
+	worker.Execution = ExecutionContainer
+
+	abs, err := filepath.Abs(logDir)
+	require.NoError(t, err)
+	cmd, err = manager.ampCommand(worker, "threads", "continue", "T-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"podman", "run", "--rm", "-i", "--init",
+		"--label", "ampd.task=abc123",
+		"--label", "ampd.thread=T-1",
+		"-v", abs + ":" + abs,
+		"-v", "/srv/repo:/workspace:ro",
+		"--network", "none",
+		"-e", "AMP_API_KEY",
+		"-e", ArtifactsEnv + "=" + filepath.Join(abs, "artifacts", "abc123"),
+		"-w", "/workspace",
+		"amp-sandbox:1", "amp", "threads", "continue", "T-1",
+	}, cmd.Args)
+}
+
+func TestStartAmp(t *testing.T) {
+	tmpDir := t.TempDir()
+	scriptPath := filepath.Join(tmpDir, "amp")
+	require.NoError(t, os.WriteFile(scriptPath, []byte("#!/bin/sh\ncat\n"), 0755))
+	manager := NewManager(tmpDir)
+	manager.SetAmpBinary(scriptPath)
+
+	cmd, err := manager.ampCommand(&Worker{ID: "abc123", ThreadID: "T-1"}, "threads", "continue", "T-1")
+	require.NoError(t, err)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+
+	// Quotes, newlines and shell syntax reach amp unchanged
+	message := "it's \"quoted\"\nsecond line $(echo no) `echo no` \\n"
+	require.NoError(t, startAmp(cmd, message))
+	require.NoError(t, cmd.Wait())
+	assert.Equal(t, message+"\n", stdout.String())
+}
+
+func TestAmpOverrides_Validate(t *testing.T) {
+	overrides := AmpOverrides{
+		Binaries: []string{"/opt/amp-beta/bin/amp"},
+		Flags:    []string{"--mcp-config", "--no-notifications"},
+	}
+
+	assert.NoError(t, overrides.validate("", nil, ExecutionHost))
+	assert.NoError(t, overrides.validate("/opt/amp-beta/bin/amp", []string{"--mcp-config=/etc/mcp.json", "--no-notifications"}, ExecutionHost))
+	assert.NoError(t, overrides.validate("", []string{"--mcp-config=x"}, ExecutionContainer))
+
+	for _, tt := range []struct {
+		binary    string
+		args      []string
+		execution ExecutionMode
+	}{
+		{"/usr/bin/amp", nil, ExecutionHost},
+		{"/opt/amp-beta/bin/amp", nil, ExecutionContainer},
+		{"", []string{"--dangerously-allow-all"}, ExecutionHost},
+		{"", []string{"/etc/mcp.json"}, ExecutionHost},
+		{"", []string{"--mcp-config", "/etc/mcp.json"}, ExecutionHost},
+	} {
+		err := overrides.validate(tt.binary, tt.args, tt.execution)
+		assert.ErrorIs(t, err, ErrAmpOverrideNotAllowed, "%s %v", tt.binary, tt.args)
+	}
+}
+
+func TestAmpCommand_Overrides(t *testing.T) {
+	manager := NewManager(t.TempDir())
+	worker := &Worker{
+		ID:        "abc123",
+		ThreadID:  "T-1",
+		AmpBinary: "/opt/amp-beta/bin/amp",
+		AmpArgs:   []string{"--mcp-config=/etc/it's.json"},
+	}
+
+	cmd, err := manager.ampCommand(worker, "threads", "continue", "T-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/opt/amp-beta/bin/amp", "--mcp-config=/etc/it's.json", "threads", "continue", "T-1"}, cmd.Args)
+}
+
+func TestAmpCommand_Profile(t *testing.T) {
+	manager := NewManager(t.TempDir())
+	manager.SetAmpProfiles(map[string]AmpProfile{
+		"staging": {Env: map[string]string{"AMP_API_KEY": "secret"}},
+	})
+	worker := &Worker{ID: "abc123", ThreadID: "T-1", Profile: "staging"}
+
+	cmd, err := manager.ampCommand(worker, "threads", "continue", "T-1")
+	require.NoError(t, err)
+	assert.Contains(t, cmd.Env, "AMP_API_KEY=secret")
+
+	// Containers get the value from the runtime's environment, not its arguments
+	require.NoError(t, manager.SetExecution(ExecutionHost, ContainerConfig{Runtime: "docker", Image: "amp-sandbox:1"}))
+	worker.Execution = ExecutionContainer
+	cmd, err = manager.ampCommand(worker, "threads", "continue", "T-1")
+	require.NoError(t, err)
+	assert.Contains(t, strings.Join(cmd.Args, " "), " -e AMP_API_KEY ")
+	assert.NotContains(t, strings.Join(cmd.Args, " "), "secret")
+	assert.Contains(t, cmd.Env, "AMP_API_KEY=secret")
+}
+
+func TestValidateEnv(t *testing.T) {
+	assert.NoError(t, validateEnv(nil))
+	assert.NoError(t, validateEnv(map[string]string{"FEATURE_FLAGS": "beta", "_debug": "1"}))
+
+	for _, name := range []string{"PATH", "path", "LD_PRELOAD", "DYLD_INSERT_LIBRARIES", "NODE_OPTIONS", "node_path", "BASH_ENV", "AMP_API_KEY", ArtifactsEnv, "1X", "A-B", "A=B", ""} {
+		err := validateEnv(map[string]string{name: "x"})
+		assert.ErrorIs(t, err, ErrEnvNotAllowed, name)
+	}
+}
+
+func TestAmpCommand_Env(t *testing.T) {
+	manager := NewManager(t.TempDir())
+	manager.SetAmpProfiles(map[string]AmpProfile{
+		"staging": {Env: map[string]string{"AMP_API_KEY": "secret"}},
+	})
+	worker := &Worker{ID: "abc123", ThreadID: "T-1", Profile: "staging", Env: map[string]string{"B": "2", "A": "1"}}
+
+	env, err := manager.workerEnv(worker)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A=1", "B=2", "AMP_API_KEY=secret"}, env)
+	cmd, err := manager.ampCommand(worker, "threads", "continue", "T-1")
+	require.NoError(t, err)
+	assert.Contains(t, cmd.Env, "A=1")
+	assert.Contains(t, cmd.Env, "B=2")
+
+	require.NoError(t, manager.SetExecution(ExecutionHost, ContainerConfig{Runtime: "docker", Image: "amp-sandbox:1"}))
+	worker.Execution = ExecutionContainer
+	cmd, err = manager.ampCommand(worker, "threads", "continue", "T-1")
+	require.NoError(t, err)
+	assert.Contains(t, strings.Join(cmd.Args, " "), " -e A -e B -e AMP_API_KEY ")
+	assert.Contains(t, cmd.Env, "B=2")
+}
+
+// mapSecrets is a SecretSource backed by a map
+type mapSecrets map[string]string
+
+func (s mapSecrets) Get(name string) (string, error) {
+	value, ok := s[name]
+	if !ok {
+		return "", errors.New("secret not found: " + name)
+	}
+	return value, nil
+}
+
+func TestAmpCommand_Secrets(t *testing.T) {
+	manager := NewManager(t.TempDir())
+	worker := &Worker{ID: "abc123", ThreadID: "T-1", Secrets: map[string]string{"GITHUB_TOKEN": "github-token"}}
+
+	_, err := manager.ampCommand(worker, "threads", "continue", "T-1")
+	assert.ErrorIs(t, err, ErrSecretsNotConfigured)
+
+	manager.SetSecrets(mapSecrets{"github-token": "ghp_123"})
+	cmd, err := manager.ampCommand(worker, "threads", "continue", "T-1")
+	require.NoError(t, err)
+	assert.Contains(t, cmd.Env, "GITHUB_TOKEN=ghp_123")
+	assert.NotContains(t, strings.Join(cmd.Args, " "), "ghp_123")
+
+	// Containers get the value from the runtime's environment
+	require.NoError(t, manager.SetExecution(ExecutionHost, ContainerConfig{Runtime: "docker", Image: "amp-sandbox:1"}))
+	worker.Execution = ExecutionContainer
+	cmd, err = manager.ampCommand(worker, "threads", "continue", "T-1")
+	require.NoError(t, err)
+	assert.Contains(t, strings.Join(cmd.Args, " "), " -e GITHUB_TOKEN ")
+	assert.NotContains(t, strings.Join(cmd.Args, " "), "ghp_123")
+
+	// A deleted secret stops the worker from launching
+	manager.SetSecrets(mapSecrets{})
+	_, err = manager.ampCommand(worker, "threads", "continue", "T-1")
+	assert.ErrorContains(t, err, "failed to read secret for GITHUB_TOKEN")
+}
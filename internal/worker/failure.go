@@ -0,0 +1,115 @@
+package worker
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// FailureKind classifies the errors that make amp give up on a task
+type FailureKind string
+
+const (
+	FailureAuth    FailureKind = "auth"    // amp isn't logged in or its credentials were rejected
+	FailureCredits FailureKind = "credits" // The account is out of credits
+	FailureNetwork FailureKind = "network" // amp couldn't reach its servers
+)
+
+// FailureReason describes the error a worker failed with
+type FailureReason struct {
+	Kind     FailureKind `json:"kind"`
+	Message  string      `json:"message"`   // The log line the error was detected in
+	ExitCode int         `json:"exit_code"` // Exit code of the amp process
+}
+
+// failurePatterns recognize amp's errors in its output, most specific first
+var failurePatterns = []struct {
+	kind    FailureKind
+	pattern *regexp.Regexp
+}{
+	{FailureCredits, regexp.MustCompile(`(?i)insufficient credits|out of credits|credit balance|payment required`)},
+	{FailureAuth, regexp.MustCompile(`(?i)unauthori[sz]ed|invalid api key|authentication (failed|required)|not logged in|please (log ?in|sign in)`)},
+	{FailureNetwork, regexp.MustCompile(`(?i)ECONNREFUSED|ECONNRESET|ENOTFOUND|ETIMEDOUT|EAI_AGAIN|getaddrinfo|fetch failed|network error|connection refused`)},
+}
+
+// failureScanBytes is how much of the end of each log is searched for errors
+const failureScanBytes = 64 * 1024
+
+// classifyFailure returns the kind of amp error line reports, if any
+func classifyFailure(line string) (FailureKind, bool) {
+	for _, p := range failurePatterns {
+		if p.pattern.MatchString(line) {
+			return p.kind, true
+		}
+	}
+	return "", false
+}
+
+// ampLogError returns the text of an error entry in amp's JSON log, or false
+// for other entries
+func ampLogError(line string) (string, bool) {
+	var entry struct {
+		Level   string          `json:"level"`
+		Message string          `json:"message"`
+		Error   json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal([]byte(line), &entry) != nil {
+		return "", false
+	}
+	if entry.Level != "error" && entry.Level != "fatal" {
+		return "", false
+	}
+	text := entry.Message
+	if len(entry.Error) > 0 {
+		text += " " + string(entry.Error)
+	}
+	return text, true
+}
+
+// detectFailure searches the end of a worker's logs for the error that made
+// amp exit. Error entries in amp's JSON log always count. Lines of the stdout
+// log only count when amp exited with an error, since they also carry the
+// agent's replies, which may quote such errors. The last error found wins.
+func (m *Manager) detectFailure(worker *Worker, exitCode int) *FailureReason {
+	var reason *FailureReason
+	if worker.AmpLogFile != "" {
+		m.scanLogTail(worker.AmpLogFile, func(line string) {
+			if text, ok := ampLogError(line); ok {
+				if kind, ok := classifyFailure(text); ok {
+					reason = &FailureReason{Kind: kind, Message: strings.TrimSpace(text), ExitCode: exitCode}
+				}
+			}
+		})
+	}
+	if reason != nil || exitCode == 0 || worker.LogFile == "" {
+		return reason
+	}
+	m.scanLogTail(worker.LogFile, func(line string) {
+		if kind, ok := classifyFailure(line); ok {
+			reason = &FailureReason{Kind: kind, Message: strings.TrimSpace(line), ExitCode: exitCode}
+		}
+	})
+	return reason
+}
+
+// scanLogTail calls fn with each line in the last failureScanBytes of a log.
+// A log that can't be read has no lines.
+func (m *Manager) scanLogTail(path string, fn func(line string)) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	if info, err := file.Stat(); err == nil && info.Size() > failureScanBytes {
+		if _, err := file.Seek(-failureScanBytes, io.SeekEnd); err != nil {
+			return
+		}
+	}
+	scanner := NewLineReader(file, &m.lineLimit)
+	for scanner.Scan() {
+		fn(scanner.Text())
+	}
+}
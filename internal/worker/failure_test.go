@@ -0,0 +1,87 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	for line, want := range map[string]FailureKind{
+		"Error: Unauthorized":                              FailureAuth,
+		"You are not logged in. Run `amp login`.":          FailureAuth,
+		"Error: insufficient credits, add more at ampcode": FailureCredits,
+		"TypeError: fetch failed":                          FailureNetwork,
+		"connect ECONNREFUSED 127.0.0.1:443":               FailureNetwork,
+	} {
+		kind, ok := classifyFailure(line)
+		assert.True(t, ok, line)
+		assert.Equal(t, want, kind, line)
+	}
+	_, ok := classifyFailure("All tests pass")
+	assert.False(t, ok)
+}
+
+func TestDetectFailure(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := NewManager(tmpDir)
+	worker := &Worker{
+		ID:         "w",
+		LogFile:    filepath.Join(tmpDir, "w.log"),
+		AmpLogFile: filepath.Join(tmpDir, "w-amp.log"),
+	}
+
+	// The agent's replies may quote errors; they only count when amp failed
+	require.NoError(t, os.WriteFile(worker.LogFile, []byte("The API returned 401 Unauthorized, so I added a token\n"), 0644))
+	assert.Nil(t, manager.detectFailure(worker, 0))
+	reason := manager.detectFailure(worker, 1)
+	require.NotNil(t, reason)
+	assert.Equal(t, FailureAuth, reason.Kind)
+	assert.Equal(t, 1, reason.ExitCode)
+
+	// Error entries of amp's log count whatever the exit code, and info
+	// entries never do
+	ampLog := `{"level":"info","message":"retrying after fetch failed"}` + "\n" +
+		`{"level":"error","message":"request failed","error":"insufficient credits"}` + "\n"
+	require.NoError(t, os.WriteFile(worker.AmpLogFile, []byte(ampLog), 0644))
+	reason = manager.detectFailure(worker, 0)
+	require.NotNil(t, reason)
+	assert.Equal(t, FailureCredits, reason.Kind)
+	assert.Equal(t, `request failed "insufficient credits"`, reason.Message)
+}
+
+func TestManager_FailedOnAmpError(t *testing.T) {
+	tmpDir := t.TempDir()
+	script := `#!/bin/bash
+if [ "$1" = "threads" ] && [ "$2" = "new" ]; then
+	echo "T-fail"
+	exit 0
+fi
+cat > /dev/null
+echo "Error: You are not logged in. Run amp login first." >&2
+exit 1
+`
+	scriptPath := filepath.Join(tmpDir, "amp")
+	require.NoError(t, os.WriteFile(scriptPath, []byte(script), 0755))
+	manager := NewManager(tmpDir)
+	manager.SetAmpBinary(scriptPath)
+
+	worker, err := manager.StartWorkerWithOptions(context.Background(), "fix the build", StartOptions{})
+	require.NoError(t, err)
+
+	var saved *Worker
+	require.Eventually(t, func() bool {
+		saved = findTestWorker(t, manager, worker.ID)
+		return saved.Status != StatusRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, StatusFailed, saved.Status)
+	require.NotNil(t, saved.FailureReason)
+	assert.Equal(t, FailureAuth, saved.FailureReason.Kind)
+	assert.Equal(t, 1, saved.FailureReason.ExitCode)
+	assert.Contains(t, saved.StatusReason, "not logged in")
+}
@@ -0,0 +1,104 @@
+package worker
+
+// Hierarchy indexes parent/child relationships between workers so rollup
+// status and subtree operations can be computed from a single state snapshot
+type Hierarchy struct {
+	workers  map[string]*Worker
+	children map[string][]string
+}
+
+// NewHierarchy builds a hierarchy index from the given workers
+func NewHierarchy(workers map[string]*Worker) *Hierarchy {
+	h := &Hierarchy{
+		workers:  workers,
+		children: make(map[string][]string),
+	}
+
+	for id, worker := range workers {
+		if worker.ParentID == "" {
+			continue
+		}
+		// Children of unknown parents are treated as roots
+		if _, ok := workers[worker.ParentID]; ok {
+			h.children[worker.ParentID] = append(h.children[worker.ParentID], id)
+		}
+	}
+
+	return h
+}
+
+// NewHierarchyFromList builds a hierarchy index from a slice of workers
+func NewHierarchyFromList(workers []*Worker) *Hierarchy {
+	byID := make(map[string]*Worker, len(workers))
+	for _, worker := range workers {
+		byID[worker.ID] = worker
+	}
+	return NewHierarchy(byID)
+}
+
+// Children returns the IDs of the direct children of a worker
+func (h *Hierarchy) Children(workerID string) []string {
+	return h.children[workerID]
+}
+
+// Subtree returns the worker and all of its descendants, ordered so that
+// children always come before their parents
+func (h *Hierarchy) Subtree(workerID string) []*Worker {
+	var result []*Worker
+	visited := make(map[string]bool)
+
+	var walk func(id string)
+	walk = func(id string) {
+		// Guard against cycles introduced by hand-edited state
+		if visited[id] {
+			return
+		}
+		visited[id] = true
+
+		for _, childID := range h.children[id] {
+			walk(childID)
+		}
+		if worker, ok := h.workers[id]; ok {
+			result = append(result, worker)
+		}
+	}
+	walk(workerID)
+
+	return result
+}
+
+// ChildStatusCounts returns the number of direct children in each status
+func (h *Hierarchy) ChildStatusCounts(workerID string) map[WorkerStatus]int {
+	counts := make(map[WorkerStatus]int)
+	for _, childID := range h.children[workerID] {
+		if child, ok := h.workers[childID]; ok {
+			counts[child.Status]++
+		}
+	}
+	return counts
+}
+
+// AggregateStatus computes the rollup status of a worker and its subtree.
+// A subtree is running if any member is running, failed if any member
+// failed, and otherwise reports the worker's own status.
+func (h *Hierarchy) AggregateStatus(workerID string) WorkerStatus {
+	worker, ok := h.workers[workerID]
+	if !ok {
+		return ""
+	}
+
+	failed := false
+	for _, member := range h.Subtree(workerID) {
+		switch member.Status {
+		case StatusRunning:
+			return StatusRunning
+		case StatusFailed:
+			failed = true
+		}
+	}
+
+	if failed {
+		return StatusFailed
+	}
+	return worker.Status
+}
@@ -0,0 +1,168 @@
+package worker
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testHierarchyWorkers() map[string]*Worker {
+	return map[string]*Worker{
+		"parent": {ID: "parent", Status: StatusStopped},
+		"child1": {ID: "child1", Status: StatusCompleted, ParentID: "parent"},
+		"child2": {ID: "child2", Status: StatusStopped, ParentID: "parent"},
+		"grand":  {ID: "grand", Status: StatusStopped, ParentID: "child2"},
+		"orphan": {ID: "orphan", Status: StatusRunning, ParentID: "missing"},
+	}
+}
+
+func TestHierarchy_Children(t *testing.T) {
+	tree := NewHierarchy(testHierarchyWorkers())
+
+	assert.ElementsMatch(t, []string{"child1", "child2"}, tree.Children("parent"))
+	assert.Equal(t, []string{"grand"}, tree.Children("child2"))
+	assert.Empty(t, tree.Children("child1"))
+	assert.Empty(t, tree.Children("missing"))
+}
+
+func TestHierarchy_SubtreeChildrenFirst(t *testing.T) {
+	tree := NewHierarchy(testHierarchyWorkers())
+
+	subtree := tree.Subtree("parent")
+	require.Len(t, subtree, 4)
+
+	position := make(map[string]int)
+	for i, w := range subtree {
+		position[w.ID] = i
+	}
+	assert.Less(t, position["grand"], position["child2"])
+	assert.Less(t, position["child1"], position["parent"])
+	assert.Less(t, position["child2"], position["parent"])
+}
+
+func TestHierarchy_SubtreeCycle(t *testing.T) {
+	workers := map[string]*Worker{
+		"a": {ID: "a", ParentID: "b"},
+		"b": {ID: "b", ParentID: "a"},
+	}
+
+	assert.Len(t, NewHierarchy(workers).Subtree("a"), 2)
+}
+
+func TestHierarchy_AggregateStatus(t *testing.T) {
+	workers := testHierarchyWorkers()
+	tree := NewHierarchy(workers)
+
+	// No running or failed members reports the parent's own status
+	assert.Equal(t, StatusStopped, tree.AggregateStatus("parent"))
+
+	// A failed descendant fails the rollup
+	workers["grand"].Status = StatusFailed
+	assert.Equal(t, StatusFailed, tree.AggregateStatus("parent"))
+
+	// A running descendant takes precedence over failures
+	workers["child1"].Status = StatusRunning
+	assert.Equal(t, StatusRunning, tree.AggregateStatus("parent"))
+
+	assert.Equal(t, WorkerStatus(""), tree.AggregateStatus("missing"))
+}
+
+func TestHierarchy_ChildStatusCounts(t *testing.T) {
+	tree := NewHierarchy(testHierarchyWorkers())
+
+	counts := tree.ChildStatusCounts("parent")
+	assert.Equal(t, 1, counts[StatusCompleted])
+	assert.Equal(t, 1, counts[StatusStopped])
+}
+
+func TestManager_WorkerTreeOperations(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := NewManager(tmpDir)
+	stateFile := filepath.Join(tmpDir, "workers.json")
+
+	newTree := func() map[string]*Worker {
+		return map[string]*Worker{
+			"parent": {ID: "parent", ThreadID: "T-parent", PID: 999997, Started: time.Now(), Status: StatusRunning},
+			"child":  {ID: "child", ThreadID: "T-child", PID: 999998, Started: time.Now(), Status: StatusRunning, ParentID: "parent"},
+			"done":   {ID: "done", ThreadID: "T-done", PID: 999999, Started: time.Now(), Status: StatusCompleted, ParentID: "parent"},
+			"other":  {ID: "other", ThreadID: "T-other", PID: 999996, Started: time.Now(), Status: StatusRunning},
+		}
+	}
+
+	t.Run("stop", func(t *testing.T) {
+		require.NoError(t, manager.SaveWorkersForTest(newTree(), stateFile))
+
+		stopped, err := manager.StopWorkerTree("parent")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"parent", "child"}, stopped)
+
+		workers, err := manager.loadWorkers()
+		require.NoError(t, err)
+		assert.Equal(t, StatusStopped, workers["parent"].Status)
+		assert.Equal(t, StatusStopped, workers["child"].Status)
+		assert.Equal(t, StatusCompleted, workers["done"].Status)
+		assert.Equal(t, StatusRunning, workers["other"].Status)
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		require.NoError(t, manager.SaveWorkersForTest(newTree(), stateFile))
+
+		aborted, err := manager.AbortWorkerTree("parent")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"parent", "child"}, aborted)
+
+		workers, err := manager.loadWorkers()
+		require.NoError(t, err)
+		assert.Equal(t, StatusAborted, workers["child"].Status)
+		assert.Equal(t, StatusCompleted, workers["done"].Status)
+	})
+
+	t.Run("abort with nothing abortable", func(t *testing.T) {
+		require.NoError(t, manager.SaveWorkersForTest(newTree(), stateFile))
+
+		_, err := manager.AbortWorkerTree("done")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot abort")
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, manager.SaveWorkersForTest(newTree(), stateFile))
+
+		_, err := manager.DeleteWorkerTree("parent", false)
+		assert.ErrorIs(t, err, ErrWorkerRunning)
+		workers, err := manager.loadWorkers()
+		require.NoError(t, err)
+		assert.Len(t, workers, 4, "nothing is deleted while members are running")
+
+		deleted, err := manager.DeleteWorkerTree("parent", true)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"parent", "child", "done"}, deleted)
+
+		workers, err = manager.loadWorkers()
+		require.NoError(t, err)
+		assert.Len(t, workers, 1)
+		assert.Contains(t, workers, "other")
+	})
+
+	t.Run("delete without cascade detaches children", func(t *testing.T) {
+		tree := newTree()
+		tree["parent"].Status = StatusStopped
+		require.NoError(t, manager.SaveWorkersForTest(tree, stateFile))
+
+		require.NoError(t, manager.DeleteWorker("parent", false))
+
+		workers, err := manager.loadWorkers()
+		require.NoError(t, err)
+		assert.Empty(t, workers["child"].ParentID)
+		assert.Empty(t, workers["done"].ParentID)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := manager.StopWorkerTree("missing")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "not found")
+	})
+}
@@ -0,0 +1,195 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultHookTimeout bounds each hook command when no timeout is configured
+const DefaultHookTimeout = time.Minute
+
+// maxHookOutput is how much of a hook command's output, from its end, is kept
+// in the thread
+const maxHookOutput = 16 * 1024
+
+// HookEvent is a point in a task's lifecycle at which hook commands run
+type HookEvent string
+
+const (
+	HookPreStart     HookEvent = "pre-start"
+	HookPostComplete HookEvent = "post-complete"
+	HookPostFail     HookEvent = "post-fail"
+)
+
+// ErrPreStartHookFailed is returned when a pre-start hook command fails,
+// refusing the task
+var ErrPreStartHookFailed = errors.New("pre-start hook failed")
+
+// HooksConfig lists commands run at points in a task's lifecycle, e.g. to
+// check out a branch before amp starts or report the outcome when it exits
+type HooksConfig struct {
+	PreStart     []string      // Before a new task's amp thread is created; a failure refuses the task
+	PostComplete []string      // After a task's process exits and it is marked completed
+	PostFail     []string      // After a task's process exits and it is marked failed
+	Timeout      time.Duration // Per command; 0 uses DefaultHookTimeout
+	Dir          string        // Working directory; empty uses the daemon's
+}
+
+// hookResult is the outcome of one hook command
+type hookResult struct {
+	Command  string
+	ExitCode int
+	TimedOut bool
+	Error    string
+	Output   string
+}
+
+// hookOutput keeps the last maxHookOutput bytes written to it. Commands write
+// their stdout and stderr to the same hookOutput, which exec serializes.
+type hookOutput struct {
+	data      []byte
+	truncated bool
+}
+
+func (o *hookOutput) Write(p []byte) (int, error) {
+	o.data = append(o.data, p...)
+	if over := len(o.data) - maxHookOutput; over > 0 {
+		o.data = append(o.data[:0], o.data[over:]...)
+		o.truncated = true
+	}
+	return len(p), nil
+}
+
+// SetHooks sets the commands run at points in each task's lifecycle
+func (m *Manager) SetHooks(config HooksConfig) {
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultHookTimeout
+	}
+	m.hooks = config
+}
+
+// hookEnv returns the environment of a worker's hook commands
+func hookEnv(worker *Worker, event HookEvent) []string {
+	return append(os.Environ(),
+		"AMP_HOOK="+string(event),
+		"AMP_TASK_ID="+worker.ID,
+		"AMP_THREAD_ID="+worker.ThreadID,
+		"AMP_TASK_STATUS="+string(worker.Status),
+		"AMP_TASK_TITLE="+worker.Title,
+		"AMP_TASK_PROJECT="+worker.ProjectName(),
+		"AMP_TASK_OWNER="+worker.Owner,
+		"AMP_TASK_PRIORITY="+worker.Priority,
+		"AMP_TASK_TAGS="+strings.Join(worker.Tags, ","),
+	)
+}
+
+// runHook runs one hook command with the hook timeout
+func (m *Manager) runHook(command string, env []string) hookResult {
+	result := hookResult{Command: command}
+	var output hookOutput
+	var err error
+	result.ExitCode, result.TimedOut, err = runShellCommand(command, env, m.hooks.Dir, m.hooks.Timeout, &output)
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	result.Output = strings.TrimRight(string(output.data), "\n")
+	if output.truncated {
+		result.Output = "[earlier output truncated]\n" + result.Output
+	}
+	return result
+}
+
+// runPreStartHooks runs the pre-start hooks for a worker that is about to be
+// created, stopping at the first that fails. The results are returned so they
+// can be recorded in the worker's thread once it is saved.
+func (m *Manager) runPreStartHooks(worker *Worker) ([]hookResult, error) {
+	var results []hookResult
+	env := hookEnv(worker, HookPreStart)
+	for _, command := range m.hooks.PreStart {
+		result := m.runHook(command, env)
+		results = append(results, result)
+		if result.Error != "" {
+			return results, preStartError(result)
+		}
+	}
+	return results, nil
+}
+
+// preStartError describes a failed pre-start hook by its error and the last
+// line it printed, which is usually why it refused the task
+func preStartError(result hookResult) error {
+	reason := result.Error
+	lines := strings.Split(strings.TrimSpace(result.Output), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		reason += ": " + last
+	}
+	return fmt.Errorf("%w: %s: %s", ErrPreStartHookFailed, result.Command, reason)
+}
+
+// recordPreStartHooks records the output of a worker's pre-start hooks in
+// its thread once the worker has been saved
+func (m *Manager) recordPreStartHooks(workerID string, results []hookResult) {
+	for _, result := range results {
+		m.recordHook(workerID, HookPreStart, result)
+	}
+}
+
+// runExitHooks runs the post-complete or post-fail hooks for a worker whose
+// process has exited, recording their output in its thread
+func (m *Manager) runExitHooks(workerID string) {
+	if len(m.hooks.PostComplete) == 0 && len(m.hooks.PostFail) == 0 {
+		return
+	}
+
+	worker, err := m.findWorker(workerID)
+	if err != nil {
+		log.Printf("Failed to load worker %s for hooks: %v", workerID, err)
+		return
+	}
+
+	var event HookEvent
+	var commands []string
+	switch worker.Status {
+	case StatusCompleted:
+		event, commands = HookPostComplete, m.hooks.PostComplete
+	case StatusFailed:
+		event, commands = HookPostFail, m.hooks.PostFail
+	default:
+		return
+	}
+
+	env := append(hookEnv(worker, event), m.artifactsEnv(worker)...)
+	for _, command := range commands {
+		m.recordHook(workerID, event, m.runHook(command, env))
+	}
+}
+
+// recordHook adds the output of a hook command to the worker's thread as a
+// system message
+func (m *Manager) recordHook(workerID string, event HookEvent, result hookResult) {
+	content := fmt.Sprintf("%s hook: $ %s", event, result.Command)
+	if result.Output != "" {
+		content += "\n" + result.Output
+	}
+	metadata := map[string]interface{}{
+		"hook":      string(event),
+		"command":   result.Command,
+		"exit_code": result.ExitCode,
+	}
+	if result.Error != "" {
+		content += fmt.Sprintf("\n%s hook failed: %s", event, result.Error)
+		metadata["error"] = result.Error
+	}
+	if result.TimedOut {
+		metadata["timed_out"] = true
+	}
+
+	if err := m.AppendThreadMessage(workerID, MessageTypeSystem, content, metadata); err != nil {
+		log.Printf("Failed to record %s hook of worker %s: %v", event, workerID, err)
+	}
+}
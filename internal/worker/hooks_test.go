@@ -0,0 +1,90 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartWorker_PreStartHooks(t *testing.T) {
+	tmpDir := t.TempDir()
+	scriptPath := filepath.Join(tmpDir, "dummy-amp")
+	require.NoError(t, os.WriteFile(scriptPath, []byte("#!/bin/bash\necho T-hooks\n"), 0755))
+
+	manager := NewManager(tmpDir)
+	manager.SetAmpBinary(scriptPath)
+	manager.SetHooks(HooksConfig{
+		PreStart: []string{"echo preparing $AMP_HOOK $AMP_TASK_TITLE $AMP_TASK_PROJECT"},
+	})
+
+	worker, err := manager.StartWorkerWithOptions(context.Background(), "hello", StartOptions{Title: "Fix"})
+	require.NoError(t, err)
+
+	messages, err := manager.GetThreadMessages(worker.ID, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, MessageTypeSystem, messages[0].Type)
+	assert.Equal(t, "pre-start hook: $ echo preparing $AMP_HOOK $AMP_TASK_TITLE $AMP_TASK_PROJECT\npreparing pre-start Fix default", messages[0].Content)
+	assert.Equal(t, "pre-start", messages[0].Metadata["hook"])
+
+	// A failing hook refuses the task, and later hooks don't run
+	marker := filepath.Join(tmpDir, "ran")
+	manager = NewManager(t.TempDir())
+	manager.SetAmpBinary(scriptPath)
+	manager.SetHooks(HooksConfig{
+		PreStart: []string{"echo checking", "echo branch is locked; exit 2", "touch " + marker},
+	})
+	_, err = manager.StartWorkerWithOptions(context.Background(), "hello", StartOptions{})
+	assert.ErrorIs(t, err, ErrPreStartHookFailed)
+	assert.ErrorContains(t, err, "exit status 2: branch is locked")
+	assert.NoFileExists(t, marker)
+
+	workers, err := manager.ListWorkers()
+	require.NoError(t, err)
+	assert.Empty(t, workers)
+}
+
+func TestRunExitHooks(t *testing.T) {
+	manager, _ := setupCleanupWorker(t)
+	manager.SetHooks(HooksConfig{
+		PostComplete: []string{"echo never"},
+		PostFail:     []string{"echo failed $AMP_TASK_ID $AMP_TASK_STATUS", "exit 3", "sleep 10"},
+		Timeout:      200 * time.Millisecond,
+	})
+
+	// Stopped tasks run neither
+	manager.runExitHooks("done")
+	messages, err := manager.GetThreadMessages("done", 0, 0)
+	require.NoError(t, err)
+	assert.Empty(t, messages)
+
+	workers, err := manager.loadWorkers()
+	require.NoError(t, err)
+	workers["done"].Status = StatusFailed
+	require.NoError(t, manager.saveWorkers(workers))
+
+	manager.runExitHooks("done")
+	messages, err = manager.GetThreadMessages("done", 0, 0)
+	require.NoError(t, err)
+	require.Len(t, messages, 3)
+	assert.Equal(t, "post-fail hook: $ echo failed $AMP_TASK_ID $AMP_TASK_STATUS\nfailed done failed", messages[0].Content)
+	assert.Equal(t, "post-fail hook: $ exit 3\npost-fail hook failed: exit status 3", messages[1].Content)
+	assert.Equal(t, float64(3), messages[1].Metadata["exit_code"])
+	assert.Equal(t, true, messages[2].Metadata["timed_out"])
+}
+
+func TestHookOutput_KeepsEnd(t *testing.T) {
+	var output hookOutput
+	output.Write([]byte(strings.Repeat("a", maxHookOutput)))
+	output.Write([]byte("end"))
+
+	assert.True(t, output.truncated)
+	assert.Len(t, output.data, maxHookOutput)
+	assert.True(t, strings.HasSuffix(string(output.data), "aend"))
+}
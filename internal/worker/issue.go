@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IssueLink ties a worker to the GitHub or Jira issue it was created from, so
+// changes to the issue can be synced onto the task
+type IssueLink struct {
+	Provider string   `json:"provider"` // "github" or "jira"
+	Key      string   `json:"key"`      // e.g. "acme/api#42" or "OPS-17"
+	URL      string   `json:"url,omitempty"`
+	Tags     []string `json:"tags,omitempty"` // Tags last applied from the issue, replaced on the next sync
+}
+
+// IssueFields are the task fields derived from an issue
+type IssueFields struct {
+	Priority string   // Empty leaves the task's priority unchanged
+	Tags     []string // Replace the tags applied by the previous sync
+}
+
+// matches reports whether the link refers to the given issue
+func (l *IssueLink) matches(provider, key string) bool {
+	return l != nil && strings.EqualFold(l.Provider, provider) && strings.EqualFold(l.Key, key)
+}
+
+// applyIssueFields sets a linked worker's priority and swaps the tags that
+// came from its issue, keeping tags added by other means
+func applyIssueFields(worker *Worker, fields IssueFields) {
+	if fields.Priority != "" {
+		worker.Priority = fields.Priority
+	}
+
+	previous := make(map[string]bool, len(worker.Issue.Tags))
+	for _, tag := range worker.Issue.Tags {
+		previous[tag] = true
+	}
+
+	tags := []string{}
+	seen := make(map[string]bool)
+	for _, tag := range worker.Tags {
+		if !previous[tag] && !seen[tag] {
+			tags = append(tags, tag)
+			seen[tag] = true
+		}
+	}
+	for _, tag := range fields.Tags {
+		if !seen[tag] {
+			tags = append(tags, tag)
+			seen[tag] = true
+		}
+	}
+
+	worker.Tags = tags
+	worker.Issue.Tags = fields.Tags
+}
+
+// SyncIssue applies an issue's current fields to every worker linked to it,
+// returning the IDs of the workers updated
+func (m *Manager) SyncIssue(provider, key string, fields IssueFields) ([]string, error) {
+	updated := []string{}
+	err := m.updateWorkers(func(workers map[string]*Worker) error {
+		for id, worker := range workers {
+			if worker.Issue.matches(provider, key) {
+				applyIssueFields(worker, fields)
+				updated = append(updated, id)
+			}
+		}
+		if len(updated) == 0 {
+			return errUnchanged
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update worker state: %w", err)
+	}
+	return updated, nil
+}
@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyIssueFields_ReplacesPreviousIssueTags(t *testing.T) {
+	worker := &Worker{
+		Priority: "low",
+		Tags:     []string{"manual", "type:bug"},
+		Issue:    &IssueLink{Provider: "github", Key: "acme/api#1", Tags: []string{"type:bug"}},
+	}
+
+	applyIssueFields(worker, IssueFields{Priority: "high", Tags: []string{"area:api", "manual"}})
+
+	assert.Equal(t, "high", worker.Priority)
+	assert.Equal(t, []string{"manual", "area:api"}, worker.Tags)
+	assert.Equal(t, []string{"area:api", "manual"}, worker.Issue.Tags)
+
+	// An issue without a mapped priority leaves the task's priority alone
+	applyIssueFields(worker, IssueFields{Tags: []string{}})
+	assert.Equal(t, "high", worker.Priority)
+	assert.Equal(t, []string{}, worker.Tags)
+}
+
+func TestSyncIssue_UpdatesLinkedWorkers(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := NewManager(tmpDir)
+	workers := map[string]*Worker{
+		"linked":   {ID: "linked", Started: time.Now(), Status: StatusRunning, Issue: &IssueLink{Provider: "jira", Key: "OPS-17"}},
+		"other":    {ID: "other", Started: time.Now(), Status: StatusRunning, Issue: &IssueLink{Provider: "jira", Key: "OPS-18"}},
+		"unlinked": {ID: "unlinked", Started: time.Now(), Status: StatusRunning},
+	}
+	require.NoError(t, manager.SaveWorkersForTest(workers, filepath.Join(tmpDir, "workers.json")))
+
+	updated, err := manager.SyncIssue("Jira", "ops-17", IssueFields{Priority: "high", Tags: []string{"incident"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"linked"}, updated)
+
+	linked := findTestWorker(t, manager, "linked")
+	assert.Equal(t, "high", linked.Priority)
+	assert.Equal(t, []string{"incident"}, linked.Tags)
+	assert.Empty(t, findTestWorker(t, manager, "other").Priority)
+
+	updated, err = manager.SyncIssue("github", "acme/api#1", IssueFields{Priority: "low"})
+	require.NoError(t, err)
+	assert.Empty(t, updated)
+}
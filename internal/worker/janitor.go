@@ -0,0 +1,171 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+)
+
+// VanishedReason is the status reason of workers whose process disappeared
+// without its exit being seen, e.g. after a power loss, an OOM kill or while
+// the daemon wasn't running
+const VanishedReason = "Process vanished"
+
+// JanitorPolicy configures the janitor
+type JanitorPolicy struct {
+	CheckInterval time.Duration // How often workers are checked
+	MaxAge        time.Duration // Finished workers are deleted this long after they stop running; 0 keeps them
+}
+
+// JanitorReport lists what one janitor pass collected
+type JanitorReport struct {
+	Finalized []string // IDs of workers whose process vanished, now marked stopped
+	Pruned    []string // IDs of workers deleted for being older than MaxAge
+	Purged    []string // IDs of workers purged from the trash after its retention
+}
+
+// Janitor periodically finalizes workers whose process vanished, deletes
+// workers that finished long ago and empties the trash of expired workers
+type Janitor struct {
+	manager *Manager
+	policy  JanitorPolicy
+	wake    chan struct{} // Runs a pass before the next check interval
+}
+
+// NewJanitor creates a janitor for the manager's workers
+func NewJanitor(manager *Manager, policy JanitorPolicy) *Janitor {
+	if policy.CheckInterval <= 0 {
+		policy.CheckInterval = time.Minute
+	}
+	return &Janitor{manager: manager, policy: policy, wake: make(chan struct{}, 1)}
+}
+
+// Trigger makes a running janitor collect now rather than at its next check
+// interval. It doesn't block; triggers while a pass is pending are merged.
+func (j *Janitor) Trigger() {
+	select {
+	case j.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run collects dead workers once, then every check interval and whenever
+// triggered until the context is cancelled
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.policy.CheckInterval)
+	defer ticker.Stop()
+
+	now := time.Now()
+	for {
+		if _, err := j.Collect(now); err != nil {
+			log.Printf("Janitor failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case now = <-ticker.C:
+		case <-j.wake:
+			now = time.Now()
+		}
+	}
+}
+
+// Collect finalizes every running worker whose process is gone without a
+// monitor to report its exit, handling the exit as the monitor would, then
+// deletes the finished workers older than the policy's MaxAge and purges the
+// workers trashed longer ago than the manager's trash retention
+func (j *Janitor) Collect(now time.Time) (JanitorReport, error) {
+	var report JanitorReport
+
+	finalized, err := j.manager.finalizeVanished()
+	if err != nil {
+		return report, err
+	}
+	report.Finalized = finalized
+
+	if j.policy.MaxAge > 0 {
+		pruned, err := j.manager.pruneWorkers(now.Add(-j.policy.MaxAge))
+		if err != nil {
+			return report, err
+		}
+		report.Pruned = pruned
+	}
+
+	if retention := j.manager.trashRetention; retention > 0 {
+		purged, err := j.manager.purgeTrash(now.Add(-retention))
+		if err != nil {
+			return report, err
+		}
+		report.Purged = purged
+	}
+	return report, nil
+}
+
+// finalizeVanished marks stopped the running workers whose process is gone and
+// isn't being waited for, then runs their exit handling, returning their IDs
+func (m *Manager) finalizeVanished() ([]string, error) {
+	var vanished []string
+	err := m.updateWorkers(func(workers map[string]*Worker) error {
+		for id, worker := range workers {
+			if worker.Status != StatusRunning {
+				continue
+			}
+			if _, monitored := m.monitored.Load(id); monitored || m.checkProcessStatus(worker) {
+				continue
+			}
+			worker.setStatus(StatusStopped, ActorJanitor)
+			worker.StatusReason = VanishedReason
+			worker.endAttempt(0, nil)
+			vanished = append(vanished, id)
+		}
+		if len(vanished) == 0 {
+			return errUnchanged
+		}
+		return nil
+	})
+	if err != nil || len(vanished) == 0 {
+		return nil, err
+	}
+	sort.Strings(vanished)
+	for _, id := range vanished {
+		log.Printf("Process of worker %s vanished, marked as stopped", id)
+		m.afterExit(id, m.handleWorkerExit)
+	}
+	return vanished, nil
+}
+
+// pruneWorkers deletes the workers that stopped running before cutoff,
+// returning their IDs
+func (m *Manager) pruneWorkers(cutoff time.Time) ([]string, error) {
+	workers, err := m.loadWorkers()
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []string
+	for id, worker := range workers {
+		if worker.Status == StatusRunning {
+			continue
+		}
+		if _, winding := m.windDowns.Load(id); winding {
+			continue
+		}
+		finished := worker.Started
+		if worker.Finished != nil {
+			finished = *worker.Finished
+		}
+		if !finished.Before(cutoff) {
+			continue
+		}
+
+		if err := m.DeleteWorker(id, false); err != nil {
+			log.Printf("Failed to prune worker %s: %v", id, err)
+			continue
+		}
+		pruned = append(pruned, id)
+	}
+	sort.Strings(pruned)
+	return pruned, nil
+}
@@ -0,0 +1,127 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJanitor_FinalizesVanishedWorkers(t *testing.T) {
+	manager := NewManager(t.TempDir())
+	var mu sync.Mutex
+	var exited []string
+	manager.SetExitCallback(func(workerID string) {
+		mu.Lock()
+		defer mu.Unlock()
+		exited = append(exited, workerID)
+	})
+
+	started := time.Now().Add(-time.Hour)
+	require.NoError(t, manager.saveWorkers(map[string]*Worker{
+		"vanished":  {ID: "vanished", PID: 999997, Started: started, Status: StatusRunning},
+		"alive":     {ID: "alive", PID: os.Getpid(), Started: started, Status: StatusRunning},
+		"monitored": {ID: "monitored", PID: 999998, Started: started, Status: StatusRunning},
+		"stopped":   {ID: "stopped", PID: 999996, Started: started, Status: StatusStopped},
+	}))
+	manager.monitored.Store("monitored", true)
+
+	// Listing shows the worker stopped but leaves finalizing it to the janitor
+	workers, err := manager.ListWorkers()
+	require.NoError(t, err)
+	for _, worker := range workers {
+		if worker.ID == "vanished" {
+			assert.Equal(t, StatusStopped, worker.Status)
+		}
+	}
+	saved, err := manager.loadWorkers()
+	require.NoError(t, err)
+	assert.Equal(t, StatusRunning, saved["vanished"].Status)
+
+	janitor := NewJanitor(manager, JanitorPolicy{})
+	report, err := janitor.Collect(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vanished"}, report.Finalized)
+	assert.Empty(t, report.Pruned)
+	assert.Equal(t, []string{"vanished"}, exited)
+
+	saved, err = manager.loadWorkers()
+	require.NoError(t, err)
+	assert.Equal(t, StatusStopped, saved["vanished"].Status)
+	assert.Equal(t, VanishedReason, saved["vanished"].StatusReason)
+	assert.NotNil(t, saved["vanished"].Finished)
+	assert.Equal(t, StatusRunning, saved["alive"].Status)
+	assert.Equal(t, StatusRunning, saved["monitored"].Status)
+
+	// Finalized workers aren't reported again
+	report, err = janitor.Collect(time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, report.Finalized)
+	assert.Len(t, exited, 1)
+}
+
+func TestJanitor_PrunesOldWorkers(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := NewManager(tmpDir)
+
+	now := time.Now()
+	longAgo := now.Add(-48 * time.Hour)
+	recently := now.Add(-time.Hour)
+	oldLog := filepath.Join(tmpDir, "worker-old.log")
+	require.NoError(t, os.WriteFile(oldLog, []byte("done\n"), 0644))
+
+	require.NoError(t, manager.saveWorkers(map[string]*Worker{
+		"old":        {ID: "old", PID: 999997, Started: longAgo, Finished: &longAgo, Status: StatusCompleted, LogFile: oldLog},
+		"unfinished": {ID: "unfinished", PID: 999996, Started: longAgo, Status: StatusFailed},
+		"recent":     {ID: "recent", PID: 999995, Started: longAgo, Finished: &recently, Status: StatusStopped},
+		"running":    {ID: "running", PID: os.Getpid(), Started: longAgo, Status: StatusRunning},
+		"child":      {ID: "child", PID: 999994, Started: now, Finished: &recently, Status: StatusStopped, ParentID: "old"},
+	}))
+
+	// Without a maximum age nothing is pruned
+	report, err := NewJanitor(manager, JanitorPolicy{}).Collect(now)
+	require.NoError(t, err)
+	assert.Empty(t, report.Pruned)
+
+	report, err = NewJanitor(manager, JanitorPolicy{MaxAge: 24 * time.Hour}).Collect(now)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"old", "unfinished"}, report.Pruned)
+
+	saved, err := manager.loadWorkers()
+	require.NoError(t, err)
+	assert.NotContains(t, saved, "old")
+	assert.NotContains(t, saved, "unfinished")
+	assert.Contains(t, saved, "recent")
+	assert.Contains(t, saved, "running")
+	assert.Empty(t, saved["child"].ParentID)
+	assert.NoFileExists(t, oldLog)
+}
+
+func TestJanitor_Trigger(t *testing.T) {
+	manager := NewManager(t.TempDir())
+	started := time.Now().Add(-time.Hour)
+	janitor := NewJanitor(manager, JanitorPolicy{CheckInterval: time.Hour})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go janitor.Run(ctx)
+
+	// The first pass runs on startup; a vanished worker saved afterwards is
+	// only collected when the janitor is triggered
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, manager.saveWorkers(map[string]*Worker{
+		"vanished": {ID: "vanished", PID: 999997, Started: started, Status: StatusRunning},
+	}))
+	janitor.Trigger()
+	janitor.Trigger()
+
+	assert.Eventually(t, func() bool {
+		saved, err := manager.loadWorkers()
+		return err == nil && saved["vanished"].Status == StatusStopped
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,146 @@
+package worker
+
+import (
+	"bufio"
+	"io"
+	"sync/atomic"
+)
+
+// DefaultMaxLineSize is the longest log line, in bytes, kept before truncation
+const DefaultMaxLineSize = 1024 * 1024
+
+// TruncationMarker is appended to log lines cut short at the maximum line size
+const TruncationMarker = " ... [truncated]"
+
+// LineLimit is the longest log line, in bytes, that line readers keep. The
+// readers sharing a limit count the lines they truncate in it.
+type LineLimit struct {
+	MaxSize   int
+	truncated atomic.Uint64
+}
+
+// Truncated returns how many lines readers using the limit have truncated
+func (l *LineLimit) Truncated() uint64 {
+	return l.truncated.Load()
+}
+
+// LineReader reads newline-terminated lines like bufio.Scanner, but instead of
+// failing on lines longer than its maximum it keeps the first maxLineSize
+// bytes, discards the rest in chunks and marks the line as truncated. Memory
+// use is bounded by the maximum line size however long the input lines are.
+type LineReader struct {
+	reader     *bufio.Reader
+	max        int
+	limit      *LineLimit
+	onTruncate func(length int)
+
+	line      []byte
+	truncated bool
+	err       error
+}
+
+// NewLineReader creates a line reader that truncates lines longer than
+// limit.MaxSize bytes and counts them in limit. A nil limit or non-positive
+// size uses DefaultMaxLineSize.
+func NewLineReader(r io.Reader, limit *LineLimit) *LineReader {
+	reader := &LineReader{
+		reader: bufio.NewReaderSize(r, 64*1024),
+		max:    DefaultMaxLineSize,
+	}
+	if limit != nil {
+		if limit.MaxSize > 0 {
+			reader.max = limit.MaxSize
+		}
+		reader.limit = limit
+	}
+	return reader
+}
+
+// OnTruncate sets a callback invoked with the original length of each truncated line
+func (lr *LineReader) OnTruncate(callback func(length int)) {
+	lr.onTruncate = callback
+}
+
+// Scan advances to the next line, returning false at the end of the input or
+// on a read error
+func (lr *LineReader) Scan() bool {
+	if lr.err != nil {
+		return false
+	}
+
+	lr.line = lr.line[:0]
+	lr.truncated = false
+
+	length := 0
+	newline := false
+	var last byte
+	for {
+		chunk, err := lr.reader.ReadSlice('\n')
+		if n := len(chunk); n > 0 && chunk[n-1] == '\n' {
+			newline = true
+			chunk = chunk[:n-1]
+		}
+		if len(chunk) > 0 {
+			last = chunk[len(chunk)-1]
+		}
+		length += len(chunk)
+
+		if room := lr.max - len(lr.line); room > 0 {
+			if len(chunk) > room {
+				chunk = chunk[:room]
+			}
+			lr.line = append(lr.line, chunk...)
+		}
+
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			lr.err = err
+			if length == 0 && !newline {
+				return false
+			}
+		}
+		break
+	}
+
+	// Drop the carriage return of a CRLF line ending
+	if last == '\r' && length > 0 {
+		length--
+		if len(lr.line) > length {
+			lr.line = lr.line[:length]
+		}
+	}
+
+	if length > lr.max {
+		lr.truncated = true
+		if lr.limit != nil {
+			lr.limit.truncated.Add(1)
+		}
+		if lr.onTruncate != nil {
+			lr.onTruncate(length)
+		}
+	}
+	return true
+}
+
+// Text returns the current line, ending with TruncationMarker if it was truncated
+func (lr *LineReader) Text() string {
+	if lr.truncated {
+		return string(lr.line) + TruncationMarker
+	}
+	return string(lr.line)
+}
+
+// Truncated reports whether the current line was longer than the maximum
+func (lr *LineReader) Truncated() bool {
+	return lr.truncated
+}
+
+// Err returns the first non-EOF error encountered while reading
+func (lr *LineReader) Err() error {
+	if lr.err == io.EOF {
+		return nil
+	}
+	return lr.err
+}
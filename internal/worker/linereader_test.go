@@ -0,0 +1,59 @@
+package worker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAll(t *testing.T, reader *LineReader) []string {
+	t.Helper()
+	var lines []string
+	for reader.Scan() {
+		lines = append(lines, reader.Text())
+	}
+	require.NoError(t, reader.Err())
+	return lines
+}
+
+func TestLineReader_Lines(t *testing.T) {
+	reader := NewLineReader(strings.NewReader("one\r\n\ntwo\nthree"), nil)
+	assert.Equal(t, []string{"one", "", "two", "three"}, readAll(t, reader))
+}
+
+func TestLineReader_TruncatesLongLines(t *testing.T) {
+	// Longer than the internal read buffer so the line is discarded in chunks
+	long := strings.Repeat("a", 200*1024)
+	input := "short\n" + long + "\nexact\r\n" + long
+
+	var truncated []int
+	limit := &LineLimit{MaxSize: 5}
+	reader := NewLineReader(strings.NewReader(input), limit)
+	reader.OnTruncate(func(length int) {
+		truncated = append(truncated, length)
+	})
+
+	lines := readAll(t, reader)
+	assert.Equal(t, []string{
+		"short",
+		"aaaaa" + TruncationMarker,
+		"exact",
+		"aaaaa" + TruncationMarker,
+	}, lines)
+	assert.Equal(t, []int{len(long), len(long)}, truncated)
+	assert.Equal(t, uint64(2), limit.Truncated())
+}
+
+func TestManager_LineLimitCountsTruncation(t *testing.T) {
+	manager := NewManager(t.TempDir())
+	manager.SetMaxLineSize(4)
+
+	lines := readAll(t, NewLineReader(strings.NewReader("ok\ntoo long\n"), manager.LineLimit()))
+	assert.Equal(t, []string{"ok", "too " + TruncationMarker}, lines)
+
+	stats := manager.LogStats()
+	assert.Equal(t, 4, stats.MaxLineSize)
+	assert.Equal(t, uint64(1), stats.TruncatedLines)
+}
@@ -1,9 +1,8 @@
 package worker
 
 import (
-	"bufio"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -11,12 +10,33 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"text/template"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+var (
+	// ErrWorkerRunning is returned when deleting a running worker without force
+	ErrWorkerRunning = errors.New("worker is running")
+
+	// ErrWorkerNotFound is returned when no worker has the given ID
+	ErrWorkerNotFound = errors.New("worker not found")
+
+	// ErrParentNotFound is returned when a new worker's parent doesn't exist
+	ErrParentNotFound = errors.New("parent worker not found")
+
+	// ErrNotRunning is returned when a message is sent to a worker that isn't
+	// running
+	ErrNotRunning = errors.New("worker is not running")
+
+	// ErrInvalidTransition is returned when a worker's status doesn't allow
+	// the requested change
+	ErrInvalidTransition = errors.New("invalid status transition")
+)
+
 type Manager struct {
 	logDir        string
 	stateFile     string
@@ -28,6 +48,44 @@ type Manager struct {
 	tailersMu     sync.RWMutex          // Protects tailers map
 	threadStorage *ThreadStorage        // Thread message storage
 	processedWorkers map[string]bool    // Track which workers have had final processing
+	limiter       *RateLimiter          // Limits amp invocations; nil means unlimited
+	stateMu       sync.RWMutex          // Held for writing across each read-modify-write of the state file
+	lineLimit     LineLimit             // Longest log line kept; counts the lines truncated
+	threadIDFormat ThreadIDFormat       // Validates thread IDs returned by amp
+	cleanup       CleanupConfig         // Commands run after a worker's process exits
+	hooks         HooksConfig           // Commands run at points in a worker's lifecycle
+	defaultExecution ExecutionMode      // Where workers run unless they ask otherwise
+	container     ContainerConfig       // Container used for container execution
+	agents        AgentPool             // Remote agents for remote execution; nil disables it
+	projectsMu    sync.Mutex            // Serializes changes to the saved projects
+	windDowns     sync.Map              // IDs of workers currently winding down
+	workspace     Workspace             // Checkout of workers whose project doesn't set one
+	commitMessage *template.Template    // Message of auto-commits; nil uses DefaultCommitMessage
+	store         ObjectStore           // Where idle workers' files are offloaded; nil disables offloading
+	offloadPolicy OffloadPolicy         // When workers' files are offloaded
+	offloadMu     sync.Mutex            // Serializes moving files to and from the store
+	ampOverrides  AmpOverrides          // amp binaries and flags tasks may choose
+	ampProfiles   map[string]AmpProfile // Credentials and endpoints tasks may select by name
+	agentClients  map[string]AgentClient // Agent backends other than amp tasks may select by name
+	ampModels     map[string][]string    // amp flags selecting each model tasks may choose
+	trashRetention time.Duration         // How long deleted workers stay in the trash; 0 deletes them immediately
+	trashMu       sync.Mutex             // Serializes changes to the trash
+	secrets       SecretSource          // Values of the secrets tasks reference; nil disables secrets
+	ampVersions   sync.Map              // Detected AmpVersion by amp executable path
+	halting       sync.Map              // IDs of workers whose process is being ended on request
+	monitored     sync.Map              // IDs of workers whose process is being waited for
+	onRestart     func(workerID string) // Callback when a worker is restarted by its restart policy
+	logDirQuota   int64                 // Bytes the log directory may use before starts are refused; 0 is unlimited
+	onOverQuota   func(QuotaEvent)      // Callback when the log directory first exceeds its quota
+	overQuota     atomic.Bool           // Whether the last quota check found the log directory over quota
+	queues        map[string][]*pendingContinue // Messages waiting for each busy worker; a worker is busy while it has an entry
+	queuesMu      sync.Mutex            // Protects queues
+	onContinue    func(workerID string, attempt Attempt) // Callback when amp has answered a message sent to a worker
+	pools         map[string]Pool       // Limits and settings tasks may select by name
+	maxWorkers    int                   // Workers running at once across every pool; 0 is unlimited
+	reserved      int                   // Slots taken by workers still starting
+	poolReserved  map[string]int        // Slots of each pool taken by workers still starting
+	poolsMu       sync.Mutex            // Protects reserved and poolReserved
 }
 
 func NewManager(logDir string) *Manager {
@@ -48,9 +106,45 @@ func NewManager(logDir string) *Manager {
 		tailers:       make(map[string]*LogTailerWithParser),
 		threadStorage: NewThreadStorage(filepath.Join(logDir, "threads")),
 		processedWorkers: make(map[string]bool),
+		lineLimit:     LineLimit{MaxSize: DefaultMaxLineSize},
+		threadIDFormat: DefaultThreadIDFormat(),
+	}
+}
+
+// SetAmpBinary sets the path of the amp executable used to launch workers
+func (m *Manager) SetAmpBinary(path string) {
+	m.ampBinaryPath = path
+}
+
+// SetMaxLineSize sets the longest log line, in bytes, read before truncation
+func (m *Manager) SetMaxLineSize(size int) {
+	m.lineLimit.MaxSize = size
+}
+
+// LineLimit returns the limit log lines are read with, which counts every
+// line the manager's readers truncate
+func (m *Manager) LineLimit() *LineLimit {
+	return &m.lineLimit
+}
+
+// LogStats reports how log lines have been read
+func (m *Manager) LogStats() LogStats {
+	return LogStats{
+		MaxLineSize:    m.lineLimit.MaxSize,
+		TruncatedLines: m.lineLimit.Truncated(),
 	}
 }
 
+// SetRateLimiter limits how often amp is invoked across all workers
+func (m *Manager) SetRateLimiter(limiter *RateLimiter) {
+	m.limiter = limiter
+}
+
+// RateLimitStats returns the state of the amp invocation rate limits
+func (m *Manager) RateLimitStats() []RateLimitStats {
+	return m.limiter.Stats()
+}
+
 // SetExitCallback sets the callback function to be called when a worker exits
 func (m *Manager) SetExitCallback(callback func(workerID string)) {
 	m.onWorkerExit = callback
@@ -66,25 +160,223 @@ func (m *Manager) SetThreadMessageCallback(callback func(workerID string, messag
 	m.onThreadMsg = callback
 }
 
+// StartOptions holds optional settings for a newly started worker
+type StartOptions struct {
+	ParentID    string         // Parent task ID when starting a subtask
+	Issue       *IssueLink     // Issue the task is created from
+	IssueFields IssueFields    // Applied to the task when Issue is set
+	Execution   ExecutionMode  // Where the worker runs; empty uses the project's or manager's default
+	Project     string         // Project the worker belongs to; empty uses the parent's or the default project
+	Owner       string         // User starting the worker
+	AutoCommit  bool           // Commit the workspace's changes when the worker's process exits
+	Restart     *RestartPolicy // When the worker's process is restarted after it exits; nil never restarts it
+	Backend     string         // Agent backend that runs the worker; empty uses amp
+	Model       string         // Model of the backend the worker uses; empty uses the backend's default
+	Pool        string         // Worker pool whose limit and settings apply; empty uses the default pool, if configured
+
+	// Task metadata, saved with the worker so it's never visible without it
+	Title       string
+	Description string
+	Priority    string   // Takes precedence over the issue's priority
+	Tags        []string // Kept alongside the tags applied from the issue
+
+	// How amp is run for the worker
+	AmpBinary string            // amp executable to run instead of the manager's; must be allow-listed
+	AmpArgs   []string          // Extra amp flags; each must be allow-listed
+	Profile   string            // amp profile whose environment the worker runs with; empty uses the pool's or the daemon's
+	Env       map[string]string // Variables added to the environment of the worker's amp invocations
+	Secrets   map[string]string // Secret names by the variable their values are added as
+}
+
 func (m *Manager) StartWorker(message string) error {
-	// Create new thread
-	threadID, err := m.createThread()
+	_, err := m.StartWorkerWithOptions(context.Background(), message, StartOptions{})
+	return err
+}
+
+// StartWorkerWithOptions starts a new worker and returns it once its state has
+// been saved. ctx bounds the wait for the rate limiter.
+func (m *Manager) StartWorkerWithOptions(ctx context.Context, message string, opts StartOptions) (*Worker, error) {
+	if err := m.checkQuota(); err != nil {
+		return nil, err
+	}
+
+	// Validate the parent before spending an amp thread on the child
+	projectName := opts.Project
+	if opts.ParentID != "" {
+		workers, err := m.loadWorkers()
+		if err != nil {
+			return nil, err
+		}
+		parent, exists := workers[opts.ParentID]
+		if !exists {
+			return nil, fmt.Errorf("%w: %s", ErrParentNotFound, opts.ParentID)
+		}
+		if projectName == "" {
+			projectName = parent.ProjectName()
+		}
+	}
+	if projectName == "" {
+		projectName = DefaultProject
+	}
+
+	project, err := m.GetProject(projectName)
+	if err != nil {
+		return nil, err
+	}
+
+	requested := opts.Execution
+	if requested == "" {
+		requested = project.Amp.Execution
+	}
+	execution, err := m.executionMode(requested)
+	if err != nil {
+		return nil, err
+	}
+
+	// Likewise check that an agent could take the worker
+	if execution == ExecutionRemote && !m.agents.Available() {
+		return nil, ErrNoAgentAvailable
+	}
+	if err := m.validateBackend(opts, execution); err != nil {
+		return nil, err
+	}
+	if err := m.ampOverrides.validate(opts.AmpBinary, opts.AmpArgs, execution); err != nil {
+		return nil, err
+	}
+	poolName, err := m.resolvePool(opts.Pool)
 	if err != nil {
-		return fmt.Errorf("failed to create thread: %w", err)
+		return nil, err
+	}
+	profile := opts.Profile
+	if profile == "" {
+		profile = m.pools[poolName].Profile
+	}
+	profileEnv, err := m.profileEnv(profile)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateEnv(opts.Env); err != nil {
+		return nil, err
+	}
+	if opts.Restart != nil {
+		if err := opts.Restart.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateEnv(opts.Secrets); err != nil {
+		return nil, err
+	}
+	if _, err := m.secretEnv(opts.Secrets); err != nil {
+		return nil, err
 	}
 
+	// Hold a slot until the worker is saved as running
+	release, err := m.reserveSlot(poolName, "")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	// Generate worker ID
 	workerID := uuid.New().String()[:8]
 
+	// Let pre-start hooks refuse the task before an amp thread is spent on it
+	preStart, err := m.runPreStartHooks(&Worker{
+		ID:       workerID,
+		Title:    opts.Title,
+		Tags:     opts.Tags,
+		Priority: opts.Priority,
+		Project:  project.Name,
+		Owner:    opts.Owner,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Create new thread, under the profile's account
+	backend := opts.Backend
+	if isAmp(backend) {
+		backend = ""
+	}
+	client, err := m.agentClient(backend, &Worker{})
+	if err != nil {
+		return nil, err
+	}
+	threadID, err := m.createThread(ctx, client, profileEnv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create thread: %w", err)
+	}
+
 	// Setup log files
-	stdoutLogFile := filepath.Join(m.logDir, fmt.Sprintf("worker-%s.log", workerID))
-	ampLogFile := filepath.Join(m.logDir, fmt.Sprintf("worker-%s-amp.log", workerID))
+	projectDir := m.projectDir(project.Name)
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create project directory: %w", err)
+	}
+	stdoutLogFile := filepath.Join(projectDir, fmt.Sprintf("worker-%s.log", workerID))
+	ampLogFile := filepath.Join(projectDir, fmt.Sprintf("worker-%s-amp.log", workerID))
+	if backend != "" {
+		// Only amp's log is parsed into thread messages
+		ampLogFile = ""
+	}
+
+	// Containers see the log directory at its absolute path
+	if execution == ExecutionContainer && ampLogFile != "" {
+		if abs, err := filepath.Abs(ampLogFile); err == nil {
+			ampLogFile = abs
+		}
+	}
 
-	// Create the command to pipe message to amp with internal logging and debug level
-	cmd := exec.Command("bash", "-c", fmt.Sprintf(
-		"echo %q | %s --log-file %s --log-level=debug threads continue %s",
-		message, m.ampBinaryPath, ampLogFile, threadID,
-	))
+	worker := &Worker{
+		ID:       workerID,
+		ThreadID: threadID,
+		LogFile:  stdoutLogFile,  // Keep the stdout log file in the worker struct
+		Status:   StatusRunning,
+		// Add amp log file path for internal use
+		AmpLogFile:  ampLogFile,
+		Title:       opts.Title,
+		Description: opts.Description,
+		Tags:        opts.Tags,
+		ParentID:    opts.ParentID,
+		Execution:   execution,
+		Project:     project.Name,
+		Owner:       opts.Owner,
+		AutoCommit:  opts.AutoCommit,
+		Restart:     opts.Restart,
+		Backend:     backend,
+		Model:       opts.Model,
+		AmpBinary:   opts.AmpBinary,
+		AmpArgs:     opts.AmpArgs,
+		Profile:     profile,
+		Pool:        poolName,
+		Env:         opts.Env,
+		Secrets:     opts.Secrets,
+	}
+	if opts.Issue != nil {
+		link := *opts.Issue
+		worker.Issue = &link
+		applyIssueFields(worker, opts.IssueFields)
+	}
+	if opts.Priority != "" {
+		worker.Priority = opts.Priority
+	}
+	worker.beginAttempt(AttemptStart)
+	worker.recordStatus("", StatusRunning, ActorAPI)
+
+	if execution == ExecutionRemote {
+		worker.Started = time.Now()
+		save := func() error { return m.saveWorker(worker) }
+		if err := m.startRemoteWorker(worker, save, message, true, "--log-level=debug", "threads", "continue", threadID); err != nil {
+			return nil, err
+		}
+		m.recordPreStartHooks(worker.ID, preStart)
+		return worker, nil
+	}
+
+	// Create the command to run the agent, with amp logging at debug level
+	cmd, err := m.continueCommand(worker, ampLogFile)
+	if err != nil {
+		return nil, err
+	}
 
 	// Set the process group ID so we can kill the entire group
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
@@ -92,78 +384,41 @@ func (m *Manager) StartWorker(message string) error {
 	// Capture both stdout and stderr to the stdout log file
 	stdoutLogFileHandle, err := os.Create(stdoutLogFile)
 	if err != nil {
-		return fmt.Errorf("failed to create stdout log file: %w", err)
+		return nil, fmt.Errorf("failed to create stdout log file: %w", err)
 	}
 
 	cmd.Stdout = stdoutLogFileHandle
 	cmd.Stderr = stdoutLogFileHandle
 
-	// Start the process
-	if err := cmd.Start(); err != nil {
+	// Start the process and send it the message
+	if err := startAmp(cmd, message); err != nil {
 		stdoutLogFileHandle.Close()
-		return fmt.Errorf("failed to start worker: %w", err)
+		return nil, fmt.Errorf("failed to start worker: %w", err)
 	}
 
-	worker := &Worker{
-		ID:       workerID,
-		ThreadID: threadID,
-		PID:      cmd.Process.Pid,
-		LogFile:  stdoutLogFile,  // Keep the stdout log file in the worker struct
-		Started:  time.Now(),
-		Status:   StatusRunning,
-		// Add amp log file path for internal use
-		AmpLogFile: ampLogFile,
-	}
+	worker.PID = cmd.Process.Pid
+	worker.Started = time.Now()
 
 	// Save worker state
 	if err := m.saveWorker(worker); err != nil {
 		// Kill the process if we can't save state
 		cmd.Process.Kill()
 		stdoutLogFileHandle.Close()
-		return fmt.Errorf("failed to save worker state: %w", err)
+		return nil, fmt.Errorf("failed to save worker state: %w", err)
 	}
 
-	// Start log tailer with amp parsing if callbacks are set
-	if m.onLogLine != nil || m.onThreadMsg != nil {
-		// Create thread message callback that stores and broadcasts
-		threadMsgCallback := func(message ThreadMessage) {
-			// Store the message
-			if err := m.threadStorage.AppendMessage(worker.ID, message); err != nil {
-				return
-			}
-			
-			// Broadcast the message if callback is set
-			if m.onThreadMsg != nil {
-				m.onThreadMsg(worker.ID, message)
-			}
-		}
-		
-		tailer := NewLogTailerWithParser(worker.AmpLogFile, worker.ID, m.onLogLine, threadMsgCallback)
-		if err := tailer.Start(context.Background()); err == nil {
-			m.tailersMu.Lock()
-			m.tailers[worker.ID] = tailer
-			m.tailersMu.Unlock()
-		}
-	}
+	m.recordPreStartHooks(worker.ID, preStart)
 
-	// Monitor the process in the background
-	m.MonitorWorkerExit(worker.ID, cmd, func(workerID string) {
-		// Stop log tailer when worker exits
-		m.stopLogTailer(workerID)
-		
-		// Call the exit callback if set
-		if m.onWorkerExit != nil {
-			m.onWorkerExit(workerID)
-		}
-	})
+	// Start log tailer with amp parsing if callbacks are set
+	m.startLogTailer(worker)
 
-	// Close stdout log file after starting monitoring
-	go func() {
+	// Monitor the process in the background, closing its log file once it exits
+	m.monitorExit(worker.ID, func() int {
 		defer stdoutLogFileHandle.Close()
-		cmd.Wait()
-	}()
+		return exitCode(cmd.Wait())
+	}, m.handleWorkerExit)
 
-	return nil
+	return worker, nil
 }
 
 func (m *Manager) StopWorker(workerID string) error {
@@ -174,28 +429,15 @@ func (m *Manager) StopWorker(workerID string) error {
 
 	worker, exists := workers[workerID]
 	if !exists {
-		return fmt.Errorf("worker %s not found", workerID)
+		return fmt.Errorf("%w: %s", ErrWorkerNotFound, workerID)
 	}
 
 	if worker.Status != StatusRunning {
-		return fmt.Errorf("worker %s is not running", workerID)
+		return fmt.Errorf("%w: %s", ErrNotRunning, workerID)
 	}
 
-	// Kill the process group to ensure we kill both bash and amp processes
-	// First try to kill the entire process group
-	if err := syscall.Kill(-worker.PID, syscall.SIGTERM); err != nil {
-		// If process group kill fails, try individual process
-		process, findErr := os.FindProcess(worker.PID)
-		if findErr != nil {
-			return fmt.Errorf("failed to find process %d: %w", worker.PID, findErr)
-		}
-
-		if err := process.Signal(syscall.SIGTERM); err != nil {
-			// Try SIGKILL if SIGTERM fails
-			if killErr := process.Kill(); killErr != nil {
-				return fmt.Errorf("failed to kill process %d: %w", worker.PID, killErr)
-			}
-		}
+	if err := m.terminateProcess(worker); err != nil {
+		return err
 	}
 
 	// Also try to kill any remaining amp processes for this thread
@@ -205,63 +447,171 @@ func (m *Manager) StopWorker(workerID string) error {
 	m.stopLogTailer(workerID)
 
 	// Update worker status
-	worker.Status = StatusStopped
-	workers[workerID] = worker
-
-	if err := m.saveWorkers(workers); err != nil {
+	_, err = m.updateWorker(workerID, func(worker *Worker) error {
+		worker.setStatus(StatusStopped, ActorAPI)
+		return nil
+	})
+	if err != nil {
 		return fmt.Errorf("failed to update worker state: %w", err)
 	}
 
 	return nil
 }
 
-func (m *Manager) ContinueWorker(workerID, message string) error {
-	workers, err := m.loadWorkers()
+// ContinueWorker sends message to a running worker's thread and waits until
+// amp has answered it. Messages sent while amp is still answering an earlier
+// one wait for it in the worker's queue. The message is dropped if ctx is done
+// before it's sent.
+func (m *Manager) ContinueWorker(ctx context.Context, workerID, message string) error {
+	return m.continueWorker(ctx, workerID, message, nil)
+}
+
+// QueueContinue queues message for a running worker's thread like
+// ContinueWorker, but returns the number of the attempt that will send it
+// without waiting for amp to answer. The continue callback runs once it has.
+func (m *Manager) QueueContinue(workerID, message string) (int, error) {
+	attempt, _, err := m.queueContinue(context.Background(), workerID, message, nil)
+	return attempt, err
+}
+
+// continueWorker queues message for a running worker's thread and waits until
+// amp has answered it, copying amp's response to tee when it isn't nil. It
+// stops waiting once ctx is done.
+func (m *Manager) continueWorker(ctx context.Context, workerID, message string, tee io.Writer) error {
+	_, done, err := m.queueContinue(ctx, workerID, message, tee)
 	if err != nil {
 		return err
 	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// queueContinue records the attempt that will send message to a running
+// worker and queues it, returning the attempt's number and the channel
+// receiving its result. ctx bounds the wait for the rate limiter.
+func (m *Manager) queueContinue(ctx context.Context, workerID, message string, tee io.Writer) (int, <-chan error, error) {
+	var attempt int
+	_, err := m.updateWorker(workerID, func(worker *Worker) error {
+		if worker.Status != StatusRunning {
+			return fmt.Errorf("%w: %s", ErrNotRunning, workerID)
+		}
+		attempt = worker.beginAttempt(AttemptContinue)
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return attempt, m.enqueueContinue(ctx, workerID, attempt, message, tee), nil
+}
+
+// sendContinue sends message to a running worker's thread as attempt and
+// waits for amp's response, copying it to tee when it isn't nil. It returns
+// amp's exit code, or -1 when amp couldn't be run. Nothing is sent once ctx is
+// done, and ctx bounds the wait for the rate limiter.
+func (m *Manager) sendContinue(ctx context.Context, workerID string, attempt int, message string, tee io.Writer) (int, error) {
+	// The caller stopped waiting while the message was queued
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	workers, err := m.loadWorkers()
+	if err != nil {
+		return -1, err
+	}
 
 	worker, exists := workers[workerID]
 	if !exists {
-		return fmt.Errorf("worker %s not found", workerID)
+		return -1, fmt.Errorf("%w: %s", ErrWorkerNotFound, workerID)
 	}
 
 	// Check if process is actually running
 	if worker.Status == StatusRunning && !m.checkProcessStatus(worker) {
-		worker.Status = StatusStopped
-		workers[workerID] = worker
-		m.saveWorkers(workers)
+		m.updateWorker(workerID, func(worker *Worker) error {
+			if worker.Status == StatusRunning {
+				worker.setStatus(StatusStopped, ActorExitMonitor)
+			}
+			return nil
+		})
+		return -1, fmt.Errorf("%w: %s", ErrNotRunning, workerID)
 	}
 
 	if worker.Status != StatusRunning {
-		return fmt.Errorf("worker %s is not running", workerID)
+		return -1, fmt.Errorf("%w: %s", ErrNotRunning, workerID)
+	}
+
+	// Fail before waiting if the worker's profile or secrets were removed
+	if _, err := m.workerEnv(worker); err != nil {
+		return -1, err
+	}
+
+	if err := m.limiter.Wait(ctx, InvocationContinue); err != nil {
+		return -1, err
+	}
+
+	// The attempt was recorded when it was queued, but only starts now
+	worker, err = m.updateWorker(workerID, func(worker *Worker) error {
+		if worker.Status != StatusRunning {
+			return fmt.Errorf("%w: %s", ErrNotRunning, workerID)
+		}
+		worker.restartAttempt(attempt)
+		return nil
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	if worker.Execution == ExecutionRemote {
+		wait, err := m.startRemote(worker, message, tee, false, "threads", "continue", worker.ThreadID)
+		if err != nil {
+			return -1, err
+		}
+		code := wait()
+		if code != 0 {
+			return code, fmt.Errorf("failed to continue worker: amp exited with code %d on agent %s", code, worker.Agent)
+		}
+		return 0, nil
 	}
 
 	// Send message to the thread and append output to existing log file
-	cmd := exec.Command("bash", "-c", fmt.Sprintf(
-		"echo %q | %s threads continue %s",
-		message, m.ampBinaryPath, worker.ThreadID,
-	))
+	cmd, err := m.continueCommand(worker, "")
+	if err != nil {
+		return -1, err
+	}
 
 	// Append to existing log file
 	logFile, err := os.OpenFile(worker.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		return fmt.Errorf("failed to open log file: %w", err)
+		return -1, fmt.Errorf("failed to open log file: %w", err)
 	}
 	defer logFile.Close()
 
 	cmd.Stdout = logFile
 	cmd.Stderr = logFile
+	if tee != nil {
+		cmd.Stdout = io.MultiWriter(logFile, tee)
+	}
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to continue worker: %w", err)
+	if err := startAmp(cmd, message); err != nil {
+		return -1, fmt.Errorf("failed to continue worker: %w", err)
+	}
+	if err := cmd.Wait(); err != nil {
+		return exitCode(err), fmt.Errorf("failed to continue worker: %w", err)
 	}
 
-	return nil
+	return 0, nil
 }
 
 // InterruptWorker interrupts a running worker with SIGINT
 func (m *Manager) InterruptWorker(workerID string) error {
+	return m.interruptWorker(workerID, ActorAPI)
+}
+
+// interruptWorker interrupts a running worker, recording actor as the cause
+func (m *Manager) interruptWorker(workerID string, actor StatusActor) error {
 	workers, err := m.loadWorkers()
 	if err != nil {
 		return err
@@ -269,29 +619,21 @@ func (m *Manager) InterruptWorker(workerID string) error {
 
 	worker, exists := workers[workerID]
 	if !exists {
-		return fmt.Errorf("worker %s not found", workerID)
+		return fmt.Errorf("%w: %s", ErrWorkerNotFound, workerID)
 	}
 
 	if !CanTransition(worker.Status, StatusInterrupted) {
-		return fmt.Errorf("cannot interrupt worker %s with status %s", workerID, worker.Status)
+		return fmt.Errorf("%w: cannot interrupt worker %s with status %s", ErrInvalidTransition, workerID, worker.Status)
 	}
 
-	// Send SIGINT to the process group
-	if err := syscall.Kill(-worker.PID, syscall.SIGINT); err != nil {
-		// If process group kill fails, try individual process
-		process, findErr := os.FindProcess(worker.PID)
-		if findErr == nil {
-			// Try to signal individual process, but don't fail if it doesn't work
-			process.Signal(syscall.SIGINT)
-		}
-		// Continue even if signaling fails - the process might already be dead
-	}
+	m.interruptProcess(worker)
 
 	// Update worker status
-	worker.Status = StatusInterrupted
-	workers[workerID] = worker
-
-	if err := m.saveWorkers(workers); err != nil {
+	_, err = m.updateWorker(workerID, func(worker *Worker) error {
+		worker.setStatus(StatusInterrupted, actor)
+		return nil
+	})
+	if err != nil {
 		return fmt.Errorf("failed to update worker state: %w", err)
 	}
 
@@ -307,23 +649,15 @@ func (m *Manager) AbortWorker(workerID string) error {
 
 	worker, exists := workers[workerID]
 	if !exists {
-		return fmt.Errorf("worker %s not found", workerID)
+		return fmt.Errorf("%w: %s", ErrWorkerNotFound, workerID)
 	}
 
 	if !CanTransition(worker.Status, StatusAborted) {
-		return fmt.Errorf("cannot abort worker %s with status %s", workerID, worker.Status)
+		return fmt.Errorf("%w: cannot abort worker %s with status %s", ErrInvalidTransition, workerID, worker.Status)
 	}
 
 	// Force kill the process group
-	if err := syscall.Kill(-worker.PID, syscall.SIGKILL); err != nil {
-		// If process group kill fails, try individual process
-		process, findErr := os.FindProcess(worker.PID)
-		if findErr == nil {
-			// Try to kill individual process, but don't fail if it doesn't work
-			process.Kill()
-		}
-		// Continue even if killing fails - the process might already be dead
-	}
+	m.forceKillProcess(worker)
 
 	// Kill any remaining amp processes for this thread
 	m.killAmpProcesses(worker.ThreadID)
@@ -332,18 +666,20 @@ func (m *Manager) AbortWorker(workerID string) error {
 	m.stopLogTailer(workerID)
 
 	// Update worker status
-	worker.Status = StatusAborted
-	workers[workerID] = worker
-
-	if err := m.saveWorkers(workers); err != nil {
+	_, err = m.updateWorker(workerID, func(worker *Worker) error {
+		worker.setStatus(StatusAborted, ActorAPI)
+		return nil
+	})
+	if err != nil {
 		return fmt.Errorf("failed to update worker state: %w", err)
 	}
 
 	return nil
 }
 
-// RetryWorker starts a new worker instance for the same thread
-func (m *Manager) RetryWorker(workerID, message string) error {
+// RetryWorker starts a new worker instance for the same thread. ctx bounds the
+// wait for the rate limiter.
+func (m *Manager) RetryWorker(ctx context.Context, workerID, message string) error {
 	workers, err := m.loadWorkers()
 	if err != nil {
 		return err
@@ -351,11 +687,57 @@ func (m *Manager) RetryWorker(workerID, message string) error {
 
 	worker, exists := workers[workerID]
 	if !exists {
-		return fmt.Errorf("worker %s not found", workerID)
+		return fmt.Errorf("%w: %s", ErrWorkerNotFound, workerID)
 	}
 
 	if !CanTransition(worker.Status, StatusRunning) {
-		return fmt.Errorf("cannot retry worker %s with status %s", workerID, worker.Status)
+		return fmt.Errorf("%w: cannot retry worker %s with status %s", ErrInvalidTransition, workerID, worker.Status)
+	}
+
+	_, err = m.relaunchWorker(ctx, worker, message, AttemptRetry, nil)
+	return err
+}
+
+// relaunchWorker starts a new amp process on the worker's existing thread.
+// Once it runs, the new process, attempt and status are saved onto the
+// current copy of the worker together with the caller's changes made by
+// update, when it isn't nil, and the saved worker is returned. action is
+// recorded as what started the attempt.
+func (m *Manager) relaunchWorker(ctx context.Context, worker *Worker, message string, action AttemptAction, update func(*Worker)) (*Worker, error) {
+	workerID := worker.ID
+	started := func() (*Worker, error) {
+		return m.updateWorker(workerID, func(saved *Worker) error {
+			if update != nil {
+				update(saved)
+			}
+			saved.beginAttempt(action)
+			saved.PID = worker.PID
+			saved.Agent = worker.Agent
+			saved.setStatus(StatusRunning, relaunchActor(action))
+			return nil
+		})
+	}
+
+	if _, err := m.workerEnv(worker); err != nil {
+		return nil, err
+	}
+
+	// A worker that isn't running takes a slot again
+	if worker.Status != StatusRunning {
+		release, err := m.reserveSlot(worker.Pool, workerID)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+
+	if err := m.limiter.Wait(ctx, InvocationContinue); err != nil {
+		return nil, err
+	}
+
+	// amp appends to the worker's logs and thread, so they must be local again
+	if err := m.restore(worker); err != nil {
+		return nil, err
 	}
 
 	// Ensure any old processes are cleaned up
@@ -363,11 +745,26 @@ func (m *Manager) RetryWorker(workerID, message string) error {
 		m.killAmpProcesses(worker.ThreadID)
 	}
 
+	// Forget requests to end an earlier process that had already exited
+	m.halting.Delete(workerID)
+
+	if worker.Execution == ExecutionRemote {
+		var saved *Worker
+		save := func() (err error) {
+			saved, err = started()
+			return err
+		}
+		if err := m.startRemoteWorker(worker, save, message, false, "threads", "continue", worker.ThreadID); err != nil {
+			return nil, err
+		}
+		return saved, nil
+	}
+
 	// Create the command to send message to the existing thread
-	cmd := exec.Command("bash", "-c", fmt.Sprintf(
-		"echo %q | %s threads continue %s",
-		message, m.ampBinaryPath, worker.ThreadID,
-	))
+	cmd, err := m.continueCommand(worker, "")
+	if err != nil {
+		return nil, err
+	}
 
 	// Set the process group ID so we can kill the entire group
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
@@ -375,107 +772,54 @@ func (m *Manager) RetryWorker(workerID, message string) error {
 	// Append to existing log file
 	logFile, err := os.OpenFile(worker.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		return fmt.Errorf("failed to open log file: %w", err)
+		return nil, fmt.Errorf("failed to open log file: %w", err)
 	}
 
 	cmd.Stdout = logFile
 	cmd.Stderr = logFile
 
-	// Start the process
-	if err := cmd.Start(); err != nil {
+	// Start the process and send it the message
+	if err := startAmp(cmd, message); err != nil {
 		logFile.Close()
-		return fmt.Errorf("failed to retry worker: %w", err)
+		return nil, fmt.Errorf("failed to retry worker: %w", err)
 	}
 
-	// Update worker with new PID and status
+	// Save the new process and status
 	worker.PID = cmd.Process.Pid
-	worker.Status = StatusRunning
-	workers[workerID] = worker
-
-	// Save worker state
-	if err := m.saveWorkers(workers); err != nil {
+	saved, err := started()
+	if err != nil {
 		// Kill the process if we can't save state
 		cmd.Process.Kill()
 		logFile.Close()
-		return fmt.Errorf("failed to save worker state: %w", err)
+		return nil, fmt.Errorf("failed to save worker state: %w", err)
 	}
 
 	// Start log tailer for both stdout and amp logs
-	if m.onLogLine != nil || m.onThreadMsg != nil {
-		// Thread message callback
-		threadMsgCallback := func(message ThreadMessage) {
-			// Append to thread storage
-			if err := m.threadStorage.AppendMessage(worker.ID, message); err != nil {
-				return
-			}
-			
-			// Broadcast the message if callback is set
-			if m.onThreadMsg != nil {
-				m.onThreadMsg(worker.ID, message)
-			}
-		}
-		
-		tailer := NewLogTailerWithParser(worker.AmpLogFile, worker.ID, m.onLogLine, threadMsgCallback)
-		if err := tailer.Start(context.Background()); err == nil {
-			m.tailersMu.Lock()
-			m.tailers[worker.ID] = tailer
-			m.tailersMu.Unlock()
-		}
-	}
+	m.startLogTailer(saved)
 
-	// Monitor the process in the background
-	m.MonitorWorkerExit(worker.ID, cmd, func(workerID string) {
-		// Stop log tailer when worker exits
-		m.stopLogTailer(workerID)
-		
-		// Call the exit callback if set
-		if m.onWorkerExit != nil {
-			m.onWorkerExit(workerID)
-		}
-	})
-
-	// Close log file after starting monitoring
-	go func() {
+	// Monitor the process in the background, closing the log file once it exits
+	m.monitorExit(workerID, func() int {
 		defer logFile.Close()
-		cmd.Wait()
-	}()
+		return exitCode(cmd.Wait())
+	}, m.handleWorkerExit)
 
-	return nil
+	return saved, nil
 }
 
 // UpdateWorkerMetadata updates the metadata fields of a worker
 func (m *Manager) UpdateWorkerMetadata(workerID string, title, description, priority *string, tags []string) error {
-	workers, err := m.loadWorkers()
-	if err != nil {
-		return err
-	}
-
-	worker, exists := workers[workerID]
-	if !exists {
-		return fmt.Errorf("worker %s not found", workerID)
-	}
-
-	// Update fields if provided
-	if title != nil {
-		worker.Title = *title
-	}
-	if description != nil {
-		worker.Description = *description
-	}
-	if priority != nil {
-		worker.Priority = *priority
-	}
-	if tags != nil {
-		worker.Tags = tags
-	}
-
-	// Save updated worker
-	workers[workerID] = worker
-	return m.saveWorkers(workers)
+	_, err := m.updateWorker(workerID, func(worker *Worker) error {
+		// Update fields if provided
+		MetadataUpdate{Title: title, Description: description, Priority: priority, Tags: tags}.apply(worker)
+		return nil
+	})
+	return err
 }
 
-// DeleteWorker removes a worker from the system
-func (m *Manager) DeleteWorker(workerID string) error {
+// DeleteWorker removes a worker, moving it to the trash when enabled and
+// otherwise deleting its files. Running workers are only deleted, after being
+// killed, when force is set.
+func (m *Manager) DeleteWorker(workerID string, force bool) error {
 	workers, err := m.loadWorkers()
 	if err != nil {
 		return err
@@ -483,18 +827,16 @@ func (m *Manager) DeleteWorker(workerID string) error {
 
 	worker, exists := workers[workerID]
 	if !exists {
-		return fmt.Errorf("worker %s not found", workerID)
+		return fmt.Errorf("%w: %s", ErrWorkerNotFound, workerID)
+	}
+	if worker.Status == StatusRunning && !force {
+		return fmt.Errorf("%w: %s", ErrWorkerRunning, workerID)
 	}
 
 	// If worker is running, stop it first
 	if worker.Status == StatusRunning {
 		// Kill the process if it's still running
-		if err := syscall.Kill(-worker.PID, syscall.SIGTERM); err != nil {
-			// Try individual process if group kill fails
-			if process, findErr := os.FindProcess(worker.PID); findErr == nil {
-				process.Kill()
-			}
-		}
+		m.terminateProcess(worker)
 		
 		// Kill any remaining amp processes
 		m.killAmpProcesses(worker.ThreadID)
@@ -503,108 +845,273 @@ func (m *Manager) DeleteWorker(workerID string) error {
 		m.stopLogTailer(workerID)
 	}
 
-	// Remove from workers map
-	delete(workers, workerID)
-	
-	// Clean up log file if it exists
-	if worker.LogFile != "" {
-		os.Remove(worker.LogFile)
+	if err := m.discardWorkers([]*Worker{worker}); err != nil {
+		return err
 	}
 
-	return m.saveWorkers(workers)
+	return m.updateWorkers(func(workers map[string]*Worker) error {
+		// Remove from workers map
+		delete(workers, workerID)
+
+		// Detach any children so they don't reference a missing parent
+		for _, w := range workers {
+			if w.ParentID == workerID {
+				w.ParentID = ""
+			}
+		}
+		return nil
+	})
 }
 
-func (m *Manager) ListWorkers() ([]*Worker, error) {
+// StopWorkerTree stops a worker and all of its running descendants with a
+// single state update, returning the IDs of the workers that were stopped
+func (m *Manager) StopWorkerTree(workerID string) ([]string, error) {
 	workers, err := m.loadWorkers()
 	if err != nil {
 		return nil, err
 	}
 
-	// Update status for all workers by checking actual process status
-	updated := false
-	for id, worker := range workers {
-		if worker.Status == StatusRunning && !m.checkProcessStatus(worker) {
-			worker.Status = StatusStopped
-			workers[id] = worker
-			updated = true
+	if _, exists := workers[workerID]; !exists {
+		return nil, fmt.Errorf("%w: %s", ErrWorkerNotFound, workerID)
+	}
+
+	var targets []*Worker
+	for _, member := range NewHierarchy(workers).Subtree(workerID) {
+		if member.Status == StatusRunning {
+			targets = append(targets, member)
 		}
 	}
 
-	// Save updated statuses if any changed
-	if updated {
-		m.saveWorkers(workers)
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNotRunning, workerID)
 	}
 
-	// Convert map to slice
-	result := make([]*Worker, 0, len(workers))
-	for _, worker := range workers {
-		result = append(result, worker)
+	// Keep stopping the others when one can't be, so the workers already
+	// stopped are saved as such
+	stopped := make([]string, 0, len(targets))
+	var errs []error
+	for _, target := range targets {
+		// Processes that already exited only need their status updated
+		if m.checkProcessStatus(target) {
+			if err := m.terminateProcess(target); err != nil {
+				errs = append(errs, fmt.Errorf("failed to stop worker %s: %w", target.ID, err))
+				continue
+			}
+		}
+		m.killAmpProcesses(target.ThreadID)
+		m.stopLogTailer(target.ID)
+		stopped = append(stopped, target.ID)
 	}
 
-	return result, nil
+	if len(stopped) > 0 {
+		if err := m.setWorkersStatus(stopped, StatusStopped); err != nil {
+			return nil, fmt.Errorf("failed to update worker state: %w", err)
+		}
+	}
+
+	return stopped, errors.Join(errs...)
 }
 
-// ListWorkersWithFilter returns workers with filtering and sorting options
-func (m *Manager) ListWorkersWithFilter(statusFilter []string, startedBefore, startedAfter *time.Time, sortBy, sortOrder string) ([]*Worker, error) {
-	allWorkers, err := m.ListWorkers()
+// AbortWorkerTree force-kills a worker and every descendant that can be
+// aborted with a single state update, returning the IDs of aborted workers.
+// Killing can't fail, so either every target is aborted or, when the state
+// can't be saved, none is.
+func (m *Manager) AbortWorkerTree(workerID string) ([]string, error) {
+	workers, err := m.loadWorkers()
 	if err != nil {
 		return nil, err
 	}
 
-	// Apply status filter
-	var filtered []*Worker
-	if len(statusFilter) > 0 {
-		statusSet := make(map[string]bool)
-		for _, status := range statusFilter {
-			statusSet[status] = true
+	worker, exists := workers[workerID]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrWorkerNotFound, workerID)
+	}
+
+	var targets []*Worker
+	for _, member := range NewHierarchy(workers).Subtree(workerID) {
+		if CanTransition(member.Status, StatusAborted) {
+			targets = append(targets, member)
 		}
-		
-		for _, worker := range allWorkers {
-			if statusSet[string(worker.Status)] {
-				filtered = append(filtered, worker)
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("%w: cannot abort worker %s with status %s", ErrInvalidTransition, workerID, worker.Status)
+	}
+
+	aborted := make([]string, 0, len(targets))
+	for _, target := range targets {
+		m.forceKillProcess(target)
+		m.killAmpProcesses(target.ThreadID)
+		m.stopLogTailer(target.ID)
+		aborted = append(aborted, target.ID)
+	}
+
+	if err := m.setWorkersStatus(aborted, StatusAborted); err != nil {
+		return nil, fmt.Errorf("failed to update worker state: %w", err)
+	}
+
+	return aborted, nil
+}
+
+// setWorkersStatus saves the status the API gave the workers with the given IDs
+// in a single update
+func (m *Manager) setWorkersStatus(workerIDs []string, status WorkerStatus) error {
+	return m.updateWorkers(func(workers map[string]*Worker) error {
+		for _, id := range workerIDs {
+			if worker, exists := workers[id]; exists {
+				worker.setStatus(status, ActorAPI)
 			}
 		}
-	} else {
-		filtered = allWorkers
+		return nil
+	})
+}
+
+// removeWorkerFiles deletes a deleted worker's logs, thread, artifacts and
+// offloaded objects
+func (m *Manager) removeWorkerFiles(worker *Worker) {
+	if worker.LogFile != "" {
+		os.Remove(worker.LogFile)
 	}
+	if worker.AmpLogFile != "" {
+		os.Remove(worker.AmpLogFile)
+	}
+	m.threads(worker.Project).Delete(worker.ID)
+	os.RemoveAll(m.artifactsDir(worker))
+	m.deleteObjects(worker.ID, worker.Offloaded)
+}
 
-	// Apply time filters
-	if startedBefore != nil || startedAfter != nil {
-		var timeFiltered []*Worker
-		for _, worker := range filtered {
-			if startedBefore != nil && worker.Started.After(*startedBefore) {
-				continue
+// DeleteWorkerTree removes a worker and all of its descendants with a single
+// state update. Running members are killed when force is set; otherwise
+// nothing is deleted. Members whose process can't be killed are kept, and the
+// others are still deleted.
+func (m *Manager) DeleteWorkerTree(workerID string, force bool) ([]string, error) {
+	workers, err := m.loadWorkers()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, exists := workers[workerID]; !exists {
+		return nil, fmt.Errorf("%w: %s", ErrWorkerNotFound, workerID)
+	}
+
+	members := NewHierarchy(workers).Subtree(workerID)
+	if !force {
+		for _, member := range members {
+			if member.Status == StatusRunning {
+				return nil, fmt.Errorf("%w: %s", ErrWorkerRunning, member.ID)
 			}
-			if startedAfter != nil && worker.Started.Before(*startedAfter) {
-				continue
+		}
+	}
+	deleted := make([]string, 0, len(members))
+	discarded := make([]*Worker, 0, len(members))
+	var errs []error
+	for _, member := range members {
+		if member.Status == StatusRunning {
+			// Processes that already exited only need their files removed
+			if m.checkProcessStatus(member) {
+				if err := m.terminateProcess(member); err != nil {
+					errs = append(errs, fmt.Errorf("failed to stop worker %s: %w", member.ID, err))
+					continue
+				}
 			}
-			timeFiltered = append(timeFiltered, worker)
+			m.killAmpProcesses(member.ThreadID)
+			m.stopLogTailer(member.ID)
 		}
-		filtered = timeFiltered
+
+		deleted = append(deleted, member.ID)
+		discarded = append(discarded, member)
+	}
+	if len(deleted) > 0 {
+		if err := m.discardWorkers(discarded); err != nil {
+			return nil, err
+		}
+		err := m.updateWorkers(func(workers map[string]*Worker) error {
+			for _, id := range deleted {
+				delete(workers, id)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return deleted, errors.Join(errs...)
+}
+
+// ListWorkers returns all workers, refreshing the status of any whose process has exited
+func (m *Manager) ListWorkers() ([]*Worker, error) {
+	snapshot, err := m.Snapshot()
+	if err != nil {
+		return nil, err
 	}
+	return snapshot.Workers(), nil
+}
 
-	// Sort workers
-	m.sortWorkers(filtered, sortBy, sortOrder)
+// ListWorkersWithFilter returns workers with filtering and sorting options
+func (m *Manager) ListWorkersWithFilter(statusFilter []string, startedBefore, startedAfter *time.Time, metadata MetadataFilter, sortBy, sortOrder string) ([]*Worker, error) {
+	snapshot, err := m.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.Filter(statusFilter, startedBefore, startedAfter, metadata, sortBy, sortOrder), nil
+}
 
-	return filtered, nil
+// IsRunning reports whether a worker's process is still running
+func (m *Manager) IsRunning(workerID string) (bool, error) {
+	worker, err := m.findWorker(workerID)
+	if err != nil {
+		return false, err
+	}
+	return worker.Status == StatusRunning && m.checkProcessStatus(worker), nil
 }
 
-func (m *Manager) createThread() (string, error) {
-	cmd := exec.Command(m.ampBinaryPath, "threads", "new")
+// createThread creates a thread with the agent's client, running it with env
+// added to the daemon's environment. ctx bounds the wait for the rate limiter.
+func (m *Manager) createThread(ctx context.Context, client AgentClient, env []string) (string, error) {
+	args := client.NewThreadArgs()
+	if len(args) == 0 {
+		// The agent has no threads of its own
+		return uuid.New().String(), nil
+	}
+	if err := m.limiter.Wait(ctx, InvocationThreadCreate); err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command(client.Binary(), args...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
 	output, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("failed to create thread: %w", err)
 	}
 
 	threadID := strings.TrimSpace(string(output))
-	if !strings.HasPrefix(threadID, "T-") {
-		return "", fmt.Errorf("unexpected thread ID format: %s", threadID)
+	if _, ok := client.(AmpClient); !ok {
+		// Only amp's thread IDs have a known format
+		if threadID == "" {
+			return "", fmt.Errorf("%s printed no thread ID", client.Binary())
+		}
+		return threadID, nil
+	}
+	if err := m.threadIDFormat.validate(threadID); err != nil {
+		return "", err
 	}
 
 	return threadID, nil
 }
 
+// loadWorkers returns the saved workers. Changes to them must be saved with
+// updateWorkers, which reloads them, so concurrent changes aren't lost.
 func (m *Manager) loadWorkers() (map[string]*Worker, error) {
+	m.stateMu.RLock()
+	defer m.stateMu.RUnlock()
+	return m.readWorkers()
+}
+
+// readWorkers reads the state file; the caller must hold stateMu
+func (m *Manager) readWorkers() (map[string]*Worker, error) {
 	workers := make(map[string]*Worker)
 
 	file, err := os.Open(m.stateFile)
@@ -625,35 +1132,97 @@ func (m *Manager) loadWorkers() (map[string]*Worker, error) {
 		return workers, nil
 	}
 
-	if err := json.Unmarshal(data, &workers); err != nil {
+	if _, err := workersFormat.Decode(data, &workers); err != nil {
 		return nil, err
 	}
 
 	return workers, nil
 }
 
+// saveWorkers replaces every saved worker. Workers are changed with
+// updateWorkers instead, so changes made meanwhile aren't lost.
 func (m *Manager) saveWorkers(workers map[string]*Worker) error {
-	data, err := json.MarshalIndent(workers, "", "  ")
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	return m.writeWorkers(workers)
+}
+
+// writeWorkers writes the state file; the caller must hold stateMu for writing
+func (m *Manager) writeWorkers(workers map[string]*Worker) error {
+	data, err := workersFormat.Encode(workers)
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(m.stateFile, data, 0644)
+	// Write to a temporary file and rename it into place so the state file is
+	// replaced atomically
+	tmpFile := m.stateFile + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, m.stateFile)
 }
 
-func (m *Manager) saveWorker(worker *Worker) error {
-	workers, err := m.loadWorkers()
+// errUnchanged is returned by updates passed to updateWorkers that changed
+// nothing, so the state file isn't rewritten
+var errUnchanged = errors.New("workers unchanged")
+
+// updateWorkers loads the saved workers, applies update and saves them,
+// holding the state lock throughout so no other change is lost in between.
+// Nothing is saved when update fails. update must not load or save workers
+// itself.
+func (m *Manager) updateWorkers(update func(workers map[string]*Worker) error) error {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+
+	workers, err := m.readWorkers()
 	if err != nil {
 		return err
 	}
+	if err := update(workers); errors.Is(err, errUnchanged) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return m.writeWorkers(workers)
+}
 
-	workers[worker.ID] = worker
-	return m.saveWorkers(workers)
+// updateWorker applies update to the saved worker with the given ID like
+// updateWorkers, and returns the updated worker
+func (m *Manager) updateWorker(workerID string, update func(worker *Worker) error) (*Worker, error) {
+	var updated *Worker
+	err := m.updateWorkers(func(workers map[string]*Worker) error {
+		worker, exists := workers[workerID]
+		if !exists {
+			return fmt.Errorf("%w: %s", ErrWorkerNotFound, workerID)
+		}
+		if err := update(worker); err != nil {
+			return err
+		}
+		updated = worker
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// saveWorker replaces the saved copy of a worker, keeping the others
+func (m *Manager) saveWorker(worker *Worker) error {
+	return m.updateWorkers(func(workers map[string]*Worker) error {
+		workers[worker.ID] = worker
+		return nil
+	})
 }
 
 
 
 func (m *Manager) checkProcessStatus(worker *Worker) bool {
+	if worker.Execution == ExecutionRemote {
+		return m.agents != nil && m.agents.Running(worker.ID)
+	}
+
 	process, err := os.FindProcess(worker.PID)
 	if err != nil {
 		return false
@@ -664,10 +1233,128 @@ func (m *Manager) checkProcessStatus(worker *Worker) bool {
 	return err == nil
 }
 
+// terminateProcess sends SIGTERM to a worker's process group, falling back to
+// the individual process and finally SIGKILL
+func (m *Manager) terminateProcess(worker *Worker) error {
+	m.markHalting(worker.ID)
+	if worker.Execution == ExecutionRemote {
+		m.signalRemote(worker, "SIGTERM")
+		return nil
+	}
+
+	// Kill the process group to ensure we kill amp and any processes it started
+	// First try to kill the entire process group
+	if err := syscall.Kill(-worker.PID, syscall.SIGTERM); err != nil {
+		// If process group kill fails, try individual process
+		process, findErr := os.FindProcess(worker.PID)
+		if findErr != nil {
+			return fmt.Errorf("failed to find process %d: %w", worker.PID, findErr)
+		}
+
+		if err := process.Signal(syscall.SIGTERM); err != nil {
+			// Try SIGKILL if SIGTERM fails
+			if killErr := process.Kill(); killErr != nil {
+				return fmt.Errorf("failed to kill process %d: %w", worker.PID, killErr)
+			}
+		}
+	}
+
+	return nil
+}
+
+// interruptProcess sends SIGINT to the worker's process group, ignoring
+// failures since the process may already be dead
+func (m *Manager) interruptProcess(worker *Worker) {
+	m.markHalting(worker.ID)
+	if worker.Execution == ExecutionRemote {
+		m.signalRemote(worker, "SIGINT")
+		return
+	}
+
+	if err := syscall.Kill(-worker.PID, syscall.SIGINT); err != nil {
+		// If process group kill fails, try individual process
+		process, findErr := os.FindProcess(worker.PID)
+		if findErr == nil {
+			process.Signal(syscall.SIGINT)
+		}
+	}
+}
+
+// forceKillProcess sends SIGKILL to a worker's process group, ignoring
+// failures since the process might already be dead
+func (m *Manager) forceKillProcess(worker *Worker) {
+	m.markHalting(worker.ID)
+	if worker.Execution == ExecutionRemote {
+		m.signalRemote(worker, "SIGKILL")
+		return
+	}
+
+	if err := syscall.Kill(-worker.PID, syscall.SIGKILL); err != nil {
+		// If process group kill fails, try individual process
+		if process, findErr := os.FindProcess(worker.PID); findErr == nil {
+			process.Kill()
+		}
+	}
+
+	// SIGKILL can't be proxied to a container, so kill it directly
+	if worker.Execution == ExecutionContainer {
+		m.signalContainers(containerTaskLabel, worker.ID, "SIGKILL")
+	}
+}
+
 func (m *Manager) killAmpProcesses(threadID string) {
 	// Use pkill to find and kill any amp processes for this thread
 	cmd := exec.Command("pkill", "-f", fmt.Sprintf("amp threads continue %s", threadID))
 	cmd.Run() // Ignore errors since the process might already be dead
+
+	// And any containers still running amp on it
+	m.signalContainers(containerThreadLabel, threadID, "SIGTERM")
+}
+
+// startLogTailer follows the worker's amp log, storing and broadcasting the
+// thread messages parsed from it, when log or thread callbacks are set
+func (m *Manager) startLogTailer(worker *Worker) {
+	if m.onLogLine == nil && m.onThreadMsg == nil {
+		return
+	}
+	// Agents other than amp don't write a log to parse
+	if worker.AmpLogFile == "" {
+		return
+	}
+
+	threads := m.threads(worker.Project)
+	threadMsgCallback := func(message ThreadMessage) {
+		// Store the message
+		if err := threads.AppendMessage(worker.ID, message); err != nil {
+			return
+		}
+
+		// Broadcast the message if callback is set
+		if m.onThreadMsg != nil {
+			m.onThreadMsg(worker.ID, message)
+		}
+	}
+
+	tailer := NewLogTailerWithParser(worker.AmpLogFile, worker.ID, m.onLogLine, threadMsgCallback)
+	tailer.SetLogFormat(m.logFormat(worker))
+	tailer.SetLineReader(func(r io.Reader) *LineReader {
+		return NewLineReader(r, &m.lineLimit)
+	})
+	if err := tailer.Start(context.Background()); err == nil {
+		m.tailersMu.Lock()
+		m.tailers[worker.ID] = tailer
+		m.tailersMu.Unlock()
+	}
+}
+
+// handleWorkerExit stops the log tailer of a worker whose process exited and
+// calls the exit callback
+func (m *Manager) handleWorkerExit(workerID string) {
+	m.stopLogTailer(workerID)
+
+	if m.onWorkerExit != nil {
+		m.onWorkerExit(workerID)
+	}
 }
 
 // stopLogTailer stops the log tailer for a worker
@@ -700,8 +1387,9 @@ func (m *Manager) ProcessStoppedWorkers() error {
 	}
 	
 	for workerID, worker := range workers {
-		// Only process stopped workers that haven't been processed yet
-		if worker.Status == StatusStopped && !m.processedWorkers[workerID] {
+		// Only process stopped and failed workers that haven't been processed yet
+		// Offloaded workers were processed before their files were moved
+		if (worker.Status == StatusStopped || worker.Status == StatusFailed) && !m.processedWorkers[workerID] && worker.Offloaded == nil {
 			// Check if this worker has a tailer (and thus amp logs to process)
 			m.tailersMu.RLock()
 			tailer, hasTailer := m.tailers[workerID]
@@ -714,7 +1402,7 @@ func (m *Manager) ProcessStoppedWorkers() error {
 			} else {
 				// No tailer, but check if amp log file exists and process manually
 				if worker.AmpLogFile != "" {
-					if err := m.processWorkerAmpLog(workerID, worker.AmpLogFile); err == nil {
+					if err := m.processWorkerAmpLog(worker); err == nil {
 						m.processedWorkers[workerID] = true
 					}
 				}
@@ -725,38 +1413,11 @@ func (m *Manager) ProcessStoppedWorkers() error {
 	return nil
 }
 
-// processWorkerAmpLog manually processes an amp log file for a worker
-func (m *Manager) processWorkerAmpLog(workerID, ampLogFile string) error {
-	// Create a temporary parser to process the log file
-	parser := NewAmpLogParser(workerID, func(msg ThreadMessage) {
-		// Store the message
-		if err := m.threadStorage.AppendMessage(workerID, msg); err != nil {
-			// Log error but continue
-			return
-		}
-		
-		// Broadcast the message if callback is set
-		if m.onThreadMsg != nil {
-			m.onThreadMsg(workerID, msg)
-		}
-	})
-	
-	// Read and process the entire amp log file
-	file, err := os.Open(ampLogFile)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-	
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		parser.ParseLine(scanner.Text())
-	}
-	
-	// Process the final conversation
-	parser.ProcessFinalConversation()
-	
-	return nil
+// processWorkerAmpLog manually processes an amp log file for a worker,
+// skipping messages its thread already has
+func (m *Manager) processWorkerAmpLog(worker *Worker) error {
+	_, err := m.backfillThread(worker, false)
+	return err
 }
 
 // AppendThreadMessage appends a message to the thread and optionally broadcasts it
@@ -769,8 +1430,15 @@ func (m *Manager) AppendThreadMessage(workerID string, messageType MessageType,
 		Metadata:  metadata,
 	}
 
+	// Appending to an offloaded thread would lose it, so bring it back first
+	if worker, err := m.findWorker(workerID); err == nil {
+		if err := m.restore(worker); err != nil {
+			return err
+		}
+	}
+
 	// Store the message
-	if err := m.threadStorage.AppendMessage(workerID, message); err != nil {
+	if err := m.workerThreads(workerID).AppendMessage(workerID, message); err != nil {
 		return fmt.Errorf("failed to store thread message: %w", err)
 	}
 
@@ -787,51 +1455,73 @@ func (m *Manager) GetThreadMessages(workerID string, limit, offset int) ([]Threa
 	// Process any stopped workers that haven't been processed yet (async)
 	go m.ProcessStoppedWorkers()
 	
-	return m.threadStorage.ReadMessages(workerID, limit, offset)
+	thread, err := m.offloadedThread(workerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open offloaded thread: %w", err)
+	}
+	if thread != nil {
+		defer thread.Close()
+		return readMessages(thread, limit, offset)
+	}
+	return m.workerThreads(workerID).ReadMessages(workerID, limit, offset)
+}
+
+// GetThreadPage returns up to limit messages of a thread following cursor, or
+// preceding it newest first when desc is set, and whether more remain
+func (m *Manager) GetThreadPage(workerID string, cursor *ThreadCursor, limit int, desc bool) ([]ThreadMessage, bool, error) {
+	go m.ProcessStoppedWorkers()
+
+	thread, err := m.offloadedThread(workerID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to open offloaded thread: %w", err)
+	}
+	if thread != nil {
+		defer thread.Close()
+		return readPage(thread, cursor, limit, desc)
+	}
+	return m.workerThreads(workerID).ReadPage(workerID, cursor, limit, desc)
 }
 
 // CountThreadMessages returns the total number of messages in a thread
 func (m *Manager) CountThreadMessages(workerID string) (int, error) {
-	return m.threadStorage.CountMessages(workerID)
+	thread, err := m.offloadedThread(workerID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open offloaded thread: %w", err)
+	}
+	if thread != nil {
+		defer thread.Close()
+		return countMessages(thread)
+	}
+	return m.workerThreads(workerID).CountMessages(workerID)
 }
 
-// sortWorkers sorts a slice of workers based on the given criteria
-func (m *Manager) sortWorkers(workers []*Worker, sortBy, sortOrder string) {
-	if len(workers) <= 1 {
-		return
-	}
+// RemoveThreadMessage removes a message from a worker's thread, e.g. one
+// echoing a secret. Offloaded threads are restored first. The worker's logs
+// are left alone, so rebuilding the thread brings the message back.
+func (m *Manager) RemoveThreadMessage(workerID, messageID string) error {
+	_, err := m.editThreadMessage(workerID, messageID, func(ThreadMessage) *ThreadMessage { return nil })
+	return err
+}
 
-	// Use a custom sort function
-	for i := 0; i < len(workers)-1; i++ {
-		for j := i + 1; j < len(workers); j++ {
-			var shouldSwap bool
-			
-			switch sortBy {
-			case "id":
-				if sortOrder == "asc" {
-					shouldSwap = workers[i].ID > workers[j].ID
-				} else {
-					shouldSwap = workers[i].ID < workers[j].ID
-				}
-			case "status":
-				if sortOrder == "asc" {
-					shouldSwap = workers[i].Status > workers[j].Status
-				} else {
-					shouldSwap = workers[i].Status < workers[j].Status
-				}
-			case "started":
-				fallthrough
-			default:
-				if sortOrder == "asc" {
-					shouldSwap = workers[i].Started.After(workers[j].Started)
-				} else {
-					shouldSwap = workers[i].Started.Before(workers[j].Started)
-				}
-			}
-			
-			if shouldSwap {
-				workers[i], workers[j] = workers[j], workers[i]
-			}
-		}
+// RedactThreadMessage blanks the content and metadata of a message in a
+// worker's thread, keeping its ID, type and time so the conversation still
+// reads in order, and returns the redacted message
+func (m *Manager) RedactThreadMessage(workerID, messageID string) (*ThreadMessage, error) {
+	return m.editThreadMessage(workerID, messageID, func(message ThreadMessage) *ThreadMessage {
+		message.Content = ""
+		message.Metadata = map[string]interface{}{"redacted": true}
+		return &message
+	})
+}
+
+// editThreadMessage applies edit to a message of a worker's thread
+func (m *Manager) editThreadMessage(workerID, messageID string, edit func(ThreadMessage) *ThreadMessage) (*ThreadMessage, error) {
+	worker, err := m.findWorker(workerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.restore(worker); err != nil {
+		return nil, err
 	}
+	return m.threads(worker.Project).EditMessage(workerID, messageID, edit)
 }
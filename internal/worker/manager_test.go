@@ -138,8 +138,7 @@ func TestManager_StopWorker_NotFound(t *testing.T) {
 	manager := NewManager(tmpDir)
 
 	err = manager.StopWorker("nonexistent")
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "worker nonexistent not found")
+	assert.ErrorIs(t, err, ErrWorkerNotFound)
 }
 
 func TestManager_createThread(t *testing.T) {
@@ -160,7 +159,7 @@ fi
 	manager := NewManager(tmpDir)
 	manager.ampBinaryPath = scriptPath
 
-	threadID, err := manager.createThread()
+	threadID, err := manager.createThread(context.Background(), AmpClient{Path: manager.ampBinaryPath}, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "T-test-thread-123", threadID)
 }
@@ -181,7 +180,7 @@ echo "invalid-thread-id"
 	manager := NewManager(tmpDir)
 	manager.ampBinaryPath = scriptPath
 
-	_, err = manager.createThread()
+	_, err = manager.createThread(context.Background(), AmpClient{Path: manager.ampBinaryPath}, nil)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "unexpected thread ID format")
 }
@@ -330,7 +329,7 @@ fi
 	_, err = os.Create(filepath.Join(tmpDir, "test.log"))
 	require.NoError(t, err)
 	
-	err = manager.RetryWorker("test-worker", "retry message")
+	err = manager.RetryWorker(context.Background(), "test-worker", "retry message")
 	require.NoError(t, err)
 	
 	workers, err := manager.loadWorkers()
@@ -363,7 +362,7 @@ func TestManager_RetryWorker_InvalidTransition(t *testing.T) {
 	err = manager.SaveWorkersForTest(testWorkers, filepath.Join(tmpDir, "workers.json"))
 	require.NoError(t, err)
 	
-	err = manager.RetryWorker("test-worker", "retry message")
+	err = manager.RetryWorker(context.Background(), "test-worker", "retry message")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "cannot retry")
 }
@@ -451,7 +450,7 @@ err = manager.SaveWorkersForTest(testWorkers, filepath.Join(tmpDir, "workers.jso
 require.NoError(t, err)
 
 // Delete worker
-err = manager.DeleteWorker("test-worker")
+err = manager.DeleteWorker("test-worker", false)
 require.NoError(t, err)
 
 // Verify worker is deleted
@@ -472,7 +471,7 @@ defer os.RemoveAll(tmpDir)
 
 manager := NewManager(tmpDir)
 
-err = manager.DeleteWorker("nonexistent")
+err = manager.DeleteWorker("nonexistent", false)
 assert.Error(t, err)
 assert.Contains(t, err.Error(), "not found")
 }
@@ -564,3 +563,62 @@ func TestManagerThreadMessages(t *testing.T) {
 		assert.Equal(t, 0, count)
 	})
 }
+
+func TestManager_StartWorker_Metadata(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := NewManager(tmpDir)
+	manager.SetAgentClients(map[string]AgentClient{"script": CommandClient{Path: "/bin/true", Continue: []string{"{thread}"}}})
+
+	worker, err := manager.StartWorkerWithOptions(context.Background(), "fix the tests", StartOptions{
+		Backend:     "script",
+		Title:       "Fix tests",
+		Description: "The unit tests are red",
+		Priority:    "high",
+		Tags:        []string{"ci"},
+		Issue:       &IssueLink{Provider: "github", Key: "acme/api#1"},
+		IssueFields: IssueFields{Priority: "low", Tags: []string{"type:bug"}},
+	})
+	require.NoError(t, err)
+
+	// The metadata is saved with the worker, before it can finish
+	workers, err := manager.loadWorkers()
+	require.NoError(t, err)
+	saved := workers[worker.ID]
+	require.NotNil(t, saved)
+	assert.Equal(t, "Fix tests", saved.Title)
+	assert.Equal(t, "The unit tests are red", saved.Description)
+	assert.Equal(t, "high", saved.Priority, "the request's priority wins over the issue's")
+	assert.Equal(t, []string{"ci", "type:bug"}, saved.Tags)
+}
+
+func TestManager_DeleteWorker_Running(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := NewManager(tmpDir)
+
+	logFile := filepath.Join(tmpDir, "worker-w.log")
+	ampLogFile := filepath.Join(tmpDir, "amp-w.log")
+	require.NoError(t, os.WriteFile(logFile, nil, 0644))
+	require.NoError(t, os.WriteFile(ampLogFile, nil, 0644))
+	require.NoError(t, manager.AppendThreadMessage("w", MessageTypeUser, "hi", nil))
+
+	require.NoError(t, manager.SaveWorkersForTest(map[string]*Worker{
+		"w": {ID: "w", ThreadID: "T-w", PID: 999999, LogFile: logFile, AmpLogFile: ampLogFile, Status: StatusRunning},
+	}, filepath.Join(tmpDir, "workers.json")))
+
+	err := manager.DeleteWorker("w", false)
+	assert.ErrorIs(t, err, ErrWorkerRunning)
+	workers, err := manager.loadWorkers()
+	require.NoError(t, err)
+	assert.Contains(t, workers, "w", "running workers are kept without force")
+
+	require.NoError(t, manager.DeleteWorker("w", true))
+	workers, err = manager.loadWorkers()
+	require.NoError(t, err)
+	assert.NotContains(t, workers, "w")
+
+	// Every file of the worker is removed, not just its stdout log
+	for _, path := range []string{logFile, ampLogFile, filepath.Join(tmpDir, "threads", "thread_w.jsonl")} {
+		_, err := os.Stat(path)
+		assert.True(t, os.IsNotExist(err), path)
+	}
+}
@@ -0,0 +1,372 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ObjectStore keeps offloaded files outside the log directory, e.g. in an S3
+// or GCS bucket
+type ObjectStore interface {
+	Put(ctx context.Context, key string, body io.ReadSeeker) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// ErrLogNotFound is returned when a worker has no log file, locally or offloaded
+var ErrLogNotFound = errors.New("log file not found")
+
+// OffloadPolicy configures when finished workers' files are offloaded
+type OffloadPolicy struct {
+	After         time.Duration // Time since a worker's files last changed before they're offloaded
+	CheckInterval time.Duration // How often workers are checked
+}
+
+// Offload records which of a worker's files were moved to the object store
+type Offload struct {
+	At    time.Time `json:"at"`
+	Files []string  `json:"files"` // Names of the offloaded files: "log", "amp.log" and "thread.jsonl"
+}
+
+// has reports whether the named file was offloaded
+func (o *Offload) has(name string) bool {
+	if o == nil {
+		return false
+	}
+	for _, file := range o.Files {
+		if file == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Names of the files of a worker that can be offloaded
+const (
+	offloadLog    = "log"
+	offloadAmpLog = "amp.log"
+	offloadThread = "thread.jsonl"
+)
+
+// offloadTimeout bounds moving one worker's files to or from the object store
+const offloadTimeout = 10 * time.Minute
+
+// SetObjectStore offloads the logs and threads of workers that finished at
+// least policy.After ago to store, freeing local disk. Offloaded files are
+// still served by OpenLog and GetThreadMessages, and are brought back before
+// the worker runs again.
+func (m *Manager) SetObjectStore(store ObjectStore, policy OffloadPolicy) {
+	if policy.CheckInterval <= 0 {
+		policy.CheckInterval = time.Hour
+	}
+	m.store = store
+	m.offloadPolicy = policy
+}
+
+// RunOffloader offloads idle workers' files until the context is cancelled
+func (m *Manager) RunOffloader(ctx context.Context) {
+	if m.store == nil {
+		return
+	}
+
+	ticker := time.NewTicker(m.offloadPolicy.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := m.OffloadIdle(ctx, now); err != nil {
+				log.Printf("Offloader failed: %v", err)
+			}
+		}
+	}
+}
+
+// OffloadIdle moves the files of every finished worker that hasn't changed
+// them for the policy's After duration to the object store, returning the IDs
+// of the workers offloaded
+func (m *Manager) OffloadIdle(ctx context.Context, now time.Time) ([]string, error) {
+	if m.store == nil {
+		return nil, nil
+	}
+
+	workers, err := m.loadWorkers()
+	if err != nil {
+		return nil, err
+	}
+
+	offloaded := []string{}
+	for _, worker := range workers {
+		if worker.Status == StatusRunning || worker.Offloaded != nil {
+			continue
+		}
+		if _, winding := m.windDowns.Load(worker.ID); winding {
+			continue
+		}
+
+		files := m.offloadFiles(worker)
+		modified, ok := lastModified(files)
+		if !ok || now.Sub(modified) < m.offloadPolicy.After {
+			continue
+		}
+
+		if err := m.offload(ctx, worker.ID, files, now); err != nil {
+			log.Printf("Failed to offload worker %s: %v", worker.ID, err)
+			continue
+		}
+		offloaded = append(offloaded, worker.ID)
+	}
+	return offloaded, nil
+}
+
+// offloadFiles returns the local paths of a worker's offloadable files by name
+func (m *Manager) offloadFiles(worker *Worker) map[string]string {
+	files := map[string]string{
+		offloadThread: m.threads(worker.Project).getThreadFilePath(worker.ID),
+	}
+	if worker.LogFile != "" {
+		files[offloadLog] = worker.LogFile
+	}
+	if worker.AmpLogFile != "" {
+		files[offloadAmpLog] = worker.AmpLogFile
+	}
+	return files
+}
+
+// lastModified returns the newest modification time of the files that
+// exist, and false if none do
+func lastModified(files map[string]string) (time.Time, bool) {
+	var latest time.Time
+	found := false
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		found = true
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, found
+}
+
+// offload uploads a worker's files, records them as offloaded and only then
+// removes the local copies
+func (m *Manager) offload(ctx context.Context, workerID string, files map[string]string, now time.Time) error {
+	m.offloadMu.Lock()
+	defer m.offloadMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, offloadTimeout)
+	defer cancel()
+
+	record := &Offload{At: now}
+	for name, path := range files {
+		uploaded, err := m.upload(ctx, offloadKey(workerID, name), path)
+		if err != nil {
+			return err
+		}
+		if uploaded {
+			record.Files = append(record.Files, name)
+		}
+	}
+
+	// The worker may have been retried while its files were uploading
+	recorded := false
+	err := m.updateWorkers(func(workers map[string]*Worker) error {
+		worker, exists := workers[workerID]
+		if !exists || worker.Status == StatusRunning || worker.Offloaded != nil {
+			return errUnchanged
+		}
+		worker.Offloaded = record
+		recorded = true
+		return nil
+	})
+	if err != nil || !recorded {
+		m.deleteObjects(workerID, record)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update worker state: %w", err)
+	}
+	if !recorded {
+		return nil
+	}
+
+	for _, name := range record.Files {
+		os.Remove(files[name])
+	}
+	return nil
+}
+
+// upload puts a local file in the object store, reporting false if it doesn't exist
+func (m *Manager) upload(ctx context.Context, key, path string) (bool, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	if err := m.store.Put(ctx, key, file); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// restore downloads a worker's offloaded files back to their local paths,
+// then clears the worker's offload record and removes the stored objects.
+// worker is updated to match so callers saving it don't undo the restore.
+func (m *Manager) restore(worker *Worker) error {
+	if worker.Offloaded == nil {
+		return nil
+	}
+	if m.store == nil {
+		return fmt.Errorf("worker %s has offloaded files but no object store is configured", worker.ID)
+	}
+
+	m.offloadMu.Lock()
+	defer m.offloadMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), offloadTimeout)
+	defer cancel()
+
+	files := m.offloadFiles(worker)
+	record := worker.Offloaded
+	for _, name := range record.Files {
+		path, ok := files[name]
+		if !ok {
+			continue
+		}
+		if err := m.download(ctx, offloadKey(worker.ID, name), path); err != nil {
+			return fmt.Errorf("failed to restore offloaded %s: %w", name, err)
+		}
+	}
+
+	err := m.updateWorkers(func(workers map[string]*Worker) error {
+		saved, exists := workers[worker.ID]
+		if !exists {
+			return errUnchanged
+		}
+		saved.Offloaded = nil
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update worker state: %w", err)
+	}
+	worker.Offloaded = nil
+
+	m.deleteObjects(worker.ID, record)
+	return nil
+}
+
+// download writes an object to a local file
+func (m *Manager) download(ctx context.Context, key, path string) error {
+	body, err := m.store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmpFile := path + ".tmp"
+	file, err := os.Create(tmpFile)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, body); err != nil {
+		file.Close()
+		os.Remove(tmpFile)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+	return os.Rename(tmpFile, path)
+}
+
+// deleteObjects removes a worker's offloaded files from the object store,
+// logging failures
+func (m *Manager) deleteObjects(workerID string, record *Offload) {
+	if m.store == nil || record == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), offloadTimeout)
+	defer cancel()
+
+	for _, name := range record.Files {
+		if err := m.store.Delete(ctx, offloadKey(workerID, name)); err != nil {
+			log.Printf("Failed to delete offloaded %s of worker %s: %v", name, workerID, err)
+		}
+	}
+}
+
+// openOffloaded opens a worker's file locally, or from the object store when
+// it was offloaded. It returns os.ErrNotExist if the file is in neither.
+func (m *Manager) openOffloaded(worker *Worker, name, path string) (io.ReadCloser, error) {
+	if worker.Offloaded.has(name) && m.store != nil {
+		return m.store.Get(context.Background(), offloadKey(worker.ID, name))
+	}
+	if path == "" {
+		return nil, os.ErrNotExist
+	}
+	return os.Open(path)
+}
+
+// OpenLog opens a worker's stdout log, reading it from the object store when
+// it was offloaded. The caller must close the returned reader.
+func (m *Manager) OpenLog(workerID string) (io.ReadCloser, error) {
+	return m.openLog(workerID, offloadLog, func(worker *Worker) string { return worker.LogFile })
+}
+
+// OpenAmpLog opens the debug log amp writes for a worker, whose JSON lines
+// its thread is parsed from. Agents other than amp don't write one.
+func (m *Manager) OpenAmpLog(workerID string) (io.ReadCloser, error) {
+	return m.openLog(workerID, offloadAmpLog, func(worker *Worker) string { return worker.AmpLogFile })
+}
+
+// openLog opens one of a worker's logs, locally at the path returned by path
+// or offloaded under name
+func (m *Manager) openLog(workerID, name string, path func(*Worker) string) (io.ReadCloser, error) {
+	worker, err := m.findWorker(workerID)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := m.openOffloaded(worker, name, path(worker))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: worker %s", ErrLogNotFound, workerID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// offloadedThread opens a worker's offloaded thread, returning nil if its
+// thread is stored locally
+func (m *Manager) offloadedThread(workerID string) (io.ReadCloser, error) {
+	worker, err := m.findWorker(workerID)
+	if err != nil || !worker.Offloaded.has(offloadThread) {
+		return nil, nil
+	}
+	return m.openOffloaded(worker, offloadThread, "")
+}
+
+// offloadKey returns the object key of one of a worker's files
+func offloadKey(workerID, name string) string {
+	return "tasks/" + workerID + "/" + name
+}
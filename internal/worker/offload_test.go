@@ -0,0 +1,176 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore is an ObjectStore keeping objects in memory
+type memoryStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{objects: make(map[string][]byte)}
+}
+
+func (s *memoryStore) Put(ctx context.Context, key string, body io.ReadSeeker) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = data
+	return nil
+}
+
+func (s *memoryStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key)
+	return nil
+}
+
+// setupOffloadWorker saves a stopped worker with a log and a two-message
+// thread last changed an hour ago
+func setupOffloadWorker(t *testing.T) (*Manager, *memoryStore, *Worker) {
+	tmpDir := t.TempDir()
+	manager := NewManager(tmpDir)
+	store := newMemoryStore()
+	manager.SetObjectStore(store, OffloadPolicy{After: 30 * time.Minute})
+
+	worker := &Worker{
+		ID:         "done",
+		LogFile:    filepath.Join(tmpDir, "worker-done.log"),
+		AmpLogFile: filepath.Join(tmpDir, "worker-done-amp.log"),
+		Started:    time.Now(),
+		Status:     StatusStopped,
+	}
+	require.NoError(t, manager.SaveWorkersForTest(map[string]*Worker{"done": worker}, filepath.Join(tmpDir, "workers.json")))
+	require.NoError(t, os.WriteFile(worker.LogFile, []byte("first\nsecond\n"), 0644))
+	require.NoError(t, manager.AppendThreadMessage("done", MessageTypeUser, "hello", nil))
+	require.NoError(t, manager.AppendThreadMessage("done", MessageTypeAssistant, "hi", nil))
+
+	hourAgo := time.Now().Add(-time.Hour)
+	for _, path := range manager.offloadFiles(worker) {
+		os.Chtimes(path, hourAgo, hourAgo)
+	}
+	return manager, store, worker
+}
+
+func TestOffloadIdle(t *testing.T) {
+	manager, store, worker := setupOffloadWorker(t)
+
+	offloaded, err := manager.OffloadIdle(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"done"}, offloaded)
+
+	saved := findTestWorker(t, manager, "done")
+	require.NotNil(t, saved.Offloaded)
+	assert.ElementsMatch(t, []string{"log", "thread.jsonl"}, saved.Offloaded.Files)
+	assert.Contains(t, store.objects, "tasks/done/log")
+	assert.Contains(t, store.objects, "tasks/done/thread.jsonl")
+	assert.NoFileExists(t, worker.LogFile)
+	assert.NoFileExists(t, manager.threads("").getThreadFilePath("done"))
+
+	// Offloaded files are still readable
+	file, err := manager.OpenLog("done")
+	require.NoError(t, err)
+	data, err := io.ReadAll(file)
+	file.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "first\nsecond\n", string(data))
+
+	messages, err := manager.GetThreadMessages("done", 0, 0)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	assert.Equal(t, "hello", messages[0].Content)
+	count, err := manager.CountThreadMessages("done")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// Already offloaded workers are left alone
+	offloaded, err = manager.OffloadIdle(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, offloaded)
+}
+
+func TestOffloadIdle_RecentOrRunning(t *testing.T) {
+	manager, _, _ := setupOffloadWorker(t)
+
+	offloaded, err := manager.OffloadIdle(context.Background(), time.Now().Add(-45*time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, offloaded, "files changed less than After ago")
+
+	workers, err := manager.loadWorkers()
+	require.NoError(t, err)
+	workers["done"].Status = StatusRunning
+	require.NoError(t, manager.saveWorkers(workers))
+
+	offloaded, err = manager.OffloadIdle(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, offloaded, "running workers are never offloaded")
+}
+
+func TestOffload_RestoredOnAppend(t *testing.T) {
+	manager, store, worker := setupOffloadWorker(t)
+	_, err := manager.OffloadIdle(context.Background(), time.Now())
+	require.NoError(t, err)
+
+	require.NoError(t, manager.AppendThreadMessage("done", MessageTypeSystem, "annotated", nil))
+
+	assert.Nil(t, findTestWorker(t, manager, "done").Offloaded)
+	assert.Empty(t, store.objects)
+	assert.FileExists(t, worker.LogFile)
+
+	messages, err := manager.GetThreadMessages("done", 0, 0)
+	require.NoError(t, err)
+	require.Len(t, messages, 3)
+	assert.Equal(t, "annotated", messages[2].Content)
+}
+
+func TestOffload_DeleteWorkerRemovesObjects(t *testing.T) {
+	manager, store, _ := setupOffloadWorker(t)
+	_, err := manager.OffloadIdle(context.Background(), time.Now())
+	require.NoError(t, err)
+	require.NotEmpty(t, store.objects)
+
+	require.NoError(t, manager.DeleteWorker("done", false))
+	assert.Empty(t, store.objects)
+}
+
+func TestOpenLog_Missing(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := NewManager(tmpDir)
+	workers := map[string]*Worker{"new": {ID: "new", LogFile: filepath.Join(tmpDir, "none.log"), Status: StatusRunning}}
+	require.NoError(t, manager.SaveWorkersForTest(workers, filepath.Join(tmpDir, "workers.json")))
+
+	_, err := manager.OpenLog("new")
+	assert.ErrorIs(t, err, ErrLogNotFound)
+	_, err = manager.OpenAmpLog("new")
+	assert.ErrorIs(t, err, ErrLogNotFound)
+
+	_, err = manager.OpenLog("missing")
+	assert.ErrorContains(t, err, "not found")
+}
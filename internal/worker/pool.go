@@ -0,0 +1,166 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// DefaultPool is the pool of workers started without one, when it is
+// configured
+const DefaultPool = "default"
+
+var (
+	// ErrUnknownPool is returned when a worker selects a pool that isn't
+	// configured
+	ErrUnknownPool = errors.New("unknown worker pool")
+
+	// ErrPoolFull is returned when a worker's pool already runs as many
+	// workers as it may
+	ErrPoolFull = errors.New("worker pool is full")
+
+	// ErrTooManyWorkers is returned when the manager already runs as many
+	// workers as it may
+	ErrTooManyWorkers = errors.New("too many workers running")
+)
+
+// Pool is a named set of settings shared by the workers that select it, with
+// a limit on how many of them run at once
+type Pool struct {
+	MaxWorkers int               // Workers of the pool running at once; 0 is unlimited
+	Dir        string            // Directory amp runs in on the host; empty uses the project's
+	Profile    string            // amp profile of workers that don't select one
+	Env        map[string]string // Variables added to amp's environment; the task's own take precedence
+}
+
+// PoolInfo describes a worker pool and how many of its workers are running
+type PoolInfo struct {
+	Name       string `json:"name"`
+	MaxWorkers int    `json:"max_workers"` // 0 is unlimited
+	Running    int    `json:"running"`
+}
+
+// SetMaxWorkers limits how many workers run at once, across every pool. 0
+// is unlimited.
+func (m *Manager) SetMaxWorkers(max int) {
+	m.maxWorkers = max
+}
+
+// SetPools sets the pools workers may select by name
+func (m *Manager) SetPools(pools map[string]Pool) {
+	m.pools = pools
+}
+
+// Pools returns the pools workers may select, sorted by name
+func (m *Manager) Pools() ([]PoolInfo, error) {
+	workers, err := m.loadWorkers()
+	if err != nil {
+		return nil, err
+	}
+
+	pools := make([]PoolInfo, 0, len(m.pools))
+	for name, pool := range m.pools {
+		pools = append(pools, PoolInfo{
+			Name:       name,
+			MaxWorkers: pool.MaxWorkers,
+			Running:    countRunning(workers, inPool(name), ""),
+		})
+	}
+	sort.Slice(pools, func(i, j int) bool { return pools[i].Name < pools[j].Name })
+	return pools, nil
+}
+
+// resolvePool returns the name of the pool a new worker selecting name joins:
+// the default pool, when it is configured, for the empty name
+func (m *Manager) resolvePool(name string) (string, error) {
+	if name == "" {
+		if _, ok := m.pools[DefaultPool]; ok {
+			return DefaultPool, nil
+		}
+		return "", nil
+	}
+	if _, ok := m.pools[name]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownPool, name)
+	}
+	return name, nil
+}
+
+// reserveSlot takes one of the manager's slots, and one of the pool's, for
+// a worker about to start, ignoring the worker with ID exclude, which is being
+// relaunched. Call release once the worker has been saved as running, or
+// failed to start.
+func (m *Manager) reserveSlot(pool, exclude string) (release func(), err error) {
+	limit := m.pools[pool].MaxWorkers
+	if pool == "" {
+		limit = 0
+	}
+	if m.maxWorkers <= 0 && limit <= 0 {
+		return func() {}, nil
+	}
+
+	m.poolsMu.Lock()
+	defer m.poolsMu.Unlock()
+
+	workers, err := m.loadWorkers()
+	if err != nil {
+		return nil, err
+	}
+	if m.maxWorkers > 0 && countRunning(workers, nil, exclude)+m.reserved >= m.maxWorkers {
+		return nil, fmt.Errorf("%w: at most %d run at once", ErrTooManyWorkers, m.maxWorkers)
+	}
+	if limit > 0 && countRunning(workers, inPool(pool), exclude)+m.poolReserved[pool] >= limit {
+		return nil, fmt.Errorf("%w: %s runs at most %d workers", ErrPoolFull, pool, limit)
+	}
+
+	if m.poolReserved == nil {
+		m.poolReserved = make(map[string]int)
+	}
+	m.reserved++
+	m.poolReserved[pool]++
+	var released bool
+	return func() {
+		m.poolsMu.Lock()
+		defer m.poolsMu.Unlock()
+		if !released {
+			released = true
+			m.reserved--
+			m.poolReserved[pool]--
+		}
+	}, nil
+}
+
+// inPool matches the workers of the named pool
+func inPool(pool string) func(*Worker) bool {
+	return func(worker *Worker) bool { return worker.Pool == pool }
+}
+
+// countRunning returns the number of running workers that match, or all of
+// them when match is nil, other than the worker with ID exclude
+func countRunning(workers map[string]*Worker, match func(*Worker) bool, exclude string) int {
+	running := 0
+	for id, worker := range workers {
+		if id != exclude && worker.Status == StatusRunning && worker.Deleted == nil && (match == nil || match(worker)) {
+			running++
+		}
+	}
+	return running
+}
+
+// poolEnv returns the variables of a worker's pool that the worker doesn't
+// set itself, as sorted NAME=value pairs
+func (m *Manager) poolEnv(worker *Worker) []string {
+	pool := m.pools[worker.Pool]
+	env := make([]string, 0, len(pool.Env))
+	for name, value := range pool.Env {
+		if _, ok := worker.Env[name]; !ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	sort.Strings(env)
+	return env
+}
+
+// poolDir returns the directory a worker's pool runs amp in, if it sets one
+func (m *Manager) poolDir(worker *Worker) string {
+	return m.pools[worker.Pool].Dir
+}
@@ -0,0 +1,177 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartWorker_Pools(t *testing.T) {
+	tmpDir := t.TempDir()
+	poolDir := t.TempDir()
+	scriptPath := filepath.Join(tmpDir, "dummy-amp")
+	script := "#!/bin/bash\n" +
+		"if [ \"$1 $2\" = \"threads new\" ]; then echo T-pool; exit 0; fi\n" +
+		"echo \"$(pwd) $FOO $BAR $AMP_API_KEY\" > " + filepath.Join(tmpDir, "invocation") + "\n" +
+		"sleep 5\n"
+	require.NoError(t, os.WriteFile(scriptPath, []byte(script), 0755))
+
+	manager := NewManager(tmpDir)
+	manager.SetAmpBinary(scriptPath)
+	manager.SetAmpProfiles(map[string]AmpProfile{"gpu": {Env: map[string]string{"AMP_API_KEY": "gpu-key"}}})
+	manager.SetPools(map[string]Pool{
+		"gpu": {MaxWorkers: 1, Dir: poolDir, Profile: "gpu", Env: map[string]string{"FOO": "pool", "BAR": "pool"}},
+	})
+
+	_, err := manager.StartWorkerWithOptions(context.Background(), "hello", StartOptions{Pool: "missing"})
+	assert.ErrorIs(t, err, ErrUnknownPool)
+
+	// The pool's settings apply, with the task's own variables taking precedence
+	first, err := manager.StartWorkerWithOptions(context.Background(), "hello", StartOptions{Pool: "gpu", Env: map[string]string{"FOO": "task"}})
+	require.NoError(t, err)
+	t.Cleanup(func() { manager.StopWorker(first.ID) })
+	assert.Equal(t, "gpu", first.Pool)
+	assert.Equal(t, "gpu", first.Profile)
+	assert.Equal(t, []string{"FOO", "BAR", "AMP_API_KEY"}, manager.workerEnvVars(first))
+
+	invocation := filepath.Join(tmpDir, "invocation")
+	require.Eventually(t, func() bool {
+		data, err := os.ReadFile(invocation)
+		return err == nil && strings.HasSuffix(string(data), "\n")
+	}, 5*time.Second, 10*time.Millisecond)
+	data, err := os.ReadFile(invocation)
+	require.NoError(t, err)
+	resolved, err := filepath.EvalSymlinks(poolDir)
+	require.NoError(t, err)
+	assert.Equal(t, resolved+" task pool gpu-key\n", string(data))
+
+	workspace, err := manager.Workspace(first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, poolDir, workspace.Dir)
+
+	// The pool is full while its worker runs
+	_, err = manager.StartWorkerWithOptions(context.Background(), "hello", StartOptions{Pool: "gpu"})
+	assert.ErrorIs(t, err, ErrPoolFull)
+	pools, err := manager.Pools()
+	require.NoError(t, err)
+	assert.Equal(t, []PoolInfo{{Name: "gpu", MaxWorkers: 1, Running: 1}}, pools)
+
+	// Tasks without a pool aren't limited when no default pool is configured
+	other, err := manager.StartWorkerWithOptions(context.Background(), "hello", StartOptions{})
+	require.NoError(t, err)
+	t.Cleanup(func() { manager.StopWorker(other.ID) })
+	assert.Empty(t, other.Pool)
+
+	require.NoError(t, manager.StopWorker(first.ID))
+	second, err := manager.StartWorkerWithOptions(context.Background(), "hello", StartOptions{Pool: "gpu"})
+	require.NoError(t, err)
+	t.Cleanup(func() { manager.StopWorker(second.ID) })
+
+	// Relaunching the stopped worker would exceed the limit again
+	workers, err := manager.loadWorkers()
+	require.NoError(t, err)
+	_, err = manager.relaunchWorker(context.Background(), workers[first.ID], "again", AttemptRetry, nil)
+	assert.ErrorIs(t, err, ErrPoolFull)
+}
+
+func TestWorkspace_PoolDirOnlyOnHost(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := NewManager(tmpDir)
+	manager.SetWorkspace(Workspace{Dir: "/srv/repo", BaseBranch: "main"})
+	manager.SetPools(map[string]Pool{"gpu": {Dir: "/srv/gpu"}})
+
+	workers := map[string]*Worker{
+		"legacy":    {ID: "legacy", Pool: "gpu", Status: StatusStopped},
+		"host":      {ID: "host", Pool: "gpu", Execution: ExecutionHost, Status: StatusStopped},
+		"container": {ID: "container", Pool: "gpu", Execution: ExecutionContainer, Status: StatusStopped},
+		"remote":    {ID: "remote", Pool: "gpu", Execution: ExecutionRemote, Status: StatusStopped},
+	}
+	require.NoError(t, manager.SaveWorkersForTest(workers, filepath.Join(tmpDir, "workers.json")))
+
+	for id, dir := range map[string]string{"legacy": "/srv/gpu", "host": "/srv/gpu", "container": "/srv/repo", "remote": "/srv/repo"} {
+		workspace, err := manager.Workspace(id)
+		require.NoError(t, err)
+		assert.Equal(t, dir, workspace.Dir, id)
+	}
+}
+
+func TestStartWorker_DefaultPool(t *testing.T) {
+	tmpDir := t.TempDir()
+	scriptPath := filepath.Join(tmpDir, "dummy-amp")
+	require.NoError(t, os.WriteFile(scriptPath, []byte("#!/bin/bash\necho T-pool\n"), 0755))
+
+	manager := NewManager(tmpDir)
+	manager.SetAmpBinary(scriptPath)
+	manager.SetPools(map[string]Pool{DefaultPool: {}, "docs": {}})
+
+	worker, err := manager.StartWorkerWithOptions(context.Background(), "hello", StartOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultPool, worker.Pool)
+
+	worker, err = manager.StartWorkerWithOptions(context.Background(), "hello", StartOptions{Pool: "docs"})
+	require.NoError(t, err)
+	assert.Equal(t, "docs", worker.Pool)
+}
+
+func TestReserveSlot(t *testing.T) {
+	manager := NewManager(t.TempDir())
+	manager.SetPools(map[string]Pool{"docs": {MaxWorkers: 2}})
+
+	// Workers still starting hold their slots
+	first, err := manager.reserveSlot("docs", "")
+	require.NoError(t, err)
+	second, err := manager.reserveSlot("docs", "")
+	require.NoError(t, err)
+	_, err = manager.reserveSlot("docs", "")
+	assert.ErrorIs(t, err, ErrPoolFull)
+
+	// Releasing twice frees one slot
+	first()
+	first()
+	third, err := manager.reserveSlot("docs", "")
+	require.NoError(t, err)
+	_, err = manager.reserveSlot("docs", "")
+	assert.ErrorIs(t, err, ErrPoolFull)
+	second()
+	third()
+
+	// Unlimited pools and workers without a pool are never refused
+	manager.SetPools(map[string]Pool{"docs": {}})
+	for i := 0; i < 3; i++ {
+		_, err := manager.reserveSlot("docs", "")
+		require.NoError(t, err)
+		_, err = manager.reserveSlot("", "")
+		require.NoError(t, err)
+	}
+}
+
+func TestReserveSlot_MaxWorkers(t *testing.T) {
+	manager := NewManager(t.TempDir())
+	manager.SetMaxWorkers(2)
+	manager.SetPools(map[string]Pool{"docs": {}})
+	require.NoError(t, manager.saveWorkers(map[string]*Worker{
+		"running": {ID: "running", Status: StatusRunning, Pool: "docs"},
+		"done":    {ID: "done", Status: StatusCompleted},
+	}))
+
+	// Running workers of every pool, and workers still starting, count
+	release, err := manager.reserveSlot("", "")
+	require.NoError(t, err)
+	_, err = manager.reserveSlot("docs", "")
+	assert.ErrorIs(t, err, ErrTooManyWorkers)
+
+	// The relaunched worker's own slot isn't counted
+	other, err := manager.reserveSlot("docs", "running")
+	require.NoError(t, err)
+	other()
+
+	release()
+	_, err = manager.reserveSlot("docs", "")
+	require.NoError(t, err)
+}
@@ -0,0 +1,52 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrUnknownProfile is returned when a worker uses an amp profile that isn't
+// configured
+var ErrUnknownProfile = errors.New("unknown amp profile")
+
+// AmpProfile is a named set of environment variables, such as amp's API key
+// and endpoint, that a worker's amp invocations run with
+type AmpProfile struct {
+	Env map[string]string
+}
+
+// SetAmpProfiles sets the profiles workers may select by name
+func (m *Manager) SetAmpProfiles(profiles map[string]AmpProfile) {
+	m.ampProfiles = profiles
+}
+
+// profileEnv returns the environment variables of the named profile as
+// sorted NAME=value pairs. The empty name is the daemon's own environment.
+func (m *Manager) profileEnv(name string) ([]string, error) {
+	if name == "" {
+		return nil, nil
+	}
+	profile, ok := m.ampProfiles[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProfile, name)
+	}
+
+	env := make([]string, 0, len(profile.Env))
+	for key, value := range profile.Env {
+		env = append(env, key+"="+value)
+	}
+	sort.Strings(env)
+	return env, nil
+}
+
+// profileVars returns the names of the named profile's variables, sorted
+func (m *Manager) profileVars(name string) []string {
+	profile := m.ampProfiles[name]
+	names := make([]string, 0, len(profile.Env))
+	for key := range profile.Env {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	return names
+}
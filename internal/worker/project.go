@@ -0,0 +1,278 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// DefaultProject is the project of tasks started without one, including tasks
+// created before projects existed. It always exists and keeps its logs and
+// threads directly in the log directory.
+const DefaultProject = "default"
+
+// ErrProjectNotFound is returned when a task or request names a project that
+// doesn't exist
+var ErrProjectNotFound = errors.New("project not found")
+
+// ErrInvalidProjectName is returned when creating a project whose name can't
+// be used as a directory name
+var ErrInvalidProjectName = errors.New("invalid project name")
+
+// projectNamePattern restricts project names to ones usable as directory names
+var projectNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,62}$`)
+
+// Project groups tasks working on the same repository, with the settings
+// they share
+type Project struct {
+	Name          string      `json:"name"`
+	RepoURL       string      `json:"repo_url,omitempty"`
+	DefaultBranch string      `json:"default_branch,omitempty"`
+	Amp           AmpSettings `json:"amp"`
+	Created       time.Time   `json:"created"`
+}
+
+// AmpSettings are the amp settings applied to a project's tasks
+type AmpSettings struct {
+	Execution ExecutionMode `json:"execution,omitempty"` // Default execution mode; empty uses the daemon's
+	Dir       string        `json:"dir,omitempty"`       // Directory amp runs in on the host, e.g. the repository checkout
+}
+
+// ProjectUpdate holds the project fields to change; nil fields are left alone
+type ProjectUpdate struct {
+	RepoURL       *string
+	DefaultBranch *string
+	Execution     *ExecutionMode
+	Dir           *string
+}
+
+// ProjectName returns the worker's project, mapping tasks created before
+// projects existed to the default project
+func (w *Worker) ProjectName() string {
+	if w.Project == "" {
+		return DefaultProject
+	}
+	return w.Project
+}
+
+// projectDir returns the directory holding a project's logs and threads
+func (m *Manager) projectDir(project string) string {
+	if project == "" || project == DefaultProject {
+		return m.logDir
+	}
+	return filepath.Join(m.logDir, "projects", project)
+}
+
+// threads returns the thread storage of a project
+func (m *Manager) threads(project string) *ThreadStorage {
+	if project == "" || project == DefaultProject {
+		return m.threadStorage
+	}
+	return NewThreadStorage(filepath.Join(m.projectDir(project), "threads"))
+}
+
+// workerThreads returns the thread storage of a worker's project. Unknown
+// workers use the default project's storage, which has no thread for them.
+func (m *Manager) workerThreads(workerID string) *ThreadStorage {
+	workers, err := m.loadWorkers()
+	if err != nil {
+		return m.threadStorage
+	}
+	if worker, exists := workers[workerID]; exists {
+		return m.threads(worker.Project)
+	}
+	return m.threadStorage
+}
+
+// CreateProject adds a project
+func (m *Manager) CreateProject(project Project) (*Project, error) {
+	if !projectNamePattern.MatchString(project.Name) {
+		return nil, fmt.Errorf("%w %q: use lowercase letters, digits, '.', '_' and '-'", ErrInvalidProjectName, project.Name)
+	}
+	if err := m.validateProjectExecution(project.Amp.Execution); err != nil {
+		return nil, err
+	}
+
+	m.projectsMu.Lock()
+	defer m.projectsMu.Unlock()
+
+	projects, err := m.loadProjects()
+	if err != nil {
+		return nil, err
+	}
+	if _, exists := projects[project.Name]; exists || project.Name == DefaultProject {
+		return nil, fmt.Errorf("cannot create project %s: it already exists", project.Name)
+	}
+
+	if err := os.MkdirAll(m.projectDir(project.Name), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create project directory: %w", err)
+	}
+
+	project.Created = time.Now()
+	projects[project.Name] = &project
+	if err := m.saveProjects(projects); err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// GetProject returns a project by name
+func (m *Manager) GetProject(name string) (*Project, error) {
+	m.projectsMu.Lock()
+	defer m.projectsMu.Unlock()
+
+	projects, err := m.loadProjects()
+	if err != nil {
+		return nil, err
+	}
+	project, exists := projects[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrProjectNotFound, name)
+	}
+	return project, nil
+}
+
+// ListProjects returns every project sorted by name
+func (m *Manager) ListProjects() ([]*Project, error) {
+	m.projectsMu.Lock()
+	defer m.projectsMu.Unlock()
+
+	projects, err := m.loadProjects()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*Project, 0, len(projects))
+	for _, project := range projects {
+		list = append(list, project)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// UpdateProject changes a project's settings
+func (m *Manager) UpdateProject(name string, update ProjectUpdate) (*Project, error) {
+	if update.Execution != nil {
+		if err := m.validateProjectExecution(*update.Execution); err != nil {
+			return nil, err
+		}
+	}
+
+	m.projectsMu.Lock()
+	defer m.projectsMu.Unlock()
+
+	projects, err := m.loadProjects()
+	if err != nil {
+		return nil, err
+	}
+	project, exists := projects[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrProjectNotFound, name)
+	}
+
+	if update.RepoURL != nil {
+		project.RepoURL = *update.RepoURL
+	}
+	if update.DefaultBranch != nil {
+		project.DefaultBranch = *update.DefaultBranch
+	}
+	if update.Execution != nil {
+		project.Amp.Execution = *update.Execution
+	}
+	if update.Dir != nil {
+		project.Amp.Dir = *update.Dir
+	}
+
+	if err := m.saveProjects(projects); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+// DeleteProject removes a project that no longer has tasks
+func (m *Manager) DeleteProject(name string) error {
+	if name == DefaultProject {
+		return fmt.Errorf("cannot delete the %s project", DefaultProject)
+	}
+
+	workers, err := m.loadWorkers()
+	if err != nil {
+		return err
+	}
+	count := 0
+	for _, worker := range workers {
+		if worker.Project == name {
+			count++
+		}
+	}
+	if count > 0 {
+		return fmt.Errorf("cannot delete project %s: it has %d tasks", name, count)
+	}
+
+	m.projectsMu.Lock()
+	defer m.projectsMu.Unlock()
+
+	projects, err := m.loadProjects()
+	if err != nil {
+		return err
+	}
+	if _, exists := projects[name]; !exists {
+		return fmt.Errorf("%w: %s", ErrProjectNotFound, name)
+	}
+	delete(projects, name)
+	return m.saveProjects(projects)
+}
+
+// validateProjectExecution checks a project's default execution mode, which
+// may be empty to use the daemon's
+func (m *Manager) validateProjectExecution(mode ExecutionMode) error {
+	if mode == "" {
+		return nil
+	}
+	_, err := m.executionMode(mode)
+	return err
+}
+
+// loadProjects reads the saved projects, adding the default project when it
+// hasn't been saved. The caller must hold projectsMu.
+func (m *Manager) loadProjects() (map[string]*Project, error) {
+	projects := make(map[string]*Project)
+
+	data, err := os.ReadFile(m.projectsFile())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if _, err := projectsFormat.Decode(data, &projects); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, exists := projects[DefaultProject]; !exists {
+		projects[DefaultProject] = &Project{Name: DefaultProject}
+	}
+	return projects, nil
+}
+
+// saveProjects replaces the saved projects. The caller must hold projectsMu.
+func (m *Manager) saveProjects(projects map[string]*Project) error {
+	data, err := projectsFormat.Encode(projects)
+	if err != nil {
+		return err
+	}
+
+	tmpFile := m.projectsFile() + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, m.projectsFile())
+}
+
+// projectsFile returns the path of the saved projects
+func (m *Manager) projectsFile() string {
+	return filepath.Join(m.logDir, "projects.json")
+}
@@ -0,0 +1,118 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjects_CRUD(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := NewManager(tmpDir)
+
+	// The default project always exists
+	projects, err := manager.ListProjects()
+	require.NoError(t, err)
+	require.Len(t, projects, 1)
+	assert.Equal(t, DefaultProject, projects[0].Name)
+
+	project, err := manager.CreateProject(Project{Name: "infra", RepoURL: "git@example.com:infra.git", DefaultBranch: "main"})
+	require.NoError(t, err)
+	assert.False(t, project.Created.IsZero())
+	assert.DirExists(t, filepath.Join(tmpDir, "projects", "infra"))
+
+	_, err = manager.CreateProject(Project{Name: "infra"})
+	assert.ErrorContains(t, err, "already exists")
+	_, err = manager.CreateProject(Project{Name: DefaultProject})
+	assert.ErrorContains(t, err, "already exists")
+	_, err = manager.CreateProject(Project{Name: "../escape"})
+	assert.ErrorIs(t, err, ErrInvalidProjectName)
+	_, err = manager.CreateProject(Project{Name: "remote", Amp: AmpSettings{Execution: ExecutionRemote}})
+	assert.ErrorIs(t, err, ErrRemoteNotConfigured)
+
+	branch := "develop"
+	project, err = manager.UpdateProject("infra", ProjectUpdate{DefaultBranch: &branch})
+	require.NoError(t, err)
+	assert.Equal(t, "develop", project.DefaultBranch)
+	assert.Equal(t, "git@example.com:infra.git", project.RepoURL)
+
+	// Changes to the default project are saved like any other
+	dir := "/srv/checkout"
+	_, err = manager.UpdateProject(DefaultProject, ProjectUpdate{Dir: &dir})
+	require.NoError(t, err)
+	project, err = manager.GetProject(DefaultProject)
+	require.NoError(t, err)
+	assert.Equal(t, "/srv/checkout", project.Amp.Dir)
+
+	_, err = manager.UpdateProject("missing", ProjectUpdate{})
+	assert.ErrorIs(t, err, ErrProjectNotFound)
+
+	projects, err = manager.ListProjects()
+	require.NoError(t, err)
+	require.Len(t, projects, 2)
+	assert.Equal(t, DefaultProject, projects[0].Name)
+	assert.Equal(t, "infra", projects[1].Name)
+}
+
+func TestDeleteProject(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := NewManager(tmpDir)
+	_, err := manager.CreateProject(Project{Name: "infra"})
+	require.NoError(t, err)
+	_, err = manager.CreateProject(Project{Name: "docs"})
+	require.NoError(t, err)
+	require.NoError(t, manager.SaveWorkersForTest(map[string]*Worker{
+		"w1": {ID: "w1", Status: StatusStopped, Project: "infra"},
+	}, filepath.Join(tmpDir, "workers.json")))
+
+	assert.ErrorContains(t, manager.DeleteProject(DefaultProject), "cannot delete")
+	assert.ErrorContains(t, manager.DeleteProject("infra"), "cannot delete project infra: it has 1 tasks")
+	assert.ErrorIs(t, manager.DeleteProject("missing"), ErrProjectNotFound)
+
+	require.NoError(t, manager.DeleteProject("docs"))
+	_, err = manager.GetProject("docs")
+	assert.ErrorIs(t, err, ErrProjectNotFound)
+}
+
+func TestStartWorker_Project(t *testing.T) {
+	tmpDir := t.TempDir()
+	scriptPath := filepath.Join(tmpDir, "dummy-amp")
+	require.NoError(t, os.WriteFile(scriptPath, []byte("#!/bin/bash\necho T-project\n"), 0755))
+
+	manager := NewManager(tmpDir)
+	manager.SetAmpBinary(scriptPath)
+	_, err := manager.CreateProject(Project{Name: "infra"})
+	require.NoError(t, err)
+
+	_, err = manager.StartWorkerWithOptions(context.Background(), "hello", StartOptions{Project: "missing"})
+	assert.ErrorIs(t, err, ErrProjectNotFound)
+
+	// Logs and threads are kept in the project's directory
+	parent, err := manager.StartWorkerWithOptions(context.Background(), "hello", StartOptions{Project: "infra"})
+	require.NoError(t, err)
+	assert.Equal(t, "infra", parent.Project)
+	projectDir := filepath.Join(tmpDir, "projects", "infra")
+	assert.Equal(t, projectDir, filepath.Dir(parent.LogFile))
+	assert.Equal(t, projectDir, filepath.Dir(parent.AmpLogFile))
+
+	require.NoError(t, manager.AppendThreadMessage(parent.ID, MessageTypeUser, "hello", nil))
+	assert.FileExists(t, filepath.Join(projectDir, "threads", "thread_"+parent.ID+".jsonl"))
+	count, err := manager.CountThreadMessages(parent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// Subtasks inherit their parent's project
+	child, err := manager.StartWorkerWithOptions(context.Background(), "more", StartOptions{ParentID: parent.ID})
+	require.NoError(t, err)
+	assert.Equal(t, "infra", child.Project)
+
+	// Tasks started without a project keep using the log directory
+	other, err := manager.StartWorkerWithOptions(context.Background(), "hello", StartOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultProject, other.Project)
+	assert.Equal(t, tmpDir, filepath.Dir(other.LogFile))
+}
@@ -0,0 +1,113 @@
+package worker
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CIStatus summarises the CI checks on a task's pull request
+type CIStatus string
+
+const (
+	CIPending CIStatus = "pending" // Some checks haven't finished, or none have reported yet
+	CIPassing CIStatus = "passing" // Every check succeeded
+	CIFailing CIStatus = "failing" // At least one check failed
+)
+
+// PullRequestLink ties a worker to the pull request opened for its changes,
+// so the results of CI checks on it can be reflected on the task
+type PullRequestLink struct {
+	Repo      string              `json:"repo"` // "owner/name"
+	Number    int                 `json:"number"`
+	URL       string              `json:"url,omitempty"`
+	CIStatus  CIStatus            `json:"ci_status"`
+	Checks    map[string]CIStatus `json:"checks,omitempty"` // Latest status of each check by name
+	UpdatedAt time.Time           `json:"updated_at"`
+}
+
+// matches reports whether the link refers to the given pull request
+func (l *PullRequestLink) matches(repo string, number int) bool {
+	return l != nil && strings.EqualFold(l.Repo, repo) && l.Number == number
+}
+
+// ciStatus combines the status of each check: failing if any check failed,
+// otherwise pending until every check passed
+func ciStatus(checks map[string]CIStatus) CIStatus {
+	if len(checks) == 0 {
+		return CIPending
+	}
+
+	status := CIPassing
+	for _, check := range checks {
+		switch check {
+		case CIFailing:
+			return CIFailing
+		case CIPending:
+			status = CIPending
+		}
+	}
+	return status
+}
+
+// LinkPullRequest records the pull request opened for a worker's changes.
+// Its CI status is pending until checks report on it.
+func (m *Manager) LinkPullRequest(workerID, repo string, number int, url string) (*PullRequestLink, error) {
+	if repo == "" || number <= 0 {
+		return nil, fmt.Errorf("cannot link pull request to worker %s: repo and number are required", workerID)
+	}
+
+	link := &PullRequestLink{
+		Repo:      repo,
+		Number:    number,
+		URL:       url,
+		CIStatus:  CIPending,
+		UpdatedAt: time.Now(),
+	}
+	_, err := m.updateWorker(workerID, func(worker *Worker) error {
+		// Relinking the same pull request keeps the checks already reported
+		if worker.PullRequest.matches(repo, number) {
+			link.Checks = worker.PullRequest.Checks
+			link.CIStatus = ciStatus(link.Checks)
+			if url == "" {
+				link.URL = worker.PullRequest.URL
+			}
+		}
+		worker.PullRequest = link
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// UpdateCheck records the status of a CI check on a pull request for every
+// worker linked to it, returning the IDs of the workers updated
+func (m *Manager) UpdateCheck(repo string, number int, check string, status CIStatus) ([]string, error) {
+	updated := []string{}
+	err := m.updateWorkers(func(workers map[string]*Worker) error {
+		for id, worker := range workers {
+			link := worker.PullRequest
+			if !link.matches(repo, number) {
+				continue
+			}
+
+			if link.Checks == nil {
+				link.Checks = make(map[string]CIStatus)
+			}
+			link.Checks[check] = status
+			link.CIStatus = ciStatus(link.Checks)
+			link.UpdatedAt = time.Now()
+			updated = append(updated, id)
+		}
+		if len(updated) == 0 {
+			return errUnchanged
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update worker state: %w", err)
+	}
+	return updated, nil
+}
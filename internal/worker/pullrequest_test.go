@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCIStatus(t *testing.T) {
+	assert.Equal(t, CIPending, ciStatus(nil))
+	assert.Equal(t, CIPassing, ciStatus(map[string]CIStatus{"test": CIPassing, "lint": CIPassing}))
+	assert.Equal(t, CIPending, ciStatus(map[string]CIStatus{"test": CIPassing, "lint": CIPending}))
+	assert.Equal(t, CIFailing, ciStatus(map[string]CIStatus{"test": CIFailing, "lint": CIPending}))
+}
+
+func TestUpdateCheck_UpdatesLinkedWorkers(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := NewManager(tmpDir)
+	workers := map[string]*Worker{
+		"linked":   {ID: "linked", Started: time.Now(), Status: StatusCompleted},
+		"unlinked": {ID: "unlinked", Started: time.Now(), Status: StatusCompleted},
+	}
+	require.NoError(t, manager.SaveWorkersForTest(workers, filepath.Join(tmpDir, "workers.json")))
+
+	link, err := manager.LinkPullRequest("linked", "acme/api", 7, "https://github.com/acme/api/pull/7")
+	require.NoError(t, err)
+	assert.Equal(t, CIPending, link.CIStatus)
+
+	updated, err := manager.UpdateCheck("Acme/API", 7, "test", CIPassing)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"linked"}, updated)
+	assert.Equal(t, CIPassing, findTestWorker(t, manager, "linked").PullRequest.CIStatus)
+
+	updated, err = manager.UpdateCheck("acme/api", 7, "lint", CIFailing)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"linked"}, updated)
+	assert.Equal(t, CIFailing, findTestWorker(t, manager, "linked").PullRequest.CIStatus)
+
+	// A rerun that passes clears the failure
+	_, err = manager.UpdateCheck("acme/api", 7, "lint", CIPassing)
+	require.NoError(t, err)
+	assert.Equal(t, CIPassing, findTestWorker(t, manager, "linked").PullRequest.CIStatus)
+
+	// Relinking the same pull request keeps its checks
+	link, err = manager.LinkPullRequest("linked", "acme/api", 7, "")
+	require.NoError(t, err)
+	assert.Equal(t, CIPassing, link.CIStatus)
+	assert.Equal(t, "https://github.com/acme/api/pull/7", link.URL)
+
+	updated, err = manager.UpdateCheck("acme/api", 8, "test", CIFailing)
+	require.NoError(t, err)
+	assert.Empty(t, updated)
+	assert.Nil(t, findTestWorker(t, manager, "unlinked").PullRequest)
+
+	_, err = manager.LinkPullRequest("missing", "acme/api", 7, "")
+	assert.ErrorContains(t, err, "not found")
+	_, err = manager.LinkPullRequest("linked", "", 7, "")
+	assert.Error(t, err)
+}
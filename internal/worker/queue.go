@@ -0,0 +1,95 @@
+package worker
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// QueuedMessage is a message waiting for amp to finish answering the ones sent
+// to a worker before it
+type QueuedMessage struct {
+	Attempt int       `json:"attempt"` // Number of the attempt that will send it
+	Message string    `json:"message"`
+	Queued  time.Time `json:"queued"`
+}
+
+// pendingContinue is a continue waiting in a worker's queue
+type pendingContinue struct {
+	QueuedMessage
+	ctx  context.Context // Bounds the wait for the rate limiter
+	tee  io.Writer
+	done chan error // Receives the result once amp has answered
+}
+
+// SetContinueCallback sets the callback run after amp has answered a message
+// sent to a worker, with the attempt that sent it
+func (m *Manager) SetContinueCallback(callback func(workerID string, attempt Attempt)) {
+	m.onContinue = callback
+}
+
+// enqueueContinue sends message to a worker's thread as attempt once amp has
+// answered the messages sent before it, and returns the channel receiving the
+// result. The first message sent to an idle worker is dispatched at once.
+func (m *Manager) enqueueContinue(ctx context.Context, workerID string, attempt int, message string, tee io.Writer) <-chan error {
+	c := &pendingContinue{
+		QueuedMessage: QueuedMessage{Attempt: attempt, Message: message, Queued: time.Now()},
+		ctx:           ctx,
+		tee:           tee,
+		done:          make(chan error, 1),
+	}
+
+	m.queuesMu.Lock()
+	defer m.queuesMu.Unlock()
+	if m.queues == nil {
+		m.queues = make(map[string][]*pendingContinue)
+	}
+	if pending, busy := m.queues[workerID]; busy {
+		m.queues[workerID] = append(pending, c)
+		return c.done
+	}
+	m.queues[workerID] = []*pendingContinue{}
+	go m.dispatchContinues(workerID, c)
+	return c.done
+}
+
+// dispatchContinues sends the queued messages of a worker one at a time,
+// starting with next, until its queue is empty
+func (m *Manager) dispatchContinues(workerID string, next *pendingContinue) {
+	for next != nil {
+		code, err := m.sendContinue(next.ctx, workerID, next.Attempt, next.Message, next.tee)
+		m.endContinueAttempt(workerID, next.Attempt, code)
+		next.done <- err
+		next = m.nextContinue(workerID)
+	}
+}
+
+// nextContinue removes the oldest message from a worker's queue. It returns
+// nil and marks the worker idle when the queue is empty.
+func (m *Manager) nextContinue(workerID string) *pendingContinue {
+	m.queuesMu.Lock()
+	defer m.queuesMu.Unlock()
+	pending := m.queues[workerID]
+	if len(pending) == 0 {
+		delete(m.queues, workerID)
+		return nil
+	}
+	m.queues[workerID] = pending[1:]
+	return pending[0]
+}
+
+// QueuedMessages returns the messages waiting to be sent to a worker, oldest
+// first. The message amp is answering isn't included.
+func (m *Manager) QueuedMessages(workerID string) ([]QueuedMessage, error) {
+	if _, err := m.findWorker(workerID); err != nil {
+		return nil, err
+	}
+
+	m.queuesMu.Lock()
+	defer m.queuesMu.Unlock()
+	queued := []QueuedMessage{}
+	for _, c := range m.queues[workerID] {
+		queued = append(queued, c.QueuedMessage)
+	}
+	return queued, nil
+}
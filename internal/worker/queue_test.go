@@ -0,0 +1,154 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupQueueManager returns a manager whose fake amp keeps a worker's
+// process running and answers each continue in 0.2s, recording when it
+// starts and ends answering in the returned file
+func setupQueueManager(t *testing.T) (*Manager, string) {
+	tmpDir := t.TempDir()
+	runs := filepath.Join(tmpDir, "runs")
+	script := `#!/bin/bash
+if [ "$1" = "threads" ] && [ "$2" = "new" ]; then
+	echo "T-queue"
+	exit 0
+fi
+message=$(cat)
+if [ "$message" = "fix the build" ]; then
+	sleep 5
+	exit 0
+fi
+echo "start $message" >> "` + runs + `"
+sleep 0.2
+echo "end $message" >> "` + runs + `"
+`
+	scriptPath := filepath.Join(tmpDir, "amp")
+	require.NoError(t, os.WriteFile(scriptPath, []byte(script), 0755))
+	manager := NewManager(tmpDir)
+	manager.SetAmpBinary(scriptPath)
+	return manager, runs
+}
+
+func TestManager_ContinueWorkerQueues(t *testing.T) {
+	manager, runs := setupQueueManager(t)
+	worker, err := manager.StartWorkerWithOptions(context.Background(), "fix the build", StartOptions{})
+	require.NoError(t, err)
+	t.Cleanup(func() { manager.StopWorker(worker.ID) })
+
+	results := make(chan error, 3)
+	send := func(message string) {
+		go func() { results <- manager.ContinueWorker(context.Background(), worker.ID, message) }()
+	}
+	send("a")
+	require.Eventually(t, func() bool {
+		contents, _ := os.ReadFile(runs)
+		return strings.Contains(string(contents), "start a")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Messages sent while amp answers wait for it in order
+	send("b")
+	require.Eventually(t, func() bool {
+		queued, err := manager.QueuedMessages(worker.ID)
+		return err == nil && len(queued) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	send("c")
+	require.Eventually(t, func() bool {
+		queued, err := manager.QueuedMessages(worker.ID)
+		return err == nil && len(queued) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	queued, err := manager.QueuedMessages(worker.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "b", queued[0].Message)
+	assert.Equal(t, "c", queued[1].Message)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, <-results)
+	}
+	contents, err := os.ReadFile(runs)
+	require.NoError(t, err)
+	assert.Equal(t, "start a\nend a\nstart b\nend b\nstart c\nend c\n", string(contents))
+
+	queued, err = manager.QueuedMessages(worker.ID)
+	require.NoError(t, err)
+	assert.Empty(t, queued)
+
+	_, err = manager.QueuedMessages("missing")
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestManager_ContinueWorkerDropsCancelled(t *testing.T) {
+	manager, runs := setupQueueManager(t)
+	worker, err := manager.StartWorkerWithOptions(context.Background(), "fix the build", StartOptions{})
+	require.NoError(t, err)
+	t.Cleanup(func() { manager.StopWorker(worker.ID) })
+
+	first := make(chan error, 1)
+	go func() { first <- manager.ContinueWorker(context.Background(), worker.ID, "a") }()
+	require.Eventually(t, func() bool {
+		contents, _ := os.ReadFile(runs)
+		return strings.Contains(string(contents), "start a")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// A message whose caller gives up while it's queued is never sent
+	ctx, cancel := context.WithCancel(context.Background())
+	second := make(chan error, 1)
+	go func() { second <- manager.ContinueWorker(ctx, worker.ID, "b") }()
+	require.Eventually(t, func() bool {
+		queued, err := manager.QueuedMessages(worker.ID)
+		return err == nil && len(queued) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-second, context.Canceled)
+
+	require.NoError(t, <-first)
+	require.Eventually(t, func() bool {
+		queued, err := manager.QueuedMessages(worker.ID)
+		return err == nil && len(queued) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, manager.ContinueWorker(context.Background(), worker.ID, "c"))
+
+	contents, err := os.ReadFile(runs)
+	require.NoError(t, err)
+	assert.Equal(t, "start a\nend a\nstart c\nend c\n", string(contents))
+}
+
+func TestManager_QueueContinue(t *testing.T) {
+	manager, runs := setupQueueManager(t)
+	finished := make(chan Attempt, 1)
+	manager.SetContinueCallback(func(workerID string, attempt Attempt) { finished <- attempt })
+	worker, err := manager.StartWorkerWithOptions(context.Background(), "fix the build", StartOptions{})
+	require.NoError(t, err)
+	t.Cleanup(func() { manager.StopWorker(worker.ID) })
+
+	// The attempt is returned before amp answers
+	attempt, err := manager.QueueContinue(worker.ID, "a")
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempt)
+	contents, _ := os.ReadFile(runs)
+	assert.NotContains(t, string(contents), "end a")
+
+	select {
+	case ended := <-finished:
+		assert.Equal(t, 2, ended.Number)
+		require.NotNil(t, ended.ExitCode)
+		assert.Equal(t, 0, *ended.ExitCode)
+	case <-time.After(5 * time.Second):
+		t.Fatal("continue callback didn't run")
+	}
+	contents, err = os.ReadFile(runs)
+	require.NoError(t, err)
+	assert.Equal(t, "start a\nend a\n", string(contents))
+
+	_, err = manager.QueueContinue("missing", "a")
+	assert.ErrorContains(t, err, "not found")
+}
@@ -0,0 +1,52 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// ErrDiskQuotaExceeded is returned when a worker can't be started because the
+// log directory uses more than its quota
+var ErrDiskQuotaExceeded = errors.New("log directory quota exceeded")
+
+// QuotaEvent reports that the log directory exceeded its quota
+type QuotaEvent struct {
+	UsedBytes  int64
+	LimitBytes int64
+}
+
+// SetLogDirQuota refuses to start workers while the log directory uses limit
+// bytes or more; 0 removes the limit. onExceeded is called when a start is
+// first refused, and again only after usage has dropped below the limit in
+// between, e.g. to trigger retention cleanup.
+func (m *Manager) SetLogDirQuota(limit int64, onExceeded func(QuotaEvent)) {
+	m.logDirQuota = limit
+	m.onOverQuota = onExceeded
+}
+
+// checkQuota returns ErrDiskQuotaExceeded when the log directory is over its
+// quota. A directory that can't be measured doesn't block new workers.
+func (m *Manager) checkQuota() error {
+	if m.logDirQuota <= 0 {
+		return nil
+	}
+
+	usage, err := m.StorageUsage()
+	if err != nil {
+		log.Printf("Failed to measure log directory for its quota: %v", err)
+		return nil
+	}
+	if usage.LogDirBytes < m.logDirQuota {
+		m.overQuota.Store(false)
+		return nil
+	}
+
+	if !m.overQuota.Swap(true) {
+		log.Printf("Log directory uses %d bytes, over its quota of %d; refusing new tasks", usage.LogDirBytes, m.logDirQuota)
+		if m.onOverQuota != nil {
+			m.onOverQuota(QuotaEvent{UsedBytes: usage.LogDirBytes, LimitBytes: m.logDirQuota})
+		}
+	}
+	return fmt.Errorf("%w: %d of %d bytes used", ErrDiskQuotaExceeded, usage.LogDirBytes, m.logDirQuota)
+}
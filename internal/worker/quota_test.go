@@ -0,0 +1,44 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_LogDirQuota(t *testing.T) {
+	logDir := t.TempDir()
+	manager := NewManager(logDir)
+	var events []QuotaEvent
+	manager.SetLogDirQuota(100, func(event QuotaEvent) {
+		events = append(events, event)
+	})
+
+	// Under the quota, starts aren't refused for it
+	require.NoError(t, manager.checkQuota())
+
+	big := filepath.Join(logDir, "worker-old.log")
+	require.NoError(t, os.WriteFile(big, []byte(strings.Repeat("x", 150)), 0644))
+	_, err := manager.StartWorkerWithOptions(context.Background(), "hello", StartOptions{})
+	assert.ErrorIs(t, err, ErrDiskQuotaExceeded)
+	_, err = manager.StartWorkerWithOptions(context.Background(), "hello", StartOptions{})
+	assert.ErrorIs(t, err, ErrDiskQuotaExceeded)
+	require.Len(t, events, 1, "reported once while over quota")
+	assert.Equal(t, QuotaEvent{UsedBytes: 150, LimitBytes: 100}, events[0])
+
+	usage, err := manager.StorageUsage()
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), usage.QuotaBytes)
+
+	// Reported again after dropping below the quota and exceeding it once more
+	require.NoError(t, os.Remove(big))
+	require.NoError(t, manager.checkQuota())
+	require.NoError(t, os.WriteFile(big, []byte(strings.Repeat("x", 120)), 0644))
+	assert.ErrorIs(t, manager.checkQuota(), ErrDiskQuotaExceeded)
+	assert.Len(t, events, 2)
+}
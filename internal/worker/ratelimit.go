@@ -0,0 +1,215 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when an amp invocation would wait longer than
+// the limiter's maximum wait
+var ErrRateLimited = errors.New("amp invocation rate limit exceeded")
+
+// InvocationKind identifies the type of amp CLI invocation being rate limited
+type InvocationKind string
+
+const (
+	InvocationThreadCreate InvocationKind = "thread-create" // amp threads new
+	InvocationContinue     InvocationKind = "continue"      // amp threads continue
+)
+
+// RateLimitConfig limits amp CLI invocations per minute
+type RateLimitConfig struct {
+	ThreadsPerMinute   int           // 0 means unlimited
+	ContinuesPerMinute int           // 0 means unlimited
+	MaxWait            time.Duration // Longest an invocation may be delayed; 0 waits indefinitely
+}
+
+// RateLimitEvent reports that invocations of a kind have started queueing
+type RateLimitEvent struct {
+	Kind    InvocationKind
+	Limit   int
+	Waiting int
+}
+
+// RateLimitStats describes the current state of a rate limit
+type RateLimitStats struct {
+	Kind      InvocationKind `json:"kind"`
+	Limit     int            `json:"limit"`     // Invocations allowed per minute
+	InWindow  int            `json:"in_window"` // Invocations in the last minute
+	Waiting   int            `json:"waiting"`   // Invocations currently delayed
+	Throttled uint64         `json:"throttled"` // Invocations that had to wait
+	Rejected  uint64         `json:"rejected"`  // Invocations that gave up waiting
+	Saturated bool           `json:"saturated"`
+}
+
+// rateLimit tracks invocations of one kind over a sliding window
+type rateLimit struct {
+	limit     int
+	recent    []time.Time
+	waiting   int
+	throttled uint64
+	rejected  uint64
+	saturated bool
+}
+
+// RateLimiter delays amp invocations that exceed per-minute limits, shared by
+// all workers so upstream API rate limits are respected globally
+type RateLimiter struct {
+	mu          sync.Mutex
+	limits      map[InvocationKind]*rateLimit
+	maxWait     time.Duration
+	window      time.Duration
+	onSaturated func(RateLimitEvent)
+}
+
+// NewRateLimiter creates a rate limiter for amp invocations
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	return newRateLimiter(config, time.Minute)
+}
+
+func newRateLimiter(config RateLimitConfig, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limits: map[InvocationKind]*rateLimit{
+			InvocationThreadCreate: {limit: config.ThreadsPerMinute},
+			InvocationContinue:     {limit: config.ContinuesPerMinute},
+		},
+		maxWait: config.MaxWait,
+		window:  window,
+	}
+}
+
+// SetSaturationCallback sets the callback invoked when invocations of a kind
+// start being delayed
+func (l *RateLimiter) SetSaturationCallback(callback func(RateLimitEvent)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onSaturated = callback
+}
+
+// Wait blocks until an invocation of the given kind is allowed. It returns
+// ErrRateLimited if that would take longer than the maximum wait.
+func (l *RateLimiter) Wait(ctx context.Context, kind InvocationKind) error {
+	if l == nil {
+		return nil
+	}
+
+	var deadline time.Time
+	queued := false
+	defer func() {
+		if queued {
+			l.mu.Lock()
+			l.limits[kind].waiting--
+			l.mu.Unlock()
+		}
+	}()
+
+	for {
+		l.mu.Lock()
+		rl := l.limits[kind]
+		if rl == nil || rl.limit <= 0 {
+			l.mu.Unlock()
+			return nil
+		}
+
+		now := time.Now()
+		rl.prune(now, l.window)
+		if len(rl.recent) < rl.limit {
+			rl.recent = append(rl.recent, now)
+
+			// Saturation ends once nobody else is queued behind this invocation
+			others := rl.waiting
+			if queued {
+				others--
+			}
+			if others == 0 {
+				rl.saturated = false
+			}
+			l.mu.Unlock()
+			return nil
+		}
+
+		var event *RateLimitEvent
+		if !queued {
+			queued = true
+			rl.waiting++
+			rl.throttled++
+			if l.maxWait > 0 {
+				deadline = now.Add(l.maxWait)
+			}
+			if !rl.saturated {
+				rl.saturated = true
+				event = &RateLimitEvent{Kind: kind, Limit: rl.limit, Waiting: rl.waiting}
+			}
+		}
+
+		delay := rl.recent[0].Add(l.window).Sub(now)
+		if !deadline.IsZero() && now.Add(delay).After(deadline) {
+			rl.rejected++
+			l.mu.Unlock()
+			return fmt.Errorf("%w: %d %s invocations per minute", ErrRateLimited, rl.limit, kind)
+		}
+		callback := l.onSaturated
+		l.mu.Unlock()
+
+		if event != nil {
+			log.Printf("Rate limit saturated for %s invocations (%d/min), delaying", kind, event.Limit)
+			if callback != nil {
+				callback(*event)
+			}
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Stats returns the state of each configured limit
+func (l *RateLimiter) Stats() []RateLimitStats {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var stats []RateLimitStats
+	for _, kind := range []InvocationKind{InvocationThreadCreate, InvocationContinue} {
+		rl := l.limits[kind]
+		if rl.limit <= 0 {
+			continue
+		}
+		rl.prune(now, l.window)
+		stats = append(stats, RateLimitStats{
+			Kind:      kind,
+			Limit:     rl.limit,
+			InWindow:  len(rl.recent),
+			Waiting:   rl.waiting,
+			Throttled: rl.throttled,
+			Rejected:  rl.rejected,
+			Saturated: rl.saturated,
+		})
+	}
+	return stats
+}
+
+// prune drops invocations that have left the window
+func (rl *rateLimit) prune(now time.Time, window time.Duration) {
+	cutoff := now.Add(-window)
+	drop := 0
+	for drop < len(rl.recent) && !rl.recent[drop].After(cutoff) {
+		drop++
+	}
+	if drop > 0 {
+		rl.recent = rl.recent[drop:]
+	}
+}
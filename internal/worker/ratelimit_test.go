@@ -0,0 +1,115 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter_Unlimited(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{}, 100*time.Millisecond)
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, limiter.Wait(context.Background(), InvocationContinue))
+	}
+	assert.Empty(t, limiter.Stats())
+}
+
+func TestRateLimiter_NilIsUnlimited(t *testing.T) {
+	var limiter *RateLimiter
+
+	assert.NoError(t, limiter.Wait(context.Background(), InvocationThreadCreate))
+	assert.Nil(t, limiter.Stats())
+}
+
+func TestRateLimiter_DelaysExcessInvocations(t *testing.T) {
+	window := 100 * time.Millisecond
+	limiter := newRateLimiter(RateLimitConfig{ContinuesPerMinute: 2}, window)
+
+	var mu sync.Mutex
+	var events []RateLimitEvent
+	limiter.SetSaturationCallback(func(e RateLimitEvent) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, limiter.Wait(context.Background(), InvocationContinue))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), window)
+
+	// Thread creation has no limit configured and isn't affected
+	require.NoError(t, limiter.Wait(context.Background(), InvocationThreadCreate))
+
+	mu.Lock()
+	require.Len(t, events, 1)
+	assert.Equal(t, InvocationContinue, events[0].Kind)
+	assert.Equal(t, 2, events[0].Limit)
+	mu.Unlock()
+
+	stats := limiter.Stats()
+	require.Len(t, stats, 1)
+	assert.Equal(t, InvocationContinue, stats[0].Kind)
+	assert.Equal(t, uint64(1), stats[0].Throttled)
+	assert.Equal(t, 0, stats[0].Waiting)
+	assert.False(t, stats[0].Saturated)
+}
+
+func TestRateLimiter_MaxWait(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{ThreadsPerMinute: 1, MaxWait: 10 * time.Millisecond}, time.Minute)
+
+	require.NoError(t, limiter.Wait(context.Background(), InvocationThreadCreate))
+
+	err := limiter.Wait(context.Background(), InvocationThreadCreate)
+	assert.True(t, errors.Is(err, ErrRateLimited))
+
+	stats := limiter.Stats()
+	require.Len(t, stats, 1)
+	assert.Equal(t, uint64(1), stats[0].Rejected)
+	assert.True(t, stats[0].Saturated)
+}
+
+func TestRateLimiter_ContextCancelled(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{ThreadsPerMinute: 1}, time.Minute)
+	require.NoError(t, limiter.Wait(context.Background(), InvocationThreadCreate))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := limiter.Wait(ctx, InvocationThreadCreate)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 0, limiter.Stats()[0].Waiting)
+}
+
+func TestStartWorker_RateLimit(t *testing.T) {
+	tmpDir := t.TempDir()
+	scriptPath := filepath.Join(tmpDir, "dummy-amp")
+	require.NoError(t, os.WriteFile(scriptPath, []byte("#!/bin/bash\necho T-limited\n"), 0755))
+
+	manager := NewManager(tmpDir)
+	manager.SetAmpBinary(scriptPath)
+	manager.SetRateLimiter(NewRateLimiter(RateLimitConfig{ThreadsPerMinute: 1, ContinuesPerMinute: 1}))
+
+	// A start spends one thread token and no continue token
+	_, err := manager.StartWorkerWithOptions(context.Background(), "hello", StartOptions{})
+	require.NoError(t, err)
+	stats := manager.RateLimitStats()
+	require.Len(t, stats, 2)
+	assert.Equal(t, 1, stats[0].InWindow)
+	assert.Equal(t, 0, stats[1].InWindow)
+
+	// The next start waits for a token only as long as its context allows
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = manager.StartWorkerWithOptions(ctx, "hello", StartOptions{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// ReadinessCheck verifies one component the manager needs to run workers
+type ReadinessCheck struct {
+	Name  string
+	Check func() error
+}
+
+// ReadinessChecks returns the checks that must pass before the manager can
+// accept work: the amp binary is executable, the log directory is writable
+// and the worker state can be loaded
+func (m *Manager) ReadinessChecks() []ReadinessCheck {
+	return []ReadinessCheck{
+		{Name: "amp_binary", Check: m.checkAmpBinary},
+		{Name: "log_dir", Check: m.checkLogDir},
+		{Name: "state", Check: m.checkState},
+	}
+}
+
+// checkAmpBinary verifies the amp binary can be found and is executable
+func (m *Manager) checkAmpBinary() error {
+	if _, err := exec.LookPath(m.ampBinaryPath); err != nil {
+		return fmt.Errorf("amp binary %q is not executable: %w", m.ampBinaryPath, err)
+	}
+	return nil
+}
+
+// checkLogDir verifies a file can be created in the log directory
+func (m *Manager) checkLogDir() error {
+	file, err := os.CreateTemp(m.logDir, ".readyz-*")
+	if err != nil {
+		return fmt.Errorf("log directory %q is not writable: %w", m.logDir, err)
+	}
+	file.Close()
+	os.Remove(file.Name())
+	return nil
+}
+
+// checkState verifies the worker state file can be read and parsed
+func (m *Manager) checkState() error {
+	if _, err := m.loadWorkers(); err != nil {
+		return fmt.Errorf("failed to load worker state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ExecutionRemote runs a worker's amp on a remote agent connected to the daemon
+const ExecutionRemote ExecutionMode = "remote"
+
+// ErrRemoteNotConfigured is returned when a worker asks for remote execution
+// but the daemon doesn't accept agents
+var ErrRemoteNotConfigured = errors.New("remote execution is not configured")
+
+// ErrNoAgentAvailable is returned when a worker asks for remote execution but
+// no connected agent has spare capacity
+var ErrNoAgentAvailable = errors.New("no remote agent available")
+
+// Invocation is one amp run dispatched to a remote agent
+type Invocation struct {
+	WorkerID string   `json:"worker_id"`
+	ThreadID string   `json:"thread_id"`
+	Message  string   `json:"message"`       // Piped to amp's stdin
+	Args     []string `json:"args"`          // amp arguments, without --log-file
+	AmpLog   bool     `json:"amp_log"`       // Run amp with a log file and stream it back
+	Env      []string `json:"env,omitempty"` // NAME=value pairs added to amp's environment
+}
+
+// AgentPool runs amp invocations on remote agents. Output is written to
+// stdout and, for invocations with AmpLog set, amp's log lines to ampLog.
+type AgentPool interface {
+	// Start dispatches inv to an agent, returning the agent's name and a
+	// channel that receives the exit code once all output has been written
+	Start(inv Invocation, stdout, ampLog io.Writer) (agent string, done <-chan int, err error)
+	// Signal sends signal (e.g. "SIGTERM") to the worker's running invocations
+	Signal(workerID, signal string) error
+	// Running reports whether any of the worker's invocations are still running
+	Running(workerID string) bool
+	// Available reports whether any agent has spare capacity
+	Available() bool
+}
+
+// SetAgentPool enables remote execution on the given pool's agents. It must be
+// called before SetExecution when remote execution is the default.
+func (m *Manager) SetAgentPool(pool AgentPool) {
+	m.agents = pool
+}
+
+// startRemote dispatches an amp invocation for worker to an agent, appending
+// its output to the worker's logs, and its stdout to tee when it isn't nil.
+// The returned wait function blocks until the invocation exits and its logs
+// are closed.
+func (m *Manager) startRemote(worker *Worker, message string, tee io.Writer, ampLog bool, args ...string) (func() int, error) {
+	if m.agents == nil {
+		return nil, ErrNoAgentAvailable
+	}
+	env, err := m.workerEnv(worker)
+	if err != nil {
+		return nil, err
+	}
+	args, err = m.invocationArgs(worker, args)
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := os.OpenFile(worker.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	files := []*os.File{stdout}
+	var stdoutWriter io.Writer = stdout
+	if tee != nil {
+		stdoutWriter = io.MultiWriter(stdout, tee)
+	}
+	closeFiles := func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}
+
+	var ampLogWriter io.Writer = io.Discard
+	if ampLog {
+		file, err := os.OpenFile(worker.AmpLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			closeFiles()
+			return nil, fmt.Errorf("failed to open amp log file: %w", err)
+		}
+		files = append(files, file)
+		ampLogWriter = file
+	}
+
+	agent, done, err := m.agents.Start(Invocation{
+		WorkerID: worker.ID,
+		ThreadID: worker.ThreadID,
+		Message:  message,
+		Args:     args,
+		Env:      env,
+		AmpLog:   ampLog,
+	}, stdoutWriter, ampLogWriter)
+	if err != nil {
+		closeFiles()
+		return nil, err
+	}
+	worker.Agent = agent
+
+	return func() int {
+		defer closeFiles()
+		return <-done
+	}, nil
+}
+
+// startRemoteWorker dispatches a worker's amp invocation to an agent, saves
+// the worker with save and monitors the invocation like a local process
+func (m *Manager) startRemoteWorker(worker *Worker, save func() error, message string, ampLog bool, args ...string) error {
+	wait, err := m.startRemote(worker, message, nil, ampLog, args...)
+	if err != nil {
+		return err
+	}
+	worker.PID = 0
+
+	if err := save(); err != nil {
+		m.signalRemote(worker, "SIGKILL")
+		return fmt.Errorf("failed to save worker state: %w", err)
+	}
+
+	m.startLogTailer(worker)
+	m.monitorExit(worker.ID, wait, m.handleWorkerExit)
+	return nil
+}
+
+// signalRemote sends signal to a remote worker's invocations, ignoring
+// failures since they may already have exited
+func (m *Manager) signalRemote(worker *Worker, signal string) {
+	if m.agents != nil {
+		m.agents.Signal(worker.ID, signal)
+	}
+}
@@ -0,0 +1,214 @@
+package worker
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePool runs invocations in-process, exiting them when signalled
+type fakePool struct {
+	mu          sync.Mutex
+	invocations []Invocation
+	signals     []string
+	running     map[string]chan int
+}
+
+func newFakePool() *fakePool {
+	return &fakePool{running: make(map[string]chan int)}
+}
+
+func (p *fakePool) Start(inv Invocation, stdout, ampLog io.Writer) (string, <-chan int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	io.WriteString(stdout, "ran: "+inv.Message+"\n")
+	io.WriteString(ampLog, `{"level":"info","message":"remote"}`+"\n")
+	p.invocations = append(p.invocations, inv)
+	done := make(chan int, 1)
+	p.running[inv.WorkerID] = done
+	return "build-1", done, nil
+}
+
+func (p *fakePool) Signal(workerID, signal string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.signals = append(p.signals, signal)
+	if done, ok := p.running[workerID]; ok {
+		delete(p.running, workerID)
+		done <- -1
+	}
+	return nil
+}
+
+func (p *fakePool) Available() bool {
+	return true
+}
+
+func (p *fakePool) Running(workerID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.running[workerID]
+	return ok
+}
+
+func TestExecutionMode_Remote(t *testing.T) {
+	manager := NewManager(t.TempDir())
+
+	_, err := manager.executionMode(ExecutionRemote)
+	assert.ErrorIs(t, err, ErrRemoteNotConfigured)
+
+	manager.SetAgentPool(newFakePool())
+	require.NoError(t, manager.SetExecution(ExecutionRemote, ContainerConfig{}))
+	mode, err := manager.executionMode("")
+	require.NoError(t, err)
+	assert.Equal(t, ExecutionRemote, mode)
+}
+
+func TestStartWorker_Remote(t *testing.T) {
+	tmpDir := t.TempDir()
+	scriptPath := filepath.Join(tmpDir, "dummy-amp")
+	require.NoError(t, os.WriteFile(scriptPath, []byte("#!/bin/bash\necho T-remote\n"), 0755))
+
+	manager := NewManager(tmpDir)
+	manager.SetAmpBinary(scriptPath)
+	pool := newFakePool()
+	manager.SetAgentPool(pool)
+	exited := make(chan string, 1)
+	manager.SetExitCallback(func(workerID string) { exited <- workerID })
+
+	worker, err := manager.StartWorkerWithOptions(context.Background(), "build it", StartOptions{Execution: ExecutionRemote})
+	require.NoError(t, err)
+	assert.Equal(t, "build-1", worker.Agent)
+	assert.Equal(t, 0, worker.PID)
+
+	require.Len(t, pool.invocations, 1)
+	assert.Equal(t, Invocation{
+		WorkerID: worker.ID,
+		ThreadID: "T-remote",
+		Message:  "build it",
+		Args:     []string{"--log-level=debug", "threads", "continue", "T-remote"},
+		AmpLog:   true,
+	}, pool.invocations[0])
+
+	saved := findTestWorker(t, manager, worker.ID)
+	assert.Equal(t, ExecutionRemote, saved.Execution)
+	assert.Equal(t, "build-1", saved.Agent)
+	assert.True(t, manager.checkProcessStatus(saved))
+
+	// Stopping signals the agent, whose run then exits
+	require.NoError(t, manager.StopWorker(worker.ID))
+	assert.Equal(t, []string{"SIGTERM"}, pool.signals)
+	select {
+	case id := <-exited:
+		assert.Equal(t, worker.ID, id)
+	case <-time.After(time.Second):
+		t.Fatal("worker exit not reported")
+	}
+	assert.Equal(t, StatusStopped, findTestWorker(t, manager, worker.ID).Status)
+
+	// Output streamed from the agent lands in the worker's logs
+	stdout, err := os.ReadFile(worker.LogFile)
+	require.NoError(t, err)
+	assert.Equal(t, "ran: build it\n", string(stdout))
+	ampLog, err := os.ReadFile(worker.AmpLogFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(ampLog), `"message":"remote"`)
+
+	// Retrying dispatches the thread to an agent again
+	require.NoError(t, manager.RetryWorker(context.Background(), worker.ID, "again"))
+	require.Len(t, pool.invocations, 2)
+	assert.Equal(t, []string{"threads", "continue", "T-remote"}, pool.invocations[1].Args)
+	assert.Equal(t, StatusRunning, findTestWorker(t, manager, worker.ID).Status)
+	require.NoError(t, manager.AbortWorker(worker.ID))
+	assert.Equal(t, []string{"SIGTERM", "SIGKILL"}, pool.signals)
+}
+
+func TestQueueContinue_Remote(t *testing.T) {
+	tmpDir := t.TempDir()
+	scriptPath := filepath.Join(tmpDir, "dummy-amp")
+	require.NoError(t, os.WriteFile(scriptPath, []byte("#!/bin/bash\necho T-remote\n"), 0755))
+
+	manager := NewManager(tmpDir)
+	manager.SetAmpBinary(scriptPath)
+	pool := newFakePool()
+	manager.SetAgentPool(pool)
+	answered := make(chan Attempt, 1)
+	manager.SetContinueCallback(func(workerID string, attempt Attempt) { answered <- attempt })
+
+	worker, err := manager.StartWorkerWithOptions(context.Background(), "build it", StartOptions{Execution: ExecutionRemote})
+	require.NoError(t, err)
+
+	// Queueing returns while the agent is still answering
+	attempt, err := manager.QueueContinue(worker.ID, "more")
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempt)
+	require.Eventually(t, func() bool {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		return len(pool.invocations) == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"threads", "continue", "T-remote"}, pool.invocations[1].Args)
+	assert.Empty(t, answered)
+
+	// The attempt finishes once the agent's run exits
+	pool.mu.Lock()
+	done := pool.running[worker.ID]
+	delete(pool.running, worker.ID)
+	pool.mu.Unlock()
+	done <- 0
+	select {
+	case finished := <-answered:
+		assert.Equal(t, attempt, finished.Number)
+		require.NotNil(t, finished.ExitCode)
+		assert.Equal(t, 0, *finished.ExitCode)
+	case <-time.After(time.Second):
+		t.Fatal("continue not reported")
+	}
+}
+
+func TestStartWorker_RemoteProfile(t *testing.T) {
+	tmpDir := t.TempDir()
+	scriptPath := filepath.Join(tmpDir, "dummy-amp")
+	require.NoError(t, os.WriteFile(scriptPath, []byte("#!/bin/bash\necho T-$AMP_API_KEY\n"), 0755))
+
+	manager := NewManager(tmpDir)
+	manager.SetAmpBinary(scriptPath)
+	manager.SetAmpProfiles(map[string]AmpProfile{
+		"staging": {Env: map[string]string{"AMP_API_KEY": "staging", "AMP_URL": "https://amp.staging.example.com"}},
+	})
+	pool := newFakePool()
+	manager.SetAgentPool(pool)
+
+	_, err := manager.StartWorkerWithOptions(context.Background(), "build it", StartOptions{Execution: ExecutionRemote, Profile: "prod"})
+	assert.ErrorIs(t, err, ErrUnknownProfile)
+	assert.Empty(t, pool.invocations)
+
+	// The thread is created and continued with the profile's credentials
+	worker, err := manager.StartWorkerWithOptions(context.Background(), "build it", StartOptions{Execution: ExecutionRemote, Profile: "staging"})
+	require.NoError(t, err)
+	assert.Equal(t, "T-staging", worker.ThreadID)
+	assert.Equal(t, "staging", findTestWorker(t, manager, worker.ID).Profile)
+	require.Len(t, pool.invocations, 1)
+	assert.Equal(t, []string{"AMP_API_KEY=staging", "AMP_URL=https://amp.staging.example.com"}, pool.invocations[0].Env)
+
+	// Removing the profile stops the worker from falling back to the daemon's credentials
+	manager.SetAmpProfiles(nil)
+	assert.ErrorIs(t, manager.ContinueWorker(context.Background(), worker.ID, "again"), ErrUnknownProfile)
+	assert.Len(t, pool.invocations, 1)
+}
+
+func TestStartWorker_RemoteNoAgent(t *testing.T) {
+	manager := NewManager(t.TempDir())
+
+	_, err := manager.StartWorkerWithOptions(context.Background(), "build it", StartOptions{Execution: ExecutionRemote})
+	assert.ErrorIs(t, err, ErrRemoteNotConfigured)
+}
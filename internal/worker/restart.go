@@ -0,0 +1,161 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// RestartMode says when a worker's amp process is restarted after it exits
+type RestartMode string
+
+const (
+	RestartNever     RestartMode = "never"      // Never restarted
+	RestartOnFailure RestartMode = "on-failure" // Restarted when amp exits with a non-zero code
+	RestartAlways    RestartMode = "always"     // Restarted whenever amp exits
+)
+
+const (
+	// DefaultMaxRestarts bounds a worker's restarts when its policy doesn't
+	DefaultMaxRestarts = 3
+
+	// DefaultRestartMessage is sent on the worker's thread when it's restarted
+	DefaultRestartMessage = "Your previous run ended before the task was finished. Continue with the task from where you left off."
+
+	// maxRestartDelay caps the wait before a restart
+	maxRestartDelay = time.Minute
+)
+
+// restartDelay is the wait before a worker's first restart, doubling with
+// each restart after it
+var restartDelay = time.Second
+
+// ErrInvalidRestartPolicy is returned when a task asks for an unknown restart
+// mode or a negative number of restarts
+var ErrInvalidRestartPolicy = errors.New("invalid restart policy")
+
+// RestartPolicy says when a worker's amp process is restarted automatically,
+// so flaky amp invocations recover without a manual retry. Processes ended on
+// request, by stopping, interrupting, aborting or winding down the worker, are
+// never restarted.
+type RestartPolicy struct {
+	Mode        RestartMode `json:"mode"`
+	MaxRestarts int         `json:"max_restarts,omitempty"` // Restarts over the worker's lifetime; 0 uses DefaultMaxRestarts
+}
+
+// validate checks the policy's mode and limit
+func (p RestartPolicy) validate() error {
+	switch p.Mode {
+	case RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return fmt.Errorf("%w: unknown mode %q", ErrInvalidRestartPolicy, p.Mode)
+	}
+	if p.MaxRestarts < 0 {
+		return fmt.Errorf("%w: max_restarts must not be negative", ErrInvalidRestartPolicy)
+	}
+	return nil
+}
+
+// shouldRestart reports whether a process exiting with exitCode is restarted
+// after restarts earlier restarts. A nil policy never restarts.
+func (p *RestartPolicy) shouldRestart(exitCode, restarts int) bool {
+	if p == nil || restarts >= p.limit() {
+		return false
+	}
+
+	switch p.Mode {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return exitCode != 0
+	}
+	return false
+}
+
+// limit returns the most restarts the policy allows
+func (p *RestartPolicy) limit() int {
+	if p.MaxRestarts == 0 {
+		return DefaultMaxRestarts
+	}
+	return p.MaxRestarts
+}
+
+// restartBackoff returns the wait before a worker restarted restarts times
+// already is restarted again
+func restartBackoff(restarts int) time.Duration {
+	delay := restartDelay
+	for i := 0; i < restarts && delay < maxRestartDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRestartDelay {
+		delay = maxRestartDelay
+	}
+	return delay
+}
+
+// SetRestartCallback sets the callback run after a worker is restarted
+func (m *Manager) SetRestartCallback(callback func(workerID string)) {
+	m.onRestart = callback
+}
+
+// markHalting records that a worker's process is being ended on request, so
+// its exit isn't restarted
+func (m *Manager) markHalting(workerID string) {
+	m.halting.Store(workerID, true)
+}
+
+// supervise restarts a worker whose process exited with exitCode when its
+// restart policy asks for it, after a delay growing with each restart. The
+// worker isn't restarted if its process was ended on request, or if it was
+// changed, retried or deleted during the delay.
+func (m *Manager) supervise(workerID string, exitCode int, halted bool) {
+	if halted {
+		return
+	}
+	if _, winding := m.windDowns.Load(workerID); winding {
+		return
+	}
+
+	workers, err := m.loadWorkers()
+	if err != nil {
+		log.Printf("Failed to load workers to supervise %s: %v", workerID, err)
+		return
+	}
+	worker, exists := workers[workerID]
+	if !exists || !worker.Restart.shouldRestart(exitCode, worker.Restarts) {
+		return
+	}
+
+	delay := restartBackoff(worker.Restarts)
+	log.Printf("Worker %s exited with code %d, restarting in %s", workerID, exitCode, delay)
+	time.Sleep(delay)
+
+	workers, err = m.loadWorkers()
+	if err != nil {
+		log.Printf("Failed to load workers to restart %s: %v", workerID, err)
+		return
+	}
+	worker, exists = workers[workerID]
+	if !exists || worker.Status != StatusStopped {
+		return
+	}
+	if _, halted := m.halting.LoadAndDelete(workerID); halted {
+		return
+	}
+
+	worker, err = m.relaunchWorker(context.Background(), worker, DefaultRestartMessage, AttemptRestart, func(saved *Worker) {
+		saved.Restarts++
+		saved.StatusReason = fmt.Sprintf("Restarted after amp exited with code %d", exitCode)
+	})
+	if err != nil {
+		log.Printf("Failed to restart worker %s: %v", workerID, err)
+		return
+	}
+	log.Printf("Worker %s restarted (%d/%d)", workerID, worker.Restarts, worker.Restart.limit())
+
+	if m.onRestart != nil {
+		m.onRestart(workerID)
+	}
+}
@@ -0,0 +1,137 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestartPolicy_ShouldRestart(t *testing.T) {
+	var none *RestartPolicy
+	assert.False(t, none.shouldRestart(1, 0))
+
+	never := &RestartPolicy{Mode: RestartNever}
+	assert.False(t, never.shouldRestart(1, 0))
+
+	onFailure := &RestartPolicy{Mode: RestartOnFailure, MaxRestarts: 2}
+	assert.True(t, onFailure.shouldRestart(1, 0))
+	assert.True(t, onFailure.shouldRestart(-1, 1))
+	assert.False(t, onFailure.shouldRestart(0, 0))
+	assert.False(t, onFailure.shouldRestart(1, 2))
+
+	always := &RestartPolicy{Mode: RestartAlways}
+	assert.True(t, always.shouldRestart(0, 0))
+	assert.True(t, always.shouldRestart(1, DefaultMaxRestarts-1))
+	assert.False(t, always.shouldRestart(0, DefaultMaxRestarts))
+}
+
+func TestRestartPolicy_Validate(t *testing.T) {
+	assert.NoError(t, RestartPolicy{Mode: RestartAlways, MaxRestarts: 5}.validate())
+	assert.ErrorIs(t, RestartPolicy{Mode: "sometimes"}.validate(), ErrInvalidRestartPolicy)
+	assert.ErrorIs(t, RestartPolicy{Mode: RestartOnFailure, MaxRestarts: -1}.validate(), ErrInvalidRestartPolicy)
+}
+
+func TestRestartBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, restartBackoff(0))
+	assert.Equal(t, 4*time.Second, restartBackoff(2))
+	assert.Equal(t, maxRestartDelay, restartBackoff(10))
+}
+
+// setupRestartManager returns a manager running a fake amp that records each
+// invocation in a file and exits with the given code
+func setupRestartManager(t *testing.T, code string) (*Manager, string) {
+	delay := restartDelay
+	restartDelay = 10 * time.Millisecond
+	t.Cleanup(func() { restartDelay = delay })
+
+	tmpDir := t.TempDir()
+	runs := filepath.Join(tmpDir, "runs")
+	script := `#!/bin/bash
+if [ "$1" = "threads" ] && [ "$2" = "new" ]; then
+	echo "T-restart"
+	exit 0
+fi
+cat > /dev/null
+echo run >> "` + runs + `"
+sleep 0.2
+exit ` + code + `
+`
+	scriptPath := filepath.Join(tmpDir, "amp")
+	require.NoError(t, os.WriteFile(scriptPath, []byte(script), 0755))
+
+	manager := NewManager(tmpDir)
+	manager.SetAmpBinary(scriptPath)
+	return manager, runs
+}
+
+// countRuns returns how many times the fake amp ran
+func countRuns(path string) int {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	return strings.Count(string(contents), "run\n")
+}
+
+func TestManager_RestartOnFailure(t *testing.T) {
+	manager, runs := setupRestartManager(t, "1")
+	restarted := make(chan string, 10)
+	manager.SetRestartCallback(func(workerID string) { restarted <- workerID })
+
+	worker, err := manager.StartWorkerWithOptions(context.Background(), "hello", StartOptions{
+		Restart: &RestartPolicy{Mode: RestartOnFailure, MaxRestarts: 2},
+	})
+	require.NoError(t, err)
+
+	// The first run and two restarts, after which the worker stays stopped
+	assert.Eventually(t, func() bool {
+		workers, err := manager.ListWorkers()
+		return err == nil && len(workers) == 1 && workers[0].Restarts == 2 && workers[0].Status == StatusStopped && countRuns(runs) == 3
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Len(t, restarted, 2)
+	assert.Equal(t, worker.ID, <-restarted)
+
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, 3, countRuns(runs))
+
+	workers, err := manager.ListWorkers()
+	require.NoError(t, err)
+	assert.Equal(t, "Restarted after amp exited with code 1", workers[0].StatusReason)
+}
+
+func TestManager_RestartSkipsSuccessAndStops(t *testing.T) {
+	manager, runs := setupRestartManager(t, "0")
+
+	// on-failure leaves a successful run alone
+	_, err := manager.StartWorkerWithOptions(context.Background(), "hello", StartOptions{
+		Restart: &RestartPolicy{Mode: RestartOnFailure},
+	})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		workers, err := manager.ListWorkers()
+		return err == nil && workers[0].Status == StatusStopped
+	}, 5*time.Second, 20*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 1, countRuns(runs))
+
+	// always doesn't restart a worker stopped on request
+	worker, err := manager.StartWorkerWithOptions(context.Background(), "hello", StartOptions{
+		Restart: &RestartPolicy{Mode: RestartAlways},
+	})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return countRuns(runs) == 2 }, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, manager.StopWorker(worker.ID))
+	time.Sleep(400 * time.Millisecond)
+	assert.Equal(t, 2, countRuns(runs))
+
+	_, err = manager.StartWorkerWithOptions(context.Background(), "hello", StartOptions{
+		Restart: &RestartPolicy{Mode: "sometimes"},
+	})
+	assert.ErrorIs(t, err, ErrInvalidRestartPolicy)
+}
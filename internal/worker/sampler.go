@@ -0,0 +1,230 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clockTicks is the kernel's USER_HZ, the unit of CPU times in /proc/<pid>/stat.
+// It is 100 on every Linux platform the daemon runs on.
+const clockTicks = 100
+
+// SamplerPolicy configures resource sampling
+type SamplerPolicy struct {
+	Interval time.Duration // How often running workers are sampled
+	Samples  int           // Samples kept per worker
+}
+
+// ResourceSample is the CPU and memory use of a worker's process group at
+// one point in time
+type ResourceSample struct {
+	Time       time.Time `json:"time"`
+	CPUPercent float64   `json:"cpu_percent"` // Since the previous sample; 100 is one core
+	RSSBytes   uint64    `json:"rss_bytes"`
+	Processes  int       `json:"processes"` // Processes in the worker's group, e.g. amp and the tools it runs
+}
+
+// cpuReading is a process group's cumulative CPU time when it was sampled
+type cpuReading struct {
+	ticks uint64
+	at    time.Time
+}
+
+// Sampler periodically reads /proc for the process group of every running
+// host worker and keeps a short series of samples per worker
+type Sampler struct {
+	manager  *Manager
+	policy   SamplerPolicy
+	onSample func(workerID string, sample ResourceSample)
+	procDir  string
+
+	mu     sync.Mutex
+	series map[string][]ResourceSample
+	last   map[string]cpuReading
+}
+
+// NewSampler creates a resource sampler for the manager's workers; onSample,
+// when set, is called with every new sample
+func NewSampler(manager *Manager, policy SamplerPolicy, onSample func(workerID string, sample ResourceSample)) *Sampler {
+	if policy.Interval <= 0 {
+		policy.Interval = 5 * time.Second
+	}
+	if policy.Samples <= 0 {
+		policy.Samples = 60
+	}
+	return &Sampler{
+		manager:  manager,
+		policy:   policy,
+		onSample: onSample,
+		procDir:  "/proc",
+		series:   make(map[string][]ResourceSample),
+		last:     make(map[string]cpuReading),
+	}
+}
+
+// Interval returns how often workers are sampled
+func (s *Sampler) Interval() time.Duration {
+	return s.policy.Interval
+}
+
+// Run samples running workers every interval until the context is cancelled
+func (s *Sampler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.policy.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.Sample(now)
+		}
+	}
+}
+
+// Sample reads the resource use of every running host worker once. Series of
+// workers that are no longer running are kept so finished tasks can still be
+// charted, until the worker is deleted.
+func (s *Sampler) Sample(now time.Time) {
+	workers, err := s.manager.ListWorkers()
+	if err != nil {
+		log.Printf("Sampler failed to list workers: %v", err)
+		return
+	}
+
+	known := make(map[string]bool, len(workers))
+	type taken struct {
+		workerID string
+		sample   ResourceSample
+	}
+	var samples []taken
+
+	s.mu.Lock()
+	for _, worker := range workers {
+		known[worker.ID] = true
+		if worker.Status != StatusRunning || worker.PID <= 0 {
+			delete(s.last, worker.ID)
+			continue
+		}
+		if worker.Execution != "" && worker.Execution != ExecutionHost {
+			continue
+		}
+
+		ticks, rss, processes, err := s.readProcessGroup(worker.PID)
+		if err != nil || processes == 0 {
+			delete(s.last, worker.ID)
+			continue
+		}
+
+		sample := ResourceSample{Time: now, RSSBytes: rss, Processes: processes}
+		if previous, ok := s.last[worker.ID]; ok && ticks >= previous.ticks {
+			if elapsed := now.Sub(previous.at).Seconds(); elapsed > 0 {
+				sample.CPUPercent = float64(ticks-previous.ticks) / clockTicks / elapsed * 100
+			}
+		}
+		s.last[worker.ID] = cpuReading{ticks: ticks, at: now}
+
+		series := append(s.series[worker.ID], sample)
+		if len(series) > s.policy.Samples {
+			series = series[len(series)-s.policy.Samples:]
+		}
+		s.series[worker.ID] = series
+		samples = append(samples, taken{worker.ID, sample})
+	}
+
+	// Forget deleted workers
+	for id := range s.series {
+		if !known[id] {
+			delete(s.series, id)
+			delete(s.last, id)
+		}
+	}
+	s.mu.Unlock()
+
+	if s.onSample != nil {
+		for _, t := range samples {
+			s.onSample(t.workerID, t.sample)
+		}
+	}
+}
+
+// Samples returns the recorded samples of a worker, oldest first
+func (s *Sampler) Samples(workerID string) []ResourceSample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ResourceSample(nil), s.series[workerID]...)
+}
+
+// readProcessGroup sums the CPU ticks and resident memory of the processes in
+// the process group led by pgid. Workers' processes are started in their own
+// group, so this covers amp and everything it spawned.
+func (s *Sampler) readProcessGroup(pgid int) (ticks, rss uint64, processes int, err error) {
+	entries, err := os.ReadDir(s.procDir)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	pageSize := uint64(os.Getpagesize())
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.procDir, entry.Name(), "stat"))
+		if err != nil {
+			continue // The process exited while scanning
+		}
+		stat, err := parseProcStat(string(data))
+		if err != nil || stat.pgrp != pgid {
+			continue
+		}
+		ticks += stat.utime + stat.stime
+		rss += stat.rssPages * pageSize
+		processes++
+	}
+	return ticks, rss, processes, nil
+}
+
+// procStat holds the fields of /proc/<pid>/stat the sampler uses
+type procStat struct {
+	pgrp     int
+	utime    uint64
+	stime    uint64
+	rssPages uint64
+}
+
+// parseProcStat parses a /proc/<pid>/stat line. The command name is skipped
+// by its closing parenthesis since it may contain spaces.
+func parseProcStat(line string) (procStat, error) {
+	var stat procStat
+	end := strings.LastIndexByte(line, ')')
+	if end < 0 {
+		return stat, fmt.Errorf("malformed stat line")
+	}
+	// Fields after the command name, starting with the state (field 3)
+	fields := strings.Fields(line[end+1:])
+	if len(fields) < 22 {
+		return stat, fmt.Errorf("stat line has %d fields after the command name", len(fields))
+	}
+
+	var err error
+	if stat.pgrp, err = strconv.Atoi(fields[2]); err != nil {
+		return stat, err
+	}
+	if stat.utime, err = strconv.ParseUint(fields[11], 10, 64); err != nil {
+		return stat, err
+	}
+	if stat.stime, err = strconv.ParseUint(fields[12], 10, 64); err != nil {
+		return stat, err
+	}
+	if stat.rssPages, err = strconv.ParseUint(fields[21], 10, 64); err != nil {
+		return stat, err
+	}
+	return stat, nil
+}
@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeProcStat writes a fake /proc/<pid>/stat with the fields the sampler reads
+func writeProcStat(t *testing.T, procDir string, pid, pgrp int, utime, stime, rssPages uint64) {
+	t.Helper()
+	dir := filepath.Join(procDir, fmt.Sprint(pid))
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	line := fmt.Sprintf("%d (amp (node) x) S 1 %d %d 0 -1 4194560 100 0 0 0 %d %d 0 0 20 0 8 0 1234 567890 %d 18446744073709551615\n",
+		pid, pgrp, pgrp, utime, stime, rssPages)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "stat"), []byte(line), 0644))
+}
+
+func TestParseProcStat(t *testing.T) {
+	stat, err := parseProcStat("42 (amp (node) x) S 1 40 40 0 -1 4194560 100 0 0 0 150 50 0 0 20 0 8 0 1234 567890 300 18446744073709551615")
+	require.NoError(t, err)
+	assert.Equal(t, procStat{pgrp: 40, utime: 150, stime: 50, rssPages: 300}, stat)
+
+	_, err = parseProcStat("42 (amp) S 1")
+	assert.Error(t, err)
+}
+
+func TestSampler_SamplesRunningProcessGroups(t *testing.T) {
+	manager := NewManager(t.TempDir())
+	leader := os.Getpid()
+	started := time.Now().Add(-time.Hour)
+	require.NoError(t, manager.saveWorkers(map[string]*Worker{
+		"running": {ID: "running", PID: leader, Started: started, Status: StatusRunning},
+		"remote":  {ID: "remote", PID: leader, Started: started, Status: StatusRunning, Execution: ExecutionRemote},
+		"stopped": {ID: "stopped", PID: leader, Started: started, Status: StatusStopped},
+	}))
+
+	var notified []string
+	sampler := NewSampler(manager, SamplerPolicy{Samples: 2}, func(workerID string, sample ResourceSample) {
+		notified = append(notified, workerID)
+	})
+	sampler.procDir = t.TempDir()
+	pageSize := uint64(os.Getpagesize())
+
+	// amp leads the group; a tool it runs belongs to it, an unrelated process doesn't
+	writeProcStat(t, sampler.procDir, leader, leader, 100, 50, 1000)
+	writeProcStat(t, sampler.procDir, 7001, leader, 10, 0, 500)
+	writeProcStat(t, sampler.procDir, 7002, 7002, 999, 999, 999)
+
+	now := time.Now()
+	sampler.Sample(now)
+	samples := sampler.Samples("running")
+	require.Len(t, samples, 1)
+	assert.Equal(t, ResourceSample{Time: now, RSSBytes: 1500 * pageSize, Processes: 2}, samples[0])
+
+	// 200 ticks over 4 seconds is half a core
+	writeProcStat(t, sampler.procDir, leader, leader, 250, 100, 1200)
+	sampler.Sample(now.Add(4 * time.Second))
+	samples = sampler.Samples("running")
+	require.Len(t, samples, 2)
+	assert.InDelta(t, 50.0, samples[1].CPUPercent, 0.001)
+	assert.Equal(t, 1700*pageSize, samples[1].RSSBytes)
+
+	// Only the configured number of samples is kept
+	sampler.Sample(now.Add(8 * time.Second))
+	samples = sampler.Samples("running")
+	require.Len(t, samples, 2)
+	assert.Equal(t, now.Add(8*time.Second), samples[1].Time)
+	assert.Zero(t, samples[1].CPUPercent)
+
+	assert.Empty(t, sampler.Samples("remote"))
+	assert.Empty(t, sampler.Samples("stopped"))
+	assert.Equal(t, []string{"running", "running", "running"}, notified)
+
+	// Deleted workers' series are forgotten
+	require.NoError(t, manager.saveWorkers(map[string]*Worker{}))
+	sampler.Sample(now.Add(12 * time.Second))
+	assert.Empty(t, sampler.Samples("running"))
+}
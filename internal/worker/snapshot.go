@@ -0,0 +1,189 @@
+package worker
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Snapshot is a point-in-time view of all workers. Every query answered from
+// the same snapshot sees the same state, even while workers are being
+// started, stopped or deleted concurrently.
+type Snapshot struct {
+	workers []*Worker
+}
+
+// Snapshot reads the current state of all workers, showing any whose process
+// has exited as stopped. The saved status is left to the exit monitor or, for
+// processes that vanished, the janitor, which also report the exit.
+func (m *Manager) Snapshot() (*Snapshot, error) {
+	workers, err := m.loadWorkers()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, worker := range workers {
+		if worker.Status == StatusRunning && !m.checkProcessStatus(worker) {
+			worker.setStatus(StatusStopped, ActorExitMonitor)
+		}
+	}
+
+	// Convert map to slice
+	result := make([]*Worker, 0, len(workers))
+	for _, worker := range workers {
+		result = append(result, worker)
+	}
+
+	return &Snapshot{workers: result}, nil
+}
+
+// NewSnapshot makes a snapshot of the given workers, for managers that don't
+// keep them in the state file
+func NewSnapshot(workers []*Worker) *Snapshot {
+	return &Snapshot{workers: append([]*Worker(nil), workers...)}
+}
+
+// Workers returns all workers in the snapshot
+func (s *Snapshot) Workers() []*Worker {
+	return append([]*Worker(nil), s.workers...)
+}
+
+// Worker returns the worker with the given ID, or nil when the snapshot has
+// none
+func (s *Snapshot) Worker(workerID string) *Worker {
+	for _, worker := range s.workers {
+		if worker.ID == workerID {
+			return worker
+		}
+	}
+	return nil
+}
+
+// MetadataFilter selects workers by the title, description, tags and priority
+// set on them through the API. Values are compared case-insensitively.
+type MetadataFilter struct {
+	Tags       []string // Workers must have every tag
+	Priorities []string // Workers must have one of the priorities
+	Text       string   // Substring of the title or description
+}
+
+// Matches reports whether worker satisfies every part of the filter
+func (f MetadataFilter) Matches(worker *Worker) bool {
+	for _, tag := range f.Tags {
+		if !containsFold(worker.Tags, tag) {
+			return false
+		}
+	}
+	if len(f.Priorities) > 0 && !containsFold(f.Priorities, worker.Priority) {
+		return false
+	}
+	if f.Text != "" {
+		text := strings.ToLower(f.Text)
+		if !strings.Contains(strings.ToLower(worker.Title), text) &&
+			!strings.Contains(strings.ToLower(worker.Description), text) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsFold reports whether values includes value, ignoring case
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the workers in the snapshot matching the status, start time
+// and metadata filters, sorted by sortBy in sortOrder
+func (s *Snapshot) Filter(statusFilter []string, startedBefore, startedAfter *time.Time, metadata MetadataFilter, sortBy, sortOrder string) []*Worker {
+	statusSet := make(map[string]bool)
+	for _, status := range statusFilter {
+		statusSet[status] = true
+	}
+
+	filtered := make([]*Worker, 0, len(s.workers))
+	for _, worker := range s.workers {
+		if len(statusSet) > 0 && !statusSet[string(worker.Status)] {
+			continue
+		}
+		if startedBefore != nil && worker.Started.After(*startedBefore) {
+			continue
+		}
+		if startedAfter != nil && worker.Started.Before(*startedAfter) {
+			continue
+		}
+		if !metadata.Matches(worker) {
+			continue
+		}
+		filtered = append(filtered, worker)
+	}
+
+	sortWorkers(filtered, sortBy, sortOrder)
+	return filtered
+}
+
+// priorityRank orders priorities from low to high, with unset and unknown
+// priorities lowest
+func priorityRank(priority string) int {
+	switch strings.ToLower(priority) {
+	case "low":
+		return 1
+	case "medium":
+		return 2
+	case "high":
+		return 3
+	}
+	return 0
+}
+
+// sortWorkers sorts a slice of workers based on the given criteria. Ties are
+// broken by ID so the order is the same every time a snapshot is queried.
+func sortWorkers(workers []*Worker, sortBy, sortOrder string) {
+	sort.Slice(workers, func(i, j int) bool {
+		return Precedes(workers[i], workers[j], sortBy, sortOrder)
+	})
+}
+
+// Precedes reports whether a comes before b in a list sorted by sortBy in
+// sortOrder, as returned by Filter
+func Precedes(a, b *Worker, sortBy, sortOrder string) bool {
+	// Workers that are still running haven't finished, so sort last in
+	// either order
+	if sortBy == "finished" && (a.Finished == nil) != (b.Finished == nil) {
+		return b.Finished == nil
+	}
+
+	if sortOrder != "asc" {
+		a, b = b, a
+	}
+
+	switch sortBy {
+	case "id":
+		return a.ID < b.ID
+	case "status":
+		if a.Status != b.Status {
+			return a.Status < b.Status
+		}
+	case "priority":
+		if pa, pb := priorityRank(a.Priority), priorityRank(b.Priority); pa != pb {
+			return pa < pb
+		}
+	case "title":
+		if ta, tb := strings.ToLower(a.Title), strings.ToLower(b.Title); ta != tb {
+			return ta < tb
+		}
+	case "finished":
+		if a.Finished != nil && !a.Finished.Equal(*b.Finished) {
+			return a.Finished.Before(*b.Finished)
+		}
+	default:
+		if !a.Started.Equal(b.Started) {
+			return a.Started.Before(b.Started)
+		}
+	}
+	return a.ID < b.ID
+}
@@ -0,0 +1,145 @@
+package worker
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func workerIDs(workers []*Worker) []string {
+	ids := make([]string, len(workers))
+	for i, w := range workers {
+		ids[i] = w.ID
+	}
+	return ids
+}
+
+func TestSnapshot_IsolatedFromLaterWrites(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(tempDir)
+	stateFile := filepath.Join(tempDir, "workers.json")
+
+	started := time.Now().Add(-time.Hour)
+	require.NoError(t, manager.SaveWorkersForTest(map[string]*Worker{
+		"a": {ID: "a", Status: StatusStopped, Started: started},
+		"b": {ID: "b", Status: StatusStopped, Started: started.Add(time.Minute)},
+	}, stateFile))
+
+	snapshot, err := manager.Snapshot()
+	require.NoError(t, err)
+
+	// Churn after the snapshot was taken
+	require.NoError(t, manager.SaveWorkersForTest(map[string]*Worker{
+		"b": {ID: "b", Status: StatusStopped, Started: started.Add(time.Minute)},
+		"c": {ID: "c", Status: StatusStopped, Started: started.Add(2 * time.Minute)},
+	}, stateFile))
+
+	assert.ElementsMatch(t, []string{"a", "b"}, workerIDs(snapshot.Workers()))
+	assert.Equal(t, []string{"b", "a"}, workerIDs(snapshot.Filter(nil, nil, nil, MetadataFilter{}, "started", "desc")))
+
+	current, err := manager.ListWorkers()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"b", "c"}, workerIDs(current))
+}
+
+func TestSnapshot_FilterBreaksTiesByID(t *testing.T) {
+	started := time.Now()
+	snapshot := &Snapshot{workers: []*Worker{
+		{ID: "b", Status: StatusStopped, Started: started},
+		{ID: "c", Status: StatusRunning, Started: started},
+		{ID: "a", Status: StatusStopped, Started: started},
+	}}
+
+	assert.Equal(t, []string{"a", "b", "c"}, workerIDs(snapshot.Filter(nil, nil, nil, MetadataFilter{}, "started", "asc")))
+	assert.Equal(t, []string{"c", "b", "a"}, workerIDs(snapshot.Filter(nil, nil, nil, MetadataFilter{}, "started", "desc")))
+	assert.Equal(t, []string{"c", "a", "b"}, workerIDs(snapshot.Filter(nil, nil, nil, MetadataFilter{}, "status", "asc")))
+	assert.Equal(t, []string{"b", "a"}, workerIDs(snapshot.Filter([]string{"stopped"}, nil, nil, MetadataFilter{}, "status", "desc")))
+}
+
+func TestSnapshot_FilterMetadata(t *testing.T) {
+	started := time.Now()
+	snapshot := &Snapshot{workers: []*Worker{
+		{ID: "a", Started: started, Title: "Fix login bug", Tags: []string{"auth", "bug"}, Priority: "high"},
+		{ID: "b", Started: started, Description: "Login page redesign", Tags: []string{"ui"}, Priority: "low"},
+		{ID: "c", Started: started, Title: "Bump deps", Tags: []string{"Bug"}, Priority: "medium"},
+	}}
+	filter := func(metadata MetadataFilter) []string {
+		return workerIDs(snapshot.Filter(nil, nil, nil, metadata, "id", "asc"))
+	}
+
+	assert.Equal(t, []string{"a", "c"}, filter(MetadataFilter{Tags: []string{"bug"}}))
+	assert.Equal(t, []string{"a"}, filter(MetadataFilter{Tags: []string{"bug", "auth"}}))
+	assert.Equal(t, []string{"a", "b"}, filter(MetadataFilter{Priorities: []string{"HIGH", "low"}}))
+	assert.Equal(t, []string{"a", "b"}, filter(MetadataFilter{Text: "login"}))
+	assert.Equal(t, []string{"b"}, filter(MetadataFilter{Text: "login", Tags: []string{"ui"}}))
+	assert.Empty(t, filter(MetadataFilter{Tags: []string{"missing"}}))
+	assert.Equal(t, []string{"a", "b", "c"}, filter(MetadataFilter{}))
+}
+
+func TestSnapshot_FilterSortKeys(t *testing.T) {
+	started := time.Now()
+	finished := func(d time.Duration) *time.Time {
+		at := started.Add(d)
+		return &at
+	}
+	snapshot := &Snapshot{workers: []*Worker{
+		{ID: "a", Started: started, Title: "beta", Priority: "high", Finished: finished(time.Minute)},
+		{ID: "b", Started: started, Title: "Alpha", Priority: "low", Status: StatusRunning},
+		{ID: "c", Started: started, Title: "gamma", Priority: "medium", Finished: finished(time.Second)},
+		{ID: "d", Started: started, Title: "alpha", Finished: finished(time.Second)},
+	}}
+	sorted := func(sortBy, sortOrder string) []string {
+		return workerIDs(snapshot.Filter(nil, nil, nil, MetadataFilter{}, sortBy, sortOrder))
+	}
+
+	assert.Equal(t, []string{"d", "b", "c", "a"}, sorted("priority", "asc"))
+	assert.Equal(t, []string{"a", "c", "b", "d"}, sorted("priority", "desc"))
+	assert.Equal(t, []string{"b", "d", "a", "c"}, sorted("title", "asc"))
+	assert.Equal(t, []string{"c", "d", "a", "b"}, sorted("finished", "asc"))
+	assert.Equal(t, []string{"a", "d", "c", "b"}, sorted("finished", "desc"))
+}
+
+func TestPrecedes_Finished(t *testing.T) {
+	earlier := time.Now()
+	later := earlier.Add(time.Minute)
+	done := &Worker{ID: "a", Finished: &earlier}
+	doneLater := &Worker{ID: "b", Finished: &later}
+	running := &Worker{ID: "c", Status: StatusRunning}
+
+	tests := []struct {
+		name      string
+		a, b      *Worker
+		sortOrder string
+		want      bool
+	}{
+		{"earlier first ascending", done, doneLater, "asc", true},
+		{"later first descending", done, doneLater, "desc", false},
+		{"running after finished ascending", running, done, "asc", false},
+		{"finished before running ascending", done, running, "asc", true},
+		{"running after finished descending", running, done, "desc", false},
+		{"finished before running descending", done, running, "desc", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Precedes(tt.a, tt.b, "finished", tt.sortOrder))
+		})
+	}
+}
+
+func TestWorker_SetStatusRecordsFinished(t *testing.T) {
+	worker := &Worker{Status: StatusRunning}
+
+	worker.setStatus(StatusStopped, ActorAPI)
+	require.NotNil(t, worker.Finished)
+	finished := *worker.Finished
+
+	// Later transitions between stopped states keep when it finished
+	worker.setStatus(StatusAborted, ActorAPI)
+	assert.Equal(t, finished, *worker.Finished)
+
+	worker.setStatus(StatusRunning, ActorAPI)
+	assert.Nil(t, worker.Finished)
+}
@@ -0,0 +1,216 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultNudgeMessage is sent to stalled workers when no message is configured
+const DefaultNudgeMessage = "Please summarize your progress so far and continue with the task."
+
+// StallAction describes what the stall monitor did about a stalled worker
+type StallAction string
+
+const (
+	StallDetected  StallAction = "detected"  // Stall observed, auto-nudge disabled
+	StallNudged    StallAction = "nudged"    // Continuation message sent
+	StallEscalated StallAction = "escalated" // Nudges exhausted, worker interrupted
+)
+
+// StallPolicy configures stall detection and automatic nudging
+type StallPolicy struct {
+	Threshold     time.Duration // Idle time after which a running worker is stalled
+	CheckInterval time.Duration // How often workers are checked
+	AutoNudge     bool          // Send a continuation message to stalled workers
+	NudgeMessage  string        // Message sent when nudging
+	MaxNudges     int           // Nudges per worker before escalating
+}
+
+// StallEvent reports a stall and the action taken
+type StallEvent struct {
+	WorkerID string
+	Action   StallAction
+	Nudges   int           // Nudges sent to the worker so far
+	IdleFor  time.Duration // Time since the worker's last activity
+}
+
+// StallMonitor periodically checks running workers for log inactivity and
+// nudges or interrupts them according to its policy
+type StallMonitor struct {
+	manager *Manager
+	policy  StallPolicy
+	onStall func(StallEvent)
+
+	mu        sync.Mutex
+	nudges    map[string]int       // Nudges sent per worker during its current run
+	lastNudge map[string]time.Time // When each worker was last nudged
+	reported  map[string]bool      // Stalls already reported when not nudging
+
+	nudging sync.WaitGroup // Nudges still being sent
+}
+
+// NewStallMonitor creates a stall monitor for the manager's workers
+func NewStallMonitor(manager *Manager, policy StallPolicy, onStall func(StallEvent)) *StallMonitor {
+	if policy.CheckInterval <= 0 {
+		policy.CheckInterval = 30 * time.Second
+	}
+	if policy.NudgeMessage == "" {
+		policy.NudgeMessage = DefaultNudgeMessage
+	}
+
+	return &StallMonitor{
+		manager:   manager,
+		policy:    policy,
+		onStall:   onStall,
+		nudges:    make(map[string]int),
+		lastNudge: make(map[string]time.Time),
+		reported:  make(map[string]bool),
+	}
+}
+
+// Run checks for stalled workers until the context is cancelled, then waits
+// for the nudges it sent to give up
+func (s *StallMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.policy.CheckInterval)
+	defer ticker.Stop()
+	defer s.nudging.Wait()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.Check(ctx, now)
+		}
+	}
+}
+
+// Check inspects all running workers once and acts on any that are stalled.
+// Nudges stop waiting for amp once ctx is done.
+func (s *StallMonitor) Check(ctx context.Context, now time.Time) []StallEvent {
+	workers, err := s.manager.ListWorkers()
+	if err != nil {
+		log.Printf("Stall monitor failed to list workers: %v", err)
+		return nil
+	}
+
+	s.mu.Lock()
+	running := make(map[string]bool)
+	var stalled []*Worker
+	var idle []time.Duration
+	for _, worker := range workers {
+		if worker.Status != StatusRunning {
+			continue
+		}
+		running[worker.ID] = true
+
+		idleFor := now.Sub(s.lastActivity(worker))
+		if idleFor < s.policy.Threshold {
+			delete(s.reported, worker.ID)
+			continue
+		}
+		stalled = append(stalled, worker)
+		idle = append(idle, idleFor)
+	}
+
+	// Forget workers that are no longer running so a retry starts fresh
+	for id := range s.nudges {
+		if !running[id] {
+			delete(s.nudges, id)
+			delete(s.lastNudge, id)
+		}
+	}
+	for id := range s.reported {
+		if !running[id] {
+			delete(s.reported, id)
+		}
+	}
+	s.mu.Unlock()
+
+	var events []StallEvent
+	for i, worker := range stalled {
+		if event, ok := s.handleStall(ctx, worker, idle[i], now); ok {
+			events = append(events, event)
+			if s.onStall != nil {
+				s.onStall(event)
+			}
+		}
+	}
+
+	return events
+}
+
+// handleStall applies the policy to a single stalled worker
+func (s *StallMonitor) handleStall(ctx context.Context, worker *Worker, idleFor time.Duration, now time.Time) (StallEvent, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event := StallEvent{
+		WorkerID: worker.ID,
+		Nudges:   s.nudges[worker.ID],
+		IdleFor:  idleFor,
+	}
+
+	if !s.policy.AutoNudge {
+		if s.reported[worker.ID] {
+			return event, false
+		}
+		s.reported[worker.ID] = true
+		event.Action = StallDetected
+		return event, true
+	}
+
+	if s.nudges[worker.ID] < s.policy.MaxNudges {
+		s.nudges[worker.ID]++
+		s.lastNudge[worker.ID] = now
+		event.Nudges = s.nudges[worker.ID]
+		event.Action = StallNudged
+
+		log.Printf("Worker %s stalled for %s, sending nudge %d/%d", worker.ID, idleFor.Round(time.Second), event.Nudges, s.policy.MaxNudges)
+
+		// Continuing blocks until amp finishes, so don't hold up other checks
+		s.nudging.Add(1)
+		go func(workerID string) {
+			defer s.nudging.Done()
+			if err := s.manager.ContinueWorker(ctx, workerID, s.policy.NudgeMessage); err != nil {
+				log.Printf("Failed to nudge worker %s: %v", workerID, err)
+			}
+		}(worker.ID)
+
+		return event, true
+	}
+
+	log.Printf("Worker %s still stalled after %d nudges, interrupting", worker.ID, s.nudges[worker.ID])
+	if err := s.manager.interruptWorker(worker.ID, ActorStallMonitor); err != nil {
+		log.Printf("Failed to interrupt stalled worker %s: %v", worker.ID, err)
+	}
+	delete(s.nudges, worker.ID)
+	delete(s.lastNudge, worker.ID)
+
+	event.Action = StallEscalated
+	return event, true
+}
+
+// lastActivity returns the most recent sign of life from a worker: its start
+// time, the last write to either of its log files, or the last nudge sent
+func (s *StallMonitor) lastActivity(worker *Worker) time.Time {
+	latest := worker.Started
+
+	for _, path := range []string{worker.LogFile, worker.AmpLogFile} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	if nudged := s.lastNudge[worker.ID]; nudged.After(latest) {
+		latest = nudged
+	}
+
+	return latest
+}
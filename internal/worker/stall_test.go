@@ -0,0 +1,116 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupStalledWorker saves a running worker backed by a real sleeping process
+// in its own process group, so interrupting it doesn't touch the test process
+func setupStalledWorker(t *testing.T) (*Manager, string) {
+	tmpDir := t.TempDir()
+	manager := NewManager(tmpDir)
+
+	scriptPath := filepath.Join(tmpDir, "dummy-amp")
+	require.NoError(t, os.WriteFile(scriptPath, []byte("#!/bin/bash\ncat > /dev/null\n"), 0755))
+	manager.ampBinaryPath = scriptPath
+
+	cmd := exec.Command("sleep", "30")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	logFile := filepath.Join(tmpDir, "worker-stalled.log")
+	require.NoError(t, os.WriteFile(logFile, []byte("working...\n"), 0644))
+
+	workers := map[string]*Worker{
+		"stalled": {
+			ID:       "stalled",
+			ThreadID: "T-stalled",
+			PID:      cmd.Process.Pid,
+			LogFile:  logFile,
+			Started:  time.Now(),
+			Status:   StatusRunning,
+		},
+	}
+	require.NoError(t, manager.SaveWorkersForTest(workers, filepath.Join(tmpDir, "workers.json")))
+
+	return manager, "stalled"
+}
+
+func TestStallMonitor_ActiveWorkerNotStalled(t *testing.T) {
+	manager, _ := setupStalledWorker(t)
+
+	monitor := NewStallMonitor(manager, StallPolicy{Threshold: time.Minute, AutoNudge: true, MaxNudges: 1}, nil)
+
+	events := monitor.Check(context.Background(), time.Now())
+	assert.Empty(t, events)
+}
+
+func TestStallMonitor_DetectOnly(t *testing.T) {
+	manager, workerID := setupStalledWorker(t)
+
+	var reported []StallEvent
+	monitor := NewStallMonitor(manager, StallPolicy{Threshold: time.Minute}, func(e StallEvent) {
+		reported = append(reported, e)
+	})
+
+	later := time.Now().Add(2 * time.Minute)
+	events := monitor.Check(context.Background(), later)
+	require.Len(t, events, 1)
+	assert.Equal(t, workerID, events[0].WorkerID)
+	assert.Equal(t, StallDetected, events[0].Action)
+	assert.GreaterOrEqual(t, events[0].IdleFor, time.Minute)
+	assert.Len(t, reported, 1)
+
+	// The same stall is only reported once
+	events = monitor.Check(context.Background(), later.Add(time.Minute))
+	assert.Empty(t, events)
+}
+
+func TestStallMonitor_NudgeThenEscalate(t *testing.T) {
+	manager, workerID := setupStalledWorker(t)
+
+	monitor := NewStallMonitor(manager, StallPolicy{
+		Threshold: time.Minute,
+		AutoNudge: true,
+		MaxNudges: 1,
+	}, nil)
+
+	now := time.Now().Add(2 * time.Minute)
+	events := monitor.Check(context.Background(), now)
+	require.Len(t, events, 1)
+	assert.Equal(t, StallNudged, events[0].Action)
+	assert.Equal(t, 1, events[0].Nudges)
+
+	// A nudge counts as activity, so the worker isn't stalled again right away
+	events = monitor.Check(context.Background(), now.Add(30*time.Second))
+	assert.Empty(t, events)
+
+	// Once the nudge has also gone unanswered, the worker is interrupted
+	events = monitor.Check(context.Background(), now.Add(5*time.Minute))
+	require.Len(t, events, 1)
+	assert.Equal(t, StallEscalated, events[0].Action)
+
+	workers, err := manager.loadWorkers()
+	require.NoError(t, err)
+	assert.Equal(t, StatusInterrupted, workers[workerID].Status)
+}
+
+func TestNewStallMonitor_Defaults(t *testing.T) {
+	monitor := NewStallMonitor(NewManager(t.TempDir()), StallPolicy{Threshold: time.Minute}, nil)
+
+	assert.Equal(t, 30*time.Second, monitor.policy.CheckInterval)
+	assert.Equal(t, DefaultNudgeMessage, monitor.policy.NudgeMessage)
+}
@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/statefile"
+)
+
+// workersFormat versions workers.json. Append a migration whenever saved
+// workers change in a way older releases would misread.
+var workersFormat = statefile.Format{
+	Name: "workers",
+	Migrations: []statefile.Migration{
+		statefile.Unchanged, // 1: the version envelope
+	},
+}
+
+// projectsFormat versions projects.json
+var projectsFormat = statefile.Format{
+	Name: "projects",
+	Migrations: []statefile.Migration{
+		statefile.Unchanged, // 1: the version envelope
+	},
+}
+
+// trashFormat versions trash.json
+var trashFormat = statefile.Format{
+	Name: "trash",
+	Migrations: []statefile.Migration{
+		statefile.Unchanged, // 1: the version envelope
+	},
+}
+
+// MigrateState upgrades state files written by older releases to the current
+// versions, keeping a backup of each, and fails if any was written by a newer
+// release. It should be called on startup, before workers are changed.
+func (m *Manager) MigrateState() error {
+	err := migrateStateFile(m.stateFile, workersFormat, func() error {
+		return m.updateWorkers(func(map[string]*Worker) error { return nil })
+	})
+	if err != nil {
+		return err
+	}
+
+	err = migrateStateFile(m.projectsFile(), projectsFormat, func() error {
+		m.projectsMu.Lock()
+		defer m.projectsMu.Unlock()
+		projects, err := m.loadProjects()
+		if err != nil {
+			return err
+		}
+		return m.saveProjects(projects)
+	})
+	if err != nil {
+		return err
+	}
+
+	return migrateStateFile(m.trashFile(), trashFormat, func() error {
+		m.trashMu.Lock()
+		defer m.trashMu.Unlock()
+		trash, err := m.loadTrash()
+		if err != nil {
+			return err
+		}
+		return m.saveTrash(trash)
+	})
+}
+
+// migrateStateFile rewrites the state file at path with rewrite if it was
+// written with an older version of format
+func migrateStateFile(path string, format statefile.Format, rewrite func() error) error {
+	contents, err := os.ReadFile(path)
+	if os.IsNotExist(err) || (err == nil && len(contents) == 0) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var data json.RawMessage
+	version, err := format.Decode(contents, &data)
+	if err != nil {
+		return err
+	}
+	if version == format.Version() {
+		return nil
+	}
+
+	if err := statefile.Backup(path, version); err != nil {
+		return fmt.Errorf("failed to back up %s state: %w", format.Name, err)
+	}
+	if err := rewrite(); err != nil {
+		return fmt.Errorf("failed to upgrade %s state: %w", format.Name, err)
+	}
+	log.Printf("Upgraded %s from version %d to %d", path, version, format.Version())
+	return nil
+}
@@ -0,0 +1,136 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateState(t *testing.T) {
+	tempDir := t.TempDir()
+	stateFile := filepath.Join(tempDir, "workers.json")
+	projectsFile := filepath.Join(tempDir, "projects.json")
+
+	// State files written before they were versioned
+	legacyWorkers := `{"abc123": {"id": "abc123", "thread_id": "T-1", "status": "stopped", "title": "Legacy"}}`
+	require.NoError(t, os.WriteFile(stateFile, []byte(legacyWorkers), 0644))
+	require.NoError(t, os.WriteFile(projectsFile, []byte(`{"infra": {"name": "infra"}}`), 0644))
+
+	manager := NewManager(tempDir)
+	require.NoError(t, manager.MigrateState())
+
+	for _, path := range []string{stateFile, projectsFile} {
+		contents, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(contents), `"version": 1`, path)
+		_, err = os.Stat(path + ".v0.bak")
+		assert.NoError(t, err, path)
+	}
+	backup, err := os.ReadFile(stateFile + ".v0.bak")
+	require.NoError(t, err)
+	assert.Equal(t, legacyWorkers, string(backup))
+
+	workers, err := manager.ListWorkers()
+	require.NoError(t, err)
+	require.Len(t, workers, 1)
+	assert.Equal(t, "Legacy", workers[0].Title)
+	_, err = manager.GetProject("infra")
+	assert.NoError(t, err)
+
+	// Current files are left alone
+	require.NoError(t, os.Remove(stateFile+".v0.bak"))
+	require.NoError(t, manager.MigrateState())
+	_, err = os.Stat(stateFile + ".v0.bak")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestMigrateState_NewerVersion(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "workers.json"), []byte(`{"version": 99, "data": {}}`), 0644))
+
+	manager := NewManager(tempDir)
+	assert.ErrorContains(t, manager.MigrateState(), "newer than the supported version")
+
+	_, err := manager.ListWorkers()
+	assert.Error(t, err)
+}
+
+func TestUpdateWorkers_Concurrent(t *testing.T) {
+	manager := NewManager(t.TempDir())
+	workers := make(map[string]*Worker)
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprintf("w%d", i)
+		workers[id] = &Worker{ID: id, Status: StatusStopped}
+	}
+	require.NoError(t, manager.saveWorkers(workers))
+
+	// Updates of different workers racing each other are all kept
+	var wg sync.WaitGroup
+	for id := range workers {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			title := "Title of " + id
+			assert.NoError(t, manager.UpdateWorkerMetadata(id, &title, nil, nil, nil))
+		}(id)
+	}
+	wg.Wait()
+
+	saved, err := manager.loadWorkers()
+	require.NoError(t, err)
+	for id := range workers {
+		assert.Equal(t, "Title of "+id, saved[id].Title)
+	}
+
+	_, err = manager.updateWorker("missing", func(*Worker) error { return nil })
+	assert.ErrorIs(t, err, ErrWorkerNotFound)
+}
+
+func TestTransitionWorker_KeepsConcurrentUpdates(t *testing.T) {
+	tmpDir := t.TempDir()
+	scriptPath := filepath.Join(tmpDir, "dummy-amp")
+	require.NoError(t, os.WriteFile(scriptPath, []byte("#!/bin/bash\nsleep 1\n"), 0755))
+
+	manager := NewManager(tmpDir)
+	manager.SetAmpBinary(scriptPath)
+	manager.SetRateLimiter(newRateLimiter(RateLimitConfig{ContinuesPerMinute: 1}, 300*time.Millisecond))
+	require.NoError(t, manager.limiter.Wait(context.Background(), InvocationContinue))
+	require.NoError(t, manager.saveWorkers(map[string]*Worker{
+		"w1": {ID: "w1", ThreadID: "T-1", LogFile: filepath.Join(tmpDir, "w1.log"), Status: StatusStopped},
+	}))
+
+	// The relaunch waits for the rate limiter while the worker is renamed
+	priority := "high"
+	done := make(chan error, 1)
+	go func() {
+		_, err := manager.TransitionWorker(context.Background(), "w1", Transition{
+			Status:   StatusRunning,
+			Reason:   "resume",
+			Message:  "carry on",
+			Metadata: MetadataUpdate{Priority: &priority},
+		})
+		done <- err
+	}()
+	require.Eventually(t, func() bool {
+		stats := manager.RateLimitStats()
+		return len(stats) == 1 && stats[0].Waiting == 1
+	}, 5*time.Second, 5*time.Millisecond)
+	title := "Renamed"
+	require.NoError(t, manager.UpdateWorkerMetadata("w1", &title, nil, nil, nil))
+
+	require.NoError(t, <-done)
+	t.Cleanup(func() { manager.StopWorker("w1") })
+	worker := findTestWorker(t, manager, "w1")
+	assert.Equal(t, "Renamed", worker.Title)
+	assert.Equal(t, "high", worker.Priority)
+	assert.Equal(t, "resume", worker.StatusReason)
+	assert.Equal(t, StatusRunning, worker.Status)
+	assert.Len(t, worker.Attempts, 1)
+}
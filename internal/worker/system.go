@@ -0,0 +1,96 @@
+package worker
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// StorageUsage reports the disk space taken up by the manager's files
+type StorageUsage struct {
+	LogDir         string `json:"log_dir"`
+	LogDirBytes    int64  `json:"log_dir_bytes"` // Every file under the log directory, including the state file
+	LogDirFiles    int    `json:"log_dir_files"`
+	StateFileBytes int64  `json:"state_file_bytes"`
+	QuotaBytes     int64  `json:"quota_bytes,omitempty"` // New tasks are refused once LogDirBytes reaches it
+}
+
+// AmpStatus reports whether the amp executable workers run is usable
+type AmpStatus struct {
+	Binary  string `json:"binary"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+	Version string `json:"version,omitempty"` // Detected at startup; empty when unknown
+}
+
+// WorkerCounts counts the tracked workers by status
+type WorkerCounts struct {
+	Total    int            `json:"total"`
+	ByStatus map[string]int `json:"by_status"`
+}
+
+// StorageUsage measures the log directory and the state file
+func (m *Manager) StorageUsage() (StorageUsage, error) {
+	usage := StorageUsage{LogDir: m.logDir, QuotaBytes: m.logDirQuota}
+
+	err := filepath.WalkDir(m.logDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Files removed during the walk aren't counted
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		usage.LogDirBytes += info.Size()
+		usage.LogDirFiles++
+		return nil
+	})
+	if err != nil {
+		return usage, err
+	}
+
+	info, err := os.Stat(m.stateFile)
+	if err != nil && !os.IsNotExist(err) {
+		return usage, err
+	}
+	if err == nil {
+		usage.StateFileBytes = info.Size()
+	}
+	return usage, nil
+}
+
+// AmpStatus checks that the amp executable can be run
+func (m *Manager) AmpStatus() AmpStatus {
+	status := AmpStatus{Binary: m.ampBinaryPath, Healthy: true}
+	if err := m.checkAmpBinary(); err != nil {
+		status.Healthy = false
+		status.Error = err.Error()
+	}
+	if version, ok := m.AmpVersion(); ok {
+		status.Version = version.String()
+	}
+	return status
+}
+
+// CountWorkers counts the tracked workers by status
+func (m *Manager) CountWorkers() (WorkerCounts, error) {
+	counts := WorkerCounts{ByStatus: make(map[string]int)}
+	workers, err := m.ListWorkers()
+	if err != nil {
+		return counts, err
+	}
+
+	counts.Total = len(workers)
+	for _, worker := range workers {
+		counts.ByStatus[string(worker.Status)]++
+	}
+	return counts, nil
+}
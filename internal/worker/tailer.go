@@ -1,7 +1,6 @@
 package worker
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"io"
@@ -22,9 +21,10 @@ type LogCallback func(LogLine)
 
 // LogTailer follows a log file and calls the callback for each new line
 type LogTailer struct {
-	filePath string
-	callback LogCallback
-	cancel   context.CancelFunc
+	filePath   string
+	callback   LogCallback
+	cancel     context.CancelFunc
+	lineReader func(io.Reader) *LineReader
 }
 
 // NewLogTailer creates a new log tailer for the given file
@@ -37,9 +37,18 @@ func NewLogTailer(filePath string, workerID string, callback LogCallback) *LogTa
 	return &LogTailer{
 		filePath: filePath,
 		callback: wrappedCallback,
+		lineReader: func(r io.Reader) *LineReader {
+			return NewLineReader(r, nil)
+		},
 	}
 }
 
+// SetLineReader sets how the tailer reads lines, e.g. to change the maximum
+// line size. It must be called before Start.
+func (t *LogTailer) SetLineReader(newReader func(io.Reader) *LineReader) {
+	t.lineReader = newReader
+}
+
 // Start begins tailing the log file
 func (t *LogTailer) Start(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
@@ -65,7 +74,7 @@ func (t *LogTailer) Stop() {
 // tailFile implements the actual file tailing logic
 func (t *LogTailer) tailFile(ctx context.Context) {
 	var file *os.File
-	var scanner *bufio.Scanner
+	var scanner *LineReader
 	var lastSize int64
 
 	ticker := time.NewTicker(100 * time.Millisecond)
@@ -96,7 +105,7 @@ func (t *LogTailer) tailFile(ctx context.Context) {
 				if err != nil {
 					continue
 				}
-				scanner = bufio.NewScanner(file)
+				scanner = t.lineReader(file)
 				lastSize = 0
 			}
 
@@ -107,14 +116,14 @@ func (t *LogTailer) tailFile(ctx context.Context) {
 				if err != nil {
 					continue
 				}
-				scanner = bufio.NewScanner(file)
+				scanner = t.lineReader(file)
 				lastSize = 0
 			}
 
 			// Seek to where we left off
 			if lastSize > 0 {
 				file.Seek(lastSize, io.SeekStart)
-				scanner = bufio.NewScanner(file)
+				scanner = t.lineReader(file)
 			}
 
 			// Read new lines
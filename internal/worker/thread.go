@@ -3,9 +3,12 @@ package worker
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 )
 
@@ -28,6 +31,22 @@ type ThreadMessage struct {
 	Metadata  map[string]interface{} `json:"metadata,omitempty"`
 }
 
+// ErrMessageNotFound is returned when a thread has no message with the given ID
+var ErrMessageNotFound = errors.New("thread message not found")
+
+// threadLocks serializes the writes to each thread file, keyed by its path.
+// Projects get a new ThreadStorage on every call, so the locks can't live in
+// it.
+var threadLocks sync.Map
+
+// lockThread locks the thread file at path and returns its unlock function
+func lockThread(path string) func() {
+	value, _ := threadLocks.LoadOrStore(path, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
 // ThreadStorage handles reading and writing thread messages to JSONL files
 type ThreadStorage struct {
 	baseDir string
@@ -45,9 +64,18 @@ func (ts *ThreadStorage) getThreadFilePath(taskID string) string {
 	return filepath.Join(ts.baseDir, fmt.Sprintf("thread_%s.jsonl", taskID))
 }
 
+// Delete removes the task's thread file, if there is one
+func (ts *ThreadStorage) Delete(taskID string) error {
+	if err := os.Remove(ts.getThreadFilePath(taskID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 // AppendMessage appends a message to the thread file for the given task
 func (ts *ThreadStorage) AppendMessage(taskID string, message ThreadMessage) error {
 	filePath := ts.getThreadFilePath(taskID)
+	defer lockThread(filePath)()
 	
 	// Ensure directory exists
 	if err := os.MkdirAll(ts.baseDir, 0755); err != nil {
@@ -84,9 +112,14 @@ func (ts *ThreadStorage) ReadMessages(taskID string, limit, offset int) ([]Threa
 		return nil, fmt.Errorf("failed to open thread file: %w", err)
 	}
 	defer file.Close()
-	
+
+	return readMessages(file, limit, offset)
+}
+
+// readMessages parses JSONL thread messages with optional pagination
+func readMessages(r io.Reader, limit, offset int) ([]ThreadMessage, error) {
 	var messages []ThreadMessage
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(r)
 	lineNum := 0
 	
 	for scanner.Scan() {
@@ -128,9 +161,14 @@ func (ts *ThreadStorage) CountMessages(taskID string) (int, error) {
 		return 0, fmt.Errorf("failed to open thread file: %w", err)
 	}
 	defer file.Close()
-	
+
+	return countMessages(file)
+}
+
+// countMessages counts the lines of a JSONL thread
+func countMessages(r io.Reader) (int, error) {
 	count := 0
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		count++
 	}
@@ -141,3 +179,185 @@ func (ts *ThreadStorage) CountMessages(taskID string) (int, error) {
 	
 	return count, nil
 }
+
+// ThreadCursor identifies the last message of a page of thread messages
+type ThreadCursor struct {
+	Timestamp time.Time
+	ID        string
+}
+
+// before reports whether the cursor sorts before message by timestamp, then ID
+func (c ThreadCursor) before(message ThreadMessage) bool {
+	if !message.Timestamp.Equal(c.Timestamp) {
+		return message.Timestamp.After(c.Timestamp)
+	}
+	return message.ID > c.ID
+}
+
+// ReadPage reads up to limit messages following cursor, or preceding it
+// newest first when desc is set. A nil cursor starts at the oldest message,
+// or the newest when desc is set. hasMore reports whether messages remain
+// beyond the page.
+func (ts *ThreadStorage) ReadPage(taskID string, cursor *ThreadCursor, limit int, desc bool) ([]ThreadMessage, bool, error) {
+	file, err := os.Open(ts.getThreadFilePath(taskID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []ThreadMessage{}, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to open thread file: %w", err)
+	}
+	defer file.Close()
+
+	return readPage(file, cursor, limit, desc)
+}
+
+// readPage reads a page of JSONL thread messages, keeping at most limit+1
+// messages in memory. Messages are in the order they were appended, and the
+// cursor's message is found by ID. If it's gone, the page starts where it
+// would have been by timestamp, so no message is repeated.
+func readPage(r io.Reader, cursor *ThreadCursor, limit int, desc bool) ([]ThreadMessage, bool, error) {
+	// Pages relative to the cursor's message and, in case it isn't found,
+	// to its timestamp
+	var byID, byTime []ThreadMessage
+	found := cursor == nil
+	keep := func(page []ThreadMessage, message ThreadMessage) []ThreadMessage {
+		page = append(page, message)
+		if desc && len(page) > limit+1 {
+			page = page[1:]
+		}
+		return page
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var message ThreadMessage
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			// Skip malformed lines
+			continue
+		}
+
+		if cursor != nil && message.ID == cursor.ID {
+			found = true
+			if desc {
+				break
+			}
+			continue
+		}
+
+		if desc {
+			byID = keep(byID, message)
+			if cursor != nil && !cursor.before(message) {
+				byTime = keep(byTime, message)
+			}
+			continue
+		}
+		if found {
+			byID = keep(byID, message)
+			if len(byID) > limit {
+				break
+			}
+		} else if cursor.before(message) && len(byTime) <= limit {
+			byTime = keep(byTime, message)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to read thread file: %w", err)
+	}
+
+	page := byID
+	if !found {
+		page = byTime
+	}
+	hasMore := len(page) > limit
+	if desc {
+		if hasMore {
+			page = page[1:]
+		}
+		for i, j := 0, len(page)-1; i < j; i, j = i+1, j-1 {
+			page[i], page[j] = page[j], page[i]
+		}
+	} else if hasMore {
+		page = page[:limit]
+	}
+	if page == nil {
+		page = []ThreadMessage{}
+	}
+	return page, hasMore, nil
+}
+
+// ReplaceMessages replaces the thread of the given task with messages. The new
+// thread is written to a temporary file first, so readers never see it half
+// written.
+func (ts *ThreadStorage) ReplaceMessages(taskID string, messages []ThreadMessage) error {
+	defer lockThread(ts.getThreadFilePath(taskID))()
+	return ts.writeMessages(taskID, messages)
+}
+
+// EditMessage rewrites the message with the given ID in the task's thread
+// with what edit returns, or removes it when edit returns nil, and returns the
+// new message. Appends wait for the rewrite, so none are lost.
+func (ts *ThreadStorage) EditMessage(taskID, messageID string, edit func(ThreadMessage) *ThreadMessage) (*ThreadMessage, error) {
+	defer lockThread(ts.getThreadFilePath(taskID))()
+
+	messages, err := ts.ReadMessages(taskID, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i, message := range messages {
+		if message.ID != messageID {
+			continue
+		}
+		edited := edit(message)
+		if edited == nil {
+			messages = append(messages[:i], messages[i+1:]...)
+		} else {
+			messages[i] = *edited
+		}
+		if err := ts.writeMessages(taskID, messages); err != nil {
+			return nil, err
+		}
+		return edited, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, messageID)
+}
+
+// writeMessages writes the task's thread to a temporary file and moves it in
+// place. The caller holds the thread's lock.
+func (ts *ThreadStorage) writeMessages(taskID string, messages []ThreadMessage) error {
+	if err := os.MkdirAll(ts.baseDir, 0755); err != nil {
+		return fmt.Errorf("failed to create thread directory: %w", err)
+	}
+
+	filePath := ts.getThreadFilePath(taskID)
+	tmpFile := filePath + ".tmp"
+	file, err := os.Create(tmpFile)
+	if err != nil {
+		return fmt.Errorf("failed to create thread file: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	for _, message := range messages {
+		messageJSON, err := json.Marshal(message)
+		if err != nil {
+			file.Close()
+			os.Remove(tmpFile)
+			return fmt.Errorf("failed to marshal message: %w", err)
+		}
+		writer.Write(append(messageJSON, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		os.Remove(tmpFile)
+		return fmt.Errorf("failed to write thread file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("failed to write thread file: %w", err)
+	}
+
+	if err := os.Rename(tmpFile, filePath); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("failed to replace thread file: %w", err)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package worker
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -163,3 +164,116 @@ func TestThreadMessage(t *testing.T) {
 		assert.Equal(t, 150, message.Metadata["tokens"])
 	})
 }
+
+func TestThreadStorage_ReadPage(t *testing.T) {
+	storage := NewThreadStorage(t.TempDir())
+	base := time.Date(2025, 6, 4, 16, 0, 0, 0, time.UTC)
+	for i, id := range []string{"a", "b", "c", "d", "e"} {
+		require.NoError(t, storage.AppendMessage("task", ThreadMessage{
+			ID:        id,
+			Type:      MessageTypeUser,
+			Content:   id,
+			Timestamp: base.Add(time.Duration(i) * time.Millisecond),
+		}))
+	}
+	ids := func(messages []ThreadMessage) []string {
+		var ids []string
+		for _, message := range messages {
+			ids = append(ids, message.ID)
+		}
+		return ids
+	}
+	cursor := func(id string, ms int) *ThreadCursor {
+		return &ThreadCursor{ID: id, Timestamp: base.Add(time.Duration(ms) * time.Millisecond)}
+	}
+
+	page, hasMore, err := storage.ReadPage("task", nil, 2, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, ids(page))
+	assert.True(t, hasMore)
+
+	page, hasMore, err = storage.ReadPage("task", cursor("b", 1), 2, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "d"}, ids(page))
+	assert.True(t, hasMore)
+
+	page, hasMore, err = storage.ReadPage("task", cursor("d", 3), 2, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"e"}, ids(page))
+	assert.False(t, hasMore)
+
+	// Newest first
+	page, hasMore, err = storage.ReadPage("task", nil, 2, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"e", "d"}, ids(page))
+	assert.True(t, hasMore)
+
+	page, hasMore, err = storage.ReadPage("task", cursor("d", 3), 2, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "b"}, ids(page))
+	assert.True(t, hasMore)
+
+	page, hasMore, err = storage.ReadPage("task", cursor("b", 1), 2, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, ids(page))
+	assert.False(t, hasMore)
+
+	// Messages appended after a page was read show up on the next one
+	require.NoError(t, storage.AppendMessage("task", ThreadMessage{ID: "f", Type: MessageTypeUser, Content: "f", Timestamp: base.Add(5 * time.Millisecond)}))
+	page, _, err = storage.ReadPage("task", cursor("e", 4), 2, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"f"}, ids(page))
+
+	// A cursor whose message is gone resumes by timestamp
+	gone := &ThreadCursor{ID: "x", Timestamp: base.Add(1500 * time.Microsecond)}
+	page, _, err = storage.ReadPage("task", gone, 2, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "d"}, ids(page))
+	page, _, err = storage.ReadPage("task", gone, 2, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "a"}, ids(page))
+
+	page, hasMore, err = storage.ReadPage("missing", nil, 2, true)
+	require.NoError(t, err)
+	assert.Empty(t, page)
+	assert.False(t, hasMore)
+}
+
+func TestThreadStorage_EditMessage(t *testing.T) {
+	storage := NewThreadStorage(t.TempDir())
+	for _, id := range []string{"a", "b", "c"} {
+		require.NoError(t, storage.AppendMessage("task", ThreadMessage{ID: id, Type: MessageTypeAssistant, Content: "secret " + id}))
+	}
+
+	// Appends racing with rewrites are kept
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			storage.AppendMessage("task", ThreadMessage{ID: fmt.Sprintf("late-%d", i), Type: MessageTypeTool})
+		}
+	}()
+
+	edited, err := storage.EditMessage("task", "b", func(message ThreadMessage) *ThreadMessage {
+		message.Content = ""
+		return &message
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "b", edited.ID)
+	assert.Empty(t, edited.Content)
+
+	edited, err = storage.EditMessage("task", "a", func(ThreadMessage) *ThreadMessage { return nil })
+	require.NoError(t, err)
+	assert.Nil(t, edited)
+	<-done
+
+	messages, err := storage.ReadMessages("task", 0, 0)
+	require.NoError(t, err)
+	require.Len(t, messages, 52)
+	assert.Equal(t, "b", messages[0].ID)
+	assert.Empty(t, messages[0].Content)
+	assert.Equal(t, "secret c", messages[1].Content)
+
+	_, err = storage.EditMessage("task", "a", func(ThreadMessage) *ThreadMessage { return nil })
+	assert.ErrorIs(t, err, ErrMessageNotFound)
+}
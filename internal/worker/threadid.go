@@ -0,0 +1,43 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+)
+
+// DefaultThreadIDPattern matches the thread IDs amp currently issues, e.g.
+// T-4a7e2c82-d080-4128-acea-e00a04e4f02e
+const DefaultThreadIDPattern = `^T-`
+
+// ThreadIDFormat controls how thread IDs returned by `amp threads new` are validated
+type ThreadIDFormat struct {
+	Pattern       *regexp.Regexp // nil accepts any non-empty ID
+	AcceptUnknown bool           // Log and accept IDs that don't match Pattern instead of failing
+}
+
+// DefaultThreadIDFormat returns the format accepting only IDs matching DefaultThreadIDPattern
+func DefaultThreadIDFormat() ThreadIDFormat {
+	return ThreadIDFormat{Pattern: regexp.MustCompile(DefaultThreadIDPattern)}
+}
+
+// validate checks a thread ID against the format
+func (f ThreadIDFormat) validate(threadID string) error {
+	if threadID == "" {
+		return errors.New("amp returned an empty thread ID")
+	}
+	if f.Pattern == nil || f.Pattern.MatchString(threadID) {
+		return nil
+	}
+	if f.AcceptUnknown {
+		log.Printf("Accepting thread ID %q that does not match expected format %s", threadID, f.Pattern)
+		return nil
+	}
+	return fmt.Errorf("unexpected thread ID format: %s", threadID)
+}
+
+// SetThreadIDFormat sets how thread IDs returned by amp are validated
+func (m *Manager) SetThreadIDFormat(format ThreadIDFormat) {
+	m.threadIDFormat = format
+}
@@ -0,0 +1,81 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupThreadIDSimulator creates a dummy amp whose `threads new` prints
+// $AMP_SIM_THREAD_ID, so tests can simulate amp changing its ID scheme
+func setupThreadIDSimulator(t *testing.T) *Manager {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	scriptPath := filepath.Join(tmpDir, "dummy-amp")
+	script := `#!/bin/bash
+if [ "$1" = "threads" ] && [ "$2" = "new" ]; then
+	printf '%s\n' "$AMP_SIM_THREAD_ID"
+fi
+`
+	require.NoError(t, os.WriteFile(scriptPath, []byte(script), 0755))
+
+	manager := NewManager(tmpDir)
+	manager.ampBinaryPath = scriptPath
+	return manager
+}
+
+func TestCreateThread_FormatCompatibility(t *testing.T) {
+	uuidPattern := regexp.MustCompile(`^(T-)?[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	ulidPattern := regexp.MustCompile(`^thread_[0-9A-Z]{26}$`)
+
+	formats := map[string]string{
+		"current":   "T-4a7e2c82-d080-4128-acea-e00a04e4f02e",
+		"padded":    "  T-4a7e2c82-d080-4128-acea-e00a04e4f02e  ",
+		"bare uuid": "4a7e2c82-d080-4128-acea-e00a04e4f02e",
+		"ulid":      "thread_01HZX3K5Q8V9W2B7N4M6R1T0YC",
+		"empty":     "",
+	}
+
+	policies := map[string]ThreadIDFormat{
+		"default":        DefaultThreadIDFormat(),
+		"accept unknown": {Pattern: regexp.MustCompile(DefaultThreadIDPattern), AcceptUnknown: true},
+		"uuid pattern":   {Pattern: uuidPattern},
+		"ulid pattern":   {Pattern: ulidPattern},
+		"any":            {},
+	}
+
+	// Expected outcome for each policy and format; true means the ID is accepted
+	matrix := map[string]map[string]bool{
+		"default":        {"current": true, "padded": true, "bare uuid": false, "ulid": false, "empty": false},
+		"accept unknown": {"current": true, "padded": true, "bare uuid": true, "ulid": true, "empty": false},
+		"uuid pattern":   {"current": true, "padded": true, "bare uuid": true, "ulid": false, "empty": false},
+		"ulid pattern":   {"current": false, "padded": false, "bare uuid": false, "ulid": true, "empty": false},
+		"any":            {"current": true, "padded": true, "bare uuid": true, "ulid": true, "empty": false},
+	}
+
+	manager := setupThreadIDSimulator(t)
+	for policyName, policy := range policies {
+		for formatName, threadID := range formats {
+			t.Run(policyName+"/"+formatName, func(t *testing.T) {
+				t.Setenv("AMP_SIM_THREAD_ID", threadID)
+				manager.SetThreadIDFormat(policy)
+
+				got, err := manager.createThread(context.Background(), AmpClient{Path: manager.ampBinaryPath}, nil)
+				if matrix[policyName][formatName] {
+					require.NoError(t, err)
+					assert.Equal(t, strings.TrimSpace(threadID), got)
+				} else {
+					assert.Error(t, err)
+					assert.Empty(t, got)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package worker
+
+import "time"
+
+// StatusActor is what changed a worker's status
+type StatusActor string
+
+const (
+	ActorAPI           StatusActor = "api"            // A request, such as starting, stopping or retrying the worker
+	ActorExitMonitor   StatusActor = "exit-monitor"   // The worker's process exited
+	ActorJanitor       StatusActor = "janitor"        // The worker's process vanished while nothing waited for it
+	ActorRestartPolicy StatusActor = "restart-policy" // The restart policy ran amp again
+	ActorStallMonitor  StatusActor = "stall-monitor"  // The worker stalled and its nudges were exhausted
+	ActorWindDown      StatusActor = "wind-down"      // The worker outlived its wind-down deadline
+)
+
+// StatusChange is one entry of a worker's status timeline
+type StatusChange struct {
+	Time  time.Time    `json:"time"`
+	From  WorkerStatus `json:"from,omitempty"` // Empty when the worker was created
+	To    WorkerStatus `json:"to"`
+	Actor StatusActor  `json:"actor"`
+}
+
+// recordStatus appends a status change to the worker's timeline
+func (w *Worker) recordStatus(from, to WorkerStatus, actor StatusActor) {
+	w.Timeline = append(w.Timeline, StatusChange{Time: time.Now(), From: from, To: to, Actor: actor})
+}
+
+// relaunchActor returns the actor of a status change caused by an attempt
+func relaunchActor(action AttemptAction) StatusActor {
+	if action == AttemptRestart {
+		return ActorRestartPolicy
+	}
+	return ActorAPI
+}
+
+// Timeline returns the status changes of a worker, oldest first
+func (m *Manager) Timeline(workerID string) ([]StatusChange, error) {
+	worker, err := m.findWorker(workerID)
+	if err != nil {
+		return nil, err
+	}
+	if worker.Timeline == nil {
+		return []StatusChange{}, nil
+	}
+	return worker.Timeline, nil
+}
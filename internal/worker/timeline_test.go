@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorker_SetStatusRecordsTimeline(t *testing.T) {
+	worker := &Worker{Status: StatusRunning}
+	worker.setStatus(StatusStopped, ActorExitMonitor)
+	worker.setStatus(StatusStopped, ActorAPI)
+	worker.setStatus(StatusRunning, ActorRestartPolicy)
+
+	require.Len(t, worker.Timeline, 2, "unchanged statuses aren't recorded")
+	assert.Equal(t, StatusRunning, worker.Timeline[0].From)
+	assert.Equal(t, StatusStopped, worker.Timeline[0].To)
+	assert.Equal(t, ActorExitMonitor, worker.Timeline[0].Actor)
+	assert.Equal(t, ActorRestartPolicy, worker.Timeline[1].Actor)
+}
+
+func TestManager_Timeline(t *testing.T) {
+	manager, _ := setupRestartManager(t, "0")
+
+	worker, err := manager.StartWorkerWithOptions(context.Background(), "fix the build", StartOptions{})
+	require.NoError(t, err)
+	waitStopped(t, manager, worker.ID)
+	require.NoError(t, manager.RetryWorker(context.Background(), worker.ID, "try again"))
+	require.NoError(t, manager.InterruptWorker(worker.ID))
+
+	// The interrupted process's exit is recorded too
+	require.Eventually(t, func() bool {
+		return findTestWorker(t, manager, worker.ID).Status == StatusStopped
+	}, 5*time.Second, 10*time.Millisecond)
+	timeline, err := manager.Timeline(worker.ID)
+	require.NoError(t, err)
+	type change struct {
+		from, to WorkerStatus
+		actor    StatusActor
+	}
+	var changes []change
+	for _, c := range timeline {
+		changes = append(changes, change{c.From, c.To, c.Actor})
+	}
+	assert.Equal(t, []change{
+		{"", StatusRunning, ActorAPI},
+		{StatusRunning, StatusStopped, ActorExitMonitor},
+		{StatusStopped, StatusRunning, ActorAPI},
+		{StatusRunning, StatusInterrupted, ActorAPI},
+		{StatusInterrupted, StatusStopped, ActorExitMonitor},
+	}, changes)
+
+	_, err = manager.Timeline("missing")
+	assert.ErrorContains(t, err, "not found")
+}
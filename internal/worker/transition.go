@@ -0,0 +1,111 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+)
+
+// MetadataUpdate holds optional metadata changes; nil fields are left unchanged
+type MetadataUpdate struct {
+	Title       *string
+	Description *string
+	Priority    *string
+	Tags        []string
+}
+
+// apply copies the provided fields onto the worker
+func (u MetadataUpdate) apply(worker *Worker) {
+	if u.Title != nil {
+		worker.Title = *u.Title
+	}
+	if u.Description != nil {
+		worker.Description = *u.Description
+	}
+	if u.Priority != nil {
+		worker.Priority = *u.Priority
+	}
+	if u.Tags != nil {
+		worker.Tags = u.Tags
+	}
+}
+
+// Transition is a status change applied together with metadata updates
+type Transition struct {
+	Status   WorkerStatus
+	Reason   string // Recorded as the worker's status reason
+	Message  string // Sent to the thread; required when transitioning to running
+	Metadata MetadataUpdate
+}
+
+// TransitionWorker validates and performs a status transition, saving the new
+// status, reason and metadata together so a failed transition changes nothing.
+// Changes saved by others meanwhile are kept.
+// ctx bounds the wait for the rate limiter when the worker is relaunched.
+func (m *Manager) TransitionWorker(ctx context.Context, workerID string, t Transition) (*Worker, error) {
+	workers, err := m.loadWorkers()
+	if err != nil {
+		return nil, err
+	}
+
+	worker, exists := workers[workerID]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrWorkerNotFound, workerID)
+	}
+
+	// Treat workers whose process has exited as stopped before validating
+	exited := worker.Status == StatusRunning && worker.PID > 0 && !m.checkProcessStatus(worker)
+	if exited {
+		worker.setStatus(StatusStopped, ActorExitMonitor)
+	}
+
+	if !CanTransition(worker.Status, t.Status) {
+		return nil, fmt.Errorf("%w: cannot transition worker %s from %s to %s", ErrInvalidTransition, workerID, worker.Status, t.Status)
+	}
+	if t.Status == StatusRunning && t.Message == "" {
+		return nil, fmt.Errorf("a message is required to transition worker %s to running", workerID)
+	}
+
+	// Only the transition's own changes are saved, onto the current copy of
+	// the worker, since ending or starting its process takes a while
+	apply := func(saved *Worker) {
+		if exited && saved.Status == StatusRunning {
+			saved.setStatus(StatusStopped, ActorExitMonitor)
+		}
+		t.Metadata.apply(saved)
+		saved.StatusReason = t.Reason
+	}
+
+	switch t.Status {
+	case StatusRunning:
+		return m.relaunchWorker(ctx, worker, t.Message, AttemptTransition, apply)
+
+	case StatusInterrupted:
+		m.interruptProcess(worker)
+
+	case StatusAborted:
+		m.forceKillProcess(worker)
+		m.killAmpProcesses(worker.ThreadID)
+		m.stopLogTailer(workerID)
+
+	default:
+		// Stopped, completed and failed all end the worker's process
+		if m.checkProcessStatus(worker) {
+			if err := m.terminateProcess(worker); err != nil {
+				return nil, err
+			}
+		}
+		m.killAmpProcesses(worker.ThreadID)
+		m.stopLogTailer(workerID)
+	}
+
+	saved, err := m.updateWorker(workerID, func(saved *Worker) error {
+		apply(saved)
+		saved.setStatus(t.Status, ActorAPI)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update worker state: %w", err)
+	}
+
+	return saved, nil
+}
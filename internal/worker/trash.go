@@ -0,0 +1,176 @@
+package worker
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// SetTrashRetention keeps deleted workers, with their files, in the trash for
+// retention before they're purged, so they can be restored. 0 deletes workers
+// immediately.
+func (m *Manager) SetTrashRetention(retention time.Duration) {
+	m.trashRetention = retention
+}
+
+// ListTrash returns the workers in the trash, most recently deleted first
+func (m *Manager) ListTrash() ([]*Worker, error) {
+	m.trashMu.Lock()
+	defer m.trashMu.Unlock()
+
+	trash, err := m.loadTrash()
+	if err != nil {
+		return nil, err
+	}
+	workers := make([]*Worker, 0, len(trash))
+	for _, worker := range trash {
+		workers = append(workers, worker)
+	}
+	sort.Slice(workers, func(i, j int) bool {
+		if !workers[i].Deleted.Equal(*workers[j].Deleted) {
+			return workers[i].Deleted.After(*workers[j].Deleted)
+		}
+		return workers[i].ID < workers[j].ID
+	})
+	return workers, nil
+}
+
+// RestoreWorker moves a worker and its trashed descendants out of the trash,
+// returning them. Workers whose parent is no longer present are restored as
+// top-level workers.
+func (m *Manager) RestoreWorker(workerID string) ([]*Worker, error) {
+	m.trashMu.Lock()
+	defer m.trashMu.Unlock()
+
+	trash, err := m.loadTrash()
+	if err != nil {
+		return nil, err
+	}
+	if _, exists := trash[workerID]; !exists {
+		return nil, fmt.Errorf("%w in the trash: %s", ErrWorkerNotFound, workerID)
+	}
+	// Save the workers first so a failure leaves them in the trash rather
+	// than losing them
+	restored := NewHierarchy(trash).Subtree(workerID)
+	err = m.updateWorkers(func(workers map[string]*Worker) error {
+		for _, worker := range restored {
+			worker.Deleted = nil
+			workers[worker.ID] = worker
+		}
+		for _, worker := range restored {
+			if _, exists := workers[worker.ParentID]; !exists {
+				worker.ParentID = ""
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, worker := range restored {
+		delete(trash, worker.ID)
+	}
+	if err := m.saveTrash(trash); err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// discardWorkers moves deleted workers to the trash, or deletes their files
+// when the trash is disabled. Their processes must already be stopped.
+func (m *Manager) discardWorkers(workers []*Worker) error {
+	if m.trashRetention <= 0 {
+		for _, worker := range workers {
+			m.removeWorkerFiles(worker)
+		}
+		return nil
+	}
+
+	m.trashMu.Lock()
+	defer m.trashMu.Unlock()
+
+	trash, err := m.loadTrash()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, worker := range workers {
+		if worker.Status == StatusRunning {
+			worker.setStatus(StatusStopped, ActorAPI)
+		}
+		worker.Deleted = &now
+		trash[worker.ID] = worker
+	}
+	return m.saveTrash(trash)
+}
+
+// purgeTrash deletes the workers trashed before cutoff along with their
+// files, returning their IDs
+func (m *Manager) purgeTrash(cutoff time.Time) ([]string, error) {
+	m.trashMu.Lock()
+	defer m.trashMu.Unlock()
+
+	trash, err := m.loadTrash()
+	if err != nil {
+		return nil, err
+	}
+
+	var purged []string
+	for id, worker := range trash {
+		if worker.Deleted == nil || worker.Deleted.Before(cutoff) {
+			purged = append(purged, id)
+		}
+	}
+	if len(purged) == 0 {
+		return nil, nil
+	}
+	sort.Strings(purged)
+
+	for _, id := range purged {
+		m.removeWorkerFiles(trash[id])
+		delete(trash, id)
+		log.Printf("Purged worker %s from the trash", id)
+	}
+	if err := m.saveTrash(trash); err != nil {
+		return nil, err
+	}
+	return purged, nil
+}
+
+// loadTrash reads the trashed workers. The caller must hold trashMu.
+func (m *Manager) loadTrash() (map[string]*Worker, error) {
+	trash := make(map[string]*Worker)
+
+	data, err := os.ReadFile(m.trashFile())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if _, err := trashFormat.Decode(data, &trash); err != nil {
+			return nil, err
+		}
+	}
+	return trash, nil
+}
+
+// saveTrash replaces the trashed workers. The caller must hold trashMu.
+func (m *Manager) saveTrash(trash map[string]*Worker) error {
+	data, err := trashFormat.Encode(trash)
+	if err != nil {
+		return err
+	}
+
+	tmpFile := m.trashFile() + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, m.trashFile())
+}
+
+// trashFile returns the path of the saved trash
+func (m *Manager) trashFile() string {
+	return filepath.Join(m.logDir, "trash.json")
+}
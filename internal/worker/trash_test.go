@@ -0,0 +1,101 @@
+package worker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_TrashAndRestore(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := NewManager(tmpDir)
+	manager.SetTrashRetention(24 * time.Hour)
+
+	parentLog := filepath.Join(tmpDir, "worker-parent.log")
+	require.NoError(t, os.WriteFile(parentLog, []byte("done\n"), 0644))
+	require.NoError(t, manager.saveWorkers(map[string]*Worker{
+		"parent": {ID: "parent", PID: 999997, Status: StatusCompleted, LogFile: parentLog},
+		"child":  {ID: "child", PID: 999996, Status: StatusStopped, ParentID: "parent"},
+		"other":  {ID: "other", PID: 999995, Status: StatusStopped},
+	}))
+
+	deleted, err := manager.DeleteWorkerTree("parent", false)
+	require.NoError(t, err)
+	assert.Len(t, deleted, 2)
+
+	// Trashed workers are gone from the state but keep their files
+	workers, err := manager.loadWorkers()
+	require.NoError(t, err)
+	assert.Len(t, workers, 1)
+	assert.FileExists(t, parentLog)
+
+	trash, err := manager.ListTrash()
+	require.NoError(t, err)
+	require.Len(t, trash, 2)
+	assert.NotNil(t, trash[0].Deleted)
+
+	restored, err := manager.RestoreWorker("parent")
+	require.NoError(t, err)
+	assert.Len(t, restored, 2, "subtasks deleted with the task come back with it")
+
+	workers, err = manager.loadWorkers()
+	require.NoError(t, err)
+	require.Contains(t, workers, "child")
+	assert.Equal(t, "parent", workers["child"].ParentID)
+	assert.Nil(t, workers["parent"].Deleted)
+	trash, err = manager.ListTrash()
+	require.NoError(t, err)
+	assert.Empty(t, trash)
+
+	_, err = manager.RestoreWorker("parent")
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestManager_RestoreDetachesFromMissingParent(t *testing.T) {
+	manager := NewManager(t.TempDir())
+	manager.SetTrashRetention(time.Hour)
+	require.NoError(t, manager.saveWorkers(map[string]*Worker{
+		"parent": {ID: "parent", PID: 999997, Status: StatusCompleted},
+		"child":  {ID: "child", PID: 999996, Status: StatusStopped, ParentID: "parent"},
+	}))
+
+	_, err := manager.DeleteWorkerTree("parent", false)
+	require.NoError(t, err)
+	restored, err := manager.RestoreWorker("child")
+	require.NoError(t, err)
+	require.Len(t, restored, 1)
+	assert.Empty(t, restored[0].ParentID)
+}
+
+func TestJanitor_PurgesTrash(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := NewManager(tmpDir)
+	manager.SetTrashRetention(24 * time.Hour)
+
+	now := time.Now()
+	longAgo := now.Add(-48 * time.Hour)
+	recently := now.Add(-time.Hour)
+	oldLog := filepath.Join(tmpDir, "worker-old.log")
+	require.NoError(t, os.WriteFile(oldLog, []byte("done\n"), 0644))
+
+	manager.trashMu.Lock()
+	require.NoError(t, manager.saveTrash(map[string]*Worker{
+		"old":    {ID: "old", Status: StatusCompleted, LogFile: oldLog, Deleted: &longAgo},
+		"recent": {ID: "recent", Status: StatusStopped, Deleted: &recently},
+	}))
+	manager.trashMu.Unlock()
+
+	report, err := NewJanitor(manager, JanitorPolicy{}).Collect(now)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"old"}, report.Purged)
+	assert.NoFileExists(t, oldLog)
+
+	trash, err := manager.ListTrash()
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	assert.Equal(t, "recent", trash[0].ID)
+}
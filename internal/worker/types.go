@@ -14,18 +14,71 @@ const (
 	StatusCompleted   WorkerStatus = "completed"
 )
 
+// Valid reports whether the status is one of the known worker statuses
+func (s WorkerStatus) Valid() bool {
+	switch s {
+	case StatusRunning, StatusStopped, StatusInterrupted, StatusAborted, StatusFailed, StatusCompleted:
+		return true
+	}
+	return false
+}
+
 type Worker struct {
-	ID          string       `json:"id"`
-	ThreadID    string       `json:"thread_id"`
-	PID         int          `json:"pid"`
-	LogFile     string       `json:"log_file"`     // Stdout/stderr log file
-	AmpLogFile  string       `json:"amp_log_file"` // Amp internal log file
-	Started     time.Time    `json:"started"`
-	Status      WorkerStatus `json:"status"`
-	Title       string       `json:"title,omitempty"`       // User-friendly task name
-	Description string       `json:"description,omitempty"` // Task description
-	Tags        []string     `json:"tags,omitempty"`        // Task tags/labels
-	Priority    string       `json:"priority,omitempty"`    // Task priority (low, medium, high)
+	ID            string           `json:"id"`
+	ThreadID      string           `json:"thread_id"`
+	PID           int              `json:"pid"`
+	LogFile       string           `json:"log_file"`     // Stdout/stderr log file
+	AmpLogFile    string           `json:"amp_log_file"` // Amp internal log file
+	Started       time.Time        `json:"started"`
+	Finished      *time.Time       `json:"finished,omitempty"` // When the worker last stopped running; nil while running
+	Status        WorkerStatus     `json:"status"`
+	Title         string           `json:"title,omitempty"`          // User-friendly task name
+	Description   string           `json:"description,omitempty"`    // Task description
+	Tags          []string         `json:"tags,omitempty"`           // Task tags/labels
+	Priority      string           `json:"priority,omitempty"`       // Task priority (low, medium, high)
+	ParentID      string           `json:"parent_id,omitempty"`      // Parent task for subtask hierarchies
+	StatusReason  string           `json:"status_reason,omitempty"`  // Why the worker entered its current status
+	FailureReason *FailureReason   `json:"failure_reason,omitempty"` // The amp error the worker failed with; nil unless it failed with one
+	Annotations   []Annotation     `json:"annotations,omitempty"`    // Statuses reported by external systems
+	Issue         *IssueLink       `json:"issue,omitempty"`          // Issue the worker was created from
+	Execution     ExecutionMode    `json:"execution,omitempty"`      // Where amp runs; empty means the host
+	Agent         string           `json:"agent,omitempty"`          // Remote agent running the latest invocation
+	Project       string           `json:"project,omitempty"`        // Project the task belongs to; empty means the default project
+	Owner         string           `json:"owner,omitempty"`          // User who started the task; empty when started without a token
+	PullRequest   *PullRequestLink `json:"pull_request,omitempty"`   // Pull request opened for the task's changes
+	AutoCommit    bool             `json:"auto_commit,omitempty"`    // Commit the workspace's changes when the process exits
+	Offloaded     *Offload         `json:"offloaded,omitempty"`      // Files moved to the object store
+	Restart       *RestartPolicy   `json:"restart,omitempty"`        // When the process is restarted after it exits; nil never restarts it
+	Restarts      int              `json:"restarts,omitempty"`       // Automatic restarts so far
+	Attempts      []Attempt        `json:"attempts,omitempty"`       // amp invocations, oldest first
+	Timeline      []StatusChange   `json:"timeline,omitempty"`       // Status changes, oldest first
+	Deleted       *time.Time       `json:"deleted,omitempty"`        // When the worker was moved to the trash; nil unless trashed
+
+	// amp settings chosen when the task was started
+	Backend   string            `json:"backend,omitempty"`    // Agent backend other than amp that runs the task; empty is amp
+	Model     string            `json:"model,omitempty"`      // Model selected from the backend's configured models
+	AmpBinary string            `json:"amp_binary,omitempty"` // amp executable run on the host; empty uses the daemon's
+	AmpArgs   []string          `json:"amp_args,omitempty"`   // Extra flags passed to every amp invocation
+	Profile   string            `json:"profile,omitempty"`    // amp profile the worker runs with; empty uses the daemon's credentials
+	Pool      string            `json:"pool,omitempty"`       // Worker pool whose limit and settings apply; empty is none
+	Env       map[string]string `json:"env,omitempty"`        // Variables added to the environment of every amp invocation
+	Secrets   map[string]string `json:"secrets,omitempty"`    // Names of the secrets added to the environment, by variable
+}
+
+// setStatus moves the worker to status, recording when it stops running and,
+// in its timeline, that actor changed it
+func (w *Worker) setStatus(status WorkerStatus, actor StatusActor) {
+	if status != w.Status {
+		w.recordStatus(w.Status, status, actor)
+	}
+	if status == StatusRunning {
+		w.Finished = nil
+		w.FailureReason = nil
+	} else if w.Status == StatusRunning || w.Finished == nil {
+		now := time.Now()
+		w.Finished = &now
+	}
+	w.Status = status
 }
 
 // AllowedTransitions defines valid state transitions for workers
@@ -62,7 +115,7 @@ func CanTransition(from, to WorkerStatus) bool {
 	if !exists {
 		return false
 	}
-	
+
 	for _, status := range allowed {
 		if status == to {
 			return true
@@ -70,3 +123,9 @@ func CanTransition(from, to WorkerStatus) bool {
 	}
 	return false
 }
+
+// LogStats describes how worker log lines have been read
+type LogStats struct {
+	MaxLineSize    int    `json:"max_line_size"`   // Bytes kept per line before truncation
+	TruncatedLines uint64 `json:"truncated_lines"` // Lines cut short at MaxLineSize
+}
@@ -80,3 +80,19 @@ func TestCanTransitionInvalidStatus(t *testing.T) {
 		t.Errorf("CanTransition with invalid status should return false, got true")
 	}
 }
+
+func TestWorkerStatusValid(t *testing.T) {
+	for _, status := range []WorkerStatus{
+		StatusRunning, StatusStopped, StatusInterrupted, StatusAborted, StatusFailed, StatusCompleted,
+	} {
+		if !status.Valid() {
+			t.Errorf("%s should be valid", status)
+		}
+	}
+
+	for _, status := range []WorkerStatus{"", "invalid", "Running"} {
+		if status.Valid() {
+			t.Errorf("%q should not be valid", status)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package worker
 
 import (
+	"errors"
 	"log"
 	"os/exec"
 )
@@ -24,53 +25,89 @@ func NewWatcher(callback WatcherCallback) *Watcher {
 func (w *Watcher) WatchProcess(workerID string, cmd *exec.Cmd) {
 	go func() {
 		// Wait for the process to complete
-		err := cmd.Wait()
+		code := exitCode(cmd.Wait())
 		
-		exitCode := 0
-		if err != nil {
-			if exitError, ok := err.(*exec.ExitError); ok {
-				exitCode = exitError.ExitCode()
-			} else {
-				// Process was killed or other error
-				exitCode = -1
-			}
-		}
-		
-		log.Printf("Worker %s exited with code %d", workerID, exitCode)
+		log.Printf("Worker %s exited with code %d", workerID, code)
 		
 		// Call the callback if set
 		if w.callback != nil {
-			w.callback(workerID, exitCode)
+			w.callback(workerID, code)
 		}
 	}()
 }
 
+// exitCode returns the exit code of a process from the error its Wait
+// returned, or -1 when it was killed or couldn't be waited for
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitError, ok := err.(*exec.ExitError); ok {
+		return exitError.ExitCode()
+	}
+	return -1
+}
+
 // MonitorWorkerExit is a convenience function to watch a process and update status
 func (m *Manager) MonitorWorkerExit(workerID string, cmd *exec.Cmd, onExit func(workerID string)) {
+	m.monitorExit(workerID, func() int { return exitCode(cmd.Wait()) }, onExit)
+}
+
+// monitorExit marks the worker stopped once wait returns the process's exit
+// code, or failed when amp exited with an error its logs explain, then
+// restarts it if its restart policy asks for it. Failed workers aren't
+// restarted, since their errors need the user's attention.
+func (m *Manager) monitorExit(workerID string, wait func() int, onExit func(workerID string)) {
+	m.monitored.Store(workerID, true)
 	go func() {
 		// Wait for the process to complete
-		cmd.Wait()
+		code := wait()
+		_, halted := m.halting.LoadAndDelete(workerID)
 		
 		// Update worker status in the manager
-		workers, err := m.loadWorkers()
+		worker, err := m.updateWorker(workerID, func(worker *Worker) error {
+			worker.endAttempt(0, &code)
+			var failure *FailureReason
+			if !halted {
+				failure = m.detectFailure(worker, code)
+			}
+			if failure != nil {
+				worker.setStatus(StatusFailed, ActorExitMonitor)
+				worker.FailureReason = failure
+				worker.StatusReason = failure.Message
+			} else {
+				worker.setStatus(StatusStopped, ActorExitMonitor)
+			}
+			return nil
+		})
+		// The janitor finalizes the worker if its status couldn't be saved
+		m.monitored.Delete(workerID)
+		if errors.Is(err, ErrWorkerNotFound) {
+			return
+		}
 		if err != nil {
-			log.Printf("Failed to load workers after exit: %v", err)
+			log.Printf("Failed to save worker state after exit: %v", err)
 			return
 		}
 		
-		if worker, exists := workers[workerID]; exists {
-			worker.Status = "stopped"
-			if err := m.saveWorkers(workers); err != nil {
-				log.Printf("Failed to save worker state after exit: %v", err)
-				return
-			}
-			
-			log.Printf("Worker %s marked as stopped", workerID)
-			
-			// Call the exit callback
-			if onExit != nil {
-				onExit(workerID)
-			}
-		}
+		log.Printf("Worker %s marked as %s", workerID, worker.Status)
+		m.afterExit(workerID, onExit)
+		m.supervise(workerID, code, halted)
 	}()
 }
+
+// afterExit runs the work that follows a worker's process exiting: its
+// auto-commit, the exit callback, its post-complete or post-fail hooks and its
+// cleanup commands
+func (m *Manager) afterExit(workerID string, onExit func(workerID string)) {
+	// Commit before the exit is broadcast so the task carries the outcome
+	m.runAutoCommit(workerID)
+	
+	// Call the exit callback
+	if onExit != nil {
+		onExit(workerID)
+	}
+	
+	m.runExitHooks(workerID)
+	m.runCleanup(workerID)
+}
@@ -0,0 +1,174 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultWindDownMessage asks a worker to leave a clean handoff point
+	DefaultWindDownMessage = "Wrap up now: summarize the current state of your work and what remains, commit any work in progress, and stop."
+
+	// DefaultWindDownTimeout bounds how long a winding-down worker may keep
+	// running when no timeout is given
+	DefaultWindDownTimeout = 5 * time.Minute
+
+	// CheckpointAnnotation is the key of the annotation holding a worker's
+	// checkpoint summary
+	CheckpointAnnotation = "checkpoint"
+)
+
+// windDownPollInterval is how often a winding-down worker is checked for exit
+var windDownPollInterval = 500 * time.Millisecond
+
+// WindDownOptions configures a worker's wind-down
+type WindDownOptions struct {
+	Message string        // Final instruction; empty uses DefaultWindDownMessage
+	Timeout time.Duration // Before the worker is interrupted; 0 uses DefaultWindDownTimeout
+}
+
+// WindDownResult is the outcome of a worker's wind-down
+type WindDownResult struct {
+	Summary     string // amp's response to the final instruction; empty if it didn't respond in time
+	Interrupted bool   // The worker was still running at the deadline
+	Err         error  // The final instruction couldn't be sent
+}
+
+// WindDownWorker sends a running worker a final instruction to summarize its
+// state, commit its work and stop, then waits for it to exit until the
+// deadline before interrupting it. amp's response is recorded as the worker's
+// checkpoint annotation. The returned channel receives the result once the
+// worker has exited or been interrupted.
+func (m *Manager) WindDownWorker(workerID string, opts WindDownOptions) (<-chan WindDownResult, error) {
+	if opts.Message == "" {
+		opts.Message = DefaultWindDownMessage
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultWindDownTimeout
+	}
+
+	workers, err := m.loadWorkers()
+	if err != nil {
+		return nil, err
+	}
+	worker, exists := workers[workerID]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrWorkerNotFound, workerID)
+	}
+	if worker.Status != StatusRunning || !m.checkProcessStatus(worker) {
+		return nil, fmt.Errorf("%w: %s", ErrNotRunning, workerID)
+	}
+	if _, busy := m.windDowns.LoadOrStore(workerID, true); busy {
+		return nil, fmt.Errorf("%w: cannot wind down worker %s: it is already winding down", ErrInvalidTransition, workerID)
+	}
+
+	m.annotateCheckpoint(workerID, Annotation{
+		Status:  AnnotationPending,
+		Summary: "Winding down",
+		Data:    map[string]interface{}{"message": opts.Message},
+	})
+
+	results := make(chan WindDownResult, 1)
+	go func() {
+		defer m.windDowns.Delete(workerID)
+		result := m.windDown(workerID, opts, time.Now().Add(opts.Timeout))
+		m.recordCheckpoint(workerID, opts.Message, result)
+		results <- result
+	}()
+	return results, nil
+}
+
+// windDown sends the final instruction and waits for the worker to exit until
+// deadline, interrupting it if it hasn't
+func (m *Manager) windDown(workerID string, opts WindDownOptions, deadline time.Time) WindDownResult {
+	var response bytes.Buffer
+	sent := make(chan error, 1)
+	go func() {
+		sent <- m.continueWorker(context.Background(), workerID, opts.Message, &response)
+	}()
+
+	var result WindDownResult
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case result.Err = <-sent:
+	case <-timer.C:
+		// amp is still answering, so there's no summary to record
+		result.Interrupted = m.interruptWindDown(workerID)
+		return result
+	}
+	result.Summary = strings.TrimSpace(response.String())
+
+	ticker := time.NewTicker(windDownPollInterval)
+	defer ticker.Stop()
+	for !m.windDownExited(workerID) {
+		select {
+		case <-ticker.C:
+		case <-timer.C:
+			result.Interrupted = m.interruptWindDown(workerID)
+			return result
+		}
+	}
+	return result
+}
+
+// windDownExited reports whether a winding-down worker has stopped running
+func (m *Manager) windDownExited(workerID string) bool {
+	workers, err := m.loadWorkers()
+	if err != nil {
+		return false
+	}
+	worker, exists := workers[workerID]
+	return !exists || worker.Status != StatusRunning || !m.checkProcessStatus(worker)
+}
+
+// interruptWindDown interrupts a worker that outlived its wind-down deadline,
+// reporting whether it was still running
+func (m *Manager) interruptWindDown(workerID string) bool {
+	if m.windDownExited(workerID) {
+		return false
+	}
+	if err := m.interruptWorker(workerID, ActorWindDown); err != nil {
+		log.Printf("Failed to interrupt worker %s after wind-down: %v", workerID, err)
+		return false
+	}
+	return true
+}
+
+// recordCheckpoint records a wind-down's outcome as the worker's checkpoint
+// annotation
+func (m *Manager) recordCheckpoint(workerID, message string, result WindDownResult) {
+	annotation := Annotation{
+		Status:  AnnotationSuccess,
+		Summary: result.Summary,
+		Data: map[string]interface{}{
+			"message":     message,
+			"interrupted": result.Interrupted,
+		},
+	}
+	switch {
+	case result.Err != nil:
+		annotation.Status = AnnotationFailure
+		annotation.Data["error"] = result.Err.Error()
+		if annotation.Summary == "" {
+			annotation.Summary = fmt.Sprintf("Failed to send the final instruction: %v", result.Err)
+		}
+	case annotation.Summary == "":
+		annotation.Status = AnnotationNeutral
+		annotation.Summary = "Stopped before responding to the final instruction"
+	}
+	m.annotateCheckpoint(workerID, annotation)
+}
+
+// annotateCheckpoint sets the worker's checkpoint annotation, logging failures
+// since wind-downs finish in the background
+func (m *Manager) annotateCheckpoint(workerID string, annotation Annotation) {
+	annotation.Key = CheckpointAnnotation
+	if _, err := m.Annotate(workerID, annotation); err != nil {
+		log.Printf("Failed to record checkpoint of worker %s: %v", workerID, err)
+	}
+}
@@ -0,0 +1,141 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// windDownAmp works until the final instruction arrives, then summarizes and
+// lets the worker finish; "stubborn" workers ignore it
+const windDownAmp = `#!/bin/bash
+if [ "$1" = "threads" ] && [ "$2" = "new" ]; then
+	echo T-wind
+	exit 0
+fi
+input=$(cat)
+case "$input" in
+	work)
+		for i in $(seq 100); do
+			[ -f %[1]s/stop ] && exit 0
+			sleep 0.1
+		done
+		;;
+	stubborn)
+		sleep 30
+		;;
+	*)
+		echo "Summary: halfway there"
+		touch %[1]s/stop
+		;;
+esac
+`
+
+func setupWindDownManager(t *testing.T) *Manager {
+	tmpDir := t.TempDir()
+	scriptPath := filepath.Join(tmpDir, "dummy-amp")
+	require.NoError(t, os.WriteFile(scriptPath, []byte(fmt.Sprintf(windDownAmp, tmpDir)), 0755))
+
+	poll := windDownPollInterval
+	windDownPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { windDownPollInterval = poll })
+
+	manager := NewManager(tmpDir)
+	manager.SetAmpBinary(scriptPath)
+	return manager
+}
+
+// waitWindDown returns the result sent on results
+func waitWindDown(t *testing.T, results <-chan WindDownResult) WindDownResult {
+	select {
+	case result := <-results:
+		return result
+	case <-time.After(10 * time.Second):
+		t.Fatal("wind-down did not finish")
+		return WindDownResult{}
+	}
+}
+
+// waitProcessGroupExit waits until the worker's last process group has
+// exited and been reaped, so nothing is still writing to the temp dir when it
+// is removed
+func waitProcessGroupExit(t *testing.T, manager *Manager, workerID string) {
+	assert.Eventually(t, func() bool {
+		worker := findTestWorker(t, manager, workerID)
+		return !manager.checkProcessStatus(worker) && syscall.Kill(-worker.PID, 0) == syscall.ESRCH
+	}, 10*time.Second, 10*time.Millisecond)
+}
+
+// checkpoint returns the worker's checkpoint annotation
+func checkpoint(t *testing.T, manager *Manager, workerID string) Annotation {
+	for _, annotation := range findTestWorker(t, manager, workerID).Annotations {
+		if annotation.Key == CheckpointAnnotation {
+			return annotation
+		}
+	}
+	t.Fatal("no checkpoint annotation")
+	return Annotation{}
+}
+
+func TestWindDownWorker_Completes(t *testing.T) {
+	manager := setupWindDownManager(t)
+	worker, err := manager.StartWorkerWithOptions(context.Background(), "work", StartOptions{})
+	require.NoError(t, err)
+
+	results, err := manager.WindDownWorker(worker.ID, WindDownOptions{Timeout: 5 * time.Second})
+	require.NoError(t, err)
+
+	// Only one wind-down runs at a time
+	_, err = manager.WindDownWorker(worker.ID, WindDownOptions{})
+	assert.ErrorContains(t, err, "already winding down")
+
+	result := waitWindDown(t, results)
+	require.NoError(t, result.Err)
+	assert.Equal(t, "Summary: halfway there", result.Summary)
+	assert.False(t, result.Interrupted)
+
+	annotation := checkpoint(t, manager, worker.ID)
+	assert.Equal(t, AnnotationSuccess, annotation.Status)
+	assert.Equal(t, "Summary: halfway there", annotation.Summary)
+	assert.Equal(t, false, annotation.Data["interrupted"])
+	assert.Equal(t, DefaultWindDownMessage, annotation.Data["message"])
+
+	// The worker is no longer running, so it can't wind down again
+	_, err = manager.WindDownWorker(worker.ID, WindDownOptions{})
+	assert.ErrorIs(t, err, ErrNotRunning)
+}
+
+func TestWindDownWorker_InterruptsAtDeadline(t *testing.T) {
+	manager := setupWindDownManager(t)
+	worker, err := manager.StartWorkerWithOptions(context.Background(), "stubborn", StartOptions{})
+	require.NoError(t, err)
+	t.Cleanup(func() { waitProcessGroupExit(t, manager, worker.ID) })
+
+	results, err := manager.WindDownWorker(worker.ID, WindDownOptions{Message: "checkpoint please", Timeout: 500 * time.Millisecond})
+	require.NoError(t, err)
+
+	result := waitWindDown(t, results)
+	require.NoError(t, result.Err)
+	assert.True(t, result.Interrupted)
+
+	annotation := checkpoint(t, manager, worker.ID)
+	assert.Equal(t, AnnotationSuccess, annotation.Status)
+	assert.Equal(t, "Summary: halfway there", annotation.Summary)
+	assert.Equal(t, true, annotation.Data["interrupted"])
+	assert.Equal(t, "checkpoint please", annotation.Data["message"])
+	assert.NotEqual(t, StatusRunning, findTestWorker(t, manager, worker.ID).Status)
+}
+
+func TestWindDownWorker_NotFound(t *testing.T) {
+	manager := setupWindDownManager(t)
+
+	_, err := manager.WindDownWorker("missing", WindDownOptions{})
+	assert.ErrorContains(t, err, "not found")
+}
@@ -0,0 +1,997 @@
+// Package workertest provides an in-memory stand-in for worker.Manager, so API
+// handlers can be tested without a log directory, a state file or amp.
+//
+// Workers are kept in a map and never have a process: a started worker is
+// running until a test stops, interrupts, aborts or transitions it. Messages
+// sent to a worker are recorded as user messages in its thread. Operations
+// that need a real checkout, log directory or amp (backfills, artifacts,
+// workspaces, wind-downs) return ErrNotSupported.
+package workertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+// ErrNotSupported is returned by operations the fake manager doesn't simulate
+var ErrNotSupported = errors.New("not supported by workertest.Manager")
+
+// Manager is a fake worker manager whose workers, threads, logs and projects
+// live in memory. It is safe for concurrent use.
+type Manager struct {
+	mu       sync.Mutex
+	workers  map[string]*worker.Worker
+	threads  map[string][]worker.ThreadMessage
+	logs     map[string]string
+	ampLogs  map[string]string
+	queues   map[string][]worker.QueuedMessage
+	projects map[string]*worker.Project
+	failures map[string]error
+	nextID   int
+
+	lineLimit worker.LineLimit
+}
+
+// New creates a fake manager with only the default project
+func New() *Manager {
+	return &Manager{
+		workers:  make(map[string]*worker.Worker),
+		threads:  make(map[string][]worker.ThreadMessage),
+		logs:     make(map[string]string),
+		ampLogs:  make(map[string]string),
+		queues:   make(map[string][]worker.QueuedMessage),
+		projects: map[string]*worker.Project{worker.DefaultProject: {Name: worker.DefaultProject}},
+		failures: make(map[string]error),
+
+		lineLimit: worker.LineLimit{MaxSize: worker.DefaultMaxLineSize},
+	}
+}
+
+// Add stores workers as they are, replacing any with the same ID
+func (m *Manager) Add(workers ...*worker.Worker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, w := range workers {
+		copied := *w
+		m.workers[w.ID] = &copied
+	}
+}
+
+// Worker returns a copy of a stored worker, or nil when there is none
+func (m *Manager) Worker(workerID string) *worker.Worker {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, exists := m.workers[workerID]
+	if !exists {
+		return nil
+	}
+	copied := *w
+	return &copied
+}
+
+// AddThreadMessages appends messages to a worker's thread
+func (m *Manager) AddThreadMessages(workerID string, messages ...worker.ThreadMessage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.threads[workerID] = append(m.threads[workerID], messages...)
+}
+
+// SetLog sets the content of a worker's stdout log
+func (m *Manager) SetLog(workerID, content string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logs[workerID] = content
+}
+
+// SetAmpLog sets the content of a worker's amp log
+func (m *Manager) SetAmpLog(workerID, content string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ampLogs[workerID] = content
+}
+
+// SetQueue sets the messages waiting to be sent to a worker. The fake sends
+// messages at once, so its queues only hold what tests put there.
+func (m *Manager) SetQueue(workerID string, messages ...worker.QueuedMessage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queues[workerID] = messages
+}
+
+// Fail makes every later call of the named method, e.g.
+// "StartWorkerWithOptions", return err. A nil err clears the failure.
+func (m *Manager) Fail(method string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err == nil {
+		delete(m.failures, method)
+		return
+	}
+	m.failures[method] = err
+}
+
+// failure returns the error set for method with Fail. The caller holds m.mu.
+func (m *Manager) failure(method string) error {
+	return m.failures[method]
+}
+
+// find returns a stored worker. The caller holds m.mu.
+func (m *Manager) find(workerID string) (*worker.Worker, error) {
+	w, exists := m.workers[workerID]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", worker.ErrWorkerNotFound, workerID)
+	}
+	return w, nil
+}
+
+// setStatus moves a worker to status, recording the change in its timeline
+// and when it stops running, and ending its open attempts with an unknown
+// exit code
+func setStatus(w *worker.Worker, status worker.WorkerStatus) {
+	if status != w.Status {
+		w.Timeline = append(w.Timeline, worker.StatusChange{Time: time.Now(), From: w.Status, To: status, Actor: worker.ActorAPI})
+	}
+	if status == worker.StatusRunning {
+		w.Finished = nil
+	} else if w.Status == worker.StatusRunning || w.Finished == nil {
+		now := time.Now()
+		w.Finished = &now
+		for i := range w.Attempts {
+			if w.Attempts[i].Finished == nil {
+				w.Attempts[i].Finished = &now
+			}
+		}
+	}
+	w.Status = status
+}
+
+// send records message as a user message in the worker's thread and an
+// attempt started by action, and marks the worker running. Continues exit
+// with code 0 straight away. The caller holds m.mu.
+func (m *Manager) send(w *worker.Worker, message string, action worker.AttemptAction) {
+	now := time.Now()
+	m.threads[w.ID] = append(m.threads[w.ID], worker.ThreadMessage{
+		ID:        fmt.Sprintf("%s-msg-%d", w.ID, len(m.threads[w.ID])+1),
+		Type:      worker.MessageTypeUser,
+		Content:   message,
+		Timestamp: now,
+	})
+	attempt := worker.Attempt{Number: len(w.Attempts) + 1, Action: action, Started: now}
+	if action == worker.AttemptContinue {
+		code := 0
+		attempt.Finished, attempt.ExitCode = &now, &code
+	}
+	w.Attempts = append(w.Attempts, attempt)
+	setStatus(w, worker.StatusRunning)
+}
+
+// copies returns copies of the stored workers sorted by ID. The caller holds m.mu.
+func (m *Manager) copies() []*worker.Worker {
+	list := make([]*worker.Worker, 0, len(m.workers))
+	for _, w := range m.workers {
+		copied := *w
+		list = append(list, &copied)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// StartWorkerWithOptions stores a running worker with the next ID (task-1,
+// task-2, ...) and records message in its thread
+func (m *Manager) StartWorkerWithOptions(ctx context.Context, message string, opts worker.StartOptions) (*worker.Worker, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure("StartWorkerWithOptions"); err != nil {
+		return nil, err
+	}
+
+	project := opts.Project
+	if opts.ParentID != "" {
+		parent, exists := m.workers[opts.ParentID]
+		if !exists {
+			return nil, fmt.Errorf("%w: %s", worker.ErrParentNotFound, opts.ParentID)
+		}
+		if project == "" {
+			project = parent.ProjectName()
+		}
+	}
+	if project == "" {
+		project = worker.DefaultProject
+	}
+	if _, exists := m.projects[project]; !exists {
+		return nil, fmt.Errorf("%w: %s", worker.ErrProjectNotFound, project)
+	}
+	if opts.Backend != "" && opts.Backend != worker.DefaultBackend {
+		return nil, fmt.Errorf("%w: %s", worker.ErrUnknownBackend, opts.Backend)
+	}
+	if opts.Model != "" {
+		return nil, fmt.Errorf("%w: %s", worker.ErrUnknownModel, opts.Model)
+	}
+	if opts.Pool != "" {
+		return nil, fmt.Errorf("%w: %s", worker.ErrUnknownPool, opts.Pool)
+	}
+
+	m.nextID++
+	id := fmt.Sprintf("task-%d", m.nextID)
+	w := &worker.Worker{
+		ID:          id,
+		ThreadID:    "T-" + id,
+		Title:       opts.Title,
+		Description: opts.Description,
+		Tags:        opts.Tags,
+		Started:     time.Now(),
+		ParentID:    opts.ParentID,
+		Execution:   opts.Execution,
+		Project:     project,
+		Owner:       opts.Owner,
+		AutoCommit:  opts.AutoCommit,
+		Restart:     opts.Restart,
+		AmpBinary:   opts.AmpBinary,
+		AmpArgs:     opts.AmpArgs,
+		Profile:     opts.Profile,
+		Env:         opts.Env,
+		Secrets:     opts.Secrets,
+	}
+	if opts.Issue != nil {
+		link := *opts.Issue
+		w.Issue = &link
+		if opts.IssueFields.Priority != "" {
+			w.Priority = opts.IssueFields.Priority
+		}
+		w.Tags = append(w.Tags, opts.IssueFields.Tags...)
+	}
+	if opts.Priority != "" {
+		w.Priority = opts.Priority
+	}
+	m.workers[id] = w
+	m.send(w, message, worker.AttemptStart)
+
+	copied := *w
+	return &copied, nil
+}
+
+// ContinueWorker records message in a running worker's thread
+func (m *Manager) ContinueWorker(ctx context.Context, workerID, message string) error {
+	_, err := m.continueWorker("ContinueWorker", workerID, message)
+	return err
+}
+
+// QueueContinue records message in a running worker's thread and returns the
+// number of the attempt that sent it
+func (m *Manager) QueueContinue(workerID, message string) (int, error) {
+	return m.continueWorker("QueueContinue", workerID, message)
+}
+
+// continueWorker records message in a running worker's thread, failing as
+// set for method
+func (m *Manager) continueWorker(method, workerID, message string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure(method); err != nil {
+		return 0, err
+	}
+
+	w, err := m.find(workerID)
+	if err != nil {
+		return 0, err
+	}
+	if w.Status != worker.StatusRunning {
+		return 0, fmt.Errorf("%w: %s", worker.ErrNotRunning, workerID)
+	}
+	m.send(w, message, worker.AttemptContinue)
+	return len(w.Attempts), nil
+}
+
+// RetryWorker records message in a finished worker's thread and runs it again
+func (m *Manager) RetryWorker(ctx context.Context, workerID, message string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure("RetryWorker"); err != nil {
+		return err
+	}
+
+	w, err := m.find(workerID)
+	if err != nil {
+		return err
+	}
+	if !worker.CanTransition(w.Status, worker.StatusRunning) {
+		return fmt.Errorf("%w: cannot retry worker %s with status %s", worker.ErrInvalidTransition, workerID, w.Status)
+	}
+	m.send(w, message, worker.AttemptRetry)
+	return nil
+}
+
+// StopWorker marks a running worker stopped
+func (m *Manager) StopWorker(workerID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure("StopWorker"); err != nil {
+		return err
+	}
+
+	w, err := m.find(workerID)
+	if err != nil {
+		return err
+	}
+	if w.Status != worker.StatusRunning {
+		return fmt.Errorf("%w: %s", worker.ErrNotRunning, workerID)
+	}
+	setStatus(w, worker.StatusStopped)
+	return nil
+}
+
+// InterruptWorker marks a running worker interrupted
+func (m *Manager) InterruptWorker(workerID string) error {
+	return m.move("InterruptWorker", workerID, worker.StatusInterrupted, "interrupt")
+}
+
+// AbortWorker marks a worker aborted
+func (m *Manager) AbortWorker(workerID string) error {
+	return m.move("AbortWorker", workerID, worker.StatusAborted, "abort")
+}
+
+// move transitions a worker to status when the transition is allowed
+func (m *Manager) move(method, workerID string, status worker.WorkerStatus, verb string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure(method); err != nil {
+		return err
+	}
+
+	w, err := m.find(workerID)
+	if err != nil {
+		return err
+	}
+	if !worker.CanTransition(w.Status, status) {
+		return fmt.Errorf("%w: cannot %s worker %s with status %s", worker.ErrInvalidTransition, verb, workerID, w.Status)
+	}
+	setStatus(w, status)
+	return nil
+}
+
+// DeleteWorker removes a worker, its thread and its log, detaching its
+// children. Running workers need force.
+func (m *Manager) DeleteWorker(workerID string, force bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure("DeleteWorker"); err != nil {
+		return err
+	}
+
+	w, err := m.find(workerID)
+	if err != nil {
+		return err
+	}
+	if w.Status == worker.StatusRunning && !force {
+		return fmt.Errorf("%w: %s", worker.ErrWorkerRunning, workerID)
+	}
+	m.remove(workerID)
+	for _, w := range m.workers {
+		if w.ParentID == workerID {
+			w.ParentID = ""
+		}
+	}
+	return nil
+}
+
+// remove forgets a worker and its files. The caller holds m.mu.
+func (m *Manager) remove(workerID string) {
+	delete(m.workers, workerID)
+	delete(m.threads, workerID)
+	delete(m.logs, workerID)
+	delete(m.ampLogs, workerID)
+}
+
+// StopWorkerTree marks a worker and its running descendants stopped
+func (m *Manager) StopWorkerTree(workerID string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure("StopWorkerTree"); err != nil {
+		return nil, err
+	}
+
+	if _, err := m.find(workerID); err != nil {
+		return nil, err
+	}
+	var stopped []string
+	for _, member := range worker.NewHierarchy(m.workers).Subtree(workerID) {
+		if member.Status == worker.StatusRunning {
+			setStatus(member, worker.StatusStopped)
+			stopped = append(stopped, member.ID)
+		}
+	}
+	if len(stopped) == 0 {
+		return nil, fmt.Errorf("%w: %s", worker.ErrNotRunning, workerID)
+	}
+	return stopped, nil
+}
+
+// AbortWorkerTree marks a worker and every descendant that can be aborted aborted
+func (m *Manager) AbortWorkerTree(workerID string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure("AbortWorkerTree"); err != nil {
+		return nil, err
+	}
+
+	w, err := m.find(workerID)
+	if err != nil {
+		return nil, err
+	}
+	var aborted []string
+	for _, member := range worker.NewHierarchy(m.workers).Subtree(workerID) {
+		if worker.CanTransition(member.Status, worker.StatusAborted) {
+			setStatus(member, worker.StatusAborted)
+			aborted = append(aborted, member.ID)
+		}
+	}
+	if len(aborted) == 0 {
+		return nil, fmt.Errorf("%w: cannot abort worker %s with status %s", worker.ErrInvalidTransition, workerID, w.Status)
+	}
+	return aborted, nil
+}
+
+// ListTrash returns nothing; deleted workers aren't kept
+func (m *Manager) ListTrash() ([]*worker.Worker, error) {
+	return []*worker.Worker{}, nil
+}
+
+// RestoreWorker fails, as there's no trash to restore from
+func (m *Manager) RestoreWorker(workerID string) ([]*worker.Worker, error) {
+	return nil, fmt.Errorf("%w in the trash: %s", worker.ErrWorkerNotFound, workerID)
+}
+
+// DeleteWorkerTree removes a worker and all of its descendants. Trees with
+// running members need force.
+func (m *Manager) DeleteWorkerTree(workerID string, force bool) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure("DeleteWorkerTree"); err != nil {
+		return nil, err
+	}
+
+	if _, err := m.find(workerID); err != nil {
+		return nil, err
+	}
+	members := worker.NewHierarchy(m.workers).Subtree(workerID)
+	for _, member := range members {
+		if member.Status == worker.StatusRunning && !force {
+			return nil, fmt.Errorf("%w: %s", worker.ErrWorkerRunning, member.ID)
+		}
+	}
+	var deleted []string
+	for _, member := range members {
+		m.remove(member.ID)
+		deleted = append(deleted, member.ID)
+	}
+	return deleted, nil
+}
+
+// TransitionWorker validates and applies a status transition with its reason
+// and metadata. Transitions to running record the message in the thread.
+func (m *Manager) TransitionWorker(ctx context.Context, workerID string, t worker.Transition) (*worker.Worker, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure("TransitionWorker"); err != nil {
+		return nil, err
+	}
+
+	w, err := m.find(workerID)
+	if err != nil {
+		return nil, err
+	}
+	if !worker.CanTransition(w.Status, t.Status) {
+		return nil, fmt.Errorf("%w: cannot transition worker %s from %s to %s", worker.ErrInvalidTransition, workerID, w.Status, t.Status)
+	}
+	if t.Status == worker.StatusRunning && t.Message == "" {
+		return nil, fmt.Errorf("a message is required to transition worker %s to running", workerID)
+	}
+
+	applyMetadata(w, t.Metadata)
+	w.StatusReason = t.Reason
+	if t.Status == worker.StatusRunning {
+		m.send(w, t.Message, worker.AttemptTransition)
+	} else {
+		setStatus(w, t.Status)
+	}
+
+	copied := *w
+	return &copied, nil
+}
+
+// applyMetadata copies the provided metadata fields onto the worker
+func applyMetadata(w *worker.Worker, update worker.MetadataUpdate) {
+	if update.Title != nil {
+		w.Title = *update.Title
+	}
+	if update.Description != nil {
+		w.Description = *update.Description
+	}
+	if update.Priority != nil {
+		w.Priority = *update.Priority
+	}
+	if update.Tags != nil {
+		w.Tags = update.Tags
+	}
+}
+
+// WindDownWorker isn't supported
+func (m *Manager) WindDownWorker(workerID string, opts worker.WindDownOptions) (<-chan worker.WindDownResult, error) {
+	return nil, ErrNotSupported
+}
+
+// ListWorkers returns copies of every worker sorted by ID
+func (m *Manager) ListWorkers() ([]*worker.Worker, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure("ListWorkers"); err != nil {
+		return nil, err
+	}
+	return m.copies(), nil
+}
+
+// Snapshot returns a snapshot of copies of every worker
+func (m *Manager) Snapshot() (*worker.Snapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure("Snapshot"); err != nil {
+		return nil, err
+	}
+	return worker.NewSnapshot(m.copies()), nil
+}
+
+// IsRunning reports whether a worker is running
+func (m *Manager) IsRunning(workerID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, err := m.find(workerID)
+	if err != nil {
+		return false, err
+	}
+	return w.Status == worker.StatusRunning, nil
+}
+
+// CountWorkers counts the workers by status
+func (m *Manager) CountWorkers() (worker.WorkerCounts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts := worker.WorkerCounts{Total: len(m.workers), ByStatus: make(map[string]int)}
+	for _, w := range m.workers {
+		counts.ByStatus[string(w.Status)]++
+	}
+	return counts, nil
+}
+
+// UpdateWorkerMetadata sets the provided metadata fields of a worker
+func (m *Manager) UpdateWorkerMetadata(workerID string, title, description, priority *string, tags []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure("UpdateWorkerMetadata"); err != nil {
+		return err
+	}
+
+	w, err := m.find(workerID)
+	if err != nil {
+		return err
+	}
+	applyMetadata(w, worker.MetadataUpdate{Title: title, Description: description, Priority: priority, Tags: tags})
+	return nil
+}
+
+// Annotate attaches an annotation to a worker, replacing any with the same key
+func (m *Manager) Annotate(workerID string, annotation worker.Annotation) (*worker.Annotation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure("Annotate"); err != nil {
+		return nil, err
+	}
+
+	w, err := m.find(workerID)
+	if err != nil {
+		return nil, err
+	}
+	annotation.UpdatedAt = time.Now()
+	annotations := make([]worker.Annotation, 0, len(w.Annotations)+1)
+	for _, existing := range w.Annotations {
+		if existing.Key != annotation.Key {
+			annotations = append(annotations, existing)
+		}
+	}
+	w.Annotations = append(annotations, annotation)
+	return &annotation, nil
+}
+
+// GetThreadMessages returns a page of a worker's thread, oldest first
+func (m *Manager) GetThreadMessages(workerID string, limit, offset int) ([]worker.ThreadMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure("GetThreadMessages"); err != nil {
+		return nil, err
+	}
+
+	messages := m.threads[workerID]
+	if offset >= len(messages) {
+		return []worker.ThreadMessage{}, nil
+	}
+	messages = messages[offset:]
+	if limit > 0 && len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return append([]worker.ThreadMessage(nil), messages...), nil
+}
+
+// GetThreadPage returns up to limit messages following cursor, or preceding it
+// newest first when desc is set, and whether more remain
+func (m *Manager) GetThreadPage(workerID string, cursor *worker.ThreadCursor, limit int, desc bool) ([]worker.ThreadMessage, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure("GetThreadPage"); err != nil {
+		return nil, false, err
+	}
+
+	messages := append([]worker.ThreadMessage(nil), m.threads[workerID]...)
+	sort.SliceStable(messages, func(i, j int) bool { return threadLess(messages[i], messages[j]) })
+	if desc {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+
+	page := []worker.ThreadMessage{}
+	for _, message := range messages {
+		if cursor != nil {
+			at := worker.ThreadMessage{ID: cursor.ID, Timestamp: cursor.Timestamp}
+			if desc && !threadLess(message, at) || !desc && !threadLess(at, message) {
+				continue
+			}
+		}
+		if len(page) == limit {
+			return page, true, nil
+		}
+		page = append(page, message)
+	}
+	return page, false, nil
+}
+
+// threadLess orders thread messages by timestamp, then ID
+func threadLess(a, b worker.ThreadMessage) bool {
+	if !a.Timestamp.Equal(b.Timestamp) {
+		return a.Timestamp.Before(b.Timestamp)
+	}
+	return a.ID < b.ID
+}
+
+// CountThreadMessages returns the number of messages in a worker's thread
+func (m *Manager) CountThreadMessages(workerID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure("CountThreadMessages"); err != nil {
+		return 0, err
+	}
+	return len(m.threads[workerID]), nil
+}
+
+// RemoveThreadMessage removes a message from a worker's thread
+func (m *Manager) RemoveThreadMessage(workerID, messageID string) error {
+	_, err := m.editThreadMessage("RemoveThreadMessage", workerID, messageID, func(worker.ThreadMessage) *worker.ThreadMessage { return nil })
+	return err
+}
+
+// RedactThreadMessage blanks the content and metadata of a message in a
+// worker's thread
+func (m *Manager) RedactThreadMessage(workerID, messageID string) (*worker.ThreadMessage, error) {
+	return m.editThreadMessage("RedactThreadMessage", workerID, messageID, func(message worker.ThreadMessage) *worker.ThreadMessage {
+		message.Content = ""
+		message.Metadata = map[string]interface{}{"redacted": true}
+		return &message
+	})
+}
+
+// editThreadMessage replaces a message of a worker's thread with what edit
+// returns, or removes it when edit returns nil
+func (m *Manager) editThreadMessage(method, workerID, messageID string, edit func(worker.ThreadMessage) *worker.ThreadMessage) (*worker.ThreadMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure(method); err != nil {
+		return nil, err
+	}
+
+	if _, err := m.find(workerID); err != nil {
+		return nil, err
+	}
+	messages := m.threads[workerID]
+	for i, message := range messages {
+		if message.ID != messageID {
+			continue
+		}
+		edited := edit(message)
+		if edited == nil {
+			m.threads[workerID] = append(messages[:i:i], messages[i+1:]...)
+		} else {
+			messages[i] = *edited
+		}
+		return edited, nil
+	}
+	return nil, fmt.Errorf("%w: %s", worker.ErrMessageNotFound, messageID)
+}
+
+// Attempts returns the attempts recorded for a worker
+func (m *Manager) Attempts(workerID string) ([]worker.Attempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure("Attempts"); err != nil {
+		return nil, err
+	}
+
+	w, err := m.find(workerID)
+	if err != nil {
+		return nil, err
+	}
+	return append([]worker.Attempt{}, w.Attempts...), nil
+}
+
+// Timeline returns the status changes recorded for a worker
+func (m *Manager) Timeline(workerID string) ([]worker.StatusChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure("Timeline"); err != nil {
+		return nil, err
+	}
+
+	w, err := m.find(workerID)
+	if err != nil {
+		return nil, err
+	}
+	return append([]worker.StatusChange{}, w.Timeline...), nil
+}
+
+// QueuedMessages returns the messages set for a worker with SetQueue
+func (m *Manager) QueuedMessages(workerID string) ([]worker.QueuedMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure("QueuedMessages"); err != nil {
+		return nil, err
+	}
+
+	if _, err := m.find(workerID); err != nil {
+		return nil, err
+	}
+	return append([]worker.QueuedMessage{}, m.queues[workerID]...), nil
+}
+
+// BackfillThreads isn't supported
+func (m *Manager) BackfillThreads(taskIDs []string, dryRun bool, progress func(done, total int, result worker.BackfillResult)) (*worker.BackfillReport, error) {
+	return nil, ErrNotSupported
+}
+
+// RebuildThread isn't supported
+func (m *Manager) RebuildThread(workerID string) (*worker.ThreadRebuild, error) {
+	return nil, ErrNotSupported
+}
+
+// OpenLog opens the log set with SetLog
+func (m *Manager) OpenLog(workerID string) (io.ReadCloser, error) {
+	return m.openLog("OpenLog", m.logs, workerID)
+}
+
+// OpenAmpLog opens the amp log set with SetAmpLog
+func (m *Manager) OpenAmpLog(workerID string) (io.ReadCloser, error) {
+	return m.openLog("OpenAmpLog", m.ampLogs, workerID)
+}
+
+// openLog opens a worker's log in logs
+func (m *Manager) openLog(method string, logs map[string]string, workerID string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure(method); err != nil {
+		return nil, err
+	}
+
+	if _, err := m.find(workerID); err != nil {
+		return nil, err
+	}
+	content, exists := logs[workerID]
+	if !exists {
+		return nil, worker.ErrLogNotFound
+	}
+	return io.NopCloser(strings.NewReader(content)), nil
+}
+
+// LineLimit returns a limit of the default maximum line size
+func (m *Manager) LineLimit() *worker.LineLimit {
+	return &m.lineLimit
+}
+
+// LogStats reports the default maximum line size and the lines truncated
+// reading the fake's logs
+func (m *Manager) LogStats() worker.LogStats {
+	return worker.LogStats{
+		MaxLineSize:    m.lineLimit.MaxSize,
+		TruncatedLines: m.lineLimit.Truncated(),
+	}
+}
+
+// ListArtifacts isn't supported
+func (m *Manager) ListArtifacts(workerID string) ([]worker.Artifact, error) {
+	return nil, ErrNotSupported
+}
+
+// OpenArtifact isn't supported
+func (m *Manager) OpenArtifact(workerID, name string) (*os.File, *worker.Artifact, error) {
+	return nil, nil, ErrNotSupported
+}
+
+// Workspace isn't supported
+func (m *Manager) Workspace(workerID string) (worker.Workspace, error) {
+	return worker.Workspace{}, ErrNotSupported
+}
+
+// LinkPullRequest records a pull request on a worker with pending checks
+func (m *Manager) LinkPullRequest(workerID, repo string, number int, url string) (*worker.PullRequestLink, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure("LinkPullRequest"); err != nil {
+		return nil, err
+	}
+
+	w, err := m.find(workerID)
+	if err != nil {
+		return nil, err
+	}
+	w.PullRequest = &worker.PullRequestLink{
+		Repo:      repo,
+		Number:    number,
+		URL:       url,
+		CIStatus:  worker.CIPending,
+		UpdatedAt: time.Now(),
+	}
+	link := *w.PullRequest
+	return &link, nil
+}
+
+// UpdateCheck isn't supported
+func (m *Manager) UpdateCheck(repo string, number int, check string, status worker.CIStatus) ([]string, error) {
+	return nil, ErrNotSupported
+}
+
+// SyncIssue isn't supported
+func (m *Manager) SyncIssue(provider, key string, fields worker.IssueFields) ([]string, error) {
+	return nil, ErrNotSupported
+}
+
+// ListProjects returns every project sorted by name
+func (m *Manager) ListProjects() ([]*worker.Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]*worker.Project, 0, len(m.projects))
+	for _, project := range m.projects {
+		copied := *project
+		list = append(list, &copied)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// GetProject returns a project by name
+func (m *Manager) GetProject(name string) (*worker.Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	project, exists := m.projects[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", worker.ErrProjectNotFound, name)
+	}
+	copied := *project
+	return &copied, nil
+}
+
+// CreateProject adds a project. Names aren't validated.
+func (m *Manager) CreateProject(project worker.Project) (*worker.Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure("CreateProject"); err != nil {
+		return nil, err
+	}
+
+	if _, exists := m.projects[project.Name]; exists {
+		return nil, fmt.Errorf("cannot create project %s: it already exists", project.Name)
+	}
+	project.Created = time.Now()
+	m.projects[project.Name] = &project
+	copied := project
+	return &copied, nil
+}
+
+// UpdateProject changes a project's settings
+func (m *Manager) UpdateProject(name string, update worker.ProjectUpdate) (*worker.Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure("UpdateProject"); err != nil {
+		return nil, err
+	}
+
+	project, exists := m.projects[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", worker.ErrProjectNotFound, name)
+	}
+	if update.RepoURL != nil {
+		project.RepoURL = *update.RepoURL
+	}
+	if update.DefaultBranch != nil {
+		project.DefaultBranch = *update.DefaultBranch
+	}
+	if update.Execution != nil {
+		project.Amp.Execution = *update.Execution
+	}
+	if update.Dir != nil {
+		project.Amp.Dir = *update.Dir
+	}
+	copied := *project
+	return &copied, nil
+}
+
+// DeleteProject removes a project that no longer has tasks
+func (m *Manager) DeleteProject(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.failure("DeleteProject"); err != nil {
+		return err
+	}
+
+	if name == worker.DefaultProject {
+		return fmt.Errorf("cannot delete the %s project", worker.DefaultProject)
+	}
+	if _, exists := m.projects[name]; !exists {
+		return fmt.Errorf("%w: %s", worker.ErrProjectNotFound, name)
+	}
+	for _, w := range m.workers {
+		if w.Project == name {
+			return fmt.Errorf("cannot delete project %s: it has tasks", name)
+		}
+	}
+	delete(m.projects, name)
+	return nil
+}
+
+// ReadinessChecks returns no checks, so the fake manager is always ready
+func (m *Manager) ReadinessChecks() []worker.ReadinessCheck {
+	return nil
+}
+
+// RateLimitStats returns no rate limits
+func (m *Manager) RateLimitStats() []worker.RateLimitStats {
+	return nil
+}
+
+// StorageUsage reports an empty log directory
+func (m *Manager) StorageUsage() (worker.StorageUsage, error) {
+	return worker.StorageUsage{}, nil
+}
+
+// AmpStatus reports a healthy amp of unknown version
+func (m *Manager) AmpStatus() worker.AmpStatus {
+	return worker.AmpStatus{Binary: "amp", Healthy: true}
+}
+
+// Backends reports amp as the only backend
+func (m *Manager) Backends() []worker.BackendInfo {
+	return []worker.BackendInfo{{Name: worker.DefaultBackend, Models: []string{}}}
+}
+
+// Pools reports that no worker pools are configured
+func (m *Manager) Pools() ([]worker.PoolInfo, error) {
+	return []worker.PoolInfo{}, nil
+}
+
+// AmpVersion reports that amp's version is unknown
+func (m *Manager) AmpVersion() (worker.AmpVersion, bool) {
+	return worker.AmpVersion{}, false
+}
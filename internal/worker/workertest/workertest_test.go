@@ -0,0 +1,62 @@
+package workertest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+)
+
+func TestManager_ThreadPages(t *testing.T) {
+	manager := New()
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, id := range []string{"m1", "m2", "m3"} {
+		manager.AddThreadMessages("w", worker.ThreadMessage{ID: id, Type: worker.MessageTypeAssistant, Timestamp: base.Add(time.Duration(i) * time.Second)})
+	}
+
+	page, more, err := manager.GetThreadPage("w", nil, 2, false)
+	require.NoError(t, err)
+	assert.True(t, more)
+	require.Len(t, page, 2)
+	assert.Equal(t, "m2", page[1].ID)
+
+	cursor := &worker.ThreadCursor{Timestamp: page[1].Timestamp, ID: page[1].ID}
+	page, more, err = manager.GetThreadPage("w", cursor, 2, false)
+	require.NoError(t, err)
+	assert.False(t, more)
+	require.Len(t, page, 1)
+	assert.Equal(t, "m3", page[0].ID)
+
+	page, _, err = manager.GetThreadPage("w", cursor, 2, true)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "m1", page[0].ID)
+}
+
+func TestManager_Trees(t *testing.T) {
+	manager := New()
+	manager.Add(
+		&worker.Worker{ID: "parent", Status: worker.StatusRunning},
+		&worker.Worker{ID: "child", Status: worker.StatusRunning, ParentID: "parent"},
+		&worker.Worker{ID: "done", Status: worker.StatusCompleted, ParentID: "parent"},
+	)
+
+	stopped, err := manager.StopWorkerTree("parent")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"parent", "child"}, stopped)
+	assert.Equal(t, worker.StatusCompleted, manager.Worker("done").Status)
+	assert.NotNil(t, manager.Worker("child").Finished)
+
+	_, err = manager.StopWorkerTree("parent")
+	assert.ErrorContains(t, err, "not running")
+
+	deleted, err := manager.DeleteWorkerTree("parent", false)
+	require.NoError(t, err)
+	assert.Len(t, deleted, 3)
+	workers, err := manager.ListWorkers()
+	require.NoError(t, err)
+	assert.Empty(t, workers)
+}
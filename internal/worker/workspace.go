@@ -0,0 +1,44 @@
+package worker
+
+import "fmt"
+
+// Workspace is the git checkout a worker makes its changes in
+type Workspace struct {
+	Dir        string // Repository checkout
+	BaseBranch string // Branch the worker's changes are compared with
+}
+
+// SetWorkspace sets the checkout and base branch of workers whose project
+// doesn't set its own directory or default branch
+func (m *Manager) SetWorkspace(workspace Workspace) {
+	m.workspace = workspace
+}
+
+// Workspace returns the checkout a worker makes its changes in: its pool's
+// directory when it runs on the host, or its project's amp directory, and its
+// project's default branch, falling back to the manager's
+func (m *Manager) Workspace(workerID string) (Workspace, error) {
+	workers, err := m.loadWorkers()
+	if err != nil {
+		return Workspace{}, err
+	}
+	worker, exists := workers[workerID]
+	if !exists {
+		return Workspace{}, fmt.Errorf("%w: %s", ErrWorkerNotFound, workerID)
+	}
+
+	workspace := m.workspace
+	if project, err := m.GetProject(worker.ProjectName()); err == nil {
+		if project.Amp.Dir != "" {
+			workspace.Dir = project.Amp.Dir
+		}
+		if project.DefaultBranch != "" {
+			workspace.BaseBranch = project.DefaultBranch
+		}
+	}
+	// Only host workers run in their pool's directory, as in ampCommand
+	if dir := m.poolDir(worker); dir != "" && onHost(worker) {
+		workspace.Dir = dir
+	}
+	return workspace, nil
+}
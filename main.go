@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -75,7 +76,7 @@ func continueCmd() *cobra.Command {
 		Short: "Send a message to an existing amp worker",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			wm := worker.NewManager("")
-			return wm.ContinueWorker(workerID, message)
+			return wm.ContinueWorker(context.Background(), workerID, message)
 		},
 	}
 
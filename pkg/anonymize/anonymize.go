@@ -0,0 +1,295 @@
+// Package anonymize replaces identifying details in text with consistent
+// pseudonyms, so logs and threads can be shared without leaking internal
+// hostnames, usernames, file paths or email addresses.
+package anonymize
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Kinds of identifying details
+const (
+	KindEmail    = "email"
+	KindHost     = "host"
+	KindIP       = "ip"
+	KindPath     = "path"
+	KindUsername = "username"
+)
+
+// identifying matches every detail the anonymizer replaces. Alternatives are
+// tried left to right, so emails win over the hostnames inside them, and
+// replacements are never rescanned.
+var identifying = regexp.MustCompile(
+	`(?P<url>[A-Za-z][A-Za-z0-9+.-]*://[^\s/"'<>?#]+(?:/[^\s"'<>?#]*)?)` +
+		`|(?P<remote>\b[A-Za-z0-9._-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}:[A-Za-z0-9._~-][A-Za-z0-9._/~-]*)` +
+		`|(?P<email>[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,})` +
+		`|(?P<path>~?(?:/[A-Za-z0-9._@+-]+){2,}/?)` +
+		`|(?P<ip>\b(?:\d{1,3}\.){3}\d{1,3}\b)` +
+		`|(?P<host>\b(?:[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?\.)+(?i:com|net|org|io|dev|ai|co|cloud|app|internal|local|lan|corp|intranet|localdomain)\b)`)
+
+// homeDirs are the directories whose children are named after users
+var homeDirs = map[string]bool{"home": true, "Users": true}
+
+// Anonymizer replaces identifying details with pseudonyms such as host1.example
+// or person2@example.com. The same value always gets the same pseudonym from one
+// Anonymizer, so references stay consistent across everything it processes.
+type Anonymizer struct {
+	mu         sync.Mutex
+	pseudonyms map[string]map[string]string // kind -> original -> pseudonym
+	words      map[string]string            // Known names replaced wherever they appear as whole words
+	known      *regexp.Regexp               // Matches the words
+}
+
+// New creates an anonymizer that also replaces the given usernames wherever
+// they appear as whole words
+func New(usernames ...string) *Anonymizer {
+	a := &Anonymizer{
+		pseudonyms: make(map[string]map[string]string),
+		words:      make(map[string]string),
+	}
+	for _, name := range usernames {
+		a.addUsername(name)
+	}
+	return a
+}
+
+// ForLocalMachine creates an anonymizer that knows the current user and the
+// machine's hostname, so bare mentions of them are replaced too
+func ForLocalMachine() *Anonymizer {
+	a := New()
+	if u, err := user.Current(); err == nil {
+		a.addUsername(u.Username)
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		a.addWord(KindHost, strings.ToLower(hostname))
+	}
+	return a
+}
+
+// String anonymizes a piece of text
+func (a *Anonymizer) String(s string) string {
+	s = identifying.ReplaceAllStringFunc(s, a.replace)
+
+	a.mu.Lock()
+	known := a.known
+	a.mu.Unlock()
+	if known == nil {
+		return s
+	}
+	return known.ReplaceAllStringFunc(s, func(word string) string {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		return a.words[strings.ToLower(word)]
+	})
+}
+
+// Value anonymizes the strings in a decoded JSON value, returning a copy
+func (a *Anonymizer) Value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return a.String(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = a.Value(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = a.Value(item)
+		}
+		return out
+	}
+	return v
+}
+
+// Learn records the identifying details in texts without anonymizing them.
+// Usernames are recognised from paths such as /home/alice, so learning
+// everything first also replaces bare mentions that come before the path.
+func (a *Anonymizer) Learn(texts ...string) {
+	for _, s := range texts {
+		identifying.ReplaceAllStringFunc(s, a.replace)
+	}
+}
+
+// Pseudonyms returns how many distinct values of each kind have pseudonyms
+func (a *Anonymizer) Pseudonyms() map[string]int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	counts := make(map[string]int, len(a.pseudonyms))
+	for kind, values := range a.pseudonyms {
+		counts[kind] = len(values)
+	}
+	return counts
+}
+
+// replace returns the pseudonym for one match of the identifying pattern
+func (a *Anonymizer) replace(match string) string {
+	groups := identifying.FindStringSubmatch(match)
+	for i, name := range identifying.SubexpNames() {
+		if name == "" || groups[i] == "" {
+			continue
+		}
+		switch name {
+		case "url":
+			return a.anonymizeURL(match)
+		case "remote":
+			return a.anonymizeRemote(match)
+		case "email":
+			return a.pseudonym(KindEmail, strings.ToLower(match))
+		case "path":
+			return a.anonymizePath(match)
+		case "ip":
+			return a.pseudonym(KindIP, match)
+		case "host":
+			return a.pseudonym(KindHost, strings.ToLower(match))
+		}
+	}
+	return match
+}
+
+// anonymizeURL replaces a URL's host, any user in it, and its path
+func (a *Anonymizer) anonymizeURL(u string) string {
+	scheme, rest, _ := strings.Cut(u, "://")
+	authority, p, hasPath := strings.Cut(rest, "/")
+
+	if userinfo, host, ok := strings.Cut(authority, "@"); ok {
+		username, _, _ := strings.Cut(userinfo, ":")
+		a.addUsername(username)
+		authority = host // Drop credentials entirely
+	}
+
+	host, port, err := net.SplitHostPort(authority)
+	if err != nil {
+		host, port = authority, ""
+	}
+	if net.ParseIP(strings.Trim(host, "[]")) != nil {
+		host = a.pseudonym(KindIP, host)
+	} else {
+		host = a.pseudonym(KindHost, strings.ToLower(host))
+	}
+	if port != "" {
+		host = net.JoinHostPort(host, port)
+	}
+
+	anonymized := scheme + "://" + host
+	if !hasPath {
+		return anonymized
+	}
+	if strings.Count(strings.TrimSuffix(p, "/"), "/") == 0 {
+		// A single segment identifies little on its own
+		return anonymized + "/" + p
+	}
+	return anonymized + a.anonymizePath("/"+p)
+}
+
+// anonymizeRemote replaces the user, host and path of an scp-style git remote
+// such as git@github.com:org/repo.git
+func (a *Anonymizer) anonymizeRemote(remote string) string {
+	userHost, p, _ := strings.Cut(remote, ":")
+	username, host, _ := strings.Cut(userHost, "@")
+	if username != "git" {
+		a.addUsername(username)
+		username = a.pseudonym(KindUsername, username)
+	}
+	host = a.pseudonym(KindHost, strings.ToLower(host))
+
+	if strings.Contains(strings.TrimPrefix(p, "/"), "/") {
+		p = strings.TrimPrefix(a.anonymizePath("/"+strings.TrimPrefix(p, "/")), "/")
+	}
+	return username + "@" + host + ":" + p
+}
+
+// anonymizePath replaces a path's directory with a pseudonym, keeping the file
+// name so shared logs still show which file was involved. Usernames found in
+// home directories are remembered and replaced elsewhere too.
+func (a *Anonymizer) anonymizePath(p string) string {
+	segments := strings.Split(strings.Trim(p, "/~"), "/")
+	for i := 0; i+1 < len(segments); i++ {
+		if homeDirs[segments[i]] {
+			a.addUsername(segments[i+1])
+		}
+	}
+
+	trimmed := strings.TrimSuffix(p, "/")
+	dir, file := path.Split(trimmed)
+	pseudonym := a.pseudonym(KindPath, strings.TrimSuffix(dir, "/"))
+	if strings.HasSuffix(p, "/") {
+		return pseudonym + "/" + file + "/"
+	}
+	return pseudonym + "/" + file
+}
+
+// addUsername registers a username to replace wherever it appears as a word
+func (a *Anonymizer) addUsername(name string) {
+	a.addWord(KindUsername, name)
+}
+
+// addWord assigns a pseudonym to a name and replaces it wherever it appears
+// as a whole word, not just where the patterns recognise it
+func (a *Anonymizer) addWord(kind, name string) {
+	if len(name) < 3 {
+		// Too short to replace without mangling unrelated words
+		return
+	}
+
+	pseudonym := a.pseudonym(kind, name)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.words[strings.ToLower(name)]; ok {
+		return
+	}
+	a.words[strings.ToLower(name)] = pseudonym
+
+	words := make([]string, 0, len(a.words))
+	for word := range a.words {
+		words = append(words, regexp.QuoteMeta(word))
+	}
+	// Longest first so overlapping names match fully
+	sort.Slice(words, func(i, j int) bool { return len(words[i]) > len(words[j]) })
+	a.known = regexp.MustCompile(`(?i)\b(?:` + strings.Join(words, "|") + `)\b`)
+}
+
+// pseudonym returns the pseudonym for a value, assigning the next one for its
+// kind the first time the value is seen
+func (a *Anonymizer) pseudonym(kind, value string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	values := a.pseudonyms[kind]
+	if values == nil {
+		values = make(map[string]string)
+		a.pseudonyms[kind] = values
+	}
+	if pseudonym, ok := values[value]; ok {
+		return pseudonym
+	}
+
+	n := len(values) + 1
+	var pseudonym string
+	switch kind {
+	case KindEmail:
+		pseudonym = fmt.Sprintf("person%d@example.com", n)
+	case KindHost:
+		pseudonym = fmt.Sprintf("host%d.example", n)
+	case KindIP:
+		pseudonym = fmt.Sprintf("192.0.2.%d", (n-1)%254+1) // TEST-NET-1, reserved for documentation
+	case KindPath:
+		pseudonym = fmt.Sprintf("/path%d", n)
+	default:
+		pseudonym = fmt.Sprintf("user%d", n)
+	}
+	values[value] = pseudonym
+	return pseudonym
+}
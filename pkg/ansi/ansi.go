@@ -0,0 +1,118 @@
+// Package ansi removes terminal escape sequences and control characters from
+// amp's output, so it can be shown somewhere other than a terminal, such as a
+// browser, without garbling the text around them.
+package ansi
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const esc = 0x1b
+
+// Strip removes all escape sequences and control characters from s, leaving
+// plain text. Tabs are kept.
+func Strip(s string) string {
+	return clean(s, false)
+}
+
+// Sanitize removes the escape sequences and control characters in s that
+// only a terminal can interpret, such as cursor movement, window titles and
+// carriage returns, keeping the SGR sequences that set colors and styles so
+// clients can still render them. Tabs are kept.
+func Sanitize(s string) string {
+	return clean(s, true)
+}
+
+// clean removes escape sequences and control characters from s, keeping SGR
+// sequences when keepSGR is set. Invalid UTF-8 is replaced with U+FFFD.
+func clean(s string, keepSGR bool) string {
+	if !needsCleaning(s) {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); {
+		if s[i] == esc {
+			end, sgr := escapeEnd(s, i)
+			if sgr && keepSGR {
+				b.WriteString(s[i:end])
+			}
+			i = end
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !isControl(r) {
+			b.WriteRune(r)
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// needsCleaning reports whether s holds anything clean would remove
+func needsCleaning(s string) bool {
+	for _, r := range s {
+		if isControl(r) || r == utf8.RuneError {
+			return true
+		}
+	}
+	return false
+}
+
+// isControl reports whether r is a C0 or C1 control character other than tab
+func isControl(r rune) bool {
+	return (r < 0x20 && r != '\t') || (r >= 0x7f && r <= 0x9f)
+}
+
+// escapeEnd returns the index just past the escape sequence starting at s[i],
+// and whether it is a complete SGR sequence. A sequence cut short ends at the
+// first byte that can't be part of it.
+func escapeEnd(s string, i int) (int, bool) {
+	j := i + 1
+	if j >= len(s) {
+		return j, false
+	}
+
+	switch s[j] {
+	case '[':
+		// CSI: parameter bytes, then intermediate bytes, then a final byte
+		j++
+		for j < len(s) && s[j] >= 0x30 && s[j] <= 0x3f {
+			j++
+		}
+		for j < len(s) && s[j] >= 0x20 && s[j] <= 0x2f {
+			j++
+		}
+		if j < len(s) && s[j] >= 0x40 && s[j] <= 0x7e {
+			return j + 1, s[j] == 'm'
+		}
+		return j, false
+
+	case ']', 'P', 'X', '^', '_':
+		// OSC, DCS, SOS, PM and APC run to BEL or ST (ESC \)
+		for j++; j < len(s); j++ {
+			if s[j] == 0x07 {
+				return j + 1, false
+			}
+			if s[j] == esc {
+				if j+1 < len(s) && s[j+1] == '\\' {
+					return j + 2, false
+				}
+				return j, false
+			}
+		}
+		return j, false
+	}
+
+	// Other sequences are intermediate bytes followed by a final byte
+	for j < len(s) && s[j] >= 0x20 && s[j] <= 0x2f {
+		j++
+	}
+	if j < len(s) && s[j] >= 0x30 && s[j] <= 0x7e {
+		j++
+	}
+	return j, false
+}
@@ -0,0 +1,55 @@
+package ansi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"plain text", "hello\tworld", "hello\tworld"},
+		{"colors", "\x1b[1;32mok\x1b[0m done", "ok done"},
+		{"cursor movement", "\x1b[2K\x1b[1Gprogress", "progress"},
+		{"private mode", "\x1b[?25lhidden\x1b[?25h", "hidden"},
+		{"window title", "\x1b]0;amp\x07text", "text"},
+		{"hyperlink", "\x1b]8;;https://ampcode.com\x1b\\link\x1b]8;;\x1b\\", "link"},
+		{"charset", "\x1b(Bascii", "ascii"},
+		{"control characters", "a\rb\x00c\x08d\x7fe", "abcde"},
+		{"c1 control", "a\u009bb", "ab"},
+		{"cut short", "text\x1b[1;3", "text"},
+		{"lone escape", "text\x1b", "text"},
+		{"invalid utf-8", "a\xffb", "a�b"},
+		{"unicode", "✓ héllo", "✓ héllo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Strip(tt.input))
+		})
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"keeps colors", "\x1b[1;32mok\x1b[0m", "\x1b[1;32mok\x1b[0m"},
+		{"drops cursor movement", "\x1b[2K\x1b[31mred", "\x1b[31mred"},
+		{"drops window title", "\x1b]0;amp\x07\x1b[0mtext", "\x1b[0mtext"},
+		{"drops control characters", "a\rb\x07c", "abc"},
+		{"drops incomplete color", "text\x1b[31", "text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Sanitize(tt.input))
+		})
+	}
+}
@@ -3,13 +3,16 @@ package apierr
 import (
 	"fmt"
 	"net/http"
+	"strings"
 )
 
 // APIError represents an API error with HTTP status code and message
 type APIError struct {
-	StatusCode int    `json:"status_code"`
-	Message    string `json:"message"`
-	Err        error  `json:"-"` // Don't serialize the underlying error
+	StatusCode int         `json:"status_code"`
+	Code       string      `json:"code"`              // Machine-readable error code, e.g. "not_found"
+	Message    string      `json:"message"`
+	Details    interface{} `json:"details,omitempty"` // Additional context for the client
+	Err        error       `json:"-"`                 // Don't serialize the underlying error
 }
 
 // Error implements the error interface
@@ -25,10 +28,32 @@ func (e *APIError) Unwrap() error {
 	return e.Err
 }
 
+// WithCode overrides the default error code derived from the status code
+func (e *APIError) WithCode(code string) *APIError {
+	e.Code = code
+	return e
+}
+
+// WithDetails attaches additional context returned to the client
+func (e *APIError) WithDetails(details interface{}) *APIError {
+	e.Details = details
+	return e
+}
+
+// DefaultCode returns the error code for a status code, e.g. "bad_request" for 400
+func DefaultCode(statusCode int) string {
+	text := http.StatusText(statusCode)
+	if text == "" {
+		return "error"
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
+
 // New creates a new API error
 func New(statusCode int, message string) *APIError {
 	return &APIError{
 		StatusCode: statusCode,
+		Code:       DefaultCode(statusCode),
 		Message:    message,
 	}
 }
@@ -37,6 +62,7 @@ func New(statusCode int, message string) *APIError {
 func Wrap(err error, statusCode int, message string) *APIError {
 	return &APIError{
 		StatusCode: statusCode,
+		Code:       DefaultCode(statusCode),
 		Message:    message,
 		Err:        err,
 	}
@@ -72,6 +98,10 @@ func Conflictf(format string, args ...interface{}) *APIError {
 	return New(http.StatusConflict, fmt.Sprintf(format, args...))
 }
 
+func TooManyRequests(message string) *APIError {
+	return New(http.StatusTooManyRequests, message)
+}
+
 func InternalError(message string) *APIError {
 	return New(http.StatusInternalServerError, message)
 }
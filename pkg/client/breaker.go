@@ -0,0 +1,108 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBreakerThreshold is the number of consecutive failures that opens the circuit
+	DefaultBreakerThreshold = 5
+
+	// DefaultBreakerCooldown is how long the circuit stays open before a trial call
+	DefaultBreakerCooldown = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned without contacting the daemon while the circuit is open
+var ErrCircuitOpen = errors.New("circuit open: daemon is unavailable")
+
+// BreakerState is the state of a circuit breaker
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"    // Calls go through
+	BreakerOpen     BreakerState = "open"      // Calls fail fast with ErrCircuitOpen
+	BreakerHalfOpen BreakerState = "half-open" // One trial call decides whether to close
+)
+
+// Breaker stops calls to a daemon that keeps failing. After Threshold
+// consecutive failures it rejects calls for Cooldown, then lets a single
+// trial call through; its success closes the circuit and its failure reopens it.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	now      func() time.Time
+}
+
+// NewBreaker creates a closed circuit breaker
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     BreakerClosed,
+		now:       time.Now,
+	}
+}
+
+// State returns the breaker's current state
+func (b *Breaker) State() BreakerState {
+	if b == nil {
+		return BreakerClosed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Allow returns ErrCircuitOpen when a call must not be attempted. Once the
+// cooldown has passed, the first caller is allowed through as the trial call.
+func (b *Breaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		return nil
+	case BreakerHalfOpen:
+		// A trial call is already in flight
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// Record reports the outcome of an allowed call
+func (b *Breaker) Record(success bool) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	}
+}
@@ -0,0 +1,190 @@
+// Package client is a Go client for the ampd HTTP API, with typed task calls
+// and a subscriber for the events of /api/ws. It retries idempotent calls
+// with jittered backoff, stops calling a daemon that keeps failing, and lets
+// callers wrap every request with middleware such as authentication and
+// logging.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+)
+
+// Doer sends a single HTTP request
+type Doer func(req *http.Request) (*http.Response, error)
+
+// Middleware wraps every HTTP request the client sends, including retries
+type Middleware func(next Doer) Doer
+
+// Client calls the ampd API
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	retry      RetryPolicy
+	breaker    *Breaker
+	middleware []Middleware
+}
+
+// New creates a client for the daemon at baseURL, e.g. http://localhost:8080,
+// with the default retry policy and circuit breaker
+func New(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		retry:      DefaultRetryPolicy(),
+		breaker:    NewBreaker(DefaultBreakerThreshold, DefaultBreakerCooldown),
+	}
+}
+
+// SetHTTPClient sets the HTTP client used to send requests
+func (c *Client) SetHTTPClient(httpClient *http.Client) {
+	c.httpClient = httpClient
+}
+
+// SetRetryPolicy sets how failed idempotent calls are retried
+func (c *Client) SetRetryPolicy(policy RetryPolicy) {
+	c.retry = policy
+}
+
+// SetBreaker sets the circuit breaker; nil disables circuit breaking
+func (c *Client) SetBreaker(breaker *Breaker) {
+	c.breaker = breaker
+}
+
+// Use appends middleware to the chain. The first middleware added is the
+// outermost, seeing each request before the others.
+func (c *Client) Use(middleware ...Middleware) {
+	c.middleware = append(c.middleware, middleware...)
+}
+
+// Get calls a GET endpoint and decodes the response into out
+func (c *Client) Get(ctx context.Context, path string, out interface{}) error {
+	return c.Do(ctx, http.MethodGet, path, nil, out)
+}
+
+// Post calls a POST endpoint with a JSON body and decodes the response into out
+func (c *Client) Post(ctx context.Context, path string, body, out interface{}) error {
+	return c.Do(ctx, http.MethodPost, path, body, out)
+}
+
+// Do sends a request with an optional JSON body and decodes a successful JSON
+// response into out when it isn't nil. Error responses are returned as
+// *apierr.APIError.
+func (c *Client) Do(ctx context.Context, method, path string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to encode request body: %w", err)
+		}
+	}
+
+	resp, err := c.send(ctx, method, path, payload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return decodeError(resp)
+	}
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// send performs a request through the middleware chain, retrying idempotent
+// requests and consulting the circuit breaker before every attempt
+func (c *Client) send(ctx context.Context, method, path string, payload []byte) (*http.Response, error) {
+	doer := c.chain()
+	idempotent := isIdempotent(method)
+
+	for attempt := 0; ; attempt++ {
+		req, err := c.newRequest(ctx, method, path, payload)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.breaker.Allow(); err != nil {
+			return nil, err
+		}
+
+		resp, err := doer(req)
+		c.breaker.Record(err == nil && resp.StatusCode < 500)
+
+		if !idempotent || attempt+1 >= c.retry.MaxAttempts || !retryable(ctx, resp, err) {
+			if err != nil {
+				return nil, err
+			}
+			return resp, nil
+		}
+
+		delay := c.retry.backoff(attempt, resp)
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// newRequest builds a request whose body can be replayed by retries and middleware
+func (c *Client) newRequest(ctx context.Context, method, path string, payload []byte) (*http.Request, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// chain wraps the HTTP client with the configured middleware
+func (c *Client) chain() Doer {
+	doer := Doer(c.httpClient.Do)
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		doer = c.middleware[i](doer)
+	}
+	return doer
+}
+
+// decodeError converts an error response into an API error, falling back to
+// the status text when the body isn't the JSON error envelope
+func decodeError(resp *http.Response) error {
+	apiErr := apierr.New(resp.StatusCode, http.StatusText(resp.StatusCode))
+
+	var body response.ErrorBody
+	if err := json.NewDecoder(resp.Body).Decode(&body); err == nil && body.Message != "" {
+		apiErr.Message = body.Message
+		apiErr.Details = body.Details
+		if body.Code != "" {
+			apiErr.Code = body.Code
+		}
+	}
+	return apiErr
+}
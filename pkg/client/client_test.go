@@ -0,0 +1,155 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+)
+
+// fastRetries retries quickly so tests don't wait on real backoff delays
+var fastRetries = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+func newTestClient(url string) *Client {
+	c := New(url)
+	c.SetRetryPolicy(fastRetries)
+	return c
+}
+
+func TestClient_RetriesIdempotentCalls(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			response.Error(w, http.StatusServiceUnavailable, "Starting up")
+			return
+		}
+		response.OK(w, map[string]string{"status": "ok"})
+	}))
+	defer server.Close()
+
+	var out map[string]string
+	require.NoError(t, newTestClient(server.URL).Get(context.Background(), "/api/tasks", &out))
+	assert.Equal(t, "ok", out["status"])
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestClient_DoesNotRetryPost(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		response.Error(w, http.StatusServiceUnavailable, "Starting up")
+	}))
+	defer server.Close()
+
+	err := newTestClient(server.URL).Post(context.Background(), "/api/tasks", map[string]string{"message": "hi"}, nil)
+
+	var apiErr *apierr.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	assert.Equal(t, "Starting up", apiErr.Message)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestClient_DecodesErrorEnvelope(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response.Error(w, http.StatusNotFound, "Task not found")
+	}))
+	defer server.Close()
+
+	err := newTestClient(server.URL).Get(context.Background(), "/api/tasks/missing/logs", nil)
+
+	var apiErr *apierr.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "not_found", apiErr.Code)
+	assert.Equal(t, "Task not found", apiErr.Message)
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for attempt := 0; attempt < 6; attempt++ {
+		ceiling := minDuration(policy.BaseDelay<<uint(attempt), policy.MaxDelay)
+		delay := policy.backoff(attempt, nil)
+		assert.GreaterOrEqual(t, delay, time.Duration(0))
+		assert.LessOrEqual(t, delay, ceiling)
+	}
+
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"30"}}}
+	assert.Equal(t, time.Second, policy.backoff(0, resp), "Retry-After is capped at MaxDelay")
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := NewBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	require.NoError(t, b.Allow())
+	b.Record(false)
+	assert.Equal(t, BreakerClosed, b.State())
+	b.Record(false)
+	assert.Equal(t, BreakerOpen, b.State())
+	assert.ErrorIs(t, b.Allow(), ErrCircuitOpen)
+
+	// After the cooldown a single trial call is allowed
+	now = now.Add(time.Minute)
+	require.NoError(t, b.Allow())
+	assert.ErrorIs(t, b.Allow(), ErrCircuitOpen)
+
+	// A failed trial reopens the circuit; a successful one closes it
+	b.Record(false)
+	assert.Equal(t, BreakerOpen, b.State())
+	now = now.Add(time.Minute)
+	require.NoError(t, b.Allow())
+	b.Record(true)
+	assert.Equal(t, BreakerClosed, b.State())
+}
+
+func TestClient_CircuitOpensWhenDaemonIsDown(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	c := newTestClient(url)
+	c.SetBreaker(NewBreaker(3, time.Minute))
+
+	// The first call's retries exhaust the failure threshold
+	err := c.Get(context.Background(), "/api/tasks", nil)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrCircuitOpen)
+
+	err = c.Get(context.Background(), "/api/tasks", nil)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+}
+
+func TestBearerAuth_RefreshesRejectedToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			response.Error(w, http.StatusUnauthorized, "Invalid token")
+			return
+		}
+		response.OK(w, map[string]string{"status": "ok"})
+	}))
+	defer server.Close()
+
+	var refreshes int
+	c := newTestClient(server.URL)
+	c.Use(BearerAuth(func(ctx context.Context, refresh bool) (string, error) {
+		if refresh {
+			refreshes++
+			return "fresh", nil
+		}
+		return "stale", nil
+	}))
+
+	require.NoError(t, c.Post(context.Background(), "/api/tasks", map[string]string{"message": "hi"}, nil))
+	assert.Equal(t, 1, refreshes)
+}
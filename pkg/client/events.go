@@ -0,0 +1,199 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// pingInterval is how often a subscriber pings the daemon, well within the
+// time after which the daemon drops silent clients
+const pingInterval = 30 * time.Second
+
+// Event is an event broadcast by the daemon over /api/ws
+type Event struct {
+	Type      string          `json:"type"` // e.g. "task-update", "log" or "thread_message"
+	Seq       uint64          `json:"seq,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// Resync says events were missed while the subscriber was disconnected and
+// are no longer available, so state built from events must be reloaded
+type Resync struct {
+	Since   uint64 `json:"since"`              // Last event the subscriber had received
+	LastSeq uint64 `json:"last_seq,omitempty"` // Events are delivered again after this one
+}
+
+// SubscribeOptions selects the events Subscribe delivers
+type SubscribeOptions struct {
+	Types   []string // Event types; empty delivers every type
+	TaskIDs []string // Task IDs or patterns such as "proj-*"; empty delivers events of every task
+	Since   uint64   // Resume after this sequence number; 0 starts with new events
+
+	// Token authenticates the connection when the daemon requires tokens
+	Token TokenFunc
+
+	// OnResync is called when missed events couldn't be replayed after a
+	// reconnect. Nil ignores the gap.
+	OnResync func(Resync)
+}
+
+// controlTypes are protocol messages that aren't delivered as events
+var controlTypes = map[string]bool{
+	"pong":          true,
+	"heartbeat":     true,
+	"session":       true,
+	"subscriptions": true,
+	"auth-ok":       true,
+}
+
+// Subscribe delivers events broadcast by the daemon to handle until ctx is
+// done. Dropped connections are reopened with the client's retry backoff and
+// resume after the last event delivered, so handle sees each event once.
+// Subscribe returns ctx's error, or the daemon's error when it refuses the
+// connection, e.g. because the token is invalid.
+func (c *Client) Subscribe(ctx context.Context, opts SubscribeOptions, handle func(Event)) error {
+	types := make(map[string]bool, len(opts.Types))
+	for _, msgType := range opts.Types {
+		types[msgType] = true
+	}
+
+	since := opts.Since
+	refresh := false
+	for attempt := 0; ; attempt++ {
+		conn, resp, err := c.dialEvents(ctx, opts, since, refresh)
+		refresh = false
+		if err == nil {
+			// Read until the connection drops, then back off from scratch
+			attempt = 0
+			c.readEvents(ctx, conn, opts, types, &since, handle)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if resp != nil {
+			switch {
+			case resp.StatusCode == http.StatusUnauthorized && opts.Token != nil && attempt == 0:
+				refresh = true
+			case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests:
+				return decodeError(resp)
+			}
+		}
+
+		select {
+		case <-time.After(c.retry.backoff(attempt, resp)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// dialEvents opens the event socket, resuming after since when it is set
+func (c *Client) dialEvents(ctx context.Context, opts SubscribeOptions, since uint64, refresh bool) (*websocket.Conn, *http.Response, error) {
+	endpoint, err := url.Parse(c.baseURL + "/api/ws")
+	if err != nil {
+		return nil, nil, err
+	}
+	endpoint.Scheme = strings.Replace(endpoint.Scheme, "http", "ws", 1)
+	if since > 0 {
+		endpoint.RawQuery = url.Values{"since": {strconv.FormatUint(since, 10)}}.Encode()
+	}
+
+	header := http.Header{}
+	if opts.Token != nil {
+		token, err := opts.Token(ctx, refresh)
+		if err != nil {
+			return nil, nil, err
+		}
+		header.Set("Authorization", "Bearer "+token)
+	}
+
+	// A failed handshake returns the response, whose body needn't be closed
+	return websocket.DefaultDialer.DialContext(ctx, endpoint.String(), header)
+}
+
+// readEvents subscribes and delivers events until the connection drops or ctx
+// is done, recording the sequence number of each event delivered
+func (c *Client) readEvents(ctx context.Context, conn *websocket.Conn, opts SubscribeOptions, types map[string]bool, since *uint64, handle func(Event)) error {
+	defer conn.Close()
+
+	if len(opts.Types) > 0 || len(opts.TaskIDs) > 0 {
+		subscribe := map[string]interface{}{
+			"type": "subscribe",
+			"data": map[string][]string{"types": opts.Types, "task_ids": opts.TaskIDs},
+		}
+		if err := conn.WriteJSON(subscribe); err != nil {
+			return err
+		}
+	}
+
+	// Pinging keeps the daemon from dropping the connection; closing it ends
+	// the read loop when ctx is done
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				conn.WriteJSON(map[string]string{"type": "ping"})
+			case <-ctx.Done():
+				conn.Close()
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		_, frame, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		// The daemon sends the messages queued for a client in one frame,
+		// separated by newlines
+		decoder := json.NewDecoder(bytes.NewReader(frame))
+		for {
+			var event Event
+			if err := decoder.Decode(&event); err != nil {
+				break
+			}
+			deliverEvent(event, opts, types, since, handle)
+		}
+	}
+}
+
+// deliverEvent passes an event to handle unless it is a protocol message or
+// of a type the subscriber didn't ask for, and records its sequence number
+func deliverEvent(event Event, opts SubscribeOptions, types map[string]bool, since *uint64, handle func(Event)) {
+	if event.Type == "resync-required" {
+		var resync Resync
+		json.Unmarshal(event.Data, &resync)
+		if resync.LastSeq > 0 {
+			*since = resync.LastSeq
+		}
+		if opts.OnResync != nil {
+			opts.OnResync(resync)
+		}
+		return
+	}
+	if event.Seq > *since {
+		*since = event.Seq
+	}
+	if controlTypes[event.Type] || len(types) > 0 && !types[event.Type] {
+		// Events replayed on reconnect aren't filtered by subscription
+		return
+	}
+	handle(event)
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub/hubtest"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+)
+
+// eventServer serves a hub's events and can drop its WebSocket connections,
+// which httptest doesn't track once they are hijacked
+type eventServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func newEventServer(t *testing.T, h *hub.Hub) *eventServer {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/ws", h.ServeWS)
+	server := &eventServer{Server: httptest.NewUnstartedServer(mux)}
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateHijacked {
+			server.mu.Lock()
+			server.conns = append(server.conns, conn)
+			server.mu.Unlock()
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *eventServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func TestClient_SubscribeResumesAfterReconnect(t *testing.T) {
+	h := hub.NewHub()
+	require.NoError(t, h.EnableReplay(hub.ReplayConfig{Size: 10}))
+	hubtest.Run(t, h)
+	server := newEventServer(t, h)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan Event, 10)
+	done := make(chan error, 1)
+	go func() {
+		// Reconnect slowly enough to broadcast while disconnected
+		c := New(server.URL)
+		c.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: 300 * time.Millisecond, MaxDelay: 300 * time.Millisecond})
+		done <- c.Subscribe(ctx, SubscribeOptions{Types: []string{"task-update"}}, func(event Event) {
+			events <- event
+		})
+	}()
+
+	connected := func(clients int) func() bool {
+		return func() bool { return h.Totals().Clients == clients }
+	}
+	next := func() Event {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(2 * time.Second):
+			t.Fatal("no event received")
+			return Event{}
+		}
+	}
+
+	require.Eventually(t, connected(1), 2*time.Second, 10*time.Millisecond)
+	h.Broadcast([]byte(`{"type":"log","data":{"content":"hi"}}`))
+	h.Broadcast([]byte(`{"type":"task-update","data":{"id":"a"}}`))
+	event := next()
+	assert.JSONEq(t, `{"id":"a"}`, string(event.Data))
+	assert.Equal(t, uint64(2), event.Seq)
+
+	// Events broadcast while disconnected are replayed once
+	server.dropConnections()
+	require.Eventually(t, connected(0), 2*time.Second, 10*time.Millisecond)
+	h.Broadcast([]byte(`{"type":"task-update","data":{"id":"b"}}`))
+	assert.JSONEq(t, `{"id":"b"}`, string(next().Data))
+
+	require.Eventually(t, connected(1), 2*time.Second, 10*time.Millisecond)
+	h.Broadcast([]byte(`{"type":"task-update","data":{"id":"c"}}`))
+	assert.JSONEq(t, `{"id":"c"}`, string(next().Data))
+	assert.Empty(t, events)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestClient_SubscribeRejected(t *testing.T) {
+	h := hub.NewHub()
+	h.SetAuthenticator(hub.TokenAuthenticator(map[string]hub.Identity{"secret": {User: "alice"}}))
+	hubtest.Run(t, h)
+	server := newEventServer(t, h)
+
+	var refreshed bool
+	token := func(ctx context.Context, refresh bool) (string, error) {
+		refreshed = refreshed || refresh
+		return "wrong", nil
+	}
+	err := newTestClient(server.URL).Subscribe(context.Background(), SubscribeOptions{Token: token}, func(Event) {})
+
+	var apiErr *apierr.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	assert.True(t, refreshed)
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+)
+
+// TokenFunc returns the API token to send. refresh is true when the daemon
+// rejected the previous token, so a fresh one should be fetched.
+type TokenFunc func(ctx context.Context, refresh bool) (string, error)
+
+// BearerAuth sends the token from tokenFunc as a bearer Authorization header.
+// When the daemon responds 401 it refreshes the token and retries the request once.
+func BearerAuth(tokenFunc TokenFunc) Middleware {
+	return func(next Doer) Doer {
+		return func(req *http.Request) (*http.Response, error) {
+			token, err := tokenFunc(req.Context(), false)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			resp, err := next(req)
+			if err != nil || resp.StatusCode != http.StatusUnauthorized {
+				return resp, err
+			}
+
+			// Without GetBody the request body has been consumed and can't be resent
+			if req.Body != nil && req.GetBody == nil {
+				return resp, nil
+			}
+			retry := req.Clone(req.Context())
+			if req.GetBody != nil {
+				if retry.Body, err = req.GetBody(); err != nil {
+					return resp, nil
+				}
+			}
+
+			token, err = tokenFunc(req.Context(), true)
+			if err != nil {
+				return resp, nil
+			}
+			resp.Body.Close()
+			retry.Header.Set("Authorization", "Bearer "+token)
+			return next(retry)
+		}
+	}
+}
+
+// Logging logs every request the client sends, with its status and duration
+func Logging(logger *log.Logger) Middleware {
+	return func(next Doer) Doer {
+		return func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next(req)
+			if err != nil {
+				logger.Printf("%s %s failed after %v: %v", req.Method, req.URL.Path, time.Since(start), err)
+				return resp, err
+			}
+			logger.Printf("%s %s %d %v", req.Method, req.URL.Path, resp.StatusCode, time.Since(start))
+			return resp, nil
+		}
+	}
+}
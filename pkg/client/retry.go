@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how failed idempotent calls are retried
+type RetryPolicy struct {
+	MaxAttempts int           // Attempts including the first; 1 disables retries
+	BaseDelay   time.Duration // Backoff ceiling for the first retry, doubled for each one after
+	MaxDelay    time.Duration // Upper bound on any single delay, including Retry-After
+}
+
+// DefaultRetryPolicy returns the policy used by new clients
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   200 * time.Millisecond,
+		MaxDelay:    5 * time.Second,
+	}
+}
+
+// backoff returns how long to wait before retrying after the given attempt,
+// honouring a Retry-After header when the response has one. Delays are
+// drawn uniformly up to the exponential ceiling so clients don't retry in
+// lockstep.
+func (p RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return minDuration(time.Duration(seconds)*time.Second, p.MaxDelay)
+		}
+	}
+
+	ceiling := p.MaxDelay
+	if attempt < 30 {
+		ceiling = minDuration(p.BaseDelay<<uint(attempt), p.MaxDelay)
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// isIdempotent reports whether requests with the method can safely be repeated
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryable reports whether a failed attempt is worth retrying: transport
+// errors other than cancellation, rate limiting and unavailable gateways
+func retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
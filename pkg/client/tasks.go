@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// taskPath returns the path of a task, or of one of its actions such as "stop"
+func taskPath(id, action string) string {
+	path := "/api/tasks/" + url.PathEscape(id)
+	if action != "" {
+		path += "/" + action
+	}
+	return path
+}
+
+// ListTasks returns a page of tasks
+func (c *Client) ListTasks(ctx context.Context, opts ListOptions) (*TaskPage, error) {
+	path := "/api/tasks"
+	if query := opts.values().Encode(); query != "" {
+		path += "?" + query
+	}
+
+	var page TaskPage
+	if err := c.Get(ctx, path, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// GetTask returns a task
+func (c *Client) GetTask(ctx context.Context, id string) (*Task, error) {
+	var task Task
+	if err := c.Get(ctx, taskPath(id, ""), &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// StartTask starts a task
+func (c *Client) StartTask(ctx context.Context, req StartTaskRequest) (*Task, error) {
+	var task Task
+	if err := c.Post(ctx, "/api/tasks", req, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// UpdateTask changes a task's title, description, tags or priority
+func (c *Client) UpdateTask(ctx context.Context, id string, req UpdateTaskRequest) (*Task, error) {
+	var task Task
+	if err := c.Do(ctx, http.MethodPatch, taskPath(id, ""), req, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// DeleteTask deletes a stopped task, or a running one when force is set
+func (c *Client) DeleteTask(ctx context.Context, id string, force bool) error {
+	path := taskPath(id, "")
+	if force {
+		path += "?force=true"
+	}
+	return c.Do(ctx, http.MethodDelete, path, nil, nil)
+}
+
+// ContinueTask sends a message to a task and returns the number of the
+// attempt that will send it. It returns once the message is queued, before
+// amp has answered.
+func (c *Client) ContinueTask(ctx context.Context, id, message string) (int, error) {
+	var resp struct {
+		Attempt int `json:"attempt"`
+	}
+	if err := c.Post(ctx, taskPath(id, "continue"), map[string]string{"message": message}, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Attempt, nil
+}
+
+// RetryTask restarts a task with a new message
+func (c *Client) RetryTask(ctx context.Context, id, message string) error {
+	return c.Post(ctx, taskPath(id, "retry"), map[string]string{"message": message}, nil)
+}
+
+// StopTask stops a task
+func (c *Client) StopTask(ctx context.Context, id string) error {
+	return c.Post(ctx, taskPath(id, "stop"), nil, nil)
+}
+
+// InterruptTask interrupts a running task with SIGINT
+func (c *Client) InterruptTask(ctx context.Context, id string) error {
+	return c.Post(ctx, taskPath(id, "interrupt"), nil, nil)
+}
+
+// AbortTask terminates a task with SIGKILL
+func (c *Client) AbortTask(ctx context.Context, id string) error {
+	return c.Post(ctx, taskPath(id, "abort"), nil, nil)
+}
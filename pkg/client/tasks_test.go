@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/response"
+)
+
+func TestClient_Tasks(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/tasks":
+			response.OK(w, map[string]interface{}{
+				"tasks":   []map[string]string{{"id": "a", "status": "running"}},
+				"total":   1,
+				"missing": []string{"gone"},
+			})
+		case "POST /api/tasks":
+			var req StartTaskRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			response.Created(w, map[string]string{"id": "b", "status": "running", "title": req.Title})
+		case "GET /api/tasks/b":
+			response.OK(w, map[string]interface{}{"id": "b", "status": "running", "child_count": 2})
+		case "PATCH /api/tasks/b":
+			var req map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			response.OK(w, map[string]interface{}{"id": "b", "priority": req["priority"]})
+		case "POST /api/tasks/b/continue":
+			response.JSON(w, http.StatusAccepted, map[string]int{"attempt": 3})
+		case "POST /api/tasks/b/stop":
+			w.WriteHeader(http.StatusAccepted)
+		case "DELETE /api/tasks/b":
+			response.Error(w, http.StatusConflict, "Task is running")
+		default:
+			response.Error(w, http.StatusNotFound, "Not found")
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	c := newTestClient(server.URL)
+
+	page, err := c.ListTasks(ctx, ListOptions{Status: []string{"running", "stopped"}, IDs: []string{"a", "gone"}, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Tasks, 1)
+	assert.Equal(t, "a", page.Tasks[0].ID)
+	assert.Equal(t, []string{"gone"}, page.Missing)
+
+	task, err := c.StartTask(ctx, StartTaskRequest{Message: "Fix the build", Title: "Build"})
+	require.NoError(t, err)
+	assert.Equal(t, "Build", task.Title)
+
+	task, err = c.GetTask(ctx, "b")
+	require.NoError(t, err)
+	assert.Equal(t, 2, task.ChildCount)
+
+	priority := "high"
+	task, err = c.UpdateTask(ctx, "b", UpdateTaskRequest{Priority: &priority})
+	require.NoError(t, err)
+	assert.Equal(t, "high", task.Priority)
+
+	attempt, err := c.ContinueTask(ctx, "b", "Also run the tests")
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempt)
+
+	require.NoError(t, c.StopTask(ctx, "b"))
+
+	err = c.DeleteTask(ctx, "b", true)
+	var apiErr *apierr.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusConflict, apiErr.StatusCode)
+
+	assert.Equal(t, []string{
+		"GET /api/tasks?ids=a%2Cgone&limit=10&status=running%2Cstopped",
+		"POST /api/tasks",
+		"GET /api/tasks/b",
+		"PATCH /api/tasks/b",
+		"POST /api/tasks/b/continue",
+		"POST /api/tasks/b/stop",
+		"DELETE /api/tasks/b?force=true",
+	}, requests)
+}
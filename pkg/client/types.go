@@ -0,0 +1,119 @@
+package client
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Task is a task as the API returns it. Nested objects such as the failure
+// reason or linked issue aren't decoded; use Do with your own type for those.
+type Task struct {
+	ID          string     `json:"id"`
+	ThreadID    string     `json:"thread_id"`
+	Status      string     `json:"status"`
+	Started     time.Time  `json:"started"`
+	Finished    *time.Time `json:"finished,omitempty"`
+	LogFile     string     `json:"log_file"`
+	Title       string     `json:"title,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Priority    string     `json:"priority,omitempty"`
+
+	StatusReason string `json:"status_reason,omitempty"`
+	Execution    string `json:"execution,omitempty"`
+	Agent        string `json:"agent,omitempty"`
+	Project      string `json:"project"`
+	Owner        string `json:"owner,omitempty"`
+	CIStatus     string `json:"ci_status,omitempty"`
+	AutoCommit   bool   `json:"auto_commit,omitempty"`
+	Restarts     int    `json:"restarts,omitempty"`
+	Profile      string `json:"profile,omitempty"`
+	Pool         string `json:"pool,omitempty"`
+	Backend      string `json:"backend"`
+	Model        string `json:"model,omitempty"`
+
+	ParentID          string         `json:"parent_id,omitempty"`
+	ChildCount        int            `json:"child_count,omitempty"`
+	ChildStatusCounts map[string]int `json:"child_status_counts,omitempty"`
+	AggregateStatus   string         `json:"aggregate_status,omitempty"`
+}
+
+// TaskPage is a page of tasks from ListTasks
+type TaskPage struct {
+	Tasks      []Task   `json:"tasks"`
+	NextCursor string   `json:"next_cursor,omitempty"` // Set as ListOptions.Cursor for the next page
+	HasMore    bool     `json:"has_more"`
+	Total      int      `json:"total"`
+	Missing    []string `json:"missing,omitempty"` // IDs in ListOptions.IDs that match no task
+}
+
+// ListOptions filters, sorts and pages ListTasks. Zero values use the
+// daemon's defaults.
+type ListOptions struct {
+	Status     []string
+	Project    string
+	Owner      string // A user, or "me" for the caller
+	Tags       []string
+	Priorities []string
+	Query      string // Substring of the title or description
+	IDs        []string
+	Fields     []string // Fields returned for each task; empty returns them all
+	SortBy     string
+	SortOrder  string
+	Limit      int
+	Cursor     string
+}
+
+// values encodes the options as query parameters
+func (o ListOptions) values() url.Values {
+	values := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	set("status", strings.Join(o.Status, ","))
+	set("project", o.Project)
+	set("owner", o.Owner)
+	set("tag", strings.Join(o.Tags, ","))
+	set("priority", strings.Join(o.Priorities, ","))
+	set("q", o.Query)
+	set("ids", strings.Join(o.IDs, ","))
+	set("fields", strings.Join(o.Fields, ","))
+	set("sort_by", o.SortBy)
+	set("sort_order", o.SortOrder)
+	set("cursor", o.Cursor)
+	if o.Limit > 0 {
+		values.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return values
+}
+
+// StartTaskRequest is the body of StartTask
+type StartTaskRequest struct {
+	Message     string            `json:"message"`
+	Title       string            `json:"title,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Priority    string            `json:"priority,omitempty"`
+	ParentID    string            `json:"parent_id,omitempty"`
+	Project     string            `json:"project,omitempty"`
+	Execution   string            `json:"execution,omitempty"`
+	AutoCommit  bool              `json:"auto_commit,omitempty"`
+	Profile     string            `json:"profile,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`
+	Secrets     map[string]string `json:"secrets,omitempty"`
+	Backend     string            `json:"backend,omitempty"`
+	Model       string            `json:"model,omitempty"`
+	Pool        string            `json:"pool,omitempty"`
+}
+
+// UpdateTaskRequest is the body of UpdateTask. Nil fields are left unchanged.
+type UpdateTaskRequest struct {
+	Title       *string  `json:"title,omitempty"`
+	Description *string  `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Priority    *string  `json:"priority,omitempty"`
+}
@@ -1,21 +1,978 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
+// DefaultConfigFile is read on startup when CONFIG_FILE is not set and the file exists
+const DefaultConfigFile = "config.yaml"
+
 type Config struct {
-	Port      string
-	AmpBinary string
-	LogDir    string
+	Port      string `yaml:"port"`
+	AmpBinary string `yaml:"amp_binary"`
+	LogDir    string `yaml:"log_dir"`
+	LogFormat string `yaml:"log_format"` // "text" (default) or "json"
+
+	MaxLogLineSize int `yaml:"max_log_line_size"` // Bytes kept per worker log line; longer lines are truncated
+
+	AmpOverrides AmpOverridesConfig          `yaml:"amp_overrides"` // amp binaries and flags tasks may choose
+	AmpProfiles  map[string]AmpProfileConfig `yaml:"amp_profiles"`  // Named amp credentials and endpoints tasks may select
+	AmpModels    map[string][]string         `yaml:"amp_models"`    // amp flags selecting each model tasks may choose
+	Backends     map[string]BackendConfig    `yaml:"backends"`      // Coding agents other than amp tasks may select
+	Pools        map[string]PoolConfig       `yaml:"pools"`         // Concurrency limits and settings tasks may select by name
+
+	Auth        AuthConfig        `yaml:"auth"`
+	Git         GitConfig         `yaml:"git"`
+	Concurrency ConcurrencyConfig `yaml:"concurrency"`
+	Webhooks    []WebhookConfig   `yaml:"webhooks"`
+	Email       EmailConfig       `yaml:"email"`
+	Routes      []RouteConfig     `yaml:"webhook_routes"`
+	Plugins     []PluginConfig    `yaml:"plugins"`
+	Stall       StallConfig       `yaml:"stall"`
+	Janitor     JanitorConfig     `yaml:"janitor"`
+	Sampling    SamplingConfig    `yaml:"sampling"`
+	DiskQuota   DiskQuotaConfig   `yaml:"disk_quota"`
+	Cleanup     CleanupConfig     `yaml:"cleanup"`
+	Hooks       HooksConfig       `yaml:"hooks"`
+	Execution   ExecutionConfig   `yaml:"execution"`
+	Agents      AgentsConfig      `yaml:"agents"`
+	Issues      IssuesConfig      `yaml:"issues"`
+	TLS         TLSConfig         `yaml:"tls"`
+	CORS        CORSConfig        `yaml:"cors"`
+	Replay      ReplayConfig      `yaml:"replay"`
+	History     HistoryConfig     `yaml:"history"`
+	Audit       AuditConfig       `yaml:"audit"`
+	WebSocket   WebSocketConfig   `yaml:"websocket"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit"`
+	ThreadID    ThreadIDConfig    `yaml:"thread_id"`
+	Storage     StorageConfig     `yaml:"storage"`
+	Secrets     SecretsConfig     `yaml:"secrets"`
+}
+
+// AmpOverridesConfig lists the amp binaries and flags tasks may choose when
+// they're started
+type AmpOverridesConfig struct {
+	Binaries []string `yaml:"binaries"` // Paths of amp executables, e.g. /opt/amp-beta/bin/amp
+	Flags    []string `yaml:"flags"`    // Flags such as --mcp-config; values are given as --flag=value
+}
+
+// AmpProfileConfig holds the environment a task's amp invocations run with
+// when the task selects the profile
+type AmpProfileConfig struct {
+	APIKey string            `yaml:"api_key"` // Sets AMP_API_KEY
+	URL    string            `yaml:"url"`     // Sets AMP_URL, the amp server to use
+	Env    map[string]string `yaml:"env"`     // Further variables; api_key and url take precedence
+}
+
+// Environment returns the variables the profile sets
+func (p AmpProfileConfig) Environment() map[string]string {
+	env := make(map[string]string, len(p.Env)+2)
+	for name, value := range p.Env {
+		env[name] = value
+	}
+	if p.APIKey != "" {
+		env["AMP_API_KEY"] = p.APIKey
+	}
+	if p.URL != "" {
+		env["AMP_URL"] = p.URL
+	}
+	return env
+}
+
+// BackendConfig describes how to run a coding agent other than amp. The
+// message is sent on stdin, as it is to amp.
+type BackendConfig struct {
+	Binary    string   `yaml:"binary"`
+	NewThread []string `yaml:"new_thread"` // Arguments that print a new thread's ID; empty for agents without threads
+	Continue  []string `yaml:"continue"`   // Arguments that send the message to a thread; "{thread}" is replaced by its ID
+
+	Models map[string][]string `yaml:"models"` // Arguments selecting each model tasks may choose
+}
+
+// AuthConfig holds API authentication settings
+type AuthConfig struct {
+	Tokens []TokenConfig `yaml:"tokens"`
+}
+
+// TokenConfig maps a bearer token to the identity it authenticates
+type TokenConfig struct {
+	Token string `yaml:"token"`
+	User  string `yaml:"user"`
+	Role  string `yaml:"role"` // "user" (default) or "admin"
+}
+
+// Enabled reports whether any API tokens are configured
+func (a AuthConfig) Enabled() bool {
+	return len(a.Tokens) > 0
+}
+
+// GitConfig holds settings for git operations on task workspaces
+type GitConfig struct {
+	RepoDir    string `yaml:"repo_dir"`
+	BaseBranch string `yaml:"base_branch"`
+	Remote     string `yaml:"remote"`
+
+	BlockFailingMerges bool   `yaml:"block_failing_merges"` // Refuse merges while a task's pull request checks fail
+	CommitMessage      string `yaml:"commit_message"`       // Go text/template for auto-commit messages; empty uses the default
+}
+
+// ConcurrencyConfig limits how many workers may run at once
+type ConcurrencyConfig struct {
+	MaxWorkers int `yaml:"max_workers"` // 0 means unlimited
+}
+
+// PoolConfig is a worker pool: the limit and settings shared by the tasks
+// that select it. Tasks that don't select a pool join the one named "default",
+// if it is configured.
+type PoolConfig struct {
+	MaxWorkers int               `yaml:"max_workers"` // Tasks of the pool running at once; 0 means unlimited
+	Dir        string            `yaml:"dir"`         // Directory amp runs in on the host, instead of the project's
+	Profile    string            `yaml:"profile"`     // Name from amp_profiles used by tasks that don't select one
+	Env        map[string]string `yaml:"env"`         // Variables added to amp's environment; the task's own take precedence
+}
+
+// StallConfig controls detection of workers that stop producing output
+type StallConfig struct {
+	Threshold     time.Duration `yaml:"threshold"`      // 0 disables stall detection
+	CheckInterval time.Duration `yaml:"check_interval"` // Defaults to 30s
+	AutoNudge     bool          `yaml:"auto_nudge"`
+	NudgeMessage  string        `yaml:"nudge_message"`
+	MaxNudges     int           `yaml:"max_nudges"`
+}
+
+// JanitorConfig controls the collection of workers whose process vanished, of
+// workers that finished long ago and of deleted workers
+type JanitorConfig struct {
+	CheckInterval  time.Duration `yaml:"check_interval"`  // Defaults to 1m
+	MaxAge         time.Duration `yaml:"max_age"`         // Finished tasks are deleted this long after they stop; 0 keeps them
+	TrashRetention time.Duration `yaml:"trash_retention"` // Deleted tasks can be restored for this long before they're purged; 0 deletes them immediately
+}
+
+// SamplingConfig controls the CPU and memory sampling of running workers
+// served by GET /api/tasks/{id}/stats
+type SamplingConfig struct {
+	Interval time.Duration `yaml:"interval"` // Defaults to 5s; 0 disables sampling
+	Samples  int           `yaml:"samples"`  // Samples kept per task
+}
+
+// DiskQuotaConfig limits the disk space the log directory may use. Over the
+// quota, new tasks are refused and the janitor and offloader run at once.
+type DiskQuotaConfig struct {
+	LogDirBytes int64 `yaml:"log_dir_bytes"` // 0 means unlimited
+}
+
+// CleanupConfig lists shell commands run in git.repo_dir after a worker's
+// process exits, e.g. to stop services it started or remove temporary credentials
+type CleanupConfig struct {
+	Commands []string      `yaml:"commands"`
+	Timeout  time.Duration `yaml:"timeout"` // Per command
+}
+
+// HooksConfig lists shell commands run in git.repo_dir at points in a task's
+// lifecycle, with the task's details in environment variables. Their output is
+// added to the task's thread.
+type HooksConfig struct {
+	PreStart     []string      `yaml:"pre_start"`     // Before a task is created; a failing command refuses it
+	PostComplete []string      `yaml:"post_complete"` // After a task completes
+	PostFail     []string      `yaml:"post_fail"`     // After a task fails
+	Timeout      time.Duration `yaml:"timeout"`       // Per command
+}
+
+// ExecutionConfig selects where workers' amp processes run. Tasks may
+// override the mode when they are created.
+type ExecutionConfig struct {
+	Mode      string          `yaml:"mode"` // "host" (default), "container" or "remote"
+	Container ContainerConfig `yaml:"container"`
+}
+
+// ContainerConfig describes the container each amp invocation runs in under
+// container execution. log_dir is always mounted at the same path.
+type ContainerConfig struct {
+	Runtime   string   `yaml:"runtime"`    // "docker" (default) or another docker-compatible CLI such as podman
+	Image     string   `yaml:"image"`      // Must provide bash and amp
+	AmpBinary string   `yaml:"amp_binary"` // amp inside the image
+	Mounts    []string `yaml:"mounts"`     // "host:container[:ro]" bind mounts with absolute paths
+	Network   string   `yaml:"network"`    // e.g. "none"; empty uses the runtime's default
+	Env       []string `yaml:"env"`        // Names of variables passed through, e.g. AMP_API_KEY
+	Workdir   string   `yaml:"workdir"`
+}
+
+// AgentsConfig controls the remote agents that connect to /api/agents/ws and
+// run tasks started with remote execution
+type AgentsConfig struct {
+	Token string `yaml:"token"` // Shared secret agents present; empty disables remote agents
+}
+
+// Enabled reports whether remote agents may connect
+func (a AgentsConfig) Enabled() bool {
+	return a.Token != ""
+}
+
+// IssuesConfig maps the priority and labels of the GitHub or Jira issue a task
+// was created from onto the task, authenticates the webhooks that keep them in
+// sync, and optionally reports back on GitHub issues when their task finishes
+type IssuesConfig struct {
+	GitHubSecret string            `yaml:"github_secret"` // Verifies X-Hub-Signature-256 on /api/integrations/github
+	JiraSecret   string            `yaml:"jira_secret"`   // Required as ?secret= on /api/integrations/jira
+	Priorities   map[string]string `yaml:"priorities"`    // Issue priority or label -> task priority
+	Tags         map[string]string `yaml:"tags"`          // Issue label -> task tag
+	CopyLabels   bool              `yaml:"copy_labels"`   // Add unmapped labels as tags unchanged
+
+	GitHubRepo      string `yaml:"github_repo"`       // "owner/repo" of issues referenced by number alone
+	GitHubToken     string `yaml:"github_token"`      // Posts comments on linked issues
+	GitHubAPI       string `yaml:"github_api"`        // Defaults to https://api.github.com; set for GitHub Enterprise
+	CommentOnFinish bool   `yaml:"comment_on_finish"` // Comment on a task's GitHub issue when its process exits
+}
+
+// RateLimitConfig limits how often the amp CLI is invoked across all workers
+type RateLimitConfig struct {
+	ThreadsPerMinute   int           `yaml:"threads_per_minute"`   // 0 means unlimited
+	ContinuesPerMinute int           `yaml:"continues_per_minute"` // 0 means unlimited
+	MaxWait            time.Duration `yaml:"max_wait"`             // Longest an invocation is delayed before failing
+}
+
+// Enabled reports whether any rate limit is configured
+func (r RateLimitConfig) Enabled() bool {
+	return r.ThreadsPerMinute > 0 || r.ContinuesPerMinute > 0
+}
+
+// ThreadIDConfig controls validation of the thread IDs returned by
+// `amp threads new`, so a change in amp's ID scheme doesn't break task creation
+type ThreadIDConfig struct {
+	Pattern       string `yaml:"pattern"`        // Regular expression IDs must match; empty accepts any ID
+	AcceptUnknown bool   `yaml:"accept_unknown"` // Log and accept IDs that don't match the pattern
+}
+
+// ReplayConfig controls the buffer of recent WebSocket events that
+// reconnecting clients can resume from
+type ReplayConfig struct {
+	Size      int           `yaml:"size"`      // Events kept; 0 disables replay
+	Retention time.Duration `yaml:"retention"` // Maximum age of replayed events; 0 means no limit
+	Persist   bool          `yaml:"persist"`   // Journal events to <log_dir>/events.jsonl so they survive restarts
+}
+
+// HistoryConfig controls the append-only journal of broadcast events served
+// by GET /api/events
+type HistoryConfig struct {
+	Enabled   bool          `yaml:"enabled"`   // Journal events to <log_dir>/history.jsonl; requires replay
+	Retention time.Duration `yaml:"retention"` // Events older than this are pruned on startup; 0 keeps everything
+}
+
+// AuditConfig controls the audit log of mutating API calls served by
+// GET /api/audit
+type AuditConfig struct {
+	Enabled bool `yaml:"enabled"` // Record calls in <log_dir>/audit.jsonl
+}
+
+// StorageConfig controls offloading the logs and threads of finished tasks
+// to an S3 or GCS bucket. Offloaded files are still served by the API.
+type StorageConfig struct {
+	Backend         string        `yaml:"backend"` // "s3", "gcs" or empty to keep everything local
+	Bucket          string        `yaml:"bucket"`
+	Prefix          string        `yaml:"prefix"`        // Prepended to object keys
+	Region          string        `yaml:"region"`        // Defaults to us-east-1 on S3
+	Endpoint        string        `yaml:"endpoint"`      // For S3-compatible services such as MinIO
+	AccessKeyID     string        `yaml:"access_key_id"` // An HMAC key on GCS
+	SecretAccessKey string        `yaml:"secret_access_key"`
+	OffloadAfter    time.Duration `yaml:"offload_after"` // Time since a task's files last changed before they're offloaded
+	CheckInterval   time.Duration `yaml:"check_interval"`
+}
+
+// Enabled reports whether a storage backend is configured
+func (s StorageConfig) Enabled() bool {
+	return s.Backend != ""
+}
+
+// SecretsConfig controls the encrypted store of secrets tasks can reference
+type SecretsConfig struct {
+	MasterKey string `yaml:"master_key"` // Encrypts stored secrets; empty disables secrets
+	File      string `yaml:"file"`       // Defaults to secrets.json in log_dir
+}
+
+// Enabled reports whether a master key is configured
+func (s SecretsConfig) Enabled() bool {
+	return s.MasterKey != ""
+}
+
+// WebSocketConfig controls delivery to WebSocket clients and the limits of
+// their connections
+type WebSocketConfig struct {
+	SlowClientPolicy string        `yaml:"slow_client_policy"` // "disconnect", "drop-message" or "drop-oldest"
+	WriteWait        time.Duration `yaml:"write_wait"`         // Time allowed to write a message to a client
+	PongWait         time.Duration `yaml:"pong_wait"`          // Clients not answering pings within this are disconnected
+	PingPeriod       time.Duration `yaml:"ping_period"`        // How often clients are pinged; must be less than pong_wait
+	MaxMessageSize   int64         `yaml:"max_message_size"`   // Largest message accepted from a client, in bytes
+}
+
+// TLSConfig enables serving the API over HTTPS, either with a certificate
+// and key from disk or with certificates obtained automatically from Let's Encrypt
+type TLSConfig struct {
+	CertFile     string         `yaml:"cert_file"`
+	KeyFile      string         `yaml:"key_file"`
+	Autocert     AutocertConfig `yaml:"autocert"`
+	RedirectPort string         `yaml:"redirect_port"` // Plain HTTP port redirected to HTTPS; empty disables
+}
+
+// AutocertConfig obtains certificates via ACME for the listed domains
+type AutocertConfig struct {
+	Domains  []string `yaml:"domains"`
+	CacheDir string   `yaml:"cache_dir"` // Defaults to <log_dir>/autocert
+	Email    string   `yaml:"email"`
+}
+
+// Enabled reports whether the API should be served over TLS
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != "" || t.UseAutocert()
+}
+
+// UseAutocert reports whether certificates are obtained automatically
+func (t TLSConfig) UseAutocert() bool {
+	return len(t.Autocert.Domains) > 0
+}
+
+// CORSConfig controls which browser origins may call the API and open
+// WebSocket connections. With no allowed origins, only same-origin requests work.
+type CORSConfig struct {
+	AllowedOrigins   []string `yaml:"allowed_origins"` // "*" allows any origin; "https://*.example.com" allows subdomains
+	AllowedMethods   []string `yaml:"allowed_methods"`
+	AllowedHeaders   []string `yaml:"allowed_headers"`
+	AllowCredentials bool     `yaml:"allow_credentials"`
+	MaxAge           int      `yaml:"max_age"` // Seconds browsers may cache preflight results
+}
+
+// AllowsOrigin reports whether the origin is in the allow-list
+func (c CORSConfig) AllowsOrigin(origin string) bool {
+	origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+	if origin == "" {
+		return false
+	}
+
+	for _, allowed := range c.AllowedOrigins {
+		allowed = strings.ToLower(strings.TrimSuffix(allowed, "/"))
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+
+		// "scheme://*.domain" matches any subdomain of domain
+		if scheme, domain, ok := strings.Cut(allowed, "://*."); ok {
+			if strings.HasPrefix(origin, scheme+"://") && strings.HasSuffix(origin, "."+domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AllowsAnyOrigin reports whether the allow-list contains the "*" wildcard
+func (c CORSConfig) AllowsAnyOrigin() bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookConfig registers an HTTP endpoint that receives task events
+type WebhookConfig struct {
+	Name        string            `yaml:"name"`
+	URL         string            `yaml:"url"`
+	Events      []string          `yaml:"events"` // Empty means all events
+	Secret      string            `yaml:"secret"`
+	Template    string            `yaml:"template"`     // Go text/template for the payload; defaults to the JSON event
+	ContentType string            `yaml:"content_type"` // Defaults to application/json
+	Headers     map[string]string `yaml:"headers"`
+}
+
+// PluginConfig runs a program that receives the daemon's events as JSON
+// lines on its standard input
+type PluginConfig struct {
+	Name    string            `yaml:"name"`
+	Command string            `yaml:"command"` // Run with bash -c
+	Events  []string          `yaml:"events"`  // Empty means all events
+	Env     map[string]string `yaml:"env"`
+}
+
+// EmailConfig sends notifications of task events over SMTP. Recipients in To
+// receive every task's notifications; Projects adds recipients for the tasks
+// of specific projects.
+type EmailConfig struct {
+	Host     string              `yaml:"host"` // SMTP server; empty disables email
+	Port     int                 `yaml:"port"` // Defaults to 587; STARTTLS is used when the server offers it
+	Username string              `yaml:"username"`
+	Password string              `yaml:"password"`
+	From     string              `yaml:"from"`
+	To       []string            `yaml:"to"`
+	Projects map[string][]string `yaml:"projects"` // Project name -> recipients
+	Events   []string            `yaml:"events"`   // Defaults to task-finished
+	Subject  string              `yaml:"subject"`  // Go text/template; empty uses the default
+	Body     string              `yaml:"body"`     // Go text/template; empty uses the default
+}
+
+// Enabled reports whether email notifications are configured
+func (e EmailConfig) Enabled() bool {
+	return e.Host != ""
+}
+
+// RouteConfig directs events matching all of its criteria to specific
+// webhooks. A criterion matches when the event has any of its values; empty
+// criteria match everything. Webhooks named by a route only receive events
+// routed to them.
+type RouteConfig struct {
+	Name       string   `yaml:"name"`
+	Events     []string `yaml:"events"`
+	Projects   []string `yaml:"projects"` // Matches tasks in, or tagged project:<name>
+	Owners     []string `yaml:"owners"`   // Matches tasks tagged owner:<name>
+	Tags       []string `yaml:"tags"`
+	Priorities []string `yaml:"priorities"`
+	Statuses   []string `yaml:"statuses"`
+	Webhooks   []string `yaml:"webhooks"`
+	Final      bool     `yaml:"final"` // Skip later routes when this one matches
 }
 
 func Load() *Config {
+	cfg := defaults()
+	// Load stays lenient for callers that don't validate; LoadFile reports bad values
+	cfg.applyEnv()
+	return cfg
+}
+
+// LoadFile builds the configuration from defaults, the YAML file at path and
+// environment variable overrides, in increasing order of precedence. An empty
+// path skips the file. The result is validated before it is returned.
+func LoadFile(path string) (*Config, error) {
+	cfg := defaults()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+
+		// Reject unknown keys so typos don't silently fall back to defaults
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// LoadDefault loads the file named by CONFIG_FILE, falling back to
+// DefaultConfigFile when it exists in the working directory
+func LoadDefault() (*Config, error) {
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		if _, err := os.Stat(DefaultConfigFile); err == nil {
+			path = DefaultConfigFile
+		}
+	}
+	return LoadFile(path)
+}
+
+// Validate checks the configuration for values that would prevent the daemon
+// from starting, reporting every problem found
+func (c *Config) Validate() error {
+	var errs []error
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("port must be a number between 1 and 65535, got %q", c.Port))
+	}
+	if c.AmpBinary == "" {
+		errs = append(errs, errors.New("amp_binary must not be empty"))
+	}
+	for _, binary := range c.AmpOverrides.Binaries {
+		if binary == "" {
+			errs = append(errs, errors.New("amp_overrides.binaries must not contain empty paths"))
+		}
+	}
+	for name, profile := range c.AmpProfiles {
+		if name == "" {
+			errs = append(errs, errors.New("amp_profiles must not have an empty name"))
+		}
+		if len(profile.Environment()) == 0 {
+			errs = append(errs, fmt.Errorf("amp_profiles.%s must set api_key, url or env", name))
+		}
+		for variable := range profile.Env {
+			if variable == "" || strings.ContainsAny(variable, "= ") {
+				errs = append(errs, fmt.Errorf("amp_profiles.%s.env has an invalid variable name %q", name, variable))
+			}
+		}
+		if profile.URL != "" {
+			if u, err := url.Parse(profile.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs = append(errs, fmt.Errorf("amp_profiles.%s.url must be an http or https URL, got %q", name, profile.URL))
+			}
+		}
+	}
+	for name, backend := range c.Backends {
+		if name == "" || name == "amp" {
+			errs = append(errs, fmt.Errorf("backends must not be named %q", name))
+		}
+		if backend.Binary == "" {
+			errs = append(errs, fmt.Errorf("backends.%s.binary must not be empty", name))
+		}
+		if len(backend.Continue) == 0 {
+			errs = append(errs, fmt.Errorf("backends.%s.continue must not be empty", name))
+		}
+		for model := range backend.Models {
+			if model == "" {
+				errs = append(errs, fmt.Errorf("backends.%s.models must not have an empty name", name))
+			}
+		}
+	}
+	for model, flags := range c.AmpModels {
+		if model == "" {
+			errs = append(errs, errors.New("amp_models must not have an empty name"))
+		}
+		if len(flags) == 0 {
+			errs = append(errs, fmt.Errorf("amp_models.%s must list the amp flags that select it", model))
+		}
+	}
+	for _, flag := range c.AmpOverrides.Flags {
+		if !strings.HasPrefix(flag, "--") || strings.Contains(flag, "=") {
+			errs = append(errs, fmt.Errorf("amp_overrides.flags must be flag names like --mcp-config, got %q", flag))
+		}
+	}
+	if c.LogDir == "" {
+		errs = append(errs, errors.New("log_dir must not be empty"))
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		errs = append(errs, fmt.Errorf("log_format must be \"text\" or \"json\", got %q", c.LogFormat))
+	}
+	if c.MaxLogLineSize <= 0 {
+		errs = append(errs, errors.New("max_log_line_size must be positive"))
+	}
+
+	seenTokens := make(map[string]bool)
+	for i, token := range c.Auth.Tokens {
+		if token.Token == "" {
+			errs = append(errs, fmt.Errorf("auth.tokens[%d]: token must not be empty", i))
+		} else if seenTokens[token.Token] {
+			errs = append(errs, fmt.Errorf("auth.tokens[%d]: duplicate token", i))
+		}
+		seenTokens[token.Token] = true
+
+		if token.User == "" {
+			errs = append(errs, fmt.Errorf("auth.tokens[%d]: user must not be empty", i))
+		}
+		if token.Role != "" && token.Role != "user" && token.Role != "admin" {
+			errs = append(errs, fmt.Errorf("auth.tokens[%d]: invalid role %q", i, token.Role))
+		}
+	}
+
+	if c.Concurrency.MaxWorkers < 0 {
+		errs = append(errs, errors.New("concurrency.max_workers must not be negative"))
+	}
+	for name, pool := range c.Pools {
+		if name == "" {
+			errs = append(errs, errors.New("pools must not have an empty name"))
+		}
+		if pool.MaxWorkers < 0 {
+			errs = append(errs, fmt.Errorf("pools.%s.max_workers must not be negative", name))
+		}
+		if _, ok := c.AmpProfiles[pool.Profile]; pool.Profile != "" && !ok {
+			errs = append(errs, fmt.Errorf("pools.%s.profile: unknown amp profile %q", name, pool.Profile))
+		}
+		for variable := range pool.Env {
+			if variable == "" || strings.ContainsAny(variable, "= ") {
+				errs = append(errs, fmt.Errorf("pools.%s.env has an invalid variable name %q", name, variable))
+			}
+		}
+	}
+
+	if c.Stall.Threshold < 0 || c.Stall.CheckInterval < 0 {
+		errs = append(errs, errors.New("stall durations must not be negative"))
+	}
+	if c.Stall.MaxNudges < 0 {
+		errs = append(errs, errors.New("stall.max_nudges must not be negative"))
+	}
+	if c.Janitor.CheckInterval <= 0 || c.Janitor.MaxAge < 0 {
+		errs = append(errs, errors.New("janitor.check_interval must be positive and janitor.max_age must not be negative"))
+	}
+	if c.Janitor.TrashRetention < 0 {
+		errs = append(errs, errors.New("janitor.trash_retention must not be negative"))
+	}
+	if c.DiskQuota.LogDirBytes < 0 {
+		errs = append(errs, errors.New("disk_quota.log_dir_bytes must not be negative"))
+	}
+	if c.Sampling.Interval < 0 || c.Sampling.Samples <= 0 {
+		errs = append(errs, errors.New("sampling.interval must not be negative and sampling.samples must be positive"))
+	}
+
+	if c.Cleanup.Timeout <= 0 {
+		errs = append(errs, errors.New("cleanup.timeout must be positive"))
+	}
+	for i, command := range c.Cleanup.Commands {
+		if strings.TrimSpace(command) == "" {
+			errs = append(errs, fmt.Errorf("cleanup.commands[%d] must not be empty", i))
+		}
+	}
+	if c.Hooks.Timeout <= 0 {
+		errs = append(errs, errors.New("hooks.timeout must be positive"))
+	}
+	hooks := []struct {
+		name     string
+		commands []string
+	}{
+		{"pre_start", c.Hooks.PreStart},
+		{"post_complete", c.Hooks.PostComplete},
+		{"post_fail", c.Hooks.PostFail},
+	}
+	for _, hook := range hooks {
+		for i, command := range hook.commands {
+			if strings.TrimSpace(command) == "" {
+				errs = append(errs, fmt.Errorf("hooks.%s[%d] must not be empty", hook.name, i))
+			}
+		}
+	}
+
+	switch c.Execution.Mode {
+	case "host":
+	case "container":
+		if c.Execution.Container.Image == "" {
+			errs = append(errs, errors.New("execution.container.image is required for container mode"))
+		}
+	case "remote":
+		if !c.Agents.Enabled() {
+			errs = append(errs, errors.New("agents.token is required for remote mode"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("execution.mode must be host, container or remote, got %q", c.Execution.Mode))
+	}
+	for i, mount := range c.Execution.Container.Mounts {
+		if err := validateMount(mount); err != nil {
+			errs = append(errs, fmt.Errorf("execution.container.mounts[%d]: %w", i, err))
+		}
+	}
+
+	for label, priority := range c.Issues.Priorities {
+		if strings.TrimSpace(label) == "" || strings.TrimSpace(priority) == "" {
+			errs = append(errs, errors.New("issues.priorities must not have empty keys or values"))
+			break
+		}
+	}
+	for label, tag := range c.Issues.Tags {
+		if strings.TrimSpace(label) == "" || strings.TrimSpace(tag) == "" {
+			errs = append(errs, errors.New("issues.tags must not have empty keys or values"))
+			break
+		}
+	}
+
+	if c.Issues.GitHubRepo != "" {
+		if owner, name, ok := strings.Cut(c.Issues.GitHubRepo, "/"); !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+			errs = append(errs, fmt.Errorf("issues.github_repo must be owner/repo, got %q", c.Issues.GitHubRepo))
+		}
+	}
+	if c.Issues.CommentOnFinish && c.Issues.GitHubToken == "" {
+		errs = append(errs, errors.New("issues.comment_on_finish requires issues.github_token"))
+	}
+
+	if c.RateLimit.ThreadsPerMinute < 0 || c.RateLimit.ContinuesPerMinute < 0 || c.RateLimit.MaxWait < 0 {
+		errs = append(errs, errors.New("rate_limit values must not be negative"))
+	}
+
+	if _, err := template.New("commit_message").Parse(c.Git.CommitMessage); err != nil {
+		errs = append(errs, fmt.Errorf("git.commit_message: %w", err))
+	}
+
+	switch c.Storage.Backend {
+	case "":
+	case "s3", "gcs":
+		if c.Storage.Bucket == "" {
+			errs = append(errs, errors.New("storage.bucket is required"))
+		}
+		if c.Storage.AccessKeyID == "" || c.Storage.SecretAccessKey == "" {
+			errs = append(errs, errors.New("storage.access_key_id and storage.secret_access_key are required"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("storage.backend must be s3 or gcs, got %q", c.Storage.Backend))
+	}
+	if c.Storage.OffloadAfter < 0 || c.Storage.CheckInterval <= 0 {
+		errs = append(errs, errors.New("storage.offload_after must not be negative and storage.check_interval must be positive"))
+	}
+
+	if c.Secrets.Enabled() && len(c.Secrets.MasterKey) < 16 {
+		errs = append(errs, errors.New("secrets.master_key must be at least 16 characters"))
+	}
+	if c.Secrets.File != "" && !c.Secrets.Enabled() {
+		errs = append(errs, errors.New("secrets.file requires secrets.master_key"))
+	}
+
+	if _, err := regexp.Compile(c.ThreadID.Pattern); err != nil {
+		errs = append(errs, fmt.Errorf("thread_id.pattern: %w", err))
+	}
+
+	switch c.WebSocket.SlowClientPolicy {
+	case "disconnect", "drop-message", "drop-oldest":
+	default:
+		errs = append(errs, fmt.Errorf("websocket.slow_client_policy must be \"disconnect\", \"drop-message\" or \"drop-oldest\", got %q", c.WebSocket.SlowClientPolicy))
+	}
+	if c.WebSocket.WriteWait <= 0 || c.WebSocket.PongWait <= 0 || c.WebSocket.PingPeriod <= 0 || c.WebSocket.MaxMessageSize <= 0 {
+		errs = append(errs, errors.New("websocket.write_wait, websocket.pong_wait, websocket.ping_period and websocket.max_message_size must be positive"))
+	} else if c.WebSocket.PingPeriod >= c.WebSocket.PongWait {
+		errs = append(errs, errors.New("websocket.ping_period must be less than websocket.pong_wait"))
+	}
+	if c.Replay.Size < 0 || c.Replay.Retention < 0 {
+		errs = append(errs, errors.New("replay.size and replay.retention must not be negative"))
+	}
+	if c.History.Retention < 0 {
+		errs = append(errs, errors.New("history.retention must not be negative"))
+	}
+	if c.History.Enabled && c.Replay.Size == 0 {
+		errs = append(errs, errors.New("history.enabled requires replay to be enabled"))
+	}
+
+	if c.TLS.UseAutocert() && (c.TLS.CertFile != "" || c.TLS.KeyFile != "") {
+		errs = append(errs, errors.New("tls: cert_file/key_file and autocert are mutually exclusive"))
+	} else if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("tls: cert_file and key_file must be set together"))
+	}
+	if c.TLS.RedirectPort != "" {
+		if !c.TLS.Enabled() {
+			errs = append(errs, errors.New("tls.redirect_port requires TLS to be enabled"))
+		} else if port, err := strconv.Atoi(c.TLS.RedirectPort); err != nil || port < 1 || port > 65535 {
+			errs = append(errs, fmt.Errorf("tls.redirect_port must be a number between 1 and 65535, got %q", c.TLS.RedirectPort))
+		} else if c.TLS.RedirectPort == c.Port {
+			errs = append(errs, errors.New("tls.redirect_port must differ from port"))
+		}
+	}
+
+	if c.CORS.AllowCredentials && c.CORS.AllowsAnyOrigin() {
+		errs = append(errs, errors.New("cors: allow_credentials cannot be combined with the \"*\" origin"))
+	}
+	if c.CORS.MaxAge < 0 {
+		errs = append(errs, errors.New("cors.max_age must not be negative"))
+	}
+
+	seenWebhooks := make(map[string]bool)
+	for i, webhook := range c.Webhooks {
+		if webhook.Name == "" {
+			errs = append(errs, fmt.Errorf("webhooks[%d]: name must not be empty", i))
+		} else if seenWebhooks[webhook.Name] {
+			errs = append(errs, fmt.Errorf("webhooks[%d]: duplicate name %q", i, webhook.Name))
+		}
+		seenWebhooks[webhook.Name] = true
+
+		if u, err := url.Parse(webhook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("webhooks[%d]: invalid url %q", i, webhook.URL))
+		}
+	}
+
+	if c.Email.Enabled() {
+		if c.Email.Port < 1 || c.Email.Port > 65535 {
+			errs = append(errs, fmt.Errorf("email.port must be between 1 and 65535, got %d", c.Email.Port))
+		}
+		if _, err := mail.ParseAddress(c.Email.From); err != nil {
+			errs = append(errs, fmt.Errorf("email.from: invalid address %q", c.Email.From))
+		}
+		recipients := append(append([]string{}, c.Email.To...), flattenRecipients(c.Email.Projects)...)
+		if len(recipients) == 0 {
+			errs = append(errs, errors.New("email requires recipients in email.to or email.projects"))
+		}
+		for _, to := range recipients {
+			if _, err := mail.ParseAddress(to); err != nil {
+				errs = append(errs, fmt.Errorf("email: invalid recipient %q", to))
+			}
+		}
+	}
+
+	seenRoutes := make(map[string]bool)
+	for i, route := range c.Routes {
+		if route.Name == "" {
+			errs = append(errs, fmt.Errorf("webhook_routes[%d]: name must not be empty", i))
+		} else if seenRoutes[route.Name] {
+			errs = append(errs, fmt.Errorf("webhook_routes[%d]: duplicate name %q", i, route.Name))
+		}
+		seenRoutes[route.Name] = true
+
+		if len(route.Webhooks) == 0 {
+			errs = append(errs, fmt.Errorf("webhook_routes[%d]: webhooks must not be empty", i))
+		}
+		for _, name := range route.Webhooks {
+			if !seenWebhooks[name] {
+				errs = append(errs, fmt.Errorf("webhook_routes[%d]: unknown webhook %q", i, name))
+			}
+		}
+	}
+
+	seenPlugins := make(map[string]bool)
+	for i, plugin := range c.Plugins {
+		if plugin.Name == "" {
+			errs = append(errs, fmt.Errorf("plugins[%d]: name must not be empty", i))
+		} else if seenPlugins[plugin.Name] {
+			errs = append(errs, fmt.Errorf("plugins[%d]: duplicate name %q", i, plugin.Name))
+		}
+		seenPlugins[plugin.Name] = true
+
+		if strings.TrimSpace(plugin.Command) == "" {
+			errs = append(errs, fmt.Errorf("plugins[%d]: command must not be empty", i))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func defaults() *Config {
 	return &Config{
-		Port:      getEnv("PORT", "8080"),
-		AmpBinary: getEnv("AMP_BINARY", "amp"),
-		LogDir:    getEnv("LOG_DIR", "./logs"),
+		Port:      "8080",
+		AmpBinary: "amp",
+		LogDir:    "./logs",
+		LogFormat: "text",
+
+		MaxLogLineSize: 1024 * 1024,
+
+		Git: GitConfig{
+			RepoDir:    ".",
+			BaseBranch: "main",
+			Remote:     "origin",
+		},
+		CORS: CORSConfig{
+			AllowedMethods: []string{"GET", "POST", "PATCH", "DELETE", "OPTIONS"},
+			AllowedHeaders: []string{"Content-Type", "Authorization", "If-None-Match"},
+			MaxAge:         600,
+		},
+		Replay: ReplayConfig{
+			Size:      1000,
+			Retention: 10 * time.Minute,
+			Persist:   true,
+		},
+		History: HistoryConfig{
+			Enabled:   true,
+			Retention: 7 * 24 * time.Hour,
+		},
+		Audit: AuditConfig{
+			Enabled: true,
+		},
+		Cleanup: CleanupConfig{
+			Timeout: 2 * time.Minute,
+		},
+		Hooks: HooksConfig{
+			Timeout: time.Minute,
+		},
+		Janitor: JanitorConfig{
+			CheckInterval: time.Minute,
+		},
+		Sampling: SamplingConfig{
+			Interval: 5 * time.Second,
+			Samples:  60,
+		},
+		Execution: ExecutionConfig{
+			Mode: "host",
+			Container: ContainerConfig{
+				Runtime:   "docker",
+				AmpBinary: "amp",
+				Env:       []string{"AMP_API_KEY"},
+			},
+		},
+		WebSocket: WebSocketConfig{
+			SlowClientPolicy: "disconnect",
+			WriteWait:        10 * time.Second,
+			PongWait:         60 * time.Second,
+			PingPeriod:       54 * time.Second,
+			MaxMessageSize:   64 << 10,
+		},
+		RateLimit: RateLimitConfig{
+			MaxWait: 2 * time.Minute,
+		},
+		ThreadID: ThreadIDConfig{
+			Pattern: "^T-",
+		},
+		Email: EmailConfig{
+			Port: 587,
+		},
+		Storage: StorageConfig{
+			OffloadAfter:  24 * time.Hour,
+			CheckInterval: time.Hour,
+		},
+	}
+}
+
+// flattenRecipients returns every recipient of every project
+func flattenRecipients(projects map[string][]string) []string {
+	var recipients []string
+	for _, to := range projects {
+		recipients = append(recipients, to...)
+	}
+	return recipients
+}
+
+// validateMount checks a "host:container[:ro|rw]" bind mount
+func validateMount(mount string) error {
+	parts := strings.Split(mount, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return fmt.Errorf("%q must be host:container[:ro]", mount)
 	}
+	if !filepath.IsAbs(parts[0]) || !strings.HasPrefix(parts[1], "/") {
+		return fmt.Errorf("%q must use absolute paths", mount)
+	}
+	if len(parts) == 3 && parts[2] != "ro" && parts[2] != "rw" {
+		return fmt.Errorf("%q has unknown option %q", mount, parts[2])
+	}
+	return nil
+}
+
+// applyEnv overrides configuration values with any non-empty environment variables
+func (c *Config) applyEnv() error {
+	c.Port = getEnv("PORT", c.Port)
+	c.AmpBinary = getEnv("AMP_BINARY", c.AmpBinary)
+	c.LogDir = getEnv("LOG_DIR", c.LogDir)
+	c.LogFormat = getEnv("LOG_FORMAT", c.LogFormat)
+	c.Git.RepoDir = getEnv("GIT_REPO_DIR", c.Git.RepoDir)
+	c.Git.BaseBranch = getEnv("GIT_BASE_BRANCH", c.Git.BaseBranch)
+	c.TLS.CertFile = getEnv("TLS_CERT_FILE", c.TLS.CertFile)
+	c.TLS.KeyFile = getEnv("TLS_KEY_FILE", c.TLS.KeyFile)
+	c.Agents.Token = getEnv("AGENT_TOKEN", c.Agents.Token)
+	c.Email.Password = getEnv("SMTP_PASSWORD", c.Email.Password)
+	c.Issues.GitHubToken = getEnv("GITHUB_TOKEN", c.Issues.GitHubToken)
+	c.Storage.AccessKeyID = getEnv("STORAGE_ACCESS_KEY_ID", c.Storage.AccessKeyID)
+	c.Storage.SecretAccessKey = getEnv("STORAGE_SECRET_ACCESS_KEY", c.Storage.SecretAccessKey)
+	c.Secrets.MasterKey = getEnv("SECRETS_MASTER_KEY", c.Secrets.MasterKey)
+
+	if origins := os.Getenv("CORS_ALLOWED_ORIGINS"); origins != "" {
+		c.CORS.AllowedOrigins = nil
+		for _, origin := range strings.Split(origins, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				c.CORS.AllowedOrigins = append(c.CORS.AllowedOrigins, origin)
+			}
+		}
+	}
+
+	if maxWorkers := os.Getenv("MAX_WORKERS"); maxWorkers != "" {
+		n, err := strconv.Atoi(strings.TrimSpace(maxWorkers))
+		if err != nil {
+			return fmt.Errorf("invalid MAX_WORKERS value %q", maxWorkers)
+		}
+		c.Concurrency.MaxWorkers = n
+	}
+
+	return nil
 }
 
 func getEnv(key, defaultValue string) string {
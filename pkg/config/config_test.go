@@ -2,9 +2,12 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestLoad_DefaultValues(t *testing.T) {
@@ -89,6 +92,383 @@ func clearTestEnvVars() {
 	os.Unsetenv("PORT")
 	os.Unsetenv("AMP_BINARY")
 	os.Unsetenv("LOG_DIR")
+	os.Unsetenv("LOG_FORMAT")
 	os.Unsetenv("TEST_VAR")
 	os.Unsetenv("EMPTY_VAR")
+	os.Unsetenv("MAX_WORKERS")
+	os.Unsetenv("GIT_REPO_DIR")
+	os.Unsetenv("GIT_BASE_BRANCH")
+	os.Unsetenv("CONFIG_FILE")
+	os.Unsetenv("TLS_CERT_FILE")
+	os.Unsetenv("TLS_KEY_FILE")
+	os.Unsetenv("AGENT_TOKEN")
+	os.Unsetenv("CORS_ALLOWED_ORIGINS")
+	os.Unsetenv("STORAGE_ACCESS_KEY_ID")
+	os.Unsetenv("SMTP_PASSWORD")
+	os.Unsetenv("GITHUB_TOKEN")
+	os.Unsetenv("STORAGE_SECRET_ACCESS_KEY")
+	os.Unsetenv("SECRETS_MASTER_KEY")
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoadFile_YAML(t *testing.T) {
+	clearTestEnvVars()
+	defer clearTestEnvVars()
+
+	path := writeConfigFile(t, `
+port: "9000"
+log_dir: /var/log/ampd
+amp_binary: /opt/amp/bin/amp
+auth:
+  tokens:
+    - token: secret-1
+      user: alice
+      role: admin
+git:
+  base_branch: develop
+concurrency:
+  max_workers: 4
+rate_limit:
+  threads_per_minute: 10
+  max_wait: 30s
+webhooks:
+  - name: ci
+    url: https://hooks.example.com/ampd
+    events: [task-update]
+webhook_routes:
+  - name: infra-failures
+    tags: [infra]
+    statuses: [failed]
+    webhooks: [ci]
+    final: true
+`)
+
+	config, err := LoadFile(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "9000", config.Port)
+	assert.Equal(t, "/var/log/ampd", config.LogDir)
+	assert.Equal(t, "/opt/amp/bin/amp", config.AmpBinary)
+	assert.True(t, config.Auth.Enabled())
+	assert.Equal(t, "alice", config.Auth.Tokens[0].User)
+	assert.Equal(t, "develop", config.Git.BaseBranch)
+	assert.Equal(t, "origin", config.Git.Remote) // default preserved
+	assert.Equal(t, 4, config.Concurrency.MaxWorkers)
+	assert.True(t, config.RateLimit.Enabled())
+	assert.Equal(t, 10, config.RateLimit.ThreadsPerMinute)
+	assert.Equal(t, 30*time.Second, config.RateLimit.MaxWait)
+	require.Len(t, config.Webhooks, 1)
+	assert.Equal(t, []string{"task-update"}, config.Webhooks[0].Events)
+	require.Len(t, config.Routes, 1)
+	assert.Equal(t, []string{"ci"}, config.Routes[0].Webhooks)
+	assert.True(t, config.Routes[0].Final)
+}
+
+func TestLoadFile_EnvOverridesFile(t *testing.T) {
+	clearTestEnvVars()
+	defer clearTestEnvVars()
+
+	path := writeConfigFile(t, "port: \"9000\"\nlog_dir: /from/file\nconcurrency:\n  max_workers: 4\n")
+
+	os.Setenv("PORT", "9191")
+	os.Setenv("MAX_WORKERS", "8")
+
+	config, err := LoadFile(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "9191", config.Port)
+	assert.Equal(t, "/from/file", config.LogDir)
+	assert.Equal(t, 8, config.Concurrency.MaxWorkers)
+}
+
+func TestLoadFile_EmptyPathUsesDefaults(t *testing.T) {
+	clearTestEnvVars()
+	defer clearTestEnvVars()
+
+	config, err := LoadFile("")
+	require.NoError(t, err)
+	assert.Equal(t, "8080", config.Port)
+	assert.Equal(t, "main", config.Git.BaseBranch)
+	assert.Equal(t, 1000, config.Replay.Size)
+	assert.True(t, config.Replay.Persist)
+	assert.True(t, config.History.Enabled)
+	assert.Equal(t, 7*24*time.Hour, config.History.Retention)
+	assert.True(t, config.Audit.Enabled)
+	assert.Empty(t, config.Cleanup.Commands)
+	assert.Equal(t, 2*time.Minute, config.Cleanup.Timeout)
+	assert.Equal(t, time.Minute, config.Hooks.Timeout)
+	assert.Equal(t, "host", config.Execution.Mode)
+	assert.Equal(t, "docker", config.Execution.Container.Runtime)
+	assert.Equal(t, "disconnect", config.WebSocket.SlowClientPolicy)
+	assert.Equal(t, 1024*1024, config.MaxLogLineSize)
+	assert.Equal(t, "^T-", config.ThreadID.Pattern)
+	assert.False(t, config.ThreadID.AcceptUnknown)
+}
+
+func TestLoadFile_Errors(t *testing.T) {
+	clearTestEnvVars()
+	defer clearTestEnvVars()
+
+	tests := []struct {
+		name    string
+		content string
+		errMsg  string
+	}{
+		{"unknown key", "prot: \"9000\"\n", "field prot not found"},
+		{"invalid port", "port: \"abc\"\n", "port must be a number"},
+		{"invalid log format", "log_format: xml\n", "log_format"},
+		{"zero max log line size", "max_log_line_size: 0\n", "max_log_line_size"},
+		{"amp override flag with value", "amp_overrides:\n  flags: [--mcp-config=x.json]\n", "amp_overrides.flags"},
+		{"empty amp profile", "amp_profiles:\n  staging: {}\n", "amp_profiles.staging must set"},
+		{"invalid amp profile url", "amp_profiles:\n  staging:\n    url: ampcode.com\n", "amp_profiles.staging.url"},
+		{"backend named amp", "backends:\n  amp:\n    binary: /bin/amp\n    continue: [x]\n", "must not be named"},
+		{"backend without continue", "backends:\n  aider:\n    binary: /bin/aider\n", "backends.aider.continue"},
+		{"amp model without flags", "amp_models:\n  rush: []\n", "amp_models.rush"},
+		{"negative concurrency", "concurrency:\n  max_workers: -1\n", "max_workers must not be negative"},
+		{"negative pool limit", "pools:\n  gpu:\n    max_workers: -1\n", "pools.gpu.max_workers"},
+		{"pool with unknown profile", "pools:\n  gpu:\n    profile: staging\n", "unknown amp profile \"staging\""},
+		{"invalid role", "auth:\n  tokens:\n    - token: t\n      user: u\n      role: root\n", "invalid role"},
+		{"duplicate token", "auth:\n  tokens:\n    - {token: t, user: a}\n    - {token: t, user: b}\n", "duplicate token"},
+		{"invalid webhook url", "webhooks:\n  - name: hook\n    url: ftp://example.com\n", "invalid url"},
+		{"tls cert without key", "tls:\n  cert_file: server.crt\n", "must be set together"},
+		{"tls cert and autocert", "tls:\n  cert_file: a\n  key_file: b\n  autocert:\n    domains: [ampd.example.com]\n", "mutually exclusive"},
+		{"redirect without tls", "tls:\n  redirect_port: \"80\"\n", "requires TLS"},
+		{"cors credentials with wildcard", "cors:\n  allowed_origins: [\"*\"]\n  allow_credentials: true\n", "allow_credentials"},
+		{"negative replay size", "replay:\n  size: -1\n", "replay.size"},
+		{"empty cleanup command", "cleanup:\n  commands: [\"  \"]\n", "cleanup.commands[0]"},
+		{"zero cleanup timeout", "cleanup:\n  timeout: 0s\n", "cleanup.timeout"},
+		{"empty hook command", "hooks:\n  post_fail: [\"\"]\n", "hooks.post_fail[0]"},
+		{"zero hook timeout", "hooks:\n  timeout: 0s\n", "hooks.timeout"},
+		{"plugin without command", "plugins:\n  - name: slack\n", "plugins[0]: command"},
+		{"duplicate plugin", "plugins:\n  - {name: a, command: x}\n  - {name: a, command: y}\n", "plugins[1]: duplicate name"},
+		{"negative janitor max age", "janitor:\n  max_age: -1h\n", "janitor.max_age"},
+		{"negative trash retention", "janitor:\n  trash_retention: -1h\n", "janitor.trash_retention"},
+		{"zero janitor interval", "janitor:\n  check_interval: 0s\n", "janitor.check_interval"},
+		{"negative sampling interval", "sampling:\n  interval: -1s\n", "sampling.interval"},
+		{"zero samples", "sampling:\n  samples: 0\n", "sampling.samples"},
+		{"negative disk quota", "disk_quota:\n  log_dir_bytes: -1\n", "disk_quota.log_dir_bytes"},
+		{"unknown execution mode", "execution:\n  mode: vm\n", "execution.mode"},
+		{"container mode without image", "execution:\n  mode: container\n", "execution.container.image"},
+		{"remote mode without agent token", "execution:\n  mode: remote\n", "agents.token"},
+		{"relative container mount", "execution:\n  container:\n    mounts: [\"src:/src\"]\n", "execution.container.mounts[0]"},
+		{"unknown container mount option", "execution:\n  container:\n    mounts: [\"/src:/src:z\"]\n", "execution.container.mounts[0]"},
+		{"empty issue priority", "issues:\n  priorities: {P1: \"\"}\n", "issues.priorities"},
+		{"empty issue tag", "issues:\n  tags: {bug: \"\"}\n", "issues.tags"},
+		{"history without replay", "replay:\n  size: 0\n", "history.enabled requires replay"},
+		{"invalid slow client policy", "websocket:\n  slow_client_policy: block\n", "slow_client_policy"},
+		{"zero websocket message size", "websocket:\n  max_message_size: 0\n", "websocket.max_message_size"},
+		{"ping period beyond pong wait", "websocket:\n  pong_wait: 30s\n", "websocket.ping_period must be less"},
+		{"negative rate limit", "rate_limit:\n  continues_per_minute: -5\n", "rate_limit"},
+		{"invalid thread id pattern", "thread_id:\n  pattern: \"^T-(\"\n", "thread_id.pattern"},
+		{"invalid commit message template", "git:\n  commit_message: \"{{.Title\"\n", "git.commit_message"},
+		{"invalid github repo", "issues:\n  github_repo: acme\n", "issues.github_repo"},
+		{"issue comments without token", "issues:\n  comment_on_finish: true\n", "issues.github_token"},
+		{"email without recipients", "email:\n  host: smtp.example.com\n  from: ampd@example.com\n", "email requires recipients"},
+		{"email with invalid sender", "email:\n  host: smtp.example.com\n  from: ampd\n  to: [team@example.com]\n", "email.from"},
+		{"email with invalid project recipient", "email:\n  host: smtp.example.com\n  from: ampd@example.com\n  projects: {infra: [oncall]}\n", "invalid recipient"},
+		{"short secrets master key", "secrets:\n  master_key: short\n", "secrets.master_key"},
+		{"secrets file without master key", "secrets:\n  file: /var/lib/ampd/secrets.json\n", "secrets.file requires"},
+		{"unknown storage backend", "storage:\n  backend: azure\n", "storage.backend"},
+		{"storage without bucket", "storage:\n  backend: s3\n  access_key_id: a\n  secret_access_key: b\n", "storage.bucket"},
+		{"storage without credentials", "storage:\n  backend: gcs\n  bucket: logs\n", "storage.access_key_id"},
+		{"duplicate webhook", "webhooks:\n  - {name: a, url: http://x.io}\n  - {name: a, url: http://y.io}\n", "duplicate name"},
+		{"route to unknown webhook", "webhooks:\n  - {name: a, url: http://x.io}\nwebhook_routes:\n  - {name: r, webhooks: [b]}\n", "unknown webhook"},
+		{"route without webhooks", "webhook_routes:\n  - {name: r, tags: [infra]}\n", "webhooks must not be empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadFile(writeConfigFile(t, tt.content))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+}
+
+func TestLoadFile_InvalidEnv(t *testing.T) {
+	clearTestEnvVars()
+	defer clearTestEnvVars()
+
+	os.Setenv("MAX_WORKERS", "lots")
+
+	_, err := LoadFile("")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "MAX_WORKERS")
+}
+
+func TestLoadFile_MissingFile(t *testing.T) {
+	clearTestEnvVars()
+	defer clearTestEnvVars()
+
+	_, err := LoadFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestLoadDefault_ConfigFileEnv(t *testing.T) {
+	clearTestEnvVars()
+	defer clearTestEnvVars()
+
+	os.Setenv("CONFIG_FILE", writeConfigFile(t, "port: \"7070\"\n"))
+
+	config, err := LoadDefault()
+	require.NoError(t, err)
+	assert.Equal(t, "7070", config.Port)
+}
+
+func TestLoadFile_Stall(t *testing.T) {
+	clearTestEnvVars()
+	defer clearTestEnvVars()
+
+	path := writeConfigFile(t, "stall:\n  threshold: 10m\n  check_interval: 1m\n  auto_nudge: true\n  max_nudges: 2\n")
+
+	config, err := LoadFile(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, 10*time.Minute, config.Stall.Threshold)
+	assert.Equal(t, time.Minute, config.Stall.CheckInterval)
+	assert.True(t, config.Stall.AutoNudge)
+	assert.Equal(t, 2, config.Stall.MaxNudges)
+}
+
+func TestLoadFile_Janitor(t *testing.T) {
+	clearTestEnvVars()
+	defer clearTestEnvVars()
+
+	config, err := LoadFile("")
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, config.Janitor.CheckInterval)
+	assert.Zero(t, config.Janitor.MaxAge)
+
+	config, err = LoadFile(writeConfigFile(t, "janitor:\n  check_interval: 5m\n  max_age: 720h\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, config.Janitor.CheckInterval)
+	assert.Equal(t, 720*time.Hour, config.Janitor.MaxAge)
+}
+
+func TestLoadFile_Pools(t *testing.T) {
+	clearTestEnvVars()
+	defer clearTestEnvVars()
+
+	config, err := LoadFile(writeConfigFile(t, `amp_profiles:
+  gpu:
+    api_key: k
+pools:
+  default:
+    max_workers: 4
+  gpu:
+    max_workers: 1
+    dir: /srv/gpu
+    profile: gpu
+    env:
+      CUDA_VISIBLE_DEVICES: "0"
+`))
+	require.NoError(t, err)
+	assert.Equal(t, PoolConfig{MaxWorkers: 4}, config.Pools["default"])
+	assert.Equal(t, PoolConfig{
+		MaxWorkers: 1,
+		Dir:        "/srv/gpu",
+		Profile:    "gpu",
+		Env:        map[string]string{"CUDA_VISIBLE_DEVICES": "0"},
+	}, config.Pools["gpu"])
+}
+
+func TestLoadFile_Sampling(t *testing.T) {
+	clearTestEnvVars()
+	defer clearTestEnvVars()
+
+	config, err := LoadFile("")
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, config.Sampling.Interval)
+	assert.Equal(t, 60, config.Sampling.Samples)
+
+	config, err = LoadFile(writeConfigFile(t, "sampling:\n  interval: 0s\n  samples: 120\n"))
+	require.NoError(t, err)
+	assert.Zero(t, config.Sampling.Interval)
+	assert.Equal(t, 120, config.Sampling.Samples)
+}
+
+func TestLoadFile_WebSocket(t *testing.T) {
+	clearTestEnvVars()
+	defer clearTestEnvVars()
+
+	config, err := LoadFile("")
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Second, config.WebSocket.WriteWait)
+	assert.Equal(t, 60*time.Second, config.WebSocket.PongWait)
+	assert.Equal(t, 54*time.Second, config.WebSocket.PingPeriod)
+	assert.Equal(t, int64(64<<10), config.WebSocket.MaxMessageSize)
+
+	config, err = LoadFile(writeConfigFile(t, "websocket:\n  write_wait: 5s\n  pong_wait: 2m\n  ping_period: 1m\n  max_message_size: 1048576\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, config.WebSocket.WriteWait)
+	assert.Equal(t, 2*time.Minute, config.WebSocket.PongWait)
+	assert.Equal(t, time.Minute, config.WebSocket.PingPeriod)
+	assert.Equal(t, int64(1<<20), config.WebSocket.MaxMessageSize)
+}
+
+func TestLoadFile_TLS(t *testing.T) {
+	clearTestEnvVars()
+	defer clearTestEnvVars()
+
+	path := writeConfigFile(t, "port: \"8443\"\ntls:\n  cert_file: /from/file.crt\n  key_file: /from/file.key\n  redirect_port: \"8080\"\n")
+	os.Setenv("TLS_CERT_FILE", "/from/env.crt")
+
+	config, err := LoadFile(path)
+	require.NoError(t, err)
+
+	assert.True(t, config.TLS.Enabled())
+	assert.False(t, config.TLS.UseAutocert())
+	assert.Equal(t, "/from/env.crt", config.TLS.CertFile)
+	assert.Equal(t, "/from/file.key", config.TLS.KeyFile)
+	assert.Equal(t, "8080", config.TLS.RedirectPort)
+}
+
+func TestLoadFile_RemoteAgents(t *testing.T) {
+	clearTestEnvVars()
+	defer clearTestEnvVars()
+
+	path := writeConfigFile(t, "execution:\n  mode: remote\nagents:\n  token: from-file\n")
+	os.Setenv("AGENT_TOKEN", "from-env")
+
+	config, err := LoadFile(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "remote", config.Execution.Mode)
+	assert.True(t, config.Agents.Enabled())
+	assert.Equal(t, "from-env", config.Agents.Token)
+}
+
+func TestLoadFile_CORSEnv(t *testing.T) {
+	clearTestEnvVars()
+	defer clearTestEnvVars()
+
+	path := writeConfigFile(t, "cors:\n  allowed_origins: [https://from-file.example.com]\n")
+	os.Setenv("CORS_ALLOWED_ORIGINS", "http://localhost:3000, https://dash.example.com")
+
+	config, err := LoadFile(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"http://localhost:3000", "https://dash.example.com"}, config.CORS.AllowedOrigins)
+	assert.Contains(t, config.CORS.AllowedMethods, "PATCH") // default preserved
+}
+
+func TestCORSConfig_AllowsOrigin(t *testing.T) {
+	cors := CORSConfig{AllowedOrigins: []string{"http://localhost:3000", "https://*.example.com"}}
+
+	assert.True(t, cors.AllowsOrigin("http://localhost:3000"))
+	assert.True(t, cors.AllowsOrigin("HTTP://LOCALHOST:3000/"))
+	assert.True(t, cors.AllowsOrigin("https://dash.example.com"))
+	assert.False(t, cors.AllowsOrigin("http://dash.example.com"))
+	assert.False(t, cors.AllowsOrigin("https://example.com.evil.io"))
+	assert.False(t, cors.AllowsOrigin("http://localhost:4000"))
+	assert.False(t, cors.AllowsOrigin(""))
+
+	wildcard := CORSConfig{AllowedOrigins: []string{"*"}}
+	assert.True(t, wildcard.AllowsOrigin("https://anything.io"))
+	assert.True(t, wildcard.AllowsAnyOrigin())
 }
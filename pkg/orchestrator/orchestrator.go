@@ -0,0 +1,612 @@
+// Package orchestrator assembles the ampd daemon: the worker manager running
+// amp, the WebSocket hub broadcasting task events and the HTTP API, wired
+// together as the configuration asks. Other Go services can embed it to run
+// the orchestrator in-process and react to tasks through callbacks.
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/brettsmith212/amp-orchestrator-2/internal/agent"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/api"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/audit"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/hub"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/issue"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/middleware"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/secrets"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/server"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/storage"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/webhook"
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/client"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/config"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/plugin"
+)
+
+// shutdownTimeout bounds how long Run waits for requests in flight once its
+// context is done
+const shutdownTimeout = 10 * time.Second
+
+// Orchestrator is an ampd daemon. Create it with New, register callbacks, then
+// call Run to serve the API, or Start to run it behind your own HTTP server
+// with Handler. Callbacks registered while it runs see the events that follow.
+type Orchestrator struct {
+	cfg     *config.Config
+	manager *worker.Manager
+	hub     *hub.Hub
+	tasks   *api.TaskHandler
+	handler http.Handler
+	plugins *plugin.Host
+
+	// Background loops run by Start
+	janitor *worker.Janitor
+	monitor *worker.StallMonitor
+	sampler *worker.Sampler
+	offload bool
+	started sync.Once
+
+	// Callbacks registered by the embedding program, guarded by callbackMu
+	callbackMu sync.RWMutex
+	onExit     []func(taskID string)
+	onRestart  []func(taskID string)
+	onContinue []func(taskID string, attempt int)
+	onLog      []func(taskID, line string)
+}
+
+// New builds an orchestrator from a validated configuration, restoring the
+// state saved in its log directory. Nothing runs until Start or Run.
+func New(cfg *config.Config) (*Orchestrator, error) {
+	manager, err := newManager(cfg)
+	if err != nil {
+		return nil, err
+	}
+	h, authenticate, err := newHub(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Orchestrator{cfg: cfg, manager: manager, hub: h, plugins: plugin.NewHost()}
+
+	// Pass every event to plugins, configured and registered by the embedding
+	// program alike
+	for _, p := range cfg.Plugins {
+		o.plugins.Register(plugin.NewProcess(p.Name, p.Command, p.Env, ""), plugin.Options{Types: p.Events})
+	}
+	h.AddListener(o.plugins.PublishMessage)
+
+	// Clients subscribed to tags follow existing tasks too; task updates keep
+	// the hub's tags current afterwards
+	if workers, err := manager.ListWorkers(); err == nil {
+		for _, w := range workers {
+			h.SetTaskTags(w.ID, w.Tags)
+		}
+	}
+
+	// Create task handler to handle broadcasting
+	taskHandler := api.NewTaskHandler(manager, h)
+	o.tasks = taskHandler
+
+	// Deliver task events to configured webhooks
+	dispatcher, err := webhook.NewDispatcher(cfg.Webhooks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure webhooks: %w", err)
+	}
+	if err := dispatcher.SetRoutes(cfg.Routes); err != nil {
+		return nil, fmt.Errorf("failed to configure webhook routes: %w", err)
+	}
+	if err := dispatcher.SetEmail(cfg.Email); err != nil {
+		return nil, fmt.Errorf("failed to configure email notifications: %w", err)
+	}
+	taskHandler.SetWebhookDispatcher(dispatcher)
+
+	// Restrict admin endpoints to admin tokens
+	if authenticate != nil {
+		taskHandler.SetAuthenticator(authenticate)
+	}
+
+	// Record who changed what through the API
+	if cfg.Audit.Enabled {
+		auditLog, err := audit.Open(filepath.Join(cfg.LogDir, "audit.jsonl"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit log: %w", err)
+		}
+		taskHandler.SetAuditLog(auditLog, func(r *http.Request) string {
+			if identity := authenticate.Identify(r); identity != nil {
+				return identity.User
+			}
+			return ""
+		})
+	}
+
+	// Keep tasks created from issues in sync with them, and report back on
+	// GitHub issues when their task finishes
+	issueSync := api.IssueSync{
+		Mapping: issue.Mapping{
+			Priorities: cfg.Issues.Priorities,
+			Tags:       cfg.Issues.Tags,
+			CopyLabels: cfg.Issues.CopyLabels,
+		},
+		GitHubSecret: cfg.Issues.GitHubSecret,
+		JiraSecret:   cfg.Issues.JiraSecret,
+		GitHubRepo:   cfg.Issues.GitHubRepo,
+	}
+	if cfg.Issues.CommentOnFinish {
+		issueSync.Commenter = issue.NewGitHubClient(cfg.Issues.GitHubToken, cfg.Issues.GitHubAPI)
+	}
+	taskHandler.SetIssueSync(issueSync)
+
+	// Keep tasks whose pull request checks fail from being merged
+	taskHandler.SetBlockFailingMerges(cfg.Git.BlockFailingMerges)
+
+	// Respect upstream API rate limits across all workers
+	if cfg.RateLimit.Enabled() {
+		limiter := worker.NewRateLimiter(worker.RateLimitConfig{
+			ThreadsPerMinute:   cfg.RateLimit.ThreadsPerMinute,
+			ContinuesPerMinute: cfg.RateLimit.ContinuesPerMinute,
+			MaxWait:            cfg.RateLimit.MaxWait,
+		})
+		limiter.SetSaturationCallback(taskHandler.BroadcastRateLimitEvent)
+		manager.SetRateLimiter(limiter)
+	}
+
+	// Accept remote agents that run amp on other machines
+	if cfg.Agents.Enabled() {
+		pool := agent.NewPool(cfg.Agents.Token)
+		manager.SetAgentPool(pool)
+		taskHandler.SetAgentPool(pool)
+	}
+
+	// Run amp on the host, sandboxed in containers or on remote agents
+	if err := manager.SetExecution(worker.ExecutionMode(cfg.Execution.Mode), worker.ContainerConfig{
+		Runtime:   cfg.Execution.Container.Runtime,
+		Image:     cfg.Execution.Container.Image,
+		AmpBinary: cfg.Execution.Container.AmpBinary,
+		Mounts:    cfg.Execution.Container.Mounts,
+		Network:   cfg.Execution.Container.Network,
+		Env:       cfg.Execution.Container.Env,
+		Workdir:   cfg.Execution.Container.Workdir,
+	}); err != nil {
+		return nil, fmt.Errorf("invalid execution configuration: %w", err)
+	}
+
+	// Compare workers' changes with the base branch of the repository
+	manager.SetWorkspace(worker.Workspace{
+		Dir:        cfg.Git.RepoDir,
+		BaseBranch: cfg.Git.BaseBranch,
+	})
+
+	// Commit the changes of tasks started with auto-commit when they exit
+	if err := manager.SetCommitMessage(cfg.Git.CommitMessage); err != nil {
+		return nil, fmt.Errorf("invalid git configuration: %w", err)
+	}
+
+	// Clean up after workers whose process exits
+	manager.SetCleanup(worker.CleanupConfig{
+		Commands: cfg.Cleanup.Commands,
+		Timeout:  cfg.Cleanup.Timeout,
+		Dir:      cfg.Git.RepoDir,
+	})
+
+	// Run the user's scripts before tasks start and after they finish
+	manager.SetHooks(worker.HooksConfig{
+		PreStart:     cfg.Hooks.PreStart,
+		PostComplete: cfg.Hooks.PostComplete,
+		PostFail:     cfg.Hooks.PostFail,
+		Timeout:      cfg.Hooks.Timeout,
+		Dir:          cfg.Git.RepoDir,
+	})
+
+	o.setCallbacks()
+
+	// Finalize workers whose process vanished, delete long-finished ones and
+	// purge the trash
+	manager.SetTrashRetention(cfg.Janitor.TrashRetention)
+	o.janitor = worker.NewJanitor(manager, worker.JanitorPolicy{
+		CheckInterval: cfg.Janitor.CheckInterval,
+		MaxAge:        cfg.Janitor.MaxAge,
+	})
+
+	// Watch for stalled workers, optionally nudging them back into action
+	if cfg.Stall.Threshold > 0 {
+		o.monitor = worker.NewStallMonitor(manager, worker.StallPolicy{
+			Threshold:     cfg.Stall.Threshold,
+			CheckInterval: cfg.Stall.CheckInterval,
+			AutoNudge:     cfg.Stall.AutoNudge,
+			NudgeMessage:  cfg.Stall.NudgeMessage,
+			MaxNudges:     cfg.Stall.MaxNudges,
+		}, taskHandler.BroadcastStallEvent)
+	}
+
+	// Sample running tasks' CPU and memory use for live charts
+	if cfg.Sampling.Interval > 0 {
+		o.sampler = worker.NewSampler(manager, worker.SamplerPolicy{
+			Interval: cfg.Sampling.Interval,
+			Samples:  cfg.Sampling.Samples,
+		}, taskHandler.BroadcastTaskStats)
+		taskHandler.SetSampler(o.sampler)
+	}
+
+	// Move the logs and threads of long-finished tasks to object storage
+	if cfg.Storage.Enabled() {
+		store, err := storage.New(storage.Config{
+			Backend:         cfg.Storage.Backend,
+			Bucket:          cfg.Storage.Bucket,
+			Prefix:          cfg.Storage.Prefix,
+			Region:          cfg.Storage.Region,
+			Endpoint:        cfg.Storage.Endpoint,
+			AccessKeyID:     cfg.Storage.AccessKeyID,
+			SecretAccessKey: cfg.Storage.SecretAccessKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid storage configuration: %w", err)
+		}
+		manager.SetObjectStore(store, worker.OffloadPolicy{
+			After:         cfg.Storage.OffloadAfter,
+			CheckInterval: cfg.Storage.CheckInterval,
+		})
+		o.offload = true
+	}
+
+	// Refuse new tasks while the log directory is over its quota, warning
+	// clients and running retention cleanup once it's exceeded
+	if cfg.DiskQuota.LogDirBytes > 0 {
+		manager.SetLogDirQuota(cfg.DiskQuota.LogDirBytes, func(event worker.QuotaEvent) {
+			taskHandler.BroadcastQuotaEvent(event)
+			o.janitor.Trigger()
+			go manager.OffloadIdle(context.Background(), time.Now())
+		})
+	}
+
+	// Encrypted secrets that tasks add to amp's environment by name
+	if cfg.Secrets.Enabled() {
+		secretsFile := cfg.Secrets.File
+		if secretsFile == "" {
+			secretsFile = filepath.Join(cfg.LogDir, "secrets.json")
+		}
+		secretStore, err := secrets.Open(secretsFile, cfg.Secrets.MasterKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open secrets: %w", err)
+		}
+		manager.SetSecrets(secretStore)
+		taskHandler.SetSecretStore(secretStore)
+	}
+
+	// Tell clients which optional subsystems this daemon supports
+	authMode := api.AuthModeNone
+	if cfg.Auth.Enabled() {
+		authMode = api.AuthModeToken
+	}
+	taskHandler.SetFeatures(authMode, api.Features{
+		Agents:         len(cfg.Backends) > 0,
+		TLS:            cfg.TLS.Enabled(),
+		Replay:         cfg.Replay.Size > 0,
+		History:        cfg.History.Enabled,
+		Audit:          cfg.Audit.Enabled,
+		Webhooks:       len(cfg.Webhooks) > 0,
+		RateLimit:      cfg.RateLimit.Enabled(),
+		StallDetection: cfg.Stall.Threshold > 0,
+		Cleanup:        len(cfg.Cleanup.Commands) > 0,
+		Hooks:          len(cfg.Hooks.PreStart) > 0 || len(cfg.Hooks.PostComplete) > 0 || len(cfg.Hooks.PostFail) > 0,
+		Plugins:        len(cfg.Plugins) > 0,
+		Pools:          len(cfg.Pools) > 0,
+		Containers:     cfg.Execution.Container.Image != "",
+		RemoteAgents:   cfg.Agents.Enabled(),
+		IssueSync:      len(cfg.Issues.Priorities) > 0 || len(cfg.Issues.Tags) > 0 || cfg.Issues.CopyLabels,
+		Secrets:        cfg.Secrets.Enabled(),
+		Sampling:       cfg.Sampling.Interval > 0,
+	})
+
+	o.handler = middleware.CORS(cfg.CORS)(api.NewRouter(taskHandler, h))
+	return o, nil
+}
+
+// newManager creates the worker manager and configures how it runs amp
+func newManager(cfg *config.Config) (*worker.Manager, error) {
+	manager := worker.NewManager(cfg.LogDir)
+	if err := manager.MigrateState(); err != nil {
+		return nil, fmt.Errorf("failed to upgrade saved state: %w", err)
+	}
+	manager.SetAmpBinary(cfg.AmpBinary)
+	if ampVersion, err := manager.DetectAmpVersion(); err != nil {
+		log.Printf("Failed to detect amp version, assuming the newest log format: %v", err)
+	} else {
+		log.Printf("Using amp %s", ampVersion)
+	}
+	manager.SetAmpOverrides(worker.AmpOverrides{
+		Binaries: cfg.AmpOverrides.Binaries,
+		Flags:    cfg.AmpOverrides.Flags,
+	})
+	profiles := make(map[string]worker.AmpProfile, len(cfg.AmpProfiles))
+	for name, profile := range cfg.AmpProfiles {
+		profiles[name] = worker.AmpProfile{Env: profile.Environment()}
+	}
+	manager.SetAmpProfiles(profiles)
+	backends := make(map[string]worker.AgentClient, len(cfg.Backends))
+	for name, backend := range cfg.Backends {
+		backends[name] = worker.CommandClient{Path: backend.Binary, NewThread: backend.NewThread, Continue: backend.Continue, Models: backend.Models}
+	}
+	manager.SetAgentClients(backends)
+	manager.SetAmpModels(cfg.AmpModels)
+	pools := make(map[string]worker.Pool, len(cfg.Pools))
+	for name, pool := range cfg.Pools {
+		pools[name] = worker.Pool{MaxWorkers: pool.MaxWorkers, Dir: pool.Dir, Profile: pool.Profile, Env: pool.Env}
+	}
+	manager.SetPools(pools)
+	manager.SetMaxWorkers(cfg.Concurrency.MaxWorkers)
+	manager.SetMaxLineSize(cfg.MaxLogLineSize)
+
+	// Validate amp thread IDs against the configured format
+	threadIDFormat := worker.ThreadIDFormat{AcceptUnknown: cfg.ThreadID.AcceptUnknown}
+	if cfg.ThreadID.Pattern != "" {
+		pattern, err := regexp.Compile(cfg.ThreadID.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid thread ID pattern: %w", err)
+		}
+		threadIDFormat.Pattern = pattern
+	}
+	manager.SetThreadIDFormat(threadIDFormat)
+	return manager, nil
+}
+
+// newHub creates the WebSocket hub, returning the authenticator of API tokens
+// when authentication is enabled
+func newHub(cfg *config.Config) (*hub.Hub, hub.Authenticator, error) {
+	h := hub.NewHub()
+	h.SetSecureOrigins(cfg.TLS.Enabled())
+	h.SetAllowedOrigins(cfg.CORS.AllowsOrigin)
+	var authenticate hub.Authenticator
+	if cfg.Auth.Enabled() {
+		tokens := make(map[string]hub.Identity, len(cfg.Auth.Tokens))
+		for _, token := range cfg.Auth.Tokens {
+			role := token.Role
+			if role == "" {
+				role = "user"
+			}
+			tokens[token.Token] = hub.Identity{User: token.User, Role: role}
+		}
+		authenticate = hub.TokenAuthenticator(tokens)
+		h.SetAuthenticator(authenticate)
+	}
+	policy, err := hub.ParseSlowClientPolicy(cfg.WebSocket.SlowClientPolicy)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid WebSocket configuration: %w", err)
+	}
+	h.SetSlowClientPolicy(policy)
+	limits := hub.ConnectionLimits{
+		WriteWait:      cfg.WebSocket.WriteWait,
+		PongWait:       cfg.WebSocket.PongWait,
+		PingPeriod:     cfg.WebSocket.PingPeriod,
+		MaxMessageSize: cfg.WebSocket.MaxMessageSize,
+	}
+	if err := h.SetConnectionLimits(limits); err != nil {
+		return nil, nil, fmt.Errorf("invalid WebSocket configuration: %w", err)
+	}
+	if cfg.Replay.Size > 0 {
+		replay := hub.ReplayConfig{Size: cfg.Replay.Size, Retention: cfg.Replay.Retention}
+		if cfg.Replay.Persist {
+			replay.Path = filepath.Join(cfg.LogDir, "events.jsonl")
+		}
+		if err := h.EnableReplay(replay); err != nil {
+			return nil, nil, fmt.Errorf("failed to restore event replay: %w", err)
+		}
+	}
+	if cfg.History.Enabled {
+		history := hub.HistoryConfig{
+			Path:      filepath.Join(cfg.LogDir, "history.jsonl"),
+			Retention: cfg.History.Retention,
+		}
+		if err := h.EnableHistory(history); err != nil {
+			return nil, nil, fmt.Errorf("failed to open event history: %w", err)
+		}
+	}
+	return h, authenticate, nil
+}
+
+// setCallbacks broadcasts the manager's events, then passes them on to the
+// callbacks registered by the embedding program
+func (o *Orchestrator) setCallbacks() {
+	// Set up log callback to broadcast log events
+	o.manager.SetLogCallback(func(line worker.LogLine) {
+		o.tasks.BroadcastLogEvent(line)
+		o.callbackMu.RLock()
+		onLog := o.onLog
+		o.callbackMu.RUnlock()
+		for _, fn := range onLog {
+			fn(line.WorkerID, line.Content)
+		}
+	})
+
+	// Set up thread message callback to broadcast thread message events
+	o.manager.SetThreadMessageCallback(func(workerID string, message worker.ThreadMessage) {
+		event := api.ThreadMessageEvent{
+			Type: "thread_message",
+			Data: api.ThreadMessageDTO{
+				ID:        message.ID,
+				Type:      string(message.Type),
+				Content:   message.Content,
+				Timestamp: message.Timestamp,
+				Metadata:  message.Metadata,
+			},
+		}
+
+		if eventJSON, err := json.Marshal(event); err == nil {
+			o.hub.BroadcastTaskEvent(workerID, api.TaskRoom(workerID), eventJSON)
+		}
+	})
+
+	// Set up worker exit callback to broadcast task updates
+	o.manager.SetExitCallback(func(workerID string) {
+		// Broadcast the worker's updated status to WebSocket clients and webhooks
+		o.tasks.BroadcastTaskUpdate(workerID)
+
+		// Process stopped workers to generate thread messages
+		o.manager.ProcessStoppedWorkers()
+
+		// Notify once the thread is complete, so issue comments can summarize it
+		o.tasks.DispatchTaskFinished(workerID)
+
+		o.callbackMu.RLock()
+		onExit := o.onExit
+		o.callbackMu.RUnlock()
+		for _, fn := range onExit {
+			fn(workerID)
+		}
+	})
+
+	// Report when amp has answered messages sent to tasks
+	o.manager.SetContinueCallback(func(workerID string, attempt worker.Attempt) {
+		o.tasks.BroadcastContinueFinished(workerID, attempt)
+		o.callbackMu.RLock()
+		onContinue := o.onContinue
+		o.callbackMu.RUnlock()
+		for _, fn := range onContinue {
+			fn(workerID, attempt.Number)
+		}
+	})
+
+	// Broadcast tasks restarted by their restart policy
+	o.manager.SetRestartCallback(func(workerID string) {
+		o.tasks.BroadcastTaskUpdate(workerID)
+		o.callbackMu.RLock()
+		onRestart := o.onRestart
+		o.callbackMu.RUnlock()
+		for _, fn := range onRestart {
+			fn(workerID)
+		}
+	})
+}
+
+// OnTaskExit registers fn to run when a task's process exits, after its new
+// status has been broadcast
+func (o *Orchestrator) OnTaskExit(fn func(taskID string)) {
+	o.callbackMu.Lock()
+	o.onExit = append(o.onExit, fn)
+	o.callbackMu.Unlock()
+}
+
+// OnTaskRestart registers fn to run when a task's restart policy runs amp
+// again
+func (o *Orchestrator) OnTaskRestart(fn func(taskID string)) {
+	o.callbackMu.Lock()
+	o.onRestart = append(o.onRestart, fn)
+	o.callbackMu.Unlock()
+}
+
+// OnContinueFinished registers fn to run when amp has answered a message sent
+// to a task, with the number of the attempt that sent it
+func (o *Orchestrator) OnContinueFinished(fn func(taskID string, attempt int)) {
+	o.callbackMu.Lock()
+	o.onContinue = append(o.onContinue, fn)
+	o.callbackMu.Unlock()
+}
+
+// OnLog registers fn to run for each line a task writes to its log
+func (o *Orchestrator) OnLog(fn func(taskID, line string)) {
+	o.callbackMu.Lock()
+	o.onLog = append(o.onLog, fn)
+	o.callbackMu.Unlock()
+}
+
+// AddPlugin registers a plugin receiving the daemon's events, like those in
+// the configuration's plugins. Register plugins before calling Start or Run.
+func (o *Orchestrator) AddPlugin(p plugin.Plugin, opts plugin.Options) {
+	o.plugins.Register(p, opts)
+}
+
+// PluginStats returns what happened to the events sent to each plugin
+func (o *Orchestrator) PluginStats() []plugin.Stats {
+	return o.plugins.Stats()
+}
+
+// Client returns an API client that calls Handler in-process, for starting
+// and controlling tasks without a network round trip. Its requests are
+// authenticated like any other client's.
+func (o *Orchestrator) Client() *client.Client {
+	c := client.New("http://ampd")
+	c.SetHTTPClient(&http.Client{Transport: handlerTransport{o.handler}})
+	return c
+}
+
+// handlerTransport sends requests straight to an HTTP handler
+type handlerTransport struct {
+	handler http.Handler
+}
+
+// RoundTrip serves req with the handler and returns the recorded response
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+	// Handlers see the request as a server would have received it
+	served := req.Clone(req.Context())
+	served.RequestURI = req.URL.RequestURI()
+	served.RemoteAddr = "in-process"
+
+	w := httptest.NewRecorder()
+	t.handler.ServeHTTP(w, served)
+	return w.Result(), nil
+}
+
+// Handler returns the HTTP API, including /api/ws, with the configured CORS
+// policy applied
+func (o *Orchestrator) Handler() http.Handler {
+	return o.handler
+}
+
+// Start runs the hub and the background loops, such as the janitor and stall
+// monitor, until ctx is done. It doesn't block or serve HTTP; mount Handler
+// on your own server, or call Run instead. Calls after the first do nothing.
+func (o *Orchestrator) Start(ctx context.Context) {
+	o.started.Do(func() {
+		go o.plugins.Run(ctx)
+		go o.hub.Run(ctx)
+		go o.janitor.Run(ctx)
+		if o.monitor != nil {
+			go o.monitor.Run(ctx)
+		}
+		if o.sampler != nil {
+			go o.sampler.Run(ctx)
+		}
+		if o.offload {
+			go o.manager.RunOffloader(ctx)
+		}
+	})
+}
+
+// Run starts the orchestrator and serves the API on the configured port until
+// ctx is done, then waits briefly for requests in flight to finish
+func (o *Orchestrator) Run(ctx context.Context) error {
+	o.Start(ctx)
+
+	srv := server.New(o.cfg, o.handler)
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
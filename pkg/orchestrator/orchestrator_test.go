@@ -0,0 +1,132 @@
+package orchestrator
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/client"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/config"
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/plugin"
+)
+
+// testConfig returns a valid configuration keeping state in a temporary
+// directory
+func testConfig(t *testing.T) *config.Config {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("LOG_DIR", t.TempDir())
+	cfg, err := config.LoadFile("")
+	require.NoError(t, err)
+	return cfg
+}
+
+func TestNew_ServesAPI(t *testing.T) {
+	o, err := New(testConfig(t))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	o.Start(ctx)
+	o.Start(ctx) // Starting again does nothing
+
+	srv := httptest.NewServer(o.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/tasks")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	page, err := o.Client().ListTasks(context.Background(), client.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Tasks)
+}
+
+// pluginFunc is a plugin calling a function with each event
+type pluginFunc func(plugin.Event)
+
+func (f pluginFunc) Name() string {
+	return "func"
+}
+
+func (f pluginFunc) HandleEvent(ctx context.Context, event plugin.Event) error {
+	f(event)
+	return nil
+}
+
+func TestAddPlugin(t *testing.T) {
+	o, err := New(testConfig(t))
+	require.NoError(t, err)
+
+	received := make(chan plugin.Event, 1)
+	o.AddPlugin(pluginFunc(func(event plugin.Event) {
+		received <- event
+	}), plugin.Options{Types: []string{"task-update"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	o.Start(ctx)
+
+	o.hub.BroadcastTaskEvent("a", "task:a", []byte(`{"type":"task-update","data":{"id":"a"}}`))
+	select {
+	case event := <-received:
+		assert.Equal(t, "a", event.TaskID)
+		assert.Equal(t, uint64(1), event.Seq)
+	case <-time.After(time.Second):
+		t.Fatal("plugin didn't receive the event")
+	}
+	assert.Eventually(t, func() bool {
+		return o.PluginStats()[0].Delivered == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Execution.Mode = "remote" // Without remote agents enabled
+
+	_, err := New(cfg)
+	assert.ErrorContains(t, err, "invalid execution configuration")
+}
+
+func TestRun_StopsWhenContextDone(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	cfg := testConfig(t)
+	cfg.Port = strconv.Itoa(port)
+	o, err := New(cfg)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := make(chan error, 1)
+	go func() {
+		ran <- o.Run(ctx)
+	}()
+
+	url := "http://127.0.0.1:" + cfg.Port + "/api/tasks"
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(url)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-ran:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return after its context was done")
+	}
+}
@@ -0,0 +1,175 @@
+// Package plugin passes the daemon's events, such as task updates, thread
+// messages and log lines, to integrations that don't belong in the daemon
+// itself. Go programs embedding the orchestrator implement Plugin; others run
+// as a Process that reads events as JSON lines on its standard input.
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultQueueSize is how many events wait for a plugin before new ones are
+// dropped, when Options doesn't set it
+const DefaultQueueSize = 1000
+
+// Event is an event broadcast by the daemon, as it is sent over /api/ws
+type Event struct {
+	Type      string          `json:"type"` // e.g. "task-update", "log" or "thread_message"
+	Seq       uint64          `json:"seq,omitempty"`
+	TaskID    string          `json:"task_id,omitempty"` // Task the event is about, if any
+	Data      json.RawMessage `json:"data,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// Plugin receives the daemon's events. A Host calls HandleEvent from one
+// goroutine per plugin, in the order events were broadcast. Plugins that also
+// implement io.Closer are closed when the host stops.
+type Plugin interface {
+	Name() string
+	HandleEvent(ctx context.Context, event Event) error
+}
+
+// Options selects the events a plugin receives
+type Options struct {
+	Types     []string // Event types; empty delivers every type
+	QueueSize int      // Events waiting for the plugin before new ones are dropped; 0 uses DefaultQueueSize
+}
+
+// Stats counts what happened to the events sent to a plugin
+type Stats struct {
+	Name      string `json:"name"`
+	Delivered uint64 `json:"delivered"`
+	Failed    uint64 `json:"failed"`  // HandleEvent returned an error
+	Dropped   uint64 `json:"dropped"` // The plugin's queue was full
+}
+
+// registered is a plugin with its queue of events
+type registered struct {
+	plugin    Plugin
+	types     map[string]bool
+	queue     chan Event
+	delivered atomic.Uint64
+	failed    atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// Host delivers events to plugins without letting a slow plugin hold up the
+// daemon or other plugins
+type Host struct {
+	plugins []*registered
+}
+
+// NewHost creates a host without plugins
+func NewHost() *Host {
+	return &Host{}
+}
+
+// Register adds a plugin. Register plugins before calling Run.
+func (h *Host) Register(plugin Plugin, opts Options) {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultQueueSize
+	}
+	types := make(map[string]bool, len(opts.Types))
+	for _, eventType := range opts.Types {
+		types[eventType] = true
+	}
+	h.plugins = append(h.plugins, &registered{
+		plugin: plugin,
+		types:  types,
+		queue:  make(chan Event, opts.QueueSize),
+	})
+}
+
+// Len returns the number of registered plugins
+func (h *Host) Len() int {
+	return len(h.plugins)
+}
+
+// Publish queues an event for the plugins that want it, dropping it for
+// those whose queue is full. It never blocks.
+func (h *Host) Publish(event Event) {
+	for _, p := range h.plugins {
+		if len(p.types) > 0 && !p.types[event.Type] {
+			continue
+		}
+		select {
+		case p.queue <- event:
+		default:
+			p.dropped.Add(1)
+		}
+	}
+}
+
+// PublishMessage publishes an event broadcast by the hub about a task, or
+// about no task when taskID is empty. Its signature matches hub.EventListener.
+func (h *Host) PublishMessage(taskID string, message []byte) {
+	if len(h.plugins) == 0 {
+		return
+	}
+	var event Event
+	if err := json.Unmarshal(message, &event); err != nil || event.Type == "" {
+		return
+	}
+	event.TaskID = taskID
+	h.Publish(event)
+}
+
+// Run delivers events to the plugins until ctx is done, then closes the
+// plugins that implement io.Closer
+func (h *Host) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, p := range h.plugins {
+		wg.Add(1)
+		go func(p *registered) {
+			defer wg.Done()
+			p.deliver(ctx)
+		}(p)
+	}
+	wg.Wait()
+
+	for _, p := range h.plugins {
+		if closer, ok := p.plugin.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				log.Printf("Failed to close plugin %s: %v", p.plugin.Name(), err)
+			}
+		}
+	}
+}
+
+// deliver passes queued events to the plugin until ctx is done
+func (p *registered) deliver(ctx context.Context) {
+	for {
+		select {
+		case event := <-p.queue:
+			if err := p.plugin.HandleEvent(ctx, event); err != nil {
+				p.failed.Add(1)
+				log.Printf("Plugin %s failed to handle %s event: %v", p.plugin.Name(), event.Type, err)
+				continue
+			}
+			p.delivered.Add(1)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Stats returns what happened to the events sent to each plugin, in the order
+// they were registered
+func (h *Host) Stats() []Stats {
+	stats := make([]Stats, 0, len(h.plugins))
+	for _, p := range h.plugins {
+		stats = append(stats, Stats{
+			Name:      p.plugin.Name(),
+			Delivered: p.delivered.Load(),
+			Failed:    p.failed.Load(),
+			Dropped:   p.dropped.Load(),
+		})
+	}
+	return stats
+}
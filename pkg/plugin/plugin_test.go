@@ -0,0 +1,107 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder is a plugin recording the events it receives
+type recorder struct {
+	name   string
+	mu     sync.Mutex
+	events []Event
+	fail   bool
+	closed bool
+}
+
+func (r *recorder) Name() string {
+	return r.name
+}
+
+func (r *recorder) HandleEvent(ctx context.Context, event Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail {
+		return errors.New("unavailable")
+	}
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return nil
+}
+
+func (r *recorder) types() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var types []string
+	for _, event := range r.events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestHost(t *testing.T) {
+	all := &recorder{name: "all"}
+	updates := &recorder{name: "updates"}
+	failing := &recorder{name: "failing", fail: true}
+
+	host := NewHost()
+	host.Register(all, Options{})
+	host.Register(updates, Options{Types: []string{"task-update"}})
+	host.Register(failing, Options{})
+	assert.Equal(t, 3, host.Len())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		host.Run(ctx)
+		close(done)
+	}()
+
+	host.PublishMessage("a", []byte(`{"type":"log","seq":1,"data":{"line":"hi"},"timestamp":"2024-01-01T00:00:00Z"}`))
+	host.PublishMessage("a", []byte(`{"type":"task-update","seq":2}`))
+	host.PublishMessage("", []byte(`not json`))
+
+	require.Eventually(t, func() bool {
+		return len(all.types()) == 2 && len(updates.types()) == 1 && host.Stats()[2].Failed == 2
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"log", "task-update"}, all.types())
+	assert.Equal(t, []string{"task-update"}, updates.types())
+
+	first := all.events[0]
+	assert.Equal(t, "a", first.TaskID)
+	assert.Equal(t, uint64(1), first.Seq)
+	assert.JSONEq(t, `{"line":"hi"}`, string(first.Data))
+
+	cancel()
+	<-done
+	assert.True(t, all.closed)
+
+	stats := host.Stats()
+	require.Len(t, stats, 3)
+	assert.Equal(t, Stats{Name: "all", Delivered: 2}, stats[0])
+	assert.Equal(t, Stats{Name: "failing", Failed: 2}, stats[2])
+}
+
+func TestHost_DropsWhenQueueFull(t *testing.T) {
+	slow := &recorder{name: "slow"}
+	host := NewHost()
+	host.Register(slow, Options{QueueSize: 2})
+
+	// Nothing is delivered until Run
+	for i := 0; i < 5; i++ {
+		host.Publish(Event{Type: "log"})
+	}
+	assert.Equal(t, uint64(3), host.Stats()[0].Dropped)
+}
@@ -0,0 +1,196 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// restartDelay is the least time between starts of a plugin's program, so
+	// one that crashes on every event doesn't spin
+	restartDelay = 5 * time.Second
+
+	// stopTimeout is how long a plugin's program may take to exit once its
+	// input is closed before it is killed
+	stopTimeout = 5 * time.Second
+)
+
+// Process is a plugin run as a program. Each event is written to its standard
+// input as a line of JSON; its output is written to the daemon's log. The
+// program is started with the first event and started again with the next
+// event after it exits.
+type Process struct {
+	name    string
+	command string
+	env     []string
+	dir     string
+
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	stdin   *os.File
+	exited  chan struct{} // Closed once the running program has exited
+	started time.Time
+}
+
+// NewProcess creates a plugin that runs command with bash -c in dir, or in
+// the daemon's working directory when dir is empty. The program's environment
+// is the daemon's with AMP_PLUGIN set to name, plus env.
+func NewProcess(name, command string, env map[string]string, dir string) *Process {
+	environ := append(os.Environ(), "AMP_PLUGIN="+name)
+	for key, value := range env {
+		environ = append(environ, key+"="+value)
+	}
+	return &Process{name: name, command: command, env: environ, dir: dir}
+}
+
+// Name returns the plugin's name
+func (p *Process) Name() string {
+	return p.name
+}
+
+// HandleEvent writes the event to the program, starting it if it isn't
+// running. A program that doesn't read the event before ctx is done is
+// stopped, and started again with a later event.
+func (p *Process) HandleEvent(ctx context.Context, event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cmd != nil && p.hasExited() {
+		p.stop()
+	}
+	if p.cmd == nil {
+		if wait := restartDelay - time.Since(p.started); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err := p.start(); err != nil {
+			return err
+		}
+	}
+
+	// Interrupt a blocked write when ctx is done
+	stop := context.AfterFunc(ctx, func() {
+		p.stdin.SetWriteDeadline(time.Now())
+	})
+	defer stop()
+
+	if _, err := p.stdin.Write(append(line, '\n')); err != nil {
+		// A partial line would corrupt the stream, so start afresh
+		p.stop()
+		return fmt.Errorf("failed to write to plugin: %w", err)
+	}
+	return nil
+}
+
+// Close stops the program
+func (p *Process) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stop()
+	return nil
+}
+
+// hasExited reports whether the running program has exited. Callers must
+// hold the lock.
+func (p *Process) hasExited() bool {
+	select {
+	case <-p.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// start starts the program, logging its output until it exits. Callers must
+// hold the lock.
+func (p *Process) start() error {
+	stdin, input, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	output, stdout, err := os.Pipe()
+	if err != nil {
+		stdin.Close()
+		input.Close()
+		return err
+	}
+
+	cmd := exec.Command("bash", "-c", p.command)
+	cmd.Dir = p.dir
+	cmd.Env = p.env
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stdout
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	p.started = time.Now()
+	err = cmd.Start()
+
+	// The program has its own copies of its ends of the pipes
+	stdin.Close()
+	stdout.Close()
+	if err != nil {
+		input.Close()
+		output.Close()
+		return fmt.Errorf("failed to start plugin: %w", err)
+	}
+	log.Printf("Started plugin %s", p.name)
+
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		defer output.Close()
+
+		scanner := bufio.NewScanner(output)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			log.Printf("Plugin %s: %s", p.name, scanner.Text())
+		}
+		// Keep draining output after an overlong line so the program can't block
+		io.Copy(io.Discard, output)
+
+		if err := cmd.Wait(); err != nil {
+			log.Printf("Plugin %s exited: %v", p.name, err)
+		} else {
+			log.Printf("Plugin %s exited", p.name)
+		}
+	}()
+
+	p.cmd = cmd
+	p.stdin = input
+	p.exited = exited
+	return nil
+}
+
+// stop closes the program's input, which should make it exit, and kills it
+// if it hasn't exited after stopTimeout. Callers must hold the lock.
+func (p *Process) stop() {
+	if p.cmd == nil {
+		return
+	}
+	p.stdin.Close()
+	select {
+	case <-p.exited:
+	case <-time.After(stopTimeout):
+		syscall.Kill(-p.cmd.Process.Pid, syscall.SIGKILL)
+		<-p.exited
+	}
+	p.cmd = nil
+}
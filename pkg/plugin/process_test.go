@@ -0,0 +1,64 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readLines returns the lines written to path so far
+func readLines(path string) []string {
+	content, _ := os.ReadFile(path)
+	return strings.Split(strings.TrimSpace(string(content)), "\n")
+}
+
+func TestProcess(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "events")
+	p := NewProcess("recorder", `while read -r line; do echo "$AMP_PLUGIN $FOO $line" >> "$OUT"; done`, map[string]string{
+		"FOO": "bar",
+		"OUT": out,
+	}, "")
+	defer p.Close()
+
+	ctx := context.Background()
+	require.NoError(t, p.HandleEvent(ctx, Event{Type: "log", Seq: 1, TaskID: "a"}))
+	require.NoError(t, p.HandleEvent(ctx, Event{Type: "task-update", Seq: 2}))
+
+	require.Eventually(t, func() bool {
+		return len(readLines(out)) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	lines := readLines(out)
+	assert.True(t, strings.HasPrefix(lines[0], "recorder bar "))
+	var event Event
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[0], "recorder bar ")), &event))
+	assert.Equal(t, "log", event.Type)
+	assert.Equal(t, "a", event.TaskID)
+
+	// Closing the program's input stops it
+	require.NoError(t, p.Close())
+	assert.Nil(t, p.cmd)
+}
+
+func TestProcess_StopsWhenBlocked(t *testing.T) {
+	p := NewProcess("stuck", "sleep 30", nil, "")
+	defer p.Close()
+
+	// A program that never reads fills the pipe, until ctx is done
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	event := Event{Type: "log", Data: json.RawMessage(`"` + strings.Repeat("x", 1024*1024) + `"`)}
+
+	start := time.Now()
+	err := p.HandleEvent(ctx, event)
+	assert.ErrorContains(t, err, "failed to write to plugin")
+	assert.Less(t, time.Since(start), stopTimeout+2*time.Second)
+	assert.Nil(t, p.cmd)
+}
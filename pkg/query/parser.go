@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/brettsmith212/amp-orchestrator-2/internal/worker"
 	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
 )
 
@@ -20,6 +21,12 @@ type TaskQuery struct {
 	Status    []string   `json:"status,omitempty"`
 	StartedBefore *time.Time `json:"started_before,omitempty"`
 	StartedAfter  *time.Time `json:"started_after,omitempty"`
+	Project       string     `json:"project,omitempty"`
+	Owner         string     `json:"owner,omitempty"`      // A user, or "me" for the caller
+	Tags          []string   `json:"tags,omitempty"`       // Tasks must have every tag
+	Priorities    []string   `json:"priorities,omitempty"` // Tasks must have one of the priorities
+	Q             string     `json:"q,omitempty"`          // Substring of the title or description
+	IDs           []string   `json:"ids,omitempty"`        // Only these tasks
 
 	// Sorting
 	SortBy    string `json:"sort_by"`
@@ -60,7 +67,7 @@ func ParseTaskQuery(values url.Values) (*TaskQuery, error) {
 		var statuses []string
 		for _, status := range rawStatuses {
 			status = strings.TrimSpace(status)
-			if status != "running" && status != "stopped" {
+			if !worker.WorkerStatus(status).Valid() {
 				return nil, apierr.BadRequestf("Invalid status filter: %s", status)
 			}
 			statuses = append(statuses, status)
@@ -86,9 +93,61 @@ func ParseTaskQuery(values url.Values) (*TaskQuery, error) {
 		query.StartedAfter = &after
 	}
 
+	// Parse project filter
+	if project := values.Get("project"); project != "" {
+		query.Project = project
+	}
+
+	// Parse owner filter
+	if owner := values.Get("owner"); owner != "" {
+		query.Owner = owner
+	}
+
+	// Parse tag filter, given as repeated or comma-separated values
+	for _, tagStr := range values["tag"] {
+		for _, tag := range strings.Split(tagStr, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				query.Tags = append(query.Tags, tag)
+			}
+		}
+	}
+
+	// Parse priority filter
+	if priorityStr := values.Get("priority"); priorityStr != "" {
+		for _, priority := range strings.Split(priorityStr, ",") {
+			if priority = strings.TrimSpace(priority); priority != "" {
+				query.Priorities = append(query.Priorities, priority)
+			}
+		}
+	}
+
+	// Parse task IDs. A batch fits on one page unless a smaller limit is given.
+	if idsStr := values.Get("ids"); idsStr != "" {
+		seen := make(map[string]bool)
+		for _, id := range strings.Split(idsStr, ",") {
+			if id = strings.TrimSpace(id); id != "" && !seen[id] {
+				seen[id] = true
+				query.IDs = append(query.IDs, id)
+			}
+		}
+		if len(query.IDs) > 100 {
+			return nil, apierr.BadRequest("Cannot request more than 100 task IDs")
+		}
+		if values.Get("limit") == "" && len(query.IDs) > query.Limit {
+			query.Limit = len(query.IDs)
+		}
+	}
+
+	// Parse text search
+	if q := strings.TrimSpace(values.Get("q")); q != "" {
+		query.Q = q
+	}
+
 	// Parse sort_by
 	if sortBy := values.Get("sort_by"); sortBy != "" {
-		if sortBy != "started" && sortBy != "status" && sortBy != "id" {
+		switch sortBy {
+		case "started", "finished", "status", "priority", "title", "id":
+		default:
 			return nil, apierr.BadRequestf("Invalid sort_by parameter: %s", sortBy)
 		}
 		query.SortBy = sortBy
@@ -107,8 +166,9 @@ func ParseTaskQuery(values url.Values) (*TaskQuery, error) {
 
 // GenerateCursor creates a cursor string for pagination
 func GenerateCursor(id string, started time.Time) string {
-	// Simple cursor format: timestamp_id
-	return fmt.Sprintf("%d_%s", started.Unix(), id)
+	// Cursor format: nanosecond timestamp_id, precise enough to order tasks
+	// started within the same second
+	return fmt.Sprintf("%d_%s", started.UnixNano(), id)
 }
 
 // ParseCursor extracts timestamp and ID from cursor string
@@ -123,5 +183,5 @@ func ParseCursor(cursor string) (time.Time, string, error) {
 		return time.Time{}, "", apierr.BadRequest("Invalid cursor timestamp")
 	}
 
-	return time.Unix(timestamp, 0), parts[1], nil
+	return time.Unix(0, timestamp), parts[1], nil
 }
@@ -1,7 +1,9 @@
 package query
 
 import (
+	"fmt"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -20,6 +22,7 @@ func TestParseTaskQuery_Defaults(t *testing.T) {
 	assert.Empty(t, query.Status)
 	assert.Nil(t, query.StartedBefore)
 	assert.Nil(t, query.StartedAfter)
+	assert.Empty(t, query.Project)
 	assert.Equal(t, "started", query.SortBy)
 	assert.Equal(t, "desc", query.SortOrder)
 }
@@ -64,6 +67,63 @@ func TestParseTaskQuery_Cursor(t *testing.T) {
 	assert.Equal(t, "test_cursor_123", query.Cursor)
 }
 
+func TestParseTaskQuery_Project(t *testing.T) {
+	values := url.Values{"project": {"infra"}}
+	query, err := ParseTaskQuery(values)
+	require.NoError(t, err)
+
+	assert.Equal(t, "infra", query.Project)
+}
+
+func TestParseTaskQuery_Owner(t *testing.T) {
+	values := url.Values{"owner": {"me"}}
+	query, err := ParseTaskQuery(values)
+	require.NoError(t, err)
+
+	assert.Equal(t, "me", query.Owner)
+}
+
+func TestParseTaskQuery_Metadata(t *testing.T) {
+	values := url.Values{
+		"tag":      {"bug, auth", "ui"},
+		"priority": {"high,medium"},
+		"q":        {" login "},
+	}
+	query, err := ParseTaskQuery(values)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"bug", "auth", "ui"}, query.Tags)
+	assert.Equal(t, []string{"high", "medium"}, query.Priorities)
+	assert.Equal(t, "login", query.Q)
+}
+
+func TestParseTaskQuery_IDs(t *testing.T) {
+	query, err := ParseTaskQuery(url.Values{"ids": {"b, a,,b"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "a"}, query.IDs)
+	assert.Equal(t, 50, query.Limit)
+
+	batch := func(n int) string {
+		ids := make([]string, n)
+		for i := range ids {
+			ids[i] = fmt.Sprintf("t%d", i)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	// Large batches fit on one page unless a limit is given
+	query, err = ParseTaskQuery(url.Values{"ids": {batch(80)}})
+	require.NoError(t, err)
+	assert.Equal(t, 80, query.Limit)
+
+	query, err = ParseTaskQuery(url.Values{"ids": {batch(80)}, "limit": {"10"}})
+	require.NoError(t, err)
+	assert.Equal(t, 10, query.Limit)
+
+	_, err = ParseTaskQuery(url.Values{"ids": {batch(101)}})
+	assert.Error(t, err)
+}
+
 func TestParseTaskQuery_Status(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -73,6 +133,11 @@ func TestParseTaskQuery_Status(t *testing.T) {
 	}{
 		{"single status", "running", []string{"running"}, false},
 		{"multiple statuses", "running,stopped", []string{"running", "stopped"}, false},
+		{"interrupted", "interrupted", []string{"interrupted"}, false},
+		{"aborted", "aborted", []string{"aborted"}, false},
+		{"failed", "failed", []string{"failed"}, false},
+		{"completed", "completed", []string{"completed"}, false},
+		{"all statuses", "running,stopped,interrupted,aborted,failed,completed", []string{"running", "stopped", "interrupted", "aborted", "failed", "completed"}, false},
 		{"with spaces", "running, stopped", []string{"running", "stopped"}, false},
 		{"invalid status", "invalid", nil, true},
 		{"mixed valid/invalid", "running,invalid", nil, true},
@@ -143,6 +208,9 @@ func TestParseTaskQuery_Sorting(t *testing.T) {
 		{"valid sort by started", "started", "asc", "started", "asc", false},
 		{"valid sort by status", "status", "desc", "status", "desc", false},
 		{"valid sort by id", "id", "asc", "id", "asc", false},
+		{"valid sort by finished", "finished", "desc", "finished", "desc", false},
+		{"valid sort by priority", "priority", "desc", "priority", "desc", false},
+		{"valid sort by title", "title", "asc", "title", "asc", false},
 		{"invalid sort by", "invalid", "asc", "", "", true},
 		{"invalid sort order", "started", "invalid", "", "", true},
 	}
@@ -172,20 +240,20 @@ func TestParseTaskQuery_Sorting(t *testing.T) {
 }
 
 func TestGenerateCursor(t *testing.T) {
-	testTime := time.Unix(1672531200, 0) // 2023-01-01 00:00:00 UTC
+	testTime := time.Unix(1672531200, 250) // 2023-01-01 00:00:00 UTC
 	testID := "abc123"
 
 	cursor := GenerateCursor(testID, testTime)
-	assert.Equal(t, "1672531200_abc123", cursor)
+	assert.Equal(t, "1672531200000000250_abc123", cursor)
 }
 
 func TestParseCursor(t *testing.T) {
 	t.Run("valid cursor", func(t *testing.T) {
-		cursor := "1672531200_abc123"
+		cursor := "1672531200000000250_abc123"
 		timestamp, id, err := ParseCursor(cursor)
 		require.NoError(t, err)
 
-		expectedTime := time.Unix(1672531200, 0)
+		expectedTime := time.Unix(1672531200, 250)
 		assert.Equal(t, expectedTime, timestamp)
 		assert.Equal(t, "abc123", id)
 	})
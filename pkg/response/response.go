@@ -1,8 +1,14 @@
 package response
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"net/http"
+	"strings"
+
+	"github.com/brettsmith212/amp-orchestrator-2/pkg/apierr"
 )
 
 // JSON sends a JSON response with the given status code and payload
@@ -22,6 +28,42 @@ func OK(w http.ResponseWriter, payload interface{}) error {
 	return JSON(w, http.StatusOK, payload)
 }
 
+// OKWithETag sends a 200 OK response with JSON payload and an ETag computed
+// from it. When the request's If-None-Match already names that ETag, a 304 Not
+// Modified response without a body is sent instead, so pollers don't download
+// an unchanged payload again.
+func OKWithETag(w http.ResponseWriter, r *http.Request, payload interface{}) error {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(payload); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(body.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(body.Bytes())
+	return err
+}
+
+// etagMatches reports whether an If-None-Match header names etag. Weak
+// validators match their strong counterparts, as the header's weak comparison
+// requires.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
 // Created sends a 201 Created response with JSON payload
 func Created(w http.ResponseWriter, payload interface{}) error {
 	return JSON(w, http.StatusCreated, payload)
@@ -44,7 +86,23 @@ func Text(w http.ResponseWriter, statusCode int, message string) {
 	w.Write([]byte(message))
 }
 
-// Error sends an error response with plain text
+// ErrorBody is the JSON envelope returned for every API error
+type ErrorBody struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// Error sends a JSON error response with the default code for the status
 func Error(w http.ResponseWriter, statusCode int, message string) {
-	Text(w, statusCode, message)
+	ErrorJSON(w, statusCode, ErrorBody{
+		Code:    apierr.DefaultCode(statusCode),
+		Message: message,
+	})
+}
+
+// ErrorJSON sends an error response with the given envelope
+func ErrorJSON(w http.ResponseWriter, statusCode int, body ErrorBody) {
+	JSON(w, statusCode, body)
 }
@@ -72,6 +72,37 @@ func TestOK(t *testing.T) {
 	assert.Contains(t, w.Body.String(), `"status":"ok"`)
 }
 
+func TestOKWithETag(t *testing.T) {
+	payload := map[string]string{"message": "success"}
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/", nil)
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		require.NoError(t, OKWithETag(w, r, payload))
+		return w
+	}
+
+	w := get("")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"message":"success"}`, w.Body.String())
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	for _, header := range []string{etag, `"other", ` + etag, "W/" + etag, "*"} {
+		w = get(header)
+		assert.Equal(t, http.StatusNotModified, w.Code, header)
+		assert.Empty(t, w.Body.String())
+		assert.Equal(t, etag, w.Header().Get("ETag"))
+	}
+
+	w = get(`"other"`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	payload["message"] = "changed"
+	assert.NotEqual(t, etag, get("").Header().Get("ETag"))
+}
+
 func TestCreated(t *testing.T) {
 	w := httptest.NewRecorder()
 	payload := map[string]string{"id": "123"}
@@ -119,6 +150,6 @@ func TestError(t *testing.T) {
 	Error(w, http.StatusBadRequest, "invalid input")
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
-	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
-	assert.Equal(t, "invalid input", w.Body.String())
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"code":"bad_request","message":"invalid input"}`, w.Body.String())
 }
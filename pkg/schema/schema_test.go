@@ -0,0 +1,60 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type base struct {
+	ID string `json:"id"`
+}
+
+type sample struct {
+	base
+	Name     string            `json:"name"`
+	Count    int               `json:"count,omitempty"`
+	Ratio    float64           `json:"ratio"`
+	Enabled  bool              `json:"enabled"`
+	Started  time.Time         `json:"started"`
+	Tags     []string          `json:"tags,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Parent   *base             `json:"parent"`
+	Extra    interface{}       `json:"extra,omitempty"`
+	Raw      json.RawMessage   `json:"raw,omitempty"`
+	Internal string            `json:"-"`
+	Untagged string
+	hidden   string
+}
+
+func TestFor_Struct(t *testing.T) {
+	s := For(sample{})
+
+	assert.Equal(t, "object", s.Type)
+	assert.ElementsMatch(t, []string{"id", "name", "ratio", "enabled", "started", "Untagged"}, s.Required)
+
+	assert.Equal(t, "string", s.Properties["id"].Type)
+	assert.Equal(t, "integer", s.Properties["count"].Type)
+	assert.Equal(t, "number", s.Properties["ratio"].Type)
+	assert.Equal(t, "boolean", s.Properties["enabled"].Type)
+	assert.Equal(t, &Schema{Type: "string", Format: "date-time"}, s.Properties["started"])
+	assert.Equal(t, &Schema{Type: "array", Items: &Schema{Type: "string"}}, s.Properties["tags"])
+	assert.Equal(t, &Schema{Type: "object", AdditionalProperties: &Schema{Type: "string"}}, s.Properties["labels"])
+	assert.Equal(t, "object", s.Properties["parent"].Type)
+	assert.Equal(t, &Schema{}, s.Properties["extra"])
+	assert.Equal(t, &Schema{}, s.Properties["raw"])
+
+	assert.NotContains(t, s.Properties, "Internal")
+	assert.NotContains(t, s.Properties, "hidden")
+	assert.Contains(t, s.Properties, "Untagged")
+}
+
+func TestFor_MarshalsAsJSONSchema(t *testing.T) {
+	data, err := json.Marshal(For(map[string]int{}))
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{"type":"object","additionalProperties":{"type":"integer"}}`, string(data))
+}